@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/stripe"
+)
+
+// productionBanner prints a banner naming the Stripe account before a command
+// changes production. It goes to stderr even in quiet mode: it is a safety
+// notice, not progress output. Other environments print nothing and return nil.
+func productionBanner(c *cli.Context, client *stripe.Client, action string) (*stripe.Account, error) {
+	if client.GetEnv() != stripe.Production {
+		return nil, nil
+	}
+
+	account, err := client.Account(c.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	name := account.ID
+	if account.Name != "" {
+		name = fmt.Sprintf("%s (%s)", account.ID, account.Name)
+	}
+	w := getErrorOutput(c)
+	printBanner(w, "Stripe account "+name, action, useColor(w))
+	return account, nil
+}
+
+// targetBanner prints the production banner for a system other than Stripe,
+// such as a plugin provider or a feature flag environment, when env is
+// production. There is no account to look up, so target names what changes.
+func targetBanner(c *cli.Context, env, target, action string) {
+	if env != "production" {
+		return
+	}
+	w := getErrorOutput(c)
+	printBanner(w, target, action, useColor(w))
+}
+
+// printBanner writes the production banner for target, in red when color is set
+func printBanner(w io.Writer, target, action string, color bool) {
+	lines := []string{
+		"PRODUCTION  " + target,
+		action,
+	}
+
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line))
+	}
+	rule := strings.Repeat("=", width+4)
+
+	if color {
+		fmt.Fprint(w, "\033[1;37;41m")
+	}
+	fmt.Fprintln(w, rule)
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %-*s  \n", width, line)
+	}
+	fmt.Fprint(w, rule)
+	if color {
+		fmt.Fprint(w, "\033[0m")
+	}
+	fmt.Fprintln(w)
+}
+
+// useColor reports whether w is a terminal and NO_COLOR isn't set
+func useColor(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmAccount makes destructive production operations name the account they
+// target: --confirm-account when given, otherwise the ID typed at a prompt
+func confirmAccount(c *cli.Context, account *stripe.Account) error {
+	typed := c.String("confirm-account")
+	if typed == "" {
+		w := getResultOutput(c)
+		fmt.Fprintf(w, "This can't be undone. Type the account ID (%s) to continue: ", account.ID)
+		_, _ = fmt.Scanln(&typed) // Error ignored: empty response doesn't match
+	}
+	return checkAccountConfirmation(typed, account)
+}
+
+// checkAccountConfirmation compares a typed account ID with the real one
+func checkAccountConfirmation(typed string, account *stripe.Account) error {
+	if strings.TrimSpace(typed) != account.ID {
+		return fmt.Errorf("account ID doesn't match %s; nothing was changed (pass --confirm-account %s when running non-interactively)", account.ID, account.ID)
+	}
+	return nil
+}
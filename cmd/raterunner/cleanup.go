@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/stripe"
+)
+
+func cleanupAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	env, stripeEnv, err := resolveStripeEnv(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+	metaKeys, err := cfg.MetadataKeys()
+	if err != nil {
+		return err
+	}
+
+	// Anything the provider file doesn't reference counts as unused, so a missing
+	// file must not be treated as empty
+	providerPath := config.ProviderFilePath(filePath, "stripe", env)
+	providerCfg, err := loadProviderFile(providerPath)
+	if err != nil {
+		return fmt.Errorf("failed to load %s (run 'raterunner apply --env %s' first): %w", providerPath, env, err)
+	}
+
+	client, err := connectStripe(c, stripeEnv)
+	if err != nil {
+		return err
+	}
+	client.SetMetadataKeys(metaKeys, false)
+
+	plan, err := client.PlanCleanup(c.Context, providerCfg)
+	if err != nil {
+		return fmt.Errorf("cleanup failed: %w", err)
+	}
+
+	// The listing is the command's result, so it is written even in quiet mode
+	listOut := getResultOutput(c)
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal cleanup plan: %w", err)
+		}
+		fmt.Fprintln(listOut, string(data))
+	} else {
+		printCleanupPlan(listOut, plan, providerPath)
+	}
+
+	archive, remove, stuck := countCleanupActions(plan)
+	if !c.Bool("delete-where-possible") || archive+remove == 0 {
+		printSummary(c, "ok",
+			summaryField{"env", env},
+			summaryField{"to_archive", archive},
+			summaryField{"to_delete", remove},
+			summaryField{"not_removable", stuck})
+		return nil
+	}
+
+	account, err := productionBanner(c, client, "cleanup archives and deletes live products and prices")
+	if err != nil {
+		return err
+	}
+
+	// Production needs the account ID retyped, even with --confirm. Elsewhere the
+	// interactive confirmation is always shown (even in quiet mode).
+	if account != nil {
+		if err := confirmAccount(c, account); err != nil {
+			return err
+		}
+	} else if !c.Bool("confirm") {
+		fmt.Fprintf(listOut, "Archive %d and delete %d object(s) in Stripe %s? [y/N]: ", archive, remove, env)
+
+		var response string
+		_, _ = fmt.Scanln(&response) // Error ignored: empty response treated as "no"
+		response = strings.ToLower(strings.TrimSpace(response))
+
+		if response != "y" && response != "yes" {
+			fmt.Fprintln(listOut, "Aborted.")
+			return nil
+		}
+	}
+
+	result, err := client.ApplyCleanup(c.Context, plan)
+	if err != nil {
+		return fmt.Errorf("cleanup failed: %w", err)
+	}
+
+	fmt.Fprintf(getOutput(c), "Done. Archived %d prices, %d products. Deleted %d products.\n",
+		result.PricesArchived, result.ProductsArchived, result.ProductsDeleted)
+
+	printSummary(c, "ok",
+		summaryField{"env", env},
+		summaryField{"prices_archived", result.PricesArchived},
+		summaryField{"products_archived", result.ProductsArchived},
+		summaryField{"products_deleted", result.ProductsDeleted})
+	return nil
+}
+
+// printCleanupPlan lists leftover objects and what cleanup would do with each
+func printCleanupPlan(w io.Writer, plan *stripe.CleanupPlan, providerPath string) {
+	if len(plan.UnusedProducts) == 0 && len(plan.DuplicatePrices) == 0 {
+		fmt.Fprintln(w, "✓ Nothing to clean up")
+		return
+	}
+
+	if len(plan.UnusedProducts) > 0 {
+		fmt.Fprintf(w, "Products not referenced by %s:\n", providerPath)
+		for _, item := range plan.UnusedProducts {
+			fmt.Fprintf(w, "  %-8s %s %s%s\n", cleanupLabel(item.Action), item.ID, item.Summary, archivedNote(item.Active))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(plan.DuplicatePrices) > 0 {
+		fmt.Fprintln(w, "Duplicate prices:")
+		for _, item := range plan.DuplicatePrices {
+			fmt.Fprintf(w, "  %-8s %s on %s: %s%s (%s)\n", cleanupLabel(item.Action), item.ID, item.ProductID, item.Summary, archivedNote(item.Active), item.Reason)
+		}
+		fmt.Fprintln(w)
+	}
+
+	archive, remove, stuck := countCleanupActions(plan)
+	fmt.Fprintf(w, "%d to archive, %d to delete, %d can't be removed (Stripe keeps archived prices).\n", archive, remove, stuck)
+}
+
+// cleanupLabel formats an action for the listing
+func cleanupLabel(action stripe.CleanupAction) string {
+	if action == stripe.CleanupNone {
+		return "[keep]"
+	}
+	return "[" + string(action) + "]"
+}
+
+// archivedNote marks objects that are already archived
+func archivedNote(active bool) string {
+	if active {
+		return ""
+	}
+	return " [archived]"
+}
+
+// countCleanupActions counts items by action
+func countCleanupActions(plan *stripe.CleanupPlan) (archive, remove, stuck int) {
+	for _, items := range [][]stripe.CleanupItem{plan.UnusedProducts, plan.DuplicatePrices} {
+		for _, item := range items {
+			switch item.Action {
+			case stripe.CleanupArchive:
+				archive++
+			case stripe.CleanupDelete:
+				remove++
+			default:
+				stuck++
+			}
+		}
+	}
+	return archive, remove, stuck
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/provider"
+	"raterunner/internal/stripe"
+)
+
+// cloneAction imports one environment's catalog and applies it to another, so
+// the target ends up with its own products and prices matching the source
+func cloneAction(c *cli.Context) error {
+	from, to := c.String("from"), c.String("to")
+	sourceEnv, err := parseEnv(from)
+	if err != nil {
+		return err
+	}
+	targetEnv, err := parseEnv(to)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return fmt.Errorf("--from and --to are both %s", from)
+	}
+	if to == "production" {
+		return fmt.Errorf("clone only writes to sandbox; review the imported config and use 'raterunner apply --env production' instead")
+	}
+	outputPath := c.String("output")
+	out := getOutput(c)
+
+	source, err := connectStripe(c, sourceEnv)
+	if err != nil {
+		return err
+	}
+	target, err := connectStripe(c, targetEnv)
+	if err != nil {
+		return err
+	}
+
+	// Import from the source, as 'raterunner import' does
+	prefixed := &config.BillingConfig{Settings: &config.Settings{MetadataPrefix: c.String("metadata-prefix")}}
+	metaKeys, err := prefixed.MetadataKeys()
+	if err != nil {
+		return err
+	}
+	source.SetMetadataKeys(metaKeys, false)
+
+	fmt.Fprintf(out, "Importing from Stripe (%s)...\n", from)
+	imported, err := provider.NewStripe(source).Import(c.Context)
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+	cfg := imported.Billing
+	if prefix := c.String("metadata-prefix"); prefix != "" {
+		cfg.Settings = &config.Settings{MetadataPrefix: prefix}
+	}
+	if err := config.SaveBillingFile(outputPath, cfg); err != nil {
+		return fmt.Errorf("failed to save billing file: %w", err)
+	}
+	sourcePath := config.ProviderFilePath(outputPath, "stripe", from)
+	if err := saveProviderFile(c, sourcePath, imported.Provider); err != nil {
+		return fmt.Errorf("failed to save provider file: %w", err)
+	}
+	fmt.Fprintf(out, "Imported %d plans to %s\n", len(cfg.Plans), outputPath)
+
+	if !c.Bool("skip-validation") {
+		if err := checkBilling(c, outputPath, nil); err != nil {
+			return err
+		}
+	}
+
+	// Apply to the target, creating whatever it doesn't have yet
+	target.SetMetadataKeys(metaKeys, false)
+	catalogVersion := config.CatalogVersion(cfg.CatalogHash(), gitCommit(filepath.Dir(outputPath)))
+	target.SetCatalogVersion(catalogVersion)
+
+	release, err := acquireRunLock(c, target, stripe.Environment(to))
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	fmt.Fprintf(out, "Syncing to Stripe (%s)...\n", to)
+	result, err := provider.NewStripe(target).Sync(c.Context, cfg)
+	var syncErrs stripe.SyncErrors
+	if err != nil && !errors.As(err, &syncErrs) {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+	for _, w := range sortedWarnings(c, result.Warnings) {
+		fmt.Fprintf(out, "  WARNING: %s\n", w)
+	}
+
+	targetPath := config.ProviderFilePath(outputPath, "stripe", to)
+	if err := saveProviderFile(c, targetPath, syncedProvider(to, catalogVersion, result)); err != nil {
+		return fmt.Errorf("failed to save provider file: %w", err)
+	}
+
+	fmt.Fprintf(out, "Cloned %d plans from %s to %s. Products: %d created, %d updated, %d unchanged. Prices: %d created.\n",
+		len(cfg.Plans), from, to, result.ProductsCreated, result.ProductsUpdated, result.ProductsUnchanged, result.PricesCreated)
+	fmt.Fprintf(out, "Saved provider IDs to %s and %s\n", sourcePath, targetPath)
+
+	status := "ok"
+	if len(syncErrs) > 0 {
+		status = "failed"
+	}
+	printSummary(c, status,
+		summaryField{"from", from},
+		summaryField{"to", to},
+		summaryField{"plans", len(cfg.Plans)},
+		summaryField{"output", outputPath},
+		summaryField{"provider_file", targetPath},
+		summaryField{"products_created", result.ProductsCreated},
+		summaryField{"prices_created", result.PricesCreated})
+
+	if len(syncErrs) > 0 {
+		return fmt.Errorf("sync failed: %w; the other changes were applied and %s was saved", syncErrs, targetPath)
+	}
+	return nil
+}
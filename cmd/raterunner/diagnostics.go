@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	stripeapi "github.com/stripe/stripe-go/v82"
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diagnostics"
+	"raterunner/internal/stripe"
+)
+
+// diagnoseCommands wraps the action of every command, subcommands included,
+// so a panic or an unexpected Stripe failure leaves a redacted diagnostics
+// bundle behind, or a hint on how to get one
+func diagnoseCommands(commands []*cli.Command, parent string) {
+	wrapActions(commands, parent, func(name string, action cli.ActionFunc) cli.ActionFunc {
+		return func(c *cli.Context) (err error) {
+			tail := &diagnostics.Tail{}
+			c.App.Writer = io.MultiWriter(getResultOutput(c), tail)
+			c.App.ErrWriter = io.MultiWriter(getErrorOutput(c), tail)
+
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("internal error: %v", r)
+					reportCrash(c, name, tail, err, string(debug.Stack()))
+				}
+			}()
+
+			err = action(c)
+			if unexpectedFailure(err) {
+				reportCrash(c, name, tail, err, "")
+			}
+			return err
+		}
+	})
+}
+
+// unexpectedFailure reports whether err came back from the Stripe API for a
+// reason other than the key, its permissions, rate limits or Ctrl-C, which
+// point at raterunner rather than at the user's setup
+func unexpectedFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var syncErrs stripe.SyncErrors
+	if errors.As(err, &syncErrs) {
+		return slices.ContainsFunc(syncErrs, func(e stripe.SyncError) bool {
+			return unexpectedFailure(e)
+		})
+	}
+	var stripeErr *stripeapi.Error
+	if !errors.As(err, &stripeErr) {
+		return false
+	}
+	switch stripeErr.HTTPStatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return stripeErr.Type != stripeapi.ErrorTypeCard
+}
+
+// diagnosticsDir returns --diagnostics-dir, falling back to the
+// diagnostics_dir setting
+func diagnosticsDir(c *cli.Context) string {
+	if dir := c.String("diagnostics-dir"); dir != "" {
+		return dir
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		return ""
+	}
+	return settings.DiagnosticsDir
+}
+
+// reportCrash writes a diagnostics bundle when a directory is set and
+// otherwise tells the user how to get one. It is shown even with --quiet.
+func reportCrash(c *cli.Context, command string, tail *diagnostics.Tail, err error, stack string) {
+	out := getErrorOutput(c)
+
+	dir := diagnosticsDir(c)
+	if dir == "" {
+		fmt.Fprintln(out, "This looks like a bug in raterunner. Rerun with --diagnostics-dir <dir> to write a redacted diagnostics bundle to attach to a bug report.")
+		return
+	}
+
+	bundle := &diagnostics.Bundle{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Command:   command,
+		Error:     err.Error(),
+		Stack:     stack,
+		Log:       tail.Lines(),
+	}
+	for _, name := range c.FlagNames() {
+		if len(name) > 1 && !slices.Contains(bundle.Flags, name) {
+			bundle.Flags = append(bundle.Flags, name)
+		}
+	}
+	slices.Sort(bundle.Flags)
+	if path := diagnosticsConfigFile(c); path != "" {
+		if content, readErr := os.ReadFile(path); readErr == nil {
+			if structure, shapeErr := diagnostics.Structure(content); shapeErr == nil {
+				bundle.ConfigFile = filepath.Base(path)
+				bundle.ConfigStructure = structure
+			}
+		}
+	}
+
+	path, writeErr := diagnostics.Write(dir, bundle, time.Now())
+	if writeErr != nil {
+		fmt.Fprintf(out, "warning: %v\n", writeErr)
+		return
+	}
+	fmt.Fprintf(out, "Diagnostics written to %s. Check it, then attach it to a bug report.\n", path)
+}
+
+// diagnosticsConfigFile returns the config the command ran on: the first
+// argument naming a YAML or JSON file, or the billing file found from the
+// working directory
+func diagnosticsConfigFile(c *cli.Context) string {
+	for _, arg := range c.Args().Slice() {
+		switch strings.ToLower(filepath.Ext(arg)) {
+		case ".yaml", ".yml", ".json":
+			if _, err := os.Stat(arg); err == nil {
+				return arg
+			}
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	path, err := config.FindBillingFile(wd)
+	if err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/stripe"
+	"raterunner/internal/validator"
+)
+
+func doctorAction(c *cli.Context) error {
+	// The report is the command's result, so it is written even in quiet mode
+	out := getResultOutput(c)
+
+	problems := 0
+	pass := func(format string, args ...any) {
+		fmt.Fprintf(out, "✓ "+format+"\n", args...)
+	}
+	fail := func(format string, args ...any) {
+		problems++
+		fmt.Fprintf(out, "✗ "+format+"\n", args...)
+	}
+	skip := func(format string, args ...any) {
+		fmt.Fprintf(out, "- "+format+"\n", args...)
+	}
+	warn := func(format string, args ...any) {
+		fmt.Fprintf(out, "! "+format+"\n", args...)
+	}
+
+	// Billing config
+	var cfg *config.BillingConfig
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		fail("billing config: %v", err)
+	} else {
+		result, err := validator.New().ValidateBillingFile(filePath)
+		if err == nil {
+			var content []byte
+			if content, err = config.ReadFile(filePath); err == nil {
+				_, err = suppressValidationErrors(result, content)
+			}
+		}
+		switch {
+		case err != nil:
+			fail("billing config %s: %v", filePath, err)
+		case !result.Valid:
+			fail("billing config %s has %d validation error(s) (run 'raterunner validate')", filePath, len(result.Errors))
+		default:
+			pass("billing config %s is valid", filePath)
+			cfg, err = config.LoadBillingFile(filePath)
+			if err != nil {
+				fail("billing config %s: %v", filePath, err)
+			}
+		}
+	}
+
+	// Stripe credentials
+	var client *stripe.Client
+	env, stripeEnv, err := resolveStripeEnv(c)
+	if err != nil {
+		fail("environment: %v", err)
+	} else {
+		if client, err = connectStripe(c, stripeEnv); err != nil {
+			fail("Stripe API key: %v", err)
+		} else {
+			pass("Stripe API key matches the %s environment", env)
+		}
+	}
+
+	// Stripe API version. raterunner's own requests are pinned, so drift only
+	// shows in what the account renders itself: events, webhooks, the Dashboard.
+	if client != nil {
+		accountVersion, err := client.AccountAPIVersion(c.Context)
+		switch {
+		case err != nil:
+			fail("Stripe API version: %v", err)
+		case accountVersion == "":
+			skip("Stripe API version: raterunner uses %s (no events in the last 30 days show the account's default)", stripe.APIVersion)
+		case stripe.SameAPIRelease(accountVersion, stripe.APIVersion):
+			pass("Stripe API version %s is in the same release as the account's default (%s)", stripe.APIVersion, accountVersion)
+		default:
+			warn("Stripe API version: raterunner uses %s, the account defaults to the %s %s. Apply and status are unaffected, but events, webhooks and Dashboard views use the account's version, so field names may differ from raterunner's output", stripe.APIVersion, stripe.APIVersionAge(accountVersion), accountVersion)
+		}
+	}
+
+	// Stripe Tax
+	switch {
+	case cfg == nil || !cfg.AutomaticTax():
+		skip("Stripe Tax: not required (settings.automatic_tax is off)")
+	case client == nil:
+		fail("Stripe Tax: can't check without a valid API key")
+	default:
+		status, err := client.TaxStatus(c.Context)
+		switch {
+		case err != nil:
+			fail("Stripe Tax: %v", err)
+		case status != stripe.TaxActive:
+			fail("Stripe Tax is %s, but settings.automatic_tax requires it to be active (Stripe Dashboard → Settings → Tax)", status)
+		default:
+			pass("Stripe Tax is active")
+		}
+	}
+
+	if problems > 0 {
+		printSummary(c, "problems", summaryField{"problems", problems})
+		return fmt.Errorf("doctor found %d problem(s)", problems)
+	}
+
+	fmt.Fprintln(out, "\nNo problems found.")
+	printSummary(c, "ok", summaryField{"problems", 0})
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/stripe"
+)
+
+// eventRetention is how long Stripe keeps events
+const eventRetention = 30 * 24 * time.Hour
+
+func eventsAction(c *cli.Context) error {
+	env, stripeEnv, err := resolveStripeEnv(c)
+	if err != nil {
+		return err
+	}
+
+	since, err := parseSince(c.String("since"), time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := connectStripe(c, stripeEnv)
+	if err != nil {
+		return err
+	}
+
+	events, err := client.FetchEvents(c.Context, since)
+	if err != nil {
+		return err
+	}
+	noteEventAPIVersions(c, events)
+
+	// The listing is the command's result, so it is written even in quiet mode
+	out := getResultOutput(c)
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(events, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal events: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	} else {
+		printEvents(out, events, since)
+	}
+
+	printSummary(c, "ok",
+		summaryField{"env", env},
+		summaryField{"events", len(events)})
+	return nil
+}
+
+// noteEventAPIVersions tells the user when events were rendered in another
+// release than raterunner's, since their changed field names follow that release
+func noteEventAPIVersions(c *cli.Context, events []stripe.Event) {
+	counts := make(map[string]int)
+	var versions []string
+	for _, e := range events {
+		if e.APIVersion == "" || stripe.SameAPIRelease(e.APIVersion, stripe.APIVersion) {
+			continue
+		}
+		if counts[e.APIVersion] == 0 {
+			versions = append(versions, e.APIVersion)
+		}
+		counts[e.APIVersion]++
+	}
+	for _, v := range versions {
+		fmt.Fprintf(getNoticeOutput(c), "note: %d event(s) use the %s Stripe API version %s (raterunner uses %s); their changed fields are named as in %s\n",
+			counts[v], stripe.APIVersionAge(v), v, stripe.APIVersion, v)
+	}
+}
+
+// parseSince turns a --since value into a start time. It accepts Go durations
+// (90m, 24h), whole days (7d), RFC 3339 timestamps, and YYYY-MM-DD dates.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	var since time.Time
+
+	switch {
+	case strings.HasSuffix(value, "d"):
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil || days <= 0 {
+			return time.Time{}, fmt.Errorf("invalid --since value: %s (use e.g. 24h, 7d, or 2026-01-31)", value)
+		}
+		since = now.Add(-time.Duration(days) * 24 * time.Hour)
+	default:
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			since = now.Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, value); err == nil {
+			since = t
+		} else if t, err := time.Parse("2006-01-02", value); err == nil {
+			since = t
+		} else {
+			return time.Time{}, fmt.Errorf("invalid --since value: %s (use e.g. 24h, 7d, or 2026-01-31)", value)
+		}
+	}
+
+	if since.After(now) {
+		return time.Time{}, fmt.Errorf("invalid --since value: %s is in the future", value)
+	}
+	if now.Sub(since) > eventRetention {
+		return time.Time{}, fmt.Errorf("invalid --since value: %s (Stripe only keeps events for 30 days)", value)
+	}
+	return since, nil
+}
+
+// printEvents lists events one per line, oldest first
+func printEvents(w io.Writer, events []stripe.Event, since time.Time) {
+	if len(events) == 0 {
+		fmt.Fprintf(w, "No product, price, coupon, or promotion code events since %s\n", since.UTC().Format(time.RFC3339))
+		return
+	}
+
+	for _, e := range events {
+		line := fmt.Sprintf("%s  %-10s %-28s %s", e.Created.Format("2006-01-02 15:04:05"), e.Actor, e.Type, e.ObjectID)
+		if len(e.Changed) > 0 {
+			line += " (changed: " + strings.Join(e.Changed, ", ") + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "\n%d event(s) since %s (times in UTC)\n", len(events), since.UTC().Format(time.RFC3339))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/export"
+)
+
+func exportAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	style, err := export.ParseUnlimitedStyle(c.String("unlimited"))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+
+	opts := export.Options{Unlimited: style, PublicOnly: c.Bool("public-only"), Region: c.String("region")}
+	if env := c.String("env"); env != "" {
+		if env != "sandbox" && env != "production" {
+			return fmt.Errorf("invalid environment: %s (use 'sandbox' or 'production')", env)
+		}
+		providerPath := config.ProviderFilePath(filePath, "stripe", env)
+		if opts.Provider, err = loadProviderFile(providerPath); err != nil {
+			return fmt.Errorf("failed to load %s (run 'raterunner apply --env %s' first): %w", providerPath, env, err)
+		}
+	}
+
+	bundle, err := export.Build(cfg, opts)
+	if err != nil {
+		return fmt.Errorf("failed to build export: %w", err)
+	}
+
+	outputPath := c.String("output")
+	if outputPath != "" {
+		if err := writeExportFile(outputPath, bundle); err != nil {
+			return err
+		}
+		fmt.Fprintf(getOutput(c), "Exported %d plans to %s\n", len(bundle.Plans), outputPath)
+		printSummary(c, "ok", summaryField{"plans", len(bundle.Plans)}, summaryField{"output", outputPath})
+		return nil
+	}
+
+	// The export itself is the command's result, so it is written even in quiet mode
+	w := getResultOutput(c)
+	if err := export.WriteJSON(w, bundle); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// writeExportFile writes an export bundle to path
+func writeExportFile(path string, bundle *export.Bundle) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	if err := export.WriteJSON(f, bundle); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/flags"
+)
+
+func flagsSyncAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+	out := getOutput(c)
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+
+	flagList := flags.BuildFlags(cfg)
+	if len(flagList) == 0 {
+		fmt.Fprintln(out, "No bool entitlements found, nothing to sync.")
+		return nil
+	}
+
+	if c.Bool("dry-run") {
+		for _, f := range flagList {
+			plans := "(none)"
+			if len(f.EnabledPlans) > 0 {
+				plans = strings.Join(f.EnabledPlans, ", ")
+			}
+			fmt.Fprintf(out, "%-20s enabled for: %s\n", f.Key, plans)
+		}
+		return nil
+	}
+
+	provider, err := newFlagProvider(c)
+	if err != nil {
+		return err
+	}
+
+	project, environment := c.String("project"), c.String("environment")
+	if project == "" {
+		project = "default" // Unleash's default; LaunchDarkly requires --project
+	}
+	targetBanner(c, environment, fmt.Sprintf("%s project %s, environment %s", provider.Name(), project, environment), "flags sync changes live feature flag targeting")
+
+	fmt.Fprintf(out, "Syncing %d flag(s) to %s...\n", len(flagList), provider.Name())
+
+	result, err := flags.Sync(provider, flagList)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "Done. Flags synced: %d.\n", result.FlagsSynced)
+	printSummary(c, "ok", summaryField{"provider", provider.Name()}, summaryField{"flags_synced", result.FlagsSynced})
+	return nil
+}
+
+// newFlagProvider builds the feature flag adapter selected by --provider
+func newFlagProvider(c *cli.Context) (flags.Provider, error) {
+	project := c.String("project")
+	environment := c.String("environment")
+
+	switch c.String("provider") {
+	case "launchdarkly":
+		return flags.NewLaunchDarkly(os.Getenv("LAUNCHDARKLY_API_TOKEN"), project, environment)
+	case "unleash":
+		return flags.NewUnleash(os.Getenv("UNLEASH_URL"), os.Getenv("UNLEASH_API_TOKEN"), project, environment)
+	default:
+		return nil, fmt.Errorf("unknown flag provider: %s (use 'launchdarkly' or 'unleash')", c.String("provider"))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/checkout"
+	"raterunner/internal/config"
+)
+
+func generateCheckoutAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	env, err := resolveEnv(c)
+	if err != nil {
+		return err
+	}
+	var keyEnv string
+	switch env {
+	case "sandbox":
+		keyEnv = "STRIPE_SANDBOX_KEY"
+	case "production":
+		keyEnv = "STRIPE_PRODUCTION_KEY"
+	default:
+		return fmt.Errorf("invalid environment: %s (use 'sandbox' or 'production')", env)
+	}
+
+	langs, err := checkout.ParseLanguages(c.String("lang"))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+
+	planID := c.String("plan")
+	plan := cfg.FindPlan(planID)
+	if plan == nil {
+		return fmt.Errorf("plan '%s' not found in %s", planID, filePath)
+	}
+	if plan.IsCustomPricing() {
+		return fmt.Errorf("plan '%s' uses pricing: custom and has no price to check out", planID)
+	}
+	if plan.IsManualInvoicing() {
+		return fmt.Errorf("plan '%s' uses invoicing: manual and is sold through sales, not checkout; create its subscriptions with collection_method=send_invoice and days_until_due=%d", planID, cfg.DaysUntilDue())
+	}
+	if plan.SendsInvoice() {
+		return fmt.Errorf("plan '%s' uses collection_method: send_invoice, which Checkout doesn't support; create its subscriptions with collection_method=send_invoice and days_until_due=%d instead", planID, cfg.DaysUntilDue())
+	}
+
+	interval, err := checkoutInterval(plan, c.String("interval"))
+	if err != nil {
+		return err
+	}
+
+	providerPath := config.ProviderFilePath(filePath, "stripe", env)
+	providerCfg, err := loadProviderFile(providerPath)
+	if err != nil {
+		return fmt.Errorf("failed to load %s (run 'raterunner apply --env %s' first): %w", providerPath, env, err)
+	}
+	priceID := providerCfg.Plans[planID].Prices[interval]
+	if priceID == "" {
+		return fmt.Errorf("no %s price ID for plan '%s' in %s (run 'raterunner apply --env %s' first)", interval, planID, providerPath, env)
+	}
+
+	session := checkout.Session{
+		PlanID:              planID,
+		Interval:            interval,
+		Environment:         env,
+		APIKeyEnv:           keyEnv,
+		PriceID:             priceID,
+		OneTime:             plan.IsOneTime(),
+		AutomaticTax:        cfg.AutomaticTax(),
+		AllowPromotionCodes: hasActivePromotion(cfg),
+		SuccessURL:          c.String("success-url"),
+		CancelURL:           c.String("cancel-url"),
+	}
+	if !plan.IsOneTime() {
+		session.TrialDays = plan.TrialDays
+	}
+	if inv := cfg.Invoice(); inv != nil {
+		session.InvoiceFooter = inv.Footer
+		for _, f := range inv.CustomFields {
+			session.InvoiceFields = append(session.InvoiceFields, checkout.InvoiceField{Name: f.Name, Value: f.Value})
+		}
+	}
+
+	// The snippets are the command's result, so they are written even in quiet mode
+	out := getResultOutput(c)
+	for i, lang := range langs {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if err := checkout.Render(out, lang, session); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkoutInterval picks the plan price to check out: the requested one, the only
+// one, or monthly when the plan has several
+func checkoutInterval(plan *config.Plan, requested string) (string, error) {
+	intervals := make([]string, 0, len(plan.Prices))
+	for interval := range plan.Prices {
+		intervals = append(intervals, interval)
+	}
+	sort.Strings(intervals)
+
+	if requested != "" {
+		if _, ok := plan.Prices[requested]; !ok {
+			return "", fmt.Errorf("plan '%s' has no %s price (available: %s)", plan.ID, requested, strings.Join(intervals, ", "))
+		}
+		return requested, nil
+	}
+
+	if len(intervals) == 1 {
+		return intervals[0], nil
+	}
+	if _, ok := plan.Prices["monthly"]; ok {
+		return "monthly", nil
+	}
+	return "", fmt.Errorf("plan '%s' has several prices; choose one with --interval (%s)", plan.ID, strings.Join(intervals, ", "))
+}
+
+// hasActivePromotion reports whether customers have promotion codes to enter
+func hasActivePromotion(cfg *config.BillingConfig) bool {
+	for _, p := range cfg.Promotions {
+		if p.IsActive() {
+			return true
+		}
+	}
+	return false
+}
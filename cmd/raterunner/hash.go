@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// hashAction prints the config's catalog hash, the part of catalog_version
+// apply compares to skip configs it has already applied
+func hashAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadBillingInput(c, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+
+	fmt.Fprintln(getResultOutput(c), cfg.CatalogHash())
+	return nil
+}
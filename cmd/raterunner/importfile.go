@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/catalog"
+	"raterunner/internal/config"
+)
+
+// importFileAction converts another billing provider's catalog export into a
+// billing config. Nothing is read from Stripe and no provider file is written:
+// the first apply creates the products and records their IDs.
+func importFileAction(c *cli.Context) error {
+	inputPath := c.String("from-file")
+	outputPath := c.String("output")
+
+	format := c.String("format")
+	if format == "" {
+		return fmt.Errorf("--format is required with --from-file (use %s)", strings.Join(catalog.Formats, ", "))
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read catalog export: %w", err)
+	}
+
+	out := getOutput(c)
+	fmt.Fprintf(out, "Converting %s catalog export %s...\n", format, inputPath)
+
+	result, err := catalog.Parse(format, data)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range result.Warnings {
+		fmt.Fprintf(out, "  WARNING: %s\n", w)
+	}
+
+	if prefix := c.String("metadata-prefix"); prefix != "" {
+		if result.Billing.Settings == nil {
+			result.Billing.Settings = &config.Settings{}
+		}
+		result.Billing.Settings.MetadataPrefix = prefix
+	}
+
+	if err := config.SaveBillingFile(outputPath, result.Billing); err != nil {
+		return fmt.Errorf("failed to save billing file: %w", err)
+	}
+
+	fmt.Fprintf(out, "Imported %d plans and %d addons to %s\n", len(result.Billing.Plans), len(result.Billing.Addons), outputPath)
+
+	printSummary(c, "ok",
+		summaryField{"format", format},
+		summaryField{"plans", len(result.Billing.Plans)},
+		summaryField{"addons", len(result.Billing.Addons)},
+		summaryField{"warnings", len(result.Warnings)},
+		summaryField{"output", outputPath})
+	return nil
+}
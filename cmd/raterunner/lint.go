@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/lint"
+)
+
+func lintAction(c *cli.Context) error {
+	if c.Bool("list-rules") {
+		return listLintRules(c)
+	}
+
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.String("format")
+	if format != "text" && format != "json" && format != "sarif" {
+		return fmt.Errorf("invalid format: %s (use 'text', 'json', or 'sarif')", format)
+	}
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+
+	content, err := config.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	sups, err := lint.ParseSuppressions(content)
+	if err != nil {
+		return fmt.Errorf("failed to read suppressions: %w", err)
+	}
+
+	enable := c.StringSlice("enable")
+	if c.Bool("copy") {
+		enable = append(enable, lint.PackCopy)
+	}
+	findings, err := lint.Run(cfg, lint.Options{
+		Enable:  enable,
+		Disable: c.StringSlice("disable"),
+
+		FeaturePeriods: c.String("feature-periods"),
+	})
+	if err != nil {
+		return err
+	}
+	findings = lint.Suppress(findings, sups)
+
+	active := 0
+	for _, f := range findings {
+		if !f.Suppressed {
+			active++
+		}
+	}
+	suppressed := len(findings) - active
+
+	// Findings are the command's result, so they are written even in quiet mode
+	out := getResultOutput(c)
+
+	switch {
+	case format == "json":
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal findings: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	case format == "sarif":
+		lines, err := lint.Lines(content)
+		if err != nil {
+			return err
+		}
+		if err := lint.WriteSARIF(out, filePath, version, findings, lines); err != nil {
+			return err
+		}
+	case active == 0:
+		fmt.Fprintf(getOutput(c), "✓ %s has no lint warnings%s\n", filePath, suppressedNote(suppressed))
+	default:
+		fmt.Fprintf(out, "⚠ %s has %d lint warning(s)%s:\n\n", filePath, active, suppressedNote(suppressed))
+		n := 0
+		for _, f := range findings {
+			if f.Suppressed {
+				continue
+			}
+			n++
+			fmt.Fprintf(out, "  %d. %s: %s [%s]\n", n, f.Path, f.Message, f.Rule)
+		}
+		fmt.Fprintln(out)
+	}
+
+	status := "ok"
+	if active > 0 {
+		status = "warnings"
+	}
+	printSummary(c, status, withSuppressed(suppressed, summaryField{"file", filePath}, summaryField{"warnings", active})...)
+
+	if c.Bool("strict") && active > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// suppressedNote formats the " (N suppressed)" suffix for result lines
+func suppressedNote(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%d suppressed)", n)
+}
+
+// withSuppressed appends a "suppressed" summary field when anything was suppressed
+func withSuppressed(n int, fields ...summaryField) []summaryField {
+	if n == 0 {
+		return fields
+	}
+	return append(fields, summaryField{"suppressed", n})
+}
+
+// listLintRules prints every rule with its pack and description
+func listLintRules(c *cli.Context) error {
+	out := getResultOutput(c)
+
+	fmt.Fprintf(out, "%-24s %-8s %s\n", "RULE", "PACK", "DESCRIPTION")
+	for _, rule := range lint.Rules {
+		pack := rule.Pack
+		if pack == "" {
+			pack = "(always)"
+		}
+		fmt.Fprintf(out, "%-24s %-8s %s\n", rule.ID, pack, rule.Description)
+	}
+	return nil
+}
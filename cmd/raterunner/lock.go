@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/lock"
+	"raterunner/internal/stripe"
+)
+
+// lockPath returns the local lock file for an environment, shared by every
+// project on this machine
+func lockPath(env stripe.Environment) string {
+	return filepath.Join(filepath.Dir(config.DefaultSettingsPath()), "locks", fmt.Sprintf("stripe_%s.lock", env))
+}
+
+// lockRefreshInterval is how often a running command refreshes its locks
+var lockRefreshInterval = lock.RefreshInterval
+
+// acquireRunLock takes the local lock for env and, with --stripe-lock, the
+// Stripe-side one, and refreshes both while the command runs. If a lock is
+// taken over in the meantime, c.Context is cancelled so the command stops
+// writing. The returned function releases both.
+func acquireRunLock(c *cli.Context, client *stripe.Client, env stripe.Environment) (func(), error) {
+	holder := lock.Holder{
+		Owner:    lockOwner(),
+		PID:      os.Getpid(),
+		Command:  c.Command.Name,
+		Acquired: time.Now().UTC(),
+	}
+	force := c.Bool("force-unlock")
+	notices := getNoticeOutput(c)
+
+	local, previous, err := lock.Acquire(lockPath(env), fmt.Sprintf("%s on this machine", env), holder, force)
+	if err != nil {
+		return nil, lockError(err)
+	}
+	if previous != nil {
+		fmt.Fprintf(notices, "Removed lock held by %s\n", previous)
+	}
+
+	var token string
+	if c.Bool("stripe-lock") {
+		var previous *lock.Holder
+		token, previous, err = client.AcquireLock(c.Context, holder, force)
+		if err != nil {
+			local.Release()
+			return nil, lockError(err)
+		}
+		if previous != nil {
+			fmt.Fprintf(notices, "Removed Stripe lock held by %s\n", previous)
+		}
+	}
+
+	parent := c.Context
+	ctx, cancel := context.WithCancelCause(parent)
+	c.Context = ctx
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		keepLocks(ctx, stop, local, client, token, holder, getErrorOutput(c), cancel)
+	}()
+
+	return func() {
+		close(stop)
+		<-stopped
+		cancel(nil)
+		c.Context = parent
+		if token != "" {
+			// Released even after Ctrl-C, so the next run doesn't have to wait for expiry
+			if err := client.ReleaseLock(context.WithoutCancel(c.Context), token); err != nil {
+				fmt.Fprintf(notices, "WARNING: %v (it expires after %s)\n", err, lock.TTL)
+			}
+		}
+		if err := local.Release(); err != nil {
+			fmt.Fprintf(notices, "WARNING: %v\n", err)
+		}
+	}, nil
+}
+
+// keepLocks refreshes the local lock and, with a token, the Stripe lock every
+// lockRefreshInterval until stop is closed. When a lock was taken over or
+// can't be refreshed, it cancels the run with the reason.
+func keepLocks(ctx context.Context, stop <-chan struct{}, local *lock.Lock, client *stripe.Client, token string, holder lock.Holder, errOut io.Writer, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		holder.Refreshed = time.Now().UTC()
+		err := local.Refresh(holder.Refreshed)
+		if err == nil && token != "" {
+			err = client.RefreshLock(ctx, token, holder)
+		}
+		if err != nil {
+			fmt.Fprintf(errOut, "ERROR: %v; stopping so two runs don't write at once\n", err)
+			cancel(err)
+			return
+		}
+	}
+}
+
+// lockError passes a held lock through unwrapped, so its message reads as the
+// command's error
+func lockError(err error) error {
+	var held *lock.HeldError
+	if errors.As(err, &held) {
+		return held
+	}
+	return fmt.Errorf("failed to acquire lock: %w", err)
+}
+
+// lockOwner identifies this run's user and machine
+func lockOwner() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return name + "@" + host
+}
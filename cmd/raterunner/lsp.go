@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/lint"
+	"raterunner/internal/lsp"
+	"raterunner/internal/validator"
+)
+
+// yamlErrorLine finds the line number in a YAML parser error
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+func lspAction(c *cli.Context) error {
+	in := c.App.Reader
+	if in == nil {
+		in = os.Stdin
+	}
+	// stdout carries the protocol, so it is used even in quiet mode
+	out := getResultOutput(c)
+
+	v := newValidator(c)
+	return lsp.Serve(in, out, func(uri string, text []byte) []lsp.Diagnostic {
+		return checkDocument(v, lsp.PathFromURI(uri), text)
+	})
+}
+
+// checkDocument validates an open billing or provider file. Other documents
+// get no diagnostics, so the server can be registered for every YAML file.
+func checkDocument(v *validator.Validator, filePath string, text []byte) []lsp.Diagnostic {
+	if !isConfigDocument(filePath, text) {
+		return nil
+	}
+
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		format = "json"
+	}
+
+	var result *validator.ValidationResult
+	var err error
+	schemaType := schemaTypeOf(filePath, text)
+	switch schemaType {
+	case "provider":
+		result, err = v.ValidateProvider(text, format)
+	default:
+		result, err = v.ValidateBilling(text, format)
+	}
+	if err != nil {
+		// Unparsable while the user is typing: point at the parser's line
+		line := 1
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		return []lsp.Diagnostic{{
+			Range:    lsp.LineRange(text, line),
+			Severity: lsp.SeverityError,
+			Source:   "raterunner",
+			Message:  err.Error(),
+		}}
+	}
+	if schemaType != "provider" {
+		// Suppression comments are honored as in validate; a malformed one is ignored here
+		_, _ = suppressValidationErrors(result, text)
+	}
+
+	lines, _ := lint.Lines(text)
+	var diagnostics []lsp.Diagnostic
+	for _, e := range result.Errors {
+		message := e.Message
+		if e.Detail != "" {
+			message += " (" + e.Detail + ")"
+		}
+		diagnostics = append(diagnostics, lsp.Diagnostic{
+			Range:    lsp.LineRange(text, lint.LineFor(lines, e.Path)),
+			Severity: lsp.SeverityError,
+			Code:     e.Rule,
+			Source:   "raterunner",
+			Message:  message,
+		})
+	}
+	for _, w := range result.Warnings {
+		diagnostics = append(diagnostics, lsp.Diagnostic{
+			Range:    lsp.LineRange(text, 1),
+			Severity: lsp.SeverityWarning,
+			Source:   "raterunner",
+			Message:  w,
+		})
+	}
+	return diagnostics
+}
+
+// isConfigDocument reports whether a document is a raterunner config: it has
+// a schema header, or is named like a billing or provider file
+func isConfigDocument(filePath string, text []byte) bool {
+	if config.SchemaHeader(text) != "" {
+		return true
+	}
+	name := strings.ToLower(filepath.Base(filePath))
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+	default:
+		return false
+	}
+	if strings.HasPrefix(name, "billing") {
+		return true
+	}
+	for _, prefix := range providerPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,18 +1,33 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/urfave/cli/v2"
 
+	"raterunner/internal/checkout"
 	"raterunner/internal/config"
 	"raterunner/internal/diff"
+	"raterunner/internal/export"
+	"raterunner/internal/lint"
+	"raterunner/internal/plan"
+	"raterunner/internal/provider"
 	"raterunner/internal/stripe"
+	"raterunner/internal/telemetry"
 	"raterunner/internal/validator"
 )
 
@@ -23,17 +38,76 @@ var (
 	date    = "unknown"
 )
 
+func init() {
+	// -v is taken by --verbose
+	cli.VersionFlag = &cli.BoolFlag{
+		Name:    "version",
+		Aliases: []string{"V"},
+		Usage:   "print the version",
+	}
+}
+
 func main() {
+	// Ctrl-C cancels the Stripe requests in flight, and the command returns
+	// through its usual error path, releasing its locks. A second Ctrl-C
+	// kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := newApp().RunContext(ctx, os.Args)
+	stop()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if ctx.Err() != nil {
+			os.Exit(130)
+		}
+		os.Exit(1)
+	}
+}
+
+// newApp builds the command tree, with the role check on every command
+func newApp() *cli.App {
 	app := &cli.App{
 		Name:    "raterunner",
 		Usage:   "Raterunner CLI - billing configuration management",
-		Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
+		Version: fmt.Sprintf("%s (commit: %s, built: %s, Stripe API: %s)", version, commit, date, stripe.APIVersion),
+		// Allows stacking short flags such as -vv
+		UseShortOptionHandling: true,
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:    "quiet",
 				Aliases: []string{"q"},
 				Usage:   "Suppress non-essential output (errors still shown)",
 			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "Show per-plan progress and Stripe object IDs (-vv adds redacted request-level detail)",
+			},
+			&cli.BoolFlag{
+				Name:  "summary",
+				Usage: "Print one machine-friendly result line (also in quiet mode)",
+			},
+			&cli.StringFlag{
+				Name:  "summary-format",
+				Usage: "Format of the --summary line: text or json",
+				Value: "text",
+			},
+			&cli.StringFlag{
+				Name:  "product",
+				Usage: "Use products/<name>/billing.yaml in a multi-product repository",
+			},
+			&cli.BoolFlag{
+				Name:  "deterministic",
+				Usage: "Leave out timestamps and sort plans and warnings, so output and provider files are the same on every run",
+			},
+			&cli.StringFlag{
+				Name:  "diagnostics-dir",
+				Usage: "On a crash or unexpected Stripe failure, write a redacted diagnostics bundle here (defaults to the diagnostics_dir setting)",
+			},
 		},
 		Commands: []*cli.Command{
 			{
@@ -45,32 +119,98 @@ func main() {
 						Name:  "force",
 						Usage: "Overwrite existing files",
 					},
+					&cli.StringFlag{
+						Name:  "template",
+						Usage: "Start from a template: " + strings.Join(config.Templates(), ", ") + " (defaults to a generic example)",
+					},
 				},
 				Action: initAction,
 			},
 			{
 				Name:      "validate",
 				Usage:     "Validate a billing or provider configuration file",
-				ArgsUsage: "<file>",
+				ArgsUsage: "[file|-]",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:    "schema-dir",
 						Aliases: []string{"s"},
 						Usage:   "Path to directory containing schema files (uses embedded schemas if not specified)",
 					},
+					&cli.StringFlag{
+						Name:    "type",
+						Aliases: []string{"t"},
+						Usage:   "Schema type: billing or provider (detected from header comment or filename if not specified)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Input format when reading from stdin (-): yaml or json",
+					},
+					&cli.BoolFlag{
+						Name:  "manifest",
+						Usage: "Validate every file in a manifest (the argument, or raterunner.manifest.yaml found upwards)",
+					},
 				},
 				Action: validateAction,
 			},
+			{
+				Name:      "lint",
+				Usage:     "Warn about billing config choices that are valid but likely mistakes",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, json, or sarif",
+						Value: "text",
+					},
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "Exit with code 1 when there are warnings",
+					},
+					&cli.StringSliceFlag{
+						Name:  "enable",
+						Usage: "Enable optional rules by pack (e.g. pricing) or rule ID",
+					},
+					&cli.StringSliceFlag{
+						Name:  "disable",
+						Usage: "Disable rules by rule ID or pack",
+					},
+					&cli.BoolFlag{
+						Name:  "copy",
+						Usage: "Check customer-facing copy for consistency (same as --enable copy)",
+					},
+					&cli.StringFlag{
+						Name:  "feature-periods",
+						Usage: "Trailing period policy for feature text: omit or require",
+						Value: "omit",
+					},
+					&cli.BoolFlag{
+						Name:  "list-rules",
+						Usage: "List available rules and exit",
+					},
+				},
+				Action: lintAction,
+			},
+			{
+				Name:      "hash",
+				Usage:     "Print the config's content hash, which ignores formatting, comments and the order of plans, addons and promotions",
+				ArgsUsage: "[billing.yaml|-]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Input format when reading from stdin (-): yaml or json",
+					},
+				},
+				Action: hashAction,
+			},
 			{
 				Name:      "apply",
 				Usage:     "Sync local billing config to Stripe (creates/updates products and prices)",
-				ArgsUsage: "<billing.yaml>",
+				ArgsUsage: "[billing.yaml|-]",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "env",
-						Aliases:  []string{"e"},
-						Usage:    "Environment: sandbox or production",
-						Required: true,
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
 					},
 					&cli.BoolFlag{
 						Name:  "dry-run",
@@ -81,18 +221,89 @@ func main() {
 						Aliases: []string{"j"},
 						Usage:   "Output as JSON instead of table (only with --dry-run)",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Input format when reading from stdin (-): yaml or json",
+					},
+					&cli.BoolFlag{
+						Name:  "migrate-metadata",
+						Usage: "Move existing un-prefixed metadata keys under settings.metadata_prefix",
+					},
+					&cli.BoolFlag{
+						Name:  "manifest",
+						Usage: "Apply the manifest's billing files for --env in order (the argument, or raterunner.manifest.yaml found upwards)",
+					},
+					&cli.BoolFlag{
+						Name:  "strict-warnings",
+						Usage: "Exit with code 1 when the sync produced warnings (defaults to the strict_warnings setting)",
+					},
+					&cli.StringSliceFlag{
+						Name:  "regen-exports",
+						Usage: "After apply, rewrite this JSON export with the environment's Stripe IDs (repeatable)",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-validation",
+						Usage: "Apply without validating the billing config first",
+					},
+					&cli.BoolFlag{
+						Name:  "keep-going",
+						Usage: "Continue past plans, addons and promotions that fail to sync and report them all at the end",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Apply even when the config is unchanged since the last apply to --env",
+					},
+					&cli.BoolFlag{
+						Name:  "refresh",
+						Usage: "With --dry-run, fetch products from Stripe even when they were cached less than 10 minutes ago",
+					},
+					&cli.StringSliceFlag{
+						Name:  "only",
+						Usage: "With --dry-run, compare only these plans, searching Stripe for their products instead of listing the catalog (repeatable)",
+					},
+					&cli.BoolFlag{
+						Name:  "serial",
+						Usage: "Sync plans in config order and write upgrades_to references in a second pass (for plans that reference each other)",
+					},
+					&cli.BoolFlag{
+						Name:  "force-unlock",
+						Usage: "Remove a lock left behind by a run that crashed, then continue",
+					},
+					&cli.BoolFlag{
+						Name:  "stripe-lock",
+						Usage: "Also take a lock stored in Stripe, shared with runs on other machines",
+					},
+					&cli.StringFlag{
+						Name:  "provider",
+						Usage: "Apply to a plugin provider listed in the billing config (e.g. x-lago) instead of Stripe",
+					},
+					&cli.BoolFlag{
+						Name:  "prune",
+						Usage: "Archive active products, and their prices, whose plan_code is no longer in the config",
+					},
+					&cli.StringFlag{
+						Name:  "confirm-account",
+						Usage: "Stripe account ID, required instead of a prompt to prune production (for CI/CD)",
+					},
+					&cli.StringFlag{
+						Name:  "save-plan",
+						Usage: "With --dry-run, save the changes to this file for a later apply --plan",
+					},
+					&cli.StringFlag{
+						Name:  "plan",
+						Usage: "Apply exactly the changes saved with --save-plan, refusing when Stripe changed since",
+					},
 				},
 				Action: applyAction,
 			},
 			{
 				Name:  "import",
-				Usage: "Import products and prices from Stripe to a local YAML file",
+				Usage: "Import products and prices from Stripe, or another provider's catalog export, to a local YAML file",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "env",
-						Aliases:  []string{"e"},
-						Usage:    "Environment: sandbox or production",
-						Required: true,
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
 					},
 					&cli.StringFlag{
 						Name:     "output",
@@ -100,115 +311,852 @@ func main() {
 						Usage:    "Output file path",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:  "metadata-prefix",
+						Usage: "Metadata key prefix the products were synced with (written to settings.metadata_prefix)",
+					},
+					&cli.StringFlag{
+						Name:  "from-file",
+						Usage: "Convert a catalog export file instead of reading from Stripe",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Format of --from-file: paddle, chargebee or csv",
+					},
 				},
 				Action: importAction,
 			},
+			{
+				Name:  "clone",
+				Usage: "Import one environment's catalog and apply it to another, e.g. to refresh sandbox from production",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "Environment to import from: sandbox or production",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "Environment to apply to (sandbox only)",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "output",
+						Aliases:  []string{"o"},
+						Usage:    "Billing file to write the imported catalog to; provider files for both environments go next to it",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "metadata-prefix",
+						Usage: "Metadata key prefix the source products were synced with (written to settings.metadata_prefix)",
+					},
+					&cli.BoolFlag{
+						Name:  "skip-validation",
+						Usage: "Apply the imported catalog without validating it first",
+					},
+					&cli.BoolFlag{
+						Name:  "force-unlock",
+						Usage: "Remove a lock left behind by a run that crashed, then continue",
+					},
+					&cli.BoolFlag{
+						Name:  "stripe-lock",
+						Usage: "Also take a lock stored in Stripe, shared with runs on other machines",
+					},
+				},
+				Action: cloneAction,
+			},
 			{
 				Name:  "truncate",
 				Usage: "Archive all products and prices in Stripe (sandbox only)",
 				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment to truncate: only sandbox is allowed (the default)",
+					},
 					&cli.BoolFlag{
 						Name:  "confirm",
 						Usage: "Skip interactive confirmation (for CI/CD)",
 					},
+					&cli.BoolFlag{
+						Name:  "subscriptions",
+						Usage: "Also cancel the subscriptions created by 'raterunner seed'",
+					},
+					&cli.BoolFlag{
+						Name:  "customers",
+						Usage: "Also delete the customers and test clocks created by 'raterunner seed'",
+					},
+					&cli.BoolFlag{
+						Name:  "force-unlock",
+						Usage: "Remove a lock left behind by a run that crashed, then continue",
+					},
+					&cli.BoolFlag{
+						Name:  "stripe-lock",
+						Usage: "Also take a lock stored in Stripe, shared with runs on other machines",
+					},
 				},
 				Action: truncateAction,
 			},
 			{
-				Name:  "config",
-				Usage: "Manage CLI configuration",
-				Subcommands: []*cli.Command{
-					{
-						Name:      "set",
-						Usage:     "Set a configuration value",
-						ArgsUsage: "<key> <value>",
-						Action:    configSetAction,
+				Name:      "status",
+				Usage:     "Compare the billing config with sandbox and production side by side",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "json",
+						Aliases: []string{"j"},
+						Usage:   "Output both diffs as JSON, keyed by environment",
 					},
-					{
-						Name:      "get",
-						Usage:     "Get a configuration value",
-						ArgsUsage: "<key>",
-						Action:    configGetAction,
+					&cli.BoolFlag{
+						Name:  "all-products",
+						Usage: "Show every product under products/, keyed by product in JSON",
+					},
+					&cli.BoolFlag{
+						Name:  "refresh",
+						Usage: "Fetch products from Stripe even when they were cached less than 10 minutes ago",
+					},
+				},
+				Action: statusAction,
+			},
+			{
+				Name:      "cleanup",
+				Usage:     "List (and optionally remove) unused products and duplicate prices left in Stripe",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
+					},
+					&cli.BoolFlag{
+						Name:  "delete-where-possible",
+						Usage: "Archive unused objects and delete products that have no prices",
+					},
+					&cli.BoolFlag{
+						Name:  "confirm",
+						Usage: "Skip interactive confirmation (for CI/CD)",
+					},
+					&cli.StringFlag{
+						Name:  "confirm-account",
+						Usage: "Stripe account ID, required instead of a prompt to clean up production (for CI/CD)",
+					},
+					&cli.BoolFlag{
+						Name:    "json",
+						Aliases: []string{"j"},
+						Usage:   "Output the listing as JSON",
+					},
+				},
+				Action: cleanupAction,
+			},
+			{
+				Name:  "events",
+				Usage: "List recent product, price, coupon, and promotion code events from Stripe",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
+					},
+					&cli.StringFlag{
+						Name:  "since",
+						Value: "24h",
+						Usage: "How far back to look: a duration (24h, 7d), RFC 3339 time, or date; at most 30 days",
+					},
+					&cli.BoolFlag{
+						Name:    "json",
+						Aliases: []string{"j"},
+						Usage:   "Output events as JSON",
+					},
+				},
+				Action: eventsAction,
+			},
+			{
+				Name:      "subscribers",
+				Usage:     "Count active and trialing subscriptions per plan and interval",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, csv, or json",
+						Value: "text",
+					},
+				},
+				Action: subscribersAction,
+			},
+			{
+				Name:      "seed",
+				Usage:     "Create test customers subscribed across the synced plans (sandbox only)",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: only sandbox is allowed (defaults to the default_env setting)",
+					},
+					&cli.IntFlag{
+						Name:  "customers",
+						Usage: "Number of customers to create, spread evenly across the plans",
+						Value: 10,
+					},
+					&cli.StringFlag{
+						Name:  "interval",
+						Usage: "Billing interval to subscribe to: monthly, yearly or quarterly (plans without it use another)",
+						Value: "monthly",
+					},
+					&cli.BoolFlag{
+						Name:  "test-clocks",
+						Usage: "Give every customer its own test clock, so billing can be advanced in time",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Show how customers would be spread across plans without creating them",
+					},
+				},
+				Action: seedAction,
+			},
+			{
+				Name:      "mrr",
+				Usage:     "Estimate monthly recurring revenue per plan from subscriptions and configured prices",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text or json",
+						Value: "text",
+					},
+				},
+				Action: mrrAction,
+			},
+			{
+				Name:   "lsp",
+				Usage:  "Run a language server over stdio that reports validation errors in billing and provider files as you type",
+				Action: lspAction,
+			},
+			{
+				Name:  "serve",
+				Usage: "Serve validate, diff and apply over gRPC for programs that drive raterunner (unauthenticated unless RATERUNNER_GRPC_TOKEN is set)",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "grpc",
+						Usage: "Address to listen on, e.g. 127.0.0.1:9090, or :9090 with RATERUNNER_GRPC_TOKEN set (unauthenticated without it)",
+					},
+					&cli.StringFlag{
+						Name:    "schema-dir",
+						Aliases: []string{"s"},
+						Usage:   "Path to directory containing schema files for Validate (uses embedded schemas if not specified)",
+					},
+				},
+				Action: serveAction,
+			},
+			{
+				Name:  "operator",
+				Usage: "Run as a Kubernetes operator that applies BillingConfig resources to Stripe",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "namespace",
+						Usage: "Only watch BillingConfigs in this namespace (default: all namespaces)",
+					},
+					&cli.StringFlag{
+						Name:  "kube-api",
+						Usage: "Kubernetes API URL without credentials, e.g. http://127.0.0.1:8001 for kubectl proxy (default: the in-cluster service account)",
 					},
+					&cli.DurationFlag{
+						Name:  "resync",
+						Usage: "Re-apply unchanged resources this often, to repair drift made in Stripe",
+						Value: time.Hour,
+					},
+					&cli.BoolFlag{
+						Name:  "allow-production",
+						Usage: "Apply resources whose environment is production (rejected otherwise)",
+					},
+					&cli.BoolFlag{
+						Name:  "stripe-lock",
+						Usage: "Also take a lock stored in Stripe, shared with apply runs on other machines",
+					},
+					&cli.StringFlag{
+						Name:    "schema-dir",
+						Aliases: []string{"s"},
+						Usage:   "Path to directory containing schema files (uses embedded schemas if not specified)",
+					},
+				},
+				Action: operatorAction,
+			},
+			{
+				Name:      "doctor",
+				Usage:     "Check the billing config, Stripe credentials, and account setup",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
+					},
+				},
+				Action: doctorAction,
+			},
+			{
+				Name:  "schema",
+				Usage: "Work with the JSON schemas embedded in this binary",
+				Subcommands: []*cli.Command{
 					{
-						Name:   "list",
-						Usage:  "List all configuration values",
-						Action: configListAction,
+						Name:      "print",
+						Usage:     "Print the embedded billing or provider JSON schema",
+						ArgsUsage: "[billing|provider]",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "resolve-refs",
+								Usage: "Inline $defs references into a single flattened schema",
+							},
+							&cli.IntFlag{
+								Name:  "version",
+								Usage: "Billing schema version to print (defaults to the newest)",
+							},
+						},
+						Action: schemaPrintAction,
 					},
 					{
-						Name:   "path",
-						Usage:  "Show configuration file path",
-						Action: configPathAction,
+						Name:  "vscode-settings",
+						Usage: "Print a VS Code yaml.schemas snippet that maps billing and provider files to their schemas",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "write-schemas",
+								Usage: "Write the embedded schemas to this directory and reference them with file:// URLs instead of their https $id",
+							},
+						},
+						Action: schemaVSCodeSettingsAction,
 					},
 				},
 			},
-		},
-	}
-
-	if err := app.Run(os.Args); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
-	}
-}
-
+			{
+				Name:      "export",
+				Usage:     "Export plans, prices, and entitlements as JSON for frontends and backends",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Where to write the export (default: stdout)",
+					},
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Embed the Stripe IDs from this environment's provider file: sandbox or production",
+					},
+					&cli.StringFlag{
+						Name:  "unlimited",
+						Usage: "How to write unlimited limits: null or -1",
+						Value: "null",
+					},
+					&cli.BoolFlag{
+						Name:  "public-only",
+						Usage: "Leave out hidden plans (public: false), e.g. for a pricing page",
+					},
+					&cli.StringFlag{
+						Name:  "region",
+						Usage: "Use this region's prices for plans that declare it, e.g. eu",
+					},
+				},
+				Action: exportAction,
+			},
+			{
+				Name:  "generate",
+				Usage: "Generate integration code from the billing config and provider IDs",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "checkout",
+						Usage:     "Print Checkout Session snippets (curl, Node, Go) wired to a plan's Stripe price ID",
+						ArgsUsage: "[billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "plan",
+								Aliases:  []string{"p"},
+								Usage:    "Plan ID to check out",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:    "interval",
+								Aliases: []string{"i"},
+								Usage:   "Price to use: monthly, yearly, one_time, ... (default: the only price, or monthly)",
+							},
+							&cli.StringFlag{
+								Name:    "env",
+								Aliases: []string{"e"},
+								Usage:   "Environment whose price IDs to use (defaults to the default_env setting)",
+							},
+							&cli.StringFlag{
+								Name:  "lang",
+								Usage: "Comma-separated snippet languages: curl, node, go (default: all)",
+							},
+							&cli.StringFlag{
+								Name:  "success-url",
+								Usage: "Where Stripe redirects after payment",
+								Value: checkout.DefaultSuccessURL,
+							},
+							&cli.StringFlag{
+								Name:  "cancel-url",
+								Usage: "Where Stripe redirects when the customer backs out",
+								Value: checkout.DefaultCancelURL,
+							},
+						},
+						Action: generateCheckoutAction,
+					},
+				},
+			},
+			{
+				Name:  "flags",
+				Usage: "Sync bool entitlements to a feature flag system",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "sync",
+						Usage:     "Target feature flags to the plans that enable each bool entitlement",
+						ArgsUsage: "[billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "provider",
+								Aliases:  []string{"p"},
+								Usage:    "Feature flag provider: launchdarkly or unleash",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "project",
+								Usage: "Flag provider project key",
+							},
+							&cli.StringFlag{
+								Name:  "environment",
+								Usage: "Flag provider environment key",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Print flag targeting without calling the provider",
+							},
+						},
+						Action: flagsSyncAction,
+					},
+				},
+			},
+			{
+				Name:  "promos",
+				Usage: "Manage promotion codes in Stripe",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "generate",
+						Usage: "Create many unique single-use promotion codes against one coupon and export them as CSV",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "env",
+								Aliases: []string{"e"},
+								Usage:   "Target environment: sandbox or production (defaults to the default_env setting)",
+							},
+							&cli.StringFlag{
+								Name:     "coupon",
+								Usage:    "Coupon ID the codes redeem, e.g. a promotion's code after apply",
+								Required: true,
+							},
+							&cli.IntFlag{
+								Name:     "count",
+								Usage:    "Number of codes to create",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "prefix",
+								Usage: "Text every code starts with, e.g. LNCH-",
+							},
+							&cli.IntFlag{
+								Name:  "length",
+								Usage: "Number of random characters after the prefix",
+								Value: 8,
+							},
+							&cli.StringFlag{
+								Name:  "out",
+								Usage: "CSV file to write the codes to (default: stdout)",
+							},
+							&cli.StringFlag{
+								Name:  "expires",
+								Usage: "Date the codes stop working (YYYY-MM-DD)",
+							},
+							&cli.BoolFlag{
+								Name:  "new-customers-only",
+								Usage: "Only allow the codes on a customer's first purchase",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Write the generated codes without creating them in Stripe",
+							},
+						},
+						Action: promosGenerateAction,
+					},
+				},
+			},
+			{
+				Name:  "plans",
+				Usage: "Scaffold and inspect plans in the billing config",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "copy",
+						Usage:     "Add a copy of a plan under a new ID, e.g. for a regional or experimental variant",
+						ArgsUsage: "<plan_id> <new_plan_id> [billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.Float64Flag{
+								Name:  "price-multiplier",
+								Usage: "Multiply every price amount of the copy, rounded to the cent (e.g. 0.9 for 10% off)",
+								Value: 1,
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Print the new plan without writing the billing file",
+							},
+						},
+						Action: plansCopyAction,
+					},
+					{
+						Name:      "graph",
+						Usage:     "Render the upgrade paths between plans as a Graphviz or Mermaid diagram",
+						ArgsUsage: "[billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "format",
+								Usage: "Diagram format: dot or mermaid",
+								Value: config.GraphMermaid,
+							},
+						},
+						Action: plansGraphAction,
+					},
+				},
+			},
+			{
+				Name:  "prices",
+				Usage: "Maintain plan prices in the billing config",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "localize",
+						Usage:     "Fill currency_prices of every flat plan price from its settings.currency amount and exchange rates",
+						ArgsUsage: "[billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "currencies",
+								Usage:    "Comma-separated currencies to fill in, e.g. eur,gbp",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "rates",
+								Usage:    `JSON file of exchange rates from settings.currency: {"eur": 0.92} or {"base": "usd", "rates": {...}}`,
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "rounding",
+								Usage: "Rounding rule: none (nearest cent), whole (whole units), or psychological (whole units minus a cent, e.g. 26.99)",
+								Value: config.RoundingNone,
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Print the prices without writing the billing file",
+							},
+						},
+						Action: pricesLocalizeAction,
+					},
+				},
+			},
+			{
+				Name:  "refactor",
+				Usage: "Rewrite the billing config consistently across plans and addons",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "rename-entitlement",
+						Usage:     "Rename an entitlement key in its definition, every plan's limits and every addon's grants",
+						ArgsUsage: "<old_key> <new_key> [billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "export",
+								Usage: "Also rename the key in this JSON export written by 'raterunner export' (repeatable)",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Report the paths that would change without writing any file",
+							},
+						},
+						Action: refactorRenameEntitlementAction,
+					},
+				},
+			},
+			{
+				Name:  "provider-file",
+				Usage: "Maintain the provider ID files apply writes",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "migrate",
+						Usage:     "Rewrite provider files in the current format version",
+						ArgsUsage: "<provider file>...",
+						Action:    providerFileMigrateAction,
+					},
+					{
+						Name:      "compact",
+						Usage:     "Prune the replaced IDs kept per plan",
+						ArgsUsage: "<provider file>...",
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "keep",
+								Usage: "Replaced ID sets to keep per plan, newest first",
+							},
+						},
+						Action: providerFileCompactAction,
+					},
+				},
+			},
+			{
+				Name:  "plugins",
+				Usage: "Manage provider plugins",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "list",
+						Usage:  "List the provider plugins in ~/.raterunner/plugins (or $RATERUNNER_PLUGIN_DIR)",
+						Action: pluginsListAction,
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Manage CLI configuration",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "Set a user configuration value",
+						ArgsUsage: "<key> <value>",
+						Action:    configSetAction,
+					},
+					{
+						Name:      "get",
+						Usage:     "Get a configuration value",
+						ArgsUsage: "<key>",
+						Action:    configGetAction,
+					},
+					{
+						Name:   "list",
+						Usage:  "List effective configuration values (user settings merged with .raterunner.yaml)",
+						Action: configListAction,
+					},
+					{
+						Name:   "path",
+						Usage:  "Show configuration file paths (user, then project)",
+						Action: configPathAction,
+					},
+				},
+			},
+			{
+				Name:  "telemetry",
+				Usage: "Anonymous usage metrics (off unless turned on with 'config set telemetry true')",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "status",
+						Usage:  "Show whether telemetry is on, where it goes and what it sends",
+						Action: telemetryStatusAction,
+					},
+				},
+			},
+		},
+	}
+
+	gateCommands(app.Commands, "")
+	diagnoseCommands(app.Commands, "")
+	trackCommands(app.Commands, "")
+	return app
+}
+
 func validateAction(c *cli.Context) error {
-	if c.NArg() < 1 {
-		return fmt.Errorf("missing required argument: file path")
+	if c.Bool("manifest") {
+		return validateManifestAction(c)
 	}
 
-	filePath := c.Args().First()
-	schemaDir := c.String("schema-dir")
-	schemaType := detectSchemaType(filePath)
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
 
-	var v *validator.Validator
-	if schemaDir != "" {
-		v = validator.NewWithSchemaDir(schemaDir)
+	schemaType := c.String("type")
+	v := newValidator(c)
+
+	var result *validator.ValidationResult
+	var content []byte
+	suppressed := 0
+
+	if filePath == stdinPath {
+		content, err = readStdin(c)
+		if err != nil {
+			return err
+		}
+		if schemaType == "" {
+			schemaType = config.SchemaHeader(content)
+		}
+
+		switch schemaType {
+		case "billing", "":
+			result, err = v.ValidateBilling(content, inputFormat(c))
+		case "provider":
+			result, err = v.ValidateProvider(content, inputFormat(c))
+		default:
+			return fmt.Errorf("unknown schema type: %s (use 'billing' or 'provider')", schemaType)
+		}
+		if err != nil {
+			return err
+		}
+		filePath = "<stdin>"
+		if schemaType == "billing" || schemaType == "" {
+			if suppressed, err = suppressValidationErrors(result, content); err != nil {
+				return err
+			}
+		}
 	} else {
-		v = validator.New()
+		if result, suppressed, err = validateFile(v, filePath, schemaType); err != nil {
+			return err
+		}
+	}
+
+	out := getOutput(c)
+	printValidationWarnings(c, filePath, result)
+
+	if result.Valid {
+		fmt.Fprintf(out, "✓ %s is valid%s\n", filePath, suppressedNote(suppressed))
+		printSummary(c, "ok", withSuppressed(suppressed, summaryField{"file", filePath}, summaryField{"errors", 0})...)
+		return nil
+	}
+
+	// Errors always shown (even in quiet mode)
+	errOut := getResultOutput(c)
+
+	printValidationErrors(errOut, filePath, result, suppressed)
+
+	printSummary(c, "invalid", withSuppressed(suppressed, summaryField{"file", filePath}, summaryField{"errors", len(result.Errors)})...)
+
+	return cli.Exit("", 1)
+}
+
+// printValidationErrors lists the errors of an invalid file
+func printValidationErrors(w io.Writer, filePath string, result *validator.ValidationResult, suppressed int) {
+	fmt.Fprintf(w, "✗ %s has %d validation error(s)%s:\n\n", filePath, len(result.Errors), suppressedNote(suppressed))
+	for i, e := range result.Errors {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, e.String())
+	}
+	fmt.Fprintln(w)
+}
+
+// printValidationWarnings writes the validator's warnings as notices
+func printValidationWarnings(c *cli.Context, filePath string, result *validator.ValidationResult) {
+	for _, w := range result.Warnings {
+		fmt.Fprintf(getNoticeOutput(c), "warning: %s: %s\n", filePath, w)
+	}
+}
+
+// newValidator returns a validator using --schema-dir, the schema_dir setting,
+// or the embedded schemas
+func newValidator(c *cli.Context) *validator.Validator {
+	schemaDir := c.String("schema-dir")
+	if schemaDir == "" {
+		if settings, err := loadSettings(); err == nil {
+			schemaDir = settings.SchemaDir
+		}
+	}
+	if schemaDir != "" {
+		return validator.NewWithSchemaDir(schemaDir)
+	}
+	return validator.New()
+}
+
+// validateFile validates a billing or provider file, detecting the type when
+// schemaType is empty, and returns the result with suppressed errors removed
+func validateFile(v *validator.Validator, filePath, schemaType string) (*validator.ValidationResult, int, error) {
+	if schemaType == "" {
+		schemaType = detectSchemaType(filePath)
 	}
 
 	var result *validator.ValidationResult
 	var err error
-
 	switch schemaType {
 	case "billing":
 		result, err = v.ValidateBillingFile(filePath)
 	case "provider":
 		result, err = v.ValidateProviderFile(filePath)
 	default:
-		return fmt.Errorf("unknown schema type: %s (use 'billing' or 'provider')", schemaType)
+		return nil, 0, fmt.Errorf("unknown schema type: %s (use 'billing' or 'provider')", schemaType)
 	}
-
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	if schemaType != "billing" {
+		return result, 0, nil
 	}
 
-	out := getOutput(c)
-
-	if result.Valid {
-		fmt.Fprintf(out, "✓ %s is valid\n", filePath)
-		return nil
+	content, err := config.ReadFile(filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+	suppressed, err := suppressValidationErrors(result, content)
+	if err != nil {
+		return nil, 0, err
 	}
+	return result, suppressed, nil
+}
 
-	// Errors always shown (even in quiet mode)
-	errOut := c.App.Writer
-	if errOut == nil {
-		errOut = os.Stdout
+// suppressValidationErrors drops semantic errors silenced by raterunner:disable
+// comments in content and returns how many were dropped. Schema errors have no
+// rule ID and can't be suppressed.
+func suppressValidationErrors(result *validator.ValidationResult, content []byte) (int, error) {
+	if result.Valid || len(content) == 0 {
+		return 0, nil
+	}
+	sups, err := lint.ParseSuppressions(content)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read suppressions: %w", err)
 	}
 
-	fmt.Fprintf(errOut, "✗ %s has %d validation error(s):\n\n", filePath, len(result.Errors))
-	for i, e := range result.Errors {
-		fmt.Fprintf(errOut, "  %d. %s\n", i+1, e.String())
+	kept := result.Errors[:0]
+	suppressed := 0
+	for _, e := range result.Errors {
+		if e.Rule != "" && isSuppressed(sups, e.Rule, e.Path) {
+			suppressed++
+			continue
+		}
+		kept = append(kept, e)
 	}
-	fmt.Fprintln(errOut)
+	result.Errors = kept
+	result.Valid = len(kept) == 0
+	return suppressed, nil
+}
 
-	return cli.Exit("", 1)
+// isSuppressed reports whether any suppression covers rule at path
+func isSuppressed(sups []lint.Suppression, rule, path string) bool {
+	for _, s := range sups {
+		if s.Matches(rule, path) {
+			return true
+		}
+	}
+	return false
 }
 
 func detectSchemaType(filePath string) string {
+	content, _ := config.ReadFile(filePath)
+	return schemaTypeOf(filePath, content)
+}
+
+// providerPrefixes are the filename prefixes of provider config files
+var providerPrefixes = []string{"provider_", "stripe_", "paddle_", "chargebee_"}
+
+// schemaTypeOf detects the schema type of a file's content
+func schemaTypeOf(filePath string, content []byte) string {
+	// An explicit "# raterunner-schema: <type>" header wins over the filename
+	if header := config.SchemaHeader(content); header != "" {
+		return header
+	}
+
 	filename := strings.ToLower(filepath.Base(filePath))
-	// Detect provider config files by filename prefix
-	providerPrefixes := []string{"provider_", "stripe_", "paddle_", "chargebee_"}
 	for _, prefix := range providerPrefixes {
 		if strings.HasPrefix(filename, prefix) {
 			return "provider"
@@ -218,107 +1166,505 @@ func detectSchemaType(filePath string) string {
 }
 
 func applyAction(c *cli.Context) error {
-	if c.NArg() < 1 {
-		return fmt.Errorf("missing required argument: billing config file path")
+	if path := c.String("plan"); path != "" {
+		return applySavedPlan(c, path)
+	}
+	if c.Bool("manifest") {
+		return applyManifestAction(c)
 	}
 
-	filePath := c.Args().First()
-	env := c.String("env")
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	env, err := resolveEnv(c)
+	if err != nil {
+		return err
+	}
+	return applyFile(c, filePath, env, nil)
+}
+
+// applySavedPlan applies the billing config saved in a plan file to the
+// environment it was planned for
+func applySavedPlan(c *cli.Context, path string) error {
+	switch {
+	case c.Bool("dry-run"):
+		return fmt.Errorf("--plan applies a saved plan; create one with --dry-run --save-plan")
+	case c.Bool("manifest"), len(c.StringSlice("only")) > 0:
+		return fmt.Errorf("--plan can't be combined with --manifest or --only")
+	case c.NArg() > 0 || c.String("product") != "":
+		return fmt.Errorf("pass either a billing file or --plan, not both; the plan holds its billing config")
+	}
+
+	saved, err := plan.Load(path)
+	if err != nil {
+		return err
+	}
+	if env := c.String("env"); env != "" && env != saved.Environment {
+		return fmt.Errorf("plan %s was made for %s, not %s", path, saved.Environment, env)
+	}
+	return applyFile(c, saved.BillingFile, saved.Environment, saved)
+}
+
+// applyFile applies one billing file (or stdin with --dry-run) to env. With a
+// saved plan, its billing config is applied instead of the file's, as long as
+// Stripe didn't change since the plan was made.
+func applyFile(c *cli.Context, filePath, env string, saved *plan.File) error {
 	dryRun := c.Bool("dry-run")
 	jsonOutput := c.Bool("json")
 
 	out := getOutput(c)
 
-	// Validate environment
-	var stripeEnv stripe.Environment
-	switch env {
-	case "sandbox":
-		stripeEnv = stripe.Sandbox
-	case "production":
-		stripeEnv = stripe.Production
-	default:
-		return fmt.Errorf("invalid environment: %s (use 'sandbox' or 'production')", env)
+	stripeEnv, err := parseEnv(env)
+	if err != nil {
+		return err
+	}
+
+	// The provider file is written next to the billing file, so stdin needs --dry-run
+	if filePath == stdinPath && !dryRun {
+		return fmt.Errorf("reading the billing config from stdin requires --dry-run")
+	}
+	only := c.StringSlice("only")
+	if len(only) > 0 && !dryRun {
+		return fmt.Errorf("--only requires --dry-run; apply always syncs the whole config")
+	}
+	savePlan := c.String("save-plan")
+	switch {
+	case savePlan == "":
+	case !dryRun:
+		return fmt.Errorf("--save-plan requires --dry-run")
+	case len(only) > 0:
+		return fmt.Errorf("--save-plan can't be combined with --only; a plan covers the whole config")
+	case filePath == stdinPath:
+		return fmt.Errorf("--save-plan needs a billing file, since apply --plan writes the provider file next to it")
+	}
+	if name := c.String("provider"); (savePlan != "" || saved != nil) && name != "" && name != "stripe" {
+		return fmt.Errorf("plans are only supported for Stripe, not --provider %s", name)
+	}
+	if name := c.String("provider"); c.Bool("prune") && name != "" && name != "stripe" {
+		return fmt.Errorf("--prune is only supported for Stripe, not --provider %s", name)
+	}
+	if c.Bool("prune") && len(only) > 0 {
+		return fmt.Errorf("--prune looks at the whole catalog; it can't be combined with --only")
+	}
+
+	// Load billing config, refusing files that fail validation. A plan's
+	// config was validated when it was made.
+	var cfg *config.BillingConfig
+	if saved != nil {
+		cfg, err = saved.Billing()
+	} else {
+		cfg, err = loadValidBilling(c, filePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Plugin providers have their own apply
+	if name := c.String("provider"); name != "" && name != "stripe" {
+		return applyPlugin(c, cfg, filePath, env, name)
+	}
+
+	// Validate provider
+	if err := validateProvider(cfg.Providers); err != nil {
+		return err
+	}
+	var onlyCodes []string
+	for _, id := range only {
+		plan := cfg.FindPlan(id)
+		if plan == nil {
+			return fmt.Errorf("unknown plan in --only: %s", id)
+		}
+		onlyCodes = append(onlyCodes, plan.ID)
+		onlyCodes = append(onlyCodes, plan.PreviousIDs...)
+	}
+
+	// Skip configs already applied as they are, before any Stripe traffic
+	providerPath := config.ProviderFilePath(filePath, "stripe", env)
+	if !dryRun && saved == nil && !c.Bool("force") && !c.Bool("migrate-metadata") && !c.Bool("prune") {
+		if version, ok := appliedUnchanged(cfg, providerPath); ok {
+			fmt.Fprintf(out, "Config unchanged since the last apply to %s (catalog version %s); nothing to do. Use --force to apply anyway.\n", env, version)
+			printSummary(c, "unchanged",
+				summaryField{"env", env},
+				summaryField{"catalog_version", version},
+				summaryField{"provider_file", providerPath})
+			return nil
+		}
+	}
+
+	client, err := connectStripe(c, stripeEnv)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("migrate-metadata") && cfg.MetadataPrefix() == "" && len(cfg.MetadataMapping) == 0 {
+		return fmt.Errorf("--migrate-metadata requires settings.metadata_prefix or metadata_mapping in the billing config")
+	}
+	metaKeys, err := cfg.MetadataKeys()
+	if err != nil {
+		return err
+	}
+	client.SetMetadataKeys(metaKeys, c.Bool("migrate-metadata"))
+
+	gitDir := filepath.Dir(filePath)
+	if filePath == stdinPath {
+		gitDir = "."
+	}
+	catalogVersion := config.CatalogVersion(cfg.CatalogHash(), gitCommit(gitDir))
+	if saved != nil {
+		catalogVersion = saved.CatalogVersion
+	}
+	client.SetCatalogVersion(catalogVersion)
+	client.SetSerial(c.Bool("serial"))
+	client.SetKeepGoing(c.Bool("keep-going"))
+	client.SetPrune(c.Bool("prune"))
+
+	if dryRun {
+		// Dry run: just compare and show differences. With --only, just the
+		// products of those plans are fetched. A saved plan is always made
+		// from fresh products, which apply --plan compares against.
+		var result *diff.DiffResult
+		var products []stripe.Product
+		if savePlan != "" {
+			products, err = client.FetchProductsWithPrices(c.Context)
+			if err != nil {
+				return fmt.Errorf("failed to fetch from Stripe: %w", err)
+			}
+			result = diff.Compare(cfg, products, env)
+			if len(cfg.Meters) > 0 {
+				meters, err := client.FetchMeters(c.Context)
+				if err != nil {
+					return fmt.Errorf("failed to fetch from Stripe: %w", err)
+				}
+				diff.AddMeters(result, cfg, meters)
+			}
+		} else if len(only) > 0 {
+			products, err := client.SearchProductsWithPrices(c.Context, onlyCodes)
+			if err != nil {
+				return fmt.Errorf("failed to fetch from Stripe: %w", err)
+			}
+			result = diff.CompareOnly(cfg, products, env, only)
+		} else {
+			var cachedAt time.Time
+			result, cachedAt, err = provider.NewStripe(client).Diff(c.Context, cfg)
+			if err != nil {
+				return fmt.Errorf("failed to fetch from Stripe: %w", err)
+			}
+			noteCachedProducts(c, env, cachedAt)
+		}
+		stabilizeDiff(c, result)
+
+		if jsonOutput {
+			if err := diff.OutputJSON(out, result); err != nil {
+				return fmt.Errorf("failed to write JSON output: %w", err)
+			}
+		} else {
+			diff.OutputTable(out, result)
+		}
+
+		if savePlan != "" {
+			if err := savePlanFile(c, savePlan, filePath, env, catalogVersion, products, result); err != nil {
+				return err
+			}
+		}
+
+		var orphaned []stripe.Product
+		if c.Bool("prune") {
+			if orphaned, err = client.OrphanedProducts(c.Context, cfg); err != nil {
+				return fmt.Errorf("failed to fetch from Stripe: %w", err)
+			}
+			printOrphaned(getNoticeOutput(c), orphaned)
+		}
+
+		status := "ok"
+		if result.HasDifferences() {
+			status = "differs"
+		}
+		printSummary(c, status,
+			summaryField{"env", env},
+			summaryField{"plans", result.Summary.Total},
+			summaryField{"synced", result.Summary.Synced},
+			summaryField{"missing", result.Summary.Missing},
+			summaryField{"differs", result.Summary.Differs},
+			summaryField{"skipped", result.Summary.Skipped},
+			summaryField{"addons_missing", result.Summary.AddonsMissing},
+			summaryField{"addons_differ", result.Summary.AddonsDiffer},
+			summaryField{"credits_missing", result.Summary.CreditsMissing},
+			summaryField{"credits_differ", result.Summary.CreditsDiffer},
+			summaryField{"products_to_prune", len(orphaned)})
+
+		if result.HasDifferences() || len(orphaned) > 0 {
+			return cli.Exit("", 1)
+		}
+		return nil
+	}
+
+	account, err := productionBanner(c, client, "apply creates and archives live products, prices and coupons")
+	if err != nil {
+		return err
+	}
+	// Pruning archives live products like cleanup does, so it asks the same way
+	if account != nil && c.Bool("prune") {
+		if err := confirmAccount(c, account); err != nil {
+			return err
+		}
+	}
+
+	// Actual apply: sync to Stripe under the run lock, so concurrent applies
+	// can't both create the same prices
+	release, err := acquireRunLock(c, client, stripeEnv)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Checked under the lock, so no other apply can change Stripe after it
+	if saved != nil {
+		if err := checkPlanState(c.Context, client, saved); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "Syncing billing config to Stripe (%s)...\n", env)
+
+	// With --keep-going, what did sync is still reported and recorded below
+	result, err := provider.NewStripe(client).Sync(c.Context, cfg)
+	var syncErrs stripe.SyncErrors
+	if err != nil && !errors.As(err, &syncErrs) {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+
+	// Print warnings
+	result.Warnings = sortedWarnings(c, result.Warnings)
+	for _, w := range result.Warnings {
+		fmt.Fprintf(out, "  WARNING: %s\n", w)
+	}
+
+	if result.PlansRenamed > 0 {
+		fmt.Fprintf(out, "  Renamed %d plan(s) on existing products (previous_ids)\n", result.PlansRenamed)
+	}
+	if result.MetadataMigrated > 0 {
+		fmt.Fprintf(out, "  Migrated metadata keys on %d object(s)\n", result.MetadataMigrated)
+	}
+	pending := make([]string, 0, len(result.Pending))
+	for code := range result.Pending {
+		pending = append(pending, code)
+	}
+	sort.Strings(pending)
+	for _, code := range pending {
+		fmt.Fprintf(out, "  Promotion '%s' is pending until %s\n", code, result.Pending[code])
+	}
+	if result.PromosActivated > 0 {
+		fmt.Fprintf(out, "  Reactivated %d promotion code(s)\n", result.PromosActivated)
+	}
+	if result.PromosExpired > 0 {
+		fmt.Fprintf(out, "  Deactivated %d expired promotion code(s)\n", result.PromosExpired)
+	}
+	for _, p := range result.Pruned {
+		fmt.Fprintf(out, "  Archived product %s %q of removed plan '%s' and %d price(s)\n", p.ProductID, p.Name, p.PlanCode, p.PricesArchived)
+	}
+
+	fmt.Fprintf(out, "Done. Products: %d created, %d updated, %d unchanged. Prices: %d created, %d updated, %d unchanged, %d archived. Addons: %d. Credit packs: %d. Meters: %d. Coupons: %d. Promo codes: %d.\n",
+		result.ProductsCreated, result.ProductsUpdated, result.ProductsUnchanged,
+		result.PricesCreated, result.PricesUpdated, result.PricesUnchanged, result.PricesArchived,
+		result.AddonsCreated, result.CreditPacksCreated, result.MetersCreated, result.CouponsCreated, result.PromosCreated)
+	if result.PlansSkipped > 0 || result.PromosSkipped > 0 {
+		fmt.Fprintf(out, "Skipped: %d plan(s), %d promotion(s).\n", result.PlansSkipped, result.PromosSkipped)
+	}
+	if len(syncErrs) > 0 {
+		fmt.Fprintf(out, "Failed: %d object(s).\n", len(syncErrs))
+	}
+
+	// Save provider file with IDs
+	providerCfg := syncedProvider(env, catalogVersion, result)
+	previous, err := loadProviderFile(providerPath)
+	if err != nil {
+		previous = nil // first apply for this environment
+	}
+	keepPreviousIDs(providerCfg, previous, syncErrs)
+	providerCfg.RecordHistory(previous, time.Now(), config.DefaultProviderHistory)
+
+	if err := saveProviderFile(c, providerPath, providerCfg); err != nil {
+		return fmt.Errorf("failed to save provider file: %w", err)
+	}
+
+	fmt.Fprintf(out, "Saved provider IDs to %s (catalog version %s)\n", providerPath, catalogVersion)
+
+	// Regenerated from the IDs just saved, so exports never lag the provider file
+	exports := c.StringSlice("regen-exports")
+	if len(exports) > 0 {
+		bundle, err := export.Build(cfg, export.Options{Provider: providerCfg})
+		if err != nil {
+			return fmt.Errorf("failed to build export: %w", err)
+		}
+		for _, path := range exports {
+			if err := writeExportFile(path, bundle); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Regenerated %s with %s IDs\n", path, env)
+		}
+	}
+
+	// Changes are applied and recorded either way; strict mode only fails the run
+	strict := strictWarnings(c) && len(result.Warnings) > 0
+	status := "ok"
+	if len(syncErrs) > 0 {
+		status = "failed"
+	} else if strict {
+		status = "warnings"
+	}
+
+	printSummary(c, status,
+		summaryField{"env", env},
+		summaryField{"catalog_version", catalogVersion},
+		summaryField{"products_created", result.ProductsCreated},
+		summaryField{"products_updated", result.ProductsUpdated},
+		summaryField{"products_unchanged", result.ProductsUnchanged},
+		summaryField{"prices_created", result.PricesCreated},
+		summaryField{"prices_updated", result.PricesUpdated},
+		summaryField{"prices_unchanged", result.PricesUnchanged},
+		summaryField{"prices_archived", result.PricesArchived},
+		summaryField{"addons_created", result.AddonsCreated},
+		summaryField{"credit_packs_created", result.CreditPacksCreated},
+		summaryField{"meters_created", result.MetersCreated},
+		summaryField{"plans_skipped", result.PlansSkipped},
+		summaryField{"promos_skipped", result.PromosSkipped},
+		summaryField{"plans_renamed", result.PlansRenamed},
+		summaryField{"metadata_migrated", result.MetadataMigrated},
+		summaryField{"coupons_created", result.CouponsCreated},
+		summaryField{"promos_created", result.PromosCreated},
+		summaryField{"promos_pending", len(result.Pending)},
+		summaryField{"promos_expired", result.PromosExpired},
+		summaryField{"products_pruned", len(result.Pruned)},
+		summaryField{"warnings", len(result.Warnings)},
+		summaryField{"warning_details", summaryDetail{result.Warnings}},
+		summaryField{"failed", len(syncErrs)},
+		summaryField{"provider_file", providerPath})
+
+	if len(syncErrs) > 0 {
+		return fmt.Errorf("sync failed: %w; the other changes were applied and %s was saved", syncErrs, providerPath)
+	}
+	if strict {
+		return fmt.Errorf("%d sync warning(s) with strict warnings enabled; the changes were applied and %s was saved", len(result.Warnings), providerPath)
+	}
+	return nil
+}
+
+// printOrphaned lists the products apply --prune would archive
+func printOrphaned(w io.Writer, orphaned []stripe.Product) {
+	if len(orphaned) == 0 {
+		fmt.Fprintln(w, "Prune: no products of removed plans")
+		return
+	}
+	fmt.Fprintf(w, "Prune: apply --prune would archive %d product(s) of removed plans:\n", len(orphaned))
+	for _, p := range orphaned {
+		active := 0
+		for _, price := range p.Prices {
+			if price.Active {
+				active++
+			}
+		}
+		fmt.Fprintf(w, "  %s %q (plan_code %s, %d active price(s))\n", p.ID, p.Name, p.PlanCode, active)
 	}
+}
 
-	// Load billing config
-	cfg, err := config.LoadBillingFile(filePath)
+// savePlanFile saves the billing config and the changes a dry run computed,
+// with a fingerprint of the products they were computed from
+func savePlanFile(c *cli.Context, path, filePath, env, catalogVersion string, products []stripe.Product, result *diff.DiffResult) error {
+	content, err := config.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to load billing config: %w", err)
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
 	}
-
-	// Validate provider
-	if err := validateProvider(cfg.Providers); err != nil {
-		return err
+	saved := &plan.File{
+		Environment:    env,
+		BillingFile:    filePath,
+		CatalogVersion: catalogVersion,
+		StripeState:    stripe.Fingerprint(products),
+		Changes:        result,
+		Config:         string(content),
 	}
-
-	// Get API key from environment
-	apiKey, err := getAPIKey(stripeEnv)
-	if err != nil {
+	if !c.Bool("deterministic") {
+		saved.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	if err := plan.Save(path, saved); err != nil {
 		return err
 	}
+	fmt.Fprintf(getNoticeOutput(c), "Saved plan to %s; apply exactly these changes with 'raterunner apply --plan %s'\n", path, path)
+	return nil
+}
 
-	// Create Stripe client
-	client, err := stripe.NewClient(stripeEnv, apiKey)
+// checkPlanState refuses a plan when Stripe's products or prices changed
+// since it was made, since its changes may no longer be the ones to make
+func checkPlanState(ctx context.Context, client *stripe.Client, saved *plan.File) error {
+	products, err := client.FetchProductsWithPrices(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create Stripe client: %w", err)
+		return fmt.Errorf("failed to fetch from Stripe: %w", err)
 	}
-
-	if dryRun {
-		// Dry run: just compare and show differences
-		products, err := client.FetchProductsWithPrices()
-		if err != nil {
-			return fmt.Errorf("failed to fetch from Stripe: %w", err)
-		}
-
-		result := diff.Compare(cfg, products, env)
-
-		if jsonOutput {
-			if err := diff.OutputJSON(out, result); err != nil {
-				return fmt.Errorf("failed to write JSON output: %w", err)
-			}
-		} else {
-			diff.OutputTable(out, result)
-		}
-
-		if result.HasDifferences() {
-			return cli.Exit("", 1)
+	if stripe.Fingerprint(products) != saved.StripeState {
+		since := ""
+		if saved.CreatedAt != "" {
+			since = " (" + saved.CreatedAt + ")"
 		}
-		return nil
+		return fmt.Errorf("%s products or prices changed since the plan was made%s; create a new plan with apply --dry-run --save-plan", saved.Environment, since)
 	}
+	return nil
+}
 
-	// Actual apply: sync to Stripe
-	fmt.Fprintf(out, "Syncing billing config to Stripe (%s)...\n", env)
-
-	result, err := client.Sync(cfg)
+// loadProviderFile loads a provider file after checking it against the embedded
+// provider schema, so a hand-edited file fails on load instead of sending
+// mistyped IDs to Stripe
+func loadProviderFile(path string) (*config.ProviderConfig, error) {
+	result, err := validator.New().ValidateProviderFile(path)
 	if err != nil {
-		return fmt.Errorf("sync failed: %w", err)
+		return nil, err
 	}
-
-	// Print warnings
-	for _, w := range result.Warnings {
-		fmt.Fprintf(out, "  WARNING: %s\n", w)
+	if !result.Valid {
+		msgs := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			msgs[i] = e.String()
+		}
+		return nil, fmt.Errorf("invalid provider file:\n  %s", strings.Join(msgs, "\n  "))
 	}
+	return config.LoadProviderFile(path)
+}
 
-	fmt.Fprintf(out, "Done. Products: %d created. Prices: %d created, %d archived. Addons: %d. Coupons: %d. Promo codes: %d.\n",
-		result.ProductsCreated, result.PricesCreated, result.PricesArchived,
-		result.AddonsCreated, result.CouponsCreated, result.PromosCreated)
+// appliedUnchanged returns the catalog version in the provider file when it
+// records an apply of the same config, with no promotion dates that would make
+// another apply do something
+func appliedUnchanged(cfg *config.BillingConfig, providerPath string) (string, bool) {
+	providerCfg, err := loadProviderFile(providerPath)
+	if err != nil || providerCfg.CatalogVersion == "" {
+		return "", false
+	}
+	if config.CatalogVersionHash(providerCfg.CatalogVersion) != cfg.CatalogHash() {
+		return "", false
+	}
+	if len(providerCfg.Pending) > 0 || cfg.HasPromotionWindows() {
+		return "", false
+	}
+	return providerCfg.CatalogVersion, true
+}
 
-	// Save provider file with IDs
-	providerPath := config.ProviderFilePath(filePath, "stripe", env)
+// syncedProvider converts the IDs of a sync result to provider file format
+func syncedProvider(env, catalogVersion string, result *stripe.SyncResult) *config.ProviderConfig {
 	providerCfg := &config.ProviderConfig{
-		Provider:    "stripe",
-		Environment: env,
-		SyncedAt:    time.Now().UTC().Format(time.RFC3339),
-		Plans:       make(map[string]config.PlanIDs),
-		Addons:      make(map[string]config.ProductIDs),
-		Promotions:  result.PromotionIDs,
+		Provider:       "stripe",
+		Environment:    env,
+		SyncedAt:       time.Now().UTC().Format(time.RFC3339),
+		CatalogVersion: catalogVersion,
+		Plans:          make(map[string]config.PlanIDs),
+		Addons:         make(map[string]config.ProductIDs),
+		Credits:        make(map[string]config.ProductIDs),
+		Promotions:     result.PromotionIDs,
+		Meters:         result.MeterIDs,
+		Pending:        result.Pending,
 	}
-
-	// Convert sync result IDs to provider config format
 	for planID, planResult := range result.PlanIDs {
 		providerCfg.Plans[planID] = config.PlanIDs{
 			ProductID: planResult.ProductID,
 			Prices:    planResult.Prices,
+			Regions:   planResult.Regions,
 		}
 	}
 	for addonID, addonResult := range result.AddonIDs {
@@ -327,52 +1673,104 @@ func applyAction(c *cli.Context) error {
 			PriceID:   addonResult.PriceID,
 		}
 	}
-
-	if err := config.SaveProviderFile(providerPath, providerCfg); err != nil {
-		return fmt.Errorf("failed to save provider file: %w", err)
+	for packID, packResult := range result.CreditIDs {
+		providerCfg.Credits[packID] = config.ProductIDs{
+			ProductID: packResult.ProductID,
+			PriceID:   packResult.PriceID,
+		}
 	}
+	return providerCfg
+}
 
-	fmt.Fprintf(out, "Saved provider IDs to %s\n", providerPath)
+// keepPreviousIDs copies the IDs of objects that failed to sync from the
+// existing provider file, so a keep-going run doesn't drop them. The previous
+// catalog version is kept too, so the next apply doesn't skip the config as
+// unchanged.
+func keepPreviousIDs(providerCfg *config.ProviderConfig, previous *config.ProviderConfig, syncErrs stripe.SyncErrors) {
+	if len(syncErrs) == 0 {
+		return
+	}
+	providerCfg.CatalogVersion = ""
+	if previous == nil {
+		return
+	}
+	providerCfg.CatalogVersion = previous.CatalogVersion
+	for _, e := range syncErrs {
+		switch e.Kind {
+		case "plan":
+			if ids, ok := previous.Plans[e.ID]; ok {
+				providerCfg.Plans[e.ID] = ids
+			}
+		case "addon":
+			if ids, ok := previous.Addons[e.ID]; ok {
+				providerCfg.Addons[e.ID] = ids
+			}
+		case "credit":
+			if ids, ok := previous.Credits[e.ID]; ok {
+				providerCfg.Credits[e.ID] = ids
+			}
+		case "promotion":
+			if id, ok := previous.Promotions[e.ID]; ok {
+				providerCfg.Promotions[e.ID] = id
+			}
+		case "meter":
+			if id, ok := previous.Meters[e.ID]; ok {
+				providerCfg.Meters[e.ID] = id
+			}
+		}
+	}
+}
 
-	return nil
+// strictWarnings checks if sync warnings fail apply, via flag or settings
+func strictWarnings(c *cli.Context) bool {
+	if c.Bool("strict-warnings") {
+		return true
+	}
+	settings, err := loadSettings()
+	if err != nil {
+		return false
+	}
+	return settings.StrictWarnings
 }
 
 func importAction(c *cli.Context) error {
-	env := c.String("env")
+	if c.String("from-file") != "" {
+		return importFileAction(c)
+	}
+
+	env, stripeEnv, err := resolveStripeEnv(c)
+	if err != nil {
+		return err
+	}
+
 	outputPath := c.String("output")
 
 	out := getOutput(c)
 
-	// Validate environment
-	var stripeEnv stripe.Environment
-	switch env {
-	case "sandbox":
-		stripeEnv = stripe.Sandbox
-	case "production":
-		stripeEnv = stripe.Production
-	default:
-		return fmt.Errorf("invalid environment: %s (use 'sandbox' or 'production')", env)
-	}
-
-	// Get API key from environment
-	apiKey, err := getAPIKey(stripeEnv)
+	client, err := connectStripe(c, stripeEnv)
 	if err != nil {
 		return err
 	}
 
-	// Create Stripe client
-	client, err := stripe.NewClient(stripeEnv, apiKey)
+	// Products synced with a prefix are read back through the same keys
+	prefixed := &config.BillingConfig{Settings: &config.Settings{MetadataPrefix: c.String("metadata-prefix")}}
+	metaKeys, err := prefixed.MetadataKeys()
 	if err != nil {
-		return fmt.Errorf("failed to create Stripe client: %w", err)
+		return err
 	}
+	client.SetMetadataKeys(metaKeys, false)
 
 	fmt.Fprintf(out, "Importing from Stripe (%s)...\n", env)
 
-	result, err := client.Import()
+	result, err := provider.NewStripe(client).Import(c.Context)
 	if err != nil {
 		return fmt.Errorf("import failed: %w", err)
 	}
 
+	if prefix := c.String("metadata-prefix"); prefix != "" {
+		result.Billing.Settings = &config.Settings{MetadataPrefix: prefix}
+	}
+
 	// Write billing config to file
 	if err := config.SaveBillingFile(outputPath, result.Billing); err != nil {
 		return fmt.Errorf("failed to save billing file: %w", err)
@@ -380,12 +1778,162 @@ func importAction(c *cli.Context) error {
 
 	// Write provider config to file
 	providerPath := config.ProviderFilePath(outputPath, "stripe", env)
-	if err := config.SaveProviderFile(providerPath, result.Provider); err != nil {
+	if err := saveProviderFile(c, providerPath, result.Provider); err != nil {
 		return fmt.Errorf("failed to save provider file: %w", err)
 	}
 
 	fmt.Fprintf(out, "Imported %d plans to %s\n", len(result.Billing.Plans), outputPath)
 	fmt.Fprintf(out, "Saved provider IDs to %s\n", providerPath)
+
+	printSummary(c, "ok",
+		summaryField{"env", env},
+		summaryField{"plans", len(result.Billing.Plans)},
+		summaryField{"output", outputPath},
+		summaryField{"provider_file", providerPath})
+	return nil
+}
+
+// stdinPath is the file argument that reads the config from stdin instead
+const stdinPath = "-"
+
+// billingFileArg returns the config path given as the first argument. When it is
+// omitted, raterunner/billing.yaml is discovered by walking up from the working
+// directory and the selected file is reported on stderr.
+// With --product, products/<name>/billing.yaml is used instead.
+func billingFileArg(c *cli.Context) (string, error) {
+	return billingFileArgAt(c, 0)
+}
+
+// billingFileArgAt is billingFileArg for commands whose config path follows
+// other arguments
+func billingFileArgAt(c *cli.Context, index int) (string, error) {
+	product := c.String("product")
+	if c.NArg() > index {
+		if product != "" {
+			return "", fmt.Errorf("pass either a billing file or --product, not both")
+		}
+		return c.Args().Get(index), nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get current directory: %w", err)
+	}
+
+	var path string
+	if product != "" {
+		path, err = config.FindProductBillingFile(wd, product)
+		if err != nil {
+			return "", err
+		}
+	} else if path, err = config.FindBillingFile(wd); err != nil {
+		if products, perr := config.FindProducts(wd); perr == nil {
+			names := make([]string, len(products))
+			for i, p := range products {
+				names[i] = p.Name
+			}
+			return "", fmt.Errorf("missing required argument: billing config file path (choose a product with --product: %s)", strings.Join(names, ", "))
+		}
+		return "", fmt.Errorf("missing required argument: billing config file path (%w)", err)
+	}
+
+	display := path
+	if rel, err := filepath.Rel(wd, path); err == nil {
+		display = rel
+	}
+
+	fmt.Fprintf(getNoticeOutput(c), "Using %s\n", display)
+
+	return display, nil
+}
+
+// readStdin reads the whole config from the app's input (stdin by default),
+// decrypting it when it is encrypted with sops
+func readStdin(c *cli.Context) ([]byte, error) {
+	var r io.Reader = c.App.Reader
+	if r == nil {
+		r = os.Stdin
+	}
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return config.DecryptContent(content, inputFormat(c))
+}
+
+// inputFormat returns the --format for stdin input (yaml by default)
+func inputFormat(c *cli.Context) string {
+	if f := c.String("format"); f != "" {
+		return f
+	}
+	return "yaml"
+}
+
+// loadBillingInput loads a billing config from a file, or from stdin when the path is "-"
+func loadBillingInput(c *cli.Context, filePath string) (*config.BillingConfig, error) {
+	if filePath != stdinPath {
+		return config.LoadBillingFile(filePath)
+	}
+	content, err := readStdin(c)
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadBilling(content, inputFormat(c))
+}
+
+// loadValidBilling loads the billing config and runs the validator on it, so
+// apply never sends a config that validate rejects to Stripe.
+// --skip-validation loads it as is.
+func loadValidBilling(c *cli.Context, filePath string) (*config.BillingConfig, error) {
+	if c.Bool("skip-validation") || filePath != stdinPath {
+		cfg, err := loadBillingInput(c, filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load billing config: %w", err)
+		}
+		if c.Bool("skip-validation") {
+			return cfg, nil
+		}
+		return cfg, checkBilling(c, filePath, nil)
+	}
+
+	// stdin can only be read once
+	content, err := readStdin(c)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadBilling(content, inputFormat(c))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load billing config: %w", err)
+	}
+	return cfg, checkBilling(c, filePath, content)
+}
+
+// checkBilling validates a billing file, or the stdin content, and lists the
+// errors when it is invalid
+func checkBilling(c *cli.Context, filePath string, content []byte) error {
+	var result *validator.ValidationResult
+	suppressed := 0
+	var err error
+	label := filePath
+	if filePath == stdinPath {
+		if result, err = newValidator(c).ValidateBilling(content, inputFormat(c)); err != nil {
+			return err
+		}
+		if suppressed, err = suppressValidationErrors(result, content); err != nil {
+			return err
+		}
+		label = "<stdin>"
+	} else if result, suppressed, err = validateFile(newValidator(c), filePath, "billing"); err != nil {
+		return err
+	}
+	printValidationWarnings(c, label, result)
+
+	if !result.Valid {
+		// Errors always shown (even in quiet mode)
+		errOut := getResultOutput(c)
+		printValidationErrors(errOut, label, result, suppressed)
+		return fmt.Errorf("refusing to apply: %s has %d validation error(s) (use --skip-validation to apply anyway)", label, len(result.Errors))
+	}
 	return nil
 }
 
@@ -401,6 +1949,9 @@ func validateProvider(providers []string) error {
 		case "paddle", "chargebee":
 			return fmt.Errorf("provider '%s' is not supported yet. Contact raterunner@akorchak.software if you need support", p)
 		default:
+			if provider.IsPlugin(p) {
+				continue // applied with --provider
+			}
 			return fmt.Errorf("unknown provider: %s", p)
 		}
 	}
@@ -414,7 +1965,7 @@ func validateProvider(providers []string) error {
 		}
 	}
 	if !hasStripe {
-		return fmt.Errorf("billing config must include 'stripe' provider for apply command")
+		return fmt.Errorf("billing config must include 'stripe' provider for apply command (use --provider to apply to a plugin provider)")
 	}
 
 	return nil
@@ -437,12 +1988,82 @@ func getAPIKey(env stripe.Environment) (string, error) {
 	return key, nil
 }
 
+// loadSettings returns the user settings merged with the project .raterunner.yaml
+// found from the working directory upwards
+func loadSettings() (*config.CLISettings, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current directory: %w", err)
+	}
+	return config.LoadEffectiveSettings(wd)
+}
+
+// resolveEnv returns --env, falling back to the default_env setting.
+// Production is never a default: it must always be passed explicitly.
+func resolveEnv(c *cli.Context) (string, error) {
+	if env := c.String("env"); env != "" {
+		return env, nil
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		return "", fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	switch settings.DefaultEnv {
+	case "":
+		return "", fmt.Errorf("missing required flag: --env (or set a default with 'raterunner config set default_env sandbox')")
+	case string(stripe.Production):
+		return "", fmt.Errorf("default_env cannot be production; pass --env production explicitly")
+	}
+
+	fmt.Fprintf(getNoticeOutput(c), "Using default environment: %s\n", settings.DefaultEnv)
+	return settings.DefaultEnv, nil
+}
+
+// gitCommit returns the short commit hash of the repository containing dir,
+// or "" when dir isn't in a git repository or git isn't installed
+func gitCommit(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// stabilizeDiff prepares a diff result for output with --deterministic
+func stabilizeDiff(c *cli.Context, result *diff.DiffResult) {
+	if c.Bool("deterministic") {
+		result.Stabilize()
+	}
+}
+
+// sortedWarnings returns sync warnings in message order with --deterministic.
+// Sync collects them in sync order, which follows the config order and the
+// references between plans, so reordering the config would change the output.
+func sortedWarnings(c *cli.Context, warnings []stripe.Warning) []stripe.Warning {
+	if !c.Bool("deterministic") {
+		return warnings
+	}
+	sorted := slices.Clone(warnings)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Message < sorted[j].Message })
+	return sorted
+}
+
+// saveProviderFile writes a provider file, without synced_at with --deterministic
+func saveProviderFile(c *cli.Context, path string, providerCfg *config.ProviderConfig) error {
+	if c.Bool("deterministic") {
+		providerCfg.SyncedAt = ""
+	}
+	return config.SaveProviderFile(path, providerCfg)
+}
+
 // isQuiet checks if quiet mode is enabled via flag or saved config
 func isQuiet(c *cli.Context) bool {
 	if c.Bool("quiet") {
 		return true
 	}
-	settings, err := config.LoadSettings()
+	settings, err := loadSettings()
 	if err != nil {
 		return false
 	}
@@ -454,23 +2075,184 @@ func getOutput(c *cli.Context) io.Writer {
 	if isQuiet(c) {
 		return io.Discard
 	}
-	out := c.App.Writer
-	if out == nil {
-		out = os.Stdout
+	return getResultOutput(c)
+}
+
+// verbosity returns how many times --verbose/-v was given
+func verbosity(c *cli.Context) int {
+	return c.Count("verbose")
+}
+
+// newStripeClient creates a Stripe client and wires up progress and request
+// logging according to --verbose: -v logs created objects, -vv also logs
+// every API request with secrets redacted.
+func newStripeClient(c *cli.Context, env stripe.Environment, apiKey string) (*stripe.Client, error) {
+	out := getOutput(c)
+	level := verbosity(c)
+
+	if level >= 2 {
+		stripe.SetRequestLogging(out)
+	}
+	var logf stripe.Logger
+	if level >= 1 {
+		logf = func(format string, args ...any) {
+			fmt.Fprintf(out, "  "+format+"\n", args...)
+		}
+	}
+
+	return openStripeClient(env, apiKey, c.Bool("refresh"), logf)
+}
+
+// openStripeClient creates a Stripe client with the HTTP settings and the
+// product cache. logf receives progress messages when set.
+func openStripeClient(env stripe.Environment, apiKey string, refresh bool, logf stripe.Logger) (*stripe.Client, error) {
+	settings, err := loadSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	stripe.ConfigureHTTP(stripe.HTTPOptions{
+		MaxIdleConns:    settings.HTTPMaxIdleConns,
+		MaxConnsPerHost: settings.HTTPMaxConnsPerHost,
+	})
+	retry := stripe.RetryOptions{MaxAttempts: settings.RetryMaxAttempts, Jitter: stripe.DefaultRetryJitter}
+	if settings.RetryBackoff != "" {
+		if retry.Backoff, err = time.ParseDuration(settings.RetryBackoff); err != nil {
+			return nil, fmt.Errorf("invalid retry_backoff setting: %w", err)
+		}
+	}
+	if settings.RetryJitter != nil {
+		retry.Jitter = *settings.RetryJitter
+	}
+	stripe.ConfigureRetries(retry)
+
+	client, err := stripe.NewClient(env, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	client.SetProductCache(&stripe.ProductCache{
+		Dir:     productCacheDir(),
+		TTL:     productCacheTTL,
+		Refresh: refresh,
+	})
+	if logf != nil {
+		client.SetLogger(logf)
+	}
+
+	return client, nil
+}
+
+// productCacheTTL is how long dry runs and status reuse the products they
+// fetched from Stripe
+const productCacheTTL = 10 * time.Minute
+
+// productCacheDir holds the cached products of every project on this machine
+func productCacheDir() string {
+	return filepath.Join(filepath.Dir(config.DefaultSettingsPath()), "cache")
+}
+
+// noteCachedProducts tells the user when products came from the cache
+func noteCachedProducts(c *cli.Context, env string, cachedAt time.Time) {
+	if cachedAt.IsZero() {
+		return
+	}
+	fmt.Fprintf(getNoticeOutput(c), "note: using %s products cached at %s; pass --refresh to fetch them again\n", env, cachedAt.Local().Format("15:04:05"))
+}
+
+// getNoticeOutput returns the writer for notices about implicit choices (discard if
+// quiet, otherwise stderr) so they never mix with a command's stdout result
+func getNoticeOutput(c *cli.Context) io.Writer {
+	if isQuiet(c) {
+		return io.Discard
+	}
+	return getErrorOutput(c)
+}
+
+// summaryField is one key/value pair of the --summary result line
+type summaryField struct {
+	Key   string
+	Value any
+}
+
+// summaryHook receives the summary instead of --summary, when set in the app
+// metadata under summaryHookKey, e.g. by the gRPC server
+type summaryHook func(status string, fields []summaryField)
+
+const summaryHookKey = "summary-hook"
+
+// summaryDetail wraps a summaryField value that only the JSON summary carries,
+// such as a list that has no sensible key=value form
+type summaryDetail struct {
+	Value any
+}
+
+// printSummary writes exactly one machine-friendly result line when --summary is set.
+// It bypasses quiet mode so scripts always learn what a command did.
+func printSummary(c *cli.Context, status string, fields ...summaryField) {
+	if hook, ok := c.App.Metadata[summaryHookKey].(summaryHook); ok {
+		hook(status, fields)
+		return
+	}
+	if !c.Bool("summary") {
+		return
+	}
+
+	out := getResultOutput(c)
+
+	command := c.Command.FullName()
+
+	if c.String("summary-format") == "json" {
+		line := map[string]any{"command": command, "status": status}
+		for _, f := range fields {
+			if d, ok := f.Value.(summaryDetail); ok {
+				line[f.Key] = d.Value
+				continue
+			}
+			line[f.Key] = f.Value
+		}
+		data, err := json.Marshal(line)
+		if err != nil {
+			return
+		}
+		fmt.Fprintln(out, string(data))
+		return
+	}
+
+	parts := []string{"command=" + strings.ReplaceAll(command, " ", "."), "status=" + status}
+	for _, f := range fields {
+		if _, ok := f.Value.(summaryDetail); ok {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", f.Key, f.Value))
 	}
-	return out
+	fmt.Fprintln(out, strings.Join(parts, " "))
 }
 
 func truncateAction(c *cli.Context) error {
 	out := getOutput(c)
 
+	// Production is refused before anything else, including the prompt
+	env := c.String("env")
+	if env == "" {
+		env = string(stripe.Sandbox)
+	}
+	stripeEnv, err := parseEnv(env)
+	if err != nil {
+		return err
+	}
+	if stripeEnv == stripe.Production {
+		return fmt.Errorf("truncate never runs against production: it archives every product and price; use 'raterunner cleanup --env production' to remove unused objects")
+	}
+
 	// Interactive confirmation always shown (even in quiet mode)
 	if !c.Bool("confirm") {
-		consoleOut := c.App.Writer
-		if consoleOut == nil {
-			consoleOut = os.Stdout
-		}
+		consoleOut := getResultOutput(c)
 		fmt.Fprintln(consoleOut, "WARNING: This will archive ALL products, prices, and delete coupons in your Stripe sandbox account.")
+		if c.Bool("subscriptions") {
+			fmt.Fprintln(consoleOut, "It will also cancel the subscriptions created by 'raterunner seed'.")
+		}
+		if c.Bool("customers") {
+			fmt.Fprintln(consoleOut, "It will also delete the customers and test clocks created by 'raterunner seed'.")
+		}
 		fmt.Fprint(consoleOut, "Are you sure? [y/N]: ")
 
 		var response string
@@ -483,27 +2265,40 @@ func truncateAction(c *cli.Context) error {
 		}
 	}
 
-	// Get API key - only sandbox is allowed
-	apiKey, err := getAPIKey(stripe.Sandbox)
+	client, err := connectStripe(c, stripeEnv)
 	if err != nil {
 		return err
 	}
 
-	// Create Stripe client
-	client, err := stripe.NewClient(stripe.Sandbox, apiKey)
+	release, err := acquireRunLock(c, client, stripeEnv)
 	if err != nil {
-		return fmt.Errorf("failed to create Stripe client: %w", err)
+		return err
 	}
+	defer release()
 
 	fmt.Fprintln(out, "Archiving all products, prices, and deleting coupons in sandbox...")
 
-	result, err := client.Truncate()
+	opts := provider.TruncateOptions{Subscriptions: c.Bool("subscriptions"), Customers: c.Bool("customers")}
+	result, err := provider.NewStripe(client).Truncate(c.Context, opts)
 	if err != nil {
 		return fmt.Errorf("truncate failed: %w", err)
 	}
 
 	fmt.Fprintf(out, "Done. Archived %d prices, %d products. Deleted %d coupons.\n",
 		result.PricesArchived, result.ProductsArchived, result.CouponsDeleted)
+	if opts.Subscriptions || opts.Customers {
+		fmt.Fprintf(out, "Seeded test data: canceled %d subscriptions, deleted %d customers and %d test clocks.\n",
+			result.SubscriptionsCanceled, result.CustomersDeleted, result.TestClocksDeleted)
+	}
+
+	printSummary(c, "ok",
+		summaryField{"env", "sandbox"},
+		summaryField{"prices_archived", result.PricesArchived},
+		summaryField{"products_archived", result.ProductsArchived},
+		summaryField{"coupons_deleted", result.CouponsDeleted},
+		summaryField{"subscriptions_canceled", result.SubscriptionsCanceled},
+		summaryField{"customers_deleted", result.CustomersDeleted},
+		summaryField{"test_clocks_deleted", result.TestClocksDeleted})
 	return nil
 }
 
@@ -512,10 +2307,7 @@ func configSetAction(c *cli.Context) error {
 		return fmt.Errorf("usage: raterunner config set <key> <value>")
 	}
 
-	out := c.App.Writer
-	if out == nil {
-		out = os.Stdout
-	}
+	out := getResultOutput(c)
 
 	key := c.Args().Get(0)
 	value := c.Args().Get(1)
@@ -528,8 +2320,76 @@ func configSetAction(c *cli.Context) error {
 	switch key {
 	case "quiet":
 		settings.Quiet = value == "true" || value == "1" || value == "yes"
+	case "strict_warnings":
+		settings.StrictWarnings = value == "true" || value == "1" || value == "yes"
+	case "default_env":
+		switch value {
+		case "sandbox":
+			settings.DefaultEnv = value
+		case "none", "":
+			settings.DefaultEnv = ""
+		case "production":
+			return fmt.Errorf("default_env cannot be production; always pass --env production explicitly")
+		default:
+			return fmt.Errorf("invalid default_env: %s (use 'sandbox' or 'none')", value)
+		}
+	case "schema_dir":
+		settings.SchemaDir = value
+	case "http_max_idle_conns", "http_max_conns_per_host":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s: %s (use a whole number, 0 for the default)", key, value)
+		}
+		if key == "http_max_idle_conns" {
+			settings.HTTPMaxIdleConns = n
+		} else {
+			settings.HTTPMaxConnsPerHost = n
+		}
+	case "retry_max_attempts":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s: %s (use a whole number, 1 to disable retries, 0 for the default)", key, value)
+		}
+		settings.RetryMaxAttempts = n
+	case "retry_backoff":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				return fmt.Errorf("invalid %s: %s (use a duration such as 500ms or 2s, or \"\" for the default)", key, value)
+			}
+		}
+		settings.RetryBackoff = value
+	case "retry_jitter":
+		if value == "" {
+			settings.RetryJitter = nil
+			break
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 || f > 1 {
+			return fmt.Errorf("invalid %s: %s (use a fraction from 0 to 1, or \"\" for the default)", key, value)
+		}
+		settings.RetryJitter = &f
+	case "telemetry":
+		settings.Telemetry = value == "true" || value == "1" || value == "yes"
+		if !settings.Telemetry {
+			settings.TelemetryID = ""
+		} else if settings.TelemetryID == "" {
+			id, err := telemetry.NewInstallID()
+			if err != nil {
+				return err
+			}
+			settings.TelemetryID = id
+		}
+	case "telemetry_endpoint":
+		if value != "" && !strings.HasPrefix(value, "https://") && !strings.HasPrefix(value, "http://") {
+			return fmt.Errorf("invalid telemetry_endpoint: %s (use an http or https URL, or \"\" for the default)", value)
+		}
+		settings.TelemetryEndpoint = value
+	case "diagnostics_dir":
+		settings.DiagnosticsDir = value
+	case "role":
+		return fmt.Errorf("role can't be changed with config set; it is managed in the settings file")
 	default:
-		return fmt.Errorf("unknown config key: %s (available: quiet)", key)
+		return fmt.Errorf("unknown config key: %s (available: quiet, default_env, schema_dir, strict_warnings, http_max_idle_conns, http_max_conns_per_host, retry_max_attempts, retry_backoff, retry_jitter, telemetry, telemetry_endpoint, diagnostics_dir)", key)
 	}
 
 	if err := config.SaveSettings(settings); err != nil {
@@ -545,14 +2405,11 @@ func configGetAction(c *cli.Context) error {
 		return fmt.Errorf("usage: raterunner config get <key>")
 	}
 
-	out := c.App.Writer
-	if out == nil {
-		out = os.Stdout
-	}
+	out := getResultOutput(c)
 
 	key := c.Args().Get(0)
 
-	settings, err := config.LoadSettings()
+	settings, err := loadSettings()
 	if err != nil {
 		return fmt.Errorf("failed to load settings: %w", err)
 	}
@@ -560,35 +2417,80 @@ func configGetAction(c *cli.Context) error {
 	switch key {
 	case "quiet":
 		fmt.Fprintf(out, "%v\n", settings.Quiet)
+	case "strict_warnings":
+		fmt.Fprintf(out, "%v\n", settings.StrictWarnings)
+	case "default_env":
+		fmt.Fprintf(out, "%s\n", settings.DefaultEnv)
+	case "schema_dir":
+		fmt.Fprintf(out, "%s\n", settings.SchemaDir)
+	case "http_max_idle_conns":
+		fmt.Fprintf(out, "%d\n", settings.HTTPMaxIdleConns)
+	case "http_max_conns_per_host":
+		fmt.Fprintf(out, "%d\n", settings.HTTPMaxConnsPerHost)
+	case "retry_max_attempts":
+		fmt.Fprintf(out, "%d\n", settings.RetryMaxAttempts)
+	case "retry_backoff":
+		fmt.Fprintf(out, "%s\n", settings.RetryBackoff)
+	case "retry_jitter":
+		fmt.Fprintf(out, "%s\n", retryJitterSetting(settings))
+	case "role":
+		fmt.Fprintf(out, "%s\n", settings.Role)
+	case "telemetry":
+		fmt.Fprintf(out, "%v\n", settings.Telemetry)
+	case "telemetry_endpoint":
+		fmt.Fprintf(out, "%s\n", settings.TelemetryEndpoint)
+	case "diagnostics_dir":
+		fmt.Fprintf(out, "%s\n", settings.DiagnosticsDir)
 	default:
-		return fmt.Errorf("unknown config key: %s (available: quiet)", key)
+		return fmt.Errorf("unknown config key: %s (available: quiet, default_env, schema_dir, strict_warnings, http_max_idle_conns, http_max_conns_per_host, retry_max_attempts, retry_backoff, retry_jitter, role, telemetry, telemetry_endpoint, diagnostics_dir)", key)
 	}
 
 	return nil
 }
 
 func configListAction(c *cli.Context) error {
-	out := c.App.Writer
-	if out == nil {
-		out = os.Stdout
-	}
+	out := getResultOutput(c)
 
-	settings, err := config.LoadSettings()
+	settings, err := loadSettings()
 	if err != nil {
 		return fmt.Errorf("failed to load settings: %w", err)
 	}
 
 	fmt.Fprintf(out, "quiet = %v\n", settings.Quiet)
+	fmt.Fprintf(out, "strict_warnings = %v\n", settings.StrictWarnings)
+	fmt.Fprintf(out, "default_env = %s\n", settings.DefaultEnv)
+	fmt.Fprintf(out, "schema_dir = %s\n", settings.SchemaDir)
+	fmt.Fprintf(out, "http_max_idle_conns = %d\n", settings.HTTPMaxIdleConns)
+	fmt.Fprintf(out, "http_max_conns_per_host = %d\n", settings.HTTPMaxConnsPerHost)
+	fmt.Fprintf(out, "retry_max_attempts = %d\n", settings.RetryMaxAttempts)
+	fmt.Fprintf(out, "retry_backoff = %s\n", settings.RetryBackoff)
+	fmt.Fprintf(out, "retry_jitter = %s\n", retryJitterSetting(settings))
+	fmt.Fprintf(out, "role = %s\n", settings.Role)
+	fmt.Fprintf(out, "telemetry = %v\n", settings.Telemetry)
+	fmt.Fprintf(out, "telemetry_endpoint = %s\n", settings.TelemetryEndpoint)
+	fmt.Fprintf(out, "diagnostics_dir = %s\n", settings.DiagnosticsDir)
 	return nil
 }
 
-func configPathAction(c *cli.Context) error {
-	out := c.App.Writer
-	if out == nil {
-		out = os.Stdout
+// retryJitterSetting formats retry_jitter, "" when unset
+func retryJitterSetting(settings *config.CLISettings) string {
+	if settings.RetryJitter == nil {
+		return ""
 	}
+	return strconv.FormatFloat(*settings.RetryJitter, 'f', -1, 64)
+}
+
+func configPathAction(c *cli.Context) error {
+	out := getResultOutput(c)
 
 	fmt.Fprintln(out, config.DefaultSettingsPath())
+
+	// The project file, if any, overrides the user settings above
+	if wd, err := os.Getwd(); err == nil {
+		if path, ok := config.FindProjectSettings(wd); ok {
+			fmt.Fprintln(out, path)
+		}
+	}
 	return nil
 }
 
@@ -609,11 +2511,12 @@ func initAction(c *cli.Context) error {
 	}
 
 	// Create files
-	if err := config.CreateInitFiles(dir); err != nil {
+	if err := config.CreateInitFiles(dir, c.String("template")); err != nil {
 		return fmt.Errorf("failed to create files: %w", err)
 	}
 
 	fmt.Fprintf(out, "Created %s\n", config.InitFilePath(dir))
+	printSummary(c, "ok", summaryField{"file", config.InitFilePath(dir)})
 	fmt.Fprintln(out, "")
 	fmt.Fprintln(out, "Next steps:")
 	fmt.Fprintln(out, "  1. Edit raterunner/billing.yaml to define your plans")
@@ -2,28 +2,87 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	stripeapi "github.com/stripe/stripe-go/v82"
 	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gopkg.in/yaml.v3"
+
+	"raterunner/internal/checkout"
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/export"
+	"raterunner/internal/lock"
+	"raterunner/internal/operator"
+	"raterunner/internal/plan"
+	"raterunner/internal/provider"
+	"raterunner/internal/redact"
+	"raterunner/internal/report"
+	"raterunner/internal/rpc/raterunnerv1"
+	"raterunner/internal/stripe"
+	"raterunner/internal/validator"
+	"raterunner/pkg/testkit"
 )
 
 func runApp(args ...string) (stdout, stderr string, exitCode int) {
+	return runAppWithStdin("", args...)
+}
+
+func runAppWithStdin(stdin string, args ...string) (stdout, stderr string, exitCode int) {
 	var outBuf, errBuf bytes.Buffer
 
 	app := &cli.App{
 		Name:      "raterunner",
 		Usage:     "Raterunner CLI - billing configuration management",
 		Version:   "0.1.0",
+		Reader:    strings.NewReader(stdin),
 		Writer:    &outBuf,
 		ErrWriter: &errBuf,
+		// Allows stacking short flags such as -vv
+		UseShortOptionHandling: true,
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:    "quiet",
 				Aliases: []string{"q"},
 				Usage:   "Suppress non-essential output",
 			},
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+				Usage:   "Show per-plan progress",
+			},
+			&cli.BoolFlag{
+				Name:  "summary",
+				Usage: "Print one machine-friendly result line",
+			},
+			&cli.StringFlag{
+				Name:  "summary-format",
+				Usage: "Format of the --summary line",
+				Value: "text",
+			},
+			&cli.StringFlag{Name: "product"},
+			&cli.BoolFlag{Name: "deterministic"},
+			&cli.StringFlag{Name: "diagnostics-dir"},
 		},
 		Commands: []*cli.Command{
 			{
@@ -35,32 +94,85 @@ func runApp(args ...string) (stdout, stderr string, exitCode int) {
 						Name:  "force",
 						Usage: "Overwrite existing files",
 					},
+					&cli.StringFlag{Name: "template", Usage: "Start from a template"},
 				},
 				Action: initAction,
 			},
 			{
 				Name:      "validate",
 				Usage:     "Validate a billing or provider configuration file",
-				ArgsUsage: "<file>",
+				ArgsUsage: "[file|-]",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:    "schema-dir",
 						Aliases: []string{"s"},
 						Usage:   "Path to directory containing schema files",
 					},
+					&cli.StringFlag{
+						Name:    "type",
+						Aliases: []string{"t"},
+						Usage:   "Schema type: billing or provider",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Input format for stdin",
+					},
+					&cli.BoolFlag{Name: "manifest", Usage: "Validate a manifest"},
 				},
 				Action: validateAction,
 			},
+			{
+				Name:      "lint",
+				Usage:     "Warn about billing config choices that are valid but likely mistakes",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Output format: text, json, or sarif",
+						Value: "text",
+					},
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "Exit with code 1 when there are warnings",
+					},
+					&cli.StringSliceFlag{
+						Name:  "enable",
+						Usage: "Enable optional rules by pack (e.g. pricing) or rule ID",
+					},
+					&cli.StringSliceFlag{
+						Name:  "disable",
+						Usage: "Disable rules by rule ID or pack",
+					},
+					&cli.BoolFlag{
+						Name:  "copy",
+						Usage: "Check customer-facing copy for consistency (same as --enable copy)",
+					},
+					&cli.StringFlag{
+						Name:  "feature-periods",
+						Usage: "Trailing period policy for feature text: omit or require",
+						Value: "omit",
+					},
+					&cli.BoolFlag{
+						Name:  "list-rules",
+						Usage: "List available rules and exit",
+					},
+				},
+				Action: lintAction,
+			},
+			{
+				Name:   "hash",
+				Flags:  []cli.Flag{&cli.StringFlag{Name: "format"}},
+				Action: hashAction,
+			},
 			{
 				Name:      "apply",
 				Usage:     "Sync local billing config to Stripe",
-				ArgsUsage: "<billing.yaml>",
+				ArgsUsage: "[billing.yaml]",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "env",
-						Aliases:  []string{"e"},
-						Usage:    "Environment: sandbox or production",
-						Required: true,
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production",
 					},
 					&cli.BoolFlag{
 						Name:  "dry-run",
@@ -71,6 +183,30 @@ func runApp(args ...string) (stdout, stderr string, exitCode int) {
 						Aliases: []string{"j"},
 						Usage:   "Output as JSON instead of table",
 					},
+					&cli.StringFlag{
+						Name:  "format",
+						Usage: "Input format for stdin",
+					},
+					&cli.BoolFlag{
+						Name:  "migrate-metadata",
+						Usage: "Move un-prefixed metadata keys",
+					},
+					&cli.BoolFlag{Name: "strict-warnings", Usage: "Fail on sync warnings"},
+					&cli.StringSliceFlag{Name: "regen-exports", Usage: "Rewrite JSON exports"},
+					&cli.BoolFlag{Name: "serial", Usage: "Sync plans in config order"},
+					&cli.BoolFlag{Name: "force", Usage: "Apply an unchanged config"},
+					&cli.BoolFlag{Name: "refresh", Usage: "Skip cached products"},
+					&cli.StringSliceFlag{Name: "only", Usage: "Compare only these plans"},
+					&cli.BoolFlag{Name: "keep-going", Usage: "Continue past objects that fail to sync"},
+					&cli.BoolFlag{Name: "skip-validation", Usage: "Apply without validating the billing config first"},
+					&cli.BoolFlag{Name: "manifest", Usage: "Apply a manifest"},
+					&cli.BoolFlag{Name: "force-unlock", Usage: "Remove a stale lock"},
+					&cli.BoolFlag{Name: "stripe-lock", Usage: "Also lock in Stripe"},
+					&cli.StringFlag{Name: "provider", Usage: "Apply to a plugin provider"},
+					&cli.BoolFlag{Name: "prune", Usage: "Archive products of removed plans"},
+					&cli.StringFlag{Name: "confirm-account", Usage: "Account ID that confirms pruning production"},
+					&cli.StringFlag{Name: "save-plan", Usage: "Save the changes for apply --plan"},
+					&cli.StringFlag{Name: "plan", Usage: "Apply a saved plan"},
 				},
 				Action: applyAction,
 			},
@@ -79,10 +215,9 @@ func runApp(args ...string) (stdout, stderr string, exitCode int) {
 				Usage: "Import products and prices from Stripe to a local YAML file",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
-						Name:     "env",
-						Aliases:  []string{"e"},
-						Usage:    "Environment: sandbox or production",
-						Required: true,
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production",
 					},
 					&cli.StringFlag{
 						Name:     "output",
@@ -90,9 +225,28 @@ func runApp(args ...string) (stdout, stderr string, exitCode int) {
 						Usage:    "Output file path",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:  "metadata-prefix",
+						Usage: "Metadata key prefix",
+					},
+					&cli.StringFlag{Name: "from-file", Usage: "Catalog export file"},
+					&cli.StringFlag{Name: "format", Usage: "Catalog export format"},
 				},
 				Action: importAction,
 			},
+			{
+				Name: "clone",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "from", Required: true},
+					&cli.StringFlag{Name: "to", Required: true},
+					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Required: true},
+					&cli.StringFlag{Name: "metadata-prefix"},
+					&cli.BoolFlag{Name: "skip-validation"},
+					&cli.BoolFlag{Name: "force-unlock"},
+					&cli.BoolFlag{Name: "stripe-lock"},
+				},
+				Action: cloneAction,
+			},
 			{
 				Name:  "truncate",
 				Usage: "Archive all products and prices in Stripe (sandbox only)",
@@ -101,9 +255,293 @@ func runApp(args ...string) (stdout, stderr string, exitCode int) {
 						Name:  "confirm",
 						Usage: "Skip interactive confirmation",
 					},
+					&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+					&cli.BoolFlag{Name: "subscriptions"},
+					&cli.BoolFlag{Name: "customers"},
+					&cli.BoolFlag{Name: "force-unlock", Usage: "Remove a stale lock"},
+					&cli.BoolFlag{Name: "stripe-lock", Usage: "Also lock in Stripe"},
 				},
 				Action: truncateAction,
 			},
+			{
+				Name:      "status",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "json", Aliases: []string{"j"}},
+					&cli.BoolFlag{Name: "all-products"},
+					&cli.BoolFlag{Name: "refresh"},
+				},
+				Action: statusAction,
+			},
+			{
+				Name:      "cleanup",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+					&cli.BoolFlag{Name: "delete-where-possible"},
+					&cli.BoolFlag{Name: "confirm"},
+					&cli.StringFlag{Name: "confirm-account"},
+					&cli.BoolFlag{Name: "json", Aliases: []string{"j"}},
+				},
+				Action: cleanupAction,
+			},
+			{
+				Name: "events",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+					&cli.StringFlag{Name: "since", Value: "24h"},
+					&cli.BoolFlag{Name: "json", Aliases: []string{"j"}},
+				},
+				Action: eventsAction,
+			},
+			{
+				Name:      "subscribers",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+					&cli.StringFlag{Name: "format", Value: "text"},
+				},
+				Action: subscribersAction,
+			},
+			{
+				Name:      "seed",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+					&cli.IntFlag{Name: "customers", Value: 10},
+					&cli.StringFlag{Name: "interval", Value: "monthly"},
+					&cli.BoolFlag{Name: "test-clocks"},
+					&cli.BoolFlag{Name: "dry-run"},
+				},
+				Action: seedAction,
+			},
+			{
+				Name:      "mrr",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+					&cli.StringFlag{Name: "format", Value: "text"},
+				},
+				Action: mrrAction,
+			},
+			{Name: "lsp", Action: lspAction},
+			{
+				Name: "serve",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "grpc"},
+					&cli.StringFlag{Name: "schema-dir", Aliases: []string{"s"}},
+				},
+				Action: serveAction,
+			},
+			{
+				Name: "operator",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "namespace"},
+					&cli.StringFlag{Name: "kube-api"},
+					&cli.DurationFlag{Name: "resync", Value: time.Hour},
+					&cli.BoolFlag{Name: "allow-production"},
+					&cli.BoolFlag{Name: "stripe-lock"},
+					&cli.StringFlag{Name: "schema-dir", Aliases: []string{"s"}},
+				},
+				Action: operatorAction,
+			},
+			{
+				Name:      "doctor",
+				Usage:     "Check the billing config, Stripe credentials, and account setup",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "env",
+						Aliases: []string{"e"},
+						Usage:   "Environment: sandbox or production (defaults to the default_env setting)",
+					},
+				},
+				Action: doctorAction,
+			},
+			{
+				Name:  "schema",
+				Usage: "Work with the JSON schemas embedded in this binary",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "print",
+						Usage:     "Print the embedded billing or provider JSON schema",
+						ArgsUsage: "[billing|provider]",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "resolve-refs",
+								Usage: "Inline $defs references into a single flattened schema",
+							},
+							&cli.IntFlag{
+								Name:  "version",
+								Usage: "Billing schema version to print (defaults to the newest)",
+							},
+						},
+						Action: schemaPrintAction,
+					},
+					{
+						Name:   "vscode-settings",
+						Flags:  []cli.Flag{&cli.StringFlag{Name: "write-schemas", Usage: "Write the schemas here"}},
+						Action: schemaVSCodeSettingsAction,
+					},
+				},
+			},
+			{
+				Name:      "export",
+				Usage:     "Export plans, prices, and entitlements as JSON",
+				ArgsUsage: "[billing.yaml]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Where to write the export",
+					},
+					&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+					&cli.StringFlag{
+						Name:  "unlimited",
+						Usage: "How to write unlimited limits",
+						Value: "null",
+					},
+					&cli.BoolFlag{Name: "public-only"},
+					&cli.StringFlag{Name: "region"},
+				},
+				Action: exportAction,
+			},
+			{
+				Name: "generate",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "checkout",
+						ArgsUsage: "[billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "plan", Aliases: []string{"p"}, Required: true},
+							&cli.StringFlag{Name: "interval", Aliases: []string{"i"}},
+							&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+							&cli.StringFlag{Name: "lang"},
+							&cli.StringFlag{Name: "success-url", Value: checkout.DefaultSuccessURL},
+							&cli.StringFlag{Name: "cancel-url", Value: checkout.DefaultCancelURL},
+						},
+						Action: generateCheckoutAction,
+					},
+				},
+			},
+			{
+				Name:  "flags",
+				Usage: "Sync bool entitlements to a feature flag system",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "sync",
+						Usage:     "Target feature flags to plans",
+						ArgsUsage: "[billing.yaml]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "provider",
+								Aliases:  []string{"p"},
+								Usage:    "Feature flag provider",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:  "project",
+								Usage: "Flag provider project key",
+							},
+							&cli.StringFlag{
+								Name:  "environment",
+								Usage: "Flag provider environment key",
+							},
+							&cli.BoolFlag{
+								Name:  "dry-run",
+								Usage: "Print flag targeting only",
+							},
+						},
+						Action: flagsSyncAction,
+					},
+				},
+			},
+			{
+				Name: "promos",
+				Subcommands: []*cli.Command{
+					{
+						Name: "generate",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "env", Aliases: []string{"e"}},
+							&cli.StringFlag{Name: "coupon", Required: true},
+							&cli.IntFlag{Name: "count", Required: true},
+							&cli.StringFlag{Name: "prefix"},
+							&cli.IntFlag{Name: "length", Value: 8},
+							&cli.StringFlag{Name: "out"},
+							&cli.StringFlag{Name: "expires"},
+							&cli.BoolFlag{Name: "new-customers-only"},
+							&cli.BoolFlag{Name: "dry-run"},
+						},
+						Action: promosGenerateAction,
+					},
+				},
+			},
+			{
+				Name: "plans",
+				Subcommands: []*cli.Command{
+					{
+						Name: "copy",
+						Flags: []cli.Flag{
+							&cli.Float64Flag{Name: "price-multiplier", Value: 1},
+							&cli.BoolFlag{Name: "dry-run"},
+						},
+						Action: plansCopyAction,
+					},
+					{
+						Name:   "graph",
+						Flags:  []cli.Flag{&cli.StringFlag{Name: "format", Value: "mermaid"}},
+						Action: plansGraphAction,
+					},
+				},
+			},
+			{
+				Name: "prices",
+				Subcommands: []*cli.Command{
+					{
+						Name: "localize",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "currencies", Required: true},
+							&cli.StringFlag{Name: "rates", Required: true},
+							&cli.StringFlag{Name: "rounding", Value: config.RoundingNone},
+							&cli.BoolFlag{Name: "dry-run"},
+						},
+						Action: pricesLocalizeAction,
+					},
+				},
+			},
+			{
+				Name: "refactor",
+				Subcommands: []*cli.Command{
+					{
+						Name: "rename-entitlement",
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{Name: "export"},
+							&cli.BoolFlag{Name: "dry-run"},
+						},
+						Action: refactorRenameEntitlementAction,
+					},
+				},
+			},
+			{
+				Name: "provider-file",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "migrate",
+						Action: providerFileMigrateAction,
+					},
+					{
+						Name:   "compact",
+						Flags:  []cli.Flag{&cli.IntFlag{Name: "keep"}},
+						Action: providerFileCompactAction,
+					},
+				},
+			},
+			{
+				Name: "plugins",
+				Subcommands: []*cli.Command{
+					{Name: "list", Action: pluginsListAction},
+				},
+			},
 			{
 				Name:  "config",
 				Usage: "Manage CLI configuration",
@@ -132,6 +570,12 @@ func runApp(args ...string) (stdout, stderr string, exitCode int) {
 					},
 				},
 			},
+			{
+				Name: "telemetry",
+				Subcommands: []*cli.Command{
+					{Name: "status", Action: telemetryStatusAction},
+				},
+			},
 		},
 		ExitErrHandler: func(c *cli.Context, err error) {
 			// Write error to stdout to capture it in tests (matches main.go behavior)
@@ -141,6 +585,10 @@ func runApp(args ...string) (stdout, stderr string, exitCode int) {
 		},
 	}
 
+	gateCommands(app.Commands, "")
+	diagnoseCommands(app.Commands, "")
+	trackCommands(app.Commands, "")
+
 	fullArgs := append([]string{"raterunner"}, args...)
 	err := app.Run(fullArgs)
 
@@ -165,6 +613,48 @@ func TestValidate_ValidBillingMinimal(t *testing.T) {
 	assertContains(t, stdout, "is valid")
 }
 
+func TestValidate_ValidBillingRegions(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_regions.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_ValidBillingCredits(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_credits.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_ValidBillingMeters(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_meters.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_ValidBillingDunning(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_dunning.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_ValidBillingInvoice(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_invoice.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_ValidBillingManualInvoicing(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_manual_invoicing.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
 func TestValidate_ValidBillingFull(t *testing.T) {
 	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_full.yaml")
 
@@ -233,6 +723,15 @@ func TestValidate_InvalidProviderFile(t *testing.T) {
 	assertContains(t, stdout, "validation error")
 }
 
+func TestValidate_ProviderIDPrefixes(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/provider_bad_ids.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "has 2 validation error(s)")
+	assertContains(t, stdout, `/plans/pro/product_id: Stripe ID must start with prod_ (got "price_1SuH5lQe3kmrxgoYdo4MDTlb")`)
+	assertContains(t, stdout, "/plans/pro/prices/monthly: Stripe ID must start with price_")
+}
+
 func TestValidate_UnsupportedProviderInBilling(t *testing.T) {
 	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_unsupported_provider.yaml")
 
@@ -266,318 +765,4779 @@ func TestValidate_UndefinedEntitlementInAddon(t *testing.T) {
 	assertContains(t, stdout, "nonexistent")
 }
 
-// --- File handling errors ---
-
-func TestValidate_MalformedYAML(t *testing.T) {
-	_, _, exitCode := runApp("validate", "testdata/errors/malformed.yaml")
+func TestValidate_RateLimitMismatch(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_rate_limit_mismatch.yaml")
 
 	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "'api_requests' is a rate limit and requires {limit, per}")
+	assertContains(t, stdout, "'sso' is not a rate limit")
 }
 
-func TestValidate_NonExistentFile(t *testing.T) {
-	_, _, exitCode := runApp("validate", "testdata/nonexistent.yaml")
+func TestValidate_RateLimitMonthly(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_rate_month.yaml")
 
-	assertExitCode(t, 1, exitCode)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
 }
 
-// --- CLI behavior ---
-
-func TestValidate_NoArguments(t *testing.T) {
-	_, _, exitCode := runApp("validate")
+func TestValidate_UnlimitedOnBool(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_unlimited_bool.yaml")
 
 	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "'unlimited' is not allowed for entitlement 'sso'")
 }
 
-// --- Apply command tests ---
-
-func TestApply_NoArguments(t *testing.T) {
-	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run")
+func TestValidate_MultipleDefaultPlans(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_multiple_defaults.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "missing required argument")
+	assertContains(t, stdout, "multiple default plans")
+	assertContains(t, stdout, "default plan must be public")
 }
 
-func TestApply_MissingEnvFlag(t *testing.T) {
-	stdout, _, exitCode := runApp("apply", "--dry-run", "testdata/valid/billing_full.yaml")
+func TestValidate_NoPublicPlans(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_no_public_plans.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "env")
+	assertContains(t, stdout, "no public plans")
 }
 
-func TestApply_InvalidEnv(t *testing.T) {
-	stdout, _, exitCode := runApp("apply", "--env", "staging", "--dry-run", "testdata/valid/billing_full.yaml")
+// --- Stdin input ---
 
-	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "invalid environment")
-}
+func TestValidate_Stdin(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func TestApply_UnsupportedProvider(t *testing.T) {
-	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/apply/billing_paddle.yaml")
+	stdout, _, exitCode := runAppWithStdin(string(content), "validate", "-")
 
-	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "not supported yet")
-	assertContains(t, stdout, "raterunner@akorchak.software")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "<stdin> is valid")
 }
 
-func TestApply_NoProviders(t *testing.T) {
-	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/valid/billing_optional_field.yaml")
+func TestValidate_StdinJSON(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/billing_minimal.json")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "no providers specified")
+	stdout, _, exitCode := runAppWithStdin(string(content), "validate", "--format", "json", "-")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
 }
 
-func TestApply_MissingAPIKey(t *testing.T) {
-	// Ensure environment variable is not set
-	os.Unsetenv("STRIPE_SANDBOX_KEY")
+func TestValidate_StdinInvalid(t *testing.T) {
+	content, err := os.ReadFile("testdata/invalid/billing_missing_name.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/valid/billing_full.yaml")
+	stdout, _, exitCode := runAppWithStdin(string(content), "validate", "-")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+	assertContains(t, stdout, "<stdin> has")
 }
 
-func TestApply_WrongKeyPrefix(t *testing.T) {
-	// Set a production key for sandbox environment
-	os.Setenv("STRIPE_SANDBOX_KEY", "sk_live_wrongprefix")
-	defer os.Unsetenv("STRIPE_SANDBOX_KEY")
+func TestValidate_StdinProviderHeader(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/ids_sandbox.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/valid/billing_full.yaml")
+	stdout, _, exitCode := runAppWithStdin(string(content), "validate", "-")
 
-	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "sandbox environment requires a test key")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
 }
 
-// --- Import command tests ---
+func TestApply_StdinDryRun(t *testing.T) {
+	content, err := os.ReadFile("testdata/apply/billing_paddle.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func TestImport_MissingEnvFlag(t *testing.T) {
-	stdout, _, exitCode := runApp("import", "--output", "/tmp/test.yaml")
+	// Config is parsed from stdin and rejected on its provider before any API call
+	stdout, _, exitCode := runAppWithStdin(string(content), "apply", "--env", "sandbox", "--dry-run", "-")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "env")
+	assertContains(t, stdout, "not supported yet")
 }
 
-func TestImport_MissingOutputFlag(t *testing.T) {
-	stdout, _, exitCode := runApp("import", "--env", "sandbox")
+func TestApply_StdinRequiresDryRun(t *testing.T) {
+	stdout, _, exitCode := runAppWithStdin("version: 1", "apply", "--env", "sandbox", "-")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "output")
+	assertContains(t, stdout, "requires --dry-run")
 }
 
-func TestImport_InvalidEnv(t *testing.T) {
-	stdout, _, exitCode := runApp("import", "--env", "staging", "--output", "/tmp/test.yaml")
+// --- Schema type selection ---
+
+func TestValidate_HeaderOverridesFilename(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/stripe_pricing_notes.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_HeaderSelectsProvider(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/ids_sandbox.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_TypeFlag(t *testing.T) {
+	// Forcing the billing schema on a provider file fails
+	stdout, _, exitCode := runApp("validate", "--type", "billing", "testdata/valid/provider_stripe.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "invalid environment")
+	assertContains(t, stdout, "validation error")
 }
 
-func TestImport_MissingAPIKey(t *testing.T) {
-	os.Unsetenv("STRIPE_SANDBOX_KEY")
+func TestValidate_TypeFlagUnknown(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "--type", "pricing", "testdata/valid/billing_minimal.yaml")
 
-	stdout, _, exitCode := runApp("import", "--env", "sandbox", "--output", "/tmp/test.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown schema type")
+}
+
+func TestValidate_SchemaVersionPinned(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_schema_v1.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestValidate_SchemaVersionRejectsNewerFields(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_schema_v1_new_field.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+	assertContains(t, stdout, "/plans/0")
+	assertContains(t, stdout, "environments")
 }
 
-// --- Truncate command tests ---
+func TestValidate_SchemaVersionNewerThanBinary(t *testing.T) {
+	stdout, stderr, exitCode := runApp("validate", "testdata/valid/billing_schema_future.yaml")
 
-func TestTruncate_WithoutConfirm(t *testing.T) {
-	// Without --confirm, prompts user and aborts if no input (empty stdin in tests)
-	stdout, _, exitCode := runApp("truncate")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+	assertContains(t, stderr, "targets billing schema version 99")
+}
 
-	assertExitCode(t, 0, exitCode) // Graceful abort = exit 0
-	assertContains(t, stdout, "WARNING")
-	assertContains(t, stdout, "Are you sure?")
-	assertContains(t, stdout, "Aborted")
+func TestSchemaPrint_Version(t *testing.T) {
+	stdout, _, exitCode := runApp("schema", "print", "--version", "1")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "https://raterunner.io/schemas/billing/v1")
+
+	_, _, exitCode = runApp("schema", "print", "--version", "99")
+	assertExitCode(t, 1, exitCode)
 }
 
-func TestTruncate_MissingAPIKey(t *testing.T) {
-	os.Unsetenv("STRIPE_SANDBOX_KEY")
+func TestSchemaVSCodeSettings(t *testing.T) {
+	stdout, _, exitCode := runApp("schema", "vscode-settings")
 
-	stdout, _, exitCode := runApp("truncate", "--confirm")
+	assertExitCode(t, 0, exitCode)
+	var settings map[string]map[string][]string
+	if err := json.Unmarshal([]byte(stdout), &settings); err != nil {
+		t.Fatalf("expected JSON output, got %v: %s", err, stdout)
+	}
+	schemas := settings["yaml.schemas"]
+	if len(schemas["https://raterunner.io/schemas/billing"]) == 0 || len(schemas["https://raterunner.io/schemas/billing/provider"]) == 0 {
+		t.Errorf("expected billing and provider schema URLs, got %v", schemas)
+	}
+}
+
+func TestSchemaVSCodeSettings_WriteSchemas(t *testing.T) {
+	dir := t.TempDir()
+	stdout, _, exitCode := runApp("schema", "vscode-settings", "--write-schemas", dir)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "file://"+filepath.ToSlash(filepath.Join(dir, "billing.schema.json")))
+	if _, err := os.Stat(filepath.Join(dir, "provider.schema.json")); err != nil {
+		t.Errorf("expected the provider schema to be written: %v", err)
+	}
+}
+
+func TestApply_RefusesProviderHeader(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/valid/ids_sandbox.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+	assertContains(t, stdout, "not a billing config")
 }
 
-func TestTruncate_WrongKeyPrefix(t *testing.T) {
-	os.Setenv("STRIPE_SANDBOX_KEY", "sk_live_wrongprefix")
-	defer os.Unsetenv("STRIPE_SANDBOX_KEY")
+func TestApply_RefusesInvalidConfig(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "testdata/invalid/billing_negative_amount.yaml")
 
-	stdout, _, exitCode := runApp("truncate", "--confirm")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "/plans/0/prices/monthly/amount")
+	assertContains(t, stdout, "refusing to apply")
+	assertContains(t, stdout, "--skip-validation")
+}
+
+func TestApply_SkipValidation(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--skip-validation", "testdata/invalid/billing_negative_amount.yaml")
 
+	// Loaded without validating, so the next check fails instead
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "sandbox environment requires a test key")
+	assertContains(t, stdout, "no providers specified")
 }
 
-// --- Config command tests ---
+// --- Schema directory ---
 
-func TestConfig_Path(t *testing.T) {
-	stdout, _, exitCode := runApp("config", "path")
+func TestValidate_SchemaDirCrossFileRefs(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "--schema-dir", "testdata/schemas/split", "testdata/valid/billing_minimal.yaml")
 
 	assertExitCode(t, 0, exitCode)
-	assertContains(t, stdout, ".raterunner")
-	assertContains(t, stdout, "config.yaml")
+	assertContains(t, stdout, "is valid")
 }
 
-func TestConfig_List(t *testing.T) {
-	stdout, _, exitCode := runApp("config", "list")
+func TestValidate_SchemaDirCrossFileRefsInvalid(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "--schema-dir", "testdata/schemas/split", "testdata/invalid/billing_negative_amount.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "/plans/0/prices/monthly/amount")
+}
+
+// --- Billing feature validation ---
+
+func TestValidate_AutomaticTax(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_automatic_tax.yaml")
 
 	assertExitCode(t, 0, exitCode)
-	assertContains(t, stdout, "quiet")
+	assertContains(t, stdout, "valid")
 }
 
-func TestConfig_GetUnknownKey(t *testing.T) {
-	stdout, _, exitCode := runApp("config", "get", "unknown_key")
+func TestValidate_InvalidEnvironment(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_environment.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "unknown config key")
+	assertContains(t, stdout, "/plans/0/environments/0")
 }
 
-func TestConfig_SetUnknownKey(t *testing.T) {
-	stdout, _, exitCode := runApp("config", "set", "unknown_key", "value")
+func TestValidate_InvalidTaxCode(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_tax_code.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "unknown config key")
+	assertContains(t, stdout, "tax_code")
 }
 
-func TestConfig_SetMissingArgs(t *testing.T) {
-	stdout, _, exitCode := runApp("config", "set", "quiet")
+func TestValidate_Trial(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_trial.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "valid")
+}
+
+func TestValidate_TrialUndefinedDowngradePlan(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_trial_bad_downgrade.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "usage")
+	assertContains(t, stdout, "undefined plan 'starter'")
 }
 
-func TestConfig_GetMissingArgs(t *testing.T) {
-	stdout, _, exitCode := runApp("config", "get")
+func TestValidate_TrialDowngradeRequiresTarget(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_trial_missing_downgrade.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "usage")
+	assertContains(t, stdout, "downgrade_to")
 }
 
-// --- Quiet flag tests ---
+func TestValidate_UpgradeCycle(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_upgrade_cycle.yaml")
 
-func TestQuietFlag_Validate(t *testing.T) {
-	// With --quiet flag, success output is suppressed
-	stdout, _, exitCode := runApp("--quiet", "validate", "testdata/valid/billing_minimal.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "/plans/1/upgrades_to/1: plan cannot upgrade to itself")
+	assertContains(t, stdout, "starter -> pro -> business -> starter")
+	assertContains(t, stdout, "[upgrade-path]")
+}
+
+func TestValidate_DowngradeConflict(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_downgrade_conflict.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "plan 'pro' is both an upgrade and a downgrade")
+	assertContains(t, stdout, "downgrades_to has no effect with downgrade_policy: blocked")
+	assertContains(t, stdout, "starter -> pro -> starter")
+	assertContains(t, stdout, "[downgrade-path]")
+}
+
+func TestPlansGraph(t *testing.T) {
+	stdout, _, exitCode := runApp("plans", "graph", "testdata/valid/billing_upgrade_paths.yaml")
 
 	assertExitCode(t, 0, exitCode)
-	if strings.Contains(stdout, "is valid") {
-		t.Error("expected quiet mode to suppress 'is valid' output")
-	}
+	assertContains(t, stdout, "flowchart LR")
+	assertContains(t, stdout, "free --> starter")
+	assertContains(t, stdout, "pro -. trial ends .-> free")
+	assertContains(t, stdout, "pro -.-> starter")
+	assertContains(t, stdout, "class business hidden")
+
+	stdout, _, exitCode = runApp("plans", "graph", "--format", "dot", "testdata/valid/billing_upgrade_paths.yaml")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "digraph plans {")
+	assertContains(t, stdout, "pro -> business;")
+	assertContains(t, stdout, `business [label="Business", style=dashed];`)
+
+	stdout, _, exitCode = runApp("plans", "graph", "--format", "svg", "testdata/valid/billing_upgrade_paths.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid format: svg")
 }
 
-func TestQuietFlag_ValidateErrors(t *testing.T) {
-	// With --quiet flag, errors are still shown
-	stdout, _, exitCode := runApp("--quiet", "validate", "testdata/invalid/billing_missing_name.yaml")
+func TestValidate_SyncFalse(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_unsynced_free.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "valid")
+}
+
+func TestValidate_CustomPricing(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_custom_pricing.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "valid")
+}
+
+func TestValidate_CustomPricingWithPrices(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_custom_pricing_with_prices.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "validation error") // Errors still shown
+	assertContains(t, stdout, "pricing: custom cannot define prices")
 }
 
-// --- Init command tests ---
+func TestValidate_MissingPrices(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_missing_prices.yaml")
 
-func TestInit_CreatesDirectory(t *testing.T) {
-	// Create a temp directory
-	tmpDir, err := os.MkdirTemp("", "raterunner-test-*")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer os.RemoveAll(tmpDir)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "prices")
+}
 
-	stdout, _, exitCode := runApp("init", tmpDir)
+func TestValidate_AddonGrants(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_addon_grants.yaml")
 
 	assertExitCode(t, 0, exitCode)
-	assertContains(t, stdout, "Created")
-	assertContains(t, stdout, "billing.yaml")
+	assertContains(t, stdout, "valid")
+}
 
-	// Verify file was created
-	billingPath := tmpDir + "/raterunner/billing.yaml"
-	if _, err := os.Stat(billingPath); os.IsNotExist(err) {
-		t.Errorf("expected billing.yaml to be created at %s", billingPath)
-	}
+func TestValidate_BadGrant(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_grant.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid grant for 'projects'")
+	assertContains(t, stdout, "invalid grant for 'sso'")
 }
 
-func TestInit_RefusesOverwrite(t *testing.T) {
-	// Create a temp directory with existing billing.yaml
-	tmpDir, err := os.MkdirTemp("", "raterunner-test-*")
+func TestValidate_BadCreditPack(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_credit_pack.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "entitlement 'projects' is not a credit balance")
+	assertContains(t, stdout, "invalid grant for 'ai_credits'")
+}
+
+func TestValidate_BadMeter(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_meter.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "undefined meter 'bandwidth'")
+	assertContains(t, stdout, "one-time prices can't be metered")
+	assertContains(t, stdout, "event name 'api_call' is already used by meter 'api_calls'")
+}
+
+func TestValidate_BadDunning(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_dunning.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "retry_days must increase")
+	assertContains(t, stdout, "cancel_after_failures is 4 but a payment is only attempted 3 time(s)")
+}
+
+func TestValidate_BadInvoice(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_invoice.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "/plans/0/collection_method")
+	assertContains(t, stdout, "duplicate invoice custom field 'vat'")
+}
+
+func TestValidate_BadManualInvoicing(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_manual_invoicing.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "cannot use collection_method: charge_automatically")
+	assertContains(t, stdout, "cannot be the default plan")
+	assertContains(t, stdout, "/settings/trial/require_payment_method")
+}
+
+func TestValidate_PromotionStacking(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_promotion_stacking.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "valid")
+}
+
+func TestValidate_PromotionBadExclude(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_promotion_bad_exclude.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "undefined promotion 'PARTNER20'")
+	assertContains(t, stdout, "promotion cannot exclude itself")
+}
+
+func TestValidate_PromotionWindow(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_promotion_window.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "valid")
+}
+
+func TestValidate_PromotionBadWindow(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_promotion_bad_window.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "/promotions/0/starts_at: starts_at must be before expires")
+}
+
+func TestPromotion_Window(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_promotion_window.yaml")
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("failed to load config: %v", err)
 	}
-	defer os.RemoveAll(tmpDir)
+	blackFriday, launch := cfg.Promotions[0], cfg.Promotions[1]
 
-	// Create existing file
-	raterunnerDir := tmpDir + "/raterunner"
-	if err := os.MkdirAll(raterunnerDir, 0755); err != nil {
-		t.Fatal(err)
+	tests := []struct {
+		promo config.Promotion
+		now   time.Time
+		want  string
+	}{
+		{blackFriday, time.Date(2026, 11, 26, 23, 59, 0, 0, time.UTC), config.PromotionPending},
+		{blackFriday, time.Date(2026, 11, 27, 0, 0, 0, 0, time.UTC), config.PromotionOpen},
+		{blackFriday, time.Date(2026, 11, 30, 23, 59, 0, 0, time.UTC), config.PromotionOpen},
+		{blackFriday, time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC), config.PromotionClosed},
+		{launch, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), config.PromotionOpen},
+		{launch, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), config.PromotionClosed},
 	}
-	if err := os.WriteFile(raterunnerDir+"/billing.yaml", []byte("existing"), 0644); err != nil {
-		t.Fatal(err)
+	for _, tt := range tests {
+		got, err := tt.promo.Window(tt.now)
+		if err != nil {
+			t.Fatalf("%s at %s: %v", tt.promo.Code, tt.now, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s at %s: expected %s, got %s", tt.promo.Code, tt.now, tt.want, got)
+		}
 	}
 
-	stdout, _, exitCode := runApp("init", tmpDir)
+	bad := config.Promotion{Code: "BAD", StartsAt: "2026-12-01", Expires: "2026-11-27"}
+	if _, err := bad.Window(time.Now()); err == nil {
+		t.Error("expected an error when starts_at is after expires")
+	}
+}
+
+// --- File handling errors ---
+
+func TestValidate_MalformedYAML(t *testing.T) {
+	_, _, exitCode := runApp("validate", "testdata/errors/malformed.yaml")
 
 	assertExitCode(t, 1, exitCode)
-	assertContains(t, stdout, "already exists")
 }
 
-func TestInit_ForceOverwrite(t *testing.T) {
-	// Create a temp directory with existing billing.yaml
-	tmpDir, err := os.MkdirTemp("", "raterunner-test-*")
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer os.RemoveAll(tmpDir)
+func TestValidate_NonExistentFile(t *testing.T) {
+	_, _, exitCode := runApp("validate", "testdata/nonexistent.yaml")
 
-	// Create existing file
-	raterunnerDir := tmpDir + "/raterunner"
-	if err := os.MkdirAll(raterunnerDir, 0755); err != nil {
-		t.Fatal(err)
-	}
-	if err := os.WriteFile(raterunnerDir+"/billing.yaml", []byte("existing"), 0644); err != nil {
+	assertExitCode(t, 1, exitCode)
+}
+
+// --- SOPS-encrypted files ---
+
+// fakeSOPS puts a stand-in sops on PATH: --decrypt drops the sops metadata
+// block, --encrypt prints the file with one appended
+func fakeSOPS(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+--decrypt) sed '/^sops:/,$d' "$2" ;;
+--encrypt) cat "$4" && printf 'sops:\n    mac: ENC[fake]\n    version: 3.9.0\n' ;;
+*) exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "sops"), []byte(script), 0755); err != nil {
 		t.Fatal(err)
 	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
 
-	stdout, _, exitCode := runApp("init", "--force", tmpDir)
+func TestValidate_SOPSEncryptedBilling(t *testing.T) {
+	fakeSOPS(t)
+
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_sops.yaml")
 
 	assertExitCode(t, 0, exitCode)
-	assertContains(t, stdout, "Created")
+	assertContains(t, stdout, "is valid")
+}
 
-	// Verify file was overwritten (content should be different)
-	content, err := os.ReadFile(raterunnerDir + "/billing.yaml")
+func TestValidate_SOPSEncryptedStdin(t *testing.T) {
+	fakeSOPS(t)
+	content, err := os.ReadFile("testdata/valid/billing_sops.yaml")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if string(content) == "existing" {
-		t.Error("expected file to be overwritten")
-	}
-}
 
-func TestValidate_ProviderSandboxFile(t *testing.T) {
-	stdout, _, exitCode := runApp("validate", "testdata/valid/stripe_sandbox.yaml")
+	stdout, _, exitCode := runAppWithStdin(string(content), "validate", "-")
 
 	assertExitCode(t, 0, exitCode)
 	assertContains(t, stdout, "is valid")
 }
 
-// --- Test helpers ---
+func TestValidate_SOPSBinaryMissing(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
 
-func assertExitCode(t *testing.T, expected, actual int) {
-	t.Helper()
-	if actual != expected {
-		t.Errorf("expected exit code %d, got %d", expected, actual)
-	}
-}
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_sops.yaml")
 
-func assertContains(t *testing.T, s, substr string) {
-	t.Helper()
-	if !strings.Contains(s, substr) {
-		t.Errorf("expected output to contain %q, got: %s", substr, s)
-	}
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "sops binary was not found")
+}
+
+func TestProviderFile_SOPSRoundTrip(t *testing.T) {
+	fakeSOPS(t)
+	content, err := os.ReadFile("testdata/valid/stripe_sops.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "stripe_sandbox.yaml")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.LoadProviderFile(path)
+	if err != nil {
+		t.Fatalf("failed to load encrypted provider file: %v", err)
+	}
+	if cfg.Plans["pro"].ProductID != "prod_abc123" {
+		t.Fatalf("unexpected decrypted plans: %+v", cfg.Plans)
+	}
+
+	if err := config.SaveProviderFile(path, cfg); err != nil {
+		t.Fatalf("failed to save provider file: %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.IsSOPSEncrypted(saved) {
+		t.Errorf("expected the saved provider file to be re-encrypted, got:\n%s", saved)
+	}
+}
+
+func TestProviderFile_SOPSEncryptFailureKeepsFile(t *testing.T) {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+case "$1" in
+--decrypt) sed '/^sops:/,$d' "$2" ;;
+*) echo "no matching creation rules found" >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "sops"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	content, err := os.ReadFile("testdata/valid/stripe_sops.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := t.TempDir()
+	path := filepath.Join(project, "stripe_sandbox.yaml")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadProviderFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = config.SaveProviderFile(path, cfg)
+	if err == nil || !strings.Contains(err.Error(), "no matching creation rules found") {
+		t.Fatalf("expected the sops error, got %v", err)
+	}
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(saved) != string(content) {
+		t.Errorf("expected the encrypted file to be left as it was, got:\n%s", saved)
+	}
+	// Neither plaintext nor a half-written file is left next to it
+	entries, err := os.ReadDir(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the provider file in the project, got %v", entries)
+	}
+}
+
+func TestProviderConfig_RecordHistory(t *testing.T) {
+	previous := &config.ProviderConfig{Plans: map[string]config.PlanIDs{
+		"pro": {ProductID: "prod_pro", Prices: map[string]string{"monthly": "price_old", "yearly": "price_y"}},
+		"free": {ProductID: "prod_free", Prices: map[string]string{"monthly": "price_free"},
+			History: []config.ReplacedIDs{{ReplacedAt: "2026-01-01T00:00:00Z", ProductID: "prod_older"}}},
+	}}
+	current := &config.ProviderConfig{Plans: map[string]config.PlanIDs{
+		"pro":  {ProductID: "prod_pro", Prices: map[string]string{"monthly": "price_new", "yearly": "price_y"}},
+		"free": {ProductID: "prod_free", Prices: map[string]string{"monthly": "price_free"}},
+	}}
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	current.RecordHistory(previous, at, 5)
+
+	pro := current.Plans["pro"].History
+	if len(pro) != 1 || pro[0].ReplacedAt != "2026-03-01T12:00:00Z" || pro[0].ProductID != "" || len(pro[0].Prices) != 1 || pro[0].Prices["monthly"] != "price_old" {
+		t.Errorf("expected only the replaced monthly price in pro's history, got %+v", pro)
+	}
+	if free := current.Plans["free"].History; len(free) != 1 || free[0].ProductID != "prod_older" {
+		t.Errorf("expected free to keep its history unchanged, got %+v", free)
+	}
+
+	// The oldest entries fall off past the limit
+	next := &config.ProviderConfig{Plans: map[string]config.PlanIDs{
+		"pro": {ProductID: "prod_pro2", Prices: map[string]string{"monthly": "price_new", "yearly": "price_y"}},
+	}}
+	next.RecordHistory(current, at.Add(time.Hour), 1)
+	if h := next.Plans["pro"].History; len(h) != 1 || h[0].ProductID != "prod_pro" {
+		t.Errorf("expected only the newest entry to be kept, got %+v", h)
+	}
+}
+
+func TestProviderFile_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stripe_sandbox.yaml")
+	cfg := &config.ProviderConfig{Provider: "stripe", Environment: "sandbox", Plans: map[string]config.PlanIDs{
+		"pro": {ProductID: "prod_pro", History: []config.ReplacedIDs{
+			{ReplacedAt: "2026-03-01T00:00:00Z", Prices: map[string]string{"monthly": "price_b"}},
+			{ReplacedAt: "2026-02-01T00:00:00Z", Prices: map[string]string{"monthly": "price_a"}},
+		}},
+	}}
+	if err := config.SaveProviderFile(path, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("provider-file", "compact", "--keep", "1", path)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "dropped 1 replaced ID set(s)")
+	compacted, err := config.LoadProviderFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := compacted.Plans["pro"].History; len(h) != 1 || h[0].Prices["monthly"] != "price_b" {
+		t.Errorf("expected the newest entry to be kept, got %+v", h)
+	}
+
+	stdout, _, exitCode = runApp("provider-file", "compact", "--keep", "1", path)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "nothing to compact")
+}
+
+func TestProviderFile_Migrate(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/raterunner/stripe_sandbox.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "stripe_sandbox.yaml")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("provider-file", "migrate", path)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "from version 1 to 2")
+	migrated, err := config.LoadProviderFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.Version != config.ProviderFileVersion || migrated.Plans["pro"].ProductID != "prod_Ts18YFiu3tDfDc" {
+		t.Errorf("unexpected migrated file: %+v", migrated)
+	}
+
+	stdout, _, exitCode = runApp("provider-file", "migrate", path)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "already at version 2")
+}
+
+func TestLoadProviderFile_NewerVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stripe_sandbox.yaml")
+	if err := os.WriteFile(path, []byte("version: 99\nprovider: stripe\nenvironment: sandbox\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := config.LoadProviderFile(path)
+	if err == nil || !strings.Contains(err.Error(), "provider file version 99 is newer") {
+		t.Errorf("expected a version error, got %v", err)
+	}
+}
+
+func TestIsSOPSEncrypted(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"version: 1\nsops:\n  mac: ENC[x]\n", true},
+		{`{"version": 1, "sops": {"mac": "ENC[x]"}}`, true},
+		{"version: 1\n# encrypt with sops before committing\n", false},
+		{"version: 1\nsops: enabled\n", false},
+	}
+	for _, tt := range tests {
+		if got := config.IsSOPSEncrypted([]byte(tt.content)); got != tt.want {
+			t.Errorf("IsSOPSEncrypted(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+// --- Manifest tests ---
+
+func TestValidate_Manifest(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "--manifest", "testdata/manifest/raterunner.manifest.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, filepath.Join("testdata", "valid", "billing_full.yaml")+" is valid")
+	assertContains(t, stdout, filepath.Join("testdata", "valid", "provider_stripe.yaml")+" is valid")
+}
+
+func TestValidate_ManifestReportsEveryFile(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "--manifest", "testdata/manifest/invalid.manifest.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "billing_missing_name.yaml has 1 validation error(s)")
+	// Files after the invalid one are still validated
+	assertContains(t, stdout, "provider_stripe.yaml is valid")
+}
+
+func TestValidate_ManifestDiscovered(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir("testdata/manifest"); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	stdout, stderr, exitCode := runApp("validate", "--manifest")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stderr, "Using raterunner.manifest.yaml")
+	assertContains(t, stdout, filepath.Join("..", "valid", "billing_minimal.yaml")+" is valid")
+}
+
+func TestApply_ManifestInOrder(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("apply", "--manifest", "--env", "sandbox", "testdata/manifest/raterunner.manifest.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	// Two billing files target sandbox; the first fails and stops the run
+	assertContains(t, stdout, "==> [1/2] "+filepath.Join("testdata", "valid", "billing_full.yaml"))
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+	assertContains(t, stdout, "1 later file(s) not applied")
+}
+
+func TestManifest_Load(t *testing.T) {
+	manifest, err := config.LoadManifest("testdata/manifest/raterunner.manifest.yaml")
+	if err != nil {
+		t.Fatalf("failed to load manifest: %v", err)
+	}
+	if len(manifest.Files) != 4 {
+		t.Fatalf("expected 4 files, got %d", len(manifest.Files))
+	}
+	if got := manifest.Files[0].Path; got != filepath.Join("testdata", "valid", "billing_full.yaml") {
+		t.Errorf("expected paths relative to the manifest, got %s", got)
+	}
+	if manifest.Files[2].TargetsEnv("sandbox") || !manifest.Files[2].TargetsEnv("production") {
+		t.Error("expected billing_optional_field.yaml to target production only")
+	}
+	if !manifest.Files[1].TargetsEnv("sandbox") {
+		t.Error("expected a file without envs to target every environment")
+	}
+
+	path := filepath.Join(t.TempDir(), "raterunner.manifest.yaml")
+	os.WriteFile(path, []byte("files:\n  - path: billing.yaml\n    envs: [staging]\n"), 0644)
+	if _, err := config.LoadManifest(path); err == nil || !strings.Contains(err.Error(), "invalid env 'staging'") {
+		t.Errorf("expected an invalid env error, got %v", err)
+	}
+}
+
+// --- Multi-product tests ---
+
+// inProducts runs the test from the multi-product fixture repository
+func inProducts(t *testing.T) {
+	t.Helper()
+	wd, _ := os.Getwd()
+	if err := os.Chdir("testdata/products"); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestProduct_Selects(t *testing.T) {
+	inProducts(t)
+
+	stdout, stderr, exitCode := runApp("--product", "app-b", "validate")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stderr, "Using "+filepath.Join("products", "app-b", "billing.yaml"))
+	assertContains(t, stdout, "valid")
+}
+
+func TestProduct_Unknown(t *testing.T) {
+	inProducts(t)
+
+	stdout, _, exitCode := runApp("--product", "app-c", "validate")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown product 'app-c' (available: app-a, app-b)")
+}
+
+func TestProduct_RequiredWithSeveralProducts(t *testing.T) {
+	inProducts(t)
+
+	stdout, _, exitCode := runApp("validate")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "choose a product with --product: app-a, app-b")
+}
+
+func TestProduct_NotWithFileArgument(t *testing.T) {
+	stdout, _, exitCode := runApp("--product", "app-a", "validate", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "either a billing file or --product")
+}
+
+func TestProduct_ProviderFilesPerProduct(t *testing.T) {
+	products, err := config.FindProducts("testdata/products/products/app-a")
+	if err != nil {
+		t.Fatalf("failed to find products: %v", err)
+	}
+	if len(products) != 2 || products[0].Name != "app-a" || products[1].Name != "app-b" {
+		t.Fatalf("expected app-a and app-b, got %+v", products)
+	}
+
+	got := config.ProviderFilePath(products[0].Path, "stripe", "sandbox")
+	want := filepath.Join(filepath.Dir(products[0].Path), "raterunner", "stripe_sandbox.yaml")
+	if got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestStatus_AllProducts(t *testing.T) {
+	inProducts(t)
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("status", "--all-products")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "app-a: environment variable STRIPE_SANDBOX_KEY is not set")
+}
+
+// --- CLI behavior ---
+
+func TestValidate_NoArguments(t *testing.T) {
+	_, _, exitCode := runApp("validate")
+
+	assertExitCode(t, 1, exitCode)
+}
+
+func TestValidate_DiscoversBillingFile(t *testing.T) {
+	root := t.TempDir()
+	if err := config.CreateInitFiles(root, ""); err != nil {
+		t.Fatalf("failed to create init files: %v", err)
+	}
+	nested := filepath.Join(root, "services", "api")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to create nested dir: %v", err)
+	}
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(nested); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	stdout, stderr, exitCode := runApp("validate")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stderr, "Using "+filepath.Join("..", "..", "raterunner", "billing.yaml"))
+	assertContains(t, stdout, "valid")
+}
+
+// --- Apply command tests ---
+
+func TestApply_NoArguments(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "missing required argument")
+}
+
+func TestApply_MissingEnvFlag(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "env")
+}
+
+func TestApply_InvalidEnv(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "staging", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid environment")
+}
+
+func TestApply_UnsupportedProvider(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/apply/billing_paddle.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "not supported yet")
+	assertContains(t, stdout, "raterunner@akorchak.software")
+}
+
+func TestApply_NoProviders(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/valid/billing_optional_field.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "no providers specified")
+}
+
+func TestApply_MissingAPIKey(t *testing.T) {
+	// Ensure environment variable is not set
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestApply_RefreshMissingAPIKey(t *testing.T) {
+	t.Setenv("STRIPE_SANDBOX_KEY", "")
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "--refresh", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestApply_OnlyRequiresDryRun(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--only", "pro", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "--only requires --dry-run")
+}
+
+func TestApply_OnlyUnknownPlan(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "--only", "enterprise", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown plan in --only: enterprise")
+}
+
+func TestSameAPIRelease(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"2025-04-30.basil", "2025-08-27.basil", true},
+		{"2025-03-31.basil", "2024-12-18.acacia", false},
+		{"2024-06-20", "2024-06-20", true},
+		{"2024-06-20", "2025-08-27.basil", false},
+		{"2025-09-01.preview", "2025-10-01.preview", false},
+	}
+	for _, tt := range tests {
+		if got := stripe.SameAPIRelease(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameAPIRelease(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+	if age := stripe.APIVersionAge("2024-06-20"); age != "older" {
+		t.Errorf("expected 2024-06-20 to be older than %s, got %s", stripe.APIVersion, age)
+	}
+}
+
+func TestCompareOnly(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := []stripe.Product{{
+		ID:             "prod_pro",
+		Name:           "Pro",
+		PlanCode:       "pro",
+		CatalogVersion: cfg.CatalogHash() + "@a1b2c3d",
+		Active:         true,
+	}}
+
+	result := diff.CompareOnly(cfg, products, "sandbox", []string{"pro"})
+
+	if len(result.Plans) != 1 || result.Plans[0].PlanID != "pro" {
+		t.Fatalf("expected only plan 'pro', got %+v", result.Plans)
+	}
+	if len(result.Addons) != 0 {
+		t.Errorf("expected addons to be left out, got %+v", result.Addons)
+	}
+	if result.CatalogVersion == nil || result.CatalogVersion.Status != diff.StatusOK {
+		t.Errorf("expected the catalog version to match the whole config, got %+v", result.CatalogVersion)
+	}
+}
+
+func TestApply_WrongKeyPrefix(t *testing.T) {
+	// Set a production key for sandbox environment
+	os.Setenv("STRIPE_SANDBOX_KEY", "sk_live_wrongprefix")
+	defer os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "sandbox environment requires a test key")
+}
+
+func TestApply_MigrateMetadataRequiresPrefix(t *testing.T) {
+	t.Setenv("STRIPE_SANDBOX_KEY", "sk_test_dummy")
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "--migrate-metadata", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "--migrate-metadata requires settings.metadata_prefix")
+}
+
+func TestValidate_MetadataPrefix(t *testing.T) {
+	_, _, exitCode := runApp("validate", "testdata/valid/billing_metadata_prefix.yaml")
+	assertExitCode(t, 0, exitCode)
+
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_bad_metadata_prefix.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "metadata_prefix")
+}
+
+func TestValidate_Provisioning(t *testing.T) {
+	_, _, exitCode := runApp("validate", "testdata/valid/billing_provisioning.yaml")
+	assertExitCode(t, 0, exitCode)
+
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_provisioning_key_too_long.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "rr.provisioning_dedicated_database_cluster_size")
+	assertContains(t, stdout, "longer than Stripe's 40 characters")
+}
+
+func TestProvisioningMetadata(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_provisioning.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	meta := cfg.ProvisioningMetadata(cfg.Plans[0])
+	want := map[string]string{
+		"rr.provisioning_service_tier": "standard",
+		"rr.provisioning_region":       "eu-west-1",
+		"rr.provisioning_skus":         "PRO-BASE,PRO-SUPPORT",
+	}
+	if len(meta) != len(want) {
+		t.Fatalf("got %d keys, want %d: %v", len(meta), len(want), meta)
+	}
+	for k, v := range want {
+		if meta[k] != v {
+			t.Errorf("%s = %q, want %q", k, meta[k], v)
+		}
+	}
+}
+
+func TestMetadataKeys_Mapping(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_metadata_mapping.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Settings = &config.Settings{MetadataPrefix: "rr."}
+
+	keys, err := cfg.MetadataKeys()
+	if err != nil {
+		t.Fatalf("MetadataKeys: %v", err)
+	}
+	want := map[string]string{
+		"plan_code":     "sku", // mapped names are used as-is
+		"plan_type":     "tier",
+		"headline":      "", // omitted
+		"billing_model": "rr.billing_model",
+	}
+	for field, key := range want {
+		if keys[field] != key {
+			t.Errorf("key for %s = %q, want %q", field, keys[field], key)
+		}
+	}
+}
+
+func TestValidate_MetadataMappingConflict(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_metadata_mapping_conflict.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "are both stored as 'tier'")
+}
+
+func TestValidate_MetadataMappingCannotOmitPlanCode(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_metadata_mapping_omit_plan_code.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "/metadata_mapping/plan_code")
+}
+
+func TestSortPlansByReferences(t *testing.T) {
+	plans := []config.Plan{
+		{ID: "free", UpgradesTo: []string{"pro", "team"}},
+		{ID: "pro", UpgradesTo: []string{"team", "enterprise"}}, // enterprise isn't synced
+		{ID: "team"},
+		{ID: "solo"},
+	}
+
+	sorted, err := config.SortPlansByReferences(plans)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, plan := range sorted {
+		got = append(got, plan.ID)
+	}
+	if strings.Join(got, ",") != "team,pro,free,solo" {
+		t.Errorf("expected referenced plans first, got %v", got)
+	}
+
+	if cycle := config.DowngradeCycle(plans); cycle != nil {
+		t.Errorf("expected no downgrade cycle, got %v", cycle)
+	}
+
+	plans[2].UpgradesTo = []string{"free"}
+	_, err = config.SortPlansByReferences(plans)
+	if err == nil || !strings.Contains(err.Error(), "free -> pro -> team -> free") {
+		t.Errorf("expected the cycle to be reported, got %v", err)
+	}
+	if cycle := config.UpgradeCycle(plans); strings.Join(cycle, " -> ") != "free -> pro -> team -> free" {
+		t.Errorf("expected the upgrade cycle, got %v", cycle)
+	}
+}
+
+func TestSyncWarning_JSON(t *testing.T) {
+	w := stripe.Warning{
+		Code:     stripe.WarnPriceDiffers,
+		PlanID:   "pro",
+		Interval: "monthly",
+		Local:    int64(2900),
+		Remote:   int64(0),
+		Message:  "plan 'pro' monthly: price differs (local=2900, stripe=0), archiving old and creating new",
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"code":"price_differs","plan_id":"pro","interval":"monthly","local":2900,"remote":0,"message":"plan 'pro' monthly: price differs (local=2900, stripe=0), archiving old and creating new"}`
+	if string(data) != want {
+		t.Errorf("unexpected JSON:\n got %s\nwant %s", data, want)
+	}
+	if w.String() != w.Message {
+		t.Errorf("expected String to return the message, got %q", w.String())
+	}
+}
+
+func TestSyncErrors_Report(t *testing.T) {
+	cause := errors.New("invalid currency")
+	var err error = stripe.SyncErrors{
+		{Kind: "plan", ID: "pro", Err: cause},
+		{Kind: "promotion", ID: "LAUNCH", Err: errors.New("rate limited")},
+	}
+	want := "2 object(s) failed to sync:\n  failed to sync plan 'pro': invalid currency\n  failed to sync promotion 'LAUNCH': rate limited"
+	if err.Error() != want {
+		t.Errorf("unexpected report:\n got %q\nwant %q", err.Error(), want)
+	}
+
+	var syncErrs stripe.SyncErrors
+	if !errors.As(fmt.Errorf("sync failed: %w", err), &syncErrs) || len(syncErrs) != 2 {
+		t.Errorf("expected the per-object errors to be recoverable, got %v", syncErrs)
+	}
+	if !errors.Is(syncErrs[0], cause) {
+		t.Error("expected a SyncError to unwrap to its cause")
+	}
+}
+
+func TestSyncError_DescribesStripeErrors(t *testing.T) {
+	apiErr := &stripeapi.Error{
+		Code:  stripeapi.ErrorCodeParameterInvalidInteger,
+		Msg:   "Invalid integer: 29.5",
+		Param: "unit_amount",
+	}
+	err := stripe.SyncError{
+		Kind:     "plan",
+		ID:       "pro",
+		Interval: "monthly",
+		Pointer:  "/plans/1/prices/monthly/amount",
+		Hint:     "amounts are whole numbers in cents, e.g. 2900 for $29.00",
+		Err:      fmt.Errorf("failed to create flat price: %w", apiErr),
+	}
+	want := "failed to sync plan 'pro' monthly at /plans/1/prices/monthly/amount: failed to create flat price: parameter_invalid_integer: Invalid integer: 29.5 (param unit_amount) (hint: amounts are whole numbers in cents, e.g. 2900 for $29.00)"
+	if err.Error() != want {
+		t.Errorf("unexpected error:\n got %q\nwant %q", err.Error(), want)
+	}
+}
+
+// lspFrame encodes an LSP message with its Content-Length header
+func lspFrame(t *testing.T, msg map[string]any) string {
+	t.Helper()
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func TestLSP_PublishesDiagnostics(t *testing.T) {
+	text := "version: 1\nproviders: [stripe]\nplans:\n  - id: pro\n    name: Pro\n    prices:\n      monthly: { amount: -5 }\n"
+	open := func(uri string) map[string]any {
+		return map[string]any{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]any{
+			"textDocument": map[string]any{"uri": uri, "languageId": "yaml", "version": 1, "text": text},
+		}}
+	}
+	stdin := lspFrame(t, map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": map[string]any{}}) +
+		lspFrame(t, open("file:///work/raterunner/billing.yaml")) +
+		lspFrame(t, open("file:///work/.github/workflows/ci.yaml")) +
+		lspFrame(t, map[string]any{"jsonrpc": "2.0", "id": 2, "method": "shutdown"}) +
+		lspFrame(t, map[string]any{"jsonrpc": "2.0", "method": "exit"})
+
+	stdout, _, exitCode := runAppWithStdin(stdin, "lsp")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"textDocumentSync":{"change":1,"openClose":true}`)
+	assertContains(t, stdout, `"diagnostics":[{"range":{"start":{"line":6,"character":6}`)
+	assertContains(t, stdout, `got '-5/1'`)
+	// Files that aren't raterunner configs get an empty list
+	assertContains(t, stdout, `{"diagnostics":[],"uri":"file:///work/.github/workflows/ci.yaml"}`)
+	assertContains(t, stdout, `{"id":2,"jsonrpc":"2.0","result":null}`)
+}
+
+// --- gRPC server tests ---
+
+// startRPCServer serves the gRPC service in memory and returns a client for it
+func startRPCServer(t *testing.T) raterunnerv1.RaterunnerClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	raterunnerv1.RegisterRaterunnerServer(server, &rpcServer{validator: validator.New()})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return raterunnerv1.NewRaterunnerClient(conn)
+}
+
+func TestServe_Validate(t *testing.T) {
+	client := startRPCServer(t)
+	ctx := context.Background()
+
+	resp, err := client.Validate(ctx, &raterunnerv1.ValidateRequest{Path: "testdata/valid/billing_full.yaml"})
+	if err != nil || !resp.Valid {
+		t.Fatalf("expected billing_full to be valid, got %v %v", resp, err)
+	}
+
+	resp, err = client.Validate(ctx, &raterunnerv1.ValidateRequest{Path: "testdata/invalid/provider_bad_ids.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Valid || len(resp.Errors) != 2 || resp.Errors[0].Path != "/plans/pro/product_id" {
+		t.Errorf("expected the two bad provider IDs, got %v", resp)
+	}
+
+	_, err = client.Validate(ctx, &raterunnerv1.ValidateRequest{Path: "../billing.yaml"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected paths outside the server directory to be rejected, got %v", err)
+	}
+}
+
+func TestServe_RefusesPublicAddressWithoutToken(t *testing.T) {
+	t.Setenv(serveTokenEnv, "")
+
+	stdout, _, exitCode := runApp("serve", "--grpc", ":0")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "without authentication: set RATERUNNER_GRPC_TOKEN, or listen on loopback")
+}
+
+func TestServe_ProductionApplyNeedsToken(t *testing.T) {
+	client := startRPCServer(t)
+
+	stream, err := client.Apply(context.Background(), &raterunnerv1.ApplyRequest{Path: "testdata/valid/billing_full.yaml", Environment: "production"})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.PermissionDenied || !strings.Contains(err.Error(), serveTokenEnv) {
+		t.Errorf("expected production applies to be refused without a token, got %v", err)
+	}
+}
+
+func TestServe_DiffMissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+	client := startRPCServer(t)
+
+	_, err := client.Diff(context.Background(), &raterunnerv1.DiffRequest{Path: "testdata/valid/billing_full.yaml", Environment: "sandbox"})
+	if status.Code(err) != codes.FailedPrecondition || !strings.Contains(err.Error(), "STRIPE_SANDBOX_KEY") {
+		t.Errorf("expected a missing key error, got %v", err)
+	}
+
+	_, err = client.Diff(context.Background(), &raterunnerv1.DiffRequest{Path: "testdata/valid/billing_full.yaml", Environment: "staging"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected an invalid environment error, got %v", err)
+	}
+}
+
+func TestServe_ApplyMissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+	client := startRPCServer(t)
+
+	stream, err := client.Apply(context.Background(), &raterunnerv1.ApplyRequest{Path: "testdata/valid/billing_full.yaml", Environment: "sandbox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+	if status.Code(err) != codes.Aborted || !strings.Contains(err.Error(), "STRIPE_SANDBOX_KEY") {
+		t.Errorf("expected apply to fail on the missing key, got %v", err)
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer s3cret"))
+	if err := checkToken(ctx, "s3cret"); err != nil {
+		t.Errorf("expected the token to be accepted, got %v", err)
+	}
+	if err := checkToken(ctx, "other"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a wrong token to be rejected, got %v", err)
+	}
+	if err := checkToken(context.Background(), "s3cret"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("expected a missing token to be rejected, got %v", err)
+	}
+}
+
+// --- Operator tests ---
+
+func TestOperator_Reconcile(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+	billing, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recent := time.Now().UTC().Format(time.RFC3339)
+	list := map[string]any{
+		"metadata": map[string]any{"resourceVersion": "42"},
+		"items": []operator.BillingConfig{
+			{Metadata: operator.ObjectMeta{Name: "pricing", Namespace: "billing", Generation: 2},
+				Spec: operator.BillingConfigSpec{Environment: "sandbox", Config: billing}},
+			{Metadata: operator.ObjectMeta{Name: "typo", Namespace: "billing", Generation: 1},
+				Spec: operator.BillingConfigSpec{Environment: "staging", Config: billing}},
+			{Metadata: operator.ObjectMeta{Name: "live", Namespace: "billing", Generation: 1},
+				Spec: operator.BillingConfigSpec{Environment: "production", Config: billing}},
+			{Metadata: operator.ObjectMeta{Name: "unchanged", Namespace: "billing", Generation: 3},
+				Spec:   operator.BillingConfigSpec{Environment: "sandbox", Config: billing},
+				Status: operator.BillingConfigStatus{ObservedGeneration: 3, LastAttemptAt: recent}},
+		},
+	}
+
+	patches := make(map[string]operator.BillingConfigStatus)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/raterunner.io/v1alpha1/namespaces/billing/billingconfigs":
+			json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodPatch && r.Header.Get("Content-Type") == "application/merge-patch+json":
+			var patch struct {
+				Status operator.BillingConfigStatus `json:"status"`
+			}
+			json.NewDecoder(r.Body).Decode(&patch)
+			patches[r.URL.Path] = patch.Status
+		default:
+			http.Error(w, "unexpected request", http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+
+	var synced []string
+	r := &operator.Reconciler{
+		Kube:      &operator.Kube{BaseURL: api.URL, Namespace: "billing"},
+		Validator: validator.New(),
+		Sync: func(_ context.Context, cfg *config.BillingConfig, env string) (string, error) {
+			synced = append(synced, env)
+			return cfg.CatalogHash(), nil
+		},
+	}
+
+	resourceVersion, err := r.ReconcileAll(context.Background())
+	if err != nil || resourceVersion != "42" {
+		t.Fatalf("expected resource version 42, got %q %v", resourceVersion, err)
+	}
+	if len(synced) != 1 || synced[0] != "sandbox" {
+		t.Errorf("expected only the changed valid resource to be synced, got %v", synced)
+	}
+
+	ok := patches["/apis/raterunner.io/v1alpha1/namespaces/billing/billingconfigs/pricing/status"]
+	ready := ok.Condition(operator.ConditionReady)
+	if ready == nil || ready.Status != "True" || ready.Reason != operator.ReasonSynced || ok.ObservedGeneration != 2 || ok.CatalogVersion == "" {
+		t.Errorf("expected pricing to be Ready, got %+v", ok)
+	}
+	bad := patches["/apis/raterunner.io/v1alpha1/namespaces/billing/billingconfigs/typo/status"]
+	if ready := bad.Condition(operator.ConditionReady); ready == nil || ready.Status != "False" || ready.Reason != operator.ReasonInvalidConfig || !strings.Contains(ready.Message, "invalid environment") {
+		t.Errorf("expected typo to be InvalidConfig, got %+v", bad)
+	}
+	live := patches["/apis/raterunner.io/v1alpha1/namespaces/billing/billingconfigs/live/status"]
+	if ready := live.Condition(operator.ConditionReady); ready == nil || ready.Reason != operator.ReasonInvalidConfig || !strings.Contains(ready.Message, "--allow-production") {
+		t.Errorf("expected production to be refused without --allow-production, got %+v", live)
+	}
+	if _, ok := patches["/apis/raterunner.io/v1alpha1/namespaces/billing/billingconfigs/unchanged/status"]; ok {
+		t.Error("expected the recently reconciled resource to be left alone")
+	}
+}
+
+func TestOperator_SyncTakesRunLock(t *testing.T) {
+	holdLock(t, time.Now())
+	t.Setenv("STRIPE_SANDBOX_KEY", "sk_test_locked")
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	c := cli.NewContext(&cli.App{Writer: &out, ErrWriter: &out}, flag.NewFlagSet("operator", flag.ContinueOnError), nil)
+	_, err = operatorSync(context.Background(), c, cfg, "sandbox")
+	if err == nil || !strings.Contains(err.Error(), "sandbox on this machine is locked by alice@ci") {
+		t.Fatalf("expected the sync to wait for the run lock, got %v", err)
+	}
+}
+
+func TestOperator_SyncsAgainAfterReleasingLock(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	newFakeStripeClient(t, newFakeStripeCatalog(0, 0, 0))
+	t.Setenv("STRIPE_SANDBOX_KEY", "sk_test_fake")
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_minimal.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	c := cli.NewContext(&cli.App{Writer: &out, ErrWriter: &out}, flag.NewFlagSet("operator", flag.ContinueOnError), nil)
+	c.Context = context.Background()
+	// The second reconcile must not inherit the first one's released lock context
+	for i := 0; i < 2; i++ {
+		if _, err := operatorSync(context.Background(), c, cfg, "sandbox"); err != nil {
+			t.Fatalf("sync %d failed: %v\n%s", i+1, err, out.String())
+		}
+	}
+	if c.Context != context.Background() {
+		t.Error("expected the operator's context to be restored after the sync")
+	}
+}
+
+func TestOperator_OutsideCluster(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	stdout, _, exitCode := runApp("operator")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "not running in a Kubernetes cluster")
+}
+
+// --- Generate command tests ---
+
+func TestGenerateCheckout_AllLanguages(t *testing.T) {
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "pro", "--interval", "yearly", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "curl https://api.stripe.com/v1/checkout/sessions")
+	assertContains(t, stdout, "stripe.checkout.sessions.create")
+	assertContains(t, stdout, "session.New(params)")
+	assertContains(t, stdout, "line_items[0][price]=price_1SuH5mQe3kmrxgoYlSM0oemM")
+	assertContains(t, stdout, "stripe.CheckoutSessionModeSubscription")
+	assertContains(t, stdout, "$STRIPE_SANDBOX_KEY")
+}
+
+func TestGenerateCheckout_SingleLanguage(t *testing.T) {
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "pro", "--lang", "node", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "price: 'price_1SuH5lQe3kmrxgoYOjFvNoBt'") // monthly by default
+	if strings.Contains(stdout, "curl") {
+		t.Errorf("expected only the node snippet, got:\n%s", stdout)
+	}
+}
+
+func TestGenerateCheckout_UnknownInterval(t *testing.T) {
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "pro", "--interval", "one_time", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "plan 'pro' has no one_time price (available: monthly, yearly)")
+}
+
+func TestGenerateCheckout_InvoiceFields(t *testing.T) {
+	dir := t.TempDir()
+	billing, err := os.ReadFile("testdata/valid/billing_invoice.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billingPath := filepath.Join(dir, "billing.yaml")
+	if err := os.WriteFile(billingPath, billing, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "raterunner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	provider := "provider: stripe\nenvironment: sandbox\nplans:\n  lifetime:\n    product_id: prod_lifetime\n    prices:\n      one_time: price_lifetime\n"
+	if err := os.WriteFile(filepath.Join(dir, "raterunner", "stripe_sandbox.yaml"), []byte(provider), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "lifetime", billingPath)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `-d "invoice_creation[enabled]=true"`)
+	assertContains(t, stdout, `--data-urlencode 'invoice_creation[invoice_data][custom_fields][0][name]=VAT'`)
+	assertContains(t, stdout, `footer: "Questions? billing@example.com",`)
+	assertContains(t, stdout, `{Name: stripe.String("Support"), Value: stripe.String("support@example.com")},`)
+}
+
+func TestGenerateCheckout_SendInvoicePlan(t *testing.T) {
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "enterprise", "testdata/valid/billing_invoice.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "plan 'enterprise' uses collection_method: send_invoice, which Checkout doesn't support")
+	assertContains(t, stdout, "days_until_due=14")
+}
+
+func TestGenerateCheckout_ManualInvoicingPlan(t *testing.T) {
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "enterprise", "testdata/valid/billing_manual_invoicing.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "plan 'enterprise' uses invoicing: manual and is sold through sales, not checkout")
+	assertContains(t, stdout, "days_until_due=30")
+}
+
+func TestGenerateCheckout_UnknownPlan(t *testing.T) {
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "nope", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "plan 'nope' not found")
+}
+
+func TestGenerateCheckout_InvalidProviderFile(t *testing.T) {
+	dir := t.TempDir()
+	billing, err := os.ReadFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := os.ReadFile("testdata/invalid/provider_bad_ids.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billingPath := filepath.Join(dir, "billing.yaml")
+	if err := os.WriteFile(billingPath, billing, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "raterunner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "raterunner", "stripe_sandbox.yaml"), provider, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("generate", "checkout", "--env", "sandbox", "--plan", "pro", billingPath)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid provider file")
+	assertContains(t, stdout, "/plans/pro/product_id: Stripe ID must start with prod_")
+}
+
+// --- Cleanup command tests ---
+
+func TestCleanup_RequiresProviderFile(t *testing.T) {
+	dir := t.TempDir()
+	content, err := os.ReadFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billingPath := filepath.Join(dir, "billing.yaml")
+	if err := os.WriteFile(billingPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("cleanup", "--env", "sandbox", billingPath)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "stripe_sandbox.yaml (run 'raterunner apply --env sandbox' first)")
+}
+
+func TestCleanup_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("cleanup", "--env", "sandbox", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+// --- Production banner tests ---
+
+func TestProductionBanner(t *testing.T) {
+	var buf bytes.Buffer
+	printBanner(&buf, "Stripe account acct_1ABC (Acme Inc)", "apply creates and archives live products, prices and coupons", false)
+
+	out := buf.String()
+	assertContains(t, out, "PRODUCTION  Stripe account acct_1ABC (Acme Inc)")
+	assertContains(t, out, "apply creates and archives live products")
+	if strings.Contains(out, "\033[") {
+		t.Error("expected no color codes without color")
+	}
+
+	buf.Reset()
+	printBanner(&buf, "Stripe account acct_1ABC", "cleanup", true)
+	assertContains(t, buf.String(), "\033[1;37;41m")
+	assertContains(t, buf.String(), "\033[0m")
+}
+
+func TestProductionBanner_NoColorForBuffers(t *testing.T) {
+	if useColor(&bytes.Buffer{}) {
+		t.Error("expected no color when not writing to a terminal")
+	}
+}
+
+func TestConfirmAccount(t *testing.T) {
+	account := &stripe.Account{ID: "acct_1ABC"}
+
+	if err := checkAccountConfirmation(" acct_1ABC ", account); err != nil {
+		t.Errorf("expected the matching ID to confirm, got %v", err)
+	}
+	for _, typed := range []string{"", "y", "acct_1ABD"} {
+		err := checkAccountConfirmation(typed, account)
+		if err == nil || !strings.Contains(err.Error(), "nothing was changed") {
+			t.Errorf("expected %q to be rejected, got %v", typed, err)
+		}
+	}
+}
+
+// --- Status command tests ---
+
+func TestStatus_RequiresBothKeys(t *testing.T) {
+	os.Setenv("STRIPE_SANDBOX_KEY", "sk_test_123")
+	os.Unsetenv("STRIPE_PRODUCTION_KEY")
+	defer os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("status", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_PRODUCTION_KEY")
+}
+
+func TestStatus_Table(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_plan_rename.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	synced := []stripe.Product{{
+		ID:       "prod_starter",
+		Name:     "Starter",
+		PlanCode: "starter",
+		Active:   true,
+		Prices:   []stripe.ProductPrice{{ID: "price_1", Interval: "monthly", Amount: 900, Active: true}},
+	}}
+	sandbox := diff.Compare(cfg, synced, "sandbox")
+	production := diff.Compare(cfg, nil, "production")
+
+	var buf bytes.Buffer
+	printStatus(&buf, []*diff.DiffResult{sandbox, production})
+
+	assertContains(t, buf.String(), "SANDBOX      PRODUCTION")
+	assertContains(t, buf.String(), "[OK]         [MISSING]")
+	assertContains(t, buf.String(), "production: starter: Not in Stripe")
+}
+
+func TestDiff_PublicFlag(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_hidden_plan.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	product := func(planCode string, amount int64, metadata map[string]string) stripe.Product {
+		return stripe.Product{
+			ID:       "prod_" + planCode,
+			Name:     planCode,
+			PlanCode: planCode,
+			Metadata: metadata,
+			Active:   true,
+			Prices:   []stripe.ProductPrice{{ID: "price_" + planCode, Interval: "monthly", Amount: amount, Active: true}},
+		}
+	}
+	products := []stripe.Product{
+		product("starter", 900, nil), // synced before the flag existed: counts as public
+		product("pro", 2900, map[string]string{"public": "false"}),
+		product("legacy", 1900, nil),
+	}
+
+	result := diff.Compare(cfg, products, "sandbox")
+
+	want := map[string]string{
+		"starter": "",
+		"pro":     "public: local=true stripe=false",
+		"legacy":  "public: local=false stripe=true",
+	}
+	for _, plan := range result.Plans {
+		if plan.Details != want[plan.PlanID] {
+			t.Errorf("%s: details = %q, want %q", plan.PlanID, plan.Details, want[plan.PlanID])
+		}
+	}
+
+	products[2].Metadata = map[string]string{"public": "false"}
+	if plan := diff.Compare(cfg, products, "sandbox").Plans[2]; plan.Status != diff.StatusOK {
+		t.Errorf("legacy: status = %s, want OK", plan.Status)
+	}
+}
+
+func TestExport_PublicOnly(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "--public-only", "testdata/valid/billing_hidden_plan.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"id": "pro"`)
+	if strings.Contains(stdout, `"id": "legacy"`) {
+		t.Errorf("expected hidden plan to be left out, got:\n%s", stdout)
+	}
+}
+
+func TestExport_Region(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "--region", "eu", "testdata/valid/billing_regions.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	var bundle export.Bundle
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("failed to parse export: %v", err)
+	}
+	if bundle.Region != "eu" {
+		t.Errorf("region = %q, want eu", bundle.Region)
+	}
+	free, pro := bundle.Plans[0], bundle.Plans[1]
+	if free.Currency != "" || free.Prices["monthly"].Amount != 0 {
+		t.Errorf("expected free to keep its default prices, got %s %v", free.Currency, free.Prices)
+	}
+	if pro.Currency != "eur" || pro.Prices["monthly"].Amount != 2700 || pro.Prices["yearly"].Amount != 27000 {
+		t.Errorf("expected pro in eur at 2700/27000, got %s %v", pro.Currency, pro.Prices)
+	}
+	if pro.Regions != nil {
+		t.Errorf("expected regions to be left out of a region export, got %v", pro.Regions)
+	}
+
+	stdout, _, exitCode = runApp("export", "--region", "apac", "testdata/valid/billing_regions.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "no plan has region 'apac'")
+}
+
+func TestExport_Credits(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_credits.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	var bundle export.Bundle
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("failed to parse export: %v", err)
+	}
+	if len(bundle.Credits) != 2 {
+		t.Fatalf("expected 2 credit packs, got %d", len(bundle.Credits))
+	}
+	pack := bundle.Credits[1]
+	if pack.ID != "credits_10k" || pack.Entitlement != "ai_credits" || pack.Credits != 10000 || pack.Amount != 8000 || pack.ExpiresDays != 365 {
+		t.Errorf("unexpected credit pack: %+v", pack)
+	}
+}
+
+func TestExport_Meters(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_meters.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	var bundle export.Bundle
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("failed to parse export: %v", err)
+	}
+	if len(bundle.Meters) != 2 {
+		t.Fatalf("expected 2 meters, got %d", len(bundle.Meters))
+	}
+	if m := bundle.Meters[0]; m.Key != "api_calls" || m.EventName != "api_call" || m.Aggregation != "sum" {
+		t.Errorf("unexpected meter: %+v", m)
+	}
+	if m := bundle.Meters[1]; m.Key != "storage_gb" || m.Aggregation != "last" {
+		t.Errorf("unexpected meter: %+v", m)
+	}
+	if price := bundle.Plans[1].Prices["monthly"]; price.Meter != "api_calls" {
+		t.Errorf("expected the metered price to keep its meter, got %+v", price)
+	}
+}
+
+func TestDiff_Meters(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_meters.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	meters := []stripe.Meter{
+		{ID: "mtr_calls", DisplayName: "Calls", EventName: "api_call", Aggregation: "sum", Active: true},
+	}
+
+	result := diff.Compare(cfg, nil, "sandbox")
+	diff.AddMeters(result, cfg, meters)
+	if result.Summary.MetersDiffer != 1 || result.Summary.MetersMissing != 1 {
+		t.Fatalf("expected 1 differing and 1 missing meter, got %+v", result.Summary)
+	}
+	if !result.HasDifferences() {
+		t.Error("expected differences")
+	}
+	if d := result.Meters[0]; d.MeterID != "mtr_calls" || !strings.Contains(d.Details, "display_name: local=API calls stripe=Calls") {
+		t.Errorf("unexpected meter diff: %+v", d)
+	}
+
+	var out bytes.Buffer
+	diff.OutputTable(&out, result)
+	assertContains(t, out.String(), "meters: 1 missing, 1 differs")
+}
+
+func TestDiff_CreditPacks(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_credits.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	products := []stripe.Product{{
+		ID:         "prod_1k",
+		Name:       "1,000 AI Credits",
+		CreditCode: "credits_1k",
+		Active:     true,
+		Prices: []stripe.ProductPrice{
+			{ID: "price_old", Amount: 1200, Currency: "usd", Active: true},
+			{ID: "price_new", Amount: 1000, Currency: "usd", Active: true},
+		},
+	}}
+
+	result := diff.Compare(cfg, products, "sandbox")
+	if result.Summary.CreditsDiffer != 1 || result.Summary.CreditsMissing != 1 {
+		t.Fatalf("expected 1 differing and 1 missing credit pack, got %+v", result.Summary)
+	}
+	if !result.HasDifferences() {
+		t.Error("expected differences")
+	}
+	if d := result.Credits[0]; d.Status != diff.StatusDiffers || !strings.Contains(d.Details, "old price(s) still active: 1200") {
+		t.Errorf("unexpected credit pack diff: %+v", d)
+	}
+}
+
+func TestDiff_RegionalPrices(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_regions.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	products := []stripe.Product{{
+		ID:       "prod_pro",
+		Name:     "Pro",
+		PlanCode: "pro",
+		Active:   true,
+		Prices: []stripe.ProductPrice{
+			// Listed first, so a region-blind match would pick them
+			{ID: "price_eu_m", Interval: "monthly", Amount: 2700, Currency: "eur", Region: "eu", Active: true},
+			{ID: "price_uk_m", Interval: "monthly", Amount: 2400, Currency: "usd", Region: "uk", Active: true},
+			{ID: "price_m", Interval: "monthly", Amount: 2900, Currency: "usd", Active: true},
+			{ID: "price_y", Interval: "yearly", Amount: 29000, Currency: "usd", Active: true},
+		},
+	}}
+
+	result := diff.Compare(cfg, products, "sandbox")
+
+	pro := result.Plans[1]
+	want := "eu yearly: missing in Stripe, uk monthly: local=2400 gbp stripe=2400 usd"
+	if pro.Details != want {
+		t.Errorf("details = %q, want %q", pro.Details, want)
+	}
+	if len(pro.Prices) != 5 || pro.Prices[2].Region != "eu" {
+		t.Errorf("expected 2 default and 3 regional price diffs, got %+v", pro.Prices)
+	}
+}
+
+func TestExport_DowngradePaths(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_upgrade_paths.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	var bundle export.Bundle
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("failed to parse export: %v", err)
+	}
+	pro := bundle.Plans[2]
+	if strings.Join(pro.DowngradesTo, ",") != "starter,free" || pro.DowngradePolicy != config.DowngradeEndOfPeriod {
+		t.Errorf("expected pro to downgrade to starter and free at the end of the period, got %v %q", pro.DowngradesTo, pro.DowngradePolicy)
+	}
+	if bundle.Plans[3].DowngradePolicy != config.DowngradeBlocked {
+		t.Errorf("expected business downgrades to be blocked, got %q", bundle.Plans[3].DowngradePolicy)
+	}
+}
+
+func TestDiff_SkipsPlansOutsideEnvironment(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_environments.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Promotions[0].InEnvironment("production") || !cfg.Promotions[0].InEnvironment("sandbox") {
+		t.Error("expected LABS_BETA to be available in sandbox only")
+	}
+
+	sandbox := diff.Compare(cfg, nil, "sandbox")
+	production := diff.Compare(cfg, nil, "production")
+
+	if sandbox.Summary.Missing != 2 {
+		t.Errorf("expected both plans missing in sandbox, got %d", sandbox.Summary.Missing)
+	}
+	if production.Summary.Missing != 1 || production.Summary.Skipped != 1 {
+		t.Errorf("expected labs to be skipped in production, got %+v", production.Summary)
+	}
+	if !production.HasDifferences() {
+		t.Error("expected starter to still count as missing in production")
+	}
+
+	var buf bytes.Buffer
+	printStatus(&buf, []*diff.DiffResult{sandbox, production})
+	assertContains(t, buf.String(), "[MISSING]    [SKIPPED]")
+}
+
+// --- Events command tests ---
+
+func TestEvents_InvalidSince(t *testing.T) {
+	stdout, _, exitCode := runApp("events", "--env", "sandbox", "--since", "yesterday")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid --since value: yesterday")
+}
+
+func TestEvents_SinceBeyondRetention(t *testing.T) {
+	stdout, _, exitCode := runApp("events", "--env", "sandbox", "--since", "45d")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "only keeps events for 30 days")
+}
+
+func TestEvents_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("events", "--env", "sandbox", "--since", "7d")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"24h", now.Add(-24 * time.Hour)},
+		{"90m", now.Add(-90 * time.Minute)},
+		{"7d", now.Add(-7 * 24 * time.Hour)},
+		{"2026-03-01", time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"2026-03-09T08:30:00Z", time.Date(2026, 3, 9, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseSince(tt.value, now)
+		if err != nil {
+			t.Errorf("parseSince(%q) error: %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSince(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	if _, err := parseSince("2026-04-01", now); err == nil {
+		t.Error("expected error for a future --since")
+	}
+}
+
+// --- Subscribers command tests ---
+
+func TestSubscribers_InvalidFormat(t *testing.T) {
+	stdout, _, exitCode := runApp("subscribers", "--env", "sandbox", "--format", "xml", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid format: xml")
+}
+
+func TestSubscribers_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("subscribers", "--env", "sandbox", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestSeed_DryRun(t *testing.T) {
+	stdout, _, exitCode := runApp("seed", "--env", "sandbox", "--customers", "5", "--interval", "yearly", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Would create 5 customer(s)")
+	// free has no yearly price, so it falls back to monthly
+	assertContains(t, stdout, "free (monthly, price_1SuH5lQe3kmrxgoYdo4MDTlb): 3")
+	assertContains(t, stdout, "pro (yearly, price_1SuH5mQe3kmrxgoYlSM0oemM): 2")
+}
+
+func TestSeed_SandboxOnly(t *testing.T) {
+	stdout, _, exitCode := runApp("seed", "--env", "production", "testdata/valid/billing_full.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "seed only runs in sandbox")
+
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+	stdout, _, exitCode = runApp("seed", "--env", "sandbox", "testdata/valid/billing_full.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestSubscribers_Grouping(t *testing.T) {
+	provider, err := config.LoadProviderFile("testdata/valid/raterunner/stripe_sandbox.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monthly := provider.Plans["pro"].Prices["monthly"]
+	yearly := provider.Plans["pro"].Prices["yearly"]
+
+	items := []stripe.SubscriptionItem{
+		{SubscriptionID: "sub_1", Status: "active", PriceID: monthly, Quantity: 1},
+		{SubscriptionID: "sub_2", Status: "active", PriceID: monthly, Quantity: 3},
+		{SubscriptionID: "sub_3", Status: "trialing", PriceID: monthly, Quantity: 1},
+		{SubscriptionID: "sub_4", Status: "active", PriceID: yearly, Quantity: 1},
+		{SubscriptionID: "sub_5", Status: "active", PriceID: "price_legacy", Quantity: 2},
+	}
+	rows := report.Subscribers(items, provider)
+
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d: %+v", len(rows), rows)
+	}
+	want := []report.SubscriberCount{
+		{Kind: report.KindPlan, ID: "pro", Interval: "monthly", PriceID: monthly, Active: 2, Trialing: 1, Quantity: 5},
+		{Kind: report.KindPlan, ID: "pro", Interval: "yearly", PriceID: yearly, Active: 1, Quantity: 1},
+		{Kind: report.KindUnknown, PriceID: "price_legacy", Active: 1, Quantity: 2},
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteSubscribersCSV(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, buf.String(), "kind,id,interval,price_id,active,trialing,quantity")
+	assertContains(t, buf.String(), "plan,pro,monthly,"+monthly+",2,1,5")
+}
+
+// --- MRR command tests ---
+
+func TestMRR_InvalidFormat(t *testing.T) {
+	stdout, _, exitCode := runApp("mrr", "--env", "sandbox", "--format", "csv", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid format: csv")
+}
+
+func TestMRR_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("mrr", "--env", "sandbox", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestMRR_Estimate(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider, err := config.LoadProviderFile("testdata/valid/raterunner/stripe_sandbox.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	monthly := provider.Plans["pro"].Prices["monthly"]
+	yearly := provider.Plans["pro"].Prices["yearly"]
+
+	items := []stripe.SubscriptionItem{
+		{Status: "active", PriceID: monthly, Quantity: 2, UnitAmount: 2900, Currency: "usd", Interval: "month"},
+		{Status: "trialing", PriceID: monthly, Quantity: 1, UnitAmount: 2900, Currency: "usd", Interval: "month"},
+		{Status: "active", PriceID: yearly, Quantity: 1, UnitAmount: 24000, Currency: "usd", Interval: "year"},
+	}
+	rows, err := report.MRR(cfg, items, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d: %+v", len(rows), rows)
+	}
+
+	if rows[0].Interval != "monthly" || rows[0].MRR != 5800 || rows[0].Mismatch != "" {
+		t.Errorf("monthly row = %+v, want MRR 5800 without mismatch", rows[0])
+	}
+	if rows[1].Interval != "yearly" || rows[1].MRR != 2417 {
+		t.Errorf("yearly row = %+v, want MRR 2417 (29000/12)", rows[1])
+	}
+	if rows[1].StripeMRR == nil || *rows[1].StripeMRR != 2000 {
+		t.Errorf("yearly Stripe MRR = %v, want 2000", rows[1].StripeMRR)
+	}
+	if rows[1].Mismatch != "billing.yaml 29000, Stripe 24000" {
+		t.Errorf("yearly mismatch = %q", rows[1].Mismatch)
+	}
+}
+
+func TestMRR_TieredCharge(t *testing.T) {
+	tiers := []config.PriceTier{
+		{UpTo: 10, Amount: 500},
+		{UpTo: 50, Amount: 400},
+		{UpTo: "unlimited", Amount: 300},
+	}
+
+	tests := []struct {
+		mode     string
+		quantity int64
+		want     int64
+	}{
+		{"graduated", 5, 2500},
+		{"graduated", 20, 10*500 + 10*400},
+		{"graduated", 60, 10*500 + 40*400 + 10*300},
+		{"volume", 5, 5 * 500},
+		{"volume", 20, 20 * 400},
+		{"volume", 60, 60 * 300},
+	}
+	for _, tt := range tests {
+		got := report.Charge(config.Price{Tiers: tiers, Mode: tt.mode}, tt.quantity)
+		if got != tt.want {
+			t.Errorf("%s x%d = %d, want %d", tt.mode, tt.quantity, got, tt.want)
+		}
+	}
+}
+
+// --- Import command tests ---
+
+func TestImport_MissingEnvFlag(t *testing.T) {
+	stdout, _, exitCode := runApp("import", "--output", "/tmp/test.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "env")
+}
+
+func TestImport_MissingOutputFlag(t *testing.T) {
+	stdout, _, exitCode := runApp("import", "--env", "sandbox")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "output")
+}
+
+func TestImport_InvalidEnv(t *testing.T) {
+	stdout, _, exitCode := runApp("import", "--env", "staging", "--output", "/tmp/test.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid environment")
+}
+
+func TestImport_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("import", "--env", "sandbox", "--output", "/tmp/test.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestImport_FromFilePaddle(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+	output := filepath.Join(t.TempDir(), "billing.yaml")
+
+	stdout, _, exitCode := runApp("import", "--from-file", "testdata/import/paddle-catalog.json", "--format", "paddle", "--output", output)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Imported 2 plans and 1 addons")
+	assertContains(t, stdout, "billing cycle every 2 week isn't supported")
+
+	cfg, err := config.LoadBillingFile(output)
+	if err != nil {
+		t.Fatalf("failed to load imported config: %v", err)
+	}
+	if len(cfg.Plans) != 2 || cfg.Plans[0].ID != "pro" || cfg.Plans[1].ID != "lifetime_deal" {
+		t.Fatalf("unexpected plans: %+v", cfg.Plans)
+	}
+	pro := cfg.Plans[0]
+	if pro.TrialDays != 14 || pro.Prices["monthly"].Amount != 2900 || pro.Prices["yearly"].Amount != 29000 {
+		t.Errorf("unexpected pro plan: %+v", pro)
+	}
+	if got := pro.Prices["monthly"].CurrencyPrices["eur"]; got != 2700 {
+		t.Errorf("expected EUR monthly price 2700, got %d", got)
+	}
+	if cfg.Plans[1].BillingModel != "one_time" {
+		t.Errorf("expected lifetime_deal to be one_time, got %q", cfg.Plans[1].BillingModel)
+	}
+	if len(cfg.Addons) != 1 || cfg.Addons[0].ID != "priority_support" || cfg.Addons[0].Price.Amount != 9900 {
+		t.Errorf("unexpected addons: %+v", cfg.Addons)
+	}
+
+	stdout, _, exitCode = runApp("validate", output)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestImport_FromFileChargebee(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "billing.yaml")
+
+	stdout, _, exitCode := runApp("import", "--from-file", "testdata/import/chargebee-catalog.json", "--format", "chargebee", "--output", output)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "stairstep pricing isn't supported")
+	assertContains(t, stdout, "charge items aren't supported")
+
+	cfg, err := config.LoadBillingFile(output)
+	if err != nil {
+		t.Fatalf("failed to load imported config: %v", err)
+	}
+	if len(cfg.Plans) != 2 || cfg.Plans[0].ID != "basic" || cfg.Plans[1].ID != "team" {
+		t.Fatalf("unexpected plans: %+v", cfg.Plans)
+	}
+	if cfg.Plans[0].TrialDays != 7 || cfg.Plans[0].Prices["monthly"].Amount != 900 {
+		t.Errorf("unexpected basic plan: %+v", cfg.Plans[0])
+	}
+	team := cfg.Plans[1].Prices["monthly"]
+	if team.PriceType() != "tiered" || len(team.Tiers) != 2 || team.Tiers[1].GetTierUpTo() != -1 {
+		t.Errorf("unexpected team price: %+v", team)
+	}
+	if _, ok := cfg.Plans[1].Prices["quarterly"]; ok {
+		t.Error("expected the stairstep quarterly price to be skipped")
+	}
+	if len(cfg.Addons) != 1 || cfg.Addons[0].Price.PerUnit != 500 {
+		t.Errorf("unexpected addons: %+v", cfg.Addons)
+	}
+
+	stdout, _, exitCode = runApp("validate", output)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestImport_FromFileMissingFormat(t *testing.T) {
+	stdout, _, exitCode := runApp("import", "--from-file", "testdata/import/paddle-catalog.json", "--output", filepath.Join(t.TempDir(), "billing.yaml"))
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "--format is required")
+}
+
+func TestImport_FromFileUnknownFormat(t *testing.T) {
+	stdout, _, exitCode := runApp("import", "--from-file", "testdata/import/paddle-catalog.json", "--format", "recurly", "--output", filepath.Join(t.TempDir(), "billing.yaml"))
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown format: recurly")
+}
+
+func TestImport_FromFileWrongFormat(t *testing.T) {
+	stdout, _, exitCode := runApp("import", "--from-file", "testdata/import/paddle-catalog.json", "--format", "chargebee", "--output", filepath.Join(t.TempDir(), "billing.yaml"))
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "failed to parse Chargebee catalog")
+}
+
+func TestImport_FromFileCSV(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "billing.yaml")
+
+	stdout, _, exitCode := runApp("import", "--from-file", "testdata/import/plans.csv", "--format", "csv", "--output", output)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Imported 3 plans")
+
+	cfg, err := config.LoadBillingFile(output)
+	if err != nil {
+		t.Fatalf("failed to load imported config: %v", err)
+	}
+	if len(cfg.Plans) != 3 || cfg.Plans[0].ID != "free" || cfg.Plans[2].ID != "enterprise" {
+		t.Fatalf("unexpected plans: %+v", cfg.Plans)
+	}
+	pro := cfg.Plans[1]
+	if pro.TrialDays != 14 || pro.Prices["monthly"].Amount != 2900 || pro.Prices["yearly"].Amount != 29000 {
+		t.Errorf("unexpected pro plan: %+v", pro)
+	}
+	if got := cfg.Plans[2].Prices["monthly"].Amount; got != 19999 {
+		t.Errorf("expected enterprise monthly 19999, got %d", got)
+	}
+	if _, ok := cfg.Plans[0].Prices["yearly"]; ok {
+		t.Error("expected no yearly price for an empty cell")
+	}
+	if cfg.Plans[2].Description != "Custom limits, SSO and support" {
+		t.Errorf("unexpected quoted description: %q", cfg.Plans[2].Description)
+	}
+
+	for key, want := range map[string]string{"projects": "int", "api_requests": "rate", "sso": "bool"} {
+		if got := cfg.Entitlements[key].Type; got != want {
+			t.Errorf("entitlement %s: expected type %s, got %q", key, want, got)
+		}
+	}
+	if rl, ok := pro.RateLimit("api_requests"); !ok || rl.Limit != 1000 || rl.Per != "minute" {
+		t.Errorf("unexpected pro api_requests: %+v", pro.Limits["api_requests"])
+	}
+	if !config.IsUnlimited(cfg.Plans[2].Limits["projects"]) {
+		t.Errorf("expected unlimited enterprise projects, got %v", cfg.Plans[2].Limits["projects"])
+	}
+
+	// The free plan's amount: 0 must survive saving for the file to validate
+	stdout, _, exitCode = runApp("validate", output)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+func TestImport_FromFileCSVMixedLimit(t *testing.T) {
+	stdout, _, exitCode := runApp("import", "--from-file", "testdata/import/plans_mixed_limit.csv", "--format", "csv", "--output", filepath.Join(t.TempDir(), "billing.yaml"))
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "line 3: projects: column mixes int and bool values")
+}
+
+// --- Truncate command tests ---
+
+func TestTruncate_WithoutConfirm(t *testing.T) {
+	// Without --confirm, prompts user and aborts if no input (empty stdin in tests)
+	stdout, _, exitCode := runApp("truncate")
+
+	assertExitCode(t, 0, exitCode) // Graceful abort = exit 0
+	assertContains(t, stdout, "WARNING")
+	assertContains(t, stdout, "Are you sure?")
+	assertContains(t, stdout, "Aborted")
+}
+
+func TestTruncate_RefusesProduction(t *testing.T) {
+	t.Setenv("STRIPE_PRODUCTION_KEY", "sk_live_dummy")
+
+	stdout, _, exitCode := runApp("truncate", "--env", "production", "--confirm")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "truncate never runs against production")
+	if strings.Contains(stdout, "Are you sure?") {
+		t.Error("prompted before refusing the environment")
+	}
+
+	stdout, _, exitCode = runApp("truncate", "--env", "staging")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid environment: staging")
+	if strings.Contains(stdout, "Are you sure?") {
+		t.Error("prompted before refusing the environment")
+	}
+}
+
+func TestTruncate_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("truncate", "--confirm")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestTruncate_SeededDataWarning(t *testing.T) {
+	stdout, _, exitCode := runApp("truncate", "--subscriptions", "--customers")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "cancel the subscriptions created by 'raterunner seed'")
+	assertContains(t, stdout, "delete the customers and test clocks created by 'raterunner seed'")
+	assertContains(t, stdout, "Aborted")
+}
+
+func TestTruncate_WrongKeyPrefix(t *testing.T) {
+	os.Setenv("STRIPE_SANDBOX_KEY", "sk_live_wrongprefix")
+	defer os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("truncate", "--confirm")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "sandbox environment requires a test key")
+}
+
+// --- Run lock tests ---
+
+// holdLock writes a sandbox lock file as if another run held it
+func holdLock(t *testing.T, acquired time.Time) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	holder := lock.Holder{Owner: "alice@ci", PID: 4242, Command: "apply", Acquired: acquired}
+	if _, _, err := lock.Acquire(lockPath(stripe.Sandbox), "sandbox", holder, false); err != nil {
+		t.Fatalf("failed to write lock: %v", err)
+	}
+}
+
+func TestApply_RefusesWhileLocked(t *testing.T) {
+	holdLock(t, time.Now())
+	t.Setenv("STRIPE_SANDBOX_KEY", "sk_test_locked")
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "sandbox on this machine is locked by alice@ci (apply, pid 4242)")
+	assertContains(t, stdout, "--force-unlock")
+}
+
+func TestTruncate_RefusesWhileLocked(t *testing.T) {
+	holdLock(t, time.Now())
+	t.Setenv("STRIPE_SANDBOX_KEY", "sk_test_locked")
+
+	stdout, _, exitCode := runApp("truncate", "--confirm")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "is locked by alice@ci")
+}
+
+func TestLock_Acquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "stripe_sandbox.lock")
+	now := time.Now()
+	first := lock.Holder{Owner: "alice@ci", PID: 1, Command: "apply", Acquired: now}
+	second := lock.Holder{Owner: "bob@laptop", PID: 2, Command: "apply", Acquired: now.Add(time.Minute)}
+
+	held, previous, err := lock.Acquire(path, "sandbox", first, false)
+	if err != nil || previous != nil {
+		t.Fatalf("expected a fresh lock, got previous %v, err %v", previous, err)
+	}
+
+	_, _, err = lock.Acquire(path, "sandbox", second, false)
+	var heldErr *lock.HeldError
+	if !errors.As(err, &heldErr) || heldErr.Holder.Owner != "alice@ci" {
+		t.Fatalf("expected the lock to be held by alice@ci, got %v", err)
+	}
+
+	// --force-unlock takes over and reports who held the lock
+	forced, previous, err := lock.Acquire(path, "sandbox", second, true)
+	if err != nil || previous == nil || previous.Owner != "alice@ci" {
+		t.Fatalf("expected to take over alice's lock, got previous %v, err %v", previous, err)
+	}
+	// The run whose lock was taken over leaves the new lock alone
+	if err := held.Release(); err != nil {
+		t.Fatalf("failed to release taken-over lock: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected bob's lock to remain: %v", err)
+	}
+	if err := forced.Release(); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the lock file to be removed, got %v", err)
+	}
+
+	// Expired locks are taken over without --force-unlock
+	if _, _, err := lock.Acquire(path, "sandbox", first, false); err != nil {
+		t.Fatal(err)
+	}
+	late := lock.Holder{Owner: "bob@laptop", Acquired: now.Add(lock.TTL + time.Minute)}
+	if _, previous, err := lock.Acquire(path, "sandbox", late, false); err != nil || previous == nil {
+		t.Fatalf("expected the expired lock to be taken over, got previous %v, err %v", previous, err)
+	}
+}
+
+func TestLock_Refresh(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "locks", "stripe_sandbox.lock")
+	now := time.Now()
+	first := lock.Holder{Owner: "alice@ci", PID: 1, Command: "apply", Acquired: now.Add(-lock.TTL + time.Minute)}
+
+	held, _, err := lock.Acquire(path, "sandbox", first, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := held.Refresh(now); err != nil {
+		t.Fatalf("failed to refresh: %v", err)
+	}
+
+	// Past TTL since acquiring, but not since the refresh
+	second := lock.Holder{Owner: "bob@laptop", PID: 2, Command: "apply", Acquired: now.Add(10 * time.Minute)}
+	_, _, err = lock.Acquire(path, "sandbox", second, false)
+	var heldErr *lock.HeldError
+	if !errors.As(err, &heldErr) {
+		t.Fatalf("expected the refreshed lock to be held, got %v", err)
+	}
+
+	if _, _, err := lock.Acquire(path, "sandbox", second, true); err != nil {
+		t.Fatal(err)
+	}
+	var lost *lock.LostError
+	if err := held.Refresh(now.Add(time.Minute)); !errors.As(err, &lost) || lost.Holder.Owner != "bob@laptop" {
+		t.Fatalf("expected the refresh to report the lock taken over by bob, got %v", err)
+	}
+}
+
+func TestLock_KeepLocksStopsRunWhenTakenOver(t *testing.T) {
+	old := lockRefreshInterval
+	lockRefreshInterval = 10 * time.Millisecond
+	t.Cleanup(func() { lockRefreshInterval = old })
+
+	path := filepath.Join(t.TempDir(), "locks", "stripe_sandbox.lock")
+	holder := lock.Holder{Owner: "alice@ci", PID: 1, Command: "apply", Acquired: time.Now()}
+	held, _, err := lock.Acquire(path, "sandbox", holder, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	var errOut bytes.Buffer
+	keep := func(stop <-chan struct{}) <-chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			keepLocks(ctx, stop, held, nil, "", holder, &errOut, cancel)
+		}()
+		return done
+	}
+
+	// Still ours: refreshed, and the run goes on
+	stop := make(chan struct{})
+	done := keep(stop)
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	<-done
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertContains(t, string(content), `"refreshed"`)
+	if ctx.Err() != nil {
+		t.Fatalf("expected the run to continue, got %v", context.Cause(ctx))
+	}
+
+	// Taken over: the run is cancelled
+	other := lock.Holder{Owner: "bob@laptop", PID: 2, Command: "apply", Acquired: time.Now()}
+	if _, _, err := lock.Acquire(path, "sandbox", other, true); err != nil {
+		t.Fatal(err)
+	}
+	<-keep(make(chan struct{}))
+	var lost *lock.LostError
+	if !errors.As(context.Cause(ctx), &lost) {
+		t.Fatalf("expected the run to be cancelled with the lost lock, got %v", context.Cause(ctx))
+	}
+	assertContains(t, errOut.String(), "sandbox was taken over by bob@laptop")
+}
+
+// --- Lint command tests ---
+
+func TestLint_Clean(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "no lint warnings")
+}
+
+func TestLint_GraceWithoutDunning(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "testdata/valid/billing_grace_days.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "/settings/grace_days")
+	assertContains(t, stdout, "[grace-without-dunning]")
+}
+
+func TestLint_GraceWithDunning(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "testdata/valid/billing_dunning.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "no lint warnings")
+}
+
+func TestLint_StrictFailsOnWarnings(t *testing.T) {
+	_, _, exitCode := runApp("lint", "--strict", "testdata/valid/billing_grace_days.yaml")
+
+	assertExitCode(t, 1, exitCode)
+}
+
+func TestLint_JSON(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--format", "json", "testdata/valid/billing_grace_days.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"rule": "grace-without-dunning"`)
+}
+
+func TestLint_PricingPackIsOptional(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "testdata/valid/billing_pricing_lint.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "no lint warnings")
+}
+
+func TestLint_PricingPack(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--enable", "pricing", "testdata/valid/billing_pricing_lint.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	for _, rule := range []string{"price-cents", "yearly-discount-band", "missing-yearly", "features-match-limits", "plan-order"} {
+		assertContains(t, stdout, "["+rule+"]")
+	}
+}
+
+func TestLint_DisableRule(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--enable", "pricing", "--disable", "missing-yearly", "testdata/valid/billing_pricing_lint.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	if strings.Contains(stdout, "[missing-yearly]") {
+		t.Errorf("expected missing-yearly to be disabled, got:\n%s", stdout)
+	}
+}
+
+func TestLint_Suppressed(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--strict", "--enable", "pricing", "testdata/valid/billing_suppressions.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "no lint warnings (2 suppressed)")
+}
+
+func TestLint_SuppressedJSON(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--format", "json", "testdata/valid/billing_suppressions.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"suppressed": true`)
+	assertContains(t, stdout, `"suppression_reason": "retries are configured in the Stripe Dashboard"`)
+}
+
+func TestLint_SARIF(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--format", "sarif", "testdata/valid/billing_suppressions.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"version": "2.1.0"`)
+	assertContains(t, stdout, `"ruleId": "grace-without-dunning"`)
+	assertContains(t, stdout, `"startLine": 7`)
+	assertContains(t, stdout, `"kind": "inSource"`)
+}
+
+func TestLint_MalformedSuppression(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "billing.yaml")
+	content := "version: 1\nplans:\n  # raterunner:disable\n  - id: pro\n    name: Pro\n    prices:\n      monthly: { amount: 2900 }\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("lint", path)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "malformed suppression")
+}
+
+func TestValidate_SuppressedSemanticError(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/billing_suppressions.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid (1 suppressed)")
+}
+
+func TestLint_UnknownRule(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--enable", "nope", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown lint rule or pack: nope")
+}
+
+func TestLint_FeatureText(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "testdata/valid/billing_feature_text.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "/plans/0/features/2")
+	assertContains(t, stdout, "[feature-html]")
+	assertContains(t, stdout, "/plans/1/features/0")
+	assertContains(t, stdout, "[feature-length]")
+	if strings.Contains(stdout, "[feature-capitalization]") {
+		t.Errorf("expected the copy pack to be off by default, got:\n%s", stdout)
+	}
+}
+
+func TestLint_CopyPack(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--enable", "copy", "testdata/valid/billing_feature_text.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `feature "audit log" doesn't start uppercase`)
+	assertContains(t, stdout, `feature "Advanced analytics." ends with a period`)
+	if strings.Contains(stdout, "More coming soon") {
+		t.Errorf("expected an ellipsis not to count as a trailing period, got:\n%s", stdout)
+	}
+}
+
+func TestLint_FeaturePeriodsRequire(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--enable", "copy", "--feature-periods", "require", "testdata/valid/billing_feature_text.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `feature "Email support" doesn't end with a period`)
+
+	stdout, _, exitCode = runApp("lint", "--feature-periods", "sometimes", "testdata/valid/billing_feature_text.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid feature period policy: sometimes")
+}
+
+func TestLint_Copy(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--copy", "testdata/valid/billing_copy_lint.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `writes "Pro Plan" but the rest of the copy writes "Pro plan"`)
+	assertContains(t, stdout, "contains the currency symbol $")
+	assertContains(t, stdout, `feature "50 projects" claims 50 projects but the limit is 25`)
+	assertContains(t, stdout, `feature "Unlimited seats" claims Unlimited seats but the limit is 10`)
+	if strings.Contains(stdout, "/plans/0/") {
+		t.Errorf("expected the free plan's copy to match its limits, got:\n%s", stdout)
+	}
+}
+
+func TestLint_ListRules(t *testing.T) {
+	stdout, _, exitCode := runApp("lint", "--list-rules")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "yearly-discount-band")
+}
+
+func TestExport_GraceDays(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_grace_days.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"grace_days": 5`)
+}
+
+func TestExport_Dunning(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_dunning.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	var bundle export.Bundle
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("failed to parse export: %v", err)
+	}
+	d := bundle.Dunning
+	if d == nil || len(d.RetryDays) != 3 || d.CancelAfterFailures != 4 {
+		t.Fatalf("unexpected dunning: %+v", d)
+	}
+	if got := d.Emails.Enabled(); strings.Join(got, ",") != "payment_failed,expiring_card" {
+		t.Errorf("unexpected dunning emails: %v", got)
+	}
+}
+
+func TestExport_Invoice(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_invoice.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	var bundle export.Bundle
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("failed to parse export: %v", err)
+	}
+	if bundle.Invoice == nil || bundle.Invoice.Footer != "Questions? billing@example.com" || len(bundle.Invoice.CustomFields) != 2 {
+		t.Fatalf("unexpected invoice settings: %+v", bundle.Invoice)
+	}
+	if p := bundle.Plans[0]; p.CollectionMethod != "" || p.DaysUntilDue != 0 {
+		t.Errorf("expected plan 'pro' to be charged automatically, got %+v", p)
+	}
+	if p := bundle.Plans[1]; p.CollectionMethod != "send_invoice" || p.DaysUntilDue != 14 {
+		t.Errorf("expected plan 'enterprise' to send invoices due in 14 days, got %+v", p)
+	}
+}
+
+func TestExport_ManualInvoicing(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_manual_invoicing.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	var bundle export.Bundle
+	if err := json.Unmarshal([]byte(stdout), &bundle); err != nil {
+		t.Fatalf("failed to parse export: %v", err)
+	}
+	if p := bundle.Plans[0]; p.Invoicing != "" || p.CollectionMethod != "" {
+		t.Errorf("expected plan 'pro' to be self-serve, got %+v", p)
+	}
+	if p := bundle.Plans[1]; p.Invoicing != "manual" || p.CollectionMethod != "send_invoice" || p.DaysUntilDue != 30 {
+		t.Errorf("expected plan 'enterprise' to be invoiced manually on net-30 terms, got %+v", p)
+	}
+}
+
+func TestExport_Trial(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_trial.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"downgrade_to": "free"`)
+	assertContains(t, stdout, `"end_behavior": "cancel"`)
+}
+
+func TestExport_Provisioning(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_provisioning.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"provisioning": {`)
+	assertContains(t, stdout, `"region": "eu-west-1"`)
+	assertContains(t, stdout, `"PRO-SUPPORT"`)
+}
+
+func TestExport_AddonGrants(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_addon_grants.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"op": "add"`)
+	assertContains(t, stdout, `"op": "unlimited"`)
+	assertContains(t, stdout, `"value": true`)
+}
+
+func TestGrant_Apply(t *testing.T) {
+	tests := []struct {
+		grant any
+		limit any
+		want  any
+	}{
+		{"+10", 5, 15},
+		{"-10", 5, 0},
+		{"+10", config.Unlimited{}, config.Unlimited{}},
+		{25, 5, 25},
+		{"unlimited", 5, config.Unlimited{}},
+		{true, false, true},
+	}
+
+	for _, tt := range tests {
+		g, err := config.ParseGrant(tt.grant)
+		if err != nil {
+			t.Fatalf("ParseGrant(%v): %v", tt.grant, err)
+		}
+		got, err := g.Apply(tt.limit)
+		if err != nil {
+			t.Fatalf("Apply(%v) with %v: %v", tt.limit, tt.grant, err)
+		}
+		if got != tt.want {
+			t.Errorf("grant %v on %v = %v, want %v", tt.grant, tt.limit, got, tt.want)
+		}
+	}
+
+	if _, err := config.ParseGrant("+0"); err == nil {
+		t.Error("expected \"+0\" to be rejected")
+	}
+}
+
+func TestExport_PromotionStacking(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_promotion_stacking.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"stackable": false`)
+	assertContains(t, stdout, `"PARTNER20"`)
+}
+
+// --- Diff tests ---
+
+func TestDiff_SkipsUnsyncedPlans(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_unsynced_free.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	result := diff.Compare(cfg, nil, "sandbox")
+
+	if result.Summary.Skipped != 1 || result.Summary.Missing != 1 || result.Summary.Total != 1 {
+		t.Errorf("unexpected summary: %+v", result.Summary)
+	}
+	if result.Plans[0].PlanID != "free" || result.Plans[0].Status != diff.StatusSkipped {
+		t.Errorf("expected free plan to be skipped, got %+v", result.Plans[0])
+	}
+
+	var buf bytes.Buffer
+	diff.OutputTable(&buf, result)
+	assertContains(t, buf.String(), "[SKIPPED]")
+	assertContains(t, buf.String(), "1 skipped")
+}
+
+func TestDiff_Addons(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	addon := func(prices ...stripe.ProductPrice) []stripe.Product {
+		return []stripe.Product{{ID: "prod_extra", Name: "Extra Projects", Active: true, Prices: prices}}
+	}
+
+	tests := []struct {
+		name     string
+		products []stripe.Product
+		status   diff.Status
+		details  string
+	}{
+		{"missing", nil, diff.StatusMissing, "Not in Stripe"},
+		{"in sync", addon(stripe.ProductPrice{ID: "price_1", Amount: 1000, Active: true}), diff.StatusOK, ""},
+		{"amount changed", addon(stripe.ProductPrice{ID: "price_1", Amount: 500, Active: true}), diff.StatusDiffers, "price 1000 missing in Stripe, old price(s) still active: 500"},
+		{"old price still active", addon(
+			stripe.ProductPrice{ID: "price_1", Amount: 500, Active: true},
+			stripe.ProductPrice{ID: "price_2", Amount: 1000, Active: true},
+		), diff.StatusDiffers, "old price(s) still active: 500"},
+		{"old price archived", addon(
+			stripe.ProductPrice{ID: "price_1", Amount: 500, Active: false},
+			stripe.ProductPrice{ID: "price_2", Amount: 1000, Active: true},
+		), diff.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		result := diff.Compare(cfg, tt.products, "sandbox")
+		got := result.Addons[0]
+		if got.Status != tt.status || got.Details != tt.details {
+			t.Errorf("%s: expected %s %q, got %s %q", tt.name, tt.status, tt.details, got.Status, got.Details)
+		}
+		if tt.status != diff.StatusOK && !result.HasDifferences() {
+			t.Errorf("%s: expected the addon to count as a difference", tt.name)
+		}
+	}
+
+	var buf bytes.Buffer
+	diff.OutputTable(&buf, diff.Compare(cfg, addon(stripe.ProductPrice{ID: "price_1", Amount: 500, Active: true}), "sandbox"))
+	assertContains(t, buf.String(), "extra_projects        [DIFFERS]")
+	assertContains(t, buf.String(), "addons: 0 missing, 1 differs")
+}
+
+func TestExport_IncludesUnsyncedPlans(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_unsynced_free.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"id": "free"`)
+}
+
+func TestExport_ContactSales(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_custom_pricing.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"contact_sales": true`)
+}
+
+func TestDiff_SkipsCustomPricing(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_custom_pricing.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+
+	result := diff.Compare(cfg, nil, "sandbox")
+
+	if result.Summary.Skipped != 1 || result.Summary.Missing != 1 {
+		t.Errorf("unexpected summary: %+v", result.Summary)
+	}
+	assertContains(t, result.Plans[1].Details, "contact sales")
+}
+
+func TestDiff_PlanRename(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_plan_rename.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	products := []stripe.Product{{
+		ID:       "prod_basic",
+		Name:     "Basic",
+		PlanCode: "basic",
+		Active:   true,
+		Prices:   []stripe.ProductPrice{{ID: "price_1", Interval: "monthly", Amount: 900, Active: true}},
+	}}
+
+	result := diff.Compare(cfg, products, "sandbox")
+
+	plan := result.Plans[0]
+	if plan.Status != diff.StatusDiffers || plan.RenamedFrom != "basic" {
+		t.Errorf("expected rename from basic, got %+v", plan)
+	}
+	assertContains(t, plan.Details, "renamed from 'basic'")
+}
+
+func TestDiff_CatalogVersion(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_metadata_prefix.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	stamped := func(version string) []stripe.Product {
+		return []stripe.Product{{
+			ID:             "prod_pro",
+			Name:           "Pro",
+			PlanCode:       "pro",
+			CatalogVersion: version,
+			Active:         true,
+			Prices:         []stripe.ProductPrice{{ID: "price_1", Interval: "monthly", Amount: 2900, Active: true}},
+		}}
+	}
+
+	// Same config applied from another commit is not drift
+	result := diff.Compare(cfg, stamped(config.CatalogVersion(cfg.CatalogHash(), "a1b2c3d")), "sandbox")
+	if result.CatalogVersion == nil || result.CatalogVersion.Status != diff.StatusOK || result.HasDifferences() {
+		t.Errorf("expected matching catalog version, got %+v", result.CatalogVersion)
+	}
+
+	result = diff.Compare(cfg, stamped("000000000000@a1b2c3d"), "sandbox")
+	if result.CatalogVersion == nil || result.CatalogVersion.Status != diff.StatusDiffers || !result.HasDifferences() {
+		t.Errorf("expected catalog version drift, got %+v", result.CatalogVersion)
+	}
+	var buf bytes.Buffer
+	diff.OutputTable(&buf, result)
+	assertContains(t, buf.String(), "Stripe 000000000000@a1b2c3d [DIFFERS]")
+
+	// Products synced before stamping was added are not compared
+	result = diff.Compare(cfg, stamped(""), "sandbox")
+	if result.CatalogVersion != nil || result.HasDifferences() {
+		t.Errorf("expected no catalog version comparison, got %+v", result.CatalogVersion)
+	}
+}
+
+func TestCatalogHash_IgnoresFormatting(t *testing.T) {
+	a, err := config.LoadBillingFile("testdata/valid/billing_metadata_prefix.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile("testdata/valid/billing_metadata_prefix.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "billing.yaml")
+	reformatted := strings.ReplaceAll(string(content), "# ", "## ") + "\n# trailing comment\n"
+	if err := os.WriteFile(path, []byte(reformatted), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := config.LoadBillingFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.CatalogHash() == "" || a.CatalogHash() != b.CatalogHash() {
+		t.Errorf("hash changed with comments only: %q vs %q", a.CatalogHash(), b.CatalogHash())
+	}
+	b.Plans[0].Prices["monthly"] = config.Price{Amount: 3900}
+	if a.CatalogHash() == b.CatalogHash() {
+		t.Error("hash did not change with the price")
+	}
+}
+
+func TestCatalogHash_IgnoresOrder(t *testing.T) {
+	a, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Plans[0], b.Plans[1] = b.Plans[1], b.Plans[0]
+	if a.CatalogHash() != b.CatalogHash() {
+		t.Errorf("hash changed with plan order: %q vs %q", a.CatalogHash(), b.CatalogHash())
+	}
+	if a.Plans[0].ID == b.Plans[0].ID {
+		t.Error("hashing reordered the config's own plans")
+	}
+
+	// Feature order is kept in Stripe, so it counts
+	b.Plans[0].Features = []string{"SSO", "Audit log"}
+	before := b.CatalogHash()
+	b.Plans[0].Features = []string{"Audit log", "SSO"}
+	if before == b.CatalogHash() {
+		t.Error("hash did not change with feature order")
+	}
+}
+
+func TestHash(t *testing.T) {
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("hash", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	if strings.TrimSpace(stdout) != cfg.CatalogHash() {
+		t.Errorf("expected %s, got %q", cfg.CatalogHash(), stdout)
+	}
+}
+
+func TestApply_SkipsUnchangedConfig(t *testing.T) {
+	t.Setenv("STRIPE_SANDBOX_KEY", "")
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	billingPath := filepath.Join(dir, "billing.yaml")
+	if err := os.WriteFile(billingPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider := &config.ProviderConfig{Provider: "stripe", Environment: "sandbox", CatalogVersion: config.CatalogVersion(cfg.CatalogHash(), "a1b2c3d")}
+	if err := config.SaveProviderFile(config.ProviderFilePath(billingPath, "stripe", "sandbox"), provider); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", billingPath)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Config unchanged since the last apply to sandbox (catalog version "+cfg.CatalogHash()+"@a1b2c3d)")
+
+	// --force goes on to Stripe, which needs the API key
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--force", billingPath)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+
+	// So does --prune: removing a plan doesn't change what remains to apply
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--prune", billingPath)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestValidate_PlanRenameConflict(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/invalid/billing_plan_rename_conflict.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "previous ID 'pro' is still a current plan ID")
+}
+
+// --- Doctor command tests ---
+
+func TestDoctor_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("doctor", "--env", "sandbox", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "✓ billing config testdata/valid/billing_full.yaml is valid")
+	assertContains(t, stdout, "✗ Stripe API key")
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestDoctor_InvalidEnvIsReported(t *testing.T) {
+	stdout, _, exitCode := runApp("doctor", "--env", "staging", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "✓ billing config testdata/valid/billing_full.yaml is valid")
+	assertContains(t, stdout, "✗ environment: invalid environment: staging")
+}
+
+func TestDoctor_AutomaticTaxNeedsKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("doctor", "--env", "sandbox", "testdata/valid/billing_automatic_tax.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "✗ Stripe Tax: can't check without a valid API key")
+}
+
+// --- Schema command tests ---
+
+func TestSchemaPrint_Billing(t *testing.T) {
+	stdout, _, exitCode := runApp("schema", "print")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Billing Configuration Schema")
+	assertContains(t, stdout, "#/$defs/Plan")
+}
+
+func TestSchemaPrint_ProviderResolved(t *testing.T) {
+	stdout, _, exitCode := runApp("schema", "print", "--resolve-refs", "provider")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Provider IDs Schema")
+	if strings.Contains(stdout, "$ref") || strings.Contains(stdout, "$defs") {
+		t.Errorf("expected resolved schema without $ref/$defs, got: %s", stdout)
+	}
+}
+
+func TestSchemaPrint_BillingResolvedIsValid(t *testing.T) {
+	stdout, _, exitCode := runApp("schema", "print", "--resolve-refs", "billing")
+
+	assertExitCode(t, 0, exitCode)
+	if strings.Contains(stdout, "#/$defs/") {
+		t.Errorf("expected all local refs to be inlined")
+	}
+
+	// The flattened schema must still validate the fixtures
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/billing.schema.json", []byte(stdout), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, _, code := runApp("validate", "--schema-dir", dir, "testdata/valid/billing_advanced.yaml")
+	assertExitCode(t, 0, code)
+	assertContains(t, out, "is valid")
+}
+
+func TestSchemaPrint_UnknownType(t *testing.T) {
+	stdout, _, exitCode := runApp("schema", "print", "pricing")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown schema type")
+}
+
+// --- Export command tests ---
+
+func TestExport_UnlimitedNull(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_advanced.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"seats": null`)
+	assertContains(t, stdout, `"seats": 10`)
+}
+
+func TestExport_UnlimitedNegative(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "--unlimited", "-1", "testdata/valid/billing_advanced.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"seats": -1`)
+}
+
+func TestExport_RateLimitTyped(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"per": "minute"`)
+}
+
+func TestExport_InvalidUnlimitedStyle(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "--unlimited", "zero", "testdata/valid/billing_advanced.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid unlimited style")
+}
+
+func TestExport_EmbedsProviderIDs(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "pricing.json")
+
+	_, _, exitCode := runApp("export", "--env", "sandbox", "-o", output, "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+	assertContains(t, string(content), `"yearly": "price_1SuH5mQe3kmrxgoYlSM0oemM"`)
+	assertContains(t, string(content), `"price_id": "price_1SuH5mQe3kmrxgoYu9XTzRMg"`)
+	assertContains(t, string(content), `"coupon_id": "LAUNCH50"`)
+}
+
+func TestExport_MissingProviderFile(t *testing.T) {
+	stdout, _, exitCode := runApp("export", "--env", "production", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "run 'raterunner apply --env production' first")
+}
+
+// --- Flags command tests ---
+
+func TestFlagsSync_DryRun(t *testing.T) {
+	stdout, _, exitCode := runApp("flags", "sync", "--provider", "launchdarkly", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "sso")
+	assertContains(t, stdout, "enabled for: pro")
+}
+
+func TestFlagsSync_UnknownProvider(t *testing.T) {
+	stdout, _, exitCode := runApp("flags", "sync", "--provider", "optimizely", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown flag provider")
+}
+
+func TestFlagsSync_MissingToken(t *testing.T) {
+	os.Unsetenv("LAUNCHDARKLY_API_TOKEN")
+
+	stdout, _, exitCode := runApp("flags", "sync", "--provider", "launchdarkly", "--project", "web", "--environment", "test", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "token is empty")
+}
+
+func TestFlagsSync_UnleashReplacesAllStrategies(t *testing.T) {
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/strategies") {
+			w.Write([]byte(`[{"id":"s1","name":"default","parameters":{},"constraints":[]},{"id":"s2","name":"default","parameters":{},"constraints":[]}]`))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	t.Setenv("UNLEASH_URL", server.URL)
+	t.Setenv("UNLEASH_API_TOKEN", "token")
+
+	_, stderr, exitCode := runApp("flags", "sync", "--provider", "unleash", "--environment", "production", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stderr, "PRODUCTION  unleash project default, environment production")
+	log := strings.Join(calls, "\n")
+	envPath := "/api/admin/projects/default/features/sso/environments/production"
+	assertContains(t, log, "PUT "+envPath+"/strategies/s1")
+	assertContains(t, log, "DELETE "+envPath+"/strategies/s2")
+	assertContains(t, log, "POST "+envPath+"/on")
+}
+
+// --- Config command tests ---
+
+func TestConfig_Path(t *testing.T) {
+	stdout, _, exitCode := runApp("config", "path")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, ".raterunner")
+	assertContains(t, stdout, "config.yaml")
+}
+
+func TestConfig_List(t *testing.T) {
+	stdout, _, exitCode := runApp("config", "list")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "quiet")
+}
+
+func TestConfig_GetUnknownKey(t *testing.T) {
+	stdout, _, exitCode := runApp("config", "get", "unknown_key")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown config key")
+}
+
+func TestConfig_SetUnknownKey(t *testing.T) {
+	stdout, _, exitCode := runApp("config", "set", "unknown_key", "value")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown config key")
+}
+
+func TestConfig_SetMissingArgs(t *testing.T) {
+	stdout, _, exitCode := runApp("config", "set", "quiet")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "usage")
+}
+
+func TestConfig_GetMissingArgs(t *testing.T) {
+	stdout, _, exitCode := runApp("config", "get")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "usage")
+}
+
+func TestConfig_StrictWarnings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	stdout, _, exitCode := runApp("config", "get", "strict_warnings")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "false")
+
+	_, _, exitCode = runApp("config", "set", "strict_warnings", "true")
+	assertExitCode(t, 0, exitCode)
+
+	stdout, _, exitCode = runApp("config", "list")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "strict_warnings = true")
+}
+
+func TestConfig_HTTPSettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, _, exitCode := runApp("config", "set", "http_max_idle_conns", "32")
+	assertExitCode(t, 0, exitCode)
+	_, _, exitCode = runApp("config", "set", "http_max_conns_per_host", "8")
+	assertExitCode(t, 0, exitCode)
+
+	stdout, _, exitCode := runApp("config", "list")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "http_max_idle_conns = 32")
+	assertContains(t, stdout, "http_max_conns_per_host = 8")
+
+	stdout, _, exitCode = runApp("config", "set", "http_max_idle_conns", "-1")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid http_max_idle_conns: -1")
+}
+
+func TestConfig_RetrySettings(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	_, _, exitCode := runApp("config", "set", "retry_max_attempts", "6")
+	assertExitCode(t, 0, exitCode)
+	_, _, exitCode = runApp("config", "set", "retry_backoff", "1s")
+	assertExitCode(t, 0, exitCode)
+	_, _, exitCode = runApp("config", "set", "retry_jitter", "0")
+	assertExitCode(t, 0, exitCode)
+
+	stdout, _, exitCode := runApp("config", "list")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "retry_max_attempts = 6")
+	assertContains(t, stdout, "retry_backoff = 1s")
+	assertContains(t, stdout, "retry_jitter = 0")
+
+	stdout, _, exitCode = runApp("config", "set", "retry_backoff", "soon")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid retry_backoff: soon")
+
+	stdout, _, exitCode = runApp("config", "set", "retry_jitter", "1.5")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid retry_jitter: 1.5")
+}
+
+func TestConfig_DefaultEnvRejectsProduction(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	stdout, _, exitCode := runApp("config", "set", "default_env", "production")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "default_env cannot be production")
+}
+
+func TestApply_UsesDefaultEnv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	_, _, exitCode := runApp("config", "set", "default_env", "sandbox")
+	assertExitCode(t, 0, exitCode)
+
+	stdout, stderr, exitCode := runApp("apply", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stderr, "Using default environment: sandbox")
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestApply_DefaultEnvIgnoredWithExplicitEnv(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	os.Unsetenv("STRIPE_PRODUCTION_KEY")
+
+	runApp("config", "set", "default_env", "sandbox")
+
+	stdout, _, exitCode := runApp("apply", "--env", "production", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_PRODUCTION_KEY")
+}
+
+func TestConfig_ProjectSettingsOverrideUser(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	runApp("config", "set", "default_env", "none")
+	runApp("config", "set", "quiet", "true")
+
+	project := t.TempDir()
+	content := "default_env: sandbox\nquiet: false\nschema_dir: schemas\n"
+	if err := os.WriteFile(filepath.Join(project, ".raterunner.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write project settings: %v", err)
+	}
+	nested := filepath.Join(project, "app")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("failed to create nested dir: %v", err)
+	}
+
+	wd, _ := os.Getwd()
+	if err := os.Chdir(nested); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	stdout, _, exitCode := runApp("config", "list")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "default_env = sandbox")
+	assertContains(t, stdout, "quiet = false")
+	assertContains(t, stdout, "schema_dir = "+filepath.Join(project, "schemas"))
+}
+
+// --- Role gating tests ---
+
+// withRole runs the test from a project whose .raterunner.yaml sets role
+func withRole(t *testing.T, role string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, ".raterunner.yaml"), []byte("role: "+role+"\n"), 0644); err != nil {
+		t.Fatalf("failed to write project settings: %v", err)
+	}
+	wd, _ := os.Getwd()
+	if err := os.Chdir(project); err != nil {
+		t.Fatalf("failed to chdir: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestRoles_ViewerRunsReadOnlyCommands(t *testing.T) {
+	billing, _ := filepath.Abs("testdata/valid/billing_full.yaml")
+	withRole(t, "viewer")
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("validate", billing)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+
+	// A dry run passes the gate and stops at the missing key
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--dry-run", billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestRoles_ViewerCannotApply(t *testing.T) {
+	billing, _ := filepath.Abs("testdata/valid/billing_full.yaml")
+	withRole(t, "viewer")
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", billing)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "'apply' requires the editor role, but this installation is configured as viewer")
+}
+
+func TestRoles_EditorCannotTruncate(t *testing.T) {
+	withRole(t, "editor")
+
+	stdout, _, exitCode := runApp("truncate", "--confirm")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "'truncate' requires the admin role")
+}
+
+func TestRoles_EditorCannotPrune(t *testing.T) {
+	billing, _ := filepath.Abs("testdata/valid/billing_full.yaml")
+	withRole(t, "editor")
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--prune", billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "'apply' requires the admin role, but this installation is configured as editor")
+
+	// Listing what would be pruned stays read-only
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--dry-run", "--prune", billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+}
+
+func TestRoles_GatesSubcommands(t *testing.T) {
+	withRole(t, "viewer")
+
+	stdout, _, exitCode := runApp("flags", "sync", "--provider", "launchdarkly")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "'flags sync' requires the editor role")
+}
+
+func TestRoles_ViewerCannotEditConfig(t *testing.T) {
+	withRole(t, "viewer")
+
+	stdout, _, exitCode := runApp("refactor", "rename-entitlement", "seats", "members", "billing.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "'refactor rename-entitlement' requires the editor role")
+
+	// A dry run passes the gate and stops at the missing file
+	stdout, _, exitCode = runApp("plans", "copy", "--dry-run", "pro", "pro_eu", "billing.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "failed to read billing.yaml")
+}
+
+func TestClone_Environments(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "billing.yaml")
+
+	stdout, _, exitCode := runApp("clone", "--from", "sandbox", "--to", "production", "-o", out)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "clone only writes to sandbox")
+
+	stdout, _, exitCode = runApp("clone", "--from", "sandbox", "--to", "sandbox", "-o", out)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "--from and --to are both sandbox")
+
+	stdout, _, exitCode = runApp("clone", "--from", "staging", "--to", "sandbox", "-o", out)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid environment: staging")
+}
+
+func TestClone_MissingAPIKey(t *testing.T) {
+	t.Setenv("STRIPE_PRODUCTION_KEY", "")
+	out := filepath.Join(t.TempDir(), "billing.yaml")
+
+	stdout, _, exitCode := runApp("clone", "--from", "production", "--to", "sandbox", "-o", out)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_PRODUCTION_KEY")
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected no billing file without an API key, got %v", err)
+	}
+}
+
+func TestRoles_InvalidRole(t *testing.T) {
+	withRole(t, "owner")
+
+	stdout, _, exitCode := runApp("config", "list")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid role: owner")
+}
+
+func TestConfig_RoleNotSettable(t *testing.T) {
+	withRole(t, "viewer")
+
+	stdout, _, exitCode := runApp("config", "set", "role", "admin")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "role can't be changed with config set")
+
+	stdout, _, exitCode = runApp("config", "get", "role")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "viewer")
+}
+
+// --- Quiet flag tests ---
+
+func TestQuietFlag_Validate(t *testing.T) {
+	// With --quiet flag, success output is suppressed
+	stdout, _, exitCode := runApp("--quiet", "validate", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	if strings.Contains(stdout, "is valid") {
+		t.Error("expected quiet mode to suppress 'is valid' output")
+	}
+}
+
+func TestQuietFlag_ValidateErrors(t *testing.T) {
+	// With --quiet flag, errors are still shown
+	stdout, _, exitCode := runApp("--quiet", "validate", "testdata/invalid/billing_missing_name.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "validation error") // Errors still shown
+}
+
+// --- Verbose flag tests ---
+
+func TestVerbose_StackedShortFlag(t *testing.T) {
+	stdout, _, exitCode := runApp("-vv", "validate", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "valid")
+}
+
+func TestVerbose_WrongKeyPrefix(t *testing.T) {
+	os.Setenv("STRIPE_SANDBOX_KEY", "sk_live_wrongprefix")
+	defer os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("-v", "apply", "--env", "sandbox", "--dry-run", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "sandbox environment requires a test key")
+}
+
+func TestVerbose_RedactsSecrets(t *testing.T) {
+	tests := map[string]string{
+		"key sk_test_51Habc123XYZ used":    "key sk_test_... used",
+		"restricted rk_live_9zzQQ":         "restricted rk_live_...",
+		"secret whsec_abcdef0123":          "secret whsec_...",
+		"Authorization: Bearer abc_123":    "Authorization: Bearer ...",
+		"price_1Habc and cus_123 are kept": "price_1Habc and cus_123 are kept",
+	}
+	for in, want := range tests {
+		if got := redact.Secrets(in); got != want {
+			t.Errorf("Secrets(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestVerbose_RequestLogRedactsKeys(t *testing.T) {
+	previous := stripeapi.DefaultLeveledLogger
+	t.Cleanup(func() { stripeapi.DefaultLeveledLogger = previous })
+
+	var buf bytes.Buffer
+	stripe.SetRequestLogging(&buf)
+	stripeapi.DefaultLeveledLogger.Infof("Requesting POST api.stripe.com/v1/products with key %s", "sk_live_51Hsecretvalue")
+	stripeapi.DefaultLeveledLogger.Errorf("Request failed for rk_test_%s", "restrictedvalue")
+
+	out := buf.String()
+	assertContains(t, out, "[stripe info] Requesting POST api.stripe.com/v1/products with key sk_live_...")
+	assertContains(t, out, "[stripe error] Request failed for rk_test_...")
+	if strings.Contains(out, "secretvalue") || strings.Contains(out, "restrictedvalue") {
+		t.Errorf("request log leaked a key: %s", out)
+	}
+}
+
+func TestVerbose_ProgressLines(t *testing.T) {
+	client := newFakeStripeClient(t, newFakeStripeCatalog(0, 0, 0))
+	var lines []string
+	client.SetLogger(func(format string, args ...any) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	})
+
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_meters.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Plans = nil
+	if _, err := client.Sync(context.Background(), cfg); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	assertContains(t, strings.Join(lines, "\n"), "meter 'api_calls': created meter mtr_new_000 for 'api_call' events")
+}
+
+func TestVersion_ShortFlag(t *testing.T) {
+	stdout, _, exitCode := runApp("-V")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "0.1.0")
+}
+
+// --- Summary flag tests ---
+
+func TestSummary_QuietValidate(t *testing.T) {
+	stdout, _, exitCode := runApp("--quiet", "--summary", "validate", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	if strings.Contains(stdout, "is valid") {
+		t.Error("expected quiet mode to suppress 'is valid' output")
+	}
+	if got := strings.TrimSpace(stdout); got != "command=validate status=ok file=testdata/valid/billing_minimal.yaml errors=0" {
+		t.Errorf("unexpected summary line: %q", got)
+	}
+}
+
+func TestSummary_QuietValidateInvalid(t *testing.T) {
+	stdout, _, exitCode := runApp("--quiet", "--summary", "validate", "testdata/invalid/billing_missing_name.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "status=invalid")
+}
+
+func TestSummary_JSON(t *testing.T) {
+	stdout, _, exitCode := runApp("--quiet", "--summary", "--summary-format", "json", "validate", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, `"status":"ok"`)
+	assertContains(t, stdout, `"errors":0`)
+}
+
+func TestSummary_NotPrintedByDefault(t *testing.T) {
+	stdout, _, exitCode := runApp("--quiet", "validate", "testdata/valid/billing_minimal.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	if stdout != "" {
+		t.Errorf("expected no output, got: %q", stdout)
+	}
+}
+
+// --- Init command tests ---
+
+func TestInit_CreatesDirectory(t *testing.T) {
+	// Create a temp directory
+	tmpDir, err := os.MkdirTemp("", "raterunner-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	stdout, _, exitCode := runApp("init", tmpDir)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Created")
+	assertContains(t, stdout, "billing.yaml")
+
+	// Verify file was created
+	billingPath := tmpDir + "/raterunner/billing.yaml"
+	if _, err := os.Stat(billingPath); os.IsNotExist(err) {
+		t.Errorf("expected billing.yaml to be created at %s", billingPath)
+	}
+}
+
+func TestInit_Templates(t *testing.T) {
+	templates := config.Templates()
+	if len(templates) != 4 {
+		t.Fatalf("expected 4 templates, got %v", templates)
+	}
+	for _, name := range templates {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			_, _, exitCode := runApp("init", "--template", name, dir)
+			assertExitCode(t, 0, exitCode)
+
+			// Every template must pass validate and lint as shipped
+			billingPath := config.InitFilePath(dir)
+			stdout, _, exitCode := runApp("validate", billingPath)
+			assertExitCode(t, 0, exitCode)
+			assertContains(t, stdout, "is valid")
+			stdout, _, exitCode = runApp("lint", "--strict", billingPath)
+			assertExitCode(t, 0, exitCode)
+			assertContains(t, stdout, "no lint warnings")
+		})
+	}
+}
+
+func TestInit_UnknownTemplate(t *testing.T) {
+	stdout, _, exitCode := runApp("init", "--template", "marketplace", t.TempDir())
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "unknown template 'marketplace' (available: lifetime-deal, open-core, saas-3-tier, usage-based)")
+}
+
+func TestPromosGenerate_ProductionBanner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/account") {
+			w.Write([]byte(`{"id":"acct_live","object":"account","settings":{"dashboard":{"display_name":"Acme Inc"}}}`))
+			return
+		}
+		http.Error(w, `{"error":{"message":"no such coupon: 'LAUNCH50'"}}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+	t.Setenv(stripe.APIURLEnv, server.URL)
+	t.Setenv("STRIPE_PRODUCTION_KEY", "sk_live_promos")
+	t.Setenv("HOME", t.TempDir())
+
+	_, stderr, exitCode := runApp("promos", "generate", "--env", "production", "--coupon", "LAUNCH50", "--count", "1")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stderr, "PRODUCTION  Stripe account acct_live")
+	assertContains(t, stderr, "promos generate creates live promotion codes")
+}
+
+func TestPromosGenerate_DryRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "codes.csv")
+
+	stdout, _, exitCode := runApp("promos", "generate", "--coupon", "LAUNCH50", "--count", "500", "--prefix", "LNCH-", "--expires", "2099-12-31", "--out", out, "--dry-run")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Would create 500 single-use promotion code(s) against coupon 'LAUNCH50'")
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 501 {
+		t.Fatalf("expected a header and 500 codes, got %d rows", len(records))
+	}
+	seen := make(map[string]bool)
+	for _, record := range records[1:] {
+		code := record[0]
+		if !strings.HasPrefix(code, "LNCH-") || len(code) != len("LNCH-")+8 {
+			t.Errorf("unexpected code %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+		if record[2] != "LAUNCH50" || record[3] != "2099-12-31" {
+			t.Errorf("unexpected row %v", record)
+		}
+	}
+}
+
+func TestPromosGenerate_MissingAPIKey(t *testing.T) {
+	os.Unsetenv("STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode := runApp("promos", "generate", "--env", "sandbox", "--coupon", "LAUNCH50", "--count", "5")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "STRIPE_SANDBOX_KEY")
+
+	stdout, _, exitCode = runApp("promos", "generate", "--coupon", "LAUNCH50", "--count", "5", "--prefix", "LAUNCH 50", "--dry-run")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "invalid prefix 'LAUNCH 50'")
+}
+
+func TestPlansCopy(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/billing_full.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billingPath := filepath.Join(t.TempDir(), "billing.yaml")
+	if err := os.WriteFile(billingPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("plans", "copy", "--price-multiplier", "0.9", "pro", "pro_eu", billingPath)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Copied plan 'pro' to 'pro_eu' in "+billingPath+" (line 38)")
+	assertContains(t, stdout, "prices/monthly/amount: 2900 -> 2610")
+	assertContains(t, stdout, "prices/yearly/amount: 29000 -> 26100")
+
+	copied, err := os.ReadFile(billingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The original plan and the rest of the file are untouched
+	if !strings.HasPrefix(string(copied), string(content[:strings.Index(string(content), "addons:")-1])) {
+		t.Errorf("original plans changed:\n%s", copied)
+	}
+	assertContains(t, string(copied), "  - id: pro_eu\n    name: Pro Plan\n")
+	assertContains(t, string(copied), "monthly: {amount: 2610}")
+	_, _, exitCode = runApp("validate", billingPath)
+	assertExitCode(t, 0, exitCode)
+
+	stdout, _, exitCode = runApp("plans", "copy", "free", "pro_eu", billingPath)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "plan 'pro_eu' already exists")
+
+	stdout, _, exitCode = runApp("plans", "copy", "enterprise", "enterprise_eu", billingPath)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "plan 'enterprise' not found")
+}
+
+func TestPricesLocalize(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/billing_currency_prices.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	billingPath := filepath.Join(dir, "billing.yaml")
+	ratesPath := filepath.Join(dir, "rates.json")
+	if err := os.WriteFile(billingPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ratesPath, []byte(`{"base": "USD", "rates": {"EUR": 0.92, "GBP": 0.79}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, exitCode := runApp("prices", "localize", "--currencies", "eur,gbp", "--rates", ratesPath, "--rounding", "psychological", billingPath)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "pro monthly eur: 2699 (new)")
+	assertContains(t, stdout, "team monthly eur: 9099 (was 9500)")
+	assertContains(t, stdout, "Localized 8 price(s) from usd")
+	assertContains(t, stderr, "note: skipped usage monthly (per_unit)")
+
+	localized, err := os.ReadFile(billingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flow prices stay flow, block prices get block entries, comments are kept
+	assertContains(t, string(localized), "monthly: { amount: 2900, currency_prices: { eur: 2699, gbp: 2299 } }")
+	assertContains(t, string(localized), "amount: 29000 # two months free\n        currency_prices:\n          eur: 26699\n          gbp: 22899\n")
+	assertContains(t, string(localized), "          eur: 9099\n          jpy: 15000\n          gbp: 7799\n")
+	assertContains(t, string(localized), "monthly: { amount: 0 }\n")
+	_, _, exitCode = runApp("validate", billingPath)
+	assertExitCode(t, 0, exitCode)
+
+	stdout, _, exitCode = runApp("prices", "localize", "--currencies", "eur,gbp", "--rates", ratesPath, "--rounding", "psychological", billingPath)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "All 8 price(s) are already up to date")
+
+	stdout, _, exitCode = runApp("prices", "localize", "--currencies", "chf", "--rates", ratesPath, billingPath)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "no exchange rate for chf")
+}
+
+func TestConvertAmount(t *testing.T) {
+	tests := []struct {
+		to       string
+		rate     float64
+		rounding string
+		want     int
+	}{
+		{"eur", 0.92, config.RoundingNone, 2668},
+		{"eur", 0.92, config.RoundingWhole, 2700},
+		{"eur", 0.92, config.RoundingPsychological, 2699},
+		{"jpy", 150.5, config.RoundingNone, 4365},
+		{"jpy", 150.5, config.RoundingWhole, 4400},
+		{"jpy", 150.5, config.RoundingPsychological, 4399},
+	}
+	for _, tt := range tests {
+		if got := config.ConvertAmount(2900, "usd", tt.to, tt.rate, tt.rounding); got != tt.want {
+			t.Errorf("ConvertAmount(2900, usd, %s, %g, %s) = %d, want %d", tt.to, tt.rate, tt.rounding, got, tt.want)
+		}
+	}
+}
+
+func TestRefactor_RenameEntitlement(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/billing_addon_grants.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	billingPath := filepath.Join(dir, "billing.yaml")
+	exportPath := filepath.Join(dir, "export.json")
+	if err := os.WriteFile(billingPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, exitCode := runApp("export", "-o", exportPath, billingPath); exitCode != 0 {
+		t.Fatalf("export failed with exit code %d", exitCode)
+	}
+
+	stdout, _, exitCode := runApp("refactor", "rename-entitlement", "--export", exportPath, "seats", "members", billingPath)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Renamed entitlement 'seats' to 'members' in "+billingPath)
+	assertContains(t, stdout, "/entitlements/members (line 7)")
+	assertContains(t, stdout, "/plans/0/limits/members (line 18)")
+	assertContains(t, stdout, "/addons/1/grants/members (line 32)")
+	assertContains(t, stdout, "Renamed entitlement 'seats' to 'members' in "+exportPath)
+
+	renamed, err := os.ReadFile(billingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the keys change; comments and flow mappings are kept
+	want := strings.ReplaceAll(string(content), "seats:", "members:")
+	if string(renamed) != want {
+		t.Errorf("renamed config:\n%s\nwant:\n%s", renamed, want)
+	}
+	_, _, exitCode = runApp("validate", billingPath)
+	assertExitCode(t, 0, exitCode)
+
+	exported, err := os.ReadFile(exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(exported), `"seats"`) || strings.Count(string(exported), `"members"`) != 3 {
+		t.Errorf("export not renamed:\n%s", exported)
+	}
+}
+
+func TestRefactor_RenameEntitlement_Conflicts(t *testing.T) {
+	content, err := os.ReadFile("testdata/valid/billing_addon_grants.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billingPath := filepath.Join(t.TempDir(), "billing.yaml")
+	if err := os.WriteFile(billingPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("refactor", "rename-entitlement", "seats", "projects", billingPath)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "entitlement 'projects' already exists at /entitlements/projects")
+
+	stdout, _, exitCode = runApp("refactor", "rename-entitlement", "storage", "disk", billingPath)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "entitlement 'storage' is not used")
+
+	stdout, _, exitCode = runApp("refactor", "rename-entitlement", "--dry-run", "sso", "single_sign_on", billingPath)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Would rename entitlement 'sso' to 'single_sign_on'")
+
+	unchanged, err := os.ReadFile(billingPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(unchanged) != string(content) {
+		t.Error("billing file changed by a failed or dry-run rename")
+	}
+}
+
+func TestInit_RefusesOverwrite(t *testing.T) {
+	// Create a temp directory with existing billing.yaml
+	tmpDir, err := os.MkdirTemp("", "raterunner-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Create existing file
+	raterunnerDir := tmpDir + "/raterunner"
+	if err := os.MkdirAll(raterunnerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(raterunnerDir+"/billing.yaml", []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("init", tmpDir)
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "already exists")
+}
+
+func TestInit_ForceOverwrite(t *testing.T) {
+	// Create a temp directory with existing billing.yaml
+	tmpDir, err := os.MkdirTemp("", "raterunner-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Create existing file
+	raterunnerDir := tmpDir + "/raterunner"
+	if err := os.MkdirAll(raterunnerDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(raterunnerDir+"/billing.yaml", []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode := runApp("init", "--force", tmpDir)
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Created")
+
+	// Verify file was overwritten (content should be different)
+	content, err := os.ReadFile(raterunnerDir + "/billing.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) == "existing" {
+		t.Error("expected file to be overwritten")
+	}
+}
+
+func TestValidate_ProviderSandboxFile(t *testing.T) {
+	stdout, _, exitCode := runApp("validate", "testdata/valid/stripe_sandbox.yaml")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "is valid")
+}
+
+// --- Stripe pagination tests ---
+
+// fakeStripeCatalog serves products, prices, coupons and meters from memory,
+// in pages of at most the requested limit like the Stripe API
+type fakeStripeCatalog struct {
+	products, prices, coupons, meters []map[string]any
+}
+
+func newFakeStripeCatalog(products, pricesOnFirst, coupons int) *fakeStripeCatalog {
+	f := &fakeStripeCatalog{}
+	for i := range products {
+		id := fmt.Sprintf("prod_%03d", i)
+		f.products = append(f.products, map[string]any{"id": id, "object": "product", "name": fmt.Sprintf("Plan %d", i), "active": true})
+		n := 1
+		if i == 0 {
+			n = pricesOnFirst
+		}
+		for j := range n {
+			f.prices = append(f.prices, map[string]any{"id": fmt.Sprintf("price_%03d_%03d", i, j), "object": "price", "product": id, "unit_amount": 100 + j, "currency": "usd", "active": true})
+		}
+	}
+	for i := range coupons {
+		f.coupons = append(f.coupons, map[string]any{"id": fmt.Sprintf("coupon_%03d", i), "object": "coupon"})
+	}
+	return f
+}
+
+func (f *fakeStripeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	path := strings.Replace(strings.TrimPrefix(r.URL.Path, "/v1/"), "billing/meters", "billing_meters", 1)
+	parts := strings.Split(path, "/")
+	var objects *[]map[string]any
+	switch parts[0] {
+	case "products":
+		objects = &f.products
+	case "prices":
+		objects = &f.prices
+	case "coupons":
+		objects = &f.coupons
+	case "billing_meters":
+		objects = &f.meters
+	default:
+		http.Error(w, "unexpected request", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 && r.Method == http.MethodPost && parts[0] == "billing_meters" {
+		meter := map[string]any{
+			"id":                  fmt.Sprintf("mtr_new_%03d", len(f.meters)),
+			"object":              "billing.meter",
+			"display_name":        r.Form.Get("display_name"),
+			"event_name":          r.Form.Get("event_name"),
+			"default_aggregation": map[string]any{"formula": r.Form.Get("default_aggregation[formula]")},
+			"status":              "active",
+		}
+		f.meters = append(f.meters, meter)
+		json.NewEncoder(w).Encode(meter)
+		return
+	}
+
+	if len(parts) == 1 {
+		var matching []map[string]any
+		for _, o := range *objects {
+			if active := r.Form.Get("active"); active != "" && fmt.Sprint(o["active"]) != active {
+				continue
+			}
+			if product := r.Form.Get("product"); product != "" && o["product"] != product {
+				continue
+			}
+			matching = append(matching, o)
+		}
+		f.writePage(w, r, matching)
+		return
+	}
+
+	for i, o := range *objects {
+		if o["id"] != parts[1] {
+			continue
+		}
+		switch r.Method {
+		case http.MethodDelete:
+			*objects = append((*objects)[:i], (*objects)[i+1:]...)
+			json.NewEncoder(w).Encode(map[string]any{"id": parts[1], "deleted": true})
+		case http.MethodPost:
+			if active := r.Form.Get("active"); active != "" {
+				o["active"] = active == "true"
+			}
+			if name := r.Form.Get("display_name"); name != "" {
+				o["display_name"] = name
+			}
+			for key := range r.Form {
+				if field, ok := strings.CutPrefix(key, "metadata["); ok {
+					meta, _ := o["metadata"].(map[string]any)
+					if meta == nil {
+						meta = map[string]any{}
+						o["metadata"] = meta
+					}
+					meta[strings.TrimSuffix(field, "]")] = r.Form.Get(key)
+				}
+			}
+			if len(parts) == 3 && parts[2] == "reactivate" {
+				o["status"] = "active"
+			}
+			json.NewEncoder(w).Encode(o)
+		default:
+			json.NewEncoder(w).Encode(o)
+		}
+		return
+	}
+	writeStripeMissing(w, parts[1])
+}
+
+// writePage writes the page of objects after the starting_after cursor, which
+// like in Stripe must still be in the list
+func (f *fakeStripeCatalog) writePage(w http.ResponseWriter, r *http.Request, objects []map[string]any) {
+	limit, _ := strconv.Atoi(r.Form.Get("limit"))
+	if limit == 0 {
+		limit = 10
+	}
+	start := 0
+	if after := r.Form.Get("starting_after"); after != "" {
+		start = -1
+		for i, o := range objects {
+			if o["id"] == after {
+				start = i + 1
+			}
+		}
+		if start < 0 {
+			writeStripeMissing(w, after)
+			return
+		}
+	}
+	end := min(start+limit, len(objects))
+	json.NewEncoder(w).Encode(map[string]any{
+		"object":   "list",
+		"url":      r.URL.Path,
+		"data":     append([]map[string]any{}, objects[start:end]...),
+		"has_more": end < len(objects),
+	})
+}
+
+func writeStripeMissing(w http.ResponseWriter, id string) {
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+		"type":    "invalid_request_error",
+		"code":    "resource_missing",
+		"message": "No such object: '" + id + "'",
+	}})
+}
+
+func newFakeStripeClient(t *testing.T, catalog *fakeStripeCatalog) *stripe.Client {
+	server := httptest.NewServer(catalog)
+	t.Cleanup(server.Close)
+	t.Setenv(stripe.APIURLEnv, server.URL)
+
+	client, err := stripe.NewClient(stripe.Sandbox, "sk_test_fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestStripe_FetchListsEveryPage(t *testing.T) {
+	client := newFakeStripeClient(t, newFakeStripeCatalog(250, 120, 0))
+
+	products, err := client.FetchProductsWithPrices(context.Background())
+	if err != nil {
+		t.Fatalf("failed to fetch: %v", err)
+	}
+	if len(products) != 250 {
+		t.Fatalf("got %d products, want 250", len(products))
+	}
+	if len(products[0].Prices) != 120 || len(products[249].Prices) != 1 {
+		t.Errorf("got %d and %d prices, want 120 and 1", len(products[0].Prices), len(products[249].Prices))
+	}
+}
+
+func TestStripe_TruncateEveryPage(t *testing.T) {
+	catalog := newFakeStripeCatalog(150, 120, 130)
+	client := newFakeStripeClient(t, catalog)
+
+	result, err := client.Truncate(context.Background(), stripe.TruncateOptions{})
+	if err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+	if result.ProductsArchived != 150 || result.PricesArchived != 269 || result.CouponsDeleted != 130 {
+		t.Errorf("got %+v, want 150 products, 269 prices and 130 coupons", result)
+	}
+	if len(catalog.coupons) != 0 {
+		t.Errorf("%d coupons left", len(catalog.coupons))
+	}
+	for _, p := range catalog.products {
+		if p["active"] != false {
+			t.Errorf("product %s still active", p["id"])
+		}
+	}
+}
+
+func TestStripe_SyncMeters(t *testing.T) {
+	catalog := newFakeStripeCatalog(0, 0, 0)
+	catalog.meters = []map[string]any{
+		{"id": "mtr_calls", "object": "billing.meter", "display_name": "Calls", "event_name": "api_call", "default_aggregation": map[string]any{"formula": "sum"}, "status": "inactive"},
+		{"id": "mtr_seats", "object": "billing.meter", "display_name": "Seats", "event_name": "seat", "default_aggregation": map[string]any{"formula": "count"}, "status": "active"},
+	}
+	client := newFakeStripeClient(t, catalog)
+
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_meters.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Plans = nil
+	cfg.Meters["seats"] = config.Meter{DisplayName: "Seats", EventName: "seat"}
+
+	result, err := client.Sync(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if result.MetersCreated != 1 {
+		t.Errorf("got %d meters created, want 1", result.MetersCreated)
+	}
+	want := map[string]string{"api_calls": "mtr_calls", "seats": "mtr_seats", "storage_gb": "mtr_new_002"}
+	for key, id := range want {
+		if result.MeterIDs[key] != id {
+			t.Errorf("meter '%s': got ID %q, want %q", key, result.MeterIDs[key], id)
+		}
+	}
+	if calls := catalog.meters[0]; calls["status"] != "active" || calls["display_name"] != "API calls" {
+		t.Errorf("meter not reactivated and renamed: %+v", calls)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != stripe.WarnMeterDiffers || result.Warnings[0].Meter != "seats" {
+		t.Errorf("expected a meter_differs warning for 'seats', got %+v", result.Warnings)
+	}
+
+	diffResult := diff.Compare(cfg, nil, "sandbox")
+	meters, err := client.FetchMeters(context.Background())
+	if err != nil {
+		t.Fatalf("failed to fetch meters: %v", err)
+	}
+	diff.AddMeters(diffResult, cfg, meters)
+	if diffResult.Summary.MetersMissing != 0 || diffResult.Summary.MetersDiffer != 1 {
+		t.Errorf("expected only 'seats' to differ, got %+v", diffResult.Meters)
+	}
+}
+
+func TestStripe_SyncDunningMetadata(t *testing.T) {
+	catalog := newFakeStripeCatalog(1, 1, 0)
+	catalog.products[0]["name"] = "Pro"
+	catalog.products[0]["metadata"] = map[string]any{"plan_code": "pro", "dunning_emails": "payment_failed"}
+	catalog.prices[0]["unit_amount"] = 2900
+	catalog.prices[0]["recurring"] = map[string]any{"interval": "month", "interval_count": 1}
+	client := newFakeStripeClient(t, catalog)
+
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_dunning.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if _, err := client.Sync(context.Background(), cfg); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	meta := catalog.products[0]["metadata"].(map[string]any)
+	want := map[string]string{
+		"dunning_retry_days":            "3,5,7",
+		"dunning_cancel_after_failures": "4",
+		"dunning_emails":                "payment_failed,expiring_card",
+	}
+	for key, value := range want {
+		if meta[key] != value {
+			t.Errorf("metadata %s = %v, want %q", key, meta[key], value)
+		}
+	}
+}
+
+func TestStripe_SyncTrialMetadata(t *testing.T) {
+	catalog := newFakeStripeCatalog(1, 1, 0)
+	catalog.products[0]["name"] = "Team"
+	catalog.products[0]["metadata"] = map[string]any{
+		"plan_code":                    "team",
+		"trial_require_payment_method": "false",
+		"trial_end_behavior":           "downgrade",
+		"trial_downgrade_to":           "free",
+	}
+	catalog.prices[0]["unit_amount"] = 9900
+	catalog.prices[0]["recurring"] = map[string]any{"interval": "month", "interval_count": 1}
+	client := newFakeStripeClient(t, catalog)
+
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_trial.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Providers = []string{"stripe"}
+	cfg.Plans = []config.Plan{*cfg.FindPlan("team")}
+	if _, err := client.Sync(context.Background(), cfg); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	meta := catalog.products[0]["metadata"].(map[string]any)
+	if meta["trial_require_payment_method"] != "true" || meta["trial_end_behavior"] != "cancel" {
+		t.Errorf("expected the plan's own trial policy, got %+v", meta)
+	}
+	if downgrade, _ := meta["trial_downgrade_to"].(string); downgrade != "" {
+		t.Errorf("expected trial_downgrade_to to be removed, got %q", downgrade)
+	}
+}
+
+func TestStripe_SyncCollectionMethodMetadata(t *testing.T) {
+	catalog := newFakeStripeCatalog(1, 1, 0)
+	catalog.products[0]["name"] = "Enterprise"
+	catalog.products[0]["metadata"] = map[string]any{"plan_code": "enterprise"}
+	catalog.prices[0]["unit_amount"] = 990000
+	catalog.prices[0]["recurring"] = map[string]any{"interval": "year", "interval_count": 1}
+	client := newFakeStripeClient(t, catalog)
+
+	cfg, err := config.LoadBillingFile("testdata/valid/billing_invoice.yaml")
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	cfg.Plans = []config.Plan{*cfg.FindPlan("enterprise")}
+	if _, err := client.Sync(context.Background(), cfg); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	meta := catalog.products[0]["metadata"].(map[string]any)
+	if meta["collection_method"] != "send_invoice" || meta["days_until_due"] != "14" {
+		t.Errorf("expected send_invoice metadata due in 14 days, got %+v", meta)
+	}
+}
+
+func TestStripe_ImportMeteredPrices(t *testing.T) {
+	catalog := newFakeStripeCatalog(1, 1, 0)
+	catalog.prices[0]["recurring"] = map[string]any{"interval": "month", "interval_count": 1, "usage_type": "metered", "meter": "mtr_calls"}
+	catalog.meters = []map[string]any{
+		{"id": "mtr_calls", "object": "billing.meter", "display_name": "API calls", "event_name": "API-Call", "default_aggregation": map[string]any{"formula": "sum"}, "status": "active"},
+		{"id": "mtr_old", "object": "billing.meter", "display_name": "Old", "event_name": "old", "default_aggregation": map[string]any{"formula": "sum"}, "status": "inactive"},
+	}
+	client := newFakeStripeClient(t, catalog)
+
+	result, err := client.Import(context.Background())
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if len(result.Billing.Meters) != 1 || result.Billing.Meters["api_call"].EventName != "API-Call" {
+		t.Fatalf("expected meter 'api_call', got %+v", result.Billing.Meters)
+	}
+	if result.Provider.Meters["api_call"] != "mtr_calls" {
+		t.Errorf("expected meter ID in provider file, got %+v", result.Provider.Meters)
+	}
+	price := result.Billing.Plans[0].Prices["monthly"]
+	if price.Meter != "api_call" || price.PerUnit != 100 {
+		t.Errorf("expected a metered per-unit price, got %+v", price)
+	}
+}
+
+// --- Test helpers ---
+
+func assertExitCode(t *testing.T, expected, actual int) {
+	t.Helper()
+	if actual != expected {
+		t.Errorf("expected exit code %d, got %d", expected, actual)
+	}
+}
+
+func assertContains(t *testing.T, s, substr string) {
+	t.Helper()
+	if !strings.Contains(s, substr) {
+		t.Errorf("expected output to contain %q, got: %s", substr, s)
+	}
 }
 
 // --- Verify testdata files exist ---
 
+// fakePluginScript answers the plugin protocol with an empty catalog and one
+// synced plan, and records the last sync request
+const fakePluginScript = `#!/bin/sh
+case "$1" in
+catalog) cat >/dev/null; echo '{"products": []}' ;;
+sync) cat >"$(dirname "$0")/last_sync.json"; echo '{"products_created": 1, "prices_created": 1, "plans": {"free": {"product_id": "fk_1", "prices": {"monthly": "fkp_1"}}}}' ;;
+*) cat >/dev/null; echo '{"error": "unsupported method"}'; exit 1 ;;
+esac
+`
+
+// setupPluginProject installs the fake plugin as x-fake and writes a billing
+// config targeting it, returning the billing file and the plugin directory
+func setupPluginProject(t *testing.T) (string, string) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	pluginDir := t.TempDir()
+	t.Setenv(provider.PluginDirEnv, pluginDir)
+	if err := os.WriteFile(filepath.Join(pluginDir, "raterunner-provider-fake"), []byte(fakePluginScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Not executable, so not a plugin
+	if err := os.WriteFile(filepath.Join(pluginDir, "raterunner-provider-notes"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	billing := filepath.Join(t.TempDir(), "billing.yaml")
+	content := "version: 1\nproviders: [x-fake]\nplans:\n  - id: free\n    name: Free\n    prices:\n      monthly: { amount: 0 }\n"
+	if err := os.WriteFile(billing, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return billing, pluginDir
+}
+
+func TestPlugins_List(t *testing.T) {
+	_, pluginDir := setupPluginProject(t)
+
+	stdout, _, exitCode := runApp("plugins", "list")
+
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "x-fake")
+	if strings.Contains(stdout, "x-notes") {
+		t.Errorf("non-executable file listed as a plugin:\n%s", stdout)
+	}
+	assertContains(t, stdout, filepath.Join(pluginDir, "raterunner-provider-fake"))
+}
+
+func TestApply_PluginProvider(t *testing.T) {
+	billing, pluginDir := setupPluginProject(t)
+
+	// Without --provider, apply still needs Stripe
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "use --provider")
+
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--dry-run", "--provider", "x-fake", billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "Not in Stripe")
+
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--provider", "x-fake", billing)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Products: 1 created")
+
+	request, err := os.ReadFile(filepath.Join(pluginDir, "last_sync.json"))
+	if err != nil {
+		t.Fatalf("plugin didn't receive a sync request: %v", err)
+	}
+	assertContains(t, string(request), `"environment":"sandbox"`)
+	assertContains(t, string(request), `"id":"free"`)
+
+	providerCfg, err := config.LoadProviderFile(filepath.Join(filepath.Dir(billing), "raterunner", "x-fake_sandbox.yaml"))
+	if err != nil {
+		t.Fatalf("failed to load provider file: %v", err)
+	}
+	if providerCfg.Provider != "x-fake" || providerCfg.Plans["free"].Prices["monthly"] != "fkp_1" {
+		t.Errorf("provider file = %+v", providerCfg)
+	}
+
+	// Applied unchanged: the plugin isn't called again
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--provider", "x-fake", billing)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "nothing to do")
+
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--provider", "x-missing", billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "no plan in the billing config targets provider 'x-missing'")
+}
+
+func TestDiff_Stabilize(t *testing.T) {
+	cfg, err := config.LoadBilling([]byte(`version: 1
+providers: [stripe]
+plans:
+  - id: zeta
+    name: Zeta
+    prices:
+      yearly: { amount: 10000 }
+      monthly: { amount: 1000 }
+  - id: alpha
+    name: Alpha
+    prices:
+      monthly: { amount: 0 }
+`), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := []stripe.Product{{ID: "prod_1", PlanCode: "zeta", Active: true}}
+
+	result := diff.Compare(cfg, products, "sandbox")
+	result.Stabilize()
+
+	if result.Plans[0].PlanID != "alpha" || result.Plans[1].PlanID != "zeta" {
+		t.Errorf("plans = %s, %s; want alpha, zeta", result.Plans[0].PlanID, result.Plans[1].PlanID)
+	}
+	if got := result.Plans[1].Details; got != "monthly: missing in Stripe, yearly: missing in Stripe" {
+		t.Errorf("details = %q", got)
+	}
+
+	var table, jsonOut bytes.Buffer
+	diff.OutputTable(&table, result)
+	if err := diff.OutputJSON(&jsonOut, result); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(table.String(), "Compared at") || strings.Contains(jsonOut.String(), "compared_at") {
+		t.Errorf("deterministic output has a timestamp:\n%s\n%s", table.String(), jsonOut.String())
+	}
+}
+
+func TestApply_PluginDeterministic(t *testing.T) {
+	billing, _ := setupPluginProject(t)
+
+	stdout, _, exitCode := runApp("--deterministic", "apply", "--env", "sandbox", "--provider", "x-fake", billing)
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Saved provider IDs")
+
+	content, err := os.ReadFile(filepath.Join(filepath.Dir(billing), "raterunner", "x-fake_sandbox.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), "synced_at") {
+		t.Errorf("deterministic provider file has synced_at:\n%s", content)
+	}
+}
+
+func TestTelemetry_OptIn(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("DO_NOT_TRACK", "")
+
+	var events []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid event: %v", err)
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	stdout, _, exitCode := runApp("telemetry", "status")
+	assertExitCode(t, 0, exitCode)
+	assertContains(t, stdout, "Telemetry: off")
+
+	// Off by default: nothing is sent
+	runApp("config", "set", "telemetry_endpoint", server.URL)
+	runApp("hash", "testdata/valid/billing_full.yaml")
+	if len(events) != 0 {
+		t.Fatalf("events sent before opting in: %v", events)
+	}
+
+	runApp("config", "set", "telemetry", "true")
+	events = nil
+	stdout, _, exitCode = runApp("hash", "testdata/valid/billing_full.yaml")
+	assertExitCode(t, 0, exitCode)
+	runApp("validate", "testdata/does-not-exist.yaml")
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %v", len(events), events)
+	}
+	if events[0]["command"] != "hash" || events[0]["status"] != "ok" || len(events[0]["install_id"].(string)) != 32 {
+		t.Errorf("hash event = %v", events[0])
+	}
+	if events[1]["command"] != "validate" || events[1]["status"] != "error" || events[1]["error_class"] == "" {
+		t.Errorf("validate event = %v", events[1])
+	}
+	data, _ := json.Marshal(events)
+	if strings.Contains(string(data), "billing_full") || strings.Contains(string(data), "does-not-exist") {
+		t.Errorf("events leak arguments: %s", data)
+	}
+
+	stdout, _, _ = runApp("telemetry", "status")
+	assertContains(t, stdout, "Telemetry: on")
+	assertContains(t, stdout, server.URL)
+
+	t.Setenv("DO_NOT_TRACK", "1")
+	events = nil
+	runApp("hash", "testdata/valid/billing_full.yaml")
+	stdout, _, _ = runApp("telemetry", "status")
+	assertContains(t, stdout, "DO_NOT_TRACK")
+	if len(events) != 0 {
+		t.Errorf("events sent with DO_NOT_TRACK: %v", events)
+	}
+
+	t.Setenv("DO_NOT_TRACK", "")
+	runApp("config", "set", "telemetry", "false")
+	settings, err := config.LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Telemetry || settings.TelemetryID != "" {
+		t.Errorf("opting out kept telemetry settings: %+v", settings)
+	}
+}
+
+// recordingTB collects assertion failures instead of failing the test
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestTestkit_Assertions(t *testing.T) {
+	cfg := testkit.Load(t, "testdata/valid/billing_full.yaml")
+	cfg.AssertGrants("pro", "sso")
+	cfg.AssertGrants("free", "api_requests")
+	cfg.AssertDenies("free", "sso")
+	cfg.AssertLimit("pro", "projects", 25)
+	cfg.AssertPrice("pro", "yearly", 29000)
+
+	rec := &recordingTB{TB: t}
+	failing := testkit.Load(rec, "testdata/valid/billing_full.yaml")
+	failing.AssertGrants("free", "sso")
+	failing.AssertDenies("pro", "projects")
+	failing.AssertLimit("free", "projects", testkit.Unlimited)
+	failing.AssertPrice("pro", "monthly", 1900)
+	failing.AssertPrice("free", "yearly", 0)
+
+	want := []string{
+		`plan "free" does not grant "sso" (got false)`,
+		`plan "pro" grants "projects" (got 25)`,
+		`plan "free": projects = 3, want unlimited`,
+		`plan "pro": monthly price = 2900, want 1900`,
+		`plan "free" has no yearly price`,
+	}
+	if strings.Join(rec.errors, "\n") != strings.Join(want, "\n") {
+		t.Errorf("failures = %q, want %q", rec.errors, want)
+	}
+}
+
+func TestTestkit_BuilderAndFakeProvider(t *testing.T) {
+	cfg := testkit.NewConfig().
+		Entitlement("seats", "int").
+		Entitlement("sso", "bool").
+		Plan("free", "Free", testkit.Monthly(0), testkit.Limit("seats", 1), testkit.Limit("sso", false)).
+		Plan("team", "Team", testkit.Monthly(4900), testkit.Yearly(49000), testkit.Limit("seats", testkit.Unlimited), testkit.Limit("sso", true)).
+		Build(t)
+	cfg.AssertLimit("team", "seats", testkit.Unlimited)
+	cfg.AssertGrants("team", "seats")
+	cfg.AssertDenies("free", "sso")
+
+	fake := &testkit.FakeProvider{}
+	golden := filepath.Join(t.TempDir(), "diff.golden")
+	t.Setenv(testkit.UpdateGoldenEnv, "1")
+	cfg.AssertDiffGolden(fake, golden)
+	t.Setenv(testkit.UpdateGoldenEnv, "")
+	before, _ := os.ReadFile(golden)
+	assertContains(t, string(before), "Not in Stripe")
+
+	result, err := fake.Sync(context.Background(), cfg.Billing())
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if result.ProductsCreated != 2 || result.PricesCreated != 3 {
+		t.Errorf("Sync() created %d products and %d prices, want 2 and 3", result.ProductsCreated, result.PricesCreated)
+	}
+	diffResult, _, err := fake.Diff(context.Background(), cfg.Billing())
+	if err != nil {
+		t.Fatalf("Diff() error: %v", err)
+	}
+	if diffResult.HasDifferences() {
+		t.Errorf("Diff() after Sync() has differences: %+v", diffResult.Plans)
+	}
+
+	rec := &recordingTB{TB: t}
+	testkit.AssertGolden(rec, golden, []byte("changed"))
+	if len(rec.errors) != 1 {
+		t.Errorf("AssertGolden() failures = %q, want one", rec.errors)
+	}
+}
+
 func TestTestdataFilesExist(t *testing.T) {
 	files := []string{
 		"testdata/valid/billing_minimal.yaml",
@@ -585,6 +5545,58 @@ func TestTestdataFilesExist(t *testing.T) {
 		"testdata/valid/billing_minimal.json",
 		"testdata/valid/billing_optional_field.yaml",
 		"testdata/valid/billing_onetime.yaml",
+		"testdata/valid/billing_rate_month.yaml",
+		"testdata/valid/billing_automatic_tax.yaml",
+		"testdata/valid/billing_grace_days.yaml",
+		"testdata/valid/billing_trial.yaml",
+		"testdata/valid/billing_unsynced_free.yaml",
+		"testdata/valid/billing_custom_pricing.yaml",
+		"testdata/valid/billing_addon_grants.yaml",
+		"testdata/valid/billing_promotion_stacking.yaml",
+		"testdata/valid/billing_pricing_lint.yaml",
+		"testdata/valid/billing_feature_text.yaml",
+		"testdata/valid/billing_copy_lint.yaml",
+		"testdata/valid/billing_suppressions.yaml",
+		"testdata/valid/billing_plan_rename.yaml",
+		"testdata/invalid/billing_plan_rename_conflict.yaml",
+		"testdata/valid/billing_metadata_prefix.yaml",
+		"testdata/invalid/billing_bad_metadata_prefix.yaml",
+		"testdata/valid/billing_metadata_mapping.yaml",
+		"testdata/invalid/billing_metadata_mapping_conflict.yaml",
+		"testdata/invalid/billing_metadata_mapping_omit_plan_code.yaml",
+		"testdata/valid/billing_provisioning.yaml",
+		"testdata/invalid/billing_provisioning_key_too_long.yaml",
+		"testdata/invalid/billing_promotion_bad_exclude.yaml",
+		"testdata/invalid/billing_bad_grant.yaml",
+		"testdata/valid/billing_credits.yaml",
+		"testdata/invalid/billing_bad_credit_pack.yaml",
+		"testdata/valid/billing_meters.yaml",
+		"testdata/invalid/billing_bad_meter.yaml",
+		"testdata/valid/billing_dunning.yaml",
+		"testdata/invalid/billing_bad_dunning.yaml",
+		"testdata/valid/billing_invoice.yaml",
+		"testdata/invalid/billing_bad_invoice.yaml",
+		"testdata/valid/billing_manual_invoicing.yaml",
+		"testdata/invalid/billing_bad_manual_invoicing.yaml",
+		"testdata/invalid/billing_custom_pricing_with_prices.yaml",
+		"testdata/invalid/billing_missing_prices.yaml",
+		"testdata/invalid/billing_trial_bad_downgrade.yaml",
+		"testdata/invalid/billing_trial_missing_downgrade.yaml",
+		"testdata/invalid/billing_bad_tax_code.yaml",
+		"testdata/valid/billing_environments.yaml",
+		"testdata/invalid/billing_bad_environment.yaml",
+		"testdata/valid/billing_promotion_window.yaml",
+		"testdata/invalid/billing_promotion_bad_window.yaml",
+		"testdata/valid/billing_schema_v1.yaml",
+		"testdata/valid/billing_schema_future.yaml",
+		"testdata/invalid/billing_schema_v1_new_field.yaml",
+		"testdata/valid/billing_currency_prices.yaml",
+		"testdata/valid/billing_hidden_plan.yaml",
+		"testdata/valid/billing_upgrade_paths.yaml",
+		"testdata/invalid/billing_upgrade_cycle.yaml",
+		"testdata/invalid/billing_downgrade_conflict.yaml",
+		"testdata/valid/stripe_pricing_notes.yaml",
+		"testdata/valid/ids_sandbox.yaml",
 		"testdata/valid/provider_stripe.yaml",
 		"testdata/valid/stripe_sandbox.yaml",
 		"testdata/invalid/billing_missing_name.yaml",
@@ -594,9 +5606,26 @@ func TestTestdataFilesExist(t *testing.T) {
 		"testdata/invalid/billing_undefined_entitlement_addon.yaml",
 		"testdata/invalid/billing_unsupported_provider.yaml",
 		"testdata/invalid/billing_onetime_wrong_interval.yaml",
+		"testdata/invalid/billing_rate_limit_mismatch.yaml",
+		"testdata/invalid/billing_unlimited_bool.yaml",
+		"testdata/invalid/billing_multiple_defaults.yaml",
+		"testdata/invalid/billing_no_public_plans.yaml",
+		"testdata/invalid/billing_negative_amount.yaml",
+		"testdata/schemas/split/billing.schema.json",
 		"testdata/invalid/provider_unknown.yaml",
+		"testdata/invalid/provider_bad_ids.yaml",
 		"testdata/errors/malformed.yaml",
 		"testdata/apply/billing_paddle.yaml",
+		"testdata/import/paddle-catalog.json",
+		"testdata/import/chargebee-catalog.json",
+		"testdata/import/plans.csv",
+		"testdata/import/plans_mixed_limit.csv",
+		"testdata/valid/billing_sops.yaml",
+		"testdata/valid/stripe_sops.yaml",
+		"testdata/manifest/raterunner.manifest.yaml",
+		"testdata/manifest/invalid.manifest.yaml",
+		"testdata/products/products/app-a/billing.yaml",
+		"testdata/products/products/app-b/billing.yaml",
 	}
 
 	for _, f := range files {
@@ -605,3 +5634,254 @@ func TestTestdataFilesExist(t *testing.T) {
 		}
 	}
 }
+
+func TestDiagnostics_PanicWritesRedactedBundle(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+
+	var out bytes.Buffer
+	app := &cli.App{
+		Name:      "raterunner",
+		Writer:    &out,
+		ErrWriter: &out,
+		Flags:     []cli.Flag{&cli.StringFlag{Name: "diagnostics-dir"}},
+		Commands: []*cli.Command{
+			{
+				Name:  "boom",
+				Flags: []cli.Flag{&cli.StringFlag{Name: "env"}},
+				Action: func(c *cli.Context) error {
+					fmt.Fprintln(c.App.Writer, "using key sk_test_abc123XYZ")
+					panic("nil map")
+				},
+			},
+		},
+	}
+	diagnoseCommands(app.Commands, "")
+
+	err := app.Run([]string{"raterunner", "--diagnostics-dir", dir, "boom", "--env", "flagvalue123", "testdata/valid/billing_full.yaml"})
+	if err == nil || !strings.Contains(err.Error(), "internal error: nil map") {
+		t.Fatalf("err = %v, want the panic as an error", err)
+	}
+	assertContains(t, out.String(), "Diagnostics written to "+dir)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "raterunner-diagnostics-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("got %d bundles, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "abc123XYZ") || strings.Contains(string(data), "flagvalue123") {
+		t.Errorf("bundle leaks secrets or flag values: %s", data)
+	}
+
+	var bundle map[string]any
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle["command"] != "boom" || bundle["config_file"] != "billing_full.yaml" || !strings.Contains(bundle["stack"].(string), "panic") {
+		t.Errorf("bundle = %v", bundle)
+	}
+	if flags := fmt.Sprint(bundle["flags"]); flags != "[diagnostics-dir env]" {
+		t.Errorf("flags = %s, want [diagnostics-dir env]", flags)
+	}
+	assertContains(t, fmt.Sprint(bundle["log"]), "using key sk_test_...")
+	if strings.Contains(string(data), "abc123XYZ") {
+		t.Error("bundle leaked the API key")
+	}
+	structure := fmt.Sprint(bundle["config_structure"])
+	assertContains(t, structure, "plans:")
+	assertContains(t, structure, "<string>")
+}
+
+func TestDiagnostics_OnlyUnexpectedFailures(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+
+	stdout, stderr, exitCode := runApp("--diagnostics-dir", dir, "validate", "testdata/does-not-exist.yaml")
+	assertExitCode(t, 1, exitCode)
+	if strings.Contains(stdout+stderr, "Diagnostics") {
+		t.Errorf("bundle written for a user error: %s%s", stdout, stderr)
+	}
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("failed to read file"), false},
+		{&stripeapi.Error{HTTPStatusCode: 500, Type: stripeapi.ErrorTypeAPI}, true},
+		{&stripeapi.Error{HTTPStatusCode: 400, Type: stripeapi.ErrorTypeInvalidRequest}, true},
+		{&stripeapi.Error{HTTPStatusCode: 401, Type: stripeapi.ErrorTypeInvalidRequest}, false},
+		{&stripeapi.Error{HTTPStatusCode: 429, Type: stripeapi.ErrorTypeInvalidRequest}, false},
+		{stripe.SyncErrors{{Kind: "plan", ID: "pro", Err: &stripeapi.Error{HTTPStatusCode: 500}}}, true},
+		{fmt.Errorf("sync failed: %w", context.Canceled), false},
+	}
+	for _, tt := range tests {
+		if got := unexpectedFailure(tt.err); got != tt.want {
+			t.Errorf("unexpectedFailure(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestApply_SavedPlanChecks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("STRIPE_SANDBOX_KEY", "sk_test_dummy")
+	dir := t.TempDir()
+	billing := "testdata/valid/billing_full.yaml"
+
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--save-plan", filepath.Join(dir, "plan.json"), billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "--save-plan requires --dry-run")
+
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--dry-run", "--only", "pro", "--save-plan", filepath.Join(dir, "plan.json"), billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "can't be combined with --only")
+
+	stdout, _, exitCode = runApp("apply", "--plan", filepath.Join(dir, "missing.json"))
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "failed to read plan")
+
+	content, err := os.ReadFile(billing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadBilling(content, "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &plan.File{
+		Environment:    "sandbox",
+		BillingFile:    filepath.Join(dir, "billing.yaml"),
+		CatalogVersion: config.CatalogVersion(cfg.CatalogHash(), "abc1234"),
+		StripeState:    stripe.Fingerprint(nil),
+		Changes:        &diff.DiffResult{Environment: "sandbox"},
+		Config:         string(content),
+	}
+	planPath := filepath.Join(dir, "plan.json")
+	if err := plan.Save(planPath, saved); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, exitCode = runApp("apply", "--plan", planPath, billing)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "pass either a billing file or --plan")
+
+	stdout, _, exitCode = runApp("apply", "--plan", planPath, "--env", "production")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "was made for sandbox, not production")
+
+	stdout, _, exitCode = runApp("apply", "--plan", planPath, "--dry-run")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "create one with --dry-run --save-plan")
+
+	saved.Config = strings.Replace(string(content), "name:", "name: Edited", 1)
+	if err := plan.Save(planPath, saved); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, exitCode = runApp("apply", "--plan", planPath)
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "was the plan edited?")
+}
+
+func TestFingerprint_IgnoresListOrder(t *testing.T) {
+	a := []stripe.Product{
+		{ID: "prod_1", Name: "Pro", Prices: []stripe.ProductPrice{{ID: "price_1", Amount: 100}, {ID: "price_2", Amount: 1000}}},
+		{ID: "prod_2", Name: "Team"},
+	}
+	b := []stripe.Product{
+		{ID: "prod_2", Name: "Team"},
+		{ID: "prod_1", Name: "Pro", Prices: []stripe.ProductPrice{{ID: "price_2", Amount: 1000}, {ID: "price_1", Amount: 100}}},
+	}
+	if stripe.Fingerprint(a) != stripe.Fingerprint(b) {
+		t.Error("fingerprint depends on list order")
+	}
+
+	b[1].Prices[0].Amount = 2000
+	if stripe.Fingerprint(a) == stripe.Fingerprint(b) {
+		t.Error("fingerprint ignores a changed price")
+	}
+}
+
+func TestWrapActions_NamesSubcommands(t *testing.T) {
+	noop := func(c *cli.Context) error { return nil }
+	commands := []*cli.Command{
+		{Name: "apply", Action: noop},
+		{Name: "provider-file", Subcommands: []*cli.Command{{Name: "compact", Action: noop}}},
+	}
+
+	var ran []string
+	wrapActions(commands, "", func(name string, action cli.ActionFunc) cli.ActionFunc {
+		return func(c *cli.Context) error {
+			ran = append(ran, name)
+			return action(c)
+		}
+	})
+	if commands[1].Action != nil {
+		t.Error("wrapped a command without an action")
+	}
+
+	app := &cli.App{Name: "raterunner", Commands: commands}
+	for _, args := range [][]string{{"raterunner", "apply"}, {"raterunner", "provider-file", "compact"}} {
+		if err := app.Run(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(ran, ",") != "apply,provider-file compact" {
+		t.Errorf("wrapped names = %v", ran)
+	}
+}
+
+func TestApply_PruneChecks(t *testing.T) {
+	stdout, _, exitCode := runApp("apply", "--env", "sandbox", "--dry-run", "--prune", "--only", "pro", "testdata/valid/billing_full.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "can't be combined with --only")
+
+	stdout, _, exitCode = runApp("apply", "--env", "sandbox", "--prune", "--provider", "x-lago", "testdata/valid/billing_full.yaml")
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "--prune is only supported for Stripe")
+}
+
+func TestApply_PruneProductionConfirmsAccount(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if strings.HasPrefix(r.URL.Path, "/v1/account") {
+			w.Write([]byte(`{"id":"acct_live","object":"account"}`))
+			return
+		}
+		http.Error(w, "unexpected request", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	t.Setenv(stripe.APIURLEnv, server.URL)
+	t.Setenv("STRIPE_PRODUCTION_KEY", "sk_live_prune")
+	t.Setenv("HOME", t.TempDir())
+
+	stdout, _, exitCode := runApp("apply", "--env", "production", "--prune", "--confirm-account", "acct_other", "testdata/valid/billing_full.yaml")
+
+	assertExitCode(t, 1, exitCode)
+	assertContains(t, stdout, "account ID doesn't match acct_live; nothing was changed")
+	if len(requests) != 1 {
+		t.Errorf("expected only the account to be fetched, got %v", requests)
+	}
+}
+
+func TestOrphaned(t *testing.T) {
+	cfg := &config.BillingConfig{Plans: []config.Plan{
+		{ID: "pro", PreviousIDs: []string{"premium"}},
+		{ID: "legacy", Sync: new(bool)}, // not synced, but still in the config
+	}}
+	products := []stripe.Product{
+		{ID: "prod_pro", PlanCode: "pro", Active: true},
+		{ID: "prod_premium", PlanCode: "premium", Active: true},
+		{ID: "prod_legacy", PlanCode: "legacy", Active: true},
+		{ID: "prod_old", PlanCode: "starter", Active: true},
+		{ID: "prod_archived", PlanCode: "basic", Active: false},
+		{ID: "prod_other", Active: true}, // not managed by raterunner
+	}
+
+	orphaned := stripe.Orphaned(cfg, products)
+	if len(orphaned) != 1 || orphaned[0].ID != "prod_old" {
+		t.Errorf("orphaned = %+v, want only prod_old", orphaned)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+)
+
+// manifestArg returns the manifest given as the first argument, or the
+// raterunner.manifest.yaml found from the working directory upwards
+func manifestArg(c *cli.Context) (*config.Manifest, string, error) {
+	path := c.Args().First()
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get current directory: %w", err)
+		}
+		if path, err = config.FindManifest(wd); err != nil {
+			return nil, "", err
+		}
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			path = rel
+		}
+		fmt.Fprintf(getNoticeOutput(c), "Using %s\n", path)
+	}
+
+	manifest, err := config.LoadManifest(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, path, nil
+}
+
+// validateManifestAction validates every file in the manifest and reports all
+// errors before failing
+func validateManifestAction(c *cli.Context) error {
+	manifest, _, err := manifestArg(c)
+	if err != nil {
+		return err
+	}
+
+	v := newValidator(c)
+	out := getOutput(c)
+
+	// Errors always shown (even in quiet mode)
+	errOut := getResultOutput(c)
+
+	invalid := 0
+	for _, entry := range manifest.Files {
+		result, suppressed, err := validateFile(v, entry.Path, entry.Type)
+		if err != nil {
+			invalid++
+			fmt.Fprintf(errOut, "✗ %s: %v\n", entry.Path, err)
+			continue
+		}
+		printValidationWarnings(c, entry.Path, result)
+		if result.Valid {
+			fmt.Fprintf(out, "✓ %s is valid%s\n", entry.Path, suppressedNote(suppressed))
+			continue
+		}
+
+		invalid++
+		fmt.Fprintf(errOut, "✗ %s has %d validation error(s)%s:\n\n", entry.Path, len(result.Errors), suppressedNote(suppressed))
+		for i, e := range result.Errors {
+			fmt.Fprintf(errOut, "  %d. %s\n", i+1, e.String())
+		}
+		fmt.Fprintln(errOut)
+	}
+
+	status := "ok"
+	if invalid > 0 {
+		status = "invalid"
+	}
+	printSummary(c, status, summaryField{"files", len(manifest.Files)}, summaryField{"invalid", invalid})
+
+	if invalid > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// applyManifestAction applies the manifest's billing files that target --env,
+// in the order they are listed. A failure stops the run so later files never
+// apply on top of a half-applied earlier one; dry runs go through every file.
+func applyManifestAction(c *cli.Context) error {
+	manifest, manifestPath, err := manifestArg(c)
+	if err != nil {
+		return err
+	}
+
+	env, err := resolveEnv(c)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, entry := range manifest.Files {
+		schemaType := entry.Type
+		if schemaType == "" {
+			schemaType = detectSchemaType(entry.Path)
+		}
+		if schemaType == "billing" && entry.TargetsEnv(env) {
+			files = append(files, entry.Path)
+		}
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("%s lists no billing files for %s", manifestPath, env)
+	}
+
+	out := getOutput(c)
+	differs := 0
+	for i, file := range files {
+		fmt.Fprintf(out, "==> [%d/%d] %s\n", i+1, len(files), file)
+
+		err := applyFile(c, file, env, nil)
+		var exit cli.ExitCoder
+		switch {
+		case err == nil:
+		case c.Bool("dry-run") && errors.As(err, &exit) && exit.Error() == "":
+			// Differences found; keep comparing the remaining files
+			differs++
+		default:
+			return fmt.Errorf("%s: %w (%d later file(s) not applied)", file, err, len(files)-i-1)
+		}
+	}
+
+	if differs > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/stripe"
+)
+
+// wrapActions replaces the action of every command, subcommands included,
+// with wrap(name, action), where name is the full command name such as
+// "provider-file compact". Commands without an action are left alone.
+func wrapActions(commands []*cli.Command, parent string, wrap func(name string, action cli.ActionFunc) cli.ActionFunc) {
+	for _, cmd := range commands {
+		name := cmd.Name
+		if parent != "" {
+			name = parent + " " + cmd.Name
+		}
+		wrapActions(cmd.Subcommands, name, wrap)
+
+		if cmd.Action != nil {
+			cmd.Action = wrap(name, cmd.Action)
+		}
+	}
+}
+
+// parseEnv converts an environment name to a Stripe environment
+func parseEnv(env string) (stripe.Environment, error) {
+	switch env {
+	case "sandbox":
+		return stripe.Sandbox, nil
+	case "production":
+		return stripe.Production, nil
+	}
+	return "", fmt.Errorf("invalid environment: %s (use 'sandbox' or 'production')", env)
+}
+
+// resolveStripeEnv returns --env, or the default_env setting, with the Stripe
+// environment it names
+func resolveStripeEnv(c *cli.Context) (string, stripe.Environment, error) {
+	env, err := resolveEnv(c)
+	if err != nil {
+		return "", "", err
+	}
+	stripeEnv, err := parseEnv(env)
+	if err != nil {
+		return "", "", err
+	}
+	return env, stripeEnv, nil
+}
+
+// connectStripe creates a client for env with the API key from the
+// environment, set up for --verbose and --refresh like every other command
+func connectStripe(c *cli.Context, env stripe.Environment) (*stripe.Client, error) {
+	apiKey, err := getAPIKey(env)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newStripeClient(c, env, apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Stripe client: %w", err)
+	}
+	return client, nil
+}
+
+// getResultOutput returns the writer for a command's result: listings,
+// reports and errors the user must see, written even in quiet mode
+func getResultOutput(c *cli.Context) io.Writer {
+	if c.App.Writer == nil {
+		return os.Stdout
+	}
+	return c.App.Writer
+}
+
+// getErrorOutput returns stderr, written even in quiet mode
+func getErrorOutput(c *cli.Context) io.Writer {
+	if c.App.ErrWriter == nil {
+		return os.Stderr
+	}
+	return c.App.ErrWriter
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/report"
+)
+
+func mrrAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.String("format")
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use 'text' or 'json')", format)
+	}
+
+	env, stripeEnv, err := resolveStripeEnv(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+
+	providerPath := config.ProviderFilePath(filePath, "stripe", env)
+	providerCfg, err := loadProviderFile(providerPath)
+	if err != nil {
+		return fmt.Errorf("failed to load %s (run 'raterunner apply --env %s' first): %w", providerPath, env, err)
+	}
+
+	client, err := connectStripe(c, stripeEnv)
+	if err != nil {
+		return err
+	}
+
+	items, err := client.FetchSubscriptionItems(c.Context)
+	if err != nil {
+		return err
+	}
+	rows, err := report.MRR(cfg, items, providerCfg)
+	if err != nil {
+		return err
+	}
+
+	// The report is the command's result, so it is written even in quiet mode
+	out := getResultOutput(c)
+
+	if format == "json" {
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal MRR: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	} else {
+		printMRR(out, rows)
+	}
+
+	var total int64
+	mismatches := 0
+	for _, row := range rows {
+		total += row.MRR
+		if row.Mismatch != "" {
+			mismatches++
+		}
+	}
+	printSummary(c, "ok",
+		summaryField{"env", env},
+		summaryField{"mrr_cents", total},
+		summaryField{"mismatches", mismatches})
+	return nil
+}
+
+// printMRR writes MRR rows as a table with totals per currency. Rows where
+// billing.yaml and Stripe disagree are marked with '!'.
+func printMRR(w io.Writer, rows []report.MRRRow) {
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "No active subscriptions")
+		return
+	}
+
+	fmt.Fprintf(w, "  %-7s %-20s %-10s %6s %8s %14s %14s\n", "KIND", "ID", "INTERVAL", "SUBS", "QTY", "MRR", "STRIPE MRR")
+	totals := make(map[string]int64)
+	var notes []string
+	for _, row := range rows {
+		mark := " "
+		if row.Mismatch != "" {
+			mark = "!"
+			notes = append(notes, fmt.Sprintf("%s %s: %s", row.ID, row.Interval, row.Mismatch))
+		}
+		id := row.ID
+		if id == "" {
+			id = row.PriceID
+		}
+		interval := row.Interval
+		if interval == "" {
+			interval = "-"
+		}
+		stripeMRR := "-"
+		if row.StripeMRR != nil {
+			stripeMRR = formatCents(*row.StripeMRR, row.Currency)
+		}
+		fmt.Fprintf(w, "%s %-7s %-20s %-10s %6d %8d %14s %14s\n", mark, row.Kind, id, interval, row.Subscriptions, row.Quantity, formatCents(row.MRR, row.Currency), stripeMRR)
+		totals[row.Currency] += row.MRR
+	}
+
+	currencies := make([]string, 0, len(totals))
+	for currency := range totals {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	fmt.Fprintln(w)
+	for _, currency := range currencies {
+		fmt.Fprintf(w, "Total MRR: %s\n", formatCents(totals[currency], currency))
+	}
+
+	if len(notes) > 0 {
+		fmt.Fprintln(w, "\nbilling.yaml and Stripe prices disagree:")
+		for _, note := range notes {
+			fmt.Fprintf(w, "  ! %s\n", note)
+		}
+	}
+}
+
+// formatCents renders an amount in cents with its currency, e.g. 2900 usd → 29.00 USD
+func formatCents(cents int64, currency string) string {
+	return fmt.Sprintf("%d.%02d %s", cents/100, cents%100, strings.ToUpper(currency))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/operator"
+	"raterunner/internal/provider"
+)
+
+// operatorAction reconciles BillingConfig resources until interrupted
+func operatorAction(c *cli.Context) error {
+	namespace := c.String("namespace")
+
+	var kube *operator.Kube
+	if api := c.String("kube-api"); api != "" {
+		kube = &operator.Kube{BaseURL: strings.TrimSuffix(api, "/"), Namespace: namespace}
+	} else {
+		var err error
+		if kube, err = operator.InCluster(namespace); err != nil {
+			return err
+		}
+	}
+
+	notices := getNoticeOutput(c)
+	logf := func(format string, args ...any) {
+		fmt.Fprintf(notices, format+"\n", args...)
+	}
+	r := &operator.Reconciler{
+		Kube:            kube,
+		Validator:       newValidator(c),
+		Resync:          c.Duration("resync"),
+		Logf:            logf,
+		AllowProduction: c.Bool("allow-production"),
+		Sync: func(ctx context.Context, cfg *config.BillingConfig, env string) (string, error) {
+			return operatorSync(ctx, c, cfg, env)
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scope := "all namespaces"
+	if namespace != "" {
+		scope = "namespace " + namespace
+	}
+	logf("Watching BillingConfig resources in %s (resync every %s)", scope, c.Duration("resync"))
+	return r.Run(ctx)
+}
+
+// operatorSync applies the billing config of a BillingConfig resource with the
+// same sync engine as apply, and returns the catalog version it stamped
+func operatorSync(ctx context.Context, c *cli.Context, cfg *config.BillingConfig, env string) (string, error) {
+	if err := validateProvider(cfg.Providers); err != nil {
+		return "", err
+	}
+	stripeEnv, err := parseEnv(env)
+	if err != nil {
+		return "", err
+	}
+	// Run under the reconcile's context, which a lost run lock cancels
+	parent := c.Context
+	c.Context = ctx
+	defer func() { c.Context = parent }()
+
+	client, err := connectStripe(c, stripeEnv)
+	if err != nil {
+		return "", err
+	}
+	if _, err := productionBanner(c, client, "operator applies a BillingConfig to live products, prices and coupons"); err != nil {
+		return "", err
+	}
+	// The same lock as apply, so a reconcile never runs alongside a CLI apply
+	release, err := acquireRunLock(c, client, stripeEnv)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	metaKeys, err := cfg.MetadataKeys()
+	if err != nil {
+		return "", err
+	}
+	client.SetMetadataKeys(metaKeys, false)
+
+	// Resources have no git commit, so the version is the config hash alone
+	version := config.CatalogVersion(cfg.CatalogHash(), "")
+	client.SetCatalogVersion(version)
+
+	result, err := provider.NewStripe(client).Sync(c.Context, cfg)
+	if err != nil {
+		return "", fmt.Errorf("sync failed: %w", err)
+	}
+	for _, w := range result.Warnings {
+		fmt.Fprintf(getNoticeOutput(c), "  WARNING: %s\n", w)
+	}
+	return version, nil
+}
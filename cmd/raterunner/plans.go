@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+)
+
+func plansCopyAction(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: raterunner plans copy <plan_id> <new_plan_id> [billing.yaml]")
+	}
+	fromID, toID := c.Args().Get(0), c.Args().Get(1)
+	for _, arg := range c.Args().Slice()[2:] {
+		if strings.HasPrefix(arg, "-") && arg != stdinPath {
+			return fmt.Errorf("flags go before the plan IDs, e.g. raterunner plans copy --price-multiplier 0.9 %s %s", fromID, toID)
+		}
+	}
+
+	filePath, err := billingFileArgAt(c, 2)
+	if err != nil {
+		return err
+	}
+	if filePath == stdinPath {
+		return fmt.Errorf("plans copy edits the billing file in place and can't read it from stdin")
+	}
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		return fmt.Errorf("plans copy only edits YAML billing files")
+	}
+
+	content, err := config.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	copied, plan, err := config.CopyPlan(content, fromID, toID, c.Float64("price-multiplier"))
+	if err != nil {
+		return fmt.Errorf("failed to copy plan in %s: %w", filePath, err)
+	}
+
+	out := getOutput(c)
+	if c.Bool("dry-run") {
+		fmt.Fprint(out, plan.YAML)
+	} else {
+		if err := config.SaveFile(filePath, copied); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filePath, err)
+		}
+		fmt.Fprintf(out, "Copied plan '%s' to '%s' in %s (line %d)\n", fromID, toID, filePath, plan.Line)
+	}
+	for _, change := range plan.Prices {
+		fmt.Fprintf(out, "  %s: %d -> %d\n", change.Path, change.From, change.To)
+	}
+	for _, field := range plan.Dropped {
+		fmt.Fprintf(getNoticeOutput(c), "note: %s stays with '%s' and was left out of the copy\n", field, fromID)
+	}
+
+	printSummary(c, "ok",
+		summaryField{"plan", toID},
+		summaryField{"line", plan.Line},
+		summaryField{"prices_changed", len(plan.Prices)},
+		summaryField{"dry_run", c.Bool("dry-run")})
+	return nil
+}
+
+func plansGraphAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+	graph, err := config.RenderPlanGraph(cfg, c.String("format"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprint(getResultOutput(c), graph)
+	return nil
+}
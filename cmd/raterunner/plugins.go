@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/provider"
+	"raterunner/internal/stripe"
+)
+
+// pluginsListAction lists the provider plugins in the plugin directory
+func pluginsListAction(c *cli.Context) error {
+	dir := provider.PluginDir()
+	plugins, err := provider.Plugins(dir)
+	if err != nil {
+		return err
+	}
+
+	out := getResultOutput(c)
+	if len(plugins) == 0 {
+		fmt.Fprintf(out, "No provider plugins in %s\n", dir)
+		return nil
+	}
+	fmt.Fprintf(out, "Provider plugins in %s:\n", dir)
+	for _, p := range plugins {
+		fmt.Fprintf(out, "  %-20s %s\n", p.Name, p.Path)
+	}
+	return nil
+}
+
+// applyPlugin applies a billing config to a plugin provider. It skips the
+// Stripe-only parts of apply (metadata keys, promotions, the Stripe lock and
+// banner) and records the IDs the plugin returns in the provider's own file.
+func applyPlugin(c *cli.Context, cfg *config.BillingConfig, filePath, env, name string) error {
+	if !targetsProvider(cfg, name) {
+		return fmt.Errorf("no plan in the billing config targets provider '%s'; add it to providers", name)
+	}
+	if len(c.StringSlice("only")) > 0 || c.Bool("migrate-metadata") || c.Bool("stripe-lock") {
+		return fmt.Errorf("--only, --migrate-metadata and --stripe-lock are not supported with plugin providers")
+	}
+
+	info, err := provider.FindPlugin(provider.PluginDir(), name)
+	if err != nil {
+		return err
+	}
+	plugin := provider.NewPlugin(*info, env, getNoticeOutput(c))
+	out := getOutput(c)
+
+	if c.Bool("dry-run") {
+		result, _, err := plugin.Diff(c.Context, cfg)
+		if err != nil {
+			return err
+		}
+		stabilizeDiff(c, result)
+		if c.Bool("json") {
+			if err := diff.OutputJSON(out, result); err != nil {
+				return fmt.Errorf("failed to write JSON output: %w", err)
+			}
+		} else {
+			diff.OutputTable(out, result)
+		}
+
+		status := "ok"
+		if result.HasDifferences() {
+			status = "differs"
+		}
+		printSummary(c, status,
+			summaryField{"env", env},
+			summaryField{"provider", name},
+			summaryField{"plans", result.Summary.Total},
+			summaryField{"synced", result.Summary.Synced},
+			summaryField{"missing", result.Summary.Missing},
+			summaryField{"differs", result.Summary.Differs},
+			summaryField{"skipped", result.Summary.Skipped})
+
+		if result.HasDifferences() {
+			return cli.Exit("", 1)
+		}
+		return nil
+	}
+
+	if filePath == stdinPath {
+		return fmt.Errorf("reading the billing config from stdin requires --dry-run")
+	}
+	providerPath := config.ProviderFilePath(filePath, name, env)
+	if !c.Bool("force") {
+		if version, ok := appliedUnchanged(cfg, providerPath); ok {
+			fmt.Fprintf(out, "Config unchanged since the last apply to %s in %s (catalog version %s); nothing to do. Use --force to apply anyway.\n", name, env, version)
+			printSummary(c, "unchanged",
+				summaryField{"env", env},
+				summaryField{"provider", name},
+				summaryField{"catalog_version", version},
+				summaryField{"provider_file", providerPath})
+			return nil
+		}
+	}
+
+	catalogVersion := config.CatalogVersion(cfg.CatalogHash(), gitCommit(filepath.Dir(filePath)))
+	plugin.SetCatalogVersion(catalogVersion)
+
+	// Same local lock as Stripe applies, one apply per environment at a time
+	release, err := acquireRunLock(c, nil, stripe.Environment(env))
+	if err != nil {
+		return err
+	}
+	defer release()
+	targetBanner(c, env, "provider "+name, "apply creates and archives live plans and prices")
+
+	fmt.Fprintf(out, "Syncing billing config to %s (%s)...\n", name, env)
+	result, err := plugin.Sync(c.Context, cfg)
+	if err != nil {
+		return fmt.Errorf("sync failed: %w", err)
+	}
+	for _, w := range sortedWarnings(c, result.Warnings) {
+		fmt.Fprintf(out, "  WARNING: %s\n", w)
+	}
+	fmt.Fprintf(out, "Done. Products: %d created, %d updated. Prices: %d created, %d archived. Addons: %d.\n",
+		result.ProductsCreated, result.ProductsUpdated, result.PricesCreated, result.PricesArchived, result.AddonsCreated)
+
+	providerCfg := syncedProvider(env, catalogVersion, result)
+	providerCfg.Provider = name
+	previous, err := loadProviderFile(providerPath)
+	if err != nil {
+		previous = nil // first apply for this environment
+	}
+	providerCfg.RecordHistory(previous, time.Now(), config.DefaultProviderHistory)
+	if err := saveProviderFile(c, providerPath, providerCfg); err != nil {
+		return fmt.Errorf("failed to save provider file: %w", err)
+	}
+	fmt.Fprintf(out, "Saved provider IDs to %s (catalog version %s)\n", providerPath, catalogVersion)
+
+	status := "ok"
+	strict := strictWarnings(c) && len(result.Warnings) > 0
+	if strict {
+		status = "warnings"
+	}
+	printSummary(c, status,
+		summaryField{"env", env},
+		summaryField{"provider", name},
+		summaryField{"catalog_version", catalogVersion},
+		summaryField{"products_created", result.ProductsCreated},
+		summaryField{"products_updated", result.ProductsUpdated},
+		summaryField{"prices_created", result.PricesCreated},
+		summaryField{"prices_archived", result.PricesArchived},
+		summaryField{"addons_created", result.AddonsCreated},
+		summaryField{"warnings", len(result.Warnings)},
+		summaryField{"provider_file", providerPath})
+
+	if strict {
+		return fmt.Errorf("%d sync warning(s) with strict warnings enabled; the changes were applied and %s was saved", len(result.Warnings), providerPath)
+	}
+	return nil
+}
+
+// targetsProvider reports whether any plan of the config targets a provider
+func targetsProvider(cfg *config.BillingConfig, name string) bool {
+	for _, plan := range cfg.Plans {
+		if plan.HasProvider(name, cfg.Providers) {
+			return true
+		}
+	}
+	return false
+}
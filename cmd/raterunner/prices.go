@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+)
+
+func pricesLocalizeAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+	if filePath == stdinPath {
+		return fmt.Errorf("prices localize edits the billing file in place and can't read it from stdin")
+	}
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		return fmt.Errorf("prices localize only edits YAML billing files")
+	}
+
+	var currencies []string
+	for _, currency := range strings.Split(c.String("currencies"), ",") {
+		if currency = strings.ToLower(strings.TrimSpace(currency)); currency != "" {
+			currencies = append(currencies, currency)
+		}
+	}
+	if len(currencies) == 0 {
+		return fmt.Errorf("--currencies lists no currencies")
+	}
+
+	ratesPath := c.String("rates")
+	ratesContent, err := os.ReadFile(ratesPath)
+	if err != nil {
+		return fmt.Errorf("failed to read rates: %w", err)
+	}
+	ratesBase, rates, err := config.ParseRates(ratesContent)
+	if err != nil {
+		return fmt.Errorf("%s: %w", ratesPath, err)
+	}
+
+	content, err := config.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	localized, result, err := config.LocalizePrices(content, config.LocalizeOptions{
+		Currencies: currencies,
+		Rates:      rates,
+		RatesBase:  ratesBase,
+		Rounding:   c.String("rounding"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to localize prices in %s: %w", filePath, err)
+	}
+
+	out := getOutput(c)
+	changed := 0
+	for _, p := range result.Prices {
+		note := ""
+		switch {
+		case p.Previous == 0:
+			note = " (new)"
+		case p.Previous != p.Amount:
+			note = fmt.Sprintf(" (was %d)", p.Previous)
+		}
+		if p.Previous != p.Amount {
+			changed++
+		}
+		fmt.Fprintf(out, "  %s %s %s: %d%s\n", p.PlanID, p.Interval, p.Currency, p.Amount, note)
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Fprintf(getNoticeOutput(c), "note: skipped %s, only flat prices are localized\n", skipped)
+	}
+
+	if c.Bool("dry-run") {
+		fmt.Fprintf(out, "Would change %d price(s) from %s in %s\n", changed, result.Base, filePath)
+	} else if changed > 0 {
+		if err := config.SaveFile(filePath, localized); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filePath, err)
+		}
+		fmt.Fprintf(out, "Localized %d price(s) from %s in %s\n", changed, result.Base, filePath)
+	} else {
+		fmt.Fprintf(out, "All %d price(s) are already up to date\n", len(result.Prices))
+	}
+
+	printSummary(c, "ok",
+		summaryField{"prices", len(result.Prices)},
+		summaryField{"changed", changed},
+		summaryField{"skipped", len(result.Skipped)},
+		summaryField{"dry_run", c.Bool("dry-run")})
+	return nil
+}
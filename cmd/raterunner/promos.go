@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/stripe"
+)
+
+// maxGeneratedCodes caps one promos generate run
+const maxGeneratedCodes = 10000
+
+func promosGenerateAction(c *cli.Context) error {
+	coupon := c.String("coupon")
+	count := c.Int("count")
+	if count < 1 || count > maxGeneratedCodes {
+		return fmt.Errorf("--count must be between 1 and %d, got %d", maxGeneratedCodes, count)
+	}
+
+	opts := stripe.PromoCodeOptions{NewCustomersOnly: c.Bool("new-customers-only")}
+	if expires := c.String("expires"); expires != "" {
+		t, err := time.Parse(time.DateOnly, expires)
+		if err != nil {
+			return fmt.Errorf("invalid --expires: %s (use YYYY-MM-DD)", expires)
+		}
+		if !t.After(time.Now()) {
+			return fmt.Errorf("--expires must be in the future, got %s", expires)
+		}
+		opts.ExpiresAt = t
+	}
+
+	prefix := c.String("prefix")
+	codes, err := stripe.NewPromoCodes(prefix, count, c.Int("length"))
+	if err != nil {
+		return err
+	}
+
+	// Status lines go to stderr when the CSV is written to stdout
+	outputPath := c.String("out")
+	status := getOutput(c)
+	if outputPath == "" {
+		status = getNoticeOutput(c)
+	}
+
+	if c.Bool("dry-run") {
+		fmt.Fprintf(status, "Would create %d single-use promotion code(s) against coupon '%s'\n", count, coupon)
+		generated := make([]stripe.GeneratedCode, len(codes))
+		for i, code := range codes {
+			generated[i] = stripe.GeneratedCode{Code: code, Coupon: coupon, ExpiresAt: opts.ExpiresAt}
+		}
+		return writePromoCodes(c, outputPath, generated)
+	}
+
+	env, stripeEnv, err := resolveStripeEnv(c)
+	if err != nil {
+		return err
+	}
+	client, err := connectStripe(c, stripeEnv)
+	if err != nil {
+		return err
+	}
+	if _, err := productionBanner(c, client, "promos generate creates live promotion codes"); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(status, "Creating %d single-use promotion code(s) against coupon '%s' in %s...\n", count, coupon, env)
+	created, genErr := client.GeneratePromotionCodes(c.Context, coupon, prefix, codes, opts)
+
+	// Codes that were created are written even when a later one failed
+	if len(created) > 0 || genErr == nil {
+		if err := writePromoCodes(c, outputPath, created); err != nil {
+			return err
+		}
+	}
+	if genErr != nil {
+		if len(created) > 0 {
+			printSummary(c, "failed", summaryField{"env", env}, summaryField{"coupon", coupon}, summaryField{"created", len(created)})
+			return fmt.Errorf("created %d of %d code(s) before failing: %w", len(created), count, genErr)
+		}
+		return genErr
+	}
+
+	if outputPath != "" {
+		fmt.Fprintf(status, "Done. Created %d code(s), written to %s\n", len(created), outputPath)
+	} else {
+		fmt.Fprintf(status, "Done. Created %d code(s)\n", len(created))
+	}
+	printSummary(c, "ok",
+		summaryField{"env", env},
+		summaryField{"coupon", coupon},
+		summaryField{"created", len(created)},
+		summaryField{"output", outputPath})
+	return nil
+}
+
+// writePromoCodes writes generated codes as CSV to path, or to stdout when
+// path is empty. The codes are the command's result, so stdout is used even
+// in quiet mode.
+func writePromoCodes(c *cli.Context, path string, codes []stripe.GeneratedCode) error {
+	w := getResultOutput(c)
+	var f *os.File
+	if path != "" {
+		var err error
+		if f, err = os.Create(path); err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		w = f
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"code", "promotion_code_id", "coupon", "expires"})
+	for _, code := range codes {
+		expires := ""
+		if !code.ExpiresAt.IsZero() {
+			expires = code.ExpiresAt.Format(time.DateOnly)
+		}
+		cw.Write([]string{code.Code, code.ID, code.Coupon, expires})
+	}
+	cw.Flush()
+	err := cw.Error()
+	if f != nil {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write codes: %w", err)
+	}
+	return nil
+}
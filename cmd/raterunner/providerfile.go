@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+)
+
+// providerFileMigrateAction rewrites provider files in the current format
+// version. Nothing is lost: newer versions only add fields.
+func providerFileMigrateAction(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: raterunner provider-file migrate <provider file>...")
+	}
+
+	out := getOutput(c)
+	for _, path := range c.Args().Slice() {
+		providerCfg, err := loadProviderFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		version := providerCfg.FileVersion()
+		if version == config.ProviderFileVersion {
+			fmt.Fprintf(out, "%s is already at version %d\n", path, version)
+			continue
+		}
+		if err := config.SaveProviderFile(path, providerCfg); err != nil {
+			return fmt.Errorf("failed to save %s: %w", path, err)
+		}
+		fmt.Fprintf(out, "Migrated %s from version %d to %d\n", path, version, config.ProviderFileVersion)
+	}
+	return nil
+}
+
+// providerFileCompactAction prunes the replaced IDs apply keeps per plan
+func providerFileCompactAction(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return fmt.Errorf("usage: raterunner provider-file compact [--keep N] <provider file>...")
+	}
+	keep := c.Int("keep")
+	if keep < 0 {
+		return fmt.Errorf("--keep must not be negative")
+	}
+
+	out := getOutput(c)
+	for _, path := range c.Args().Slice() {
+		providerCfg, err := loadProviderFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		dropped := providerCfg.CompactHistory(keep)
+		if dropped == 0 {
+			fmt.Fprintf(out, "%s: nothing to compact\n", path)
+			continue
+		}
+		if err := config.SaveProviderFile(path, providerCfg); err != nil {
+			return fmt.Errorf("failed to save %s: %w", path, err)
+		}
+		fmt.Fprintf(out, "%s: dropped %d replaced ID set(s)\n", path, dropped)
+	}
+	return nil
+}
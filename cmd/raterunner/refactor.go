@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+)
+
+// renamedFile is a config or export rewritten by a rename
+type renamedFile struct {
+	path    string
+	content []byte
+	refs    []config.KeyRef
+	config  bool // written with sops re-encryption
+}
+
+func refactorRenameEntitlementAction(c *cli.Context) error {
+	if c.NArg() < 2 {
+		return fmt.Errorf("usage: raterunner refactor rename-entitlement <old_key> <new_key> [billing.yaml]")
+	}
+	oldKey, newKey := c.Args().Get(0), c.Args().Get(1)
+
+	filePath, err := billingFileArgAt(c, 2)
+	if err != nil {
+		return err
+	}
+	if filePath == stdinPath {
+		return fmt.Errorf("rename-entitlement rewrites the billing file in place and can't read it from stdin")
+	}
+
+	content, err := config.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	renamed, refs, err := config.RenameEntitlement(content, oldKey, newKey)
+	if err != nil {
+		return fmt.Errorf("failed to rename entitlement in %s: %w", filePath, err)
+	}
+	files := []renamedFile{{path: filePath, content: renamed, refs: refs, config: true}}
+
+	// Every file is renamed before any is written, so a failure changes nothing
+	for _, exportPath := range c.StringSlice("export") {
+		content, err := os.ReadFile(exportPath)
+		if err != nil {
+			return fmt.Errorf("failed to read export %s: %w", exportPath, err)
+		}
+		renamed, refs, err := config.RenameEntitlement(content, oldKey, newKey)
+		if err != nil {
+			return fmt.Errorf("failed to rename entitlement in %s: %w", exportPath, err)
+		}
+		files = append(files, renamedFile{path: exportPath, content: renamed, refs: refs})
+	}
+
+	out := getOutput(c)
+	dryRun := c.Bool("dry-run")
+	verb := "Renamed"
+	if dryRun {
+		verb = "Would rename"
+	}
+	var paths []string
+	for _, f := range files {
+		fmt.Fprintf(out, "%s entitlement '%s' to '%s' in %s:\n", verb, oldKey, newKey, f.path)
+		for _, ref := range f.refs {
+			fmt.Fprintf(out, "  %s (line %d)\n", ref.Path, ref.Line)
+			paths = append(paths, f.path+"#"+ref.Path)
+		}
+		if dryRun {
+			continue
+		}
+
+		if f.config {
+			err = config.SaveFile(f.path, f.content)
+		} else {
+			err = os.WriteFile(f.path, f.content, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+	}
+
+	printSummary(c, "ok",
+		summaryField{"renamed", len(paths)},
+		summaryField{"files", len(files)},
+		summaryField{"dry_run", dryRun},
+		summaryField{"paths", summaryDetail{paths}})
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+)
+
+// requiredRole returns the role a command needs. Read-only commands and dry
+// runs only need viewer; commands that change Stripe or write config files need
+// editor; commands that archive or delete objects need admin.
+func requiredRole(c *cli.Context, command string) string {
+	switch command {
+	case "truncate", "cleanup":
+		return config.RoleAdmin
+	case "apply", "flags sync", "promos generate", "seed", "plans copy", "prices localize", "refactor rename-entitlement":
+		if c.Bool("dry-run") {
+			return config.RoleViewer
+		}
+		if command == "apply" && c.Bool("prune") {
+			return config.RoleAdmin // archives products of removed plans
+		}
+		return config.RoleEditor
+	case "init", "import", "clone", "provider-file migrate", "provider-file compact", "operator":
+		return config.RoleEditor
+	}
+	return config.RoleViewer
+}
+
+// gateCommands wraps the action of every command, subcommands included, so it
+// only runs when the role in the settings allows it
+func gateCommands(commands []*cli.Command, parent string) {
+	wrapActions(commands, parent, func(name string, action cli.ActionFunc) cli.ActionFunc {
+		return func(c *cli.Context) error {
+			if err := checkRole(c, name); err != nil {
+				return err
+			}
+			return action(c)
+		}
+	})
+}
+
+// checkRole returns an error when the configured role may not run command
+func checkRole(c *cli.Context, command string) error {
+	settings, err := loadSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	required := requiredRole(c, command)
+	allowed, err := config.RoleAllows(settings.Role, required)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("'%s' requires the %s role, but this installation is configured as %s (role in settings)", command, required, settings.Role)
+	}
+	return nil
+}
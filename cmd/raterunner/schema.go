@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/schema"
+)
+
+func schemaPrintAction(c *cli.Context) error {
+	schemaType := "billing"
+	if c.NArg() > 0 {
+		schemaType = c.Args().First()
+	}
+
+	var content []byte
+	var err error
+
+	version := c.Int("version")
+	if version != 0 && schemaType != "billing" {
+		return fmt.Errorf("--version only applies to the billing schema")
+	}
+	if version < 0 || version > schema.BillingSchemaVersion {
+		return fmt.Errorf("unknown billing schema version %d (this binary ships versions 1 to %d)", version, schema.BillingSchemaVersion)
+	}
+
+	switch schemaType {
+	case "billing":
+		if version == 0 {
+			content, err = schema.BillingSchema()
+		} else {
+			content, err = schema.FS.ReadFile(schema.BillingSchemaFileFor(version))
+		}
+	case "provider":
+		content, err = schema.ProviderSchema()
+	default:
+		return fmt.Errorf("unknown schema type: %s (use 'billing' or 'provider')", schemaType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read embedded schema: %w", err)
+	}
+
+	if c.Bool("resolve-refs") {
+		content, err = schema.ResolveRefs(content)
+		if err != nil {
+			return err
+		}
+		content = append(content, '\n')
+	}
+
+	// The schema is the command's result, so it is written even in quiet mode
+	out := getResultOutput(c)
+
+	_, err = out.Write(content)
+	return err
+}
+
+// schemaVSCodeSettingsAction prints the yaml.schemas setting of the VS Code
+// YAML extension, so billing and provider files get completion and hover docs
+func schemaVSCodeSettingsAction(c *cli.Context) error {
+	billingURL, providerURL, err := schemaURLs(c.String("write-schemas"))
+	if err != nil {
+		return err
+	}
+
+	// Same files the lsp command checks
+	billingGlobs := []string{"**/billing*.yaml", "**/billing*.yml"}
+	var providerGlobs []string
+	for _, prefix := range providerPrefixes {
+		providerGlobs = append(providerGlobs, "**/"+prefix+"*.yaml", "**/"+prefix+"*.yml")
+	}
+
+	settings := map[string]any{
+		"yaml.schemas": map[string][]string{
+			billingURL:  billingGlobs,
+			providerURL: providerGlobs,
+		},
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal settings: %w", err)
+	}
+
+	// The snippet is the command's result, so it is written even in quiet mode
+	out := getResultOutput(c)
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+// schemaURLs returns the billing and provider schema URLs: their $id, or
+// file:// URLs of copies written to dir
+func schemaURLs(dir string) (string, string, error) {
+	var urls []string
+	for _, name := range []string{schema.BillingSchemaFile, schema.ProviderSchemaFile} {
+		content, err := schema.FS.ReadFile(name)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read embedded schema: %w", err)
+		}
+
+		if dir == "" {
+			var doc struct {
+				ID string `json:"$id"`
+			}
+			if err := json.Unmarshal(content, &doc); err != nil {
+				return "", "", fmt.Errorf("failed to parse schema %s: %w", name, err)
+			}
+			urls = append(urls, doc.ID)
+			continue
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", "", fmt.Errorf("failed to create directory: %w", err)
+		}
+		path, err := filepath.Abs(filepath.Join(dir, name))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to resolve schema path: %w", err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return "", "", fmt.Errorf("failed to write schema: %w", err)
+		}
+		urls = append(urls, "file://"+filepath.ToSlash(path))
+	}
+	return urls[0], urls[1], nil
+}
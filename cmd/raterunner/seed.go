@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/stripe"
+)
+
+// seedIntervals is the order billing intervals are picked in when a plan
+// doesn't have a price for --interval
+var seedIntervals = []string{"monthly", "yearly", "quarterly"}
+
+// maxSeedCustomers keeps a seed run within Stripe's test mode rate limits
+const maxSeedCustomers = 500
+
+func seedAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	env, err := resolveEnv(c)
+	if err != nil {
+		return err
+	}
+	if env != "sandbox" {
+		return fmt.Errorf("seed only runs in sandbox, got --env %s", env)
+	}
+	count := c.Int("customers")
+	if count < 1 || count > maxSeedCustomers {
+		return fmt.Errorf("--customers must be between 1 and %d, got %d", maxSeedCustomers, count)
+	}
+	interval := c.String("interval")
+	if !isSeedInterval(interval) {
+		return fmt.Errorf("invalid interval: %s (use monthly, yearly or quarterly)", interval)
+	}
+
+	cfg, err := config.LoadBillingFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+	providerPath := config.ProviderFilePath(filePath, "stripe", env)
+	providerCfg, err := loadProviderFile(providerPath)
+	if err != nil {
+		return fmt.Errorf("failed to load %s (run 'raterunner apply --env %s' first): %w", providerPath, env, err)
+	}
+	plans := seedPlans(cfg, providerCfg, interval)
+	if len(plans) == 0 {
+		return fmt.Errorf("no plan in %s has a recurring price in %s", providerPath, env)
+	}
+
+	out := getOutput(c)
+	if c.Bool("dry-run") {
+		fmt.Fprintf(out, "Would create %d customer(s) with a test card, subscribed to:\n", count)
+		for i, plan := range plans {
+			if i >= count {
+				break
+			}
+			n := count / len(plans)
+			if i < count%len(plans) {
+				n++
+			}
+			fmt.Fprintf(out, "  %s (%s, %s): %d\n", plan.PlanID, plan.Interval, plan.PriceID, n)
+		}
+		printSummary(c, "ok", summaryField{"env", env}, summaryField{"customers", count}, summaryField{"dry_run", true})
+		return nil
+	}
+
+	client, err := connectStripe(c, stripe.Sandbox)
+	if err != nil {
+		return err
+	}
+
+	runID := time.Now().UTC().Format("20060102150405")
+	fmt.Fprintf(out, "Seeding %d customer(s) in Stripe (%s)...\n", count, env)
+	seeded, seedErr := client.SeedCustomers(c.Context, count, plans, stripe.SeedOptions{RunID: runID, TestClocks: c.Bool("test-clocks")})
+
+	// The customers are the command's result, so they are listed even in quiet mode
+	w := getResultOutput(c)
+	fmt.Fprintf(w, "%-20s %-20s %-10s %-30s %-10s %s\n", "CUSTOMER", "PLAN", "INTERVAL", "SUBSCRIPTION", "STATUS", "TEST CLOCK")
+	for _, s := range seeded {
+		clock := s.TestClockID
+		if clock == "" {
+			clock = "-"
+		}
+		fmt.Fprintf(w, "%-20s %-20s %-10s %-30s %-10s %s\n", s.CustomerID, s.PlanID, s.Interval, s.SubscriptionID, s.Status, clock)
+	}
+
+	status := "ok"
+	if seedErr != nil {
+		status = "failed"
+	}
+	printSummary(c, status,
+		summaryField{"env", env},
+		summaryField{"customers", len(seeded)},
+		summaryField{"run_id", runID},
+		summaryField{"test_clocks", c.Bool("test-clocks")})
+
+	if seedErr != nil {
+		return fmt.Errorf("seed stopped after %d of %d customer(s): %w", len(seeded), count, seedErr)
+	}
+	return nil
+}
+
+// seedPlans returns the synced plans seeded customers can subscribe to, each
+// with its price for interval or the first other recurring interval it has
+func seedPlans(cfg *config.BillingConfig, providerCfg *config.ProviderConfig, interval string) []stripe.SeedPlan {
+	var plans []stripe.SeedPlan
+	for _, plan := range cfg.Plans {
+		ids, ok := providerCfg.Plans[plan.ID]
+		if !ok || plan.IsOneTime() || !plan.InEnvironment(providerCfg.Environment) {
+			continue
+		}
+		for _, candidate := range append([]string{interval}, seedIntervals...) {
+			if priceID := ids.Prices[candidate]; priceID != "" {
+				seedPlan := stripe.SeedPlan{PlanID: plan.ID, Interval: candidate, PriceID: priceID, TrialDays: plan.TrialDays}
+				if plan.SendsInvoice() {
+					seedPlan.DaysUntilDue = cfg.DaysUntilDue()
+				}
+				plans = append(plans, seedPlan)
+				break
+			}
+		}
+	}
+	return plans
+}
+
+func isSeedInterval(interval string) bool {
+	for _, i := range seedIntervals {
+		if interval == i {
+			return true
+		}
+	}
+	return false
+}
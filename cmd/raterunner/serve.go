@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/provider"
+	"raterunner/internal/rpc/raterunnerv1"
+	"raterunner/internal/stripe"
+	"raterunner/internal/validator"
+)
+
+// serveTokenEnv holds the bearer token gRPC clients must send, when set
+const serveTokenEnv = "RATERUNNER_GRPC_TOKEN"
+
+// serveAction serves validate, diff and apply over gRPC until interrupted.
+// Without a token it only listens on loopback and refuses production applies.
+func serveAction(c *cli.Context) error {
+	addr := c.String("grpc")
+	if addr == "" {
+		return fmt.Errorf("--grpc is required, e.g. --grpc 127.0.0.1:9090")
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	token := os.Getenv(serveTokenEnv)
+	if token == "" {
+		if tcp, ok := lis.Addr().(*net.TCPAddr); !ok || !tcp.IP.IsLoopback() {
+			lis.Close()
+			return fmt.Errorf("refusing to serve on %s without authentication: set %s, or listen on loopback, e.g. --grpc 127.0.0.1:9090", lis.Addr(), serveTokenEnv)
+		}
+		fmt.Fprintf(getNoticeOutput(c), "WARNING: %s is not set, so any local process can call the server; production applies are refused\n", serveTokenEnv)
+	}
+
+	var opts []grpc.ServerOption
+	if token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := checkToken(ctx, token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := checkToken(ss.Context(), token); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}))
+	}
+
+	server := grpc.NewServer(opts...)
+	raterunnerv1.RegisterRaterunnerServer(server, &rpcServer{validator: newValidator(c), authenticated: token != ""})
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+
+	fmt.Fprintf(getNoticeOutput(c), "Serving gRPC on %s\n", lis.Addr())
+	return server.Serve(lis)
+}
+
+// checkToken requires "authorization: Bearer <token>" metadata
+func checkToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(v, "Bearer ")), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or invalid bearer token")
+}
+
+// rpcServer implements the Raterunner gRPC service on top of the commands
+type rpcServer struct {
+	raterunnerv1.UnimplementedRaterunnerServer
+
+	validator     *validator.Validator
+	authenticated bool       // callers must send the token; required for production applies
+	applyMu       sync.Mutex // one apply at a time, like the run lock
+}
+
+// rpcPath checks that a request path stays inside the server's directory
+func rpcPath(path string) (string, error) {
+	if path == "" {
+		return "", status.Error(codes.InvalidArgument, "path is required")
+	}
+	if !filepath.IsLocal(path) {
+		return "", status.Errorf(codes.InvalidArgument, "path must be relative to the server's directory: %s", path)
+	}
+	return path, nil
+}
+
+// rpcEnvironment parses a request environment
+func rpcEnvironment(env string) (stripe.Environment, error) {
+	switch env {
+	case "sandbox":
+		return stripe.Sandbox, nil
+	case "production":
+		return stripe.Production, nil
+	}
+	return "", status.Errorf(codes.InvalidArgument, "invalid environment: %q (use 'sandbox' or 'production')", env)
+}
+
+func (s *rpcServer) Validate(_ context.Context, req *raterunnerv1.ValidateRequest) (*raterunnerv1.ValidateResponse, error) {
+	path, err := rpcPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, suppressed, err := validateFile(s.validator, path, req.Type)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	resp := &raterunnerv1.ValidateResponse{
+		Valid:      result.Valid,
+		Warnings:   result.Warnings,
+		Suppressed: int32(suppressed),
+	}
+	for _, e := range result.Errors {
+		resp.Errors = append(resp.Errors, &raterunnerv1.ValidationError{
+			Path:    e.Path,
+			Message: e.Message,
+			Detail:  e.Detail,
+			Rule:    e.Rule,
+		})
+	}
+	return resp, nil
+}
+
+func (s *rpcServer) Diff(ctx context.Context, req *raterunnerv1.DiffRequest) (*raterunnerv1.DiffResponse, error) {
+	path, err := rpcPath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	env, err := rpcEnvironment(req.Environment)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.LoadBillingFile(path)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "failed to load billing config: %v", err)
+	}
+	if err := validateProvider(cfg.Providers); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	apiKey, err := getAPIKey(env)
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	client, err := openStripeClient(env, apiKey, req.Refresh, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Stripe client: %w", err)
+	}
+	metaKeys, err := cfg.MetadataKeys()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	client.SetMetadataKeys(metaKeys, false)
+
+	result, _, err := provider.NewStripe(client).Diff(ctx, cfg)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to fetch from Stripe: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := diff.OutputJSON(&buf, result); err != nil {
+		return nil, fmt.Errorf("failed to write JSON output: %w", err)
+	}
+	return &raterunnerv1.DiffResponse{
+		HasDifferences: result.HasDifferences(),
+		Summary: &raterunnerv1.DiffSummary{
+			Total:         int32(result.Summary.Total),
+			Synced:        int32(result.Summary.Synced),
+			Missing:       int32(result.Summary.Missing),
+			Differs:       int32(result.Summary.Differs),
+			Skipped:       int32(result.Summary.Skipped),
+			AddonsMissing: int32(result.Summary.AddonsMissing),
+			AddonsDiffer:  int32(result.Summary.AddonsDiffer),
+		},
+		ResultJson: buf.Bytes(),
+	}, nil
+}
+
+// Apply runs the apply command in process, so it validates, locks, saves the
+// provider file and regenerates exports exactly like the CLI. Each output line
+// is streamed as progress, and the summary is sent as the result.
+func (s *rpcServer) Apply(req *raterunnerv1.ApplyRequest, stream grpc.ServerStreamingServer[raterunnerv1.ApplyEvent]) error {
+	path, err := rpcPath(req.Path)
+	if err != nil {
+		return err
+	}
+	env, err := rpcEnvironment(req.Environment)
+	if err != nil {
+		return err
+	}
+	if env == stripe.Production && !s.authenticated {
+		return status.Errorf(codes.PermissionDenied, "production applies need authentication: restart the server with %s set", serveTokenEnv)
+	}
+
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	args := []string{"raterunner", "--verbose", "apply", "--env", req.Environment}
+	if req.Force {
+		args = append(args, "--force")
+	}
+	if req.KeepGoing {
+		args = append(args, "--keep-going")
+	}
+	if req.StrictWarnings {
+		args = append(args, "--strict-warnings")
+	}
+	args = append(args, path)
+
+	progress := &lineWriter{send: func(line string) error {
+		return stream.Send(&raterunnerv1.ApplyEvent{Event: &raterunnerv1.ApplyEvent_Progress{
+			Progress: &raterunnerv1.ApplyProgress{Message: line},
+		}})
+	}}
+	var result *raterunnerv1.ApplyResult
+
+	app := newApp()
+	app.Writer = progress
+	app.ErrWriter = progress
+	app.ExitErrHandler = func(*cli.Context, error) {} // errors go back to the client; never exit the server
+	app.Metadata = map[string]any{summaryHookKey: summaryHook(func(status string, fields []summaryField) {
+		result = &raterunnerv1.ApplyResult{Status: status, Fields: make(map[string]string)}
+		for _, f := range fields {
+			value := fmt.Sprint(f.Value)
+			if d, ok := f.Value.(summaryDetail); ok {
+				data, _ := json.Marshal(d.Value)
+				value = string(data)
+			}
+			result.Fields[f.Key] = value
+		}
+	})}
+
+	runErr := app.RunContext(stream.Context(), args)
+	if err := progress.Flush(); err != nil {
+		return err
+	}
+	if result != nil {
+		if err := stream.Send(&raterunnerv1.ApplyEvent{Event: &raterunnerv1.ApplyEvent_Result{Result: result}}); err != nil {
+			return err
+		}
+	}
+	if runErr != nil {
+		return status.Error(codes.Aborted, runErr.Error())
+	}
+	return nil
+}
+
+// lineWriter calls send with every complete line written to it
+type lineWriter struct {
+	send func(line string) error
+	buf  []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if err := w.send(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush sends a trailing line without a newline
+func (w *lineWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := string(w.buf)
+	w.buf = nil
+	return w.send(line)
+}
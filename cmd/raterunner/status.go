@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/provider"
+	"raterunner/internal/stripe"
+)
+
+// statusEnvs are the environments status compares, in column order
+var statusEnvs = []stripe.Environment{stripe.Sandbox, stripe.Production}
+
+func statusAction(c *cli.Context) error {
+	if c.Bool("all-products") {
+		return statusAllProductsAction(c)
+	}
+
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadBillingInput(c, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to load billing config: %w", err)
+	}
+	results, err := fetchStatus(c, cfg)
+	if err != nil {
+		return err
+	}
+
+	// The status table is the command's result, so it is written even in quiet mode
+	out := getResultOutput(c)
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(statusByEnv(results), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal status: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	} else {
+		printStatus(out, results)
+	}
+
+	status := "ok"
+	fields := []summaryField{}
+	for i, result := range results {
+		envStatus := "ok"
+		if result.HasDifferences() {
+			envStatus = "differs"
+			status = "differs"
+		}
+		fields = append(fields, summaryField{string(statusEnvs[i]), envStatus})
+	}
+	printSummary(c, status, fields...)
+
+	if status != "ok" {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// statusAllProductsAction prints the status of every product under products/,
+// one table per product
+func statusAllProductsAction(c *cli.Context) error {
+	if c.NArg() > 0 || c.String("product") != "" {
+		return fmt.Errorf("--all-products takes no billing file or --product")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	products, err := config.FindProducts(wd)
+	if err != nil {
+		return err
+	}
+
+	out := getResultOutput(c)
+
+	status := "ok"
+	fields := []summaryField{}
+	byProduct := make(map[string]map[string]*diff.DiffResult, len(products))
+	for i, product := range products {
+		cfg, err := config.LoadBillingFile(product.Path)
+		if err != nil {
+			return fmt.Errorf("%s: failed to load billing config: %w", product.Name, err)
+		}
+		results, err := fetchStatus(c, cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", product.Name, err)
+		}
+
+		productStatus := "ok"
+		for _, result := range results {
+			if result.HasDifferences() {
+				productStatus = "differs"
+				status = "differs"
+			}
+		}
+		fields = append(fields, summaryField{product.Name, productStatus})
+
+		if c.Bool("json") {
+			byProduct[product.Name] = statusByEnv(results)
+			continue
+		}
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		path := product.Path
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			path = rel
+		}
+		fmt.Fprintf(out, "==> %s (%s)\n", product.Name, path)
+		printStatus(out, results)
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(byProduct, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal status: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	}
+
+	printSummary(c, status, fields...)
+
+	if status != "ok" {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// fetchStatus compares cfg with every environment in statusEnvs
+func fetchStatus(c *cli.Context, cfg *config.BillingConfig) ([]*diff.DiffResult, error) {
+	if err := validateProvider(cfg.Providers); err != nil {
+		return nil, err
+	}
+	metaKeys, err := cfg.MetadataKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	// Clients are created up front: request logging is set up globally, and both
+	// keys are checked before anything goes over the network
+	clients := make([]*stripe.Client, len(statusEnvs))
+	for i, env := range statusEnvs {
+		client, err := connectStripe(c, env)
+		if err != nil {
+			return nil, err
+		}
+		client.SetMetadataKeys(metaKeys, false)
+		clients[i] = client
+	}
+
+	results := make([]*diff.DiffResult, len(statusEnvs))
+	cachedAt := make([]time.Time, len(statusEnvs))
+	errs := make([]error, len(statusEnvs))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client *stripe.Client) {
+			defer wg.Done()
+			result, fetched, err := provider.NewStripe(client).Diff(c.Context, cfg)
+			if err != nil {
+				errs[i] = fmt.Errorf("failed to fetch from Stripe (%s): %w", statusEnvs[i], err)
+				return
+			}
+			cachedAt[i] = fetched
+			results[i] = result
+		}(i, client)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, fetched := range cachedAt {
+		noteCachedProducts(c, string(statusEnvs[i]), fetched)
+		stabilizeDiff(c, results[i])
+	}
+	return results, nil
+}
+
+// statusByEnv keys results by environment for JSON output
+func statusByEnv(results []*diff.DiffResult) map[string]*diff.DiffResult {
+	byEnv := make(map[string]*diff.DiffResult, len(results))
+	for i, result := range results {
+		byEnv[string(statusEnvs[i])] = result
+	}
+	return byEnv
+}
+
+// printStatus writes one row per plan with its status in each environment,
+// followed by the details of every plan that isn't in sync
+func printStatus(w io.Writer, results []*diff.DiffResult) {
+	fmt.Fprintf(w, "%-20s", "PLAN")
+	for _, env := range statusEnvs {
+		fmt.Fprintf(w, " %-12s", strings.ToUpper(string(env)))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, strings.Repeat("-", 20+13*len(statusEnvs)))
+
+	// Every result lists the same plans in config order
+	for p, plan := range results[0].Plans {
+		fmt.Fprintf(w, "%-20s", plan.PlanID)
+		for _, result := range results {
+			fmt.Fprintf(w, " %-12s", "["+string(result.Plans[p].Status)+"]")
+		}
+		fmt.Fprintln(w)
+	}
+
+	var details []string
+	for i, result := range results {
+		if v := result.CatalogVersion; v != nil && v.Status == diff.StatusDiffers {
+			details = append(details, fmt.Sprintf("%s: catalog version %s, local %s", statusEnvs[i], strings.Join(v.Stripe, ", "), v.Local))
+		}
+		for _, plan := range result.Plans {
+			if plan.Status == diff.StatusDiffers || plan.Status == diff.StatusMissing {
+				details = append(details, fmt.Sprintf("%s: %s: %s", statusEnvs[i], plan.PlanID, plan.Details))
+			}
+		}
+		for _, addon := range result.Addons {
+			if addon.Status == diff.StatusDiffers || addon.Status == diff.StatusMissing {
+				details = append(details, fmt.Sprintf("%s: addon %s: %s", statusEnvs[i], addon.AddonID, addon.Details))
+			}
+		}
+	}
+	if len(details) > 0 {
+		fmt.Fprintln(w)
+		for _, d := range details {
+			fmt.Fprintf(w, "  %s\n", d)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/report"
+)
+
+func subscribersAction(c *cli.Context) error {
+	filePath, err := billingFileArg(c)
+	if err != nil {
+		return err
+	}
+
+	format := c.String("format")
+	if format != "text" && format != "csv" && format != "json" {
+		return fmt.Errorf("invalid format: %s (use 'text', 'csv', or 'json')", format)
+	}
+
+	env, stripeEnv, err := resolveStripeEnv(c)
+	if err != nil {
+		return err
+	}
+
+	providerPath := config.ProviderFilePath(filePath, "stripe", env)
+	providerCfg, err := loadProviderFile(providerPath)
+	if err != nil {
+		return fmt.Errorf("failed to load %s (run 'raterunner apply --env %s' first): %w", providerPath, env, err)
+	}
+
+	client, err := connectStripe(c, stripeEnv)
+	if err != nil {
+		return err
+	}
+
+	items, err := client.FetchSubscriptionItems(c.Context)
+	if err != nil {
+		return err
+	}
+	rows := report.Subscribers(items, providerCfg)
+
+	// The report is the command's result, so it is written even in quiet mode
+	out := getResultOutput(c)
+
+	switch format {
+	case "csv":
+		if err := report.WriteSubscribersCSV(out, rows); err != nil {
+			return err
+		}
+	case "json":
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal subscribers: %w", err)
+		}
+		fmt.Fprintln(out, string(data))
+	default:
+		printSubscribers(out, rows)
+	}
+
+	active, trialing, unknown := 0, 0, 0
+	for _, row := range rows {
+		active += row.Active
+		trialing += row.Trialing
+		if row.Kind == report.KindUnknown {
+			unknown++
+		}
+	}
+	printSummary(c, "ok",
+		summaryField{"env", env},
+		summaryField{"active", active},
+		summaryField{"trialing", trialing},
+		summaryField{"unknown_prices", unknown})
+	return nil
+}
+
+// printSubscribers writes subscriber counts as a table
+func printSubscribers(w io.Writer, rows []report.SubscriberCount) {
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "No active or trialing subscriptions")
+		return
+	}
+
+	fmt.Fprintf(w, "%-7s %-20s %-10s %-32s %8s %8s %8s\n", "KIND", "ID", "INTERVAL", "PRICE", "ACTIVE", "TRIALING", "QTY")
+	for _, row := range rows {
+		id := row.ID
+		if id == "" {
+			id = "-"
+		}
+		interval := row.Interval
+		if interval == "" {
+			interval = "-"
+		}
+		fmt.Fprintf(w, "%-7s %-20s %-10s %-32s %8d %8d %8d\n", row.Kind, id, interval, row.PriceID, row.Active, row.Trialing, row.Quantity)
+	}
+
+	for _, row := range rows {
+		if row.Kind == report.KindUnknown {
+			fmt.Fprintln(w, "\nPrices marked unknown aren't in the provider file (created outside raterunner or by an older sync).")
+			break
+		}
+	}
+}
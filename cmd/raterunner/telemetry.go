@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+
+	stripeapi "github.com/stripe/stripe-go/v82"
+	"github.com/urfave/cli/v2"
+
+	"raterunner/internal/config"
+	"raterunner/internal/lock"
+	"raterunner/internal/stripe"
+	"raterunner/internal/telemetry"
+)
+
+// trackCommands wraps the action of every command, subcommands included, so
+// each run is reported when the user opted in to telemetry
+func trackCommands(commands []*cli.Command, parent string) {
+	wrapActions(commands, parent, func(name string, action cli.ActionFunc) cli.ActionFunc {
+		return func(c *cli.Context) error {
+			start := time.Now()
+			err := action(c)
+			reportCommand(c, name, time.Since(start), err)
+			return err
+		}
+	})
+}
+
+// telemetrySettings returns the user settings when telemetry is on. Only the
+// user settings count: a project .raterunner.yaml can't opt anyone in.
+func telemetrySettings() (*config.CLISettings, bool) {
+	if telemetry.Disabled() != "" {
+		return nil, false
+	}
+	settings, err := config.LoadSettings()
+	if err != nil || !settings.Telemetry || settings.TelemetryID == "" {
+		return nil, false
+	}
+	return settings, true
+}
+
+// telemetryEndpoint returns where events go
+func telemetryEndpoint(settings *config.CLISettings) string {
+	if settings.TelemetryEndpoint != "" {
+		return settings.TelemetryEndpoint
+	}
+	return telemetry.DefaultEndpoint
+}
+
+// reportCommand sends the event of one run. Failures to send are ignored:
+// telemetry never changes how a command ends.
+func reportCommand(c *cli.Context, command string, duration time.Duration, err error) {
+	settings, ok := telemetrySettings()
+	if !ok {
+		return
+	}
+	_ = telemetry.Send(c.Context, telemetryEndpoint(settings), commandEvent(c, settings.TelemetryID, command, duration, err))
+}
+
+// commandEvent describes one run without its arguments or flag values
+func commandEvent(c *cli.Context, installID, command string, duration time.Duration, err error) telemetry.Event {
+	event := telemetry.Event{
+		InstallID:  installID,
+		Version:    version,
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		Command:    command,
+		DurationMS: duration.Milliseconds(),
+		Status:     "ok",
+		CI:         os.Getenv("CI") != "",
+	}
+	for _, name := range c.FlagNames() {
+		if len(name) > 1 && !slices.Contains(event.Flags, name) {
+			event.Flags = append(event.Flags, name)
+		}
+	}
+	slices.Sort(event.Flags)
+	if err != nil {
+		event.Status = "error"
+		event.ErrorClass = errorClass(err)
+	}
+	return event
+}
+
+// errorClass buckets an error by type, never by message, which may hold
+// file paths or IDs
+func errorClass(err error) string {
+	var exitErr cli.ExitCoder
+	var held *lock.HeldError
+	var syncErrs stripe.SyncErrors
+	var syncErr stripe.SyncError
+	var stripeErr *stripeapi.Error
+	var netErr net.Error
+	var pathErr *fs.PathError
+	switch {
+	case errors.As(err, &held):
+		return "lock_held"
+	case errors.As(err, &syncErrs), errors.As(err, &syncErr):
+		return "sync"
+	case errors.As(err, &stripeErr):
+		return "stripe_api"
+	case errors.As(err, &netErr):
+		return "network"
+	case errors.As(err, &pathErr):
+		return "file"
+	case errors.As(err, &exitErr):
+		return fmt.Sprintf("exit_%d", exitErr.ExitCode())
+	}
+	return "other"
+}
+
+// telemetryStatusAction shows whether telemetry is on and what an event holds
+func telemetryStatusAction(c *cli.Context) error {
+	out := getResultOutput(c)
+
+	settings, err := config.LoadSettings()
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	switch {
+	case !settings.Telemetry:
+		fmt.Fprintln(out, "Telemetry: off (turn on with 'raterunner config set telemetry true')")
+		return nil
+	case telemetry.Disabled() != "":
+		fmt.Fprintf(out, "Telemetry: off (%s, overriding telemetry: true in the settings)\n", telemetry.Disabled())
+		return nil
+	}
+
+	fmt.Fprintln(out, "Telemetry: on (turn off with 'raterunner config set telemetry false')")
+	fmt.Fprintf(out, "Endpoint: %s\n", telemetryEndpoint(settings))
+	fmt.Fprintf(out, "Install ID: %s\n", settings.TelemetryID)
+	fmt.Fprintln(out, "Each command sends its name, the names of the flags set (not their values), its duration,")
+	fmt.Fprintln(out, "ok or error with an error class, the raterunner version, OS, architecture and whether it ran in CI.")
+	return nil
+}
@@ -0,0 +1,233 @@
+package catalog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"raterunner/internal/config"
+)
+
+// Formats are the catalog export formats Parse understands: other providers'
+// catalog exports, and a plan-per-row spreadsheet
+var Formats = []string{"paddle", "chargebee", "csv"}
+
+// Result is a billing config converted from a catalog export, with notes about
+// anything that couldn't be carried over
+type Result struct {
+	Billing  *config.BillingConfig
+	Warnings []string
+}
+
+// Parse converts a catalog export from another billing provider, or a CSV
+// plan list, into a billing config targeting Stripe
+func Parse(format string, data []byte) (*Result, error) {
+	var entries []entry
+	var warnings []string
+	var err error
+
+	switch format {
+	case "paddle":
+		entries, warnings, err = parsePaddle(data)
+	case "chargebee":
+		entries, warnings, err = parseChargebee(data)
+	case "csv":
+		return parseCSV(data)
+	default:
+		return nil, fmt.Errorf("unknown format: %s (use %s)", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := build(entries)
+	result.Warnings = append(warnings, result.Warnings...)
+	return result, nil
+}
+
+// Entry kinds
+const (
+	kindPlan  = "plan"
+	kindAddon = "addon"
+)
+
+// entry is one price of a product, in a provider-neutral form
+type entry struct {
+	kind        string
+	id          string // raterunner ID, see toID
+	name        string
+	description string
+	interval    string // billing.yaml price key: monthly, quarterly, yearly, one_time
+	currency    string // lowercase ISO code
+	price       config.Price
+	trialDays   int
+}
+
+// intervalKey maps a provider billing period to a billing.yaml price key
+func intervalKey(unit string, count int) (string, bool) {
+	switch {
+	case unit == "month" && count == 1:
+		return "monthly", true
+	case unit == "month" && count == 3:
+		return "quarterly", true
+	case unit == "year" && count == 1:
+		return "yearly", true
+	}
+	return "", false
+}
+
+// trialDays converts a provider trial period to days
+func trialDays(unit string, count int) (int, bool) {
+	switch unit {
+	case "day":
+		return count, true
+	case "week":
+		return count * 7, true
+	}
+	return 0, false
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// toID turns a provider ID or name into a billing.yaml ID (^[a-z][a-z0-9_]*$)
+func toID(s string) string {
+	id := strings.Trim(nonIDChars.ReplaceAllString(strings.ToLower(s), "_"), "_")
+	id = strings.TrimSuffix(id, "_plan")
+	if id == "" || id[0] < 'a' || id[0] > 'z' {
+		id = "plan_" + id
+	}
+	return id
+}
+
+// build assembles plans and addons from entries. The most common currency
+// becomes settings.currency, and flat or per-unit prices in other currencies
+// become currency_prices.
+func build(entries []entry) *Result {
+	result := &Result{
+		Billing: &config.BillingConfig{
+			Version:   1,
+			Providers: []string{"stripe"},
+		},
+	}
+
+	currency := primaryCurrency(entries)
+	if currency != "" && currency != "usd" {
+		result.Billing.Settings = &config.Settings{Currency: currency}
+	}
+
+	plans := make(map[string]*config.Plan)
+	addons := make(map[string]*config.Addon)
+	addonPriced := make(map[string]bool)
+	var planOrder, addonOrder []string
+
+	// Prices in the main currency first, so other currencies have a price to attach to
+	sorted := make([]entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].currency == currency && sorted[j].currency != currency
+	})
+
+	for _, e := range sorted {
+		if e.kind == kindAddon {
+			addon, ok := addons[e.id]
+			if !ok {
+				addon = &config.Addon{ID: e.id, Name: e.name}
+				addons[e.id] = addon
+				addonOrder = append(addonOrder, e.id)
+			}
+			if e.interval != "one_time" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("addon '%s': %s %s price imported as a one-time addon price", e.id, e.interval, strings.ToUpper(e.currency)))
+			}
+			switch {
+			case e.currency == currency && addonPriced[e.id]:
+				result.Warnings = append(result.Warnings, fmt.Sprintf("addon '%s': several prices, kept the first", e.id))
+			case e.currency == currency:
+				addon.Price = e.price
+				addonPriced[e.id] = true
+			case addonPriced[e.id]:
+				addon.Price = withCurrency(addon.Price, e, &result.Warnings)
+			default:
+				result.Warnings = append(result.Warnings, fmt.Sprintf("addon '%s': price only exists in %s, skipped", e.id, strings.ToUpper(e.currency)))
+			}
+			continue
+		}
+
+		plan, ok := plans[e.id]
+		if !ok {
+			plan = &config.Plan{ID: e.id, Name: e.name, Description: e.description, Prices: make(map[string]config.Price)}
+			plans[e.id] = plan
+			planOrder = append(planOrder, e.id)
+		}
+		if e.trialDays > plan.TrialDays {
+			plan.TrialDays = e.trialDays
+		}
+
+		if e.currency == currency {
+			if _, exists := plan.Prices[e.interval]; exists {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("plan '%s': several %s prices, kept the first", e.id, e.interval))
+				continue
+			}
+			plan.Prices[e.interval] = e.price
+			continue
+		}
+		existing, ok := plan.Prices[e.interval]
+		if !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("plan '%s': %s price only exists in %s, skipped", e.id, e.interval, strings.ToUpper(e.currency)))
+			continue
+		}
+		plan.Prices[e.interval] = withCurrency(existing, e, &result.Warnings)
+	}
+
+	for _, id := range planOrder {
+		plan := plans[id]
+		if _, ok := plan.Prices["one_time"]; ok && len(plan.Prices) == 1 {
+			plan.BillingModel = "one_time"
+			plan.TrialDays = 0
+		}
+		result.Billing.Plans = append(result.Billing.Plans, *plan)
+	}
+	for _, id := range addonOrder {
+		result.Billing.Addons = append(result.Billing.Addons, *addons[id])
+	}
+	return result
+}
+
+// withCurrency adds an entry's amount as a currency_prices override
+func withCurrency(price config.Price, e entry, warnings *[]string) config.Price {
+	amount := e.price.Amount
+	if e.price.PriceType() == "per_unit" {
+		amount = e.price.PerUnit
+	}
+	if e.price.PriceType() == "tiered" || price.PriceType() == "tiered" || e.price.PriceType() != price.PriceType() {
+		*warnings = append(*warnings, fmt.Sprintf("%s '%s': %s %s price doesn't fit currency_prices, skipped", e.kind, e.id, e.interval, strings.ToUpper(e.currency)))
+		return price
+	}
+
+	prices := make(map[string]int, len(price.CurrencyPrices)+1)
+	for k, v := range price.CurrencyPrices {
+		prices[k] = v
+	}
+	prices[e.currency] = amount
+	price.CurrencyPrices = prices
+	return price
+}
+
+// primaryCurrency returns the currency most prices use, preferring usd on a tie
+func primaryCurrency(entries []entry) string {
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[e.currency]++
+	}
+	best := ""
+	for currency, n := range counts {
+		switch {
+		case best == "",
+			n > counts[best],
+			n == counts[best] && currency == "usd",
+			n == counts[best] && best != "usd" && currency < best:
+			best = currency
+		}
+	}
+	return best
+}
@@ -0,0 +1,155 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"raterunner/internal/config"
+)
+
+// chargebeeItem is an item from Chargebee's list items endpoint
+type chargebeeItem struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Type        string `json:"type"` // plan, addon or charge
+	Status      string `json:"status"`
+}
+
+// chargebeeItemPrice is an item price from Chargebee's list item prices endpoint
+type chargebeeItemPrice struct {
+	ID              string          `json:"id"`
+	ItemID          string          `json:"item_id"`
+	PricingModel    string          `json:"pricing_model"`
+	Price           int             `json:"price"`
+	Tiers           []chargebeeTier `json:"tiers"`
+	Period          int             `json:"period"`
+	PeriodUnit      string          `json:"period_unit"`
+	CurrencyCode    string          `json:"currency_code"`
+	TrialPeriod     int             `json:"trial_period"`
+	TrialPeriodUnit string          `json:"trial_period_unit"`
+	Status          string          `json:"status"`
+}
+
+type chargebeeTier struct {
+	StartingUnit int  `json:"starting_unit"`
+	EndingUnit   *int `json:"ending_unit"`
+	Price        int  `json:"price"`
+}
+
+// parseChargebee reads Chargebee list responses: {"list": [...]} with both
+// {"item": ...} and {"item_price": ...} records, as produced by concatenating
+// the items and item prices exports. Archived records and charge items are
+// skipped.
+func parseChargebee(data []byte) ([]entry, []string, error) {
+	var export struct {
+		List []struct {
+			Item      *chargebeeItem      `json:"item"`
+			ItemPrice *chargebeeItemPrice `json:"item_price"`
+		} `json:"list"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil || export.List == nil {
+		return nil, nil, fmt.Errorf("failed to parse Chargebee catalog: expected {\"list\": [...]} of items and item prices")
+	}
+
+	items := make(map[string]*chargebeeItem)
+	var prices []*chargebeeItemPrice
+	for _, record := range export.List {
+		if record.Item != nil {
+			items[record.Item.ID] = record.Item
+		}
+		if record.ItemPrice != nil {
+			prices = append(prices, record.ItemPrice)
+		}
+	}
+
+	var entries []entry
+	var warnings []string
+	for _, price := range prices {
+		if price.Status == "archived" {
+			continue
+		}
+		item, ok := items[price.ItemID]
+		if !ok {
+			warnings = append(warnings, fmt.Sprintf("item price %s: item '%s' isn't in the export, skipped", price.ID, price.ItemID))
+			continue
+		}
+		if item.Status == "archived" {
+			continue
+		}
+
+		kind := kindPlan
+		switch item.Type {
+		case "addon":
+			kind = kindAddon
+		case "charge":
+			warnings = append(warnings, fmt.Sprintf("item price %s: charge items aren't supported, skipped", price.ID))
+			continue
+		}
+
+		interval := "one_time"
+		if price.PeriodUnit != "" {
+			key, ok := intervalKey(price.PeriodUnit, price.Period)
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("item price %s: billing period every %d %s isn't supported, skipped", price.ID, price.Period, price.PeriodUnit))
+				continue
+			}
+			interval = key
+		}
+
+		p, err := chargebeePrice(price)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("item price %s: %v, skipped", price.ID, err))
+			continue
+		}
+
+		e := entry{
+			kind:        kind,
+			id:          toID(item.ID),
+			name:        item.Name,
+			description: item.Description,
+			interval:    interval,
+			currency:    strings.ToLower(price.CurrencyCode),
+			price:       p,
+		}
+		if price.TrialPeriod > 0 {
+			days, ok := trialDays(price.TrialPeriodUnit, price.TrialPeriod)
+			if !ok {
+				warnings = append(warnings, fmt.Sprintf("item price %s: trial of %d %s isn't supported, skipped the trial", price.ID, price.TrialPeriod, price.TrialPeriodUnit))
+			}
+			e.trialDays = days
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, warnings, nil
+}
+
+// chargebeePrice converts an item price's pricing model to a billing.yaml price
+func chargebeePrice(price *chargebeeItemPrice) (config.Price, error) {
+	switch price.PricingModel {
+	case "flat_fee", "":
+		return config.Price{Amount: price.Price}, nil
+	case "per_unit":
+		return config.Price{PerUnit: price.Price}, nil
+	case "tiered", "volume":
+		p := config.Price{Mode: "graduated"}
+		if price.PricingModel == "volume" {
+			p.Mode = "volume"
+		}
+		for _, tier := range price.Tiers {
+			var upTo any = "unlimited"
+			if tier.EndingUnit != nil {
+				upTo = *tier.EndingUnit
+			}
+			p.Tiers = append(p.Tiers, config.PriceTier{UpTo: upTo, Amount: tier.Price})
+		}
+		if len(p.Tiers) == 0 {
+			return config.Price{}, fmt.Errorf("%s pricing without tiers", price.PricingModel)
+		}
+		return p, nil
+	default:
+		return config.Price{}, fmt.Errorf("%s pricing isn't supported", price.PricingModel)
+	}
+}
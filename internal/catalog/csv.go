@@ -0,0 +1,220 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"raterunner/internal/config"
+)
+
+// csvColumns are the CSV columns with a fixed meaning. Every other column is a
+// limit named after its header.
+var csvColumns = map[string]bool{
+	"id":          true,
+	"name":        true,
+	"description": true,
+	"trial_days":  true,
+	"monthly":     true,
+	"quarterly":   true,
+	"yearly":      true,
+	"one_time":    true,
+}
+
+var (
+	csvAmount   = regexp.MustCompile(`^\d+(\.\d{1,2})?$`)
+	csvRate     = regexp.MustCompile(`^(\d+)\s*/\s*([a-z]+)$`)
+	csvLimitKey = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// parseCSV reads one plan per row. Prices are amounts in major units of
+// settings.currency ("29" or "29.00"); limit cells are a number, "unlimited",
+// true/false or a rate such as "1000/minute", and the column's values decide
+// its entitlement type. Empty cells are left out.
+func parseCSV(data []byte) (*Result, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("failed to parse CSV: expected a header row and at least one plan")
+	}
+
+	header := make([]string, len(rows[0]))
+	seen := make(map[string]bool)
+	for i, h := range rows[0] {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		if !csvColumns[h] && !csvLimitKey.MatchString(h) {
+			return nil, fmt.Errorf("column %d: '%s' isn't a valid limit name (use lowercase letters, digits and underscores)", i+1, rows[0][i])
+		}
+		if seen[h] {
+			return nil, fmt.Errorf("column '%s' appears more than once", h)
+		}
+		seen[h] = true
+		header[i] = h
+	}
+	if !seen["id"] || !seen["name"] {
+		return nil, fmt.Errorf("CSV must have 'id' and 'name' columns")
+	}
+
+	result := &Result{
+		Billing: &config.BillingConfig{
+			Version:      1,
+			Providers:    []string{"stripe"},
+			Entitlements: make(map[string]config.Entitlement),
+		},
+	}
+
+	ids := make(map[string]int)
+	for n, row := range rows[1:] {
+		line := n + 2
+		plan := config.Plan{Prices: make(map[string]config.Price)}
+
+		for i, cell := range row {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+			column := header[i]
+
+			switch column {
+			case "id":
+				plan.ID = cell
+			case "name":
+				plan.Name = cell
+			case "description":
+				plan.Description = cell
+			case "trial_days":
+				days, err := strconv.Atoi(cell)
+				if err != nil || days < 0 {
+					return nil, fmt.Errorf("line %d: trial_days must be a whole number of days, got '%s'", line, cell)
+				}
+				plan.TrialDays = days
+			case "monthly", "quarterly", "yearly", "one_time":
+				amount, err := parseCSVAmount(cell)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s: %w", line, column, err)
+				}
+				plan.Prices[column] = config.Price{Amount: amount}
+			default:
+				value, kind, err := parseCSVLimit(cell)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s: %w", line, column, err)
+				}
+				if err := addCSVEntitlement(result.Billing.Entitlements, column, kind); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				}
+				if plan.Limits == nil {
+					plan.Limits = make(map[string]any)
+				}
+				plan.Limits[column] = value
+			}
+		}
+
+		if plan.ID == "" {
+			return nil, fmt.Errorf("line %d: id is empty", line)
+		}
+		if first, ok := ids[plan.ID]; ok {
+			return nil, fmt.Errorf("line %d: plan '%s' is already defined on line %d", line, plan.ID, first)
+		}
+		ids[plan.ID] = line
+		if plan.Name == "" {
+			plan.Name = plan.ID
+			result.Warnings = append(result.Warnings, fmt.Sprintf("line %d: plan '%s' has no name, used its ID", line, plan.ID))
+		}
+		if len(plan.Prices) == 0 {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("line %d: plan '%s' has no prices, add them before applying", line, plan.ID))
+		}
+		if _, ok := plan.Prices["one_time"]; ok && len(plan.Prices) == 1 {
+			plan.BillingModel = "one_time"
+		}
+
+		result.Billing.Plans = append(result.Billing.Plans, plan)
+	}
+
+	// A column of only "unlimited" values is an int limit
+	for key, ent := range result.Billing.Entitlements {
+		if ent.Type == "" {
+			result.Billing.Entitlements[key] = config.Entitlement{Type: "int"}
+		}
+	}
+
+	return result, nil
+}
+
+// parseCSVAmount converts a price in major units to the smallest currency unit
+func parseCSVAmount(cell string) (int, error) {
+	if !csvAmount.MatchString(cell) {
+		return 0, fmt.Errorf("expected an amount such as 29 or 29.00, got '%s'", cell)
+	}
+	whole, frac, _ := strings.Cut(cell, ".")
+	for len(frac) < 2 {
+		frac += "0"
+	}
+	amount, err := strconv.Atoi(whole + frac)
+	if err != nil {
+		return 0, fmt.Errorf("amount '%s' is out of range", cell)
+	}
+	return amount, nil
+}
+
+// parseCSVLimit parses a limit cell, returning its value and the entitlement
+// type it implies ("" for unlimited, which fits int and rate)
+func parseCSVLimit(cell string) (any, string, error) {
+	lower := strings.ToLower(cell)
+	switch lower {
+	case config.UnlimitedKeyword:
+		return config.Unlimited{}, "", nil
+	case "true", "yes":
+		return true, "bool", nil
+	case "false", "no":
+		return false, "bool", nil
+	}
+	if n, err := strconv.Atoi(cell); err == nil {
+		return n, "int", nil
+	}
+	if m := csvRate.FindStringSubmatch(lower); m != nil {
+		limit, _ := strconv.Atoi(m[1])
+		rl := config.RateLimit{Limit: limit, Per: m[2]}
+		if _, err := config.ParseRateLimit(rl); err != nil {
+			return nil, "", err
+		}
+		return rl, "rate", nil
+	}
+	return nil, "", fmt.Errorf("expected a number, unlimited, true/false or a rate such as 1000/minute, got '%s'", cell)
+}
+
+// addCSVEntitlement records a limit column's type, rejecting columns that mix types
+func addCSVEntitlement(entitlements map[string]config.Entitlement, key, kind string) error {
+	ent, ok := entitlements[key]
+	if !ok {
+		entitlements[key] = config.Entitlement{Type: kind}
+		return nil
+	}
+
+	// Unlimited fits int and rate columns, but not bool
+	mixed := ent.Type != kind
+	if kind == "" || ent.Type == "" {
+		mixed = kind == "bool" || ent.Type == "bool"
+	}
+	if mixed {
+		return fmt.Errorf("%s: column mixes %s and %s values", key, orUnlimited(ent.Type), orUnlimited(kind))
+	}
+	if ent.Type == "" {
+		entitlements[key] = config.Entitlement{Type: kind}
+	}
+	return nil
+}
+
+// orUnlimited names the "" kind in error messages
+func orUnlimited(kind string) string {
+	if kind == "" {
+		return config.UnlimitedKeyword
+	}
+	return kind
+}
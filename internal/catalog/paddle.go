@@ -0,0 +1,126 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// paddleProduct is a product from Paddle Billing's list products endpoint
+// (GET /products?include=prices)
+type paddleProduct struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description *string        `json:"description"`
+	Status      string         `json:"status"`
+	CustomData  map[string]any `json:"custom_data"`
+	Prices      []paddlePrice  `json:"prices"`
+}
+
+type paddlePrice struct {
+	ID           string        `json:"id"`
+	Status       string        `json:"status"`
+	BillingCycle *paddlePeriod `json:"billing_cycle"`
+	TrialPeriod  *paddlePeriod `json:"trial_period"`
+	UnitPrice    paddleMoney   `json:"unit_price"`
+}
+
+type paddlePeriod struct {
+	Interval  string `json:"interval"`
+	Frequency int    `json:"frequency"`
+}
+
+type paddleMoney struct {
+	Amount       string `json:"amount"` // lowest denomination, as a string
+	CurrencyCode string `json:"currency_code"`
+}
+
+// parsePaddle reads a Paddle products response: {"data": [...]} or a bare
+// array of products, each with its prices included. Archived products and
+// prices are skipped. A product is imported as an addon when its custom_data
+// has "type": "addon".
+func parsePaddle(data []byte) ([]entry, []string, error) {
+	var products []paddleProduct
+	var wrapped struct {
+		Data []paddleProduct `json:"data"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err == nil && wrapped.Data != nil {
+		products = wrapped.Data
+	} else if err := json.Unmarshal(data, &products); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse Paddle catalog: expected {\"data\": [...]} or an array of products")
+	}
+
+	var entries []entry
+	var warnings []string
+	for _, p := range products {
+		if p.Status == "archived" {
+			continue
+		}
+
+		id := p.ID
+		if code, ok := p.CustomData["plan_code"].(string); ok && code != "" {
+			id = code
+		} else if p.Name != "" {
+			id = p.Name
+		}
+		id = toID(id)
+
+		kind := kindPlan
+		if t, _ := p.CustomData["type"].(string); t == "addon" {
+			kind = kindAddon
+		}
+		description := ""
+		if p.Description != nil {
+			description = *p.Description
+		}
+
+		if len(p.Prices) == 0 {
+			warnings = append(warnings, fmt.Sprintf("product %s (%s): no prices included, skipped (export with ?include=prices)", p.ID, p.Name))
+			continue
+		}
+
+		for _, price := range p.Prices {
+			if price.Status == "archived" {
+				continue
+			}
+
+			interval := "one_time"
+			if c := price.BillingCycle; c != nil {
+				key, ok := intervalKey(c.Interval, c.Frequency)
+				if !ok {
+					warnings = append(warnings, fmt.Sprintf("price %s: billing cycle every %d %s isn't supported, skipped", price.ID, c.Frequency, c.Interval))
+					continue
+				}
+				interval = key
+			}
+
+			amount, err := strconv.Atoi(price.UnitPrice.Amount)
+			if err != nil {
+				return nil, nil, fmt.Errorf("price %s: invalid unit_price.amount %q", price.ID, price.UnitPrice.Amount)
+			}
+
+			e := entry{
+				kind:        kind,
+				id:          id,
+				name:        p.Name,
+				description: description,
+				interval:    interval,
+				currency:    strings.ToLower(price.UnitPrice.CurrencyCode),
+			}
+			e.price.Amount = amount
+
+			if t := price.TrialPeriod; t != nil {
+				days, ok := trialDays(t.Interval, t.Frequency)
+				if !ok {
+					warnings = append(warnings, fmt.Sprintf("price %s: trial of %d %s isn't supported, skipped the trial", price.ID, t.Frequency, t.Interval))
+				}
+				e.trialDays = days
+			}
+
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, warnings, nil
+}
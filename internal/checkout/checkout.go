@@ -0,0 +1,228 @@
+package checkout
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Languages are the snippet flavors Render supports, in output order
+var Languages = []string{"curl", "node", "go"}
+
+// Default redirect URLs; {CHECKOUT_SESSION_ID} is filled in by Stripe
+const (
+	DefaultSuccessURL = "https://example.com/success?session_id={CHECKOUT_SESSION_ID}"
+	DefaultCancelURL  = "https://example.com/pricing"
+)
+
+// Session describes the Checkout Session a snippet creates
+type Session struct {
+	PlanID              string
+	Interval            string // price key from billing.yaml: monthly, yearly, one_time, ...
+	Environment         string
+	APIKeyEnv           string // environment variable holding the secret key
+	PriceID             string
+	OneTime             bool // payment mode instead of subscription mode
+	TrialDays           int
+	AutomaticTax        bool
+	AllowPromotionCodes bool
+	InvoiceFooter       string         // printed on the invoice of one-time purchases
+	InvoiceFields       []InvoiceField // custom fields of that invoice
+	SuccessURL          string
+	CancelURL           string
+}
+
+// InvoiceField is a custom field printed on the invoice
+type InvoiceField struct {
+	Name  string
+	Value string
+}
+
+// InvoiceCreation reports whether the session creates a customized invoice.
+// Subscription invoices are created anyway and use the customer's settings.
+func (s Session) InvoiceCreation() bool {
+	return s.OneTime && (s.InvoiceFooter != "" || len(s.InvoiceFields) > 0)
+}
+
+// Mode returns the Checkout Session mode for the price
+func (s Session) Mode() string {
+	if s.OneTime {
+		return "payment"
+	}
+	return "subscription"
+}
+
+// ParseLanguages validates a comma-separated --lang value; empty means all
+func ParseLanguages(value string) ([]string, error) {
+	if value == "" {
+		return Languages, nil
+	}
+
+	var langs []string
+	for _, lang := range strings.Split(value, ",") {
+		lang = strings.TrimSpace(lang)
+		if _, ok := templates[lang]; !ok {
+			return nil, fmt.Errorf("unknown language: %s (use %s)", lang, strings.Join(Languages, ", "))
+		}
+		langs = append(langs, lang)
+	}
+	return langs, nil
+}
+
+// Render writes the snippet for one language
+func Render(w io.Writer, lang string, s Session) error {
+	tmpl, ok := templates[lang]
+	if !ok {
+		return fmt.Errorf("unknown language: %s (use %s)", lang, strings.Join(Languages, ", "))
+	}
+	if err := tmpl.Execute(w, s); err != nil {
+		return fmt.Errorf("failed to render %s snippet: %w", lang, err)
+	}
+	return nil
+}
+
+var funcs = template.FuncMap{
+	// camel turns a mode into its stripe-go constant suffix: subscription -> Subscription
+	"camel": func(s string) string {
+		parts := strings.Split(s, "_")
+		for i, p := range parts {
+			if p != "" {
+				parts[i] = strings.ToUpper(p[:1]) + p[1:]
+			}
+		}
+		return strings.Join(parts, "")
+	},
+	// quote writes a string literal that JavaScript and Go both read back
+	"quote": strconv.Quote,
+	// shell single-quotes a curl argument
+	"shell": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+}
+
+var templates = map[string]*template.Template{
+	"curl": template.Must(template.New("curl").Funcs(funcs).Parse(curlTemplate)),
+	"node": template.Must(template.New("node").Funcs(funcs).Parse(nodeTemplate)),
+	"go":   template.Must(template.New("go").Funcs(funcs).Parse(goTemplate)),
+}
+
+const curlTemplate = `# Checkout for plan '{{.PlanID}}' ({{.Interval}}, {{.Environment}})
+curl https://api.stripe.com/v1/checkout/sessions \
+  -u "${{.APIKeyEnv}}:" \
+  -d mode={{.Mode}} \
+  -d "line_items[0][price]={{.PriceID}}" \
+  -d "line_items[0][quantity]=1" \
+{{- if .TrialDays}}
+  -d "subscription_data[trial_period_days]={{.TrialDays}}" \
+{{- end}}
+{{- if .AutomaticTax}}
+  -d "automatic_tax[enabled]=true" \
+{{- end}}
+{{- if .AllowPromotionCodes}}
+  -d allow_promotion_codes=true \
+{{- end}}
+{{- if .InvoiceCreation}}
+  -d "invoice_creation[enabled]=true" \
+{{- if .InvoiceFooter}}
+  --data-urlencode {{shell (print "invoice_creation[invoice_data][footer]=" .InvoiceFooter)}} \
+{{- end}}
+{{- range $i, $f := .InvoiceFields}}
+  --data-urlencode {{shell (printf "invoice_creation[invoice_data][custom_fields][%d][name]=%s" $i $f.Name)}} \
+  --data-urlencode {{shell (printf "invoice_creation[invoice_data][custom_fields][%d][value]=%s" $i $f.Value)}} \
+{{- end}}
+{{- end}}
+  --data-urlencode "success_url={{.SuccessURL}}" \
+  --data-urlencode "cancel_url={{.CancelURL}}"
+`
+
+const nodeTemplate = `// Checkout for plan '{{.PlanID}}' ({{.Interval}}, {{.Environment}})
+const stripe = require('stripe')(process.env.{{.APIKeyEnv}});
+
+const session = await stripe.checkout.sessions.create({
+  mode: '{{.Mode}}',
+  line_items: [{ price: '{{.PriceID}}', quantity: 1 }],
+{{- if .TrialDays}}
+  subscription_data: { trial_period_days: {{.TrialDays}} },
+{{- end}}
+{{- if .AutomaticTax}}
+  automatic_tax: { enabled: true },
+{{- end}}
+{{- if .AllowPromotionCodes}}
+  allow_promotion_codes: true,
+{{- end}}
+{{- if .InvoiceCreation}}
+  invoice_creation: {
+    enabled: true,
+    invoice_data: {
+{{- if .InvoiceFooter}}
+      footer: {{quote .InvoiceFooter}},
+{{- end}}
+{{- if .InvoiceFields}}
+      custom_fields: [
+{{- range .InvoiceFields}}
+        { name: {{quote .Name}}, value: {{quote .Value}} },
+{{- end}}
+      ],
+{{- end}}
+    },
+  },
+{{- end}}
+  success_url: '{{.SuccessURL}}',
+  cancel_url: '{{.CancelURL}}',
+});
+// Redirect the customer to session.url
+`
+
+const goTemplate = `// Checkout for plan '{{.PlanID}}' ({{.Interval}}, {{.Environment}})
+// import (
+//	"os"
+//
+//	"github.com/stripe/stripe-go/v82"
+//	"github.com/stripe/stripe-go/v82/checkout/session"
+// )
+stripe.Key = os.Getenv("{{.APIKeyEnv}}")
+
+params := &stripe.CheckoutSessionParams{
+	Mode: stripe.String(string(stripe.CheckoutSessionMode{{camel .Mode}})),
+	LineItems: []*stripe.CheckoutSessionLineItemParams{
+		{Price: stripe.String("{{.PriceID}}"), Quantity: stripe.Int64(1)},
+	},
+{{- if .TrialDays}}
+	SubscriptionData: &stripe.CheckoutSessionSubscriptionDataParams{
+		TrialPeriodDays: stripe.Int64({{.TrialDays}}),
+	},
+{{- end}}
+{{- if .AutomaticTax}}
+	AutomaticTax: &stripe.CheckoutSessionAutomaticTaxParams{Enabled: stripe.Bool(true)},
+{{- end}}
+{{- if .AllowPromotionCodes}}
+	AllowPromotionCodes: stripe.Bool(true),
+{{- end}}
+{{- if .InvoiceCreation}}
+	InvoiceCreation: &stripe.CheckoutSessionInvoiceCreationParams{
+		Enabled: stripe.Bool(true),
+		InvoiceData: &stripe.CheckoutSessionInvoiceCreationInvoiceDataParams{
+{{- if .InvoiceFooter}}
+			Footer: stripe.String({{quote .InvoiceFooter}}),
+{{- end}}
+{{- if .InvoiceFields}}
+			CustomFields: []*stripe.CheckoutSessionInvoiceCreationInvoiceDataCustomFieldParams{
+{{- range .InvoiceFields}}
+				{Name: stripe.String({{quote .Name}}), Value: stripe.String({{quote .Value}})},
+{{- end}}
+			},
+{{- end}}
+		},
+	},
+{{- end}}
+	SuccessURL: stripe.String("{{.SuccessURL}}"),
+	CancelURL:  stripe.String("{{.CancelURL}}"),
+}
+s, err := session.New(params)
+if err != nil {
+	return err
+}
+// Redirect the customer to s.URL
+`
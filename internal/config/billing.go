@@ -1,5 +1,15 @@
 package config
 
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
 // BillingConfig represents the full billing configuration
 type BillingConfig struct {
 	Version      int                    `yaml:"version" json:"version"`
@@ -8,14 +18,211 @@ type BillingConfig struct {
 	Entitlements map[string]Entitlement `yaml:"entitlements" json:"entitlements"`
 	Plans        []Plan                 `yaml:"plans" json:"plans"`
 	Addons       []Addon                `yaml:"addons" json:"addons"`
+	Credits      []CreditPack           `yaml:"credits,omitempty" json:"credits,omitempty"`
+	Meters       map[string]Meter       `yaml:"meters,omitempty" json:"meters,omitempty"` // meter key -> meter
 	Promotions   []Promotion            `yaml:"promotions" json:"promotions"`
+
+	// Renames (string) or omits (false) metadata fields, keyed by default name
+	MetadataMapping map[string]any `yaml:"metadata_mapping,omitempty" json:"metadata_mapping,omitempty"`
+
+	// Pins the billing schema version used for validation (default: the newest)
+	SchemaVersion int `yaml:"schema_version,omitempty" json:"schema_version,omitempty"`
 }
 
 // Settings contains global billing settings
 type Settings struct {
-	Currency  string `yaml:"currency,omitempty" json:"currency,omitempty"`
-	TrialDays int    `yaml:"trial_days,omitempty" json:"trial_days,omitempty"`
-	GraceDays int    `yaml:"grace_days,omitempty" json:"grace_days,omitempty"`
+	Currency  string   `yaml:"currency,omitempty" json:"currency,omitempty"`
+	TrialDays int      `yaml:"trial_days,omitempty" json:"trial_days,omitempty"`
+	GraceDays int      `yaml:"grace_days,omitempty" json:"grace_days,omitempty"`
+	Trial     *Trial   `yaml:"trial,omitempty" json:"trial,omitempty"`
+	Dunning   *Dunning `yaml:"dunning,omitempty" json:"dunning,omitempty"`
+	Invoice   *Invoice `yaml:"invoice,omitempty" json:"invoice,omitempty"`
+
+	// Stripe Tax: when enabled, products get tax codes and prices a tax behavior
+	AutomaticTax bool   `yaml:"automatic_tax,omitempty" json:"automatic_tax,omitempty"`
+	TaxCode      string `yaml:"tax_code,omitempty" json:"tax_code,omitempty"`         // default product tax code (txcd_...)
+	TaxBehavior  string `yaml:"tax_behavior,omitempty" json:"tax_behavior,omitempty"` // exclusive (default) or inclusive
+
+	// Prefix for the metadata keys raterunner manages (e.g. "raterunner." gives raterunner.plan_code)
+	MetadataPrefix string `yaml:"metadata_prefix,omitempty" json:"metadata_prefix,omitempty"`
+}
+
+// Trial end behaviors when a trial ends without a payment method
+const (
+	TrialEndCancel    = "cancel"
+	TrialEndPause     = "pause"
+	TrialEndDowngrade = "downgrade"
+)
+
+// Downgrade policies: when a self-serve downgrade takes effect
+const (
+	DowngradeImmediate   = "immediate"     // right away, with proration
+	DowngradeEndOfPeriod = "end_of_period" // when the current billing period ends
+	DowngradeBlocked     = "blocked"       // not self-serve; customers contact support
+)
+
+// Trial configures how trials start and end
+type Trial struct {
+	RequirePaymentMethod *bool  `yaml:"require_payment_method,omitempty" json:"require_payment_method,omitempty"`
+	EndBehavior          string `yaml:"end_behavior,omitempty" json:"end_behavior,omitempty"` // cancel, pause, downgrade
+	DowngradeTo          string `yaml:"downgrade_to,omitempty" json:"downgrade_to,omitempty"` // plan ID, with end_behavior: downgrade
+}
+
+// Dunning describes how failed subscription payments are retried and when the
+// subscription is given up on. Stripe keeps these settings per account in the
+// Dashboard, so they are versioned and exported here, and written to plan
+// product metadata, but not applied to Stripe.
+type Dunning struct {
+	RetryDays           []int          `yaml:"retry_days,omitempty" json:"retry_days,omitempty"`                       // days after the first failed payment, one per retry
+	CancelAfterFailures int            `yaml:"cancel_after_failures,omitempty" json:"cancel_after_failures,omitempty"` // failed payments before cancelling (0 = never)
+	Emails              *DunningEmails `yaml:"emails,omitempty" json:"emails,omitempty"`
+}
+
+// DunningEmails switches the customer emails sent around failed payments
+type DunningEmails struct {
+	PaymentFailed   *bool `yaml:"payment_failed,omitempty" json:"payment_failed,omitempty"`
+	ExpiringCard    *bool `yaml:"expiring_card,omitempty" json:"expiring_card,omitempty"`
+	UpcomingRenewal *bool `yaml:"upcoming_renewal,omitempty" json:"upcoming_renewal,omitempty"`
+}
+
+// Enabled returns the names of the emails switched on, in a fixed order
+func (e *DunningEmails) Enabled() []string {
+	if e == nil {
+		return nil
+	}
+	var names []string
+	for _, email := range []struct {
+		name string
+		on   *bool
+	}{
+		{"payment_failed", e.PaymentFailed},
+		{"expiring_card", e.ExpiringCard},
+		{"upcoming_renewal", e.UpcomingRenewal},
+	} {
+		if email.on != nil && *email.on {
+			names = append(names, email.name)
+		}
+	}
+	return names
+}
+
+// Collection methods: how a plan's invoices are paid
+const (
+	CollectionChargeAutomatically = "charge_automatically" // the default payment method is charged
+	CollectionSendInvoice         = "send_invoice"         // the customer is emailed an invoice to pay
+)
+
+// Invoicing modes: how customers get onto a plan
+const (
+	InvoicingAutomatic = "automatic" // self-serve: customers check out with a card
+	InvoicingManual    = "manual"    // sales-led: subscriptions are set up for a quote or PO and invoiced
+)
+
+// DefaultDaysUntilDue is the payment term of send_invoice plans when
+// settings.invoice.days_until_due is unset
+const DefaultDaysUntilDue = 30
+
+// Invoice customizes the invoices customers receive
+type Invoice struct {
+	Footer       string         `yaml:"footer,omitempty" json:"footer,omitempty"`
+	CustomFields []InvoiceField `yaml:"custom_fields,omitempty" json:"custom_fields,omitempty"`
+	DaysUntilDue int            `yaml:"days_until_due,omitempty" json:"days_until_due,omitempty"` // payment term of send_invoice plans
+}
+
+// InvoiceField is a name and value printed in the invoice header, e.g. a VAT number
+type InvoiceField struct {
+	Name  string `yaml:"name" json:"name"`
+	Value string `yaml:"value" json:"value"`
+}
+
+// TrialFor returns the trial configuration for a plan: the plan's own trial block,
+// falling back to settings.trial. Returns nil when neither is set.
+func (c *BillingConfig) TrialFor(plan Plan) *Trial {
+	if plan.Trial != nil {
+		return plan.Trial
+	}
+	if c.Settings != nil {
+		return c.Settings.Trial
+	}
+	return nil
+}
+
+// GraceDays returns settings.grace_days (0 when unset)
+func (c *BillingConfig) GraceDays() int {
+	if c.Settings == nil {
+		return 0
+	}
+	return c.Settings.GraceDays
+}
+
+// Dunning returns settings.dunning (nil when unset)
+func (c *BillingConfig) Dunning() *Dunning {
+	if c.Settings == nil {
+		return nil
+	}
+	return c.Settings.Dunning
+}
+
+// Invoice returns settings.invoice (nil when unset)
+func (c *BillingConfig) Invoice() *Invoice {
+	if c.Settings == nil {
+		return nil
+	}
+	return c.Settings.Invoice
+}
+
+// DaysUntilDue returns the payment term of send_invoice plans:
+// settings.invoice.days_until_due, or DefaultDaysUntilDue
+func (c *BillingConfig) DaysUntilDue() int {
+	if inv := c.Invoice(); inv != nil && inv.DaysUntilDue > 0 {
+		return inv.DaysUntilDue
+	}
+	return DefaultDaysUntilDue
+}
+
+// FindPlan returns the plan with the given ID, or nil
+func (c *BillingConfig) FindPlan(id string) *Plan {
+	for i := range c.Plans {
+		if c.Plans[i].ID == id {
+			return &c.Plans[i]
+		}
+	}
+	return nil
+}
+
+// MetadataPrefix returns settings.metadata_prefix ("" when unset)
+func (c *BillingConfig) MetadataPrefix() string {
+	if c.Settings == nil {
+		return ""
+	}
+	return c.Settings.MetadataPrefix
+}
+
+// AutomaticTax reports whether the config asks for Stripe-managed tax
+func (c *BillingConfig) AutomaticTax() bool {
+	return c.Settings != nil && c.Settings.AutomaticTax
+}
+
+// TaxCode returns the product tax code for a plan or addon, falling back to settings.tax_code
+func (c *BillingConfig) TaxCode(override string) string {
+	if override != "" {
+		return override
+	}
+	if c.Settings != nil {
+		return c.Settings.TaxCode
+	}
+	return ""
+}
+
+// TaxBehavior returns the price tax behavior to use with automatic tax ("" when disabled)
+func (c *BillingConfig) TaxBehavior() string {
+	if !c.AutomaticTax() {
+		return ""
+	}
+	if c.Settings.TaxBehavior != "" {
+		return c.Settings.TaxBehavior
+	}
+	return "exclusive"
 }
 
 // Entitlement defines a feature or limit that can be granted
@@ -25,23 +232,159 @@ type Entitlement struct {
 	Description string `yaml:"description,omitempty" json:"description,omitempty"`
 }
 
+// UnlimitedKeyword is the YAML/JSON spelling of an uncapped limit
+const UnlimitedKeyword = "unlimited"
+
+// Unlimited is the typed sentinel for limits with no cap (written as "unlimited" in config files)
+type Unlimited struct{}
+
+// MarshalYAML writes the sentinel back as "unlimited"
+func (Unlimited) MarshalYAML() (any, error) {
+	return UnlimitedKeyword, nil
+}
+
+// MarshalJSON writes the sentinel back as "unlimited"
+func (Unlimited) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + UnlimitedKeyword + `"`), nil
+}
+
+// IsUnlimited reports whether a limit value is the unlimited sentinel (typed or raw string)
+func IsUnlimited(v any) bool {
+	switch val := v.(type) {
+	case Unlimited:
+		return true
+	case string:
+		return val == UnlimitedKeyword
+	}
+	return false
+}
+
+// RatePeriods lists the allowed values for RateLimit.Per
+var RatePeriods = []string{"second", "minute", "hour", "day", "month"}
+
+// RateLimit is the typed value of a rate entitlement (e.g. 1000 requests per minute)
+type RateLimit struct {
+	Limit int    `yaml:"limit" json:"limit"`
+	Per   string `yaml:"per" json:"per"`
+}
+
+// ParseRateLimit converts a raw {limit, per} map (as decoded from YAML or JSON) into a RateLimit
+func ParseRateLimit(v any) (RateLimit, error) {
+	if rl, ok := v.(RateLimit); ok {
+		return rl, rl.check()
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return RateLimit{}, fmt.Errorf("expected {limit, per}, got %T", v)
+	}
+
+	var rl RateLimit
+	switch limit := m["limit"].(type) {
+	case int:
+		rl.Limit = limit
+	case float64:
+		if limit != float64(int(limit)) {
+			return RateLimit{}, fmt.Errorf("limit must be an integer, got %v", limit)
+		}
+		rl.Limit = int(limit)
+	case nil:
+		return RateLimit{}, fmt.Errorf("missing 'limit'")
+	default:
+		return RateLimit{}, fmt.Errorf("limit must be an integer, got %v", limit)
+	}
+
+	per, ok := m["per"].(string)
+	if !ok {
+		return RateLimit{}, fmt.Errorf("missing 'per'")
+	}
+	rl.Per = per
+
+	return rl, rl.check()
+}
+
+// check validates the limit and period
+func (r RateLimit) check() error {
+	if r.Limit < 1 {
+		return fmt.Errorf("limit must be a positive integer, got %d", r.Limit)
+	}
+	for _, p := range RatePeriods {
+		if r.Per == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("per must be one of %s, got '%s'", strings.Join(RatePeriods, ", "), r.Per)
+}
+
 // Plan represents a pricing plan
 type Plan struct {
-	ID           string           `yaml:"id" json:"id"`
-	Name         string           `yaml:"name" json:"name"`
-	Description  string           `yaml:"description,omitempty" json:"description,omitempty"`
-	Headline     string           `yaml:"headline,omitempty" json:"headline,omitempty"`
-	Type         string           `yaml:"type,omitempty" json:"type,omitempty"`                   // personal, team, enterprise
-	BillingModel string           `yaml:"billing_model,omitempty" json:"billing_model,omitempty"` // subscription (default), one_time
-	Providers    []string         `yaml:"providers,omitempty" json:"providers,omitempty"`
-	Public       *bool            `yaml:"public,omitempty" json:"public,omitempty"`
-	Default      bool             `yaml:"default,omitempty" json:"default,omitempty"`
-	TrialDays    int              `yaml:"trial_days,omitempty" json:"trial_days,omitempty"`
-	Prices       map[string]Price `yaml:"prices" json:"prices"`
-	Limits       map[string]any   `yaml:"limits,omitempty" json:"limits,omitempty"`
-	Features     []string         `yaml:"features,omitempty" json:"features,omitempty"`
-	UpgradesTo   []string         `yaml:"upgrades_to,omitempty" json:"upgrades_to,omitempty"`
-	Metadata     map[string]any   `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	ID               string            `yaml:"id" json:"id"`
+	PreviousIDs      []string          `yaml:"previous_ids,omitempty" json:"previous_ids,omitempty"` // old IDs, so renamed plans keep their Stripe product
+	Name             string            `yaml:"name" json:"name"`
+	Description      string            `yaml:"description,omitempty" json:"description,omitempty"`
+	Headline         string            `yaml:"headline,omitempty" json:"headline,omitempty"`
+	Type             string            `yaml:"type,omitempty" json:"type,omitempty"`                   // personal, team, enterprise
+	BillingModel     string            `yaml:"billing_model,omitempty" json:"billing_model,omitempty"` // subscription (default), one_time
+	Providers        []string          `yaml:"providers,omitempty" json:"providers,omitempty"`
+	Pricing          string            `yaml:"pricing,omitempty" json:"pricing,omitempty"` // fixed (default), custom
+	Sync             *bool             `yaml:"sync,omitempty" json:"sync,omitempty"`       // false = provisioned outside the billing provider
+	Public           *bool             `yaml:"public,omitempty" json:"public,omitempty"`
+	Default          bool              `yaml:"default,omitempty" json:"default,omitempty"`
+	TrialDays        int               `yaml:"trial_days,omitempty" json:"trial_days,omitempty"`
+	Trial            *Trial            `yaml:"trial,omitempty" json:"trial,omitempty"` // overrides settings.trial
+	Prices           map[string]Price  `yaml:"prices,omitempty" json:"prices,omitempty"`
+	Limits           map[string]any    `yaml:"limits,omitempty" json:"limits,omitempty"`
+	Features         []string          `yaml:"features,omitempty" json:"features,omitempty"`
+	UpgradesTo       []string          `yaml:"upgrades_to,omitempty" json:"upgrades_to,omitempty"`
+	DowngradesTo     []string          `yaml:"downgrades_to,omitempty" json:"downgrades_to,omitempty"`
+	DowngradePolicy  string            `yaml:"downgrade_policy,omitempty" json:"downgrade_policy,omitempty"`   // immediate, end_of_period, blocked
+	CollectionMethod string            `yaml:"collection_method,omitempty" json:"collection_method,omitempty"` // charge_automatically (default), send_invoice
+	Invoicing        string            `yaml:"invoicing,omitempty" json:"invoicing,omitempty"`                 // automatic (default), manual
+	Metadata         map[string]any    `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Provisioning     map[string]any    `yaml:"provisioning,omitempty" json:"provisioning,omitempty"` // string or list of strings per key
+	TaxCode          string            `yaml:"tax_code,omitempty" json:"tax_code,omitempty"`         // overrides settings.tax_code
+	Environments     []string          `yaml:"environments,omitempty" json:"environments,omitempty"` // empty = all environments
+	Regions          map[string]Region `yaml:"regions,omitempty" json:"regions,omitempty"`           // region name -> region-specific prices
+}
+
+// Region is a plan's pricing in one region, e.g. eu: its own currency,
+// amounts and tax behavior. Each region price is a separate provider price
+// tagged with the region, next to the plan's default prices.
+type Region struct {
+	Currency    string           `yaml:"currency" json:"currency"`
+	TaxBehavior string           `yaml:"tax_behavior,omitempty" json:"tax_behavior,omitempty"` // overrides settings.tax_behavior
+	Prices      map[string]Price `yaml:"prices" json:"prices"`
+}
+
+// RegionNames returns the names of the plan's regions, sorted
+func (p *Plan) RegionNames() []string {
+	names := make([]string, 0, len(p.Regions))
+	for name := range p.Regions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegionTaxBehavior returns the tax behavior of a region's prices: its own,
+// falling back to the one used with automatic tax ("" when neither is set)
+func (c *BillingConfig) RegionTaxBehavior(region Region) string {
+	if region.TaxBehavior != "" {
+		return region.TaxBehavior
+	}
+	return c.TaxBehavior()
+}
+
+// SendsInvoice reports whether the plan's customers pay emailed invoices
+// instead of being charged automatically. Manual invoicing implies it.
+func (p *Plan) SendsInvoice() bool {
+	return p.CollectionMethod == CollectionSendInvoice || p.IsManualInvoicing()
+}
+
+// IsManualInvoicing returns true for sales-led plans whose subscriptions are
+// set up by hand and invoiced, without a card or checkout
+func (p *Plan) IsManualInvoicing() bool {
+	return p.Invoicing == InvoicingManual
 }
 
 // IsOneTime returns true if this plan uses one-time billing (e.g., lifetime deal)
@@ -67,6 +410,41 @@ func (p *Plan) HasProvider(provider string, globalProviders []string) bool {
 	return false
 }
 
+// PricingCustom marks plans with no fixed price ("contact sales")
+const PricingCustom = "custom"
+
+// IsCustomPricing returns true for contact-sales plans without fixed prices
+func (p *Plan) IsCustomPricing() bool {
+	return p.Pricing == PricingCustom
+}
+
+// InEnvironment reports whether the plan is available in env (all
+// environments when environments isn't set)
+func (p *Plan) InEnvironment(env string) bool {
+	return inEnvironments(p.Environments, env)
+}
+
+// IsSynced reports whether the plan is pushed to billing providers.
+// Plans with sync: false (free tiers) and custom pricing stay in exports only.
+func (p *Plan) IsSynced() bool {
+	if p.IsCustomPricing() {
+		return false
+	}
+	return p.Sync == nil || *p.Sync
+}
+
+// IsPublic reports whether the plan is listed on pricing pages (public
+// defaults to true)
+func (p *Plan) IsPublic() bool {
+	return p.Public == nil || *p.Public
+}
+
+// RateLimit returns the typed rate limit for an entitlement, if the plan sets one
+func (p *Plan) RateLimit(key string) (RateLimit, bool) {
+	rl, ok := p.Limits[key].(RateLimit)
+	return rl, ok
+}
+
 // Price represents a price point for a plan (supports flat, per_unit, and tiered)
 type Price struct {
 	// Flat price
@@ -79,6 +457,7 @@ type Price struct {
 	Min      int    `yaml:"min,omitempty" json:"min,omitempty"`
 	Max      int    `yaml:"max,omitempty" json:"max,omitempty"`
 	Included int    `yaml:"included,omitempty" json:"included,omitempty"`
+	Meter    string `yaml:"meter,omitempty" json:"meter,omitempty"` // key in meters: bill the usage reported to it
 
 	// Tiered price
 	Tiers []PriceTier `yaml:"tiers,omitempty" json:"tiers,omitempty"`
@@ -103,6 +482,26 @@ func (p *Price) PriceType() string {
 	return "flat"
 }
 
+// MarshalYAML keeps "amount: 0" on free flat prices, which omitempty would
+// drop and the schema requires
+func (p Price) MarshalYAML() (any, error) {
+	type plain Price
+	if p.Amount != 0 || p.PriceType() != "flat" {
+		return plain(p), nil
+	}
+
+	var node yaml.Node
+	if err := node.Encode(plain(p)); err != nil {
+		return nil, err
+	}
+	node.Style = 0 // an otherwise empty price encodes as flow-style {}
+	node.Content = append([]*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "amount"},
+		{Kind: yaml.ScalarNode, Tag: "!!int", Value: "0"},
+	}, node.Content...)
+	return &node, nil
+}
+
 // GetTierUpTo returns the up_to value as int64, or -1 for unlimited
 func (t *PriceTier) GetTierUpTo() int64 {
 	switch v := t.UpTo.(type) {
@@ -124,6 +523,55 @@ type Addon struct {
 	Name   string         `yaml:"name" json:"name"`
 	Price  Price          `yaml:"price" json:"price"`
 	Grants map[string]any `yaml:"grants" json:"grants"`
+	// TaxCode overrides settings.tax_code for this addon
+	TaxCode string `yaml:"tax_code,omitempty" json:"tax_code,omitempty"`
+}
+
+// CreditPack is a one-time purchase that adds credits to the balance of a
+// credit entitlement, e.g. 1,000 AI credits for $10
+type CreditPack struct {
+	ID          string `yaml:"id" json:"id"`
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Entitlement string `yaml:"entitlement" json:"entitlement"`                       // a credit entitlement
+	Credits     int    `yaml:"credits" json:"credits"`                               // added to the balance per pack
+	Amount      int    `yaml:"amount" json:"amount"`                                 // one-time price in cents
+	ExpiresDays int    `yaml:"expires_days,omitempty" json:"expires_days,omitempty"` // 0 = the credits don't expire
+	// TaxCode overrides settings.tax_code for this pack
+	TaxCode string `yaml:"tax_code,omitempty" json:"tax_code,omitempty"`
+}
+
+// Meter aggregations: how the usage events of a billing period are combined
+const (
+	MeterSum   = "sum"   // add up the reported values
+	MeterCount = "count" // count the events
+	MeterLast  = "last"  // take the last reported value
+)
+
+// Meter is a usage meter that metered prices bill from, e.g. API calls
+// reported as "api_call" events
+type Meter struct {
+	DisplayName string `yaml:"display_name" json:"display_name"`
+	EventName   string `yaml:"event_name" json:"event_name"`                       // name usage events are reported under
+	Aggregation string `yaml:"aggregation,omitempty" json:"aggregation,omitempty"` // sum (default), count or last
+}
+
+// AggregationOrDefault returns the meter's aggregation, sum when unset
+func (m Meter) AggregationOrDefault() string {
+	if m.Aggregation == "" {
+		return MeterSum
+	}
+	return m.Aggregation
+}
+
+// MeterKeys returns the keys of the meters, sorted
+func (c *BillingConfig) MeterKeys() []string {
+	keys := make([]string, 0, len(c.Meters))
+	for key := range c.Meters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // Promotion represents a promotional discount
@@ -135,8 +583,12 @@ type Promotion struct {
 	AppliesTo        []string          `yaml:"applies_to,omitempty" json:"applies_to,omitempty"`
 	NewCustomersOnly bool              `yaml:"new_customers_only,omitempty" json:"new_customers_only,omitempty"`
 	MaxUses          int               `yaml:"max_uses,omitempty" json:"max_uses,omitempty"`
-	Expires          string            `yaml:"expires,omitempty" json:"expires,omitempty"`
+	StartsAt         string            `yaml:"starts_at,omitempty" json:"starts_at,omitempty"` // YYYY-MM-DD, UTC; not synced before
+	Expires          string            `yaml:"expires,omitempty" json:"expires,omitempty"`     // YYYY-MM-DD, UTC; deactivated from
 	Active           *bool             `yaml:"active,omitempty" json:"active,omitempty"`
+	Stackable        *bool             `yaml:"stackable,omitempty" json:"stackable,omitempty"`       // combinable with other codes (default true)
+	Excludes         []string          `yaml:"excludes,omitempty" json:"excludes,omitempty"`         // codes this one can't be combined with
+	Environments     []string          `yaml:"environments,omitempty" json:"environments,omitempty"` // empty = all environments
 }
 
 // PromotionDiscount defines the discount amount
@@ -176,3 +628,84 @@ func (p *Promotion) IsActive() bool {
 	}
 	return *p.Active
 }
+
+// Promotion window states, see Promotion.Window
+const (
+	PromotionPending = "pending" // before starts_at
+	PromotionOpen    = "open"
+	PromotionClosed  = "closed" // on or after expires
+)
+
+// Window reports whether now is before, within or after the promotion's
+// starts_at/expires window. Both dates are midnight UTC; either may be unset.
+func (p *Promotion) Window(now time.Time) (string, error) {
+	starts, err := parsePromotionDate(p.StartsAt)
+	if err != nil {
+		return "", fmt.Errorf("promotion '%s': invalid starts_at: %w", p.Code, err)
+	}
+	expires, err := parsePromotionDate(p.Expires)
+	if err != nil {
+		return "", fmt.Errorf("promotion '%s': invalid expires: %w", p.Code, err)
+	}
+	if !starts.IsZero() && !expires.IsZero() && !starts.Before(expires) {
+		return "", fmt.Errorf("promotion '%s': starts_at %s is not before expires %s", p.Code, p.StartsAt, p.Expires)
+	}
+
+	switch {
+	case !starts.IsZero() && now.Before(starts):
+		return PromotionPending, nil
+	case !expires.IsZero() && !now.Before(expires):
+		return PromotionClosed, nil
+	}
+	return PromotionOpen, nil
+}
+
+// ExpiresAt returns expires as a time, zero when unset
+func (p *Promotion) ExpiresAt() time.Time {
+	expires, _ := parsePromotionDate(p.Expires) // Error reported by Window
+	return expires
+}
+
+// parsePromotionDate parses a YYYY-MM-DD date as midnight UTC; empty is zero
+func parsePromotionDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// InEnvironment reports whether the promotion is available in env (all
+// environments when environments isn't set)
+func (p *Promotion) InEnvironment(env string) bool {
+	return inEnvironments(p.Environments, env)
+}
+
+// inEnvironments reports whether env is in envs, treating an empty list as all
+func inEnvironments(envs []string, env string) bool {
+	if len(envs) == 0 {
+		return true
+	}
+	for _, e := range envs {
+		if e == env {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStackable returns whether the promotion can be combined with other codes (defaults to true)
+func (p *Promotion) IsStackable() bool {
+	return p.Stackable == nil || *p.Stackable
+}
+
+// StackingMetadata returns the stacking rules as Stripe coupon metadata so
+// checkout services can enforce them
+func (p *Promotion) StackingMetadata() map[string]string {
+	meta := map[string]string{
+		"stackable": strconv.FormatBool(p.IsStackable()),
+	}
+	if len(p.Excludes) > 0 {
+		meta["excludes"] = strings.Join(p.Excludes, ",")
+	}
+	return meta
+}
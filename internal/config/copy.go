@@ -0,0 +1,225 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// planIDPattern is the schema's pattern for plan IDs
+var planIDPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// PriceChange is a price amount adjusted in a copied plan
+type PriceChange struct {
+	Path string // relative to the plan, e.g. prices/monthly/amount
+	From int
+	To   int
+}
+
+// PlanCopy describes a plan added by CopyPlan
+type PlanCopy struct {
+	Line    int           // one-based line of the new plan
+	YAML    string        // the new plan's sequence item
+	Prices  []PriceChange // amounts changed by the multiplier
+	Dropped []string      // fields that can't be shared with the original
+}
+
+// CopyPlan inserts a copy of plan fromID named toID right after it in a YAML
+// billing config, with every price amount multiplied by multiplier and rounded
+// to the cent. The rest of the file is left as it was. previous_ids and
+// default: true stay with the original plan.
+func CopyPlan(content []byte, fromID, toID string, multiplier float64) ([]byte, *PlanCopy, error) {
+	if !planIDPattern.MatchString(toID) {
+		return nil, nil, fmt.Errorf("invalid plan ID '%s' (use snake_case: ^[a-z][a-z0-9_]*$)", toID)
+	}
+	if multiplier <= 0 {
+		return nil, nil, fmt.Errorf("price multiplier must be positive, got %g", multiplier)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config is not a mapping")
+	}
+	root := doc.Content[0]
+
+	// Find the plans sequence and the top-level key that follows it
+	var plans *yaml.Node
+	sectionEnd := 0 // one-based line of the next top-level key, 0 = end of file
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if plans != nil {
+			sectionEnd = root.Content[i].Line
+			break
+		}
+		if root.Content[i].Value == "plans" && root.Content[i+1].Kind == yaml.SequenceNode {
+			plans = root.Content[i+1]
+		}
+	}
+	if plans == nil {
+		return nil, nil, fmt.Errorf("config has no plans")
+	}
+
+	index := -1
+	for i, item := range plans.Content {
+		id := mappingValue(item, "id")
+		if id == nil {
+			continue
+		}
+		switch id.Value {
+		case fromID:
+			index = i
+		case toID:
+			return nil, nil, fmt.Errorf("plan '%s' already exists", toID)
+		}
+	}
+	if index < 0 {
+		return nil, nil, fmt.Errorf("plan '%s' not found", fromID)
+	}
+	original := plans.Content[index]
+	if original.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("plan '%s' is not a mapping", fromID)
+	}
+
+	plan := copyNode(original)
+	plan.HeadComment = "" // it describes the original
+	result := &PlanCopy{}
+	kept := plan.Content[:0]
+	for i := 0; i+1 < len(plan.Content); i += 2 {
+		key, value := plan.Content[i], plan.Content[i+1]
+		switch {
+		case key.Value == "id":
+			value.Value = toID
+			value.Style = 0
+		case key.Value == "previous_ids":
+			result.Dropped = append(result.Dropped, "previous_ids")
+			continue
+		case key.Value == "default" && value.Value == "true":
+			result.Dropped = append(result.Dropped, "default")
+			continue
+		case key.Value == "prices" && multiplier != 1:
+			result.Prices = scalePrices(value, "prices", multiplier)
+		}
+		kept = append(kept, key, value)
+	}
+	plan.Content = kept
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(plan); err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal plan: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal plan: %w", err)
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// The original runs up to the next plan or section, without the blank
+	// lines and comments in front of it
+	end := len(lines)
+	if index+1 < len(plans.Content) {
+		end = plans.Content[index+1].Line - 1
+	} else if sectionEnd > 0 {
+		end = sectionEnd - 1
+	}
+	for end > original.Line {
+		trimmed := strings.TrimSpace(lines[end-1])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			break
+		}
+		end--
+	}
+
+	// Match the original's indentation, with a blank line between plans
+	startLine := lines[original.Line-1]
+	indent := startLine[:len(startLine)-len(strings.TrimLeft(startLine, " \t"))]
+	var item strings.Builder
+	for i, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		prefix := indent + "  "
+		if i == 0 {
+			prefix = indent + "- "
+		}
+		if line == "" {
+			prefix = ""
+		}
+		item.WriteString(prefix + line + "\n")
+	}
+	result.YAML = item.String()
+
+	if !strings.HasSuffix(lines[end-1], "\n") {
+		lines[end-1] += "\n"
+	}
+	var out strings.Builder
+	out.WriteString(strings.Join(lines[:end], ""))
+	out.WriteString("\n" + result.YAML)
+	out.WriteString(strings.Join(lines[end:], ""))
+	result.Line = end + 2
+	return []byte(out.String()), result, nil
+}
+
+// scalePrices multiplies every amount under a plan's prices in place
+func scalePrices(node *yaml.Node, path string, multiplier float64) []PriceChange {
+	var changes []PriceChange
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			childPath := path + "/" + key.Value
+			switch {
+			case value.Kind == yaml.ScalarNode && isAmountField(path, key.Value):
+				from, err := strconv.Atoi(value.Value)
+				if err != nil {
+					continue
+				}
+				to := int(math.Round(float64(from) * multiplier))
+				value.Value = strconv.Itoa(to)
+				changes = append(changes, PriceChange{Path: childPath, From: from, To: to})
+			default:
+				changes = append(changes, scalePrices(value, childPath, multiplier)...)
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			changes = append(changes, scalePrices(item, path+"/"+strconv.Itoa(i), multiplier)...)
+		}
+	}
+	return changes
+}
+
+// isAmountField reports whether key under path holds an amount in cents
+func isAmountField(path, key string) bool {
+	if strings.HasSuffix(path, "/currency_prices") {
+		return true
+	}
+	switch key {
+	case "amount", "per_unit", "flat":
+		return true
+	}
+	return false
+}
+
+// copyNode deep-copies a YAML node. Aliases are resolved so that the copy
+// doesn't refer to anchors that only exist in the original.
+func copyNode(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		return copyNode(n.Alias)
+	}
+	c := *n
+	c.Anchor = ""
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
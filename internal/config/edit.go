@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// textEdit replaces content[start:end] with text
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits applies non-overlapping edits to content, leaving the rest of it
+// byte for byte as it was
+func applyEdits(content []byte, edits []textEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := append([]byte(nil), content...)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+	return out
+}
+
+// sourceIndex maps the one-based lines and columns of YAML nodes to byte offsets
+type sourceIndex struct {
+	content    []byte
+	lineStarts []int
+}
+
+func newSourceIndex(content []byte) *sourceIndex {
+	idx := &sourceIndex{content: content, lineStarts: []int{0}}
+	for i, b := range content {
+		if b == '\n' {
+			idx.lineStarts = append(idx.lineStarts, i+1)
+		}
+	}
+	return idx
+}
+
+// line returns a one-based line without its newline
+func (idx *sourceIndex) line(n int) []byte {
+	line := idx.content[idx.lineStarts[n-1]:]
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	return line
+}
+
+// lineEnd returns the offset just past a one-based line, including its newline
+func (idx *sourceIndex) lineEnd(n int) int {
+	if n < len(idx.lineStarts) {
+		return idx.lineStarts[n]
+	}
+	return len(idx.content)
+}
+
+// offset returns the byte offset of a node. Columns count characters, not bytes.
+func (idx *sourceIndex) offset(n *yaml.Node) int {
+	line := idx.line(n.Line)
+	return idx.lineStarts[n.Line-1] + len(string([]rune(string(line))[:n.Column-1]))
+}
+
+// flowEnd returns the offset just past the closing bracket of a flow mapping
+// or sequence that starts at start, or -1
+func (idx *sourceIndex) flowEnd(start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(idx.content); i++ {
+		b := idx.content[i]
+		switch {
+		case quote != 0:
+			if b == quote {
+				quote = 0
+			}
+		case b == '"' || b == '\'':
+			quote = b
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// lastLine returns the last line a node or any of its children starts on
+func lastLine(n *yaml.Node) int {
+	last := n.Line
+	for _, child := range n.Content {
+		if l := lastLine(child); l > last {
+			last = l
+		}
+	}
+	return last
+}
+
+// flowText formats a mapping of scalars and nested mappings in flow style,
+// with spaces inside the braces when spaced is set ("{ amount: 2900 }")
+func flowText(n *yaml.Node, spaced bool) string {
+	if n.Kind != yaml.MappingNode {
+		return scalarText(n)
+	}
+	pairs := make([]string, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, scalarText(n.Content[i])+": "+flowText(n.Content[i+1], spaced))
+	}
+	if len(pairs) == 0 {
+		return "{}"
+	}
+	if spaced {
+		return "{ " + strings.Join(pairs, ", ") + " }"
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// scalarText formats a scalar in its original quoting style
+func scalarText(n *yaml.Node) string {
+	switch {
+	case n.Style&yaml.DoubleQuotedStyle != 0:
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(n.Value, `\`, `\\`), `"`, `\"`) + `"`
+	case n.Style&yaml.SingleQuotedStyle != 0:
+		return "'" + strings.ReplaceAll(n.Value, "'", "''") + "'"
+	}
+	return n.Value
+}
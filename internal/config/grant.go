@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GrantOp is how an addon grant changes a plan limit
+type GrantOp string
+
+const (
+	GrantSet       GrantOp = "set"       // projects: 50, sso: true
+	GrantAdd       GrantOp = "add"       // projects: "+10" (or "-5")
+	GrantUnlimited GrantOp = "unlimited" // projects: unlimited
+)
+
+// Grant is a parsed addon grant
+type Grant struct {
+	Op    GrantOp `json:"op"`
+	Value any     `json:"value,omitempty"` // int for set/add, bool for set on bool entitlements
+}
+
+// ParseGrant parses an addon grant value: an absolute int, a "+N"/"-N" delta,
+// "unlimited", or a bool
+func ParseGrant(v any) (Grant, error) {
+	switch val := v.(type) {
+	case bool:
+		return Grant{Op: GrantSet, Value: val}, nil
+	case float64:
+		// JSON configs decode numbers as float64
+		if val != float64(int(val)) {
+			return Grant{}, fmt.Errorf("grant must be a whole number, got %v", val)
+		}
+		return ParseGrant(int(val))
+	case int:
+		if val < 0 {
+			return Grant{}, fmt.Errorf("absolute grant must not be negative, got %d", val)
+		}
+		return Grant{Op: GrantSet, Value: val}, nil
+	case Unlimited:
+		return Grant{Op: GrantUnlimited}, nil
+	case string:
+		if val == UnlimitedKeyword {
+			return Grant{Op: GrantUnlimited}, nil
+		}
+		if strings.HasPrefix(val, "+") || strings.HasPrefix(val, "-") {
+			n, err := strconv.Atoi(val)
+			if err == nil && n != 0 {
+				return Grant{Op: GrantAdd, Value: n}, nil
+			}
+		}
+		return Grant{}, fmt.Errorf("invalid grant %q (use N, \"+N\", \"-N\", \"unlimited\", or true/false)", val)
+	}
+	return Grant{}, fmt.Errorf("invalid grant %v (use N, \"+N\", \"-N\", \"unlimited\", or true/false)", v)
+}
+
+// IsBool returns true for grants that switch a bool entitlement
+func (g Grant) IsBool() bool {
+	_, ok := g.Value.(bool)
+	return ok
+}
+
+// Apply returns the limit a plan ends up with after this grant. Deltas on an
+// unlimited limit stay unlimited, and never go below zero.
+func (g Grant) Apply(limit any) (any, error) {
+	switch g.Op {
+	case GrantSet:
+		return g.Value, nil
+	case GrantUnlimited:
+		return Unlimited{}, nil
+	case GrantAdd:
+		if IsUnlimited(limit) {
+			return limit, nil
+		}
+		base := 0
+		if limit != nil {
+			n, ok := limit.(int)
+			if !ok {
+				return nil, fmt.Errorf("cannot add %v to non-numeric limit %v", g.Value, limit)
+			}
+			base = n
+		}
+		result := base + g.Value.(int)
+		if result < 0 {
+			result = 0
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unknown grant op: %s", g.Op)
+}
+
+// String formats the grant the way it's written in billing.yaml
+func (g Grant) String() string {
+	switch g.Op {
+	case GrantUnlimited:
+		return UnlimitedKeyword
+	case GrantAdd:
+		return fmt.Sprintf("%+d", g.Value)
+	}
+	return fmt.Sprint(g.Value)
+}
+
+// ParsedGrants returns the addon's grants parsed into typed Grants
+func (a *Addon) ParsedGrants() (map[string]Grant, error) {
+	keys := make([]string, 0, len(a.Grants))
+	for k := range a.Grants {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	grants := make(map[string]Grant, len(a.Grants))
+	for _, key := range keys {
+		g, err := ParseGrant(a.Grants[key])
+		if err != nil {
+			return nil, fmt.Errorf("addon '%s' grant '%s': %w", a.ID, key, err)
+		}
+		grants[key] = g
+	}
+	return grants, nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Graph formats for RenderPlanGraph
+const (
+	GraphDot     = "dot"
+	GraphMermaid = "mermaid"
+)
+
+// GraphFormats lists the valid graph formats
+var GraphFormats = []string{GraphDot, GraphMermaid}
+
+// Kinds of graph edges
+const (
+	edgeUpgrade = iota
+	edgeDowngrade
+	edgeTrial // a trial that ends in a downgrade
+)
+
+type graphEdge struct {
+	from, to string
+	kind     int
+}
+
+// RenderPlanGraph renders the plans and their upgrades_to and downgrades_to
+// paths as a Graphviz or Mermaid diagram. Downgrades, including trials that end
+// in one, are drawn as dashed edges, and hidden plans (public: false) with a
+// dashed border.
+func RenderPlanGraph(cfg *BillingConfig, format string) (string, error) {
+	if format != GraphDot && format != GraphMermaid {
+		return "", fmt.Errorf("invalid format: %s (use %s)", format, strings.Join(GraphFormats, ", "))
+	}
+
+	var edges []graphEdge
+	for _, plan := range cfg.Plans {
+		for _, target := range plan.UpgradesTo {
+			if cfg.FindPlan(target) != nil {
+				edges = append(edges, graphEdge{from: plan.ID, to: target, kind: edgeUpgrade})
+			}
+		}
+		for _, target := range plan.DowngradesTo {
+			if cfg.FindPlan(target) != nil {
+				edges = append(edges, graphEdge{from: plan.ID, to: target, kind: edgeDowngrade})
+			}
+		}
+		if trial := cfg.TrialFor(plan); plan.TrialDays > 0 && trial != nil &&
+			trial.EndBehavior == TrialEndDowngrade && cfg.FindPlan(trial.DowngradeTo) != nil {
+			edges = append(edges, graphEdge{from: plan.ID, to: trial.DowngradeTo, kind: edgeTrial})
+		}
+	}
+
+	var b strings.Builder
+	if format == GraphDot {
+		b.WriteString("digraph plans {\n  rankdir=LR;\n  node [shape=box];\n")
+		for _, plan := range cfg.Plans {
+			style := ""
+			if !plan.IsPublic() {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(&b, "  %s [label=%q%s];\n", plan.ID, graphLabel(plan), style)
+		}
+		for _, e := range edges {
+			switch e.kind {
+			case edgeDowngrade:
+				fmt.Fprintf(&b, "  %s -> %s [style=dashed];\n", e.from, e.to)
+			case edgeTrial:
+				fmt.Fprintf(&b, "  %s -> %s [style=dashed, label=\"trial ends\"];\n", e.from, e.to)
+			default:
+				fmt.Fprintf(&b, "  %s -> %s;\n", e.from, e.to)
+			}
+		}
+		b.WriteString("}\n")
+		return b.String(), nil
+	}
+
+	b.WriteString("flowchart LR\n")
+	var hidden []string
+	for _, plan := range cfg.Plans {
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", plan.ID, strings.ReplaceAll(graphLabel(plan), `"`, "#quot;"))
+		if !plan.IsPublic() {
+			hidden = append(hidden, plan.ID)
+		}
+	}
+	for _, e := range edges {
+		switch e.kind {
+		case edgeDowngrade:
+			fmt.Fprintf(&b, "  %s -.-> %s\n", e.from, e.to)
+		case edgeTrial:
+			fmt.Fprintf(&b, "  %s -. trial ends .-> %s\n", e.from, e.to)
+		default:
+			fmt.Fprintf(&b, "  %s --> %s\n", e.from, e.to)
+		}
+	}
+	if len(hidden) > 0 {
+		b.WriteString("  classDef hidden stroke-dasharray: 5 5\n")
+		fmt.Fprintf(&b, "  class %s hidden\n", strings.Join(hidden, ","))
+	}
+	return b.String(), nil
+}
+
+// graphLabel is a plan's name, falling back to its ID
+func graphLabel(plan Plan) string {
+	if plan.Name != "" {
+		return plan.Name
+	}
+	return plan.ID
+}
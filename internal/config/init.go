@@ -117,8 +117,14 @@ plans:
   #     - All future updates
 `
 
-// CreateInitFiles creates the raterunner/billing.yaml file in the specified directory
-func CreateInitFiles(dir string) error {
+// CreateInitFiles creates the raterunner/billing.yaml file in the specified
+// directory from an init template ("" for the generic example)
+func CreateInitFiles(dir, template string) error {
+	content, err := Template(template)
+	if err != nil {
+		return err
+	}
+
 	// Create raterunner directory
 	raterunnerDir := filepath.Join(dir, "raterunner")
 	if err := os.MkdirAll(raterunnerDir, 0755); err != nil {
@@ -127,7 +133,7 @@ func CreateInitFiles(dir string) error {
 
 	// Write billing.yaml
 	billingPath := filepath.Join(raterunnerDir, "billing.yaml")
-	if err := os.WriteFile(billingPath, []byte(InitTemplate), 0644); err != nil {
+	if err := os.WriteFile(billingPath, content, 0644); err != nil {
 		return fmt.Errorf("failed to write billing.yaml: %w", err)
 	}
 
@@ -145,3 +151,34 @@ func InitFilesExist(dir string) bool {
 func InitFilePath(dir string) string {
 	return filepath.Join(dir, "raterunner", "billing.yaml")
 }
+
+// FindBillingFile walks up from dir looking for raterunner/billing.yaml,
+// the same way git finds .git, and returns the first match
+func FindBillingFile(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	path, ok := findUp(abs, filepath.Join("raterunner", "billing.yaml"))
+	if !ok {
+		return "", fmt.Errorf("no raterunner/billing.yaml found in %s or any parent directory", abs)
+	}
+	return path, nil
+}
+
+// findUp returns the first existing regular file named rel in dir or any of its parents
+func findUp(dir, rel string) (string, bool) {
+	for current := dir; ; {
+		candidate := filepath.Join(current, rel)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, true
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", false
+		}
+		current = parent
+	}
+}
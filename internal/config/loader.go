@@ -1,17 +1,18 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
-// LoadBillingFile loads and parses a billing configuration file
+// LoadBillingFile loads and parses a billing configuration file, decrypting it
+// first when it is encrypted with sops
 func LoadBillingFile(filePath string) (*BillingConfig, error) {
-	content, err := os.ReadFile(filePath)
+	content, err := ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
@@ -21,14 +22,77 @@ func LoadBillingFile(filePath string) (*BillingConfig, error) {
 		return nil, fmt.Errorf("unsupported file extension: %s (use .yaml or .yml)", ext)
 	}
 
+	return LoadBilling(content, "yaml")
+}
+
+// LoadBilling parses billing configuration content in the given format ("yaml" or "json")
+func LoadBilling(content []byte, format string) (*BillingConfig, error) {
+	if header := SchemaHeader(content); header != "" && header != "billing" {
+		return nil, fmt.Errorf("file is marked '# %s %s', not a billing config", SchemaHeaderPrefix, header)
+	}
+
 	var config BillingConfig
-	if err := yaml.Unmarshal(content, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	switch format {
+	case "yaml":
+		if err := yaml.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	case "json":
+		if err := json.Unmarshal(content, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: %s (use yaml or json)", format)
 	}
 
+	config.normalize()
+
 	return &config, nil
 }
 
+// normalize converts raw decoded values into their typed forms
+// (rate limit maps into RateLimit, "unlimited" into Unlimited) so downstream
+// code can type-switch on them. Values that don't parse are left untouched
+// for the validator to report.
+func (c *BillingConfig) normalize() {
+	for i := range c.Plans {
+		for key, value := range c.Plans[i].Limits {
+			if IsUnlimited(value) {
+				c.Plans[i].Limits[key] = Unlimited{}
+				continue
+			}
+			if _, isMap := value.(map[string]any); !isMap {
+				continue
+			}
+			if rl, err := ParseRateLimit(value); err == nil {
+				c.Plans[i].Limits[key] = rl
+			}
+		}
+	}
+}
+
+// SchemaHeaderPrefix marks a file's schema type in its leading comment block,
+// e.g. "# raterunner-schema: provider"
+const SchemaHeaderPrefix = "raterunner-schema:"
+
+// SchemaHeader returns the schema type declared in the file's leading comments, or "" if none
+func SchemaHeader(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if strings.HasPrefix(comment, SchemaHeaderPrefix) {
+			return strings.TrimSpace(strings.TrimPrefix(comment, SchemaHeaderPrefix))
+		}
+	}
+	return ""
+}
+
 // SaveBillingFile saves a billing configuration to a YAML file
 func SaveBillingFile(filePath string, cfg *BillingConfig) error {
 	content, err := yaml.Marshal(cfg)
@@ -36,9 +100,5 @@ func SaveBillingFile(filePath string, cfg *BillingConfig) error {
 		return fmt.Errorf("failed to marshal YAML: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, content, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
-	}
-
-	return nil
+	return writeConfigFile(filePath, content)
 }
@@ -0,0 +1,276 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rounding rules for localized prices
+const (
+	RoundingNone          = "none"          // nearest cent
+	RoundingWhole         = "whole"         // nearest whole unit: 26.68 -> 27.00
+	RoundingPsychological = "psychological" // whole unit minus a cent: 26.68 -> 26.99
+)
+
+// RoundingModes lists the valid rounding rules
+var RoundingModes = []string{RoundingNone, RoundingWhole, RoundingPsychological}
+
+// zeroDecimalCurrencies are charged in whole units rather than cents
+var zeroDecimalCurrencies = map[string]bool{
+	"bif": true, "clp": true, "djf": true, "gnf": true, "jpy": true, "kmf": true,
+	"krw": true, "mga": true, "pyg": true, "rwf": true, "ugx": true, "vnd": true,
+	"vuv": true, "xaf": true, "xof": true, "xpf": true,
+}
+
+// LocalizeOptions configures LocalizePrices
+type LocalizeOptions struct {
+	Currencies []string           // currencies to fill in, lowercase
+	Rates      map[string]float64 // units of each currency per unit of the base currency
+	RatesBase  string             // base currency of Rates, "" if the file doesn't say
+	Rounding   string
+}
+
+// LocalizedPrice is a currency price set by LocalizePrices
+type LocalizedPrice struct {
+	PlanID   string
+	Interval string
+	Currency string
+	Amount   int
+	Previous int // 0 when the price had no amount in this currency
+	Line     int // line of the price
+}
+
+// Localization is the result of LocalizePrices
+type Localization struct {
+	Base    string
+	Prices  []LocalizedPrice
+	Skipped []string // prices that aren't flat, as "plan interval (type)"
+}
+
+// LocalizePrices fills currency_prices of every flat plan price from its amount
+// in settings.currency, using the given exchange rates and rounding rule.
+// Currencies not listed are left alone. Only the touched prices are rewritten,
+// so the rest of the file keeps its formatting and comments.
+func LocalizePrices(content []byte, opts LocalizeOptions) ([]byte, *Localization, error) {
+	if !isRounding(opts.Rounding) {
+		return nil, nil, fmt.Errorf("invalid rounding: %s (use %s)", opts.Rounding, strings.Join(RoundingModes, ", "))
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config is not a mapping")
+	}
+	root := doc.Content[0]
+
+	result := &Localization{Base: "usd"}
+	if currency := mappingValue(mappingValue(root, "settings"), "currency"); currency != nil {
+		result.Base = strings.ToLower(currency.Value)
+	}
+	if opts.RatesBase != "" && opts.RatesBase != result.Base {
+		return nil, nil, fmt.Errorf("rates are based on %s, but settings.currency is %s", opts.RatesBase, result.Base)
+	}
+	for _, currency := range opts.Currencies {
+		if currency == result.Base {
+			return nil, nil, fmt.Errorf("%s is the base currency (settings.currency)", currency)
+		}
+		if _, ok := opts.Rates[currency]; !ok {
+			return nil, nil, fmt.Errorf("no exchange rate for %s", currency)
+		}
+	}
+
+	idx := newSourceIndex(content)
+	var edits []textEdit
+	plans := mappingValue(root, "plans")
+	if plans == nil || plans.Kind != yaml.SequenceNode {
+		return nil, nil, fmt.Errorf("config has no plans")
+	}
+	for _, plan := range plans.Content {
+		id := mappingValue(plan, "id")
+		prices := mappingValue(plan, "prices")
+		if id == nil || prices == nil || prices.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i+1 < len(prices.Content); i += 2 {
+			interval, price := prices.Content[i].Value, prices.Content[i+1]
+			if price.Kind != yaml.MappingNode {
+				continue
+			}
+			if mappingValue(price, "per_unit") != nil || mappingValue(price, "tiers") != nil {
+				priceType := "per_unit"
+				if mappingValue(price, "tiers") != nil {
+					priceType = "tiered"
+				}
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s %s (%s)", id.Value, interval, priceType))
+				continue
+			}
+			amountNode := mappingValue(price, "amount")
+			if amountNode == nil {
+				continue
+			}
+			amount, err := strconv.Atoi(amountNode.Value)
+			if err != nil || amount <= 0 {
+				continue // free prices cost nothing in any currency
+			}
+
+			amounts := make(map[string]int, len(opts.Currencies))
+			for _, currency := range opts.Currencies {
+				converted := ConvertAmount(amount, result.Base, currency, opts.Rates[currency], opts.Rounding)
+				amounts[currency] = converted
+				localized := LocalizedPrice{PlanID: id.Value, Interval: interval, Currency: currency, Amount: converted, Line: price.Line}
+				if previous := mappingValue(mappingValue(price, "currency_prices"), currency); previous != nil {
+					localized.Previous, _ = strconv.Atoi(previous.Value)
+				}
+				result.Prices = append(result.Prices, localized)
+			}
+			edits = append(edits, currencyPriceEdits(idx, price, opts.Currencies, amounts)...)
+		}
+	}
+	return applyEdits(content, edits), result, nil
+}
+
+// currencyPriceEdits sets the amounts in a price's currency_prices, creating
+// the field when the price doesn't have one
+func currencyPriceEdits(idx *sourceIndex, price *yaml.Node, currencies []string, amounts map[string]int) []textEdit {
+	currencyPrices := mappingValue(price, "currency_prices")
+
+	// A flow price is rewritten as a whole: { amount: 2900, currency_prices: { eur: 2699 } }
+	if price.Style&yaml.FlowStyle != 0 {
+		if currencyPrices == nil {
+			currencyPrices = &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+			price.Content = append(price.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "currency_prices"}, currencyPrices)
+		}
+		setAmounts(currencyPrices, currencies, amounts)
+		return []textEdit{flowEdit(idx, price)}
+	}
+
+	indent := strings.Repeat(" ", price.Content[0].Column-1)
+	if currencyPrices == nil {
+		text := indent + "currency_prices:\n"
+		for _, currency := range currencies {
+			text += fmt.Sprintf("%s  %s: %d\n", indent, currency, amounts[currency])
+		}
+		return []textEdit{insertAfterLine(idx, lastLine(price), text)}
+	}
+	if currencyPrices.Kind != yaml.MappingNode {
+		return nil
+	}
+	if currencyPrices.Style&yaml.FlowStyle != 0 || len(currencyPrices.Content) == 0 {
+		setAmounts(currencyPrices, currencies, amounts)
+		currencyPrices.Style = yaml.FlowStyle
+		return []textEdit{flowEdit(idx, currencyPrices)}
+	}
+
+	// Block currency_prices: replace existing amounts and append new currencies
+	var edits []textEdit
+	var added string
+	entryIndent := strings.Repeat(" ", currencyPrices.Content[0].Column-1)
+	for _, currency := range currencies {
+		value := mappingValue(currencyPrices, currency)
+		if value == nil {
+			added += fmt.Sprintf("%s%s: %d\n", entryIndent, currency, amounts[currency])
+			continue
+		}
+		start := idx.offset(value)
+		edits = append(edits, textEdit{start: start, end: start + len(scalarText(value)), text: strconv.Itoa(amounts[currency])})
+	}
+	if added != "" {
+		edits = append(edits, insertAfterLine(idx, lastLine(currencyPrices), added))
+	}
+	return edits
+}
+
+// setAmounts sets the currency amounts in a currency_prices mapping node
+func setAmounts(currencyPrices *yaml.Node, currencies []string, amounts map[string]int) {
+	for _, currency := range currencies {
+		value := strconv.Itoa(amounts[currency])
+		if existing := mappingValue(currencyPrices, currency); existing != nil {
+			existing.Value, existing.Style = value, 0
+			continue
+		}
+		currencyPrices.Content = append(currencyPrices.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: currency},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value})
+	}
+}
+
+// flowEdit replaces a flow mapping with its current content, keeping the
+// original spacing inside the braces
+func flowEdit(idx *sourceIndex, n *yaml.Node) textEdit {
+	start := idx.offset(n)
+	spaced := start+1 < len(idx.content) && idx.content[start+1] == ' '
+	return textEdit{start: start, end: idx.flowEnd(start), text: flowText(n, spaced)}
+}
+
+// insertAfterLine inserts whole lines of text after a one-based line
+func insertAfterLine(idx *sourceIndex, line int, text string) textEdit {
+	at := idx.lineEnd(line)
+	if at == len(idx.content) && at > 0 && idx.content[at-1] != '\n' {
+		text = "\n" + text
+	}
+	return textEdit{start: at, end: at, text: text}
+}
+
+// ConvertAmount converts an amount in the smallest unit of one currency to
+// another at rate, then rounds it. Whole units are hundreds for zero-decimal
+// currencies such as jpy.
+func ConvertAmount(amount int, from, to string, rate float64, rounding string) int {
+	converted := float64(amount) * rate * minorUnits(to) / minorUnits(from)
+	switch rounding {
+	case RoundingWhole:
+		return int(math.Max(1, math.Round(converted/100))) * 100
+	case RoundingPsychological:
+		return int(math.Max(1, math.Round(converted/100)))*100 - 1
+	}
+	return int(math.Max(1, math.Round(converted)))
+}
+
+// minorUnits returns the number of smallest units in one unit of currency
+func minorUnits(currency string) float64 {
+	if zeroDecimalCurrencies[currency] {
+		return 1
+	}
+	return 100
+}
+
+func isRounding(rounding string) bool {
+	for _, r := range RoundingModes {
+		if rounding == r {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRates reads exchange rates from JSON: either a flat object of rates
+// ({"eur": 0.92}) or an object with "base" and "rates" as most FX APIs return.
+// Currency codes are lowercased; base is "" when the file doesn't name one.
+func ParseRates(content []byte) (base string, rates map[string]float64, err error) {
+	var doc map[string]any
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return "", nil, fmt.Errorf("failed to parse rates: %w", err)
+	}
+	if nested, ok := doc["rates"].(map[string]any); ok {
+		if b, ok := doc["base"].(string); ok {
+			base = strings.ToLower(b)
+		}
+		doc = nested
+	}
+
+	rates = make(map[string]float64, len(doc))
+	for currency, value := range doc {
+		rate, ok := value.(float64)
+		if !ok || rate <= 0 {
+			return "", nil, fmt.Errorf("invalid rate for %s: %v (use a positive number)", currency, value)
+		}
+		rates[strings.ToLower(currency)] = rate
+	}
+	return base, rates, nil
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFileName is the manifest listing the config files of a repository
+const ManifestFileName = "raterunner.manifest.yaml"
+
+// Manifest lists config files to validate and apply together
+type Manifest struct {
+	Files []ManifestEntry `yaml:"files"`
+}
+
+// ManifestEntry is one config file in a manifest
+type ManifestEntry struct {
+	Path string   `yaml:"path"`           // relative to the manifest
+	Type string   `yaml:"type,omitempty"` // billing or provider; detected like validate when empty
+	Envs []string `yaml:"envs,omitempty"` // environments apply targets; empty = all
+}
+
+// TargetsEnv reports whether apply --env env includes the entry
+func (e ManifestEntry) TargetsEnv(env string) bool {
+	if len(e.Envs) == 0 {
+		return true
+	}
+	for _, target := range e.Envs {
+		if target == env {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadManifest loads a manifest and resolves its paths against the manifest's directory
+func LoadManifest(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if len(manifest.Files) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no files", path)
+	}
+
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	for i := range manifest.Files {
+		entry := &manifest.Files[i]
+		if entry.Path == "" {
+			return nil, fmt.Errorf("manifest %s: files[%d]: path is required", path, i)
+		}
+		switch entry.Type {
+		case "", "billing", "provider":
+		default:
+			return nil, fmt.Errorf("manifest %s: %s: unknown type '%s' (use 'billing' or 'provider')", path, entry.Path, entry.Type)
+		}
+		for _, env := range entry.Envs {
+			if env != "sandbox" && env != "production" {
+				return nil, fmt.Errorf("manifest %s: %s: invalid env '%s' (use 'sandbox' or 'production')", path, entry.Path, env)
+			}
+		}
+		if !filepath.IsAbs(entry.Path) {
+			entry.Path = filepath.Join(dir, entry.Path)
+		}
+		if seen[entry.Path] {
+			return nil, fmt.Errorf("manifest %s: %s is listed more than once", path, entry.Path)
+		}
+		seen[entry.Path] = true
+	}
+
+	return &manifest, nil
+}
+
+// FindManifest walks up from dir looking for raterunner.manifest.yaml
+func FindManifest(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	path, ok := findUp(abs, ManifestFileName)
+	if !ok {
+		return "", fmt.Errorf("no %s found in %s or any parent directory", ManifestFileName, abs)
+	}
+	return path, nil
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MetadataFields are the metadata keys raterunner writes to billing provider
+// objects, by their default names. settings.metadata_prefix and
+// metadata_mapping change how they are stored.
+var MetadataFields = []string{
+	"plan_code",
+	"previous_plan_code",
+	"addon_code",
+	"type",
+	"headline",
+	"plan_type",
+	"public",
+	"billing_model",
+	"grace_days",
+	"dunning_retry_days",
+	"dunning_cancel_after_failures",
+	"dunning_emails",
+	"trial_require_payment_method",
+	"trial_end_behavior",
+	"trial_downgrade_to",
+	"upgrades_to",
+	"downgrades_to",
+	"downgrade_policy",
+	"collection_method",
+	"days_until_due",
+	"stackable",
+	"excludes",
+	"catalog_version",
+	"region",
+	"credit_code",
+	"credit_entitlement",
+	"credits",
+	"credit_expires_days",
+}
+
+// RequiredMetadataFields are used to match products and prices and can't be
+// omitted
+var RequiredMetadataFields = map[string]bool{
+	"plan_code":   true,
+	"addon_code":  true,
+	"region":      true,
+	"credit_code": true,
+}
+
+// MetadataKeys returns the stored key for every metadata field: the
+// metadata_mapping target when set ("" when the field is omitted), otherwise the
+// default name with settings.metadata_prefix applied.
+func (c *BillingConfig) MetadataKeys() (map[string]string, error) {
+	keys := make(map[string]string, len(MetadataFields))
+	for _, field := range MetadataFields {
+		keys[field] = c.MetadataPrefix() + field
+	}
+
+	fields := make([]string, 0, len(c.MetadataMapping))
+	for field := range c.MetadataMapping {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		if _, ok := keys[field]; !ok {
+			return nil, fmt.Errorf("metadata_mapping: unknown field '%s'", field)
+		}
+		switch target := c.MetadataMapping[field].(type) {
+		case string:
+			keys[field] = target
+		case bool:
+			if target {
+				return nil, fmt.Errorf("metadata_mapping: '%s' must be a key name or false, got true", field)
+			}
+			if RequiredMetadataFields[field] {
+				return nil, fmt.Errorf("metadata_mapping: '%s' is used for matching and can't be omitted", field)
+			}
+			keys[field] = ""
+		default:
+			return nil, fmt.Errorf("metadata_mapping: '%s' must be a key name or false, got %v", field, target)
+		}
+	}
+	return keys, nil
+}
+
+// ProvisioningKeyPrefix starts the metadata key of every provisioning value
+const ProvisioningKeyPrefix = "provisioning_"
+
+// ProvisioningMetadata returns a plan's provisioning block as product metadata,
+// keyed provisioning_<key> under settings.metadata_prefix. Lists are joined
+// with commas.
+func (c *BillingConfig) ProvisioningMetadata(plan Plan) map[string]string {
+	meta := make(map[string]string, len(plan.Provisioning))
+	for key, value := range plan.Provisioning {
+		meta[c.MetadataPrefix()+ProvisioningKeyPrefix+key] = ProvisioningValue(value)
+	}
+	return meta
+}
+
+// ProvisioningValue formats a provisioning value as a metadata string
+func ProvisioningValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
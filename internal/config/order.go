@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SortPlansByReferences orders plans so that each one comes after the plans it
+// names in upgrades_to, keeping config order otherwise. References to plans
+// that aren't in plans are ignored. A reference cycle is an error naming it.
+func SortPlansByReferences(plans []Plan) ([]Plan, error) {
+	sorted, cycle := sortPlans(plans, upgradeTargets)
+	if cycle != nil {
+		return nil, fmt.Errorf("plans reference each other in upgrades_to: %s", strings.Join(cycle, " -> "))
+	}
+	return sorted, nil
+}
+
+// UpgradeCycle returns the plan IDs along a cycle in upgrades_to, starting and
+// ending with the same plan, or nil when upgrade paths don't loop
+func UpgradeCycle(plans []Plan) []string {
+	_, cycle := sortPlans(plans, upgradeTargets)
+	return cycle
+}
+
+// DowngradeCycle is UpgradeCycle for downgrades_to
+func DowngradeCycle(plans []Plan) []string {
+	_, cycle := sortPlans(plans, func(p Plan) []string { return p.DowngradesTo })
+	return cycle
+}
+
+func upgradeTargets(p Plan) []string {
+	return p.UpgradesTo
+}
+
+// sortPlans orders plans after the plans they reference, or returns the first
+// reference cycle it finds
+func sortPlans(plans []Plan, targets func(Plan) []string) ([]Plan, []string) {
+	byID := make(map[string]int, len(plans))
+	for i, plan := range plans {
+		byID[plan.ID] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(plans))
+	sorted := make([]Plan, 0, len(plans))
+	var path []string
+
+	var visit func(i int) []string
+	visit = func(i int) []string {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			start := 0
+			for path[start] != plans[i].ID {
+				start++
+			}
+			return append(path[start:len(path):len(path)], plans[i].ID)
+		}
+
+		state[i] = visiting
+		path = append(path, plans[i].ID)
+		for _, target := range targets(plans[i]) {
+			if j, ok := byID[target]; ok {
+				if cycle := visit(j); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = done
+		sorted = append(sorted, plans[i])
+		return nil
+	}
+
+	for i := range plans {
+		if cycle := visit(i); cycle != nil {
+			return nil, cycle
+		}
+	}
+	return sorted, nil
+}
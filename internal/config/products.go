@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProductsDir holds one directory per product in a multi-product repository,
+// each with its own billing.yaml and raterunner/ provider files
+const ProductsDir = "products"
+
+// Product is one catalog in a multi-product repository
+type Product struct {
+	Name string
+	Path string // the product's billing.yaml
+}
+
+// FindProducts walks up from dir looking for a products/ directory and returns
+// every product in it that has a billing.yaml, sorted by name
+func FindProducts(dir string) ([]Product, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve directory: %w", err)
+	}
+
+	for current := abs; ; {
+		products, err := listProducts(filepath.Join(current, ProductsDir))
+		if err != nil {
+			return nil, err
+		}
+		if len(products) > 0 {
+			return products, nil
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return nil, fmt.Errorf("no %s/<name>/billing.yaml found in %s or any parent directory", ProductsDir, abs)
+		}
+		current = parent
+	}
+}
+
+// FindProductBillingFile returns the billing.yaml of the named product
+func FindProductBillingFile(dir, name string) (string, error) {
+	products, err := FindProducts(dir)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, len(products))
+	for i, product := range products {
+		if product.Name == name {
+			return product.Path, nil
+		}
+		names[i] = product.Name
+	}
+	return "", fmt.Errorf("unknown product '%s' (available: %s)", name, strings.Join(names, ", "))
+}
+
+// listProducts returns the subdirectories of dir that contain a billing.yaml
+func listProducts(dir string) ([]Product, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var products []Product
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name(), "billing.yaml")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			products = append(products, Product{Name: entry.Name(), Path: path})
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+	return products, nil
+}
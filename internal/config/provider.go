@@ -5,27 +5,51 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// ProviderFileVersion is the format version of the provider files this binary
+// writes. Files without a version predate plan ID history and are version 1.
+const ProviderFileVersion = 2
+
+// DefaultProviderHistory is how many sets of replaced IDs apply keeps per plan
+const DefaultProviderHistory = 5
+
 // ProviderConfig represents the provider ID mapping file
 type ProviderConfig struct {
-	Provider    string               `yaml:"provider"`
-	Environment string               `yaml:"environment"`
-	SyncedAt    string               `yaml:"synced_at,omitempty"`
-	Plans       map[string]PlanIDs   `yaml:"plans,omitempty"`
-	Addons      map[string]ProductIDs `yaml:"addons,omitempty"`
-	Promotions  map[string]string    `yaml:"promotions,omitempty"`
+	Version        int                   `yaml:"version,omitempty"`
+	Provider       string                `yaml:"provider"`
+	Environment    string                `yaml:"environment"`
+	SyncedAt       string                `yaml:"synced_at,omitempty"`
+	CatalogVersion string                `yaml:"catalog_version,omitempty"` // config hash and git commit of the last apply
+	Plans          map[string]PlanIDs    `yaml:"plans,omitempty"`
+	Addons         map[string]ProductIDs `yaml:"addons,omitempty"`
+	Credits        map[string]ProductIDs `yaml:"credits,omitempty"`
+	Meters         map[string]string     `yaml:"meters,omitempty"` // meter key -> meter ID
+	Promotions     map[string]string     `yaml:"promotions,omitempty"`
+	Pending        map[string]string     `yaml:"pending_promotions,omitempty"` // code -> starts_at
 }
 
 // PlanIDs contains Stripe IDs for a plan
 type PlanIDs struct {
-	ProductID string            `yaml:"product_id"`
-	Prices    map[string]string `yaml:"prices,omitempty"` // interval -> price_id
+	ProductID string                       `yaml:"product_id"`
+	Prices    map[string]string            `yaml:"prices,omitempty"`  // interval -> price_id
+	Regions   map[string]map[string]string `yaml:"regions,omitempty"` // region -> interval -> price_id
+	History   []ReplacedIDs                `yaml:"history,omitempty"` // newest first
+}
+
+// ReplacedIDs are the IDs of a plan that an apply replaced, e.g. when it
+// archived a price and created a new one, so their holders can still be
+// resolved to the plan
+type ReplacedIDs struct {
+	ReplacedAt string            `yaml:"replaced_at"`
+	ProductID  string            `yaml:"product_id,omitempty"`
+	Prices     map[string]string `yaml:"prices,omitempty"`
 }
 
-// ProductIDs contains Stripe IDs for an addon
+// ProductIDs contains Stripe IDs for an addon or a credit pack
 type ProductIDs struct {
 	ProductID string `yaml:"product_id"`
 	PriceID   string `yaml:"price_id"`
@@ -48,9 +72,10 @@ func ProviderFilePath(billingPath, provider, env string) string {
 	return filepath.Join(dir, "raterunner", fmt.Sprintf("%s_%s.yaml", provider, env))
 }
 
-// LoadProviderFile loads a provider config from a file
+// LoadProviderFile loads a provider config from a file, decrypting it first when
+// it is encrypted with sops
 func LoadProviderFile(path string) (*ProviderConfig, error) {
-	content, err := os.ReadFile(path)
+	content, err := ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
@@ -64,6 +89,9 @@ func LoadProviderFile(path string) (*ProviderConfig, error) {
 	if err := yaml.Unmarshal(content, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
+	if cfg.Version > ProviderFileVersion {
+		return nil, fmt.Errorf("provider file version %d is newer than this binary supports (%d); upgrade raterunner", cfg.Version, ProviderFileVersion)
+	}
 
 	return &cfg, nil
 }
@@ -76,14 +104,73 @@ func SaveProviderFile(path string, cfg *ProviderConfig) error {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
+	cfg.Version = ProviderFileVersion
 	content, err := yaml.Marshal(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal YAML: %w", err)
 	}
 
-	if err := os.WriteFile(path, content, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	return writeConfigFile(path, content)
+}
+
+// FileVersion returns the format version of a loaded provider file
+func (p *ProviderConfig) FileVersion() int {
+	if p.Version == 0 {
+		return 1
+	}
+	return p.Version
+}
+
+// RecordHistory adds the IDs previous had for each plan and p replaces to the
+// plan's history, keeping at most limit entries. Plans whose IDs didn't
+// change keep their history as is.
+func (p *ProviderConfig) RecordHistory(previous *ProviderConfig, at time.Time, limit int) {
+	if previous == nil {
+		return
 	}
+	for planID, ids := range p.Plans {
+		old, ok := previous.Plans[planID]
+		if !ok {
+			continue
+		}
+		ids.History = old.History
 
-	return nil
+		replaced := ReplacedIDs{ReplacedAt: at.UTC().Format(time.RFC3339)}
+		if old.ProductID != "" && old.ProductID != ids.ProductID {
+			replaced.ProductID = old.ProductID
+		}
+		for interval, priceID := range old.Prices {
+			if ids.Prices[interval] != priceID {
+				if replaced.Prices == nil {
+					replaced.Prices = make(map[string]string)
+				}
+				replaced.Prices[interval] = priceID
+			}
+		}
+		if replaced.ProductID != "" || len(replaced.Prices) > 0 {
+			ids.History = append([]ReplacedIDs{replaced}, ids.History...)
+		}
+		if len(ids.History) > limit {
+			ids.History = ids.History[:limit]
+		}
+		p.Plans[planID] = ids
+	}
+}
+
+// CompactHistory keeps the keep newest history entries of each plan and
+// returns how many entries it dropped
+func (p *ProviderConfig) CompactHistory(keep int) int {
+	dropped := 0
+	for planID, ids := range p.Plans {
+		if len(ids.History) <= keep {
+			continue
+		}
+		dropped += len(ids.History) - keep
+		ids.History = ids.History[:keep]
+		if len(ids.History) == 0 {
+			ids.History = nil
+		}
+		p.Plans[planID] = ids
+	}
+	return dropped
 }
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyRef is an occurrence of an entitlement key, as a JSON pointer and the
+// one-based line of the key
+type KeyRef struct {
+	Path string
+	Line int
+}
+
+// RenameEntitlement renames an entitlement key in a billing config or a JSON
+// export: the entitlements definition, plan limits and addon grants. Only the
+// key tokens are rewritten, so formatting and comments are kept. It returns the
+// new content and the renamed keys, with paths using the new key.
+func RenameEntitlement(content []byte, oldKey, newKey string) ([]byte, []KeyRef, error) {
+	if oldKey == newKey {
+		return nil, nil, fmt.Errorf("old and new entitlement keys are the same: %s", oldKey)
+	}
+	if newKey == "" {
+		return nil, nil, fmt.Errorf("new entitlement key is empty")
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config is not a mapping")
+	}
+
+	var keys []*yaml.Node
+	var refs []KeyRef
+	seen := make(map[*yaml.Node]bool) // a map shared through an anchor is renamed once
+	rename := func(m *yaml.Node, path string) error {
+		if m != nil && m.Kind == yaml.AliasNode {
+			m = m.Alias
+		}
+		if m == nil || m.Kind != yaml.MappingNode || seen[m] {
+			return nil
+		}
+		seen[m] = true
+		var found *yaml.Node
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			switch m.Content[i].Value {
+			case oldKey:
+				found = m.Content[i]
+			case newKey:
+				return fmt.Errorf("entitlement '%s' already exists at %s/%s", newKey, path, newKey)
+			}
+		}
+		if found != nil {
+			keys = append(keys, found)
+			refs = append(refs, KeyRef{Path: path + "/" + newKey, Line: found.Line})
+		}
+		return nil
+	}
+
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		value := root.Content[i+1]
+		switch root.Content[i].Value {
+		case "entitlements":
+			if err := rename(value, "/entitlements"); err != nil {
+				return nil, nil, err
+			}
+		case "plans", "addons":
+			field := "limits"
+			if root.Content[i].Value == "addons" {
+				field = "grants"
+			}
+			if value.Kind != yaml.SequenceNode {
+				continue
+			}
+			for j, item := range value.Content {
+				path := fmt.Sprintf("/%s/%d/%s", root.Content[i].Value, j, field)
+				if err := rename(mappingValue(item, field), path); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("entitlement '%s' is not used in entitlements, plan limits or addon grants", oldKey)
+	}
+
+	idx := newSourceIndex(content)
+	edits := make([]textEdit, len(keys))
+	for i, key := range keys {
+		// The key token may be quoted
+		start := idx.offset(key)
+		start += bytes.Index(content[start:idx.lineEnd(key.Line)], []byte(oldKey))
+		edits[i] = textEdit{start: start, end: start + len(oldKey), text: newKey}
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Line < refs[j].Line })
+	return applyEdits(content, edits), refs, nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// SaveFile writes raw content to a config file, re-encrypting it with sops
+// when the file is encrypted
+func SaveFile(path string, content []byte) error {
+	return writeConfigFile(path, content)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -10,8 +11,68 @@ import (
 // CLISettings represents persistent CLI configuration
 type CLISettings struct {
 	Quiet bool `yaml:"quiet,omitempty" json:"quiet,omitempty"`
+	// DefaultEnv is used when --env is omitted. Only sandbox is allowed:
+	// production must always be requested explicitly.
+	DefaultEnv string `yaml:"default_env,omitempty" json:"default_env,omitempty"`
+	// SchemaDir replaces the embedded schemas for validate when --schema-dir is omitted
+	SchemaDir string `yaml:"schema_dir,omitempty" json:"schema_dir,omitempty"`
+	// StrictWarnings makes apply fail when the sync produced warnings, as --strict-warnings does
+	StrictWarnings bool `yaml:"strict_warnings,omitempty" json:"strict_warnings,omitempty"`
+	// Role limits which commands may run: viewer, editor or admin. Empty means admin.
+	Role string `yaml:"role,omitempty" json:"role,omitempty"`
+	// HTTPMaxIdleConns is how many keep-alive connections to Stripe stay open between calls (0 = default)
+	HTTPMaxIdleConns int `yaml:"http_max_idle_conns,omitempty" json:"http_max_idle_conns,omitempty"`
+	// HTTPMaxConnsPerHost caps the connections open to Stripe at once (0 = no limit)
+	HTTPMaxConnsPerHost int `yaml:"http_max_conns_per_host,omitempty" json:"http_max_conns_per_host,omitempty"`
+	// RetryMaxAttempts is how often a rate-limited or failed Stripe call is sent, including the first time (0 = default)
+	RetryMaxAttempts int `yaml:"retry_max_attempts,omitempty" json:"retry_max_attempts,omitempty"`
+	// RetryBackoff is the wait before the first retry as a duration, e.g. 500ms, doubled for each later one ("" = default)
+	RetryBackoff string `yaml:"retry_backoff,omitempty" json:"retry_backoff,omitempty"`
+	// RetryJitter is the fraction of each wait that is randomized, 0 to 1 (nil = default)
+	RetryJitter *float64 `yaml:"retry_jitter,omitempty" json:"retry_jitter,omitempty"`
+	// Telemetry opts in to anonymous usage events. Only honored in the user settings.
+	Telemetry bool `yaml:"telemetry,omitempty" json:"telemetry,omitempty"`
+	// TelemetryEndpoint replaces the default endpoint events are sent to
+	TelemetryEndpoint string `yaml:"telemetry_endpoint,omitempty" json:"telemetry_endpoint,omitempty"`
+	// TelemetryID is the random install ID generated on opt-in, cleared on opt-out
+	TelemetryID string `yaml:"telemetry_id,omitempty" json:"telemetry_id,omitempty"`
+	// DiagnosticsDir is where crash diagnostics bundles go when --diagnostics-dir is omitted
+	DiagnosticsDir string `yaml:"diagnostics_dir,omitempty" json:"diagnostics_dir,omitempty"`
 }
 
+// Roles in increasing order of what they may run
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Roles lists the roles from least to most privileged
+var Roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+// RoleAllows reports whether role may run a command that requires the given
+// role. An empty role is treated as admin.
+func RoleAllows(role, required string) (bool, error) {
+	if role == "" {
+		role = RoleAdmin
+	}
+	rank := func(r string) int {
+		for i, name := range Roles {
+			if name == r {
+				return i
+			}
+		}
+		return -1
+	}
+	if rank(role) < 0 {
+		return false, fmt.Errorf("invalid role: %s (use viewer, editor or admin)", role)
+	}
+	return rank(role) >= rank(required), nil
+}
+
+// ProjectSettingsFile is the project-local settings file, meant to be checked into the repo
+const ProjectSettingsFile = ".raterunner.yaml"
+
 // DefaultSettingsPath returns the default path for CLI settings
 func DefaultSettingsPath() string {
 	home, err := os.UserHomeDir()
@@ -45,6 +106,60 @@ func LoadSettingsFrom(path string) (*CLISettings, error) {
 	return settings, nil
 }
 
+// FindProjectSettings walks up from dir looking for a project .raterunner.yaml.
+// The user settings file itself is never treated as a project file.
+func FindProjectSettings(dir string) (string, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+
+	path, ok := findUp(abs, ProjectSettingsFile)
+	if !ok || path == DefaultSettingsPath() {
+		return "", false
+	}
+	return path, true
+}
+
+// LoadEffectiveSettings loads the user settings and merges the nearest project
+// .raterunner.yaml (searched from dir upwards) on top of them. Keys present in
+// the project file win; a relative schema_dir or diagnostics_dir is resolved
+// against its directory.
+func LoadEffectiveSettings(dir string) (*CLISettings, error) {
+	settings, err := LoadSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	path, ok := FindProjectSettings(dir)
+	if !ok {
+		return settings, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal onto the user settings so only keys present in the project file override
+	if err := yaml.Unmarshal(data, settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	project := &CLISettings{}
+	if err := yaml.Unmarshal(data, project); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if project.SchemaDir != "" && !filepath.IsAbs(project.SchemaDir) {
+		settings.SchemaDir = filepath.Join(filepath.Dir(path), project.SchemaDir)
+	}
+	if project.DiagnosticsDir != "" && !filepath.IsAbs(project.DiagnosticsDir) {
+		settings.DiagnosticsDir = filepath.Join(filepath.Dir(path), project.DiagnosticsDir)
+	}
+
+	return settings, nil
+}
+
 // SaveSettings saves CLI settings to the default path
 func SaveSettings(settings *CLISettings) error {
 	return SaveSettingsTo(DefaultSettingsPath(), settings)
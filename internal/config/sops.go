@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sopsCommand is the sops binary used to decrypt and re-encrypt config files
+const sopsCommand = "sops"
+
+// IsSOPSEncrypted reports whether a YAML or JSON document carries the metadata
+// block sops adds when it encrypts a file
+func IsSOPSEncrypted(content []byte) bool {
+	if !bytes.Contains(content, []byte("sops")) {
+		return false
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return false
+	}
+	meta, ok := doc["sops"].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, hasMAC := meta["mac"]
+	return hasMAC
+}
+
+// ReadFile reads a config file, decrypting it with sops when it is encrypted
+func ReadFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !IsSOPSEncrypted(content) {
+		return content, nil
+	}
+	return runSOPS("--decrypt", path)
+}
+
+// DecryptContent decrypts sops-encrypted content read from somewhere other
+// than a file (stdin). Plain content is returned unchanged.
+func DecryptContent(content []byte, format string) ([]byte, error) {
+	if !IsSOPSEncrypted(content) {
+		return content, nil
+	}
+
+	// sops picks the document format from the file extension
+	tmp, err := os.CreateTemp("", "raterunner-*."+format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	return runSOPS("--decrypt", tmp.Name())
+}
+
+// writeConfigFile writes content to path. When path holds a sops-encrypted file,
+// the new content is encrypted with the creation rules of the project's
+// .sops.yaml for path and renamed over it, so path never holds plaintext and
+// keeps the previous file if encryption fails.
+func writeConfigFile(path string, content []byte) error {
+	previous, err := os.ReadFile(path)
+	if err != nil || !IsSOPSEncrypted(previous) {
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		return nil
+	}
+
+	// The plaintext only ever lives in a private temp file outside the project
+	plain, err := os.CreateTemp("", "raterunner-*"+filepath.Ext(path))
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(plain.Name())
+	if _, err := plain.Write(content); err != nil {
+		plain.Close()
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := plain.Close(); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	// --filename-override matches the creation rules against path, not the temp file
+	encrypted, err := runSOPS("--encrypt", "--filename-override", path, plain.Name())
+	if err != nil {
+		return fmt.Errorf("%w (is there a .sops.yaml creation rule for %s?)", err, filepath.Base(path))
+	}
+
+	next, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(next.Name())
+	_, werr := next.Write(encrypted)
+	cerr := next.Close()
+	if werr != nil || cerr != nil {
+		return fmt.Errorf("failed to write file: %w", errors.Join(werr, cerr))
+	}
+	if err := os.Chmod(next.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(next.Name(), path); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+// runSOPS runs sops and returns its output, with sops' own message on failure
+func runSOPS(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(sopsCommand, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("file is encrypted with sops, but the sops binary was not found in PATH (install it from https://github.com/getsops/sops)")
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("sops %s failed: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("sops %s failed: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
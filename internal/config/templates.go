@@ -0,0 +1,37 @@
+package config
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Starting configs for `init --template`, one per business model
+//
+//go:embed templates/*.yaml
+var templatesFS embed.FS
+
+// Templates returns the names of the init templates
+func Templates() []string {
+	entries, _ := templatesFS.ReadDir("templates")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Template returns the billing.yaml content of an init template; "" is the
+// generic example
+func Template(name string) ([]byte, error) {
+	if name == "" {
+		return []byte(InitTemplate), nil
+	}
+	content, err := templatesFS.ReadFile("templates/" + name + ".yaml")
+	if err != nil {
+		return nil, fmt.Errorf("unknown template '%s' (available: %s)", name, strings.Join(Templates(), ", "))
+	}
+	return content, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// CatalogHash returns a short hash of the loaded config. It is computed from the
+// parsed config rather than the file, so comments and formatting don't count,
+// and neither does the order of providers, plans, addons, credit packs and
+// promotions.
+func (c *BillingConfig) CatalogHash() string {
+	data, err := json.Marshal(c.canonical())
+	if err != nil {
+		// Configs loaded from YAML or JSON always marshal
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// canonical returns a copy of the config with its top-level lists sorted by ID.
+// Lists inside them keep their order, since it shows up in Stripe (features
+// become marketing features in order).
+func (c *BillingConfig) canonical() *BillingConfig {
+	cc := *c
+	cc.Providers = append([]string(nil), c.Providers...)
+	sort.Strings(cc.Providers)
+	cc.Plans = append([]Plan(nil), c.Plans...)
+	sort.SliceStable(cc.Plans, func(i, j int) bool { return cc.Plans[i].ID < cc.Plans[j].ID })
+	cc.Addons = append([]Addon(nil), c.Addons...)
+	sort.SliceStable(cc.Addons, func(i, j int) bool { return cc.Addons[i].ID < cc.Addons[j].ID })
+	if c.Credits != nil {
+		cc.Credits = append([]CreditPack(nil), c.Credits...)
+		sort.SliceStable(cc.Credits, func(i, j int) bool { return cc.Credits[i].ID < cc.Credits[j].ID })
+	}
+	cc.Promotions = append([]Promotion(nil), c.Promotions...)
+	sort.SliceStable(cc.Promotions, func(i, j int) bool { return cc.Promotions[i].Code < cc.Promotions[j].Code })
+	return &cc
+}
+
+// HasPromotionWindows reports whether any promotion has starts_at or expires,
+// which apply acts on as the dates pass even when the config is unchanged
+func (c *BillingConfig) HasPromotionWindows() bool {
+	for _, p := range c.Promotions {
+		if p.StartsAt != "" || p.Expires != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// CatalogVersion combines a config hash with the git commit it was applied
+// from, e.g. "3f9a1c0e5b2d@a1b2c3d". The commit is left out when unknown.
+func CatalogVersion(hash, gitCommit string) string {
+	if gitCommit == "" {
+		return hash
+	}
+	return hash + "@" + gitCommit
+}
+
+// CatalogVersionHash returns the config hash part of a catalog version
+func CatalogVersionHash(version string) string {
+	hash, _, _ := strings.Cut(version, "@")
+	return hash
+}
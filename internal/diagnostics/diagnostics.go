@@ -0,0 +1,143 @@
+// Package diagnostics writes redacted bundles users can attach to bug reports
+// when a command crashes or an API fails unexpectedly.
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"raterunner/internal/redact"
+)
+
+// TailLines is how many output lines a bundle keeps
+const TailLines = 200
+
+// Bundle is what a diagnostics file holds. Nothing in it comes from the
+// environment, and secrets are redacted from everything that came from output.
+type Bundle struct {
+	CreatedAt string   `json:"created_at"`
+	Version   string   `json:"version"`
+	GoVersion string   `json:"go_version"`
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	Command   string   `json:"command"`
+	Flags     []string `json:"flags,omitempty"` // names only
+	Error     string   `json:"error"`
+	Stack     string   `json:"stack,omitempty"` // set after a panic
+
+	ConfigFile      string `json:"config_file,omitempty"`
+	ConfigStructure any    `json:"config_structure,omitempty"` // keys kept, values replaced by their type
+
+	Log []string `json:"log"` // the last TailLines lines of output
+}
+
+// freeformKeys are maps whose keys are user data (plan IDs in provider
+// files, entitlement names, promotion codes) rather than schema fields
+var freeformKeys = map[string]bool{
+	"entitlements": true, "limits": true, "grants": true, "metadata": true,
+	"metadata_mapping": true, "provisioning": true,
+	"plans": true, "addons": true, "promotions": true, "pending_promotions": true,
+}
+
+// Structure parses a YAML or JSON config and returns its shape: schema keys
+// are kept, user-defined keys are numbered, and every value is replaced by
+// its type
+func Structure(content []byte) (any, error) {
+	var doc any
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return shape(doc, false), nil
+}
+
+func shape(v any, freeform bool) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		i := 0
+		for key, child := range val {
+			name := key
+			if freeform {
+				i++
+				name = fmt.Sprintf("<key %d>", i)
+			}
+			out[name] = shape(child, !freeform && freeformKeys[key])
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, child := range val {
+			out[i] = shape(child, false)
+		}
+		return out
+	case nil:
+		return "<null>"
+	case string:
+		return "<string>"
+	case bool:
+		return "<bool>"
+	case int, int64, float64:
+		return "<number>"
+	}
+	return fmt.Sprintf("<%T>", v)
+}
+
+// Tail keeps the last lines written to it, redacted
+type Tail struct {
+	lines   []string
+	partial []byte
+}
+
+func (t *Tail) Write(p []byte) (int, error) {
+	t.partial = append(t.partial, p...)
+	for {
+		i := strings.IndexByte(string(t.partial), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		t.add(string(t.partial[:i]))
+		t.partial = t.partial[i+1:]
+	}
+}
+
+func (t *Tail) add(line string) {
+	t.lines = append(t.lines, redact.Secrets(line))
+	if len(t.lines) > TailLines {
+		t.lines = t.lines[len(t.lines)-TailLines:]
+	}
+}
+
+// Lines returns the kept lines, including an unfinished last one
+func (t *Tail) Lines() []string {
+	lines := append([]string(nil), t.lines...)
+	if len(t.partial) > 0 {
+		lines = append(lines, redact.Secrets(string(t.partial)))
+	}
+	return lines
+}
+
+// Write saves a bundle in dir and returns its path. The file is only
+// readable by the user, since it holds paths and output.
+func Write(dir string, bundle *Bundle, now time.Time) (string, error) {
+	bundle.CreatedAt = now.UTC().Format(time.RFC3339)
+	bundle.Error = redact.Secrets(bundle.Error)
+
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode diagnostics: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create diagnostics directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("raterunner-diagnostics-%s.json", now.UTC().Format("20060102T150405Z")))
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return "", fmt.Errorf("failed to write diagnostics: %w", err)
+	}
+	return path, nil
+}
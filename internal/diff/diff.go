@@ -2,6 +2,8 @@ package diff
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,18 +13,59 @@ import (
 
 // Compare compares the local billing config with Stripe products
 func Compare(cfg *config.BillingConfig, products []stripe.Product, env string) *DiffResult {
+	return CompareProvider("stripe", cfg, products, env)
+}
+
+// CompareProvider compares the plans targeting a provider with its products,
+// for providers whose catalog is converted to Stripe's product shape
+func CompareProvider(providerName string, cfg *config.BillingConfig, products []stripe.Product, env string) *DiffResult {
 	result := &DiffResult{
 		Environment: env,
 		ComparedAt:  time.Now().Format("2006-01-02 15:04:05"),
 		Plans:       make([]PlanDiff, 0, len(cfg.Plans)),
 	}
 
+	// The key the public flag is stored under; "" when metadata_mapping omits it
+	publicKey := "public"
+	if keys, err := cfg.MetadataKeys(); err == nil {
+		publicKey = keys["public"]
+	}
+
 	for _, plan := range cfg.Plans {
-		// Skip plans not targeting Stripe
-		if !plan.HasProvider("stripe", cfg.Providers) {
+		// Skip plans not targeting the provider
+		if !plan.HasProvider(providerName, cfg.Providers) {
+			continue
+		}
+
+		// Plans provisioned outside Stripe are listed but never count as missing
+		if !plan.IsSynced() {
+			details := "sync: false (provisioned externally)"
+			if plan.IsCustomPricing() {
+				details = "pricing: custom (contact sales)"
+			}
+			result.Plans = append(result.Plans, PlanDiff{
+				PlanID:   plan.ID,
+				PlanName: plan.Name,
+				Status:   StatusSkipped,
+				Details:  details,
+			})
+			result.Summary.Skipped++
 			continue
 		}
-		planDiff := comparePlan(plan, products)
+
+		// Plans limited to other environments are never created here
+		if !plan.InEnvironment(env) {
+			result.Plans = append(result.Plans, PlanDiff{
+				PlanID:   plan.ID,
+				PlanName: plan.Name,
+				Status:   StatusSkipped,
+				Details:  fmt.Sprintf("not available in %s (environments: %s)", env, strings.Join(plan.Environments, ", ")),
+			})
+			result.Summary.Skipped++
+			continue
+		}
+
+		planDiff := comparePlan(plan, products, publicKey)
 		result.Plans = append(result.Plans, planDiff)
 
 		switch planDiff.Status {
@@ -36,75 +79,348 @@ func Compare(cfg *config.BillingConfig, products []stripe.Product, env string) *
 		result.Summary.Total++
 	}
 
+	for _, addon := range cfg.Addons {
+		addonDiff := compareAddon(addon, products)
+		result.Addons = append(result.Addons, addonDiff)
+
+		switch addonDiff.Status {
+		case StatusMissing:
+			result.Summary.AddonsMissing++
+		case StatusDiffers:
+			result.Summary.AddonsDiffer++
+		}
+	}
+
+	for _, pack := range cfg.Credits {
+		creditDiff := compareCreditPack(pack, products)
+		result.Credits = append(result.Credits, creditDiff)
+
+		switch creditDiff.Status {
+		case StatusMissing:
+			result.Summary.CreditsMissing++
+		case StatusDiffers:
+			result.Summary.CreditsDiffer++
+		}
+	}
+
+	result.CatalogVersion = compareCatalogVersion(providerName, cfg, products, env, cfg.CatalogHash())
+
+	return result
+}
+
+// CompareOnly is Compare restricted to the plans in planIDs, for products
+// fetched for those plans alone. Addons and credit packs are left out, and
+// catalog versions are still compared with the hash of the whole config.
+func CompareOnly(cfg *config.BillingConfig, products []stripe.Product, env string, planIDs []string) *DiffResult {
+	wanted := make(map[string]bool, len(planIDs))
+	for _, id := range planIDs {
+		wanted[id] = true
+	}
+	only := *cfg
+	only.Plans = nil
+	only.Addons = nil
+	only.Credits = nil
+	for _, plan := range cfg.Plans {
+		if wanted[plan.ID] {
+			only.Plans = append(only.Plans, plan)
+		}
+	}
+
+	result := Compare(&only, products, env)
+	result.CatalogVersion = compareCatalogVersion("stripe", &only, products, env, cfg.CatalogHash())
 	return result
 }
 
+// AddMeters compares the config's meters with Stripe's, matched by event
+// name, and adds them to result. Meters are fetched separately from products,
+// so callers that fetched them add them after Compare.
+func AddMeters(result *DiffResult, cfg *config.BillingConfig, meters []stripe.Meter) {
+	for _, key := range cfg.MeterKeys() {
+		meterDiff := compareMeter(key, cfg.Meters[key], meters)
+		result.Meters = append(result.Meters, meterDiff)
+
+		switch meterDiff.Status {
+		case StatusMissing:
+			result.Summary.MetersMissing++
+		case StatusDiffers:
+			result.Summary.MetersDiffer++
+		}
+	}
+}
+
+// compareMeter compares a meter with the Stripe meter of its event name
+func compareMeter(key string, meter config.Meter, meters []stripe.Meter) MeterDiff {
+	diff := MeterDiff{
+		MeterKey:  key,
+		EventName: meter.EventName,
+	}
+
+	m := stripe.MatchMeter(meters, meter.EventName)
+	if m == nil {
+		diff.Status = StatusMissing
+		diff.Details = "Not in Stripe"
+		return diff
+	}
+	diff.MeterID = m.ID
+
+	var details []string
+	if !m.Active {
+		details = append(details, "inactive in Stripe")
+	}
+	if m.DisplayName != meter.DisplayName {
+		details = append(details, fmt.Sprintf("display_name: local=%s stripe=%s", meter.DisplayName, m.DisplayName))
+	}
+	if aggregation := meter.AggregationOrDefault(); m.Aggregation != aggregation {
+		details = append(details, fmt.Sprintf("aggregation: local=%s stripe=%s (can't be changed)", aggregation, m.Aggregation))
+	}
+
+	if len(details) > 0 {
+		diff.Status = StatusDiffers
+		diff.Details = strings.Join(details, ", ")
+	} else {
+		diff.Status = StatusOK
+	}
+	return diff
+}
+
+// compareCatalogVersion checks the catalog_version stamped on the products of
+// synced plans against the local config hash. Only the hash is compared, so
+// applying the same config from another commit doesn't count as drift.
+func compareCatalogVersion(providerName string, cfg *config.BillingConfig, products []stripe.Product, env, local string) *CatalogVersionDiff {
+	diff := &CatalogVersionDiff{Local: local, Status: StatusOK}
+
+	seen := make(map[string]bool)
+	for _, plan := range cfg.Plans {
+		if !plan.HasProvider(providerName, cfg.Providers) || !plan.IsSynced() || !plan.InEnvironment(env) {
+			continue
+		}
+		product := stripe.MatchProduct(products, plan.ID, plan.Name, plan.PreviousIDs...)
+		if product == nil || product.CatalogVersion == "" || seen[product.CatalogVersion] {
+			continue
+		}
+		seen[product.CatalogVersion] = true
+		diff.Stripe = append(diff.Stripe, product.CatalogVersion)
+		if config.CatalogVersionHash(product.CatalogVersion) != local {
+			diff.Status = StatusDiffers
+		}
+	}
+
+	if len(diff.Stripe) == 0 {
+		return nil
+	}
+	sort.Strings(diff.Stripe)
+	return diff
+}
+
 // comparePlan compares a single plan with Stripe products
-func comparePlan(plan config.Plan, products []stripe.Product) PlanDiff {
+func comparePlan(plan config.Plan, products []stripe.Product, publicKey string) PlanDiff {
 	diff := PlanDiff{
 		PlanID:   plan.ID,
 		PlanName: plan.Name,
 	}
 
 	// Find matching Stripe product
-	product := stripe.MatchProduct(products, plan.ID, plan.Name)
+	product := stripe.MatchProduct(products, plan.ID, plan.Name, plan.PreviousIDs...)
 	if product == nil {
 		diff.Status = StatusMissing
 		diff.Details = "Not in Stripe"
 		return diff
 	}
 
-	// Compare prices
+	var differDetails []string
+
+	// A product still tagged with an old plan ID gets retagged on apply
+	if oldID := stripe.RenamedFrom(product, plan.ID, plan.PreviousIDs); oldID != "" {
+		diff.RenamedFrom = oldID
+		differDetails = append(differDetails, fmt.Sprintf("renamed from '%s'", oldID))
+	}
+
+	// Products synced before the flag existed have no public metadata and count as public
+	if publicKey != "" {
+		stored, ok := product.Metadata[publicKey]
+		if !ok {
+			stored, ok = product.Metadata["public"]
+		}
+		if !ok {
+			stored = "true"
+		}
+		if want := strconv.FormatBool(plan.IsPublic()); stored != want {
+			differDetails = append(differDetails, fmt.Sprintf("public: local=%s stripe=%s", want, stored))
+		}
+	}
+
+	priceDiffs, details := comparePrices(plan.Prices, "", "", product.Prices)
+	differDetails = append(differDetails, details...)
+	for _, name := range plan.RegionNames() {
+		region := plan.Regions[name]
+		regionDiffs, details := comparePrices(region.Prices, name, region.Currency, product.Prices)
+		priceDiffs = append(priceDiffs, regionDiffs...)
+		differDetails = append(differDetails, details...)
+	}
+
+	diff.Prices = priceDiffs
+
+	if len(differDetails) > 0 {
+		diff.Status = StatusDiffers
+		diff.Details = strings.Join(differDetails, ", ")
+	} else {
+		diff.Status = StatusOK
+	}
+
+	return diff
+}
+
+// compareAddon compares an addon with the active one-time prices of its product.
+// Any active price with another amount differs: checkout could still sell it.
+func compareAddon(addon config.Addon, products []stripe.Product) AddonDiff {
+	diff := AddonDiff{
+		AddonID:     addon.ID,
+		AddonName:   addon.Name,
+		LocalAmount: addon.Price.Amount,
+	}
+
+	product := stripe.MatchProduct(products, addon.ID, addon.Name)
+	if product == nil {
+		diff.Status = StatusMissing
+		diff.Details = "Not in Stripe"
+		return diff
+	}
+
+	diff.StripeAmount, diff.Status, diff.Details = compareOneTimePrices(product, addon.Price.Amount)
+	return diff
+}
+
+// compareCreditPack compares a credit pack with the active one-time prices of
+// its product, like an addon
+func compareCreditPack(pack config.CreditPack, products []stripe.Product) CreditDiff {
+	diff := CreditDiff{
+		CreditID:    pack.ID,
+		CreditName:  pack.Name,
+		LocalAmount: pack.Amount,
+	}
+
+	product := stripe.MatchCreditPack(products, pack.ID, pack.Name)
+	if product == nil {
+		diff.Status = StatusMissing
+		diff.Details = "Not in Stripe"
+		return diff
+	}
+
+	diff.StripeAmount, diff.Status, diff.Details = compareOneTimePrices(product, pack.Amount)
+	return diff
+}
+
+// compareOneTimePrices returns the amounts of a product's active one-time
+// prices and whether they match amount
+func compareOneTimePrices(product *stripe.Product, amount int) ([]int64, Status, string) {
+	var amounts []int64
+	found := false
+	var stale []string
+	for _, p := range product.Prices {
+		if p.Interval != "" || !p.Active {
+			continue
+		}
+		amounts = append(amounts, p.Amount)
+		if p.Amount == int64(amount) {
+			found = true
+		} else {
+			stale = append(stale, strconv.FormatInt(p.Amount, 10))
+		}
+	}
+
+	var details []string
+	if !found {
+		details = append(details, fmt.Sprintf("price %d missing in Stripe", amount))
+	}
+	if len(stale) > 0 {
+		details = append(details, fmt.Sprintf("old price(s) still active: %s", strings.Join(stale, ", ")))
+	}
+
+	if len(details) > 0 {
+		return amounts, StatusDiffers, strings.Join(details, ", ")
+	}
+	return amounts, StatusOK, ""
+}
+
+// comparePrices compares the prices of a plan, or of one of its regions, with
+// the product's prices of that region. Regional prices also compare currency.
+func comparePrices(local map[string]config.Price, region, currency string, prices []stripe.ProductPrice) ([]PriceDiff, []string) {
 	var priceDiffs []PriceDiff
 	var differDetails []string
 
-	for interval, localPrice := range plan.Prices {
+	// Compare prices, in interval order so details read the same every run
+	intervals := make([]string, 0, len(local))
+	for interval := range local {
+		intervals = append(intervals, interval)
+	}
+	sort.Strings(intervals)
+
+	for _, interval := range intervals {
+		localPrice := local[interval]
 		priceDiff := PriceDiff{
 			Interval:    interval,
+			Region:      region,
 			LocalAmount: localPrice.Amount,
 		}
+		label := interval
+		if region != "" {
+			label = region + " " + interval
+		}
 
 		// Find matching Stripe price
-		stripePrice := findPrice(product.Prices, interval)
-		if stripePrice == nil {
+		stripePrice := findPrice(prices, region, interval)
+		switch {
+		case stripePrice == nil:
 			priceDiff.Status = StatusMissing
-			differDetails = append(differDetails, fmt.Sprintf("%s: missing in Stripe", interval))
-		} else {
+			differDetails = append(differDetails, fmt.Sprintf("%s: missing in Stripe", label))
+		case currency != "" && !strings.EqualFold(currency, stripePrice.Currency):
+			priceDiff.StripeAmount = stripePrice.Amount
+			priceDiff.Status = StatusDiffers
+			differDetails = append(differDetails, fmt.Sprintf("%s: local=%d %s stripe=%d %s", label,
+				localPrice.Amount, strings.ToLower(currency), stripePrice.Amount, stripePrice.Currency))
+		default:
 			priceDiff.StripeAmount = stripePrice.Amount
 			if int64(localPrice.Amount) == stripePrice.Amount {
 				priceDiff.Status = StatusOK
 			} else {
 				priceDiff.Status = StatusDiffers
-				differDetails = append(differDetails, fmt.Sprintf("%s: local=%d stripe=%d", interval, localPrice.Amount, stripePrice.Amount))
+				differDetails = append(differDetails, fmt.Sprintf("%s: local=%d stripe=%d", label, localPrice.Amount, stripePrice.Amount))
 			}
 		}
 
 		priceDiffs = append(priceDiffs, priceDiff)
 	}
-
-	diff.Prices = priceDiffs
-
-	if len(differDetails) > 0 {
-		diff.Status = StatusDiffers
-		diff.Details = strings.Join(differDetails, ", ")
-	} else {
-		diff.Status = StatusOK
-	}
-
-	return diff
+	return priceDiffs, differDetails
 }
 
-// findPrice finds a price by interval
-func findPrice(prices []stripe.ProductPrice, interval string) *stripe.ProductPrice {
+// findPrice finds an active price by region and interval; region is "" for a
+// plan's default prices
+func findPrice(prices []stripe.ProductPrice, region, interval string) *stripe.ProductPrice {
 	for i := range prices {
-		if prices[i].Interval == interval && prices[i].Active {
+		if prices[i].Region == region && prices[i].Interval == interval && prices[i].Active {
 			return &prices[i]
 		}
 	}
 	return nil
 }
 
+// Stabilize drops the comparison time and sorts plans, addons, credit packs
+// and meters by ID, so the result prints the same on every run
+func (r *DiffResult) Stabilize() {
+	r.ComparedAt = ""
+	sort.SliceStable(r.Plans, func(i, j int) bool { return r.Plans[i].PlanID < r.Plans[j].PlanID })
+	sort.SliceStable(r.Addons, func(i, j int) bool { return r.Addons[i].AddonID < r.Addons[j].AddonID })
+	sort.SliceStable(r.Credits, func(i, j int) bool { return r.Credits[i].CreditID < r.Credits[j].CreditID })
+	sort.SliceStable(r.Meters, func(i, j int) bool { return r.Meters[i].MeterKey < r.Meters[j].MeterKey })
+}
+
 // HasDifferences returns true if there are any differences
 func (r *DiffResult) HasDifferences() bool {
-	return r.Summary.Missing > 0 || r.Summary.Differs > 0
+	if r.CatalogVersion != nil && r.CatalogVersion.Status == StatusDiffers {
+		return true
+	}
+	return r.Summary.Missing > 0 || r.Summary.Differs > 0 ||
+		r.Summary.AddonsMissing > 0 || r.Summary.AddonsDiffer > 0 ||
+		r.Summary.CreditsMissing > 0 || r.Summary.CreditsDiffer > 0 ||
+		r.Summary.MetersMissing > 0 || r.Summary.MetersDiffer > 0
 }
@@ -10,7 +10,15 @@ import (
 // OutputTable writes the diff result as a formatted table
 func OutputTable(w io.Writer, result *DiffResult) {
 	fmt.Fprintf(w, "Environment: %s\n", result.Environment)
-	fmt.Fprintf(w, "Compared at: %s\n", result.ComparedAt)
+	if result.ComparedAt != "" {
+		fmt.Fprintf(w, "Compared at: %s\n", result.ComparedAt)
+	}
+	if v := result.CatalogVersion; v != nil {
+		fmt.Fprintf(w, "Catalog version: local %s, Stripe %s %s\n", v.Local, strings.Join(v.Stripe, ", "), formatStatus(v.Status))
+		if v.Status == StatusDiffers {
+			fmt.Fprintln(w, "  Stripe was last applied from a different config; run apply to bring it up to date")
+		}
+	}
 	fmt.Fprintln(w)
 
 	// Header
@@ -27,14 +35,69 @@ func OutputTable(w io.Writer, result *DiffResult) {
 		fmt.Fprintln(w)
 	}
 
+	// Addons
+	if len(result.Addons) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%-20s %10s  %s\n", "ADDON", "STATUS", "DETAILS")
+		fmt.Fprintln(w, strings.Repeat("-", 60))
+		for _, addon := range result.Addons {
+			fmt.Fprintf(w, "%-20s %10s", addon.AddonID, formatStatus(addon.Status))
+			if addon.Details != "" {
+				fmt.Fprintf(w, "  %s", addon.Details)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	// Credit packs
+	if len(result.Credits) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%-20s %10s  %s\n", "CREDIT PACK", "STATUS", "DETAILS")
+		fmt.Fprintln(w, strings.Repeat("-", 60))
+		for _, pack := range result.Credits {
+			fmt.Fprintf(w, "%-20s %10s", pack.CreditID, formatStatus(pack.Status))
+			if pack.Details != "" {
+				fmt.Fprintf(w, "  %s", pack.Details)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
+	// Meters
+	if len(result.Meters) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "%-20s %10s  %s\n", "METER", "STATUS", "DETAILS")
+		fmt.Fprintln(w, strings.Repeat("-", 60))
+		for _, meter := range result.Meters {
+			fmt.Fprintf(w, "%-20s %10s", meter.MeterKey, formatStatus(meter.Status))
+			if meter.Details != "" {
+				fmt.Fprintf(w, "  %s", meter.Details)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+
 	// Summary
 	fmt.Fprintln(w)
-	fmt.Fprintf(w, "Summary: %d total, %d synced, %d missing, %d differs\n",
+	fmt.Fprintf(w, "Summary: %d total, %d synced, %d missing, %d differs",
 		result.Summary.Total,
 		result.Summary.Synced,
 		result.Summary.Missing,
 		result.Summary.Differs,
 	)
+	if result.Summary.Skipped > 0 {
+		fmt.Fprintf(w, ", %d skipped", result.Summary.Skipped)
+	}
+	if n := result.Summary.AddonsMissing + result.Summary.AddonsDiffer; n > 0 {
+		fmt.Fprintf(w, "; addons: %d missing, %d differs", result.Summary.AddonsMissing, result.Summary.AddonsDiffer)
+	}
+	if n := result.Summary.CreditsMissing + result.Summary.CreditsDiffer; n > 0 {
+		fmt.Fprintf(w, "; credit packs: %d missing, %d differs", result.Summary.CreditsMissing, result.Summary.CreditsDiffer)
+	}
+	if n := result.Summary.MetersMissing + result.Summary.MetersDiffer; n > 0 {
+		fmt.Fprintf(w, "; meters: %d missing, %d differs", result.Summary.MetersMissing, result.Summary.MetersDiffer)
+	}
+	fmt.Fprintln(w)
 }
 
 // OutputJSON writes the diff result as JSON
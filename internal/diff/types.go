@@ -7,31 +7,76 @@ const (
 	StatusOK      Status = "OK"
 	StatusDiffers Status = "DIFFERS"
 	StatusMissing Status = "MISSING"
+	StatusSkipped Status = "SKIPPED" // plan is not synced to Stripe by design
 )
 
 // DiffResult contains the comparison results
 type DiffResult struct {
-	Environment string     `json:"environment"`
-	ComparedAt  string     `json:"compared_at"`
-	Plans       []PlanDiff `json:"plans"`
-	Summary     Summary    `json:"summary"`
+	Environment string       `json:"environment"`
+	ComparedAt  string       `json:"compared_at,omitempty"` // empty with --deterministic
+	Plans       []PlanDiff   `json:"plans"`
+	Addons      []AddonDiff  `json:"addons,omitempty"`
+	Credits     []CreditDiff `json:"credits,omitempty"`
+	Meters      []MeterDiff  `json:"meters,omitempty"`
+	Summary     Summary      `json:"summary"`
+
+	CatalogVersion *CatalogVersionDiff `json:"catalog_version,omitempty"` // unset when no product is stamped yet
+}
+
+// CatalogVersionDiff compares the local config with the catalog_version stamped
+// on Stripe products by the last apply
+type CatalogVersionDiff struct {
+	Local  string   `json:"local"`  // hash of the local config
+	Stripe []string `json:"stripe"` // distinct versions stamped on plan products
+	Status Status   `json:"status"`
 }
 
 // PlanDiff represents the diff for a single plan
 type PlanDiff struct {
-	PlanID   string       `json:"plan_id"`
-	PlanName string       `json:"plan_name"`
-	Status   Status       `json:"status"`
-	Details  string       `json:"details,omitempty"`
-	Prices   []PriceDiff  `json:"prices,omitempty"`
+	PlanID      string      `json:"plan_id"`
+	PlanName    string      `json:"plan_name"`
+	Status      Status      `json:"status"`
+	Details     string      `json:"details,omitempty"`
+	RenamedFrom string      `json:"renamed_from,omitempty"` // old plan ID the Stripe product is still tagged with
+	Prices      []PriceDiff `json:"prices,omitempty"`
+}
+
+// AddonDiff represents the diff for a single addon and its one-time price
+type AddonDiff struct {
+	AddonID      string  `json:"addon_id"`
+	AddonName    string  `json:"addon_name"`
+	Status       Status  `json:"status"`
+	Details      string  `json:"details,omitempty"`
+	LocalAmount  int     `json:"local_amount"`
+	StripeAmount []int64 `json:"stripe_amount,omitempty"` // every active one-time price
+}
+
+// CreditDiff represents the diff for a single credit pack and its one-time price
+type CreditDiff struct {
+	CreditID     string  `json:"credit_id"`
+	CreditName   string  `json:"credit_name"`
+	Status       Status  `json:"status"`
+	Details      string  `json:"details,omitempty"`
+	LocalAmount  int     `json:"local_amount"`
+	StripeAmount []int64 `json:"stripe_amount,omitempty"` // every active one-time price
+}
+
+// MeterDiff represents the diff for a single usage meter
+type MeterDiff struct {
+	MeterKey  string `json:"meter_key"`
+	EventName string `json:"event_name"`
+	MeterID   string `json:"meter_id,omitempty"`
+	Status    Status `json:"status"`
+	Details   string `json:"details,omitempty"`
 }
 
 // PriceDiff represents the diff for a single price
 type PriceDiff struct {
-	Interval    string `json:"interval"`
-	LocalAmount int    `json:"local_amount"`
+	Interval     string `json:"interval"`
+	Region       string `json:"region,omitempty"`
+	LocalAmount  int    `json:"local_amount"`
 	StripeAmount int64  `json:"stripe_amount,omitempty"`
-	Status      Status `json:"status"`
+	Status       Status `json:"status"`
 }
 
 // Summary contains the summary statistics
@@ -40,4 +85,14 @@ type Summary struct {
 	Synced  int `json:"synced"`
 	Missing int `json:"missing"`
 	Differs int `json:"differs"`
+	Skipped int `json:"skipped"`
+
+	AddonsMissing int `json:"addons_missing"`
+	AddonsDiffer  int `json:"addons_differ"`
+
+	CreditsMissing int `json:"credits_missing"`
+	CreditsDiffer  int `json:"credits_differ"`
+
+	MetersMissing int `json:"meters_missing"`
+	MetersDiffer  int `json:"meters_differ"`
 }
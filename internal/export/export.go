@@ -0,0 +1,331 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"raterunner/internal/config"
+)
+
+// UnlimitedStyle controls how the unlimited sentinel is written in exports
+type UnlimitedStyle string
+
+const (
+	UnlimitedNull     UnlimitedStyle = "null" // "seats": null
+	UnlimitedNegative UnlimitedStyle = "-1"   // "seats": -1
+)
+
+// ParseUnlimitedStyle validates an --unlimited flag value
+func ParseUnlimitedStyle(s string) (UnlimitedStyle, error) {
+	switch UnlimitedStyle(s) {
+	case UnlimitedNull, UnlimitedNegative:
+		return UnlimitedStyle(s), nil
+	case "":
+		return UnlimitedNull, nil
+	}
+	return "", fmt.Errorf("invalid unlimited style: %s (use 'null' or '-1')", s)
+}
+
+// Options configures an export
+type Options struct {
+	Unlimited  UnlimitedStyle
+	Provider   *config.ProviderConfig // embeds its Stripe IDs when set
+	PublicOnly bool                   // leaves out plans with public: false
+	Region     string                 // uses this region's prices for plans that declare it
+}
+
+// StripeIDs are the Stripe objects behind an exported plan, addon, credit pack,
+// promotion or meter, taken from a provider file
+type StripeIDs struct {
+	Environment string            `json:"environment"`
+	ProductID   string            `json:"product_id,omitempty"`
+	PriceIDs    map[string]string `json:"price_ids,omitempty"` // interval -> price_id, for plans
+	PriceID     string            `json:"price_id,omitempty"`  // for addons and credit packs
+	CouponID    string            `json:"coupon_id,omitempty"` // for promotions
+	MeterID     string            `json:"meter_id,omitempty"`  // for meters
+
+	// region -> interval -> price_id, for plans with regions
+	RegionPriceIDs map[string]map[string]string `json:"region_price_ids,omitempty"`
+}
+
+// Bundle is the pricing and entitlements export consumed by frontends and backends
+type Bundle struct {
+	Version      int                           `json:"version"`
+	GraceDays    int                           `json:"grace_days,omitempty"` // days of access kept after a failed payment
+	Dunning      *config.Dunning               `json:"dunning,omitempty"`    // how failed payments are retried
+	Invoice      *config.Invoice               `json:"invoice,omitempty"`    // invoice footer, custom fields and payment term
+	Region       string                        `json:"region,omitempty"`     // set when exported with Options.Region
+	Entitlements map[string]config.Entitlement `json:"entitlements"`
+	Plans        []Plan                        `json:"plans"`
+	Addons       []Addon                       `json:"addons,omitempty"`
+	Credits      []CreditPack                  `json:"credits,omitempty"`
+	Meters       []Meter                       `json:"meters,omitempty"`
+	Promotions   []Promotion                   `json:"promotions,omitempty"`
+}
+
+// Promotion is the exported form of an active promotion, including its stacking rules
+type Promotion struct {
+	Code             string                   `json:"code"`
+	Description      string                   `json:"description,omitempty"`
+	Discount         config.PromotionDiscount `json:"discount"`
+	AppliesTo        []string                 `json:"applies_to,omitempty"`
+	NewCustomersOnly bool                     `json:"new_customers_only,omitempty"`
+	StartsAt         string                   `json:"starts_at,omitempty"`
+	Expires          string                   `json:"expires,omitempty"`
+	Stackable        bool                     `json:"stackable"`
+	Excludes         []string                 `json:"excludes,omitempty"`
+	Stripe           *StripeIDs               `json:"stripe,omitempty"`
+}
+
+// Addon is the exported form of an addon, with grants parsed into {op, value}
+type Addon struct {
+	ID     string                  `json:"id"`
+	Name   string                  `json:"name"`
+	Price  config.Price            `json:"price"`
+	Grants map[string]config.Grant `json:"grants"`
+	Stripe *StripeIDs              `json:"stripe,omitempty"`
+}
+
+// CreditPack is the exported form of a credit pack
+type CreditPack struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Entitlement string     `json:"entitlement"`
+	Credits     int        `json:"credits"`
+	Amount      int        `json:"amount"`
+	ExpiresDays int        `json:"expires_days,omitempty"`
+	Stripe      *StripeIDs `json:"stripe,omitempty"`
+}
+
+// Meter is the exported form of a usage meter: backends report usage to it
+// under its event name
+type Meter struct {
+	Key         string     `json:"key"`
+	DisplayName string     `json:"display_name"`
+	EventName   string     `json:"event_name"`
+	Aggregation string     `json:"aggregation"`
+	Stripe      *StripeIDs `json:"stripe,omitempty"`
+}
+
+// Plan is the exported form of a plan
+type Plan struct {
+	ID               string                   `json:"id"`
+	Name             string                   `json:"name"`
+	Description      string                   `json:"description,omitempty"`
+	Headline         string                   `json:"headline,omitempty"`
+	Type             string                   `json:"type,omitempty"`
+	Public           bool                     `json:"public"`
+	Default          bool                     `json:"default"`
+	ContactSales     bool                     `json:"contact_sales,omitempty"` // pricing: custom, no fixed price
+	TrialDays        int                      `json:"trial_days,omitempty"`
+	Trial            *config.Trial            `json:"trial,omitempty"`
+	Prices           map[string]config.Price  `json:"prices"`
+	Currency         string                   `json:"currency,omitempty"` // of prices, when taken from a region
+	Regions          map[string]config.Region `json:"regions,omitempty"`  // left out when exporting one region
+	Limits           map[string]any           `json:"limits"`
+	Features         []string                 `json:"features,omitempty"`
+	UpgradesTo       []string                 `json:"upgrades_to,omitempty"`
+	DowngradesTo     []string                 `json:"downgrades_to,omitempty"`
+	DowngradePolicy  string                   `json:"downgrade_policy,omitempty"`
+	Invoicing        string                   `json:"invoicing,omitempty"`         // manual; omitted for self-serve plans
+	CollectionMethod string                   `json:"collection_method,omitempty"` // send_invoice; omitted for plans charged automatically
+	DaysUntilDue     int                      `json:"days_until_due,omitempty"`    // payment term of send_invoice plans
+	Provisioning     map[string]any           `json:"provisioning,omitempty"`
+	Stripe           *StripeIDs               `json:"stripe,omitempty"`
+}
+
+// Build converts a billing config into an export bundle
+func Build(cfg *config.BillingConfig, opts Options) (*Bundle, error) {
+	bundle := &Bundle{
+		Version:      cfg.Version,
+		GraceDays:    cfg.GraceDays(),
+		Dunning:      cfg.Dunning(),
+		Invoice:      cfg.Invoice(),
+		Region:       opts.Region,
+		Entitlements: cfg.Entitlements,
+		Plans:        make([]Plan, 0, len(cfg.Plans)),
+	}
+	if bundle.Entitlements == nil {
+		bundle.Entitlements = map[string]config.Entitlement{}
+	}
+
+	if opts.Region != "" && !hasRegion(cfg, opts.Region) {
+		return nil, fmt.Errorf("no plan has region '%s'", opts.Region)
+	}
+
+	for _, p := range cfg.Plans {
+		if opts.PublicOnly && !p.IsPublic() {
+			continue
+		}
+		plan := Plan{
+			ID:              p.ID,
+			Name:            p.Name,
+			Description:     p.Description,
+			Headline:        p.Headline,
+			Type:            p.Type,
+			Public:          p.IsPublic(),
+			Default:         p.Default,
+			TrialDays:       p.TrialDays,
+			Prices:          p.Prices,
+			Regions:         p.Regions,
+			Limits:          make(map[string]any, len(p.Limits)),
+			Features:        p.Features,
+			UpgradesTo:      p.UpgradesTo,
+			DowngradesTo:    p.DowngradesTo,
+			DowngradePolicy: p.DowngradePolicy,
+			Provisioning:    p.Provisioning,
+		}
+		if p.IsCustomPricing() {
+			plan.ContactSales = true
+			plan.Prices = map[string]config.Price{}
+		}
+		if p.TrialDays > 0 {
+			plan.Trial = cfg.TrialFor(p)
+		}
+		if p.IsManualInvoicing() {
+			plan.Invoicing = config.InvoicingManual
+		}
+		if p.SendsInvoice() {
+			plan.CollectionMethod = config.CollectionSendInvoice
+			plan.DaysUntilDue = cfg.DaysUntilDue()
+		}
+		for key, value := range p.Limits {
+			plan.Limits[key] = exportLimit(value, opts.Unlimited)
+		}
+		if opts.Provider != nil {
+			if ids, ok := opts.Provider.Plans[p.ID]; ok {
+				plan.Stripe = &StripeIDs{Environment: opts.Provider.Environment, ProductID: ids.ProductID, PriceIDs: ids.Prices, RegionPriceIDs: ids.Regions}
+			}
+		}
+		if opts.Region != "" {
+			applyRegion(&plan, p, opts.Region)
+		}
+		bundle.Plans = append(bundle.Plans, plan)
+	}
+
+	for _, a := range cfg.Addons {
+		grants, err := a.ParsedGrants()
+		if err != nil {
+			return nil, err
+		}
+		addon := Addon{
+			ID:     a.ID,
+			Name:   a.Name,
+			Price:  a.Price,
+			Grants: grants,
+		}
+		if opts.Provider != nil {
+			if ids, ok := opts.Provider.Addons[a.ID]; ok {
+				addon.Stripe = &StripeIDs{Environment: opts.Provider.Environment, ProductID: ids.ProductID, PriceID: ids.PriceID}
+			}
+		}
+		bundle.Addons = append(bundle.Addons, addon)
+	}
+
+	for _, c := range cfg.Credits {
+		pack := CreditPack{
+			ID:          c.ID,
+			Name:        c.Name,
+			Description: c.Description,
+			Entitlement: c.Entitlement,
+			Credits:     c.Credits,
+			Amount:      c.Amount,
+			ExpiresDays: c.ExpiresDays,
+		}
+		if opts.Provider != nil {
+			if ids, ok := opts.Provider.Credits[c.ID]; ok {
+				pack.Stripe = &StripeIDs{Environment: opts.Provider.Environment, ProductID: ids.ProductID, PriceID: ids.PriceID}
+			}
+		}
+		bundle.Credits = append(bundle.Credits, pack)
+	}
+
+	for _, key := range cfg.MeterKeys() {
+		m := cfg.Meters[key]
+		meter := Meter{
+			Key:         key,
+			DisplayName: m.DisplayName,
+			EventName:   m.EventName,
+			Aggregation: m.AggregationOrDefault(),
+		}
+		if opts.Provider != nil {
+			if meterID, ok := opts.Provider.Meters[key]; ok {
+				meter.Stripe = &StripeIDs{Environment: opts.Provider.Environment, MeterID: meterID}
+			}
+		}
+		bundle.Meters = append(bundle.Meters, meter)
+	}
+
+	for _, p := range cfg.Promotions {
+		if !p.IsActive() {
+			continue
+		}
+		promo := Promotion{
+			Code:             p.Code,
+			Description:      p.Description,
+			Discount:         p.Discount,
+			AppliesTo:        p.AppliesTo,
+			NewCustomersOnly: p.NewCustomersOnly,
+			StartsAt:         p.StartsAt,
+			Expires:          p.Expires,
+			Stackable:        p.IsStackable(),
+			Excludes:         p.Excludes,
+		}
+		if opts.Provider != nil {
+			if couponID, ok := opts.Provider.Promotions[p.Code]; ok {
+				promo.Stripe = &StripeIDs{Environment: opts.Provider.Environment, CouponID: couponID}
+			}
+		}
+		bundle.Promotions = append(bundle.Promotions, promo)
+	}
+
+	return bundle, nil
+}
+
+// hasRegion reports whether any plan declares the region
+func hasRegion(cfg *config.BillingConfig, region string) bool {
+	for _, p := range cfg.Plans {
+		if _, ok := p.Regions[region]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRegion replaces a plan's prices and price IDs with those of region.
+// Plans without the region keep their default prices.
+func applyRegion(plan *Plan, p config.Plan, region string) {
+	plan.Regions = nil
+	r, ok := p.Regions[region]
+	if plan.Stripe != nil {
+		if ok {
+			plan.Stripe.PriceIDs = plan.Stripe.RegionPriceIDs[region]
+		}
+		plan.Stripe.RegionPriceIDs = nil
+	}
+	if !ok || p.IsCustomPricing() {
+		return
+	}
+	plan.Prices = r.Prices
+	plan.Currency = r.Currency
+}
+
+// exportLimit converts a normalized limit value into its export form
+func exportLimit(v any, style UnlimitedStyle) any {
+	if config.IsUnlimited(v) {
+		if style == UnlimitedNegative {
+			return -1
+		}
+		return nil
+	}
+	return v
+}
+
+// WriteJSON writes the bundle as indented JSON
+func WriteJSON(w io.Writer, bundle *Bundle) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(bundle)
+}
@@ -0,0 +1,128 @@
+package flags
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"raterunner/internal/config"
+)
+
+// Flag describes the targeting for a single bool entitlement
+type Flag struct {
+	Key          string   `json:"key"`
+	Description  string   `json:"description,omitempty"`
+	EnabledPlans []string `json:"enabled_plans"`
+}
+
+// Provider pushes flag targeting to a feature flag system
+type Provider interface {
+	// Name returns the provider name for output (e.g. "launchdarkly")
+	Name() string
+	// SyncFlag creates the flag if needed and targets it to the enabled plans
+	SyncFlag(flag Flag) error
+}
+
+// SyncResult contains the results of a flag sync
+type SyncResult struct {
+	FlagsSynced int
+}
+
+// BuildFlags collects bool entitlements and the plans that enable them.
+// Flags are sorted by key, plans keep their config order.
+func BuildFlags(cfg *config.BillingConfig) []Flag {
+	var flags []Flag
+
+	for key, ent := range cfg.Entitlements {
+		if ent.Type != "bool" {
+			continue
+		}
+
+		flag := Flag{
+			Key:          key,
+			Description:  ent.Description,
+			EnabledPlans: []string{},
+		}
+		for _, plan := range cfg.Plans {
+			if enabled, ok := plan.Limits[key].(bool); ok && enabled {
+				flag.EnabledPlans = append(flag.EnabledPlans, plan.ID)
+			}
+		}
+		flags = append(flags, flag)
+	}
+
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Key < flags[j].Key
+	})
+
+	return flags
+}
+
+// Sync pushes all flags to the provider, stopping at the first error
+func Sync(p Provider, flags []Flag) (*SyncResult, error) {
+	result := &SyncResult{}
+
+	for _, f := range flags {
+		if err := p.SyncFlag(f); err != nil {
+			return result, fmt.Errorf("failed to sync flag '%s' to %s: %w", f.Key, p.Name(), err)
+		}
+		result.FlagsSynced++
+	}
+
+	return result, nil
+}
+
+// httpClient is shared by all adapters
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// errNotFound is returned by doJSON for 404 responses
+var errNotFound = errors.New("not found")
+
+// doJSON sends a JSON request and decodes the JSON response into out (if non-nil)
+func doJSON(method, url, token, contentType string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: HTTP %d: %s", method, url, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultLaunchDarklyURL is the LaunchDarkly REST API base URL
+const DefaultLaunchDarklyURL = "https://app.launchdarkly.com/api/v2"
+
+// LaunchDarkly syncs flags to a LaunchDarkly project.
+// Plans are targeted with a rule on the "plan" context attribute:
+// variation 0 (true) for enabled plans, fallthrough to variation 1 (false).
+type LaunchDarkly struct {
+	BaseURL     string
+	Token       string
+	Project     string
+	Environment string
+}
+
+// NewLaunchDarkly creates a LaunchDarkly adapter
+func NewLaunchDarkly(token, project, environment string) (*LaunchDarkly, error) {
+	if token == "" {
+		return nil, fmt.Errorf("LaunchDarkly API token is empty")
+	}
+	if project == "" || environment == "" {
+		return nil, fmt.Errorf("LaunchDarkly project and environment are required")
+	}
+	return &LaunchDarkly{
+		BaseURL:     DefaultLaunchDarklyURL,
+		Token:       token,
+		Project:     project,
+		Environment: environment,
+	}, nil
+}
+
+// Name returns the provider name
+func (l *LaunchDarkly) Name() string {
+	return "launchdarkly"
+}
+
+// SyncFlag creates the boolean flag if missing and replaces its targeting rules
+func (l *LaunchDarkly) SyncFlag(flag Flag) error {
+	flagURL := fmt.Sprintf("%s/flags/%s/%s", strings.TrimSuffix(l.BaseURL, "/"), l.Project, flag.Key)
+
+	err := doJSON("GET", flagURL, l.Token, "", nil, nil)
+	if errors.Is(err, errNotFound) {
+		create := map[string]any{
+			"key":         flag.Key,
+			"name":        flag.Key,
+			"description": flag.Description,
+			"variations": []map[string]any{
+				{"value": true},
+				{"value": false},
+			},
+			"tags": []string{"raterunner"},
+		}
+		createURL := fmt.Sprintf("%s/flags/%s", strings.TrimSuffix(l.BaseURL, "/"), l.Project)
+		if err := doJSON("POST", createURL, l.Token, "application/json", create, nil); err != nil {
+			return fmt.Errorf("failed to create flag: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get flag: %w", err)
+	}
+
+	rules := []map[string]any{}
+	if len(flag.EnabledPlans) > 0 {
+		rules = append(rules, map[string]any{
+			"variation": 0,
+			"clauses": []map[string]any{
+				{
+					"attribute": "plan",
+					"op":        "in",
+					"values":    flag.EnabledPlans,
+					"negate":    false,
+				},
+			},
+		})
+	}
+
+	envPath := "/environments/" + l.Environment
+	patch := map[string]any{
+		"comment": "Synced from billing config by raterunner",
+		"patch": []map[string]any{
+			{"op": "replace", "path": envPath + "/on", "value": true},
+			{"op": "replace", "path": envPath + "/rules", "value": rules},
+			{"op": "replace", "path": envPath + "/fallthrough/variation", "value": 1},
+		},
+	}
+	if err := doJSON("PATCH", flagURL, l.Token, "application/json", patch, nil); err != nil {
+		return fmt.Errorf("failed to update targeting: %w", err)
+	}
+
+	return nil
+}
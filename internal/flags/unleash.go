@@ -0,0 +1,125 @@
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Unleash syncs flags to an Unleash project via the Admin API.
+// Each flag gets a single flexibleRollout strategy constrained to
+// the "plan" context field; existing strategies are replaced.
+type Unleash struct {
+	BaseURL     string
+	Token       string
+	Project     string
+	Environment string
+}
+
+// unleashStrategy is the strategy payload used by the Admin API
+type unleashStrategy struct {
+	ID          string              `json:"id,omitempty"`
+	Name        string              `json:"name"`
+	Parameters  map[string]string   `json:"parameters"`
+	Constraints []unleashConstraint `json:"constraints"`
+}
+
+type unleashConstraint struct {
+	ContextName string   `json:"contextName"`
+	Operator    string   `json:"operator"`
+	Values      []string `json:"values"`
+}
+
+// NewUnleash creates an Unleash adapter
+func NewUnleash(baseURL, token, project, environment string) (*Unleash, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("Unleash URL is empty")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("Unleash API token is empty")
+	}
+	if project == "" {
+		project = "default"
+	}
+	if environment == "" {
+		return nil, fmt.Errorf("Unleash environment is required")
+	}
+	return &Unleash{
+		BaseURL:     strings.TrimSuffix(baseURL, "/"),
+		Token:       token,
+		Project:     project,
+		Environment: environment,
+	}, nil
+}
+
+// Name returns the provider name
+func (u *Unleash) Name() string {
+	return "unleash"
+}
+
+// SyncFlag creates the feature if missing, sets its plan strategy, and toggles the environment
+func (u *Unleash) SyncFlag(flag Flag) error {
+	featuresURL := fmt.Sprintf("%s/api/admin/projects/%s/features", u.BaseURL, u.Project)
+	featureURL := featuresURL + "/" + flag.Key
+
+	err := doJSON("GET", featureURL, u.Token, "", nil, nil)
+	if errors.Is(err, errNotFound) {
+		create := map[string]any{
+			"name":        flag.Key,
+			"description": flag.Description,
+			"type":        "permission",
+		}
+		if err := doJSON("POST", featuresURL, u.Token, "application/json", create, nil); err != nil {
+			return fmt.Errorf("failed to create feature: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to get feature: %w", err)
+	}
+
+	envURL := fmt.Sprintf("%s/environments/%s", featureURL, u.Environment)
+
+	if len(flag.EnabledPlans) == 0 {
+		if err := doJSON("POST", envURL+"/off", u.Token, "", nil, nil); err != nil {
+			return fmt.Errorf("failed to disable feature: %w", err)
+		}
+		return nil
+	}
+
+	strategy := unleashStrategy{
+		Name: "flexibleRollout",
+		Parameters: map[string]string{
+			"rollout":    "100",
+			"stickiness": "default",
+			"groupId":    flag.Key,
+		},
+		Constraints: []unleashConstraint{
+			{ContextName: "plan", Operator: "IN", Values: flag.EnabledPlans},
+		},
+	}
+
+	var existing []unleashStrategy
+	if err := doJSON("GET", envURL+"/strategies", u.Token, "", nil, &existing); err != nil {
+		return fmt.Errorf("failed to list strategies: %w", err)
+	}
+
+	if len(existing) > 0 {
+		err = doJSON("PUT", envURL+"/strategies/"+existing[0].ID, u.Token, "application/json", strategy, nil)
+	} else {
+		err = doJSON("POST", envURL+"/strategies", u.Token, "application/json", strategy, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set strategy: %w", err)
+	}
+	// Any other strategy could still enable the flag for plans outside EnabledPlans
+	for _, s := range existing[min(len(existing), 1):] {
+		if err := doJSON("DELETE", envURL+"/strategies/"+s.ID, u.Token, "", nil, nil); err != nil {
+			return fmt.Errorf("failed to delete strategy %s: %w", s.ID, err)
+		}
+	}
+
+	if err := doJSON("POST", envURL+"/on", u.Token, "", nil, nil); err != nil {
+		return fmt.Errorf("failed to enable feature: %w", err)
+	}
+
+	return nil
+}
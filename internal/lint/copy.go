@@ -0,0 +1,173 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"raterunner/internal/config"
+)
+
+// copyText is a customer-facing string in a billing config
+type copyText struct {
+	path   string
+	planID string // empty for addons
+	text   string
+}
+
+// customerCopy returns the plan names, descriptions, headlines and features and
+// the addon names, in file order
+func customerCopy(cfg *config.BillingConfig) []copyText {
+	var texts []copyText
+	for i, plan := range cfg.Plans {
+		for _, field := range []struct{ name, text string }{
+			{"name", plan.Name}, {"description", plan.Description}, {"headline", plan.Headline},
+		} {
+			if field.text != "" {
+				texts = append(texts, copyText{fmt.Sprintf("/plans/%d/%s", i, field.name), plan.ID, field.text})
+			}
+		}
+		for j, feature := range plan.Features {
+			texts = append(texts, copyText{fmt.Sprintf("/plans/%d/features/%d", i, j), plan.ID, feature})
+		}
+	}
+	for i, addon := range cfg.Addons {
+		if addon.Name != "" {
+			texts = append(texts, copyText{fmt.Sprintf("/addons/%d/name", i), "", addon.Name})
+		}
+	}
+	return texts
+}
+
+// checkPlanNaming flags "<plan name> plan" phrases written with different
+// casing than the rest of the copy, e.g. "Pro plan" next to "Pro Plan"
+func checkPlanNaming(cfg *config.BillingConfig, _ Options) []Finding {
+	texts := customerCopy(cfg)
+
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, plan := range cfg.Plans {
+		name := strings.TrimSpace(plan.Name)
+		if name == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		phrase := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(name) + `\s+plans?\b`)
+
+		type mention struct {
+			text  copyText
+			match string
+		}
+		var mentions []mention
+		counts := make(map[string]int)
+		for _, t := range texts {
+			for _, m := range phrase.FindAllString(t.text, -1) {
+				// "Pro plan" and "Pro plans" are the same spelling
+				m = strings.TrimSuffix(strings.TrimSuffix(m, "s"), "S")
+				mentions = append(mentions, mention{t, m})
+				counts[m]++
+			}
+		}
+		if len(counts) < 2 {
+			continue
+		}
+
+		// The most common spelling wins; ties go to the one written first
+		canonical := ""
+		for _, m := range mentions {
+			if counts[m.match] > counts[canonical] {
+				canonical = m.match
+			}
+		}
+		for _, m := range mentions {
+			if m.match != canonical {
+				findings = append(findings, Finding{
+					Rule:    "plan-naming",
+					Path:    m.text.path,
+					Message: fmt.Sprintf("%q writes %q but the rest of the copy writes %q", m.text.text, m.match, canonical),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkFeatureCurrency(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		for j, feature := range plan.Features {
+			for _, r := range feature {
+				if unicode.Is(unicode.Sc, r) {
+					findings = append(findings, Finding{
+						Rule:    "feature-currency",
+						Path:    fmt.Sprintf("/plans/%d/features/%d", i, j),
+						Message: fmt.Sprintf("plan '%s' feature %q contains the currency symbol %c; amounts in features go stale and aren't converted for other currencies", plan.ID, feature, r),
+					})
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// checkFeatureLimitClaims flags features like "50 projects" or "Unlimited
+// projects" on plans whose int limit for that entitlement says otherwise. A
+// claim names the entitlement by its key, with underscores as spaces.
+func checkFeatureLimitClaims(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		if len(plan.Features) == 0 {
+			continue
+		}
+		for _, key := range sortedKeys(plan.Limits) {
+			if ent, ok := cfg.Entitlements[key]; !ok || ent.Type != "int" {
+				continue
+			}
+			limit := plan.Limits[key]
+			unlimited := config.IsUnlimited(limit)
+			value, isNumber := limitNumber(limit)
+			if !unlimited && !isNumber {
+				continue
+			}
+
+			noun := strings.ReplaceAll(key, "_", " ")
+			claim := regexp.MustCompile(`(?i)\b(\d[\d,]*|unlimited)\s+` + regexp.QuoteMeta(strings.TrimSuffix(noun, "s")) + `s?\b`)
+			for j, feature := range plan.Features {
+				for _, m := range claim.FindAllStringSubmatch(feature, -1) {
+					if strings.EqualFold(m[1], config.UnlimitedKeyword) {
+						if unlimited {
+							continue
+						}
+					} else if claimed, err := strconv.Atoi(strings.ReplaceAll(m[1], ",", "")); err != nil || (isNumber && claimed == value) {
+						continue
+					}
+					actual := config.UnlimitedKeyword
+					if isNumber {
+						actual = strconv.Itoa(value)
+					}
+					findings = append(findings, Finding{
+						Rule:    "feature-limit-claims",
+						Path:    fmt.Sprintf("/plans/%d/features/%d", i, j),
+						Message: fmt.Sprintf("plan '%s' feature %q claims %s %s but the limit is %s", plan.ID, feature, m[1], noun, actual),
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// limitNumber returns an int limit value, which decodes as int from YAML and
+// float64 from JSON
+func limitNumber(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
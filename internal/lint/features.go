@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"raterunner/internal/config"
+)
+
+// maxFeatureLength is Stripe's limit on a marketing feature name
+const maxFeatureLength = 80
+
+// Trailing period policies for the feature-trailing-period rule
+const (
+	PeriodsOmit    = "omit"    // features never end with a period (default)
+	PeriodsRequire = "require" // every feature ends with a period
+)
+
+// htmlPattern matches tags like <b> or </br> and entities like &amp; or &#169;
+var htmlPattern = regexp.MustCompile(`</?[a-zA-Z][^<>]*>|&(#[0-9]+|#x[0-9a-fA-F]+|[a-zA-Z]+);`)
+
+func checkFeatureLength(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		for j, feature := range plan.Features {
+			if n := utf8.RuneCountInString(feature); n > maxFeatureLength {
+				findings = append(findings, Finding{
+					Rule:    "feature-length",
+					Path:    fmt.Sprintf("/plans/%d/features/%d", i, j),
+					Message: fmt.Sprintf("plan '%s' feature is %d characters; Stripe marketing features allow at most %d", plan.ID, n, maxFeatureLength),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkFeatureHTML(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		for j, feature := range plan.Features {
+			if match := htmlPattern.FindString(feature); match != "" {
+				findings = append(findings, Finding{
+					Rule:    "feature-html",
+					Path:    fmt.Sprintf("/plans/%d/features/%d", i, j),
+					Message: fmt.Sprintf("plan '%s' feature %q contains HTML (%s); features are shown as plain text in Stripe and read verbatim by screen readers", plan.ID, feature, match),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkFeatureCapitalization warns about features whose first letter is cased
+// differently from most features across all plans
+func checkFeatureCapitalization(cfg *config.BillingConfig, _ Options) []Finding {
+	upper, lower := 0, 0
+	for _, plan := range cfg.Plans {
+		for _, feature := range plan.Features {
+			switch firstLetterCase(feature) {
+			case 'U':
+				upper++
+			case 'l':
+				lower++
+			}
+		}
+	}
+	// Ties go to sentence case, the usual style on pricing pages
+	want, wantName := 'U', "uppercase"
+	if lower > upper {
+		want, wantName = 'l', "lowercase"
+	}
+
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		for j, feature := range plan.Features {
+			if c := firstLetterCase(feature); c != 0 && c != want {
+				findings = append(findings, Finding{
+					Rule:    "feature-capitalization",
+					Path:    fmt.Sprintf("/plans/%d/features/%d", i, j),
+					Message: fmt.Sprintf("plan '%s' feature %q doesn't start %s like the other features", plan.ID, feature, wantName),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// firstLetterCase returns 'U' or 'l' for a feature starting with an upper or
+// lowercase letter, and 0 when it starts with anything else (e.g. "5 seats")
+func firstLetterCase(feature string) rune {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(feature))
+	switch {
+	case unicode.IsUpper(r):
+		return 'U'
+	case unicode.IsLower(r):
+		return 'l'
+	}
+	return 0
+}
+
+func checkFeatureTrailingPeriod(cfg *config.BillingConfig, opts Options) []Finding {
+	require := opts.FeaturePeriods == PeriodsRequire
+
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		for j, feature := range plan.Features {
+			trimmed := strings.TrimSpace(feature)
+			// An ellipsis is not a sentence-ending period
+			hasPeriod := strings.HasSuffix(trimmed, ".") && !strings.HasSuffix(trimmed, "...")
+			if hasPeriod == require {
+				continue
+			}
+			message := fmt.Sprintf("plan '%s' feature %q ends with a period", plan.ID, feature)
+			if require {
+				message = fmt.Sprintf("plan '%s' feature %q doesn't end with a period", plan.ID, feature)
+			}
+			findings = append(findings, Finding{
+				Rule:    "feature-trailing-period",
+				Path:    fmt.Sprintf("/plans/%d/features/%d", i, j),
+				Message: message,
+			})
+		}
+	}
+	return findings
+}
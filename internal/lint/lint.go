@@ -0,0 +1,181 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"raterunner/internal/config"
+)
+
+// Finding is a single lint warning about a billing config
+type Finding struct {
+	Rule              string `json:"rule"`
+	Path              string `json:"path"`
+	Message           string `json:"message"`
+	Suppressed        bool   `json:"suppressed,omitempty"`         // silenced by a raterunner:disable comment
+	SuppressionReason string `json:"suppression_reason,omitempty"` // reason="..." from the comment
+}
+
+// Rule is a named check over a billing config
+type Rule struct {
+	ID          string
+	Pack        string // empty for rules that always run; otherwise opt-in via Options.Enable
+	Description string
+	Check       func(cfg *config.BillingConfig, opts Options) []Finding
+}
+
+// Rules is the built-in rule set, in the order findings are reported
+var Rules = []Rule{
+	{
+		ID:          "grace-without-dunning",
+		Description: "grace_days is set but no dunning settings exist",
+		Check:       checkGraceWithoutDunning,
+	},
+	{
+		ID:          "price-cents",
+		Pack:        PackPricing,
+		Description: "price ends in non-standard cents (not .00, .50, .95, or .99)",
+		Check:       checkPriceCents,
+	},
+	{
+		ID:          "yearly-discount-band",
+		Pack:        PackPricing,
+		Description: "yearly price discount is outside the 10-30% band",
+		Check:       checkYearlyDiscount,
+	},
+	{
+		ID:          "missing-yearly",
+		Pack:        PackPricing,
+		Description: "plan has a monthly price but no yearly price",
+		Check:       checkMissingYearly,
+	},
+	{
+		ID:          "features-match-limits",
+		Pack:        PackPricing,
+		Description: "features list doesn't match the plan's bool entitlements",
+		Check:       checkFeaturesMatchLimits,
+	},
+	{
+		ID:          "plan-order",
+		Pack:        PackPricing,
+		Description: "public plans are not ordered by monthly price",
+		Check:       checkPlanOrder,
+	},
+	{
+		ID:          "feature-length",
+		Description: "feature is longer than Stripe's 80-character marketing feature limit",
+		Check:       checkFeatureLength,
+	},
+	{
+		ID:          "feature-html",
+		Description: "feature contains raw HTML tags or entities",
+		Check:       checkFeatureHTML,
+	},
+	{
+		ID:          "feature-capitalization",
+		Pack:        PackCopy,
+		Description: "feature's first letter is cased differently from most features",
+		Check:       checkFeatureCapitalization,
+	},
+	{
+		ID:          "feature-trailing-period",
+		Pack:        PackCopy,
+		Description: "feature has or lacks a trailing period, per --feature-periods",
+		Check:       checkFeatureTrailingPeriod,
+	},
+	{
+		ID:          "plan-naming",
+		Pack:        PackCopy,
+		Description: "a plan is named with different casing across the copy (\"Pro plan\" vs \"Pro Plan\")",
+		Check:       checkPlanNaming,
+	},
+	{
+		ID:          "feature-currency",
+		Pack:        PackCopy,
+		Description: "feature contains a currency symbol",
+		Check:       checkFeatureCurrency,
+	},
+	{
+		ID:          "feature-limit-claims",
+		Pack:        PackCopy,
+		Description: "feature claims a number that contradicts the plan's int limit",
+		Check:       checkFeatureLimitClaims,
+	},
+}
+
+// PackPricing groups the optional pricing psychology and consistency rules
+const PackPricing = "pricing"
+
+// PackCopy groups the optional consistency rules for customer-facing text,
+// which is shown verbatim in Stripe and on pricing pages
+const PackCopy = "copy"
+
+// Options selects which rules run
+type Options struct {
+	Enable  []string // packs or rule IDs to turn on in addition to the always-on rules
+	Disable []string // rule IDs to turn off
+
+	FeaturePeriods string // PeriodsOmit (default) or PeriodsRequire
+}
+
+// Run applies the selected rules to cfg and returns findings ordered by rule, then path
+func Run(cfg *config.BillingConfig, opts Options) ([]Finding, error) {
+	if opts.FeaturePeriods == "" {
+		opts.FeaturePeriods = PeriodsOmit
+	}
+	if opts.FeaturePeriods != PeriodsOmit && opts.FeaturePeriods != PeriodsRequire {
+		return nil, fmt.Errorf("invalid feature period policy: %s (use '%s' or '%s')", opts.FeaturePeriods, PeriodsOmit, PeriodsRequire)
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range opts.Enable {
+		if !isKnown(name) {
+			return nil, fmt.Errorf("unknown lint rule or pack: %s", name)
+		}
+		enabled[name] = true
+	}
+	disabled := make(map[string]bool)
+	for _, id := range opts.Disable {
+		if !isKnown(id) {
+			return nil, fmt.Errorf("unknown lint rule or pack: %s", id)
+		}
+		disabled[id] = true
+	}
+
+	var findings []Finding
+	for _, rule := range Rules {
+		if disabled[rule.ID] || (rule.Pack != "" && disabled[rule.Pack]) {
+			continue
+		}
+		if rule.Pack != "" && !enabled[rule.Pack] && !enabled[rule.ID] {
+			continue
+		}
+		found := rule.Check(cfg, opts)
+		sort.SliceStable(found, func(i, j int) bool { return found[i].Path < found[j].Path })
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+// isKnown reports whether name is a rule ID or a pack
+func isKnown(name string) bool {
+	for _, rule := range Rules {
+		if rule.ID == name || (rule.Pack != "" && rule.Pack == name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGraceWithoutDunning warns when a grace period is configured but nothing
+// describes how failed payments are retried during it
+func checkGraceWithoutDunning(cfg *config.BillingConfig, _ Options) []Finding {
+	if cfg.GraceDays() == 0 || cfg.Dunning() != nil {
+		return nil
+	}
+	return []Finding{{
+		Rule:    "grace-without-dunning",
+		Path:    "/settings/grace_days",
+		Message: "grace_days is set but no dunning settings exist; describe payment retries in settings.dunning, and make sure they are configured in Stripe, so the grace period can recover failed payments",
+	}}
+}
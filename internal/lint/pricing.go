@@ -0,0 +1,189 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"raterunner/internal/config"
+)
+
+// standardCents are the price endings customers expect (x.00, x.50, x.95, x.99)
+var standardCents = map[int]bool{0: true, 50: true, 95: true, 99: true}
+
+// Yearly discount band considered normal, as a fraction of twelve monthly payments
+const (
+	minYearlyDiscount = 0.10
+	maxYearlyDiscount = 0.30
+)
+
+func checkPriceCents(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		for _, interval := range sortedIntervals(plan.Prices) {
+			price := plan.Prices[interval]
+			if price.PriceType() != "flat" || price.Amount == 0 {
+				continue
+			}
+			if cents := price.Amount % 100; !standardCents[cents] {
+				findings = append(findings, Finding{
+					Rule:    "price-cents",
+					Path:    fmt.Sprintf("/plans/%d/prices/%s/amount", i, interval),
+					Message: fmt.Sprintf("plan '%s' %s price %s ends in .%02d; prefer .00, .50, .95, or .99", plan.ID, interval, formatAmount(price.Amount), cents),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkYearlyDiscount(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		monthly, yearly, ok := flatMonthlyYearly(plan)
+		if !ok || monthly == 0 {
+			continue
+		}
+		discount := 1 - float64(yearly)/float64(monthly*12)
+		if discount < minYearlyDiscount || discount > maxYearlyDiscount {
+			findings = append(findings, Finding{
+				Rule:    "yearly-discount-band",
+				Path:    fmt.Sprintf("/plans/%d/prices/yearly/amount", i),
+				Message: fmt.Sprintf("plan '%s' yearly price %s is a %.0f%% discount on 12 × %s; expected %.0f-%.0f%%", plan.ID, formatAmount(yearly), discount*100, formatAmount(monthly), minYearlyDiscount*100, maxYearlyDiscount*100),
+			})
+		}
+	}
+	return findings
+}
+
+func checkMissingYearly(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		monthly, hasMonthly := plan.Prices["monthly"]
+		if !hasMonthly || (monthly.PriceType() == "flat" && monthly.Amount == 0) {
+			continue
+		}
+		if _, hasYearly := plan.Prices["yearly"]; !hasYearly {
+			findings = append(findings, Finding{
+				Rule:    "missing-yearly",
+				Path:    fmt.Sprintf("/plans/%d/prices", i),
+				Message: fmt.Sprintf("plan '%s' has a monthly price but no yearly price", plan.ID),
+			})
+		}
+	}
+	return findings
+}
+
+// checkFeaturesMatchLimits flags bool entitlements that are enabled on a plan but
+// never mentioned in its features, and features that advertise a disabled one.
+// An entitlement is "mentioned" when a feature contains its key (underscores as
+// spaces) or its description, case-insensitively.
+func checkFeaturesMatchLimits(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	for i, plan := range cfg.Plans {
+		if len(plan.Features) == 0 {
+			continue
+		}
+		for _, key := range sortedKeys(plan.Limits) {
+			ent, ok := cfg.Entitlements[key]
+			if !ok || ent.Type != "bool" {
+				continue
+			}
+			enabled, _ := plan.Limits[key].(bool)
+			feature := mentioningFeature(plan.Features, key, ent.Description)
+
+			switch {
+			case enabled && feature < 0:
+				findings = append(findings, Finding{
+					Rule:    "features-match-limits",
+					Path:    fmt.Sprintf("/plans/%d/limits/%s", i, key),
+					Message: fmt.Sprintf("plan '%s' enables '%s' but no feature mentions it", plan.ID, key),
+				})
+			case !enabled && feature >= 0:
+				findings = append(findings, Finding{
+					Rule:    "features-match-limits",
+					Path:    fmt.Sprintf("/plans/%d/features/%d", i, feature),
+					Message: fmt.Sprintf("plan '%s' feature %q advertises '%s', which the plan disables", plan.ID, plan.Features[feature], key),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkPlanOrder warns when a public plan's monthly price is lower than the
+// previous public plan's, since pricing pages render plans in file order
+func checkPlanOrder(cfg *config.BillingConfig, _ Options) []Finding {
+	var findings []Finding
+	prevID := ""
+	prevAmount := -1
+	for i, plan := range cfg.Plans {
+		if !plan.IsPublic() {
+			continue
+		}
+		price, ok := plan.Prices["monthly"]
+		if !ok || price.PriceType() != "flat" {
+			continue
+		}
+		if price.Amount < prevAmount {
+			findings = append(findings, Finding{
+				Rule:    "plan-order",
+				Path:    fmt.Sprintf("/plans/%d", i),
+				Message: fmt.Sprintf("plan '%s' (%s/month) is listed after the more expensive plan '%s' (%s/month)", plan.ID, formatAmount(price.Amount), prevID, formatAmount(prevAmount)),
+			})
+		}
+		prevID = plan.ID
+		prevAmount = price.Amount
+	}
+	return findings
+}
+
+// flatMonthlyYearly returns the flat monthly and yearly amounts when a plan has both
+func flatMonthlyYearly(plan config.Plan) (monthly, yearly int, ok bool) {
+	m, hasMonthly := plan.Prices["monthly"]
+	y, hasYearly := plan.Prices["yearly"]
+	if !hasMonthly || !hasYearly || m.PriceType() != "flat" || y.PriceType() != "flat" {
+		return 0, 0, false
+	}
+	return m.Amount, y.Amount, true
+}
+
+// mentioningFeature returns the index of the first feature mentioning an entitlement, or -1
+func mentioningFeature(features []string, key, description string) int {
+	needles := []string{strings.ReplaceAll(key, "_", " ")}
+	if description != "" {
+		needles = append(needles, strings.ToLower(description))
+	}
+	for i, f := range features {
+		text := strings.ToLower(f)
+		for _, n := range needles {
+			if strings.Contains(text, n) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// formatAmount renders an amount in cents as dollars, e.g. 2900 → $29.00
+func formatAmount(cents int) string {
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
+}
+
+func sortedIntervals(prices map[string]config.Price) []string {
+	keys := make([]string, 0, len(prices))
+	for k := range prices {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,164 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SuppressDirective is the comment prefix that silences findings at a YAML node
+const SuppressDirective = "raterunner:disable"
+
+// directivePattern matches `# raterunner:disable rule-a,rule-b reason="..."`
+var directivePattern = regexp.MustCompile(`^#\s*raterunner:disable\s+([A-Za-z0-9_,-]+)(?:\s+reason="([^"]*)")?\s*$`)
+
+// Suppression silences the listed rules at Path and everything below it
+type Suppression struct {
+	Path   string   `json:"path"`
+	Rules  []string `json:"rules"`
+	Reason string   `json:"reason,omitempty"`
+	Line   int      `json:"line"`
+}
+
+// Matches reports whether the suppression covers a finding of rule at path
+func (s Suppression) Matches(rule, path string) bool {
+	if s.Path != "" && path != s.Path && !strings.HasPrefix(path, s.Path+"/") {
+		return false
+	}
+	for _, r := range s.Rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSuppressions collects `# raterunner:disable` comments from a YAML document.
+// A comment above or beside a key (or list item) applies to that node's JSON
+// pointer path; a comment at the top of the file applies to the whole document.
+func ParseSuppressions(content []byte) ([]Suppression, error) {
+	var sups []Suppression
+	var parseErr error
+	err := walkYAML(content, func(path string, nodes ...*yaml.Node) {
+		for _, n := range nodes {
+			for _, comment := range []string{n.HeadComment, n.LineComment} {
+				found, err := parseDirectives(path, comment, n.Line)
+				if err != nil && parseErr == nil {
+					parseErr = err
+				}
+				sups = append(sups, found...)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sups, parseErr
+}
+
+// Lines maps each JSON pointer path in a YAML document to its line number
+func Lines(content []byte) (map[string]int, error) {
+	lines := make(map[string]int)
+	err := walkYAML(content, func(path string, nodes ...*yaml.Node) {
+		lines[path] = nodes[0].Line
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// LineFor returns the line of path, falling back to its closest ancestor
+func LineFor(lines map[string]int, path string) int {
+	for {
+		if line, ok := lines[path]; ok {
+			return line
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return 1
+		}
+		path = path[:i]
+	}
+}
+
+// walkYAML calls visit for the document and for every mapping key and sequence
+// item, with the node's JSON pointer path. Mapping entries pass the key node
+// first, then the value node.
+func walkYAML(content []byte, visit func(path string, nodes ...*yaml.Node)) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("failed to parse YAML: %w", err)
+	}
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			visit("", node)
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				childPath := path + "/" + escapePointer(key.Value)
+				visit(childPath, key, value)
+				walk(value, childPath)
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				childPath := path + "/" + strconv.Itoa(i)
+				visit(childPath, item)
+				walk(item, childPath)
+			}
+		}
+	}
+	walk(&doc, "")
+	return nil
+}
+
+// parseDirectives extracts suppressions from a (possibly multi-line) comment
+func parseDirectives(path, comment string, line int) ([]Suppression, error) {
+	var sups []Suppression
+	for _, text := range strings.Split(comment, "\n") {
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(text, "#")), SuppressDirective) {
+			continue
+		}
+		m := directivePattern.FindStringSubmatch(text)
+		if m == nil {
+			return sups, fmt.Errorf("line %d: malformed suppression %q (use: # %s rule-id reason=\"...\")", line, text, SuppressDirective)
+		}
+		sups = append(sups, Suppression{
+			Path:   path,
+			Rules:  strings.Split(m[1], ","),
+			Reason: m[2],
+			Line:   line,
+		})
+	}
+	return sups, nil
+}
+
+// escapePointer escapes a key for use in a JSON pointer (RFC 6901)
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// Suppress marks findings covered by a suppression. Suppressed findings stay in
+// the result so JSON and SARIF output can record them.
+func Suppress(findings []Finding, sups []Suppression) []Finding {
+	for i := range findings {
+		for _, s := range sups {
+			if s.Matches(findings[i].Rule, findings[i].Path) {
+				findings[i].Suppressed = true
+				findings[i].SuppressionReason = s.Reason
+				break
+			}
+		}
+	}
+	return findings
+}
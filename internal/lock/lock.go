@@ -0,0 +1,179 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// TTL is how long a lock is honoured after it was last refreshed. An older
+// lock is assumed to belong to a run that crashed and is taken over.
+const TTL = 30 * time.Minute
+
+// RefreshInterval is how often a running holder refreshes its lock, well
+// within TTL so a long run never looks crashed
+const RefreshInterval = TTL / 3
+
+// Holder describes who holds a lock
+type Holder struct {
+	Owner     string    `json:"owner"` // user@host
+	PID       int       `json:"pid"`
+	Command   string    `json:"command"`
+	Acquired  time.Time `json:"acquired"`
+	Refreshed time.Time `json:"refreshed,omitempty"`
+}
+
+// Expired reports whether the lock was acquired or last refreshed more than
+// TTL ago
+func (h Holder) Expired(now time.Time) bool {
+	last := h.Acquired
+	if h.Refreshed.After(last) {
+		last = h.Refreshed
+	}
+	return now.Sub(last) > TTL
+}
+
+// Same reports whether h and other describe the same run
+func (h Holder) Same(other Holder) bool {
+	return h.Owner == other.Owner && h.PID == other.PID && h.Acquired.Equal(other.Acquired)
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s (%s, pid %d) since %s", h.Owner, h.Command, h.PID, h.Acquired.Local().Format("15:04:05"))
+}
+
+// HeldError is returned when another run holds the lock
+type HeldError struct {
+	Name   string
+	Holder Holder
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("%s is locked by %s; if no other run is in progress, retry with --force-unlock", e.Name, e.Holder)
+}
+
+// LostError is returned when refreshing a lock that another run has taken
+// over, with --force-unlock or because it expired
+type LostError struct {
+	Name   string
+	Holder Holder
+}
+
+func (e *LostError) Error() string {
+	return fmt.Sprintf("%s was taken over by %s", e.Name, e.Holder)
+}
+
+// Lock is a held lock file
+type Lock struct {
+	path   string
+	name   string
+	holder Holder
+}
+
+// Acquire creates the lock file at path, failing with *HeldError when another
+// run holds it. Expired locks are taken over; force removes any existing lock.
+// The returned holder is the previous one when a lock was taken over or
+// removed, so callers can report it.
+func Acquire(path, name string, holder Holder, force bool) (*Lock, *Holder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	content, err := json.Marshal(holder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	var previous *Holder
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, werr := f.Write(content)
+			cerr := f.Close()
+			if werr != nil || cerr != nil {
+				os.Remove(path)
+				return nil, nil, fmt.Errorf("failed to write lock file: %w", errors.Join(werr, cerr))
+			}
+			return &Lock{path: path, name: name, holder: holder}, previous, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		current, err := read(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !force && !current.Expired(holder.Acquired) {
+			return nil, nil, &HeldError{Name: name, Holder: current}
+		}
+		previous = &current
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, nil, fmt.Errorf("failed to remove stale lock file: %w", err)
+		}
+	}
+	return nil, nil, fmt.Errorf("failed to acquire %s: lock file keeps reappearing", name)
+}
+
+// Refresh records now as the time the lock was last known to be held, so a
+// run longer than TTL keeps it. It fails with *LostError when another run has
+// taken the lock over since.
+func (l *Lock) Refresh(now time.Time) error {
+	current, err := read(l.path)
+	if err != nil {
+		return err
+	}
+	if !current.Same(l.holder) {
+		return &LostError{Name: l.name, Holder: current}
+	}
+
+	holder := l.holder
+	holder.Refreshed = now
+	content, err := json.Marshal(holder)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock: %w", err)
+	}
+	// Written aside and renamed, so a concurrent Acquire never reads half a lock
+	tmp := l.path + ".refresh"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to refresh lock file: %w", err)
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to refresh lock file: %w", err)
+	}
+	l.holder = holder
+	return nil
+}
+
+// Release removes the lock file, unless another run has taken it over since
+func (l *Lock) Release() error {
+	current, err := read(l.path)
+	if err != nil {
+		return err
+	}
+	if !current.Same(l.holder) {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file: %w", err)
+	}
+	return nil
+}
+
+// read parses a lock file. A lock file that can't be parsed (e.g. left half
+// written) is reported as held since the zero time, so it counts as expired.
+func read(path string) (Holder, error) {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return Holder{}, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	var holder Holder
+	if err := json.Unmarshal(content, &holder); err != nil {
+		return Holder{Owner: "unknown"}, nil
+	}
+	return holder, nil
+}
@@ -0,0 +1,209 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A minimal language server: JSON-RPC over stdio that publishes diagnostics
+// for open documents. Completion, hover and the rest of LSP are not supported.
+
+// Diagnostic severities
+const (
+	SeverityError   = 1
+	SeverityWarning = 2
+)
+
+// JSON-RPC error code for requests the server doesn't implement
+const methodNotFound = -32601
+
+// Position is a zero-based line and character offset
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span of a document
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Diagnostic is a problem reported for a document
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// CheckFunc returns the diagnostics for the current text of a document
+type CheckFunc func(uri string, text []byte) []Diagnostic
+
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type textDocumentParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+// Serve reads LSP messages from r and writes responses and diagnostics to w
+// until the client sends exit or closes the stream
+func Serve(r io.Reader, w io.Writer, check CheckFunc) error {
+	reader := bufio.NewReader(r)
+	for {
+		msg, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch msg.Method {
+		case "initialize":
+			err = reply(w, msg, map[string]any{
+				"capabilities": map[string]any{
+					// Full document sync: every change sends the whole text
+					"textDocumentSync": map[string]any{"openClose": true, "change": 1},
+				},
+				"serverInfo": map[string]any{"name": "raterunner"},
+			})
+		case "shutdown":
+			err = reply(w, msg, nil)
+		case "exit":
+			return nil
+		case "textDocument/didOpen", "textDocument/didChange":
+			var params textDocumentParams
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				return fmt.Errorf("failed to parse %s params: %w", msg.Method, err)
+			}
+			text := params.TextDocument.Text
+			if n := len(params.ContentChanges); n > 0 {
+				text = params.ContentChanges[n-1].Text
+			}
+			uri := params.TextDocument.URI
+			err = publish(w, uri, check(uri, []byte(text)))
+		case "textDocument/didClose":
+			var params textDocumentParams
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				return fmt.Errorf("failed to parse %s params: %w", msg.Method, err)
+			}
+			err = publish(w, params.TextDocument.URI, nil)
+		default:
+			// Notifications without a handler are ignored; requests get an error
+			if msg.ID != nil {
+				err = write(w, message{
+					JSONRPC: "2.0",
+					ID:      msg.ID,
+					Error:   &responseError{Code: methodNotFound, Message: "method not supported: " + msg.Method},
+				})
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// PathFromURI returns the file path of a file:// URI, or the URI itself
+func PathFromURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// LineRange spans the text of a one-based line, without its indentation
+func LineRange(text []byte, line int) Range {
+	lines := strings.Split(string(text), "\n")
+	if line < 1 || line > len(lines) {
+		line = 1
+	}
+	content := strings.TrimRight(lines[line-1], "\r")
+	start := len([]rune(content)) - len([]rune(strings.TrimLeft(content, " \t")))
+	return Range{
+		Start: Position{Line: line - 1, Character: start},
+		End:   Position{Line: line - 1, Character: len([]rune(content))},
+	}
+}
+
+func readMessage(r *bufio.Reader) (*message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if len(header) == 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read message header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	return &msg, nil
+}
+
+func reply(w io.Writer, req *message, result any) error {
+	if req.ID == nil {
+		return nil
+	}
+	// Written as a map so that a null result is still sent
+	return write(w, map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+func publish(w io.Writer, uri string, diagnostics []Diagnostic) error {
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{} // an empty list clears the editor's markers
+	}
+	params, err := json.Marshal(map[string]any{"uri": uri, "diagnostics": diagnostics})
+	if err != nil {
+		return fmt.Errorf("failed to marshal diagnostics: %w", err)
+	}
+	return write(w, message{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params})
+}
+
+func write(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package operator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into pods
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Kube is a minimal client for the BillingConfig resources of the Kubernetes
+// API, enough to list, watch and update their status
+type Kube struct {
+	BaseURL   string // e.g. https://10.0.0.1:443, or http://127.0.0.1:8001 for kubectl proxy
+	Token     string // bearer token; empty behind kubectl proxy
+	Namespace string // "" = all namespaces
+	HTTP      *http.Client
+}
+
+// InCluster returns a client using the pod's service account
+func InCluster(namespace string) (*Kube, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster (KUBERNETES_SERVICE_HOST is not set); use --kube-api with kubectl proxy")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("failed to parse service account CA")
+	}
+
+	return &Kube{
+		BaseURL:   "https://" + net.JoinHostPort(host, port),
+		Token:     strings.TrimSpace(string(token)),
+		Namespace: namespace,
+		HTTP: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}},
+	}, nil
+}
+
+// collectionPath returns the path of the BillingConfig collection
+func (k *Kube) collectionPath() string {
+	if k.Namespace == "" {
+		return fmt.Sprintf("/apis/%s/%s/billingconfigs", Group, Version)
+	}
+	return fmt.Sprintf("/apis/%s/%s/namespaces/%s/billingconfigs", Group, Version, k.Namespace)
+}
+
+func (k *Kube) request(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, k.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if k.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.Token)
+	}
+
+	client := k.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return resp, nil
+}
+
+// List returns the BillingConfigs and the collection's resource version,
+// from which a watch continues
+func (k *Kube) List(ctx context.Context) ([]BillingConfig, string, error) {
+	resp, err := k.request(ctx, http.MethodGet, k.collectionPath(), "", nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list billing configs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []BillingConfig `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, "", fmt.Errorf("failed to parse billing config list: %w", err)
+	}
+	return list.Items, list.Metadata.ResourceVersion, nil
+}
+
+// WatchEvent is one change reported by Watch
+type WatchEvent struct {
+	Type   string        `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK or ERROR
+	Object BillingConfig `json:"object"`
+}
+
+// Watch calls fn for every change after resourceVersion until the API server
+// ends the watch, which it does every few minutes, or ctx is done
+func (k *Kube) Watch(ctx context.Context, resourceVersion string, fn func(WatchEvent)) error {
+	path := k.collectionPath() + "?watch=true&allowWatchBookmarks=true&resourceVersion=" + resourceVersion
+	resp, err := k.request(ctx, http.MethodGet, path, "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to watch billing configs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024) // objects carry whole billing configs
+	for scanner.Scan() {
+		var event WatchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to parse watch event: %w", err)
+		}
+		if event.Type == "ERROR" {
+			return fmt.Errorf("watch ended by the API server (resource version %s is probably too old)", resourceVersion)
+		}
+		fn(event)
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return scanner.Err()
+}
+
+// UpdateStatus replaces the status of a BillingConfig
+func (k *Kube) UpdateStatus(ctx context.Context, obj *BillingConfig) error {
+	body, err := json.Marshal(map[string]any{"status": obj.Status})
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/apis/%s/%s/namespaces/%s/billingconfigs/%s/status", Group, Version, obj.Metadata.Namespace, obj.Metadata.Name)
+	resp, err := k.request(ctx, http.MethodPatch, path, "application/merge-patch+json", body)
+	if err != nil {
+		return fmt.Errorf("failed to update status of %s: %w", obj.Key(), err)
+	}
+	resp.Body.Close()
+	return nil
+}
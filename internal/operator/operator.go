@@ -0,0 +1,262 @@
+// Package operator reconciles BillingConfig custom resources to Stripe, so
+// pricing can be managed in Kubernetes like the rest of a platform.
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"raterunner/internal/config"
+	"raterunner/internal/validator"
+)
+
+// API group and version of the BillingConfig custom resource
+const (
+	Group   = "raterunner.io"
+	Version = "v1alpha1"
+)
+
+// ConditionReady reports whether the Stripe account matches the resource
+const ConditionReady = "Ready"
+
+// Reasons of the Ready condition
+const (
+	ReasonSynced        = "Synced"
+	ReasonInvalidConfig = "InvalidConfig"
+	ReasonSyncFailed    = "SyncFailed"
+)
+
+// BillingConfig is the custom resource holding a billing config and the
+// environment it is applied to
+type BillingConfig struct {
+	Metadata ObjectMeta          `json:"metadata"`
+	Spec     BillingConfigSpec   `json:"spec"`
+	Status   BillingConfigStatus `json:"status,omitempty"`
+}
+
+// ObjectMeta is the part of Kubernetes object metadata the operator uses
+type ObjectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	Generation      int64  `json:"generation"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// BillingConfigSpec is what the resource asks for
+type BillingConfigSpec struct {
+	Environment string          `json:"environment"` // sandbox or production
+	Config      json.RawMessage `json:"config"`      // the billing config, as in billing.yaml
+	Suspend     bool            `json:"suspend,omitempty"`
+}
+
+// BillingConfigStatus is what the operator last did
+type BillingConfigStatus struct {
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	CatalogVersion     string      `json:"catalogVersion,omitempty"`
+	LastSyncedAt       string      `json:"lastSyncedAt,omitempty"`
+	LastAttemptAt      string      `json:"lastAttemptAt,omitempty"`
+	Conditions         []Condition `json:"conditions,omitempty"`
+}
+
+// Condition is a standard Kubernetes status condition
+type Condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"` // True, False or Unknown
+	Reason             string `json:"reason"`
+	Message            string `json:"message,omitempty"`
+	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+}
+
+// Key returns namespace/name
+func (b *BillingConfig) Key() string {
+	return b.Metadata.Namespace + "/" + b.Metadata.Name
+}
+
+// Condition returns the condition of a type, or nil
+func (s *BillingConfigStatus) Condition(conditionType string) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == conditionType {
+			return &s.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setCondition sets a condition, keeping its transition time when the status
+// doesn't change
+func (s *BillingConfigStatus) setCondition(cond Condition, now time.Time) {
+	cond.LastTransitionTime = now.UTC().Format(time.RFC3339)
+	if existing := s.Condition(cond.Type); existing != nil {
+		if existing.Status == cond.Status {
+			cond.LastTransitionTime = existing.LastTransitionTime
+		}
+		*existing = cond
+		return
+	}
+	s.Conditions = append(s.Conditions, cond)
+}
+
+// SyncFunc applies a billing config to an environment with the sync engine
+// and returns the catalog version it stamped
+type SyncFunc func(ctx context.Context, cfg *config.BillingConfig, env string) (string, error)
+
+// Reconciler brings the Stripe accounts in line with BillingConfig resources
+type Reconciler struct {
+	Kube      *Kube
+	Sync      SyncFunc
+	Validator *validator.Validator
+	Resync    time.Duration // re-apply unchanged resources this often, to repair drift
+	Logf      func(format string, args ...any)
+
+	AllowProduction bool // apply resources targeting production; rejected otherwise
+}
+
+func (r *Reconciler) logf(format string, args ...any) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
+
+// Run lists and watches BillingConfigs and reconciles each change until ctx
+// is done. Every resource is also reconciled when the watch restarts, so
+// drift is repaired at least every Resync.
+func (r *Reconciler) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		resourceVersion, err := r.ReconcileAll(ctx)
+		if err != nil {
+			r.logf("%v; retrying in 10s", err)
+			if !sleep(ctx, 10*time.Second) {
+				break
+			}
+			continue
+		}
+
+		watchCtx, cancel := context.WithTimeout(ctx, r.resync())
+		err = r.Kube.Watch(watchCtx, resourceVersion, func(event WatchEvent) {
+			if event.Type == "ADDED" || event.Type == "MODIFIED" {
+				r.Reconcile(ctx, &event.Object)
+			}
+		})
+		cancel()
+		if err != nil {
+			r.logf("%v; relisting", err)
+		}
+	}
+	return nil
+}
+
+func (r *Reconciler) resync() time.Duration {
+	if r.Resync <= 0 {
+		return time.Hour
+	}
+	return r.Resync
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// ReconcileAll reconciles every BillingConfig once and returns the resource
+// version to watch from
+func (r *Reconciler) ReconcileAll(ctx context.Context) (string, error) {
+	items, resourceVersion, err := r.Kube.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	for i := range items {
+		r.Reconcile(ctx, &items[i])
+	}
+	return resourceVersion, nil
+}
+
+// Reconcile applies one BillingConfig when its spec changed since the last
+// attempt, or the last attempt is older than Resync, and records the outcome
+// in its status. Failures are reported in the Ready condition, not returned.
+func (r *Reconciler) Reconcile(ctx context.Context, obj *BillingConfig) {
+	now := time.Now()
+	if !r.needsSync(obj, now) {
+		return
+	}
+
+	status := obj.Status
+	status.ObservedGeneration = obj.Metadata.Generation
+	status.LastAttemptAt = now.UTC().Format(time.RFC3339)
+	cond := Condition{Type: ConditionReady, ObservedGeneration: obj.Metadata.Generation}
+
+	cfg, err := r.load(obj)
+	if err != nil {
+		cond.Status, cond.Reason, cond.Message = "False", ReasonInvalidConfig, err.Error()
+		r.logf("%s: %v", obj.Key(), err)
+	} else if version, err := r.Sync(ctx, cfg, obj.Spec.Environment); err != nil {
+		cond.Status, cond.Reason, cond.Message = "False", ReasonSyncFailed, err.Error()
+		r.logf("%s: sync to %s failed: %v", obj.Key(), obj.Spec.Environment, err)
+	} else {
+		cond.Status, cond.Reason = "True", ReasonSynced
+		cond.Message = fmt.Sprintf("Applied to %s (catalog version %s)", obj.Spec.Environment, version)
+		status.CatalogVersion = version
+		status.LastSyncedAt = now.UTC().Format(time.RFC3339)
+		r.logf("%s: synced to %s (catalog version %s)", obj.Key(), obj.Spec.Environment, version)
+	}
+	status.setCondition(cond, now)
+
+	obj.Status = status
+	if err := r.Kube.UpdateStatus(ctx, obj); err != nil {
+		r.logf("%v", err)
+	}
+}
+
+// needsSync reports whether a resource changed since the last attempt, or
+// the last attempt is due for a resync. Failed syncs are only retried on
+// resync too, so a broken spec isn't retried against Stripe in a loop.
+func (r *Reconciler) needsSync(obj *BillingConfig, now time.Time) bool {
+	if obj.Spec.Suspend {
+		return false
+	}
+	if obj.Status.ObservedGeneration != obj.Metadata.Generation {
+		return true
+	}
+	last, err := time.Parse(time.RFC3339, obj.Status.LastAttemptAt)
+	return err != nil || now.Sub(last) >= r.resync()
+}
+
+// load validates the resource's billing config and its environment
+func (r *Reconciler) load(obj *BillingConfig) (*config.BillingConfig, error) {
+	switch obj.Spec.Environment {
+	case "sandbox", "production":
+	default:
+		return nil, fmt.Errorf("invalid environment: %q (use 'sandbox' or 'production')", obj.Spec.Environment)
+	}
+	if obj.Spec.Environment == "production" && !r.AllowProduction {
+		return nil, fmt.Errorf("production is not enabled for this operator; start it with --allow-production")
+	}
+	if len(obj.Spec.Config) == 0 {
+		return nil, fmt.Errorf("spec.config is empty")
+	}
+
+	result, err := r.Validator.ValidateBilling(obj.Spec.Config, "json")
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid {
+		msgs := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			msgs[i] = e.String()
+		}
+		return nil, fmt.Errorf("%d validation error(s): %s", len(msgs), strings.Join(msgs, "; "))
+	}
+
+	cfg, err := config.LoadBilling(obj.Spec.Config, "json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load billing config: %w", err)
+	}
+	return cfg, nil
+}
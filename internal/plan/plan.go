@@ -0,0 +1,73 @@
+// Package plan saves the changes a dry run computed, so a later apply can run
+// exactly those changes after they were reviewed.
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+)
+
+// FormatVersion is the version of the plan file format
+const FormatVersion = 1
+
+// File is a saved plan: the billing config to apply, the changes it makes and
+// a fingerprint of the Stripe state they were computed from
+type File struct {
+	Version        int              `json:"version"`
+	CreatedAt      string           `json:"created_at,omitempty"` // empty with --deterministic
+	Environment    string           `json:"environment"`
+	BillingFile    string           `json:"billing_file"` // the provider file is written next to it
+	CatalogVersion string           `json:"catalog_version"`
+	StripeState    string           `json:"stripe_state"` // stripe.Fingerprint of the products and prices
+	Changes        *diff.DiffResult `json:"changes"`
+	Config         string           `json:"config"` // the billing config as it was planned
+}
+
+// Save writes a plan file
+func Save(path string, f *File) error {
+	f.Version = FormatVersion
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+// Load reads a plan file written by Save
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse plan %s: %w", path, err)
+	}
+	if f.Version != FormatVersion {
+		return nil, fmt.Errorf("plan %s has format version %d; this raterunner reads version %d", path, f.Version, FormatVersion)
+	}
+	if f.Environment == "" || f.BillingFile == "" || f.StripeState == "" || f.Config == "" {
+		return nil, fmt.Errorf("plan %s is incomplete", path)
+	}
+	return &f, nil
+}
+
+// Billing parses the planned billing config, refusing one that no longer
+// matches the catalog version it was planned with
+func (f *File) Billing() (*config.BillingConfig, error) {
+	cfg, err := config.LoadBilling([]byte(f.Config), "yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the plan's billing config: %w", err)
+	}
+	if cfg.CatalogHash() != config.CatalogVersionHash(f.CatalogVersion) {
+		return nil, fmt.Errorf("the plan's billing config doesn't match its catalog version %s; was the plan edited?", f.CatalogVersion)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,339 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/stripe"
+)
+
+// PluginPrefix marks a provider implemented by a plugin in billing configs,
+// e.g. "x-lago"
+const PluginPrefix = "x-"
+
+// PluginDirEnv overrides the plugin directory
+const PluginDirEnv = "RATERUNNER_PLUGIN_DIR"
+
+// PluginProtocolVersion is sent with every request, so plugins can refuse
+// versions they don't speak
+const PluginProtocolVersion = 1
+
+// pluginExecutablePrefix is the file name prefix of plugin executables: the
+// plugin for x-lago is raterunner-provider-lago
+const pluginExecutablePrefix = "raterunner-provider-"
+
+// PluginDir returns the directory plugins are discovered in:
+// $RATERUNNER_PLUGIN_DIR, or ~/.raterunner/plugins
+func PluginDir() string {
+	if dir := os.Getenv(PluginDirEnv); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".raterunner", "plugins")
+	}
+	return filepath.Join(home, ".raterunner", "plugins")
+}
+
+// IsPlugin reports whether a provider name refers to a plugin
+func IsPlugin(name string) bool {
+	return strings.HasPrefix(name, PluginPrefix)
+}
+
+// PluginInfo is a plugin found in the plugin directory
+type PluginInfo struct {
+	Name string // provider name used in billing configs, e.g. x-lago
+	Path string
+}
+
+// Plugins lists the executable plugins in dir, by name. A missing directory
+// has no plugins.
+func Plugins(dir string) ([]PluginInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin directory: %w", err)
+	}
+
+	var plugins []PluginInfo
+	for _, entry := range entries {
+		base, ok := strings.CutPrefix(entry.Name(), pluginExecutablePrefix)
+		if !ok || base == "" || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+		plugins = append(plugins, PluginInfo{Name: PluginPrefix + base, Path: filepath.Join(dir, entry.Name())})
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins, nil
+}
+
+// FindPlugin returns the plugin for a provider name from dir
+func FindPlugin(dir, name string) (*PluginInfo, error) {
+	if !IsPlugin(name) {
+		return nil, fmt.Errorf("provider '%s' is not a plugin provider (plugin providers start with %s)", name, PluginPrefix)
+	}
+	plugins, err := Plugins(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := range plugins {
+		if plugins[i].Name == name {
+			return &plugins[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no plugin for provider '%s': install an executable named %s%s in %s",
+		name, pluginExecutablePrefix, strings.TrimPrefix(name, PluginPrefix), dir)
+}
+
+// Plugin is a Provider implemented by an external executable. Each call runs
+// the executable with the method as its argument, writes a JSON request to its
+// stdin and reads a JSON response from its stdout. Plugins log to stderr.
+type Plugin struct {
+	name           string
+	path           string
+	env            string
+	log            io.Writer
+	catalogVersion string
+}
+
+var _ Provider = (*Plugin)(nil)
+
+// NewPlugin returns the provider of a plugin for an environment. The plugin's
+// stderr goes to log.
+func NewPlugin(info PluginInfo, env string, log io.Writer) *Plugin {
+	return &Plugin{name: info.Name, path: info.Path, env: env, log: log}
+}
+
+// SetCatalogVersion sets the catalog version sent with sync, for the plugin
+// to stamp on what it creates
+func (p *Plugin) SetCatalogVersion(version string) {
+	p.catalogVersion = version
+}
+
+// pluginRequest is the JSON written to a plugin's stdin
+type pluginRequest struct {
+	Protocol       int                   `json:"protocol"`
+	Method         string                `json:"method"`
+	Provider       string                `json:"provider"`
+	Environment    string                `json:"environment"`
+	CatalogVersion string                `json:"catalog_version,omitempty"`
+	Config         *config.BillingConfig `json:"config,omitempty"`
+	Options        any                   `json:"options,omitempty"`
+}
+
+// pluginProduct and pluginPrice are catalog entries in plugin responses
+type pluginProduct struct {
+	ID             string        `json:"id"`
+	Name           string        `json:"name"`
+	PlanCode       string        `json:"plan_code"`
+	CatalogVersion string        `json:"catalog_version,omitempty"`
+	Active         bool          `json:"active"`
+	Prices         []pluginPrice `json:"prices"`
+}
+
+type pluginPrice struct {
+	ID       string `json:"id"`
+	Interval string `json:"interval"` // monthly, yearly, or "" for one-time
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Active   bool   `json:"active"`
+}
+
+// pluginIDs are the IDs a plugin gave plans and addons
+type pluginIDs struct {
+	Plans map[string]struct {
+		ProductID string                       `json:"product_id"`
+		Prices    map[string]string            `json:"prices"`
+		Regions   map[string]map[string]string `json:"regions,omitempty"`
+	} `json:"plans"`
+	Addons map[string]struct {
+		ProductID string `json:"product_id"`
+		PriceID   string `json:"price_id"`
+	} `json:"addons"`
+}
+
+// pluginResponse is the JSON read from a plugin's stdout; the fields set
+// depend on the method
+type pluginResponse struct {
+	Error string `json:"error,omitempty"`
+
+	// sync
+	ProductsCreated int              `json:"products_created"`
+	ProductsUpdated int              `json:"products_updated"`
+	PricesCreated   int              `json:"prices_created"`
+	PricesArchived  int              `json:"prices_archived"`
+	AddonsCreated   int              `json:"addons_created"`
+	Warnings        []stripe.Warning `json:"warnings"`
+	pluginIDs
+
+	// catalog
+	Products []pluginProduct `json:"products"`
+
+	// import
+	Billing json.RawMessage `json:"billing"`
+
+	// truncate
+	ProductsArchived int `json:"products_archived"`
+}
+
+// call runs one method of the plugin, killing it when ctx is canceled
+func (p *Plugin) call(ctx context.Context, method string, cfg *config.BillingConfig, options any) (*pluginResponse, error) {
+	body, err := json.Marshal(pluginRequest{
+		Protocol:       PluginProtocolVersion,
+		Method:         method,
+		Provider:       p.name,
+		Environment:    p.env,
+		CatalogVersion: p.catalogVersion,
+		Config:         cfg,
+		Options:        options,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plugin request: %w", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.path, method)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = p.log
+	runErr := cmd.Run()
+
+	var resp pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		if runErr != nil {
+			return nil, fmt.Errorf("plugin %s %s failed: %w", p.name, method, runErr)
+		}
+		return nil, fmt.Errorf("plugin %s %s returned invalid JSON: %w", p.name, method, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin %s %s failed: %s", p.name, method, resp.Error)
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("plugin %s %s failed: %w", p.name, method, runErr)
+	}
+	return &resp, nil
+}
+
+// Name implements Provider
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Sync implements Provider with the "sync" method
+func (p *Plugin) Sync(ctx context.Context, cfg *config.BillingConfig) (*SyncResult, error) {
+	resp, err := p.call(ctx, "sync", cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{
+		ProductsCreated: resp.ProductsCreated,
+		ProductsUpdated: resp.ProductsUpdated,
+		PricesCreated:   resp.PricesCreated,
+		PricesArchived:  resp.PricesArchived,
+		AddonsCreated:   resp.AddonsCreated,
+		Warnings:        resp.Warnings,
+		PlanIDs:         make(map[string]stripe.PlanIDResult),
+		AddonIDs:        make(map[string]stripe.AddonIDResult),
+	}
+	for id, ids := range resp.Plans {
+		result.PlanIDs[id] = stripe.PlanIDResult{ProductID: ids.ProductID, Prices: ids.Prices, Regions: ids.Regions}
+	}
+	for id, ids := range resp.Addons {
+		result.AddonIDs[id] = stripe.AddonIDResult{ProductID: ids.ProductID, PriceID: ids.PriceID}
+	}
+	return result, nil
+}
+
+// Import implements Provider with the "import" method
+func (p *Plugin) Import(ctx context.Context) (*ImportResult, error) {
+	resp, err := p.call(ctx, "import", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Billing) == 0 {
+		return nil, fmt.Errorf("plugin %s import returned no billing config", p.name)
+	}
+	billing, err := config.LoadBilling(resp.Billing, "json")
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s import: %w", p.name, err)
+	}
+
+	ids := &config.ProviderConfig{
+		Provider:    p.name,
+		Environment: p.env,
+		Plans:       make(map[string]config.PlanIDs),
+		Addons:      make(map[string]config.ProductIDs),
+	}
+	for id, plan := range resp.Plans {
+		ids.Plans[id] = config.PlanIDs{ProductID: plan.ProductID, Prices: plan.Prices, Regions: plan.Regions}
+	}
+	for id, addon := range resp.Addons {
+		ids.Addons[id] = config.ProductIDs{ProductID: addon.ProductID, PriceID: addon.PriceID}
+	}
+	return &ImportResult{Billing: billing, Provider: ids}, nil
+}
+
+// Diff implements Provider by comparing the config with the products of the
+// "catalog" method, so plugins are diffed by the same rules as Stripe
+func (p *Plugin) Diff(ctx context.Context, cfg *config.BillingConfig) (*diff.DiffResult, time.Time, error) {
+	resp, err := p.call(ctx, "catalog", nil, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	products := make([]stripe.Product, len(resp.Products))
+	for i, pp := range resp.Products {
+		products[i] = stripe.Product{
+			ID:             pp.ID,
+			Name:           pp.Name,
+			PlanCode:       pp.PlanCode,
+			CatalogVersion: pp.CatalogVersion,
+			Active:         pp.Active,
+		}
+		for _, price := range pp.Prices {
+			products[i].Prices = append(products[i].Prices, stripe.ProductPrice{
+				ID:       price.ID,
+				Interval: price.Interval,
+				Amount:   price.Amount,
+				Currency: price.Currency,
+				Region:   price.Region,
+				Active:   price.Active,
+			})
+		}
+	}
+	return diff.CompareProvider(p.name, cfg, products, p.env), time.Time{}, nil
+}
+
+// Truncate implements Provider with the "truncate" method, in sandbox only
+func (p *Plugin) Truncate(ctx context.Context, opts TruncateOptions) (*TruncateResult, error) {
+	if p.env != string(stripe.Sandbox) {
+		return nil, fmt.Errorf("truncate is only allowed in sandbox environment")
+	}
+	resp, err := p.call(ctx, "truncate", nil, map[string]bool{
+		"subscriptions": opts.Subscriptions,
+		"customers":     opts.Customers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TruncateResult{ProductsArchived: resp.ProductsArchived, PricesArchived: resp.PricesArchived}, nil
+}
@@ -0,0 +1,38 @@
+// Package provider abstracts the payment providers billing configs are applied
+// to, so commands don't depend on one provider's client.
+package provider
+
+import (
+	"context"
+	"time"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/stripe"
+)
+
+// The result types are Stripe's for now, since Stripe is the only provider.
+// They move here once a second provider needs them.
+type (
+	SyncResult      = stripe.SyncResult
+	ImportResult    = stripe.ImportResult
+	TruncateOptions = stripe.TruncateOptions
+	TruncateResult  = stripe.TruncateResult
+)
+
+// Provider syncs billing configs to a payment provider's catalog. Every
+// call stops early when its context is canceled.
+type Provider interface {
+	// Name returns the provider name used in billing configs and provider
+	// file names (e.g. "stripe")
+	Name() string
+	// Sync creates and updates the catalog to match the config
+	Sync(ctx context.Context, cfg *config.BillingConfig) (*SyncResult, error)
+	// Import converts the catalog to a billing config and provider IDs
+	Import(ctx context.Context) (*ImportResult, error)
+	// Diff compares the config with the catalog. cachedAt is when the catalog
+	// was cached, or zero when it was fetched for this call.
+	Diff(ctx context.Context, cfg *config.BillingConfig) (result *diff.DiffResult, cachedAt time.Time, err error)
+	// Truncate removes the catalog from a test environment
+	Truncate(ctx context.Context, opts TruncateOptions) (*TruncateResult, error)
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/stripe"
+)
+
+var _ Provider = (*Stripe)(nil)
+
+// Stripe adapts a configured Stripe client to Provider
+type Stripe struct {
+	client *stripe.Client
+}
+
+// NewStripe returns the provider for a Stripe client
+func NewStripe(client *stripe.Client) *Stripe {
+	return &Stripe{client: client}
+}
+
+func (s *Stripe) Name() string {
+	return "stripe"
+}
+
+func (s *Stripe) Sync(ctx context.Context, cfg *config.BillingConfig) (*SyncResult, error) {
+	return s.client.Sync(ctx, cfg)
+}
+
+func (s *Stripe) Import(ctx context.Context) (*ImportResult, error) {
+	return s.client.Import(ctx)
+}
+
+func (s *Stripe) Diff(ctx context.Context, cfg *config.BillingConfig) (*diff.DiffResult, time.Time, error) {
+	products, cachedAt, err := s.client.CachedProductsWithPrices(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	result := diff.Compare(cfg, products, string(s.client.GetEnv()))
+	if len(cfg.Meters) > 0 {
+		meters, err := s.client.FetchMeters(ctx)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		diff.AddMeters(result, cfg, meters)
+	}
+	return result, cachedAt, nil
+}
+
+func (s *Stripe) Truncate(ctx context.Context, opts TruncateOptions) (*TruncateResult, error) {
+	return s.client.Truncate(ctx, opts)
+}
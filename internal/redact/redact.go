@@ -0,0 +1,14 @@
+// Package redact masks secrets in output that is logged or written to files.
+package redact
+
+import "regexp"
+
+// secretPattern matches API keys, webhook secrets and bearer tokens, with the
+// prefix that tells their kind apart in the first group
+var secretPattern = regexp.MustCompile(`\b((?:sk|rk|pk)_(?:test|live)_|whsec_|Bearer )[A-Za-z0-9_]+`)
+
+// Secrets masks the secrets in s, keeping their prefix (e.g. sk_live_...) so
+// the kind of key stays visible
+func Secrets(s string) string {
+	return secretPattern.ReplaceAllString(s, "${1}...")
+}
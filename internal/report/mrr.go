@@ -0,0 +1,185 @@
+package report
+
+import (
+	"fmt"
+	"math"
+
+	"raterunner/internal/config"
+	"raterunner/internal/stripe"
+)
+
+// intervalMonths is the length of each billing.yaml price interval in months
+var intervalMonths = map[string]float64{
+	"monthly":   1,
+	"quarterly": 3,
+	"yearly":    12,
+}
+
+// stripeIntervalMonths is the length of a Stripe recurring interval in months
+var stripeIntervalMonths = map[string]float64{
+	"day":   12.0 / 365,
+	"week":  12.0 / 52,
+	"month": 1,
+	"year":  12,
+}
+
+// MRRRow is the approximate monthly recurring revenue of one price
+type MRRRow struct {
+	Kind          string `json:"kind"`
+	ID            string `json:"id,omitempty"`
+	Interval      string `json:"interval,omitempty"`
+	PriceID       string `json:"price_id"`
+	Currency      string `json:"currency"`
+	Subscriptions int    `json:"subscriptions"`
+	Quantity      int64  `json:"quantity"`
+	MRR           int64  `json:"mrr"`                  // in cents, from billing.yaml prices (Stripe's for unknown prices)
+	StripeMRR     *int64 `json:"stripe_mrr,omitempty"` // in cents, from Stripe unit amounts; unset for tiered prices
+	Mismatch      string `json:"mismatch,omitempty"`   // how billing.yaml and Stripe prices disagree
+}
+
+// MRR estimates monthly recurring revenue per plan and interval from active
+// subscriptions. Each subscription is charged at its billing.yaml price for its
+// quantity (tiers included), then spread over the interval's months. Trialing
+// subscriptions and one-time prices don't count.
+func MRR(cfg *config.BillingConfig, items []stripe.SubscriptionItem, provider *config.ProviderConfig) ([]MRRRow, error) {
+	prices := make(map[string]config.Price)
+	for _, plan := range cfg.Plans {
+		for interval, price := range plan.Prices {
+			prices[plan.ID+"/"+interval] = price
+		}
+	}
+	for _, addon := range cfg.Addons {
+		prices[addon.ID+"/"] = addon.Price
+	}
+
+	counts := Subscribers(activeOnly(items), provider)
+	rows := make([]MRRRow, 0, len(counts))
+	for _, count := range counts {
+		row := MRRRow{
+			Kind:          count.Kind,
+			ID:            count.ID,
+			Interval:      count.Interval,
+			PriceID:       count.PriceID,
+			Subscriptions: count.Active,
+			Quantity:      count.Quantity,
+		}
+
+		var localTotal, stripeTotal float64
+		stripeKnown := true
+		localPrice, hasLocal := prices[count.ID+"/"+count.Interval]
+		for _, item := range items {
+			if item.PriceID != count.PriceID || item.Status != "active" {
+				continue
+			}
+			row.Currency = item.Currency
+
+			months, ok := stripeIntervalMonths[item.Interval]
+			if !ok {
+				return nil, fmt.Errorf("price %s: unsupported interval %q", item.PriceID, item.Interval)
+			}
+			if item.IntervalCount > 1 {
+				months *= float64(item.IntervalCount)
+			}
+
+			if item.Tiered {
+				// Stripe doesn't list tiers with subscriptions
+				stripeKnown = false
+			}
+			stripeTotal += float64(item.UnitAmount*item.Quantity) / months
+
+			if hasLocal {
+				localMonths, ok := intervalMonths[count.Interval]
+				if !ok {
+					localMonths = months
+				}
+				localTotal += float64(Charge(localPrice, item.Quantity)) / localMonths
+			}
+		}
+
+		if stripeKnown {
+			stripeMRR := int64(math.Round(stripeTotal))
+			row.StripeMRR = &stripeMRR
+		}
+		if hasLocal {
+			row.MRR = int64(math.Round(localTotal))
+			row.Mismatch = priceMismatch(localPrice, items, count.PriceID)
+		} else if row.StripeMRR != nil {
+			row.MRR = *row.StripeMRR
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Charge returns what one billing period of a price costs for a quantity, in cents
+func Charge(price config.Price, quantity int64) int64 {
+	switch price.PriceType() {
+	case "per_unit":
+		return int64(price.PerUnit) * quantity
+	case "tiered":
+		if price.Mode == "volume" {
+			for _, tier := range price.Tiers {
+				upTo := tier.GetTierUpTo()
+				if upTo == -1 || quantity <= upTo {
+					return int64(tier.Amount)*quantity + int64(tier.Flat)
+				}
+			}
+			return 0
+		}
+
+		// Graduated: each tier charges for the units that fall within it
+		var total, from int64
+		for _, tier := range price.Tiers {
+			upTo := tier.GetTierUpTo()
+			units := quantity - from
+			if upTo != -1 && upTo-from < units {
+				units = upTo - from
+			}
+			if units <= 0 {
+				break
+			}
+			total += int64(tier.Amount)*units + int64(tier.Flat)
+			from += units
+		}
+		return total
+	default:
+		return int64(price.Amount) * quantity
+	}
+}
+
+// priceMismatch describes how Stripe's unit amount differs from billing.yaml, or
+// returns "" when they agree. Tier amounts aren't compared.
+func priceMismatch(price config.Price, items []stripe.SubscriptionItem, priceID string) string {
+	want := int64(price.Amount)
+	if price.PriceType() == "per_unit" {
+		want = int64(price.PerUnit)
+	}
+
+	for _, item := range items {
+		if item.PriceID != priceID {
+			continue
+		}
+		tiered := price.PriceType() == "tiered"
+		switch {
+		case tiered && !item.Tiered:
+			return "tiered in billing.yaml, not in Stripe"
+		case !tiered && item.Tiered:
+			return "tiered in Stripe, not in billing.yaml"
+		case !tiered && item.UnitAmount != want:
+			return fmt.Sprintf("billing.yaml %d, Stripe %d", want, item.UnitAmount)
+		}
+	}
+	return ""
+}
+
+// activeOnly drops trialing subscription items
+func activeOnly(items []stripe.SubscriptionItem) []stripe.SubscriptionItem {
+	var active []stripe.SubscriptionItem
+	for _, item := range items {
+		if item.Status == "active" {
+			active = append(active, item)
+		}
+	}
+	return active
+}
@@ -0,0 +1,111 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"raterunner/internal/config"
+	"raterunner/internal/stripe"
+)
+
+// Row kinds, in report order
+const (
+	KindPlan    = "plan"
+	KindAddon   = "addon"
+	KindUnknown = "unknown" // price not in the provider file
+)
+
+// SubscriberCount counts live subscriptions on one price
+type SubscriberCount struct {
+	Kind     string `json:"kind"`
+	ID       string `json:"id,omitempty"`       // plan or addon ID from billing.yaml
+	Interval string `json:"interval,omitempty"` // price key: monthly, yearly, ...
+	PriceID  string `json:"price_id"`
+	Active   int    `json:"active"`
+	Trialing int    `json:"trialing"`
+	Quantity int64  `json:"quantity"` // total seats across active and trialing subscriptions
+}
+
+// Subscribers groups subscription items by plan and interval, resolving price
+// IDs through the provider file. Prices the provider file doesn't know are
+// reported on their own, so totals still match Stripe.
+func Subscribers(items []stripe.SubscriptionItem, provider *config.ProviderConfig) []SubscriberCount {
+	known := make(map[string]SubscriberCount)
+	for planID, ids := range provider.Plans {
+		for interval, priceID := range ids.Prices {
+			known[priceID] = SubscriberCount{Kind: KindPlan, ID: planID, Interval: interval, PriceID: priceID}
+		}
+	}
+	for addonID, ids := range provider.Addons {
+		known[ids.PriceID] = SubscriberCount{Kind: KindAddon, ID: addonID, PriceID: ids.PriceID}
+	}
+
+	counts := make(map[string]*SubscriberCount)
+	for _, item := range items {
+		row, ok := counts[item.PriceID]
+		if !ok {
+			entry, found := known[item.PriceID]
+			if !found {
+				entry = SubscriberCount{Kind: KindUnknown, PriceID: item.PriceID}
+			}
+			row = &entry
+			counts[item.PriceID] = row
+		}
+		if item.Status == "trialing" {
+			row.Trialing++
+		} else {
+			row.Active++
+		}
+		row.Quantity += item.Quantity
+	}
+
+	rows := make([]SubscriberCount, 0, len(counts))
+	for _, row := range counts {
+		rows = append(rows, *row)
+	}
+	order := map[string]int{KindPlan: 0, KindAddon: 1, KindUnknown: 2}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		if a.Interval != b.Interval {
+			return a.Interval < b.Interval
+		}
+		return a.PriceID < b.PriceID
+	})
+	return rows
+}
+
+// WriteSubscribersCSV writes subscriber counts as CSV with a header row
+func WriteSubscribersCSV(w io.Writer, rows []SubscriberCount) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"kind", "id", "interval", "price_id", "active", "trialing", "quantity"}); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	for _, row := range rows {
+		record := []string{
+			row.Kind,
+			row.ID,
+			row.Interval,
+			row.PriceID,
+			strconv.Itoa(row.Active),
+			strconv.Itoa(row.Trialing),
+			strconv.FormatInt(row.Quantity, 10),
+		}
+		if err := cw.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return nil
+}
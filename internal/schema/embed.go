@@ -2,11 +2,12 @@ package schema
 
 import (
 	"embed"
+	"fmt"
 )
 
 // Schemas are copied from schema/ submodule by `make generate`
 //
-//go:embed billing.schema.json provider.schema.json
+//go:embed billing.schema.json billing.v*.schema.json provider.schema.json
 var FS embed.FS
 
 const (
@@ -14,6 +15,18 @@ const (
 	ProviderSchemaFile = "provider.schema.json"
 )
 
+// BillingSchemaVersion is the version of billing.schema.json. Older versions
+// ship as billing.v<N>.schema.json, so configs can pin them with schema_version.
+const BillingSchemaVersion = 2
+
+// BillingSchemaFileFor returns the schema file of a billing schema version
+func BillingSchemaFileFor(version int) string {
+	if version == BillingSchemaVersion {
+		return BillingSchemaFile
+	}
+	return fmt.Sprintf("billing.v%d.schema.json", version)
+}
+
 func BillingSchema() ([]byte, error) {
 	return FS.ReadFile(BillingSchemaFile)
 }
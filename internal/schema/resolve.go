@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ResolveRefs inlines every local "#/$defs/..." reference and drops $defs,
+// producing a single self-contained schema for tools that can't follow $ref.
+// Recursive definitions are left as $ref to avoid infinite expansion.
+func ResolveRefs(content []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+
+	defs, _ := doc["$defs"].(map[string]any)
+
+	resolved := resolveNode(doc, defs, map[string]bool{})
+	if m, ok := resolved.(map[string]any); ok && !hasUnresolvedRefs(m) {
+		delete(m, "$defs")
+	}
+
+	return json.MarshalIndent(resolved, "", "  ")
+}
+
+func resolveNode(node any, defs map[string]any, stack map[string]bool) any {
+	switch n := node.(type) {
+	case map[string]any:
+		if ref, ok := n["$ref"].(string); ok && strings.HasPrefix(ref, "#/$defs/") {
+			name := strings.TrimPrefix(ref, "#/$defs/")
+			def, found := defs[name]
+			if !found || stack[name] {
+				return n
+			}
+
+			stack[name] = true
+			inlined := resolveNode(def, defs, stack)
+			delete(stack, name)
+
+			// Keep sibling keywords (e.g. description) next to the inlined definition
+			if len(n) > 1 {
+				if m, ok := inlined.(map[string]any); ok {
+					merged := make(map[string]any, len(m)+len(n))
+					for k, v := range m {
+						merged[k] = v
+					}
+					for k, v := range n {
+						if k != "$ref" {
+							merged[k] = v
+						}
+					}
+					return merged
+				}
+			}
+			return inlined
+		}
+
+		out := make(map[string]any, len(n))
+		for k, v := range n {
+			if k == "$defs" {
+				out[k] = v
+				continue
+			}
+			out[k] = resolveNode(v, defs, stack)
+		}
+		return out
+
+	case []any:
+		out := make([]any, len(n))
+		for i, v := range n {
+			out[i] = resolveNode(v, defs, stack)
+		}
+		return out
+	}
+
+	return node
+}
+
+// hasUnresolvedRefs reports whether any local $ref survived resolution (recursive definitions)
+func hasUnresolvedRefs(node any) bool {
+	switch n := node.(type) {
+	case map[string]any:
+		for k, v := range n {
+			if k == "$defs" {
+				continue
+			}
+			if k == "$ref" {
+				if ref, ok := v.(string); ok && strings.HasPrefix(ref, "#/$defs/") {
+					return true
+				}
+			}
+			if hasUnresolvedRefs(v) {
+				return true
+			}
+		}
+	case []any:
+		for _, v := range n {
+			if hasUnresolvedRefs(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package stripe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ProductCache keeps FetchProductsWithPrices results on disk between runs, one
+// file per environment and account
+type ProductCache struct {
+	Dir     string
+	TTL     time.Duration
+	Refresh bool // fetch again even when the cached products are fresh
+}
+
+// cachedProducts is the content of a cache file
+type cachedProducts struct {
+	Account  string    `json:"account"`
+	Fetched  time.Time `json:"fetched"`
+	Products []Product `json:"products"`
+}
+
+// SetProductCache makes CachedProductsWithPrices use cache. Sync, Truncate and
+// ApplyCleanup drop the environment's cached products, since they change them.
+func (c *Client) SetProductCache(cache *ProductCache) {
+	c.cache = cache
+}
+
+// CachedProductsWithPrices is FetchProductsWithPrices for read-only commands:
+// products fetched less than the cache TTL ago are read from disk instead of
+// Stripe. It returns when cached products were fetched, or the zero time when
+// they were just fetched.
+func (c *Client) CachedProductsWithPrices(ctx context.Context) ([]Product, time.Time, error) {
+	if c.cache == nil {
+		products, err := c.FetchProductsWithPrices(ctx)
+		return products, time.Time{}, err
+	}
+
+	account, err := c.Account(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	path := filepath.Join(c.cache.Dir, fmt.Sprintf("stripe_%s_%s.json", c.env, account.ID))
+
+	if !c.cache.Refresh {
+		if cached, ok := readProductCache(path, account.ID); ok && time.Since(cached.Fetched) < c.cache.TTL {
+			// Managed fields are read again, since the metadata keys may have changed
+			for i := range cached.Products {
+				c.readManagedMetadata(&cached.Products[i])
+			}
+			c.logProgress("using products cached at %s", cached.Fetched.Local().Format("15:04:05"))
+			return cached.Products, cached.Fetched, nil
+		}
+	}
+
+	products, err := c.FetchProductsWithPrices(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if err := writeProductCache(path, cachedProducts{Account: account.ID, Fetched: time.Now().UTC(), Products: products}); err != nil {
+		c.logProgress("WARNING: %v", err)
+	}
+	return products, time.Time{}, nil
+}
+
+// dropProductCache removes the cached products of the client's environment,
+// for every account
+func (c *Client) dropProductCache() {
+	if c.cache == nil {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(c.cache.Dir, fmt.Sprintf("stripe_%s_*.json", c.env)))
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			c.logProgress("WARNING: failed to remove cached products %s: %v", path, err)
+		}
+	}
+}
+
+// readProductCache reads a cache file. Missing or unreadable files are a miss.
+func readProductCache(path, account string) (*cachedProducts, bool) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var cached cachedProducts
+	if err := json.Unmarshal(content, &cached); err != nil || cached.Account != account {
+		return nil, false
+	}
+	return &cached, true
+}
+
+func writeProductCache(path string, cached cachedProducts) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached products: %w", err)
+	}
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return fmt.Errorf("failed to write cached products: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"raterunner/internal/config"
+)
+
+// CleanupAction is what cleanup can do about a leftover object
+type CleanupAction string
+
+const (
+	CleanupArchive CleanupAction = "archive" // active object, can be archived
+	CleanupDelete  CleanupAction = "delete"  // product without prices, can be deleted
+	CleanupNone    CleanupAction = "none"    // already archived and Stripe doesn't allow deleting it
+)
+
+// CleanupItem is a product or price that is no longer needed
+type CleanupItem struct {
+	ID        string        `json:"id"`
+	ProductID string        `json:"product_id,omitempty"` // for prices
+	Summary   string        `json:"summary"`
+	Active    bool          `json:"active"`
+	Action    CleanupAction `json:"action"`
+	Reason    string        `json:"reason"`
+}
+
+// CleanupPlan lists leftover products and prices found by PlanCleanup
+type CleanupPlan struct {
+	UnusedProducts  []CleanupItem `json:"unused_products"`
+	DuplicatePrices []CleanupItem `json:"duplicate_prices"`
+
+	// prices to archive along with unused products, by product ID
+	productPrices map[string][]ProductPrice
+}
+
+// CleanupResult contains the results of applying a cleanup plan
+type CleanupResult struct {
+	PricesArchived   int
+	ProductsArchived int
+	ProductsDeleted  int
+}
+
+// PlanCleanup finds raterunner-managed products that the provider file doesn't
+// reference, and prices that duplicate another price on the same product.
+// Products without plan_code, addon_code or credit_code metadata belong to
+// other tools and are never touched.
+func (c *Client) PlanCleanup(ctx context.Context, provider *config.ProviderConfig) (*CleanupPlan, error) {
+	products, err := c.fetchProducts(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	usedProducts := make(map[string]bool)
+	usedPrices := make(map[string]bool)
+	for _, ids := range provider.Plans {
+		usedProducts[ids.ProductID] = true
+		for _, priceID := range ids.Prices {
+			usedPrices[priceID] = true
+		}
+	}
+	for _, ids := range provider.Addons {
+		usedProducts[ids.ProductID] = true
+		usedPrices[ids.PriceID] = true
+	}
+	for _, ids := range provider.Credits {
+		usedProducts[ids.ProductID] = true
+		usedPrices[ids.PriceID] = true
+	}
+
+	plan := &CleanupPlan{
+		UnusedProducts:  []CleanupItem{},
+		DuplicatePrices: []CleanupItem{},
+		productPrices:   make(map[string][]ProductPrice),
+	}
+
+	for _, p := range products {
+		code, managed := c.metaValue(p.Metadata, "plan_code")
+		if !managed {
+			code, managed = c.metaValue(p.Metadata, "addon_code")
+		}
+		if !managed {
+			code, managed = c.metaValue(p.Metadata, "credit_code")
+		}
+		if !managed {
+			continue
+		}
+
+		prices, err := c.FetchPricesForProduct(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !usedProducts[p.ID] {
+			item := CleanupItem{
+				ID:      p.ID,
+				Summary: fmt.Sprintf("%q (%s, %d prices)", p.Name, code, len(prices)),
+				Active:  p.Active,
+				Reason:  "not referenced by the provider file",
+			}
+			switch {
+			case len(prices) == 0:
+				item.Action = CleanupDelete
+			case p.Active || hasActivePrice(prices):
+				item.Action = CleanupArchive
+			default:
+				item.Action = CleanupNone
+				item.Reason += "; Stripe can't delete products that have prices"
+			}
+			plan.UnusedProducts = append(plan.UnusedProducts, item)
+			plan.productPrices[p.ID] = prices
+			continue
+		}
+
+		plan.DuplicatePrices = append(plan.DuplicatePrices, duplicatePrices(p.ID, prices, usedPrices)...)
+	}
+
+	return plan, nil
+}
+
+// duplicatePrices returns the prices that repeat the interval, amount, and
+// currency of a price kept on the same product. The referenced price is kept,
+// otherwise the first active one.
+func duplicatePrices(productID string, prices []ProductPrice, usedPrices map[string]bool) []CleanupItem {
+	type priceKey struct {
+		interval string
+		amount   int64
+		currency string
+	}
+	keyOf := func(p ProductPrice) priceKey { return priceKey{p.Interval, p.Amount, p.Currency} }
+
+	kept := make(map[priceKey]string)
+	for _, p := range prices {
+		if usedPrices[p.ID] {
+			kept[keyOf(p)] = p.ID
+		}
+	}
+	for _, p := range prices {
+		if _, ok := kept[keyOf(p)]; !ok && p.Active {
+			kept[keyOf(p)] = p.ID
+		}
+	}
+	for _, p := range prices {
+		if _, ok := kept[keyOf(p)]; !ok {
+			kept[keyOf(p)] = p.ID
+		}
+	}
+
+	var items []CleanupItem
+	for _, p := range prices {
+		keptID := kept[keyOf(p)]
+		if keptID == p.ID {
+			continue
+		}
+		interval := p.Interval
+		if interval == "" {
+			interval = "one-time"
+		}
+		item := CleanupItem{
+			ID:        p.ID,
+			ProductID: productID,
+			Summary:   fmt.Sprintf("%s %d %s", interval, p.Amount, p.Currency),
+			Active:    p.Active,
+			Action:    CleanupArchive,
+			Reason:    fmt.Sprintf("duplicates %s", keptID),
+		}
+		if !p.Active {
+			item.Action = CleanupNone
+			item.Reason += "; Stripe has no API to delete prices"
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// hasActivePrice reports whether any price is still active
+func hasActivePrice(prices []ProductPrice) bool {
+	for _, p := range prices {
+		if p.Active {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyCleanup archives or deletes everything in the plan that Stripe allows
+func (c *Client) ApplyCleanup(ctx context.Context, plan *CleanupPlan) (*CleanupResult, error) {
+	result := &CleanupResult{}
+	c.dropProductCache()
+
+	for _, item := range plan.DuplicatePrices {
+		if item.Action != CleanupArchive {
+			continue
+		}
+		if err := c.archivePrice(ctx, item.ID); err != nil {
+			return result, err
+		}
+		result.PricesArchived++
+	}
+
+	for _, item := range plan.UnusedProducts {
+		switch item.Action {
+		case CleanupDelete:
+			if _, err := c.api.Products.Del(item.ID, &stripe.ProductParams{Params: stripe.Params{Context: ctx}}); err != nil {
+				return result, fmt.Errorf("failed to delete product %s: %w", item.ID, err)
+			}
+			result.ProductsDeleted++
+			c.logProgress("deleted product %s", item.ID)
+		case CleanupArchive:
+			// Prices are archived first, like truncate does
+			for _, p := range plan.productPrices[item.ID] {
+				if !p.Active {
+					continue
+				}
+				if err := c.archivePrice(ctx, p.ID); err != nil {
+					return result, err
+				}
+				result.PricesArchived++
+			}
+			if item.Active {
+				_, err := c.api.Products.Update(item.ID, &stripe.ProductParams{Params: stripe.Params{Context: ctx}, Active: stripe.Bool(false)})
+				if err != nil {
+					return result, fmt.Errorf("failed to archive product %s: %w", item.ID, err)
+				}
+				result.ProductsArchived++
+				c.logProgress("archived product %s", item.ID)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// archivePrice deactivates a price
+func (c *Client) archivePrice(ctx context.Context, id string) error {
+	_, err := c.api.Prices.Update(id, &stripe.PriceParams{Params: stripe.Params{Context: ctx}, Active: stripe.Bool(false)})
+	if err != nil {
+		return fmt.Errorf("failed to archive price %s: %w", id, err)
+	}
+	c.logProgress("archived price %s", id)
+	return nil
+}
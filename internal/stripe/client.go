@@ -1,10 +1,14 @@
 package stripe
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"os"
 	"strings"
 
 	"github.com/stripe/stripe-go/v82"
+	stripeclient "github.com/stripe/stripe-go/v82/client"
 )
 
 // Environment represents the Stripe environment
@@ -15,9 +19,26 @@ const (
 	Production Environment = "production"
 )
 
+// APIURLEnv points Stripe calls at another server, such as stripe-mock
+const APIURLEnv = "RATERUNNER_STRIPE_API_URL"
+
 // Client wraps the Stripe API client
 type Client struct {
-	env Environment
+	env  Environment
+	logf Logger
+	api  *stripeclient.API // per-client key, so clients for both environments can run at once
+
+	retry *retryTransport // retries rate-limited and failed calls, counting them for sync warnings
+
+	metaKeys    map[string]string // stored key per managed metadata field ("" = omitted); nil = default names
+	migrateMeta bool              // move managed keys stored under their default names during sync
+
+	catalogVersion string // stamped on managed products during sync; "" = no stamp
+	serial         bool   // sync plans in config order, resolving references in a second pass
+	keepGoing      bool   // collect per-object sync errors instead of stopping at the first
+	prune          bool   // archive products whose plan_code is no longer in the config
+
+	cache *ProductCache // nil = CachedProductsWithPrices always fetches
 }
 
 // NewClient creates a new Stripe client for the given environment
@@ -26,9 +47,18 @@ func NewClient(env Environment, apiKey string) (*Client, error) {
 		return nil, err
 	}
 
-	stripe.Key = apiKey
-
-	return &Client{env: env}, nil
+	c := &Client{env: env, api: &stripeclient.API{}}
+	c.retry = newRetryTransport(retryOptions, c.logProgress)
+	backend := &stripe.BackendConfig{
+		HTTPClient: &http.Client{Transport: c.retry},
+		// retryTransport retries, stripe-go's own retries would multiply the attempts
+		MaxNetworkRetries: stripe.Int64(0),
+	}
+	if url := os.Getenv(APIURLEnv); url != "" {
+		backend.URL = stripe.String(url)
+	}
+	c.api.Init(apiKey, stripe.NewBackendsWithConfig(backend))
+	return c, nil
 }
 
 // validateKey validates that the API key prefix matches the environment
@@ -65,3 +95,27 @@ func keyPrefix(key string) string {
 func (c *Client) GetEnv() Environment {
 	return c.env
 }
+
+// Account identifies the Stripe account an API key belongs to
+type Account struct {
+	ID   string
+	Name string // dashboard display name, or the business name
+}
+
+// Account fetches the account the client's key belongs to
+func (c *Client) Account(ctx context.Context) (*Account, error) {
+	// An empty ID gets the account of the key
+	a, err := c.api.Accounts.GetByID("", &stripe.AccountParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Stripe account: %w", err)
+	}
+
+	account := &Account{ID: a.ID}
+	if a.Settings != nil && a.Settings.Dashboard != nil {
+		account.Name = a.Settings.Dashboard.DisplayName
+	}
+	if account.Name == "" && a.BusinessProfile != nil {
+		account.Name = a.BusinessProfile.Name
+	}
+	return account, nil
+}
@@ -0,0 +1,192 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"raterunner/internal/config"
+)
+
+// MatchCreditPack finds the active product of a credit pack: by credit_code
+// metadata, falling back to the normalized name like addons
+func MatchCreditPack(products []Product, packID, packName string) *Product {
+	for i := range products {
+		if products[i].Active && products[i].CreditCode == packID {
+			return &products[i]
+		}
+	}
+	normalized := normalizeName(packID)
+	for i := range products {
+		if products[i].Active && products[i].PlanCode == "" && normalizeName(products[i].Name) == normalized {
+			return &products[i]
+		}
+	}
+	return nil
+}
+
+// creditMetadata is the metadata that identifies a credit pack's product and
+// tells webhook handlers what it grants, by default field name. An empty
+// value marks a key to remove.
+func creditMetadata(pack config.CreditPack) map[string]string {
+	meta := map[string]string{
+		"credit_code":         pack.ID,
+		"type":                "credit",
+		"credit_entitlement":  pack.Entitlement,
+		"credits":             strconv.Itoa(pack.Credits),
+		"credit_expires_days": "",
+	}
+	if pack.ExpiresDays > 0 {
+		meta["credit_expires_days"] = strconv.Itoa(pack.ExpiresDays)
+	}
+	return meta
+}
+
+// syncCreditPack creates or updates a credit pack's product and its one-time
+// price. Like addons, a pack whose amount changed gets a new price and its
+// old one is archived.
+func (c *Client) syncCreditPack(ctx context.Context, cfg *config.BillingConfig, pack config.CreditPack, existingProducts []Product, result *SyncResult) error {
+	existingProduct := MatchCreditPack(existingProducts, pack.ID, pack.Name)
+	taxCode := cfg.TaxCode(pack.TaxCode)
+	taxBehavior := cfg.TaxBehavior()
+
+	var productID string
+	if existingProduct != nil {
+		productID = existingProduct.ID
+		updated := false
+		track := func(changed bool, err error) error {
+			updated = updated || changed
+			return err
+		}
+		if err := track(c.syncMetadataKeys(ctx, *existingProduct, result)); err != nil {
+			return err
+		}
+		if err := track(c.syncCreditMetadata(ctx, *existingProduct, pack)); err != nil {
+			return err
+		}
+		if err := track(c.syncTaxCode(ctx, *existingProduct, taxCode)); err != nil {
+			return err
+		}
+		if err := track(c.syncCatalogVersion(ctx, *existingProduct)); err != nil {
+			return err
+		}
+		countProduct(updated, result)
+
+		var current *ProductPrice
+		for i, p := range existingProduct.Prices {
+			if p.Interval == "" && p.Amount == int64(pack.Amount) && p.Active {
+				current = &existingProduct.Prices[i]
+				break
+			}
+		}
+
+		// Archive one-time prices with another amount, so checkout stops selling them
+		for _, p := range existingProduct.Prices {
+			if p.Interval != "" || !p.Active || p.Amount == int64(pack.Amount) {
+				continue
+			}
+			action := "archiving old and creating new"
+			if current != nil {
+				action = "archiving old"
+			}
+			result.warn(Warning{
+				Code:       WarnPriceDiffers,
+				CreditPack: pack.ID,
+				ProductID:  productID,
+				Local:      int64(pack.Amount),
+				Remote:     p.Amount,
+				Message: fmt.Sprintf("credit pack '%s': price differs (local=%d, stripe=%d), %s",
+					pack.ID, pack.Amount, p.Amount, action),
+			})
+			if err := c.archivePrice(ctx, p.ID); err != nil {
+				return err
+			}
+			result.PricesArchived++
+		}
+
+		if current != nil {
+			changed, err := c.syncTaxBehavior(ctx, *current, taxBehavior)
+			if err != nil {
+				return err
+			}
+			countPrice(changed, result)
+			result.CreditIDs[pack.ID] = AddonIDResult{ProductID: productID, PriceID: current.ID}
+			return nil
+		}
+	} else {
+		meta := creditMetadata(pack)
+		if meta["credit_expires_days"] == "" {
+			delete(meta, "credit_expires_days")
+		}
+		if c.catalogVersion != "" {
+			meta["catalog_version"] = c.catalogVersion
+		}
+		params := &stripe.ProductParams{
+			Name:     stripe.String(pack.Name),
+			Metadata: c.storedMetadata(meta),
+		}
+		if pack.Description != "" {
+			params.Description = stripe.String(pack.Description)
+		}
+		if taxCode != "" {
+			params.TaxCode = stripe.String(taxCode)
+		}
+
+		params.Context = ctx
+		newProductID, err := c.newProduct(params, "product", "credit", pack.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create credit pack product: %w", err)
+		}
+		productID = newProductID
+		result.CreditPacksCreated++
+		c.logProgress("credit pack '%s': created product %s", pack.ID, productID)
+	}
+
+	priceParams := &stripe.PriceParams{
+		Product:    stripe.String(productID),
+		UnitAmount: stripe.Int64(int64(pack.Amount)),
+		Currency:   stripe.String("usd"),
+	}
+	if taxBehavior != "" {
+		priceParams.TaxBehavior = stripe.String(taxBehavior)
+	}
+
+	priceParams.Context = ctx
+	priceID, err := c.newPrice(priceParams, "price", "credit", pack.ID, strconv.Itoa(pack.Amount))
+	if err != nil {
+		return fmt.Errorf("failed to create credit pack price: %w", err)
+	}
+	result.PricesCreated++
+	c.logProgress("credit pack '%s': created price %s", pack.ID, priceID)
+
+	result.CreditIDs[pack.ID] = AddonIDResult{ProductID: productID, PriceID: priceID}
+	return nil
+}
+
+// syncCreditMetadata keeps the grant metadata of an existing credit pack
+// product in line with the config. It reports whether the product was updated.
+func (c *Client) syncCreditMetadata(ctx context.Context, p Product, pack config.CreditPack) (bool, error) {
+	params := &stripe.ProductParams{}
+	changed := false
+	for field, want := range creditMetadata(pack) {
+		key := c.metaKey(field)
+		if current, _ := c.metaValue(p.Metadata, field); key == "" || current == want {
+			continue
+		}
+		// An empty value removes the key from Stripe metadata
+		params.AddMetadata(key, want)
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return false, fmt.Errorf("failed to update credit metadata on product %s: %w", p.ID, err)
+	}
+	c.logProgress("credit pack '%s': updated credit metadata on product %s", pack.ID, p.ID)
+	return true, nil
+}
@@ -0,0 +1,199 @@
+package stripe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// SyncError is the failure of one plan, addon or promotion. Pointer and Hint
+// map a Stripe API error back to the billing file when the failed parameter
+// is known.
+type SyncError struct {
+	Kind     string // plan, addon or promotion
+	ID       string
+	Interval string // price interval, for plan price errors
+	Pointer  string // JSON pointer into the billing file, e.g. /plans/1/prices/monthly/amount
+	Hint     string // common cause of the Stripe error
+	Err      error
+}
+
+func (e SyncError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "failed to sync %s '%s'", e.Kind, e.ID)
+	if e.Interval != "" {
+		b.WriteString(" " + e.Interval)
+	}
+	if e.Pointer != "" {
+		b.WriteString(" at " + e.Pointer)
+	}
+	b.WriteString(": " + describeError(e.Err))
+	if e.Hint != "" {
+		fmt.Fprintf(&b, " (hint: %s)", e.Hint)
+	}
+	return b.String()
+}
+
+func (e SyncError) Unwrap() error {
+	return e.Err
+}
+
+// SyncErrors reports every object that failed during a keep-going sync
+type SyncErrors []SyncError
+
+func (e SyncErrors) Error() string {
+	lines := make([]string, 0, len(e)+1)
+	lines = append(lines, fmt.Sprintf("%d object(s) failed to sync:", len(e)))
+	for _, err := range e {
+		lines = append(lines, "  "+err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// priceError marks an error from syncing one of a plan's prices
+type priceError struct {
+	interval  string
+	region    string // "" for the plan's default prices
+	priceType string
+	err       error
+}
+
+func (e *priceError) Error() string {
+	return e.err.Error()
+}
+
+func (e *priceError) Unwrap() error {
+	return e.err
+}
+
+// describeError replaces the JSON that stripe-go prints for API errors with
+// the error code and message
+func describeError(err error) string {
+	msg := err.Error()
+	var stripeErr *stripe.Error
+	if !errors.As(err, &stripeErr) || stripeErr.Msg == "" {
+		return msg
+	}
+	short := stripeErr.Msg
+	if stripeErr.Code != "" {
+		short = fmt.Sprintf("%s: %s", stripeErr.Code, stripeErr.Msg)
+	}
+	if stripeErr.Param != "" {
+		short += fmt.Sprintf(" (param %s)", stripeErr.Param)
+	}
+	return strings.Replace(msg, stripeErr.Error(), short, 1)
+}
+
+// enrich fills in the interval, the pointer to the failed field and a hint
+// from the Stripe error wrapped in e. e.Pointer holds the object's pointer.
+func (e SyncError) enrich() SyncError {
+	var pe *priceError
+	if errors.As(e.Err, &pe) {
+		e.Interval = pe.interval
+		if pe.region != "" {
+			e.Interval = pe.region + " " + pe.interval
+		}
+	}
+
+	var stripeErr *stripe.Error
+	if !errors.As(e.Err, &stripeErr) {
+		return e
+	}
+	if field := localField(e.Kind, stripeErr.Param, pe); field != "" {
+		e.Pointer += "/" + field
+	}
+	e.Hint = errorHint(stripeErr)
+	return e
+}
+
+// localField maps a Stripe API parameter to the billing file field it is
+// built from, relative to the plan, addon, promotion or meter
+func localField(kind, param string, pe *priceError) string {
+	switch kind {
+	case "plan":
+		if param == "recurring[trial_period_days]" {
+			return "trial_days"
+		}
+		if pe == nil {
+			return productField(param)
+		}
+		prices := "prices/" + pe.interval
+		if pe.region != "" {
+			prices = "regions/" + pe.region + "/" + prices
+		}
+		switch {
+		case param == "unit_amount" && pe.priceType == "per_unit":
+			return prices + "/per_unit"
+		case param == "unit_amount":
+			return prices + "/amount"
+		case strings.HasPrefix(param, "tiers"):
+			return prices + "/tiers"
+		case param == "recurring[meter]":
+			return prices + "/meter"
+		}
+		return prices
+	case "addon":
+		if param == "unit_amount" {
+			return "price/amount"
+		}
+		return productField(param)
+	case "credit":
+		if param == "unit_amount" {
+			return "amount"
+		}
+		return productField(param)
+	case "promotion":
+		switch param {
+		case "percent_off":
+			return "discount/percent"
+		case "amount_off":
+			return "discount/fixed"
+		case "duration", "duration_in_months":
+			return "duration"
+		case "max_redemptions":
+			return "max_uses"
+		case "expires_at":
+			return "expires"
+		case "code":
+			return "code"
+		}
+	case "meter":
+		switch param {
+		case "display_name", "event_name":
+			return param
+		case "default_aggregation[formula]":
+			return "aggregation"
+		}
+	}
+	return ""
+}
+
+// productField maps a product parameter to the plan or addon field
+func productField(param string) string {
+	switch param {
+	case "name", "description", "tax_code":
+		return param
+	}
+	return ""
+}
+
+// errorHint returns a common cause of a Stripe API error, or ""
+func errorHint(err *stripe.Error) string {
+	switch {
+	case err.Code == stripe.ErrorCodeRateLimit:
+		return "Stripe rate-limited the run after every retry; apply again, objects that were synced are reused, or raise retry_max_attempts"
+	case strings.Contains(err.Param, "amount") || strings.HasPrefix(err.Param, "tiers"):
+		return "amounts are whole numbers in cents, e.g. 2900 for $29.00"
+	case err.Param == "tax_code":
+		return "tax codes look like txcd_10103001; a plan or addon tax_code overrides settings.tax_code"
+	case err.Param == "recurring[trial_period_days]":
+		return "trial_days is a whole number of days, at most 730"
+	case err.Param == "percent_off":
+		return "discount.percent is between 1 and 100"
+	case err.Param == "duration_in_months":
+		return "duration months is a whole number of months"
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// EventTypes are the event type filters for objects raterunner manages
+var EventTypes = []string{"product.*", "price.*", "coupon.*", "promotion_code.*"}
+
+// Who triggered an event
+const (
+	ActorAPI       = "api"       // API request with an idempotency key, as SDKs like stripe-go send
+	ActorDashboard = "dashboard" // API request without an idempotency key
+	ActorAutomatic = "automatic" // no request, e.g. a coupon expiring
+)
+
+// Event is a change to a product, price, coupon, or promotion code
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Created   time.Time `json:"created"`
+	ObjectID  string    `json:"object_id"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id,omitempty"`
+	Changed   []string  `json:"changed,omitempty"` // fields changed by *.updated events
+	// APIVersion is the version Stripe rendered the event in, the account's
+	// default when the event was created
+	APIVersion string `json:"api_version,omitempty"`
+}
+
+// FetchEvents lists events for managed object types created at or after since,
+// oldest first. Stripe keeps events for 30 days.
+func (c *Client) FetchEvents(ctx context.Context, since time.Time) ([]Event, error) {
+	params := &stripe.EventListParams{
+		CreatedRange: &stripe.RangeQueryParams{GreaterThanOrEqual: since.Unix()},
+		Types:        stripe.StringSlice(EventTypes),
+	}
+	params.Context = ctx
+	params.Filters.AddFilter("limit", "", listPageSize)
+
+	var events []Event
+	iter := c.api.Events.List(params)
+	for iter.Next() {
+		e := iter.Event()
+
+		ev := Event{
+			ID:      e.ID,
+			Type:    string(e.Type),
+			Created: time.Unix(e.Created, 0).UTC(),
+			Actor:   eventActor(e.Request),
+
+			APIVersion: e.APIVersion,
+		}
+		if e.Request != nil {
+			ev.RequestID = e.Request.ID
+		}
+		if e.Data != nil {
+			if id, ok := e.Data.Object["id"].(string); ok {
+				ev.ObjectID = id
+			}
+			for field := range e.Data.PreviousAttributes {
+				ev.Changed = append(ev.Changed, field)
+			}
+			sort.Strings(ev.Changed)
+		}
+
+		events = append(events, ev)
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	// Stripe lists newest first
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Created.Before(events[j].Created)
+	})
+	return events, nil
+}
+
+// eventActor guesses who triggered an event. Stripe doesn't say whether a
+// request came from the Dashboard, but its SDKs send an idempotency key with
+// every write (stripe-go does whenever retries are enabled, the default) and
+// the Dashboard doesn't, so this is a best effort.
+func eventActor(req *stripe.EventRequest) string {
+	switch {
+	case req == nil || req.ID == "":
+		return ActorAutomatic
+	case req.IdempotencyKey == "":
+		return ActorDashboard
+	default:
+		return ActorAPI
+	}
+}
@@ -1,62 +1,62 @@
 package stripe
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/stripe/stripe-go/v82"
-	"github.com/stripe/stripe-go/v82/price"
-	"github.com/stripe/stripe-go/v82/product"
 )
 
 // Product represents a Stripe product with its prices
 type Product struct {
-	ID           string
-	Name         string
-	PlanCode     string // from metadata
-	BillingModel string // from metadata: "subscription" or "one_time"
-	Active       bool
-	Prices       []ProductPrice
+	ID             string
+	Name           string
+	PlanCode       string // from metadata
+	CreditCode     string // from metadata, set on credit pack products
+	BillingModel   string // from metadata: "subscription" or "one_time"
+	CatalogVersion string // from metadata: catalog_version stamped by the last apply
+	TaxCode        string
+	Metadata       map[string]string
+	Active         bool
+	Prices         []ProductPrice
 }
 
 // ProductPrice represents a Stripe price
 type ProductPrice struct {
-	ID       string
-	Interval string // "month", "year", or "" for one-time
-	Amount   int64
-	Currency string
-	Active   bool
+	ID          string
+	Interval    string // "month", "year", or "" for one-time
+	Amount      int64
+	Currency    string
+	TaxBehavior string // "exclusive", "inclusive", or "unspecified"
+	Region      string // from metadata, "" for a plan's default prices
+	MeterID     string // meter of a metered price
+	Active      bool
 }
 
 // FetchProducts retrieves all active products from Stripe
-func (c *Client) FetchProducts() ([]Product, error) {
+func (c *Client) FetchProducts(ctx context.Context) ([]Product, error) {
+	return c.fetchProducts(ctx, true)
+}
+
+// fetchProducts lists products, optionally including archived ones
+func (c *Client) fetchProducts(ctx context.Context, activeOnly bool) ([]Product, error) {
 	var products []Product
 
 	params := &stripe.ProductListParams{}
-	params.Filters.AddFilter("limit", "", "100")
-	params.Filters.AddFilter("active", "", "true")
+	params.Context = ctx
+	params.Filters.AddFilter("limit", "", listPageSize)
+	if activeOnly {
+		params.Filters.AddFilter("active", "", "true")
+	}
 
-	iter := product.List(params)
+	iter := c.api.Products.List(params)
 	for iter.Next() {
-		p := iter.Product()
-
-		prod := Product{
-			ID:     p.ID,
-			Name:   p.Name,
-			Active: p.Active,
-		}
-
-		// Check for plan_code in metadata
-		if planCode, ok := p.Metadata["plan_code"]; ok {
-			prod.PlanCode = planCode
-		}
-
-		// Check for billing_model in metadata
-		if billingModel, ok := p.Metadata["billing_model"]; ok {
-			prod.BillingModel = billingModel
-		}
-
-		products = append(products, prod)
+		products = append(products, c.toProduct(iter.Product()))
 	}
 
 	if err := iter.Err(); err != nil {
@@ -66,24 +66,51 @@ func (c *Client) FetchProducts() ([]Product, error) {
 	return products, nil
 }
 
+// toProduct converts a Stripe product, without its prices
+func (c *Client) toProduct(p *stripe.Product) Product {
+	prod := Product{
+		ID:       p.ID,
+		Name:     p.Name,
+		Metadata: p.Metadata,
+		Active:   p.Active,
+	}
+
+	if p.TaxCode != nil {
+		prod.TaxCode = p.TaxCode.ID
+	}
+	c.readManagedMetadata(&prod)
+	return prod
+}
+
+// readManagedMetadata sets the product fields raterunner keeps in metadata
+func (c *Client) readManagedMetadata(prod *Product) {
+	prod.PlanCode, _ = c.metaValue(prod.Metadata, "plan_code")
+	prod.CreditCode, _ = c.metaValue(prod.Metadata, "credit_code")
+	prod.BillingModel, _ = c.metaValue(prod.Metadata, "billing_model")
+	prod.CatalogVersion, _ = c.metaValue(prod.Metadata, "catalog_version")
+}
+
 // FetchPricesForProduct retrieves all prices for a given product
-func (c *Client) FetchPricesForProduct(productID string) ([]ProductPrice, error) {
+func (c *Client) FetchPricesForProduct(ctx context.Context, productID string) ([]ProductPrice, error) {
 	var prices []ProductPrice
 
 	params := &stripe.PriceListParams{}
+	params.Context = ctx
 	params.Filters.AddFilter("product", "", productID)
-	params.Filters.AddFilter("limit", "", "100")
+	params.Filters.AddFilter("limit", "", listPageSize)
 
-	iter := price.List(params)
+	iter := c.api.Prices.List(params)
 	for iter.Next() {
 		p := iter.Price()
 
 		pp := ProductPrice{
-			ID:       p.ID,
-			Amount:   p.UnitAmount,
-			Currency: string(p.Currency),
-			Active:   p.Active,
+			ID:          p.ID,
+			Amount:      p.UnitAmount,
+			Currency:    string(p.Currency),
+			TaxBehavior: string(p.TaxBehavior),
+			Active:      p.Active,
 		}
+		pp.Region, _ = c.metaValue(p.Metadata, "region")
 
 		// Determine interval
 		if p.Recurring != nil {
@@ -95,6 +122,7 @@ func (c *Client) FetchPricesForProduct(productID string) ([]ProductPrice, error)
 			default:
 				pp.Interval = string(p.Recurring.Interval)
 			}
+			pp.MeterID = p.Recurring.Meter
 		}
 
 		prices = append(prices, pp)
@@ -108,14 +136,14 @@ func (c *Client) FetchPricesForProduct(productID string) ([]ProductPrice, error)
 }
 
 // FetchProductsWithPrices retrieves all products with their prices
-func (c *Client) FetchProductsWithPrices() ([]Product, error) {
-	products, err := c.FetchProducts()
+func (c *Client) FetchProductsWithPrices(ctx context.Context) ([]Product, error) {
+	products, err := c.FetchProducts(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	for i := range products {
-		prices, err := c.FetchPricesForProduct(products[i].ID)
+		prices, err := c.FetchPricesForProduct(ctx, products[i].ID)
 		if err != nil {
 			return nil, err
 		}
@@ -125,10 +153,29 @@ func (c *Client) FetchProductsWithPrices() ([]Product, error) {
 	return products, nil
 }
 
+// Fingerprint hashes products and their prices independently of the order
+// Stripe listed them in, so two fetches can be compared to tell whether
+// anything changed in between
+func Fingerprint(products []Product) string {
+	sorted := make([]Product, len(products))
+	for i, p := range products {
+		p.Prices = append([]ProductPrice(nil), p.Prices...)
+		sort.Slice(p.Prices, func(a, b int) bool { return p.Prices[a].ID < p.Prices[b].ID })
+		sorted[i] = p
+	}
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].ID < sorted[b].ID })
+
+	// Products and prices always marshal
+	data, _ := json.Marshal(sorted)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // MatchProduct finds an active Stripe product that matches the given plan ID.
-// It first checks for plan_code metadata match, then falls back to name matching.
+// It first checks for plan_code metadata match, then for a plan_code matching one
+// of previousIDs (a renamed plan), then falls back to name matching.
 // Only active products are considered - archived products are ignored.
-func MatchProduct(products []Product, planID, planName string) *Product {
+func MatchProduct(products []Product, planID, planName string, previousIDs ...string) *Product {
 	// Primary: match by plan_code metadata (active only)
 	for i := range products {
 		if products[i].Active && products[i].PlanCode == planID {
@@ -136,6 +183,15 @@ func MatchProduct(products []Product, planID, planName string) *Product {
 		}
 	}
 
+	// Renamed plans: match by a previous plan_code, most recent rename first
+	for _, prevID := range previousIDs {
+		for i := range products {
+			if products[i].Active && products[i].PlanCode == prevID {
+				return &products[i]
+			}
+		}
+	}
+
 	// Fallback: match by normalized name (active only)
 	normalizedPlanID := normalizeName(planID)
 	for i := range products {
@@ -147,6 +203,20 @@ func MatchProduct(products []Product, planID, planName string) *Product {
 	return nil
 }
 
+// RenamedFrom returns the old plan ID when product is tagged with one of
+// previousIDs rather than planID, or "" when no rename is pending
+func RenamedFrom(product *Product, planID string, previousIDs []string) string {
+	if product == nil || product.PlanCode == "" || product.PlanCode == planID {
+		return ""
+	}
+	for _, prevID := range previousIDs {
+		if product.PlanCode == prevID {
+			return prevID
+		}
+	}
+	return ""
+}
+
 // normalizeName converts a name to a normalized form for comparison
 func normalizeName(name string) string {
 	// Convert to lowercase
@@ -0,0 +1,41 @@
+package stripe
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPOptions tunes the connection pool shared by every Stripe client
+type HTTPOptions struct {
+	MaxIdleConns    int // keep-alive connections kept open between calls (0 = DefaultMaxIdleConns)
+	MaxConnsPerHost int // connections open at once, idle or not (0 = no limit)
+}
+
+// DefaultMaxIdleConns keeps a connection alive for every client a command runs
+// at once, with room to spare
+const DefaultMaxIdleConns = 10
+
+// requestTimeout matches stripe-go's default
+const requestTimeout = 80 * time.Second
+
+// httpClient is shared by all clients, so they reuse each other's connections
+var httpClient = newHTTPClient(HTTPOptions{})
+
+// ConfigureHTTP replaces the HTTP client Stripe clients share. Must be called
+// before creating clients.
+func ConfigureHTTP(opts HTTPOptions) {
+	httpClient = newHTTPClient(opts)
+}
+
+func newHTTPClient(opts HTTPOptions) *http.Client {
+	idle := opts.MaxIdleConns
+	if idle <= 0 {
+		idle = DefaultMaxIdleConns
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = idle
+	// Every call goes to api.stripe.com, so the per-host pool is the whole pool
+	transport.MaxIdleConnsPerHost = idle
+	transport.MaxConnsPerHost = opts.MaxConnsPerHost
+	return &http.Client{Timeout: requestTimeout, Transport: transport}
+}
@@ -0,0 +1,155 @@
+package stripe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// idempotentReplayedHeader is set by Stripe on the response to a request
+// whose idempotency key was already used
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// idempotencyKey derives the key of a create call from what it creates, e.g.
+// "price", "pro", "monthly", "2900", and its parameters. A re-run after a
+// crash sends the same key, so Stripe returns the object the crashed run
+// created instead of creating a duplicate. The parameters are hashed because
+// Stripe rejects a key reused with different ones.
+func idempotencyKey(params stripe.ParamsContainer, parts ...string) string {
+	p := params.GetParams()
+	ctx, key := p.Context, p.IdempotencyKey
+	p.Context, p.IdempotencyKey = nil, nil
+	// Parameter structs always marshal
+	data, _ := json.Marshal(params)
+	p.Context, p.IdempotencyKey = ctx, key
+
+	sum := sha256.Sum256(append([]byte(strings.Join(parts, "\x00")+"\x00"), data...))
+	return fmt.Sprintf("raterunner-%s-%s", strings.Join(parts, "-"), hex.EncodeToString(sum[:8]))
+}
+
+// createIdempotent runs create with the idempotency key for parts and params.
+// Stripe keeps keys for 24 hours and replays the first response even when
+// the object was archived or deleted since, e.g. by truncate, so a replayed
+// object is checked with live and, when it is gone, created again under a key
+// derived from its ID. create returns the created object's ID and response.
+func (c *Client) createIdempotent(ctx context.Context, params stripe.ParamsContainer, parts []string, create func() (string, *stripe.APIResponse, error), live func(ctx context.Context, id string) (bool, error)) (string, error) {
+	for {
+		params.GetParams().IdempotencyKey = stripe.String(idempotencyKey(params, parts...))
+		id, resp, err := create()
+		if err != nil || resp == nil || resp.Header.Get(idempotentReplayedHeader) != "true" {
+			return id, err
+		}
+		ok, err := live(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			c.logProgress("%s: %s was created by an earlier run", strings.Join(parts, " "), id)
+			return id, nil
+		}
+		parts = append(parts, id)
+	}
+}
+
+// productLive, priceLive, couponLive, promotionCodeLive and meterLive report
+// whether a replayed object can still be used
+func (c *Client) productLive(ctx context.Context, id string) (bool, error) {
+	p, err := c.api.Products.Get(id, &stripe.ProductParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return false, fmt.Errorf("failed to get product %s: %w", id, err)
+	}
+	return p.Active, nil
+}
+
+func (c *Client) priceLive(ctx context.Context, id string) (bool, error) {
+	p, err := c.api.Prices.Get(id, &stripe.PriceParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return false, fmt.Errorf("failed to get price %s: %w", id, err)
+	}
+	return p.Active, nil
+}
+
+func (c *Client) couponLive(ctx context.Context, id string) (bool, error) {
+	_, err := c.api.Coupons.Get(id, &stripe.CouponParams{Params: stripe.Params{Context: ctx}})
+	if stripeErr, ok := err.(*stripe.Error); ok && stripeErr.Code == stripe.ErrorCodeResourceMissing {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get coupon %s: %w", id, err)
+	}
+	return true, nil
+}
+
+func (c *Client) promotionCodeLive(ctx context.Context, id string) (bool, error) {
+	pc, err := c.api.PromotionCodes.Get(id, &stripe.PromotionCodeParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return false, fmt.Errorf("failed to get promotion code %s: %w", id, err)
+	}
+	return pc.Active, nil
+}
+
+func (c *Client) meterLive(ctx context.Context, id string) (bool, error) {
+	m, err := c.api.BillingMeters.Get(id, &stripe.BillingMeterParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return false, fmt.Errorf("failed to get meter %s: %w", id, err)
+	}
+	return m.Status == stripe.BillingMeterStatusActive, nil
+}
+
+// newProduct, newPrice, newCoupon, newPromotionCode and newMeter create an
+// object with createIdempotent and return its ID. params must already carry
+// the context.
+func (c *Client) newProduct(params *stripe.ProductParams, parts ...string) (string, error) {
+	return c.createIdempotent(params.Context, params, parts, func() (string, *stripe.APIResponse, error) {
+		p, err := c.api.Products.New(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return p.ID, p.LastResponse, nil
+	}, c.productLive)
+}
+
+func (c *Client) newPrice(params *stripe.PriceParams, parts ...string) (string, error) {
+	return c.createIdempotent(params.Context, params, parts, func() (string, *stripe.APIResponse, error) {
+		p, err := c.api.Prices.New(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return p.ID, p.LastResponse, nil
+	}, c.priceLive)
+}
+
+func (c *Client) newCoupon(params *stripe.CouponParams, parts ...string) (string, error) {
+	return c.createIdempotent(params.Context, params, parts, func() (string, *stripe.APIResponse, error) {
+		cp, err := c.api.Coupons.New(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return cp.ID, cp.LastResponse, nil
+	}, c.couponLive)
+}
+
+func (c *Client) newPromotionCode(params *stripe.PromotionCodeParams, parts ...string) (string, error) {
+	return c.createIdempotent(params.Context, params, parts, func() (string, *stripe.APIResponse, error) {
+		pc, err := c.api.PromotionCodes.New(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return pc.ID, pc.LastResponse, nil
+	}, c.promotionCodeLive)
+}
+
+func (c *Client) newMeter(params *stripe.BillingMeterParams, parts ...string) (string, error) {
+	return c.createIdempotent(params.Context, params, parts, func() (string, *stripe.APIResponse, error) {
+		m, err := c.api.BillingMeters.New(params)
+		if err != nil {
+			return "", nil, err
+		}
+		return m.ID, m.LastResponse, nil
+	}, c.meterLive)
+}
@@ -1,7 +1,12 @@
 package stripe
 
 import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"raterunner/internal/config"
 )
@@ -13,8 +18,12 @@ type ImportResult struct {
 }
 
 // Import fetches all products and prices from Stripe and converts to BillingConfig and ProviderConfig
-func (c *Client) Import() (*ImportResult, error) {
-	products, err := c.FetchProductsWithPrices()
+func (c *Client) Import(ctx context.Context) (*ImportResult, error) {
+	products, err := c.FetchProductsWithPrices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	meters, err := c.FetchMeters(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -32,10 +41,17 @@ func (c *Client) Import() (*ImportResult, error) {
 		Plans:       make(map[string]config.PlanIDs),
 	}
 
+	// Metered prices reference their meter by key
+	meterKeys := importMeters(billing, provider, meters)
+
 	for _, prod := range products {
 		if !prod.Active {
 			continue
 		}
+		if prod.CreditCode != "" {
+			c.importCreditPack(billing, provider, prod)
+			continue
+		}
 
 		planID := planIDFromProduct(prod)
 		plan := config.Plan{
@@ -57,6 +73,10 @@ func (c *Client) Import() (*ImportResult, error) {
 			if !p.Active {
 				continue
 			}
+			if p.Region != "" {
+				importRegionalPrice(&plan, &planIDs, p, meterKeys)
+				continue
+			}
 			if p.Interval == "" {
 				// One-time price
 				plan.Prices["one_time"] = config.Price{
@@ -66,9 +86,7 @@ func (c *Client) Import() (*ImportResult, error) {
 				hasOneTime = true
 			} else {
 				// Recurring price
-				plan.Prices[p.Interval] = config.Price{
-					Amount: int(p.Amount),
-				}
+				plan.Prices[p.Interval] = importedPrice(p, meterKeys)
 				planIDs.Prices[p.Interval] = p.ID
 				hasRecurring = true
 			}
@@ -102,3 +120,119 @@ func planIDFromProduct(prod Product) string {
 	// Fallback: normalize product name
 	return normalizeName(prod.Name)
 }
+
+// importCreditPack adds a credit pack product with its active one-time price,
+// declaring its credit entitlement when the imported config doesn't have it
+func (c *Client) importCreditPack(billing *config.BillingConfig, provider *config.ProviderConfig, prod Product) {
+	var price *ProductPrice
+	for i, p := range prod.Prices {
+		if p.Active && p.Interval == "" {
+			price = &prod.Prices[i]
+			break
+		}
+	}
+	if price == nil {
+		return
+	}
+
+	entitlement, _ := c.metaValue(prod.Metadata, "credit_entitlement")
+	credits, _ := c.metaValue(prod.Metadata, "credits")
+	expiresDays, _ := c.metaValue(prod.Metadata, "credit_expires_days")
+	pack := config.CreditPack{
+		ID:          prod.CreditCode,
+		Name:        prod.Name,
+		Entitlement: entitlement,
+		Amount:      int(price.Amount),
+	}
+	// Unparsable values are left at zero for the user to fill in
+	pack.Credits, _ = strconv.Atoi(credits)
+	pack.ExpiresDays, _ = strconv.Atoi(expiresDays)
+	billing.Credits = append(billing.Credits, pack)
+
+	if entitlement != "" {
+		if billing.Entitlements == nil {
+			billing.Entitlements = make(map[string]config.Entitlement)
+		}
+		if _, ok := billing.Entitlements[entitlement]; !ok {
+			billing.Entitlements[entitlement] = config.Entitlement{Type: "credit"}
+		}
+	}
+
+	if provider.Credits == nil {
+		provider.Credits = make(map[string]config.ProductIDs)
+	}
+	provider.Credits[pack.ID] = config.ProductIDs{ProductID: prod.ID, PriceID: price.ID}
+}
+
+// importMeters adds the active meters, keyed by their event name, and
+// returns the key of each meter ID
+func importMeters(billing *config.BillingConfig, provider *config.ProviderConfig, meters []Meter) map[string]string {
+	keys := make(map[string]string)
+	for _, m := range meters {
+		if !m.Active {
+			continue
+		}
+		key := meterKey(m.EventName)
+		for n := 2; billing.Meters[key].EventName != ""; n++ {
+			key = fmt.Sprintf("%s_%d", meterKey(m.EventName), n)
+		}
+		if billing.Meters == nil {
+			billing.Meters = make(map[string]config.Meter)
+			provider.Meters = make(map[string]string)
+		}
+		meter := config.Meter{DisplayName: m.DisplayName, EventName: m.EventName}
+		if m.Aggregation != config.MeterSum {
+			meter.Aggregation = m.Aggregation
+		}
+		billing.Meters[key] = meter
+		provider.Meters[key] = m.ID
+		keys[m.ID] = key
+	}
+	return keys
+}
+
+// meterKey turns an event name into a meter key: lowercase letters, digits
+// and underscores, starting with a letter
+func meterKey(eventName string) string {
+	key := strings.Trim(strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, eventName), "_")
+	if key == "" || key[0] < 'a' || key[0] > 'z' {
+		key = "meter_" + key
+	}
+	return key
+}
+
+// importedPrice converts a recurring price; a price billing a known meter is
+// imported as a metered per-unit price
+func importedPrice(p ProductPrice, meterKeys map[string]string) config.Price {
+	if key, ok := meterKeys[p.MeterID]; ok && p.MeterID != "" {
+		return config.Price{PerUnit: int(p.Amount), Meter: key}
+	}
+	return config.Price{Amount: int(p.Amount)}
+}
+
+// importRegionalPrice adds a price tagged with a region to that region of the
+// plan, taking the region's currency from the price
+func importRegionalPrice(plan *config.Plan, ids *config.PlanIDs, p ProductPrice, meterKeys map[string]string) {
+	interval := p.Interval
+	if interval == "" {
+		interval = "one_time"
+	}
+	if plan.Regions == nil {
+		plan.Regions = make(map[string]config.Region)
+		ids.Regions = make(map[string]map[string]string)
+	}
+	region, ok := plan.Regions[p.Region]
+	if !ok {
+		region = config.Region{Currency: p.Currency, Prices: make(map[string]config.Price)}
+		ids.Regions[p.Region] = make(map[string]string)
+	}
+	region.Prices[interval] = importedPrice(p, meterKeys)
+	plan.Regions[p.Region] = region
+	ids.Regions[p.Region][interval] = p.ID
+}
@@ -0,0 +1,25 @@
+package stripe
+
+// listPageSize is the most objects Stripe returns per list page. List
+// iterators request the next page after the last object of the previous one
+// until Stripe reports there are no more.
+const listPageSize = "100"
+
+// listIter is the part of stripe-go's list iterators that listAll uses
+type listIter interface {
+	Next() bool
+	Current() interface{}
+	Err() error
+}
+
+// listAll reads every page of a list before returning. Callers that change
+// the objects they list use it instead of changing them while paging: the
+// next page starts after the last object listed, and Stripe rejects that
+// cursor once the object is deleted.
+func listAll[T any](iter listIter) ([]T, error) {
+	var objects []T
+	for iter.Next() {
+		objects = append(objects, iter.Current().(T))
+	}
+	return objects, iter.Err()
+}
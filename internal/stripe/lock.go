@@ -0,0 +1,161 @@
+package stripe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"raterunner/internal/lock"
+)
+
+// The Stripe-side lock lives in the metadata of a dedicated customer, the one
+// object type raterunner never syncs, archives or deletes
+const (
+	lockCustomerEmail = "apply-lock@raterunner.invalid"
+	lockHolderKey     = "raterunner_lock"
+	lockTokenKey      = "raterunner_lock_token"
+)
+
+// AcquireLock takes the account-wide lock shared by everyone applying to this
+// Stripe account, failing with *lock.HeldError while another unexpired run
+// holds it. Stripe has no compare-and-set, so the lock is written and read
+// back: of two runs racing within the same moment, the later writer wins and
+// the other fails. It returns the token ReleaseLock needs, and the previous
+// holder when an expired or forced lock was taken over.
+func (c *Client) AcquireLock(ctx context.Context, holder lock.Holder, force bool) (string, *lock.Holder, error) {
+	customer, err := c.lockCustomer(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var previous *lock.Holder
+	if raw := customer.Metadata[lockHolderKey]; raw != "" {
+		var current lock.Holder
+		if err := json.Unmarshal([]byte(raw), &current); err != nil {
+			current = lock.Holder{Owner: "unknown"}
+		}
+		if !force && !current.Expired(holder.Acquired) {
+			return "", nil, &lock.HeldError{Name: "Stripe " + string(c.env), Holder: current}
+		}
+		previous = &current
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return "", nil, err
+	}
+	content, err := json.Marshal(holder)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal lock: %w", err)
+	}
+
+	params := &stripe.CustomerParams{Params: stripe.Params{Context: ctx}}
+	params.AddMetadata(lockHolderKey, string(content))
+	params.AddMetadata(lockTokenKey, token)
+	if _, err := c.api.Customers.Update(customer.ID, params); err != nil {
+		return "", nil, fmt.Errorf("failed to write Stripe lock: %w", err)
+	}
+
+	// Read back: a concurrent run may have written in between
+	customer, err = c.api.Customers.Get(customer.ID, &stripe.CustomerParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read Stripe lock: %w", err)
+	}
+	if customer.Metadata[lockTokenKey] != token {
+		var winner lock.Holder
+		_ = json.Unmarshal([]byte(customer.Metadata[lockHolderKey]), &winner)
+		return "", nil, &lock.HeldError{Name: "Stripe " + string(c.env), Holder: winner}
+	}
+
+	c.logProgress("acquired Stripe lock on customer %s", customer.ID)
+	return token, previous, nil
+}
+
+// RefreshLock records holder, with its Refreshed time, as the holder of the
+// Stripe-side lock, so a run longer than lock.TTL keeps it. It fails with
+// *lock.LostError when another run has taken the lock over since.
+func (c *Client) RefreshLock(ctx context.Context, token string, holder lock.Holder) error {
+	customer, err := c.lockCustomer(ctx)
+	if err != nil {
+		return err
+	}
+	if customer.Metadata[lockTokenKey] != token {
+		var current lock.Holder
+		if err := json.Unmarshal([]byte(customer.Metadata[lockHolderKey]), &current); err != nil {
+			current = lock.Holder{Owner: "unknown"}
+		}
+		return &lock.LostError{Name: "Stripe " + string(c.env), Holder: current}
+	}
+
+	content, err := json.Marshal(holder)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lock: %w", err)
+	}
+	params := &stripe.CustomerParams{Params: stripe.Params{Context: ctx}}
+	params.AddMetadata(lockHolderKey, string(content))
+	if _, err := c.api.Customers.Update(customer.ID, params); err != nil {
+		return fmt.Errorf("failed to refresh Stripe lock: %w", err)
+	}
+	c.logProgress("refreshed Stripe lock on customer %s", customer.ID)
+	return nil
+}
+
+// ReleaseLock clears the Stripe-side lock if it is still held with token
+func (c *Client) ReleaseLock(ctx context.Context, token string) error {
+	customer, err := c.lockCustomer(ctx)
+	if err != nil {
+		return err
+	}
+	if customer.Metadata[lockTokenKey] != token {
+		// Taken over with --force-unlock or after expiry: it isn't ours to clear
+		return nil
+	}
+
+	params := &stripe.CustomerParams{Params: stripe.Params{Context: ctx}}
+	params.AddMetadata(lockHolderKey, "")
+	params.AddMetadata(lockTokenKey, "")
+	if _, err := c.api.Customers.Update(customer.ID, params); err != nil {
+		return fmt.Errorf("failed to release Stripe lock: %w", err)
+	}
+	c.logProgress("released Stripe lock on customer %s", customer.ID)
+	return nil
+}
+
+// lockCustomer finds the lock customer, creating it on first use
+func (c *Client) lockCustomer(ctx context.Context) (*stripe.Customer, error) {
+	params := &stripe.CustomerListParams{Email: stripe.String(lockCustomerEmail)}
+	params.Context = ctx
+	params.Filters.AddFilter("limit", "", "1")
+
+	iter := c.api.Customers.List(params)
+	if iter.Next() {
+		return iter.Customer(), nil
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up Stripe lock: %w", err)
+	}
+
+	customer, err := c.api.Customers.New(&stripe.CustomerParams{
+		Params:      stripe.Params{Context: ctx},
+		Email:       stripe.String(lockCustomerEmail),
+		Name:        stripe.String("Raterunner apply lock"),
+		Description: stripe.String("Holds the lock raterunner takes during apply and truncate. Do not delete."),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Stripe lock customer: %w", err)
+	}
+	c.logProgress("created Stripe lock customer %s", customer.ID)
+	return customer, nil
+}
+
+func newLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,45 @@
+package stripe
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"raterunner/internal/redact"
+)
+
+// Logger receives progress messages (plans synced, objects created) in verbose mode
+type Logger func(format string, args ...any)
+
+// SetLogger enables progress messages for this client
+func (c *Client) SetLogger(l Logger) {
+	c.logf = l
+}
+
+// logProgress emits a progress message if a logger is set
+func (c *Client) logProgress(format string, args ...any) {
+	if c.logf != nil {
+		c.logf(format, args...)
+	}
+}
+
+// SetRequestLogging routes stripe-go's request-level logs (method, path, timings,
+// response bodies) to w with secrets redacted. Must be called before creating clients.
+func SetRequestLogging(w io.Writer) {
+	stripe.DefaultLeveledLogger = &redactingLogger{w: w}
+}
+
+// redactingLogger implements stripe.LeveledLoggerInterface
+type redactingLogger struct {
+	w io.Writer
+}
+
+func (l *redactingLogger) write(level, format string, v ...any) {
+	fmt.Fprintf(l.w, "[stripe %s] %s\n", level, redact.Secrets(fmt.Sprintf(format, v...)))
+}
+
+func (l *redactingLogger) Debugf(format string, v ...any) { l.write("debug", format, v...) }
+func (l *redactingLogger) Infof(format string, v ...any)  { l.write("info", format, v...) }
+func (l *redactingLogger) Warnf(format string, v ...any)  { l.write("warn", format, v...) }
+func (l *redactingLogger) Errorf(format string, v ...any) { l.write("error", format, v...) }
@@ -0,0 +1,129 @@
+package stripe
+
+import (
+	"context"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"raterunner/internal/config"
+)
+
+// SetMetadataKeys sets the stored key for each managed metadata field, as
+// returned by config.BillingConfig.MetadataKeys (settings.metadata_prefix and
+// metadata_mapping). With migrate, existing objects that still carry keys under
+// their default names are rewritten during sync.
+func (c *Client) SetMetadataKeys(keys map[string]string, migrate bool) {
+	c.metaKeys = keys
+	c.migrateMeta = migrate
+}
+
+// SetCatalogVersion sets the catalog_version that sync stamps on every plan and
+// addon product, as built by config.CatalogVersion
+func (c *Client) SetCatalogVersion(version string) {
+	c.catalogVersion = version
+}
+
+// SetSerial makes sync keep the config's plan order instead of syncing the
+// plans named in upgrades_to first. References to plans later in the config
+// are then written in a second pass, which also works for reference cycles.
+func (c *Client) SetSerial(serial bool) {
+	c.serial = serial
+}
+
+// metaKey returns the stored name of a managed metadata field, or "" when the
+// field is omitted
+func (c *Client) metaKey(name string) string {
+	if key, ok := c.metaKeys[name]; ok {
+		return key
+	}
+	return name
+}
+
+// metaValue reads a managed metadata field, falling back to its default name
+// for objects synced before the key was renamed
+func (c *Client) metaValue(metadata map[string]string, name string) (string, bool) {
+	if key := c.metaKey(name); key != "" {
+		if v, ok := metadata[key]; ok {
+			return v, true
+		}
+	}
+	v, ok := metadata[name]
+	return v, ok
+}
+
+// storedMetadata returns managed metadata under its stored keys, dropping omitted fields
+func (c *Client) storedMetadata(metadata map[string]string) map[string]string {
+	out := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if key := c.metaKey(k); key != "" {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// unmigratedKeys returns the managed fields stored under their default name
+// even though they are now renamed or omitted
+func (c *Client) unmigratedKeys(metadata map[string]string) []string {
+	var keys []string
+	for _, k := range config.MetadataFields {
+		if _, ok := metadata[k]; ok && c.metaKey(k) != k {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// migrateProductMetadata moves a product's managed keys from their default names
+// to their stored keys. It does nothing unless migration was requested.
+func (c *Client) migrateProductMetadata(ctx context.Context, p Product, result *SyncResult) error {
+	keys := c.unmigratedKeys(p.Metadata)
+	if !c.migrateMeta || len(keys) == 0 {
+		return nil
+	}
+
+	params := &stripe.ProductParams{Params: stripe.Params{Context: ctx}}
+	for _, k := range keys {
+		if key := c.metaKey(k); key != "" {
+			if _, ok := p.Metadata[key]; !ok {
+				params.AddMetadata(key, p.Metadata[k])
+			}
+		}
+		// An empty value removes the key from Stripe metadata
+		params.AddMetadata(k, "")
+	}
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return err
+	}
+	result.MetadataMigrated++
+	c.logProgress("product %s: migrated %d metadata key(s)", p.ID, len(keys))
+	return nil
+}
+
+// migrateCouponMetadata removes stacking keys stored under their default names
+// from an existing coupon whose stored keys have just been written
+func (c *Client) migrateCouponMetadata(ctx context.Context, couponID string, result *SyncResult) error {
+	if !c.migrateMeta {
+		return nil
+	}
+
+	existing, err := c.api.Coupons.Get(couponID, &stripe.CouponParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return err
+	}
+	keys := c.unmigratedKeys(existing.Metadata)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	params := &stripe.CouponParams{Params: stripe.Params{Context: ctx}}
+	for _, k := range keys {
+		params.AddMetadata(k, "")
+	}
+	if _, err := c.api.Coupons.Update(couponID, params); err != nil {
+		return err
+	}
+	result.MetadataMigrated++
+	c.logProgress("coupon %s: migrated %d metadata key(s)", couponID, len(keys))
+	return nil
+}
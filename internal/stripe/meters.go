@@ -0,0 +1,120 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"raterunner/internal/config"
+)
+
+// Meter is a Stripe billing meter
+type Meter struct {
+	ID          string
+	DisplayName string
+	EventName   string
+	Aggregation string // "sum", "count" or "last"
+	Active      bool
+}
+
+// FetchMeters lists the account's billing meters, active and inactive
+func (c *Client) FetchMeters(ctx context.Context) ([]Meter, error) {
+	params := &stripe.BillingMeterListParams{}
+	params.Context = ctx
+	params.Filters.AddFilter("limit", "", listPageSize)
+
+	var meters []Meter
+	iter := c.api.BillingMeters.List(params)
+	for iter.Next() {
+		m := iter.BillingMeter()
+		meter := Meter{
+			ID:          m.ID,
+			DisplayName: m.DisplayName,
+			EventName:   m.EventName,
+			Active:      m.Status == stripe.BillingMeterStatusActive,
+		}
+		if m.DefaultAggregation != nil {
+			meter.Aggregation = string(m.DefaultAggregation.Formula)
+		}
+		meters = append(meters, meter)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list meters: %w", err)
+	}
+	return meters, nil
+}
+
+// MatchMeter finds the meter of an event name. Meters have no metadata, and
+// Stripe keeps event names unique, so the event name identifies the meter.
+// An active meter wins over an inactive one.
+func MatchMeter(meters []Meter, eventName string) *Meter {
+	var inactive *Meter
+	for i := range meters {
+		if meters[i].EventName != eventName {
+			continue
+		}
+		if meters[i].Active {
+			return &meters[i]
+		}
+		if inactive == nil {
+			inactive = &meters[i]
+		}
+	}
+	return inactive
+}
+
+// syncMeter creates a meter, or reactivates and renames an existing one.
+// Stripe doesn't allow changing the aggregation of a meter, so a differing
+// one is only warned about.
+func (c *Client) syncMeter(ctx context.Context, key string, meter config.Meter, existing []Meter, result *SyncResult) error {
+	aggregation := meter.AggregationOrDefault()
+
+	m := MatchMeter(existing, meter.EventName)
+	if m == nil {
+		params := &stripe.BillingMeterParams{
+			DisplayName:        stripe.String(meter.DisplayName),
+			EventName:          stripe.String(meter.EventName),
+			DefaultAggregation: &stripe.BillingMeterDefaultAggregationParams{Formula: stripe.String(aggregation)},
+		}
+		params.Context = ctx
+		id, err := c.newMeter(params, "meter", key)
+		if err != nil {
+			return fmt.Errorf("failed to create meter: %w", err)
+		}
+		result.MetersCreated++
+		result.MeterIDs[key] = id
+		c.logProgress("meter '%s': created meter %s for '%s' events", key, id, meter.EventName)
+		return nil
+	}
+
+	if !m.Active {
+		_, err := c.api.BillingMeters.Reactivate(m.ID, &stripe.BillingMeterReactivateParams{Params: stripe.Params{Context: ctx}})
+		if err != nil {
+			return fmt.Errorf("failed to reactivate meter %s: %w", m.ID, err)
+		}
+		c.logProgress("meter '%s': reactivated meter %s", key, m.ID)
+	}
+	if m.DisplayName != meter.DisplayName {
+		_, err := c.api.BillingMeters.Update(m.ID, &stripe.BillingMeterParams{
+			Params:      stripe.Params{Context: ctx},
+			DisplayName: stripe.String(meter.DisplayName),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update meter %s: %w", m.ID, err)
+		}
+		c.logProgress("meter '%s': renamed meter %s to '%s'", key, m.ID, meter.DisplayName)
+	}
+	if m.Aggregation != aggregation {
+		result.warn(Warning{
+			Code:    WarnMeterDiffers,
+			Meter:   key,
+			Local:   aggregation,
+			Remote:  m.Aggregation,
+			Message: fmt.Sprintf("meter '%s': aggregation differs (local=%s, stripe=%s); Stripe can't change it, use a new event_name to start a new meter", key, aggregation, m.Aggregation),
+		})
+	}
+
+	result.MeterIDs[key] = m.ID
+	return nil
+}
@@ -0,0 +1,131 @@
+package stripe
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// promoCodeAlphabet leaves out characters that are easy to misread (0/O, 1/I/L)
+const promoCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// promoCodeRetries is how often a code that already exists in Stripe is
+// replaced with a fresh one before giving up
+const promoCodeRetries = 3
+
+// promoPrefixPattern limits prefixes to what Stripe accepts in a code
+var promoPrefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]*$`)
+
+// PromoCodeOptions configures generated promotion codes
+type PromoCodeOptions struct {
+	ExpiresAt        time.Time // zero = no expiry
+	NewCustomersOnly bool
+}
+
+// GeneratedCode is a single-use promotion code created by GeneratePromotionCodes
+type GeneratedCode struct {
+	Code      string
+	ID        string
+	Coupon    string
+	ExpiresAt time.Time
+}
+
+// NewPromoCodes returns count distinct random codes of prefix followed by
+// length characters
+func NewPromoCodes(prefix string, count, length int) ([]string, error) {
+	if !promoPrefixPattern.MatchString(prefix) {
+		return nil, fmt.Errorf("invalid prefix '%s' (use letters, digits, '-' and '_')", prefix)
+	}
+	if length < 4 {
+		return nil, fmt.Errorf("code length must be at least 4, got %d", length)
+	}
+
+	seen := make(map[string]bool, count)
+	codes := make([]string, 0, count)
+	for len(codes) < count {
+		code, err := randomCode(prefix, length)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	return codes, nil
+}
+
+// randomCode returns prefix followed by length random characters
+func randomCode(prefix string, length int) (string, error) {
+	b := make([]byte, length)
+	max := big.NewInt(int64(len(promoCodeAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate code: %w", err)
+		}
+		b[i] = promoCodeAlphabet[n.Int64()]
+	}
+	return prefix + string(b), nil
+}
+
+// GeneratePromotionCodes creates a single-use promotion code for each of codes
+// against an existing coupon. A code that already exists in Stripe is replaced
+// with a new random one of the same prefix and length. The codes created
+// before an error are returned with it.
+func (c *Client) GeneratePromotionCodes(ctx context.Context, coupon, prefix string, codes []string, opts PromoCodeOptions) ([]GeneratedCode, error) {
+	if _, err := c.api.Coupons.Get(coupon, &stripe.CouponParams{Params: stripe.Params{Context: ctx}}); err != nil {
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && stripeErr.HTTPStatusCode == 404 {
+			return nil, fmt.Errorf("coupon '%s' not found (run 'raterunner apply' for its promotion first)", coupon)
+		}
+		return nil, fmt.Errorf("failed to get coupon '%s': %w", coupon, err)
+	}
+
+	created := make([]GeneratedCode, 0, len(codes))
+	for _, code := range codes {
+		for attempt := 0; ; attempt++ {
+			params := promoCodeParams(coupon, code, opts)
+			params.Context = ctx
+			id, err := c.newPromotionCode(params, "promotion_code", coupon, code)
+			var stripeErr *stripe.Error
+			if err != nil && errors.As(err, &stripeErr) && stripeErr.Code == stripe.ErrorCodeResourceAlreadyExists && attempt < promoCodeRetries {
+				if code, err = randomCode(prefix, len(code)-len(prefix)); err != nil {
+					return created, err
+				}
+				continue
+			}
+			if err != nil {
+				return created, fmt.Errorf("failed to create promotion code '%s': %w", code, err)
+			}
+			created = append(created, GeneratedCode{Code: code, ID: id, Coupon: coupon, ExpiresAt: opts.ExpiresAt})
+			c.logProgress("coupon '%s': created promotion code %s (%s)", coupon, code, id)
+			break
+		}
+	}
+	return created, nil
+}
+
+// promoCodeParams builds the parameters of one single-use promotion code
+func promoCodeParams(coupon, code string, opts PromoCodeOptions) *stripe.PromotionCodeParams {
+	params := &stripe.PromotionCodeParams{
+		Coupon:         stripe.String(coupon),
+		Code:           stripe.String(code),
+		MaxRedemptions: stripe.Int64(1),
+	}
+	if !opts.ExpiresAt.IsZero() {
+		params.ExpiresAt = stripe.Int64(opts.ExpiresAt.Unix())
+	}
+	if opts.NewCustomersOnly {
+		params.Restrictions = &stripe.PromotionCodeRestrictionsParams{
+			FirstTimeTransaction: stripe.Bool(true),
+		}
+	}
+	return params
+}
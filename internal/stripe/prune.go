@@ -0,0 +1,98 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v82"
+
+	"raterunner/internal/config"
+)
+
+// PrunedProduct is an active product archived by sync because its plan_code
+// is no longer in the config
+type PrunedProduct struct {
+	ProductID      string
+	PlanCode       string
+	Name           string
+	PricesArchived int
+}
+
+// SetPrune makes sync archive active products whose plan_code matches no plan
+// in the config, with their prices
+func (c *Client) SetPrune(prune bool) {
+	c.prune = prune
+}
+
+// Orphaned returns the active products whose plan_code matches neither a plan
+// ID nor a previous ID in cfg. Every plan counts, including ones that aren't
+// synced or aren't available in this environment. Products without a
+// plan_code, such as addons and products of other tools, are never orphaned.
+func Orphaned(cfg *config.BillingConfig, products []Product) []Product {
+	known := make(map[string]bool)
+	for _, plan := range cfg.Plans {
+		known[plan.ID] = true
+		for _, id := range plan.PreviousIDs {
+			known[id] = true
+		}
+	}
+
+	var orphaned []Product
+	for _, p := range products {
+		if p.Active && p.PlanCode != "" && !known[p.PlanCode] {
+			orphaned = append(orphaned, p)
+		}
+	}
+	return orphaned
+}
+
+// OrphanedProducts lists the products an apply with pruning would archive,
+// for dry runs
+func (c *Client) OrphanedProducts(ctx context.Context, cfg *config.BillingConfig) ([]Product, error) {
+	products, _, err := c.CachedProductsWithPrices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Orphaned(cfg, products), nil
+}
+
+// pruneProducts archives the orphaned products among existing, prices first.
+// Products this sync just matched to a plan are kept whatever their plan_code.
+func (c *Client) pruneProducts(ctx context.Context, cfg *config.BillingConfig, existing []Product, result *SyncResult) error {
+	matched := make(map[string]bool)
+	for _, ids := range result.PlanIDs {
+		matched[ids.ProductID] = true
+	}
+
+	for _, p := range Orphaned(cfg, existing) {
+		if matched[p.ID] {
+			continue
+		}
+		pruned := PrunedProduct{ProductID: p.ID, PlanCode: p.PlanCode, Name: p.Name}
+		err := func() error {
+			for _, price := range p.Prices {
+				if !price.Active {
+					continue
+				}
+				if err := c.archivePrice(ctx, price.ID); err != nil {
+					return err
+				}
+				pruned.PricesArchived++
+			}
+			if _, err := c.api.Products.Update(p.ID, &stripe.ProductParams{Params: stripe.Params{Context: ctx}, Active: stripe.Bool(false)}); err != nil {
+				return fmt.Errorf("failed to archive product %s: %w", p.ID, err)
+			}
+			return nil
+		}()
+		result.PricesArchived += pruned.PricesArchived
+		if err != nil {
+			if err := c.fail(ctx, result, "product", p.ID, "", err); err != nil {
+				return err
+			}
+			continue
+		}
+		c.logProgress("plan '%s': archived product %s (no longer in the config)", p.PlanCode, p.ID)
+		result.Pruned = append(result.Pruned, pruned)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package stripe
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryOptions configures how Stripe calls are retried after rate limiting
+// (429) and transient server errors (5xx)
+type RetryOptions struct {
+	MaxAttempts int           // attempts per call, including the first (0 = DefaultRetryAttempts, 1 = no retries)
+	Backoff     time.Duration // delay before the first retry, doubled for each one after it (0 = DefaultRetryBackoff)
+	Jitter      float64       // fraction of each delay that is randomized, 0 to 1
+}
+
+// Defaults used by ConfigureRetries for unset options
+const (
+	DefaultRetryAttempts = 4
+	DefaultRetryBackoff  = 500 * time.Millisecond
+	DefaultRetryJitter   = 0.5
+)
+
+// maxRetryBackoff caps a single delay, however many retries came before it
+const maxRetryBackoff = 30 * time.Second
+
+// retryOptions is used by every client created afterwards
+var retryOptions = RetryOptions{Jitter: DefaultRetryJitter}
+
+// ConfigureRetries replaces the retry options of Stripe clients. Must be
+// called before creating clients.
+func ConfigureRetries(opts RetryOptions) {
+	retryOptions = opts
+}
+
+// retryTransport retries idempotent Stripe calls and counts the retries of
+// its client. Each attempt goes through the shared HTTP client, so the request
+// timeout applies per attempt and connections stay pooled.
+type retryTransport struct {
+	opts RetryOptions
+	logf Logger
+
+	mu      sync.Mutex
+	retries map[int]int // status code (0 = network error) -> retries
+}
+
+func newRetryTransport(opts RetryOptions, logf Logger) *retryTransport {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultRetryAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultRetryBackoff
+	}
+	opts.Jitter = min(max(opts.Jitter, 0), 1)
+	return &retryTransport{opts: opts, logf: logf, retries: make(map[int]int)}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		r := req.Clone(ctx)
+		if attempt > 1 && req.Body != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r.Body = body
+		}
+
+		resp, err := httpClient.Do(r)
+		if attempt >= t.opts.MaxAttempts || !t.retryable(req, resp, err) {
+			return resp, err
+		}
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		delay := t.delay(attempt)
+		t.record(status)
+		t.logf("%s %s: %s, retrying in %s (attempt %d of %d)", req.Method, req.URL.Path, retryReason(status, err), delay.Round(time.Millisecond), attempt+1, t.opts.MaxAttempts)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether a failed call can be sent again: only calls that
+// are safe to repeat (reads, deletes and writes with an idempotency key),
+// and only after rate limiting, a server error or a network error
+func (t *retryTransport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+	if req.Method == http.MethodPost && req.Header.Get("Idempotency-Key") == "" {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	if resp.Header.Get("Stripe-Should-Retry") == "false" {
+		return false
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// delay returns the wait before the retry that follows attempt: the backoff
+// doubled per earlier retry, capped, with its jitter fraction randomized
+func (t *retryTransport) delay(attempt int) time.Duration {
+	d := t.opts.Backoff << (attempt - 1)
+	if d <= 0 || d > maxRetryBackoff {
+		d = maxRetryBackoff
+	}
+	if t.opts.Jitter > 0 {
+		spread := time.Duration(float64(d) * t.opts.Jitter)
+		d = d - spread + time.Duration(rand.Int64N(int64(spread)+1))
+	}
+	return d
+}
+
+func (t *retryTransport) record(status int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.retries[status]++
+}
+
+// counts returns a copy of the retries so far by status code
+func (t *retryTransport) counts() map[int]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make(map[int]int, len(t.retries))
+	for status, n := range t.retries {
+		out[status] = n
+	}
+	return out
+}
+
+// retryReason describes why a call is retried
+func retryReason(status int, err error) string {
+	switch {
+	case err != nil:
+		return err.Error()
+	case status == http.StatusTooManyRequests:
+		return "rate limited (429)"
+	}
+	return fmt.Sprintf("server error (%d)", status)
+}
+
+// warnRetries adds a warning with the calls retried since before, a snapshot
+// taken with counts when the sync started
+func (c *Client) warnRetries(result *SyncResult, before map[int]int) {
+	total := 0
+	var parts []string
+	for status, n := range c.retry.counts() {
+		n -= before[status]
+		if n == 0 {
+			continue
+		}
+		total += n
+		label := "network error"
+		if status != 0 {
+			label = fmt.Sprint(status)
+		}
+		parts = append(parts, fmt.Sprintf("%s x%d", label, n))
+	}
+	if total == 0 {
+		return
+	}
+	sort.Strings(parts)
+	result.warn(Warning{
+		Code:    WarnRequestsRetried,
+		Local:   total,
+		Message: fmt.Sprintf("retried %d Stripe request(s) after rate limiting or transient errors (%s)", total, strings.Join(parts, ", ")),
+	})
+}
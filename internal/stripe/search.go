@@ -0,0 +1,72 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// maxSearchClauses is the most clauses the Search API accepts in one query
+const maxSearchClauses = 10
+
+// SearchProductsWithPrices fetches the active products tagged with one of
+// planCodes in plan_code metadata, with their prices, instead of listing the
+// whole catalog. Products only matched by name are not found. The Search API
+// is eventually consistent, so products changed in the last minute may be
+// missing or stale.
+func (c *Client) SearchProductsWithPrices(ctx context.Context, planCodes []string) ([]Product, error) {
+	keys := []string{"plan_code"}
+	if key := c.metaKey("plan_code"); key != "" && key != "plan_code" {
+		keys = append([]string{key}, keys...)
+	}
+
+	var clauses []string
+	for _, code := range planCodes {
+		for _, key := range keys {
+			clauses = append(clauses, fmt.Sprintf("metadata['%s']:'%s'", searchEscape(key), searchEscape(code)))
+		}
+	}
+
+	// AND and OR can't be combined in one query, so active is checked here
+	var products []Product
+	seen := make(map[string]bool)
+	for start := 0; start < len(clauses); start += maxSearchClauses {
+		end := start + maxSearchClauses
+		if end > len(clauses) {
+			end = len(clauses)
+		}
+		params := &stripe.ProductSearchParams{}
+		params.Context = ctx
+		params.Query = strings.Join(clauses[start:end], " OR ")
+		params.Limit = stripe.Int64(100)
+
+		iter := c.api.Products.Search(params)
+		for iter.Next() {
+			p := iter.Product()
+			if !p.Active || seen[p.ID] {
+				continue
+			}
+			seen[p.ID] = true
+			products = append(products, c.toProduct(p))
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to search products: %w", err)
+		}
+	}
+
+	for i := range products {
+		prices, err := c.FetchPricesForProduct(ctx, products[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		products[i].Prices = prices
+	}
+	return products, nil
+}
+
+// searchEscape escapes a value for a quoted Search API string
+func searchEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
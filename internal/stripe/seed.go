@@ -0,0 +1,123 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// seedMetadataKey marks the customers and subscriptions created by
+// SeedCustomers, holding the run ID
+const seedMetadataKey = "raterunner_seed"
+
+// seedClockPrefix starts the name of every test clock SeedCustomers creates
+const seedClockPrefix = "raterunner seed "
+
+// seedPaymentMethod is Stripe's test Visa card that always succeeds
+const seedPaymentMethod = "pm_card_visa"
+
+// SeedPlan is a plan price that seeded customers subscribe to
+type SeedPlan struct {
+	PlanID    string
+	Interval  string
+	PriceID   string
+	TrialDays int
+
+	// Payment term of plans with collection_method: send_invoice; 0 for
+	// plans charged automatically
+	DaysUntilDue int
+}
+
+// SeedOptions configures SeedCustomers
+type SeedOptions struct {
+	RunID      string // stored in raterunner_seed metadata and customer emails
+	TestClocks bool   // give every customer its own test clock, frozen at the current time
+}
+
+// SeededCustomer is a test customer created by SeedCustomers
+type SeededCustomer struct {
+	CustomerID     string
+	Email          string
+	PlanID         string
+	Interval       string
+	SubscriptionID string
+	Status         string
+	TestClockID    string
+}
+
+// SeedCustomers creates count test customers with a test card, subscribed to
+// plans in turn. It only runs in sandbox. The customers created before an
+// error are returned with it.
+func (c *Client) SeedCustomers(ctx context.Context, count int, plans []SeedPlan, opts SeedOptions) ([]SeededCustomer, error) {
+	if c.env != Sandbox {
+		return nil, fmt.Errorf("seed only runs in sandbox")
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("no plans to subscribe to")
+	}
+
+	seeded := make([]SeededCustomer, 0, count)
+	for i := 0; i < count; i++ {
+		plan := plans[i%len(plans)]
+		customer := SeededCustomer{
+			Email:    fmt.Sprintf("seed-%s-%d@example.com", opts.RunID, i+1),
+			PlanID:   plan.PlanID,
+			Interval: plan.Interval,
+		}
+
+		params := &stripe.CustomerParams{
+			Params:        stripe.Params{Context: ctx},
+			Email:         stripe.String(customer.Email),
+			Name:          stripe.String(fmt.Sprintf("Seed customer %d (%s)", i+1, plan.PlanID)),
+			PaymentMethod: stripe.String(seedPaymentMethod),
+			InvoiceSettings: &stripe.CustomerInvoiceSettingsParams{
+				DefaultPaymentMethod: stripe.String(seedPaymentMethod),
+			},
+		}
+		params.AddMetadata(seedMetadataKey, opts.RunID)
+		if opts.TestClocks {
+			clock, err := c.api.TestHelpersTestClocks.New(&stripe.TestHelpersTestClockParams{
+				Params:     stripe.Params{Context: ctx},
+				FrozenTime: stripe.Int64(time.Now().Unix()),
+				Name:       stripe.String(fmt.Sprintf("%s%s #%d", seedClockPrefix, opts.RunID, i+1)),
+			})
+			if err != nil {
+				return seeded, fmt.Errorf("failed to create test clock: %w", err)
+			}
+			customer.TestClockID = clock.ID
+			params.TestClock = stripe.String(clock.ID)
+		}
+
+		created, err := c.api.Customers.New(params)
+		if err != nil {
+			return seeded, fmt.Errorf("failed to create customer %s: %w", customer.Email, err)
+		}
+		customer.CustomerID = created.ID
+
+		subParams := &stripe.SubscriptionParams{
+			Params:   stripe.Params{Context: ctx},
+			Customer: stripe.String(created.ID),
+			Items:    []*stripe.SubscriptionItemsParams{{Price: stripe.String(plan.PriceID)}},
+		}
+		if plan.TrialDays > 0 {
+			subParams.TrialPeriodDays = stripe.Int64(int64(plan.TrialDays))
+		}
+		if plan.DaysUntilDue > 0 {
+			subParams.CollectionMethod = stripe.String(string(stripe.SubscriptionCollectionMethodSendInvoice))
+			subParams.DaysUntilDue = stripe.Int64(int64(plan.DaysUntilDue))
+		}
+		subParams.AddMetadata(seedMetadataKey, opts.RunID)
+		sub, err := c.api.Subscriptions.New(subParams)
+		if err != nil {
+			return seeded, fmt.Errorf("failed to subscribe customer %s to plan '%s': %w", created.ID, plan.PlanID, err)
+		}
+		customer.SubscriptionID = sub.ID
+		customer.Status = string(sub.Status)
+
+		seeded = append(seeded, customer)
+		c.logProgress("customer %s: subscribed to plan '%s' (%s, %s)", created.ID, plan.PlanID, sub.ID, sub.Status)
+	}
+	return seeded, nil
+}
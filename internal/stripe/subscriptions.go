@@ -0,0 +1,93 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// SubscriptionItem is one price on a live subscription
+type SubscriptionItem struct {
+	SubscriptionID string
+	Status         string // "active" or "trialing"
+	PriceID        string
+	ProductID      string
+	Quantity       int64
+	UnitAmount     int64 // in cents, 0 for tiered prices
+	Tiered         bool
+	Currency       string
+	Interval       string // "month", "year", or "" for one-time
+	IntervalCount  int64
+}
+
+// FetchSubscriptionItems lists the items of all active and trialing subscriptions
+func (c *Client) FetchSubscriptionItems(ctx context.Context) ([]SubscriptionItem, error) {
+	var items []SubscriptionItem
+
+	for _, status := range []stripe.SubscriptionStatus{stripe.SubscriptionStatusActive, stripe.SubscriptionStatusTrialing} {
+		params := &stripe.SubscriptionListParams{Status: stripe.String(string(status))}
+		params.Context = ctx
+		params.Filters.AddFilter("limit", "", listPageSize)
+
+		iter := c.api.Subscriptions.List(params)
+		for iter.Next() {
+			s := iter.Subscription()
+			if s.Items == nil {
+				continue
+			}
+			// The embedded item list holds the first page, which usually covers a plan plus its addons
+			subscriptionItems := s.Items.Data
+			if s.Items.HasMore {
+				all, err := c.subscriptionItems(ctx, s.ID)
+				if err != nil {
+					return nil, err
+				}
+				subscriptionItems = all
+			}
+			for _, si := range subscriptionItems {
+				if si.Price == nil {
+					continue
+				}
+				item := SubscriptionItem{
+					SubscriptionID: s.ID,
+					Status:         string(s.Status),
+					PriceID:        si.Price.ID,
+					Quantity:       si.Quantity,
+					UnitAmount:     si.Price.UnitAmount,
+					Currency:       string(si.Price.Currency),
+					Tiered:         si.Price.BillingScheme == stripe.PriceBillingSchemeTiered,
+				}
+				if si.Price.Product != nil {
+					item.ProductID = si.Price.Product.ID
+				}
+				if si.Price.Recurring != nil {
+					item.Interval = string(si.Price.Recurring.Interval)
+					item.IntervalCount = si.Price.Recurring.IntervalCount
+				}
+				items = append(items, item)
+			}
+		}
+
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list %s subscriptions: %w", status, err)
+		}
+		c.logProgress("fetched %s subscriptions", status)
+	}
+
+	return items, nil
+}
+
+// subscriptionItems lists every item of a subscription whose embedded item
+// list was cut off
+func (c *Client) subscriptionItems(ctx context.Context, subscriptionID string) ([]*stripe.SubscriptionItem, error) {
+	params := &stripe.SubscriptionItemListParams{Subscription: stripe.String(subscriptionID)}
+	params.Context = ctx
+	params.Filters.AddFilter("limit", "", listPageSize)
+
+	items, err := listAll[*stripe.SubscriptionItem](c.api.SubscriptionItems.List(params))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items of subscription %s: %w", subscriptionID, err)
+	}
+	return items, nil
+}
@@ -1,37 +1,100 @@
 package stripe
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/stripe/stripe-go/v82"
-	"github.com/stripe/stripe-go/v82/coupon"
-	"github.com/stripe/stripe-go/v82/price"
-	"github.com/stripe/stripe-go/v82/product"
-	"github.com/stripe/stripe-go/v82/promotioncode"
 
 	"raterunner/internal/config"
 )
 
 // SyncResult contains the results of the sync operation
 type SyncResult struct {
-	ProductsCreated int
-	PricesCreated   int
-	PricesArchived  int
-	AddonsCreated   int
-	CouponsCreated  int
-	PromosCreated   int
-	Warnings        []string
+	ProductsCreated    int
+	PricesCreated      int
+	PricesArchived     int
+	AddonsCreated      int
+	CreditPacksCreated int
+	MetersCreated      int
+	PlansRenamed       int
+	MetadataMigrated   int // objects whose metadata keys were moved under the namespace prefix
+	CouponsCreated     int
+	PromosCreated      int
+	PromosActivated    int // existing codes reactivated inside their window
+	PromosExpired      int // codes deactivated after expires
+	Warnings           []Warning
+
+	// Objects that already existed, split by whether apply had to change them
+	ProductsUpdated   int
+	ProductsUnchanged int
+	PricesUpdated     int
+	PricesUnchanged   int
+
+	// Config entries that were deliberately not synced
+	PlansSkipped  int // sync: false or not available in this environment
+	PromosSkipped int // inactive or not available in this environment
+
+	// Objects that failed to sync with keep-going enabled
+	Errors SyncErrors
+
+	// Products archived with pruning enabled; their prices count toward PricesArchived
+	Pruned []PrunedProduct
 
 	// ID tracking for provider file generation
 	PlanIDs      map[string]PlanIDResult
 	AddonIDs     map[string]AddonIDResult
+	CreditIDs    map[string]AddonIDResult // credit pack -> product and one-time price, like addons
 	PromotionIDs map[string]string
+	MeterIDs     map[string]string // meter key -> meter ID
+	Pending      map[string]string // promotion code -> starts_at
+}
+
+// SetKeepGoing makes sync continue past objects that fail, collecting their
+// errors in SyncResult.Errors instead of stopping at the first one
+func (c *Client) SetKeepGoing(keepGoing bool) {
+	c.keepGoing = keepGoing
+}
+
+// fail stops the sync with the object's error, or records it and carries on
+// in keep-going mode. pointer locates the object in the billing file. A
+// canceled run stops either way, since every later call would fail too.
+func (c *Client) fail(ctx context.Context, result *SyncResult, kind, id, pointer string, err error) error {
+	syncErr := SyncError{Kind: kind, ID: id, Pointer: pointer, Err: err}.enrich()
+	if !c.keepGoing || ctx.Err() != nil {
+		return syncErr
+	}
+	c.logProgress("%s '%s': failed, continuing: %v", kind, id, describeError(err))
+	result.Errors = append(result.Errors, syncErr)
+	return nil
+}
+
+// countProduct records an existing product as updated or unchanged
+func countProduct(updated bool, result *SyncResult) {
+	if updated {
+		result.ProductsUpdated++
+	} else {
+		result.ProductsUnchanged++
+	}
+}
+
+// countPrice records a reused price as updated or unchanged
+func countPrice(updated bool, result *SyncResult) {
+	if updated {
+		result.PricesUpdated++
+	} else {
+		result.PricesUnchanged++
+	}
 }
 
 // PlanIDResult contains Stripe IDs for a synced plan
 type PlanIDResult struct {
 	ProductID string
-	Prices    map[string]string // interval -> price_id
+	Prices    map[string]string            // interval -> price_id
+	Regions   map[string]map[string]string // region -> interval -> price_id
 }
 
 // AddonIDResult contains Stripe IDs for a synced addon
@@ -41,48 +104,206 @@ type AddonIDResult struct {
 }
 
 // Sync creates or updates all plans from a billing config in Stripe
-func (c *Client) Sync(cfg *config.BillingConfig) (*SyncResult, error) {
+func (c *Client) Sync(ctx context.Context, cfg *config.BillingConfig) (*SyncResult, error) {
 	result := &SyncResult{
 		PlanIDs:      make(map[string]PlanIDResult),
 		AddonIDs:     make(map[string]AddonIDResult),
+		CreditIDs:    make(map[string]AddonIDResult),
 		PromotionIDs: make(map[string]string),
+		MeterIDs:     make(map[string]string),
+		Pending:      make(map[string]string),
 	}
+	defer c.warnRetries(result, c.retry.counts())
 
-	// Fetch existing products once
-	existingProducts, err := c.FetchProductsWithPrices()
+	// Stripe Tax must be active before products are configured for it
+	if cfg.AutomaticTax() {
+		status, err := c.TaxStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if status != TaxActive {
+			return nil, fmt.Errorf("settings.automatic_tax is enabled but Stripe Tax is not active on this account (status: %s); run 'raterunner doctor' for details", status)
+		}
+	}
+
+	// Fetch existing products once, never from the cache
+	c.dropProductCache()
+	existingProducts, err := c.FetchProductsWithPrices(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch existing products: %w", err)
 	}
 
+	// Sync meters first, so metered prices can reference them
+	if len(cfg.Meters) > 0 {
+		existingMeters, err := c.FetchMeters(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range cfg.MeterKeys() {
+			if err := c.syncMeter(ctx, key, cfg.Meters[key], existingMeters, result); err != nil {
+				if err := c.fail(ctx, result, "meter", key, "/meters/"+key, err); err != nil {
+					return result, err
+				}
+			}
+		}
+	}
+
 	// Sync plans (skip plans not targeting Stripe)
-	for _, plan := range cfg.Plans {
+	var plans []config.Plan
+	synced := make(map[string]bool)
+	planIndex := make(map[string]int)
+	for i, plan := range cfg.Plans {
+		planIndex[plan.ID] = i
 		if !plan.HasProvider("stripe", cfg.Providers) {
 			continue
 		}
-		if err := c.syncPlan(plan, existingProducts, result); err != nil {
-			return result, fmt.Errorf("failed to sync plan '%s': %w", plan.ID, err)
+		if !plan.IsSynced() {
+			c.logProgress("plan '%s': skipped (not synced to Stripe)", plan.ID)
+			result.PlansSkipped++
+			continue
+		}
+		if !plan.InEnvironment(string(c.env)) {
+			c.logProgress("plan '%s': skipped (not available in %s)", plan.ID, c.env)
+			result.PlansSkipped++
+			continue
+		}
+		plans = append(plans, plan)
+		synced[plan.ID] = true
+	}
+
+	// upgrades_to and downgrades_to metadata hold the targets' product IDs, so
+	// upgrade targets go first. Downgrade targets usually come later and are
+	// written in the second pass below.
+	if !c.serial {
+		if plans, err = config.SortPlansByReferences(plans); err != nil {
+			return nil, fmt.Errorf("%w; apply with --serial to write these references in a second pass", err)
+		}
+	}
+
+	var deferred []config.Plan
+	pendingRefs := make(map[string][]string)
+	for _, plan := range plans {
+		c.logProgress("plan '%s': syncing", plan.ID)
+		refs, pending := planReferences(plan, synced, result)
+		if len(pending) > 0 {
+			deferred = append(deferred, plan)
+			pendingRefs[plan.ID] = pending
+		}
+		if err := c.syncPlan(ctx, cfg, plan, refs, existingProducts, result); err != nil {
+			if err := c.fail(ctx, result, "plan", plan.ID, fmt.Sprintf("/plans/%d", planIndex[plan.ID]), err); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Write references to plans synced after the referencing one
+	for _, plan := range deferred {
+		planIDs, ok := result.PlanIDs[plan.ID]
+		if !ok {
+			continue // the plan failed; its error is already recorded
+		}
+		refs, _ := planReferences(plan, synced, result)
+		for _, field := range pendingRefs[plan.ID] {
+			value, resolved := refs[field]
+			if !resolved {
+				continue // one of its targets failed; its error is already recorded
+			}
+			if err := c.setReference(ctx, planIDs.ProductID, field, value); err != nil {
+				if err := c.fail(ctx, result, "plan", plan.ID, fmt.Sprintf("/plans/%d/%s", planIndex[plan.ID], field), err); err != nil {
+					return result, err
+				}
+			}
 		}
 	}
 
 	// Sync addons
-	for _, addon := range cfg.Addons {
-		if err := c.syncAddon(addon, existingProducts, result); err != nil {
-			return result, fmt.Errorf("failed to sync addon '%s': %w", addon.ID, err)
+	for i, addon := range cfg.Addons {
+		if err := c.syncAddon(ctx, cfg, addon, existingProducts, result); err != nil {
+			if err := c.fail(ctx, result, "addon", addon.ID, fmt.Sprintf("/addons/%d", i), err); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Sync credit packs
+	for i, pack := range cfg.Credits {
+		if err := c.syncCreditPack(ctx, cfg, pack, existingProducts, result); err != nil {
+			if err := c.fail(ctx, result, "credit", pack.ID, fmt.Sprintf("/credits/%d", i), err); err != nil {
+				return result, err
+			}
 		}
 	}
 
 	// Sync promotions
-	for _, promo := range cfg.Promotions {
-		if err := c.syncPromotion(promo, result); err != nil {
-			return result, fmt.Errorf("failed to sync promotion '%s': %w", promo.Code, err)
+	for i, promo := range cfg.Promotions {
+		if !promo.InEnvironment(string(c.env)) {
+			c.logProgress("promotion '%s': skipped (not available in %s)", promo.Code, c.env)
+			result.PromosSkipped++
+			continue
+		}
+		if err := c.syncPromotion(ctx, promo, result); err != nil {
+			if err := c.fail(ctx, result, "promotion", promo.Code, fmt.Sprintf("/promotions/%d", i), err); err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// Archive the products of plans removed from the config
+	if c.prune {
+		if err := c.pruneProducts(ctx, cfg, existingProducts, result); err != nil {
+			return result, err
 		}
 	}
 
+	if len(result.Errors) > 0 {
+		return result, result.Errors
+	}
 	return result, nil
 }
 
-func (c *Client) syncPlan(plan config.Plan, existingProducts []Product, result *SyncResult) error {
-	existingProduct := MatchProduct(existingProducts, plan.ID, plan.Name)
+// referenceFields are the plan fields written to metadata as the product IDs
+// of other plans
+var referenceFields = []string{"upgrades_to", "downgrades_to"}
+
+// planReferences returns the comma-separated product IDs of the synced plans
+// in each of plan's reference fields. Fields with targets that haven't been
+// synced yet are left out and returned as pending.
+func planReferences(plan config.Plan, synced map[string]bool, result *SyncResult) (map[string]string, []string) {
+	refs := make(map[string]string, len(referenceFields))
+	var pending []string
+	for _, field := range referenceFields {
+		targets := plan.UpgradesTo
+		if field == "downgrades_to" {
+			targets = plan.DowngradesTo
+		}
+
+		var ids []string
+		resolved := true
+		for _, target := range targets {
+			if !synced[target] {
+				continue
+			}
+			targetIDs, ok := result.PlanIDs[target]
+			if !ok {
+				resolved = false
+				break
+			}
+			ids = append(ids, targetIDs.ProductID)
+		}
+		if resolved {
+			refs[field] = strings.Join(ids, ",")
+		} else {
+			pending = append(pending, field)
+		}
+	}
+	return refs, pending
+}
+
+// syncPlan creates or updates a plan's product and prices. Only the reference
+// fields in refs are written; Sync writes the others once their targets exist.
+func (c *Client) syncPlan(ctx context.Context, cfg *config.BillingConfig, plan config.Plan, refs map[string]string, existingProducts []Product, result *SyncResult) error {
+	existingProduct := MatchProduct(existingProducts, plan.ID, plan.Name, plan.PreviousIDs...)
+	taxCode := cfg.TaxCode(plan.TaxCode)
 
 	var productID string
 	var existingPrices []ProductPrice
@@ -95,20 +316,90 @@ func (c *Client) syncPlan(plan config.Plan, existingProducts []Product, result *
 	if existingProduct != nil {
 		productID = existingProduct.ID
 		existingPrices = existingProduct.Prices
+		c.logProgress("plan '%s': using existing product %s", plan.ID, productID)
+
+		// Each step reports whether it wrote to the product
+		updated := false
+		track := func(changed bool, err error) error {
+			updated = updated || changed
+			return err
+		}
+
+		if err := track(c.syncMetadataKeys(ctx, *existingProduct, result)); err != nil {
+			return err
+		}
+
+		if oldID := RenamedFrom(existingProduct, plan.ID, plan.PreviousIDs); oldID != "" {
+			if err := c.renamePlanCode(ctx, *existingProduct, oldID, plan.ID); err != nil {
+				return err
+			}
+			result.PlansRenamed++
+			updated = true
+		}
 
 		// Check if name needs update
 		if existingProduct.Name != plan.Name {
-			result.Warnings = append(result.Warnings,
-				fmt.Sprintf("plan '%s': product name differs (local='%s', stripe='%s'), not updating",
-					plan.ID, plan.Name, existingProduct.Name))
+			result.warn(Warning{
+				Code:      WarnNameDiffers,
+				PlanID:    plan.ID,
+				ProductID: existingProduct.ID,
+				Local:     plan.Name,
+				Remote:    existingProduct.Name,
+				Message: fmt.Sprintf("plan '%s': product name differs (local='%s', stripe='%s'), not updating",
+					plan.ID, plan.Name, existingProduct.Name),
+			})
+		}
+
+		if err := track(c.syncTaxCode(ctx, *existingProduct, taxCode)); err != nil {
+			return err
+		}
+		if err := track(c.syncGraceDays(ctx, *existingProduct, cfg.GraceDays())); err != nil {
+			return err
+		}
+		if err := track(c.syncProductMetadata(ctx, *existingProduct, trialMetadata(cfg, plan), "trial")); err != nil {
+			return err
+		}
+		if err := track(c.syncProductMetadata(ctx, *existingProduct, dunningMetadata(cfg.Dunning()), "dunning")); err != nil {
+			return err
+		}
+		if err := track(c.syncProductMetadata(ctx, *existingProduct, invoiceMetadata(cfg, plan), "collection method")); err != nil {
+			return err
+		}
+		if err := track(c.syncPublic(ctx, *existingProduct, plan.IsPublic())); err != nil {
+			return err
+		}
+		if err := track(c.syncProvisioning(ctx, *existingProduct, cfg.ProvisioningMetadata(plan), cfg.MetadataPrefix())); err != nil {
+			return err
+		}
+		if err := track(c.syncCatalogVersion(ctx, *existingProduct)); err != nil {
+			return err
+		}
+		if err := track(c.syncDowngradePolicy(ctx, *existingProduct, plan.DowngradePolicy)); err != nil {
+			return err
+		}
+		for _, field := range referenceFields {
+			value, resolved := refs[field]
+			if current, _ := c.metaValue(existingProduct.Metadata, field); !resolved || current == value {
+				continue
+			}
+			if err := c.setReference(ctx, productID, field, value); err != nil {
+				return err
+			}
+			updated = true
 		}
+		countProduct(updated, result)
 	} else {
-		// Create new product with full metadata
+		// Create new product with full metadata. Managed fields are collected under
+		// their default names and stored under the configured keys.
+		meta := map[string]string{
+			"plan_code": plan.ID,
+		}
 		params := &stripe.ProductParams{
 			Name: stripe.String(plan.Name),
-			Metadata: map[string]string{
-				"plan_code": plan.ID,
-			},
+		}
+
+		if taxCode != "" {
+			params.TaxCode = stripe.String(taxCode)
 		}
 
 		// Add description
@@ -118,17 +409,62 @@ func (c *Client) syncPlan(plan config.Plan, existingProducts []Product, result *
 
 		// Add headline to metadata
 		if plan.Headline != "" {
-			params.Metadata["headline"] = plan.Headline
+			meta["headline"] = plan.Headline
 		}
 
 		// Add plan type to metadata
 		if plan.Type != "" {
-			params.Metadata["plan_type"] = plan.Type
+			meta["plan_type"] = plan.Type
 		}
 
+		// Hidden plans are ordinary products in Stripe; the flag tells them apart
+		meta["public"] = strconv.FormatBool(plan.IsPublic())
+
 		// Add billing model to metadata
 		if plan.BillingModel != "" {
-			params.Metadata["billing_model"] = plan.BillingModel
+			meta["billing_model"] = plan.BillingModel
+		}
+
+		// Stripe only supports trial end settings on subscriptions and checkout
+		// sessions, so the plan's policy travels as product metadata
+		for field, value := range trialMetadata(cfg, plan) {
+			if value != "" {
+				meta[field] = value
+			}
+		}
+
+		if plan.DowngradePolicy != "" {
+			meta["downgrade_policy"] = plan.DowngradePolicy
+		}
+		for field, value := range refs {
+			if value != "" {
+				meta[field] = value
+			}
+		}
+
+		// Add grace period to metadata so webhooks can enforce it
+		if graceDays := cfg.GraceDays(); graceDays > 0 {
+			meta["grace_days"] = strconv.Itoa(graceDays)
+		}
+		for field, value := range dunningMetadata(cfg.Dunning()) {
+			if value != "" {
+				meta[field] = value
+			}
+		}
+		for field, value := range invoiceMetadata(cfg, plan) {
+			if value != "" {
+				meta[field] = value
+			}
+		}
+
+		if c.catalogVersion != "" {
+			meta["catalog_version"] = c.catalogVersion
+		}
+		params.Metadata = c.storedMetadata(meta)
+
+		// Provisioning keys are already namespaced and aren't subject to metadata_mapping
+		for k, v := range cfg.ProvisioningMetadata(plan) {
+			params.Metadata[k] = v
 		}
 
 		// Add marketing features
@@ -148,12 +484,14 @@ func (c *Client) syncPlan(plan config.Plan, existingProducts []Product, result *
 			}
 		}
 
-		newProduct, err := product.New(params)
+		params.Context = ctx
+		newProductID, err := c.newProduct(params, "product", plan.ID)
 		if err != nil {
 			return fmt.Errorf("failed to create product: %w", err)
 		}
-		productID = newProduct.ID
+		productID = newProductID
 		result.ProductsCreated++
+		c.logProgress("plan '%s': created product %s", plan.ID, productID)
 	}
 
 	// Record product ID
@@ -161,15 +499,36 @@ func (c *Client) syncPlan(plan config.Plan, existingProducts []Product, result *
 
 	// Sync prices
 	for interval, localPrice := range plan.Prices {
-		priceID, err := c.syncPriceAdvanced(productID, plan.ID, interval, localPrice, plan.TrialDays, existingPrices, result)
+		priceID, err := c.syncPriceAdvanced(ctx, productID, plan.ID, "", "usd", interval, localPrice, plan.TrialDays, cfg.TaxBehavior(), existingPrices, result)
 		if err != nil {
-			return err
+			return &priceError{interval: interval, priceType: localPrice.PriceType(), err: err}
 		}
 		if priceID != "" {
 			planIDResult.Prices[interval] = priceID
 		}
 	}
 
+	// Regional prices sit on the same product, told apart by region metadata
+	for _, name := range plan.RegionNames() {
+		region := plan.Regions[name]
+		for interval, localPrice := range region.Prices {
+			priceID, err := c.syncPriceAdvanced(ctx, productID, plan.ID, name, region.Currency, interval, localPrice, plan.TrialDays, cfg.RegionTaxBehavior(region), existingPrices, result)
+			if err != nil {
+				return &priceError{interval: interval, region: name, priceType: localPrice.PriceType(), err: err}
+			}
+			if priceID == "" {
+				continue
+			}
+			if planIDResult.Regions == nil {
+				planIDResult.Regions = make(map[string]map[string]string)
+			}
+			if planIDResult.Regions[name] == nil {
+				planIDResult.Regions[name] = make(map[string]string)
+			}
+			planIDResult.Regions[name][interval] = priceID
+		}
+	}
+
 	// Record plan IDs
 	result.PlanIDs[plan.ID] = planIDResult
 
@@ -177,32 +536,61 @@ func (c *Client) syncPlan(plan config.Plan, existingProducts []Product, result *
 }
 
 // syncPriceAdvanced creates prices supporting flat, per_unit, and tiered pricing
-// Returns the price ID (either existing or newly created)
-func (c *Client) syncPriceAdvanced(productID, planID, interval string, localPrice config.Price, trialDays int, existingPrices []ProductPrice, result *SyncResult) (string, error) {
+// Returns the price ID (either existing or newly created). region is "" for
+// the plan's default prices; only prices of the same region are reused or
+// archived.
+func (c *Client) syncPriceAdvanced(ctx context.Context, productID, planID, region, currency, interval string, localPrice config.Price, trialDays int, taxBehavior string, existingPrices []ProductPrice, result *SyncResult) (string, error) {
 	priceType := localPrice.PriceType()
+	label := interval
+	if region != "" {
+		label = region + " " + interval
+	}
 
 	// For flat prices, check if exact price already exists
 	if priceType == "flat" {
 		for _, p := range existingPrices {
+			if p.Region != region || !strings.EqualFold(p.Currency, currency) {
+				continue
+			}
 			if p.Interval == interval && p.Amount == int64(localPrice.Amount) && p.Active {
+				c.logProgress("plan '%s' %s: using existing price %s", planID, label, p.ID)
+				changed, err := c.syncTaxBehavior(ctx, p, taxBehavior)
+				if err != nil {
+					return "", err
+				}
+				countPrice(changed, result)
 				return p.ID, nil // Price already exists, return existing ID
 			}
 		}
 
 		// Archive conflicting prices
 		for _, p := range existingPrices {
-			if p.Interval == interval && p.Active && p.Amount != int64(localPrice.Amount) {
-				result.Warnings = append(result.Warnings,
-					fmt.Sprintf("plan '%s' %s: price differs (local=%d, stripe=%d), archiving old and creating new",
-						planID, interval, localPrice.Amount, p.Amount))
+			if p.Region != region {
+				continue
+			}
+			// A region moved to another currency replaces its prices too
+			moved := region != "" && !strings.EqualFold(p.Currency, currency)
+			if p.Interval == interval && p.Active && (p.Amount != int64(localPrice.Amount) || moved) {
+				result.warn(Warning{
+					Code:      WarnPriceDiffers,
+					PlanID:    planID,
+					ProductID: productID,
+					Interval:  interval,
+					Local:     int64(localPrice.Amount),
+					Remote:    p.Amount,
+					Message: fmt.Sprintf("plan '%s' %s: price differs (local=%d, stripe=%d), archiving old and creating new",
+						planID, label, localPrice.Amount, p.Amount),
+				})
 
-				_, err := price.Update(p.ID, &stripe.PriceParams{
+				_, err := c.api.Prices.Update(p.ID, &stripe.PriceParams{
+					Params: stripe.Params{Context: ctx},
 					Active: stripe.Bool(false),
 				})
 				if err != nil {
 					return "", fmt.Errorf("failed to archive old price %s: %w", p.ID, err)
 				}
 				result.PricesArchived++
+				c.logProgress("plan '%s' %s: archived price %s", planID, label, p.ID)
 			}
 		}
 	}
@@ -210,7 +598,13 @@ func (c *Client) syncPriceAdvanced(productID, planID, interval string, localPric
 	// Build price params
 	params := &stripe.PriceParams{
 		Product:  stripe.String(productID),
-		Currency: stripe.String("usd"),
+		Currency: stripe.String(strings.ToLower(currency)),
+	}
+	if taxBehavior != "" {
+		params.TaxBehavior = stripe.String(taxBehavior)
+	}
+	if region != "" {
+		params.Metadata = c.storedMetadata(map[string]string{"region": region})
 	}
 
 	// Set price based on type
@@ -276,60 +670,403 @@ func (c *Client) syncPriceAdvanced(productID, planID, interval string, localPric
 			recurring.TrialPeriodDays = stripe.Int64(int64(trialDays))
 		}
 
-		// For per-unit pricing: use "licensed" (quantity set at subscription time)
-		// "metered" requires a Meter object (for usage reporting)
-		if priceType == "per_unit" {
+		// Metered prices bill the usage reported to their meter; other
+		// per-unit prices are "licensed" (quantity set at subscription time)
+		if localPrice.Meter != "" {
+			meterID, ok := result.MeterIDs[localPrice.Meter]
+			if !ok {
+				return "", fmt.Errorf("meter '%s' was not synced", localPrice.Meter)
+			}
+			recurring.UsageType = stripe.String("metered")
+			recurring.Meter = stripe.String(meterID)
+		} else if priceType == "per_unit" {
 			recurring.UsageType = stripe.String("licensed")
 		}
 
 		params.Recurring = recurring
 	}
 
-	newPrice, err := price.New(params)
+	// Tiered prices are told apart by the hash of their tiers alone
+	amount := priceType
+	if params.UnitAmount != nil {
+		amount = strconv.FormatInt(*params.UnitAmount, 10)
+	}
+	params.Context = ctx
+	parts := []string{"price", planID, interval, amount}
+	if region != "" {
+		parts = []string{"price", planID, region, interval, amount}
+	}
+	newPriceID, err := c.newPrice(params, parts...)
 	if err != nil {
 		return "", fmt.Errorf("failed to create %s price: %w", priceType, err)
 	}
 	result.PricesCreated++
+	c.logProgress("plan '%s' %s: created %s price %s", planID, label, priceType, newPriceID)
+
+	return newPriceID, nil
+}
+
+// trialMetadata flattens the trial policy of a plan with trial_days into
+// product metadata keys. An empty value marks a key to remove.
+func trialMetadata(cfg *config.BillingConfig, plan config.Plan) map[string]string {
+	meta := map[string]string{
+		"trial_require_payment_method": "",
+		"trial_end_behavior":           "",
+		"trial_downgrade_to":           "",
+	}
+	trial := cfg.TrialFor(plan)
+	if trial == nil || plan.TrialDays == 0 {
+		return meta
+	}
+	if trial.RequirePaymentMethod != nil {
+		meta["trial_require_payment_method"] = strconv.FormatBool(*trial.RequirePaymentMethod)
+	}
+	if trial.EndBehavior != "" {
+		meta["trial_end_behavior"] = trial.EndBehavior
+	}
+	if trial.DowngradeTo != "" {
+		meta["trial_downgrade_to"] = trial.DowngradeTo
+	}
+	return meta
+}
+
+// dunningMetadata flattens settings.dunning into product metadata keys. An
+// empty value marks a key to remove.
+func dunningMetadata(d *config.Dunning) map[string]string {
+	meta := map[string]string{
+		"dunning_retry_days":            "",
+		"dunning_cancel_after_failures": "",
+		"dunning_emails":                "",
+	}
+	if d == nil {
+		return meta
+	}
+	days := make([]string, len(d.RetryDays))
+	for i, day := range d.RetryDays {
+		days[i] = strconv.Itoa(day)
+	}
+	meta["dunning_retry_days"] = strings.Join(days, ",")
+	if d.CancelAfterFailures > 0 {
+		meta["dunning_cancel_after_failures"] = strconv.Itoa(d.CancelAfterFailures)
+	}
+	meta["dunning_emails"] = strings.Join(d.Emails.Enabled(), ",")
+	return meta
+}
+
+// invoiceMetadata flattens a plan's collection method into product metadata
+// keys. Plans charged automatically, the default, carry none. An empty value
+// marks a key to remove.
+func invoiceMetadata(cfg *config.BillingConfig, plan config.Plan) map[string]string {
+	meta := map[string]string{
+		"collection_method": "",
+		"days_until_due":    "",
+	}
+	if plan.SendsInvoice() {
+		meta["collection_method"] = config.CollectionSendInvoice
+		meta["days_until_due"] = strconv.Itoa(cfg.DaysUntilDue())
+	}
+	return meta
+}
+
+// syncProductMetadata keeps the metadata fields in meta, such as the dunning
+// settings, of an existing plan product in line with the config. what names
+// them in errors and progress. It reports whether the product was updated.
+func (c *Client) syncProductMetadata(ctx context.Context, p Product, meta map[string]string, what string) (bool, error) {
+	params := &stripe.ProductParams{}
+	changed := false
+	for field, want := range meta {
+		key := c.metaKey(field)
+		if current, _ := c.metaValue(p.Metadata, field); key == "" || current == want {
+			continue
+		}
+		// An empty value removes the key from Stripe metadata
+		params.AddMetadata(key, want)
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return false, fmt.Errorf("failed to update %s metadata on product %s: %w", what, p.ID, err)
+	}
+	c.logProgress("product %s: updated %s metadata", p.ID, what)
+	return true, nil
+}
+
+// syncGraceDays keeps the grace_days metadata of an existing plan product in
+// line with settings. It reports whether the product was updated.
+func (c *Client) syncGraceDays(ctx context.Context, p Product, graceDays int) (bool, error) {
+	want := ""
+	if graceDays > 0 {
+		want = strconv.Itoa(graceDays)
+	}
+	key := c.metaKey("grace_days")
+	if current, _ := c.metaValue(p.Metadata, "grace_days"); key == "" || current == want {
+		return false, nil
+	}
+
+	// An empty value removes the key from Stripe metadata
+	params := &stripe.ProductParams{}
+	params.AddMetadata(key, want)
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return false, fmt.Errorf("failed to update grace_days metadata on product %s: %w", p.ID, err)
+	}
+	c.logProgress("product %s: set grace_days metadata to '%s'", p.ID, want)
+	return true, nil
+}
+
+// syncPublic keeps the public metadata of an existing plan product in line
+// with the plan. It reports whether the product was updated.
+func (c *Client) syncPublic(ctx context.Context, p Product, public bool) (bool, error) {
+	want := strconv.FormatBool(public)
+	key := c.metaKey("public")
+	if current, _ := c.metaValue(p.Metadata, "public"); key == "" || current == want {
+		return false, nil
+	}
+
+	params := &stripe.ProductParams{}
+	params.AddMetadata(key, want)
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return false, fmt.Errorf("failed to update public metadata on product %s: %w", p.ID, err)
+	}
+	c.logProgress("product %s: set public metadata to '%s'", p.ID, want)
+	return true, nil
+}
+
+// syncDowngradePolicy keeps the downgrade_policy metadata of an existing plan
+// product in line with the plan. It reports whether the product was updated.
+func (c *Client) syncDowngradePolicy(ctx context.Context, p Product, policy string) (bool, error) {
+	key := c.metaKey("downgrade_policy")
+	if current, _ := c.metaValue(p.Metadata, "downgrade_policy"); key == "" || current == policy {
+		return false, nil
+	}
+
+	// An empty value removes the key from Stripe metadata
+	params := &stripe.ProductParams{}
+	params.AddMetadata(key, policy)
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return false, fmt.Errorf("failed to update downgrade_policy metadata on product %s: %w", p.ID, err)
+	}
+	c.logProgress("product %s: set downgrade_policy metadata to '%s'", p.ID, policy)
+	return true, nil
+}
+
+// setReference writes the product IDs of a plan's upgrades_to or downgrades_to
+// targets to its product; an empty value removes the key
+func (c *Client) setReference(ctx context.Context, productID, field, value string) error {
+	key := c.metaKey(field)
+	if key == "" {
+		return nil
+	}
+
+	params := &stripe.ProductParams{}
+	params.AddMetadata(key, value)
+	params.Context = ctx
+	if _, err := c.api.Products.Update(productID, params); err != nil {
+		return fmt.Errorf("failed to update %s metadata on product %s: %w", field, productID, err)
+	}
+	c.logProgress("product %s: set %s metadata to '%s'", productID, field, value)
+	return nil
+}
+
+// syncCatalogVersion stamps an existing product with the catalog version being
+// applied, reporting whether it was out of date
+func (c *Client) syncCatalogVersion(ctx context.Context, p Product) (bool, error) {
+	key := c.metaKey("catalog_version")
+	if c.catalogVersion == "" || key == "" || p.CatalogVersion == c.catalogVersion {
+		return false, nil
+	}
+
+	params := &stripe.ProductParams{}
+	params.AddMetadata(key, c.catalogVersion)
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return false, fmt.Errorf("failed to update catalog_version metadata on product %s: %w", p.ID, err)
+	}
+	c.logProgress("product %s: set catalog_version metadata to '%s'", p.ID, c.catalogVersion)
+	return true, nil
+}
+
+// syncProvisioning keeps the provisioning_* metadata of an existing plan product
+// in line with the plan's provisioning block, removing keys that were dropped.
+// It reports whether any key changed.
+func (c *Client) syncProvisioning(ctx context.Context, p Product, want map[string]string, prefix string) (bool, error) {
+	params := &stripe.ProductParams{}
+	changed := 0
+	for k, v := range want {
+		if p.Metadata[k] != v {
+			params.AddMetadata(k, v)
+			changed++
+		}
+	}
+	for k := range p.Metadata {
+		if _, ok := want[k]; !ok && strings.HasPrefix(k, prefix+config.ProvisioningKeyPrefix) {
+			// An empty value removes the key from Stripe metadata
+			params.AddMetadata(k, "")
+			changed++
+		}
+	}
+	if changed == 0 {
+		return false, nil
+	}
+
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return false, fmt.Errorf("failed to update provisioning metadata on product %s: %w", p.ID, err)
+	}
+	c.logProgress("product %s: updated %d provisioning metadata key(s)", p.ID, changed)
+	return true, nil
+}
 
-	return newPrice.ID, nil
+// syncMetadataKeys migrates an existing product's metadata keys that are still
+// stored under their default names, or warns about them when migration wasn't
+// requested. It reports whether the keys were migrated.
+func (c *Client) syncMetadataKeys(ctx context.Context, p Product, result *SyncResult) (bool, error) {
+	keys := c.unmigratedKeys(p.Metadata)
+	if len(keys) == 0 {
+		return false, nil
+	}
+	if !c.migrateMeta {
+		result.warn(Warning{
+			Code:      WarnMetadataUnmigrated,
+			ProductID: p.ID,
+			Local:     keys,
+			Message: fmt.Sprintf("product %s has %d metadata key(s) under their default names (%s); run apply with --migrate-metadata to move them",
+				p.ID, len(keys), strings.Join(keys, ", ")),
+		})
+		return false, nil
+	}
+	if err := c.migrateProductMetadata(ctx, p, result); err != nil {
+		return false, fmt.Errorf("failed to migrate metadata on product %s: %w", p.ID, err)
+	}
+	return true, nil
+}
+
+// renamePlanCode retags a product created under a plan's old ID with its new ID.
+// The old ID is kept in previous_plan_code so webhooks can still resolve it.
+func (c *Client) renamePlanCode(ctx context.Context, p Product, oldID, newID string) error {
+	params := &stripe.ProductParams{}
+	params.AddMetadata(c.metaKey("plan_code"), newID)
+	if key := c.metaKey("previous_plan_code"); key != "" {
+		params.AddMetadata(key, oldID)
+	}
+	params.Context = ctx
+	if _, err := c.api.Products.Update(p.ID, params); err != nil {
+		return fmt.Errorf("failed to rename plan_code on product %s: %w", p.ID, err)
+	}
+	c.logProgress("product %s: renamed plan_code '%s' → '%s'", p.ID, oldID, newID)
+	return nil
 }
 
-func (c *Client) syncAddon(addon config.Addon, existingProducts []Product, result *SyncResult) error {
+func (c *Client) syncAddon(ctx context.Context, cfg *config.BillingConfig, addon config.Addon, existingProducts []Product, result *SyncResult) error {
 	// Addons are products with one-time prices
 	existingProduct := MatchProduct(existingProducts, addon.ID, addon.Name)
+	taxCode := cfg.TaxCode(addon.TaxCode)
+	taxBehavior := cfg.TaxBehavior()
 
 	var productID string
 	var priceID string
 
 	if existingProduct != nil {
 		productID = existingProduct.ID
+		updated := false
+		track := func(changed bool, err error) error {
+			updated = updated || changed
+			return err
+		}
+		if err := track(c.syncMetadataKeys(ctx, *existingProduct, result)); err != nil {
+			return err
+		}
+		if err := track(c.syncTaxCode(ctx, *existingProduct, taxCode)); err != nil {
+			return err
+		}
+		if err := track(c.syncCatalogVersion(ctx, *existingProduct)); err != nil {
+			return err
+		}
+		countProduct(updated, result)
+
 		// Check if one-time price with correct amount exists
-		for _, p := range existingProduct.Prices {
+		var current *ProductPrice
+		for i, p := range existingProduct.Prices {
 			if p.Interval == "" && p.Amount == int64(addon.Price.Amount) && p.Active {
-				// Record existing IDs and return
-				result.AddonIDs[addon.ID] = AddonIDResult{
-					ProductID: productID,
-					PriceID:   p.ID,
-				}
-				return nil // Addon already exists with correct price
+				current = &existingProduct.Prices[i]
+				break
+			}
+		}
+
+		// Archive one-time prices with another amount, so checkout stops selling them
+		for _, p := range existingProduct.Prices {
+			if p.Interval != "" || !p.Active || p.Amount == int64(addon.Price.Amount) {
+				continue
 			}
+			action := "archiving old and creating new"
+			if current != nil {
+				action = "archiving old"
+			}
+			result.warn(Warning{
+				Code:      WarnPriceDiffers,
+				AddonID:   addon.ID,
+				ProductID: productID,
+				Local:     int64(addon.Price.Amount),
+				Remote:    p.Amount,
+				Message: fmt.Sprintf("addon '%s': price differs (local=%d, stripe=%d), %s",
+					addon.ID, addon.Price.Amount, p.Amount, action),
+			})
+
+			_, err := c.api.Prices.Update(p.ID, &stripe.PriceParams{
+				Params: stripe.Params{Context: ctx},
+				Active: stripe.Bool(false),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to archive old addon price %s: %w", p.ID, err)
+			}
+			result.PricesArchived++
+			c.logProgress("addon '%s': archived price %s", addon.ID, p.ID)
+		}
+
+		if current != nil {
+			changed, err := c.syncTaxBehavior(ctx, *current, taxBehavior)
+			if err != nil {
+				return err
+			}
+			countPrice(changed, result)
+			// Record existing IDs and return
+			result.AddonIDs[addon.ID] = AddonIDResult{
+				ProductID: productID,
+				PriceID:   current.ID,
+			}
+			return nil // Addon already exists with correct price
 		}
 	} else {
 		// Create new product for addon
+		meta := map[string]string{
+			"addon_code": addon.ID,
+			"type":       "addon",
+		}
+		if c.catalogVersion != "" {
+			meta["catalog_version"] = c.catalogVersion
+		}
 		params := &stripe.ProductParams{
-			Name: stripe.String(addon.Name),
-			Metadata: map[string]string{
-				"addon_code": addon.ID,
-				"type":       "addon",
-			},
+			Name:     stripe.String(addon.Name),
+			Metadata: c.storedMetadata(meta),
+		}
+		if taxCode != "" {
+			params.TaxCode = stripe.String(taxCode)
 		}
 
-		newProduct, err := product.New(params)
+		params.Context = ctx
+		newProductID, err := c.newProduct(params, "product", addon.ID)
 		if err != nil {
 			return fmt.Errorf("failed to create addon product: %w", err)
 		}
-		productID = newProduct.ID
+		productID = newProductID
 		result.AddonsCreated++
+		c.logProgress("addon '%s': created product %s", addon.ID, productID)
 	}
 
 	// Create one-time price for addon
@@ -338,13 +1075,17 @@ func (c *Client) syncAddon(addon config.Addon, existingProducts []Product, resul
 		UnitAmount: stripe.Int64(int64(addon.Price.Amount)),
 		Currency:   stripe.String("usd"),
 	}
+	if taxBehavior != "" {
+		priceParams.TaxBehavior = stripe.String(taxBehavior)
+	}
 
-	newPrice, err := price.New(priceParams)
+	priceParams.Context = ctx
+	priceID, err := c.newPrice(priceParams, "price", addon.ID, strconv.Itoa(addon.Price.Amount))
 	if err != nil {
 		return fmt.Errorf("failed to create addon price: %w", err)
 	}
-	priceID = newPrice.ID
 	result.PricesCreated++
+	c.logProgress("addon '%s': created price %s", addon.ID, priceID)
 
 	// Record addon IDs
 	result.AddonIDs[addon.ID] = AddonIDResult{
@@ -355,15 +1096,32 @@ func (c *Client) syncAddon(addon config.Addon, existingProducts []Product, resul
 	return nil
 }
 
-func (c *Client) syncPromotion(promo config.Promotion, result *SyncResult) error {
+func (c *Client) syncPromotion(ctx context.Context, promo config.Promotion, result *SyncResult) error {
 	if !promo.IsActive() {
+		result.PromosSkipped++
 		return nil // Skip inactive promotions
 	}
 
+	window, err := promo.Window(time.Now())
+	if err != nil {
+		return err
+	}
+	switch window {
+	case config.PromotionPending:
+		c.logProgress("promotion '%s': pending until %s", promo.Code, promo.StartsAt)
+		result.Pending[promo.Code] = promo.StartsAt
+		return nil
+	case config.PromotionClosed:
+		return c.setPromotionCodesActive(ctx, promo.Code, false, result)
+	}
+
 	// Create coupon in Stripe
 	couponParams := &stripe.CouponParams{
 		ID: stripe.String(promo.Code), // Use code as coupon ID
 	}
+	for k, v := range c.storedMetadata(promo.StackingMetadata()) {
+		couponParams.AddMetadata(k, v)
+	}
 
 	// Set discount type
 	if promo.Discount.Percent > 0 {
@@ -390,19 +1148,40 @@ func (c *Client) syncPromotion(promo config.Promotion, result *SyncResult) error
 		couponParams.MaxRedemptions = stripe.Int64(int64(promo.MaxUses))
 	}
 
-	newCoupon, err := coupon.New(couponParams)
+	couponParams.Context = ctx
+	couponID, err := c.newCoupon(couponParams, "coupon", promo.Code)
 	if err != nil {
 		// Check if coupon already exists
 		if stripeErr, ok := err.(*stripe.Error); ok && stripeErr.Code == stripe.ErrorCodeResourceAlreadyExists {
-			result.Warnings = append(result.Warnings,
-				fmt.Sprintf("coupon '%s' already exists, skipping", promo.Code))
+			result.warn(Warning{
+				Code:      WarnCouponExists,
+				Promotion: promo.Code,
+				Message:   fmt.Sprintf("coupon '%s' already exists, skipping", promo.Code),
+			})
 			// Record coupon ID (same as code since we use code as ID)
 			result.PromotionIDs[promo.Code] = promo.Code
-			return nil
+
+			// Coupons are immutable except for metadata, so keep the stacking rules current
+			updateParams := &stripe.CouponParams{}
+			for k, v := range c.storedMetadata(promo.StackingMetadata()) {
+				updateParams.AddMetadata(k, v)
+			}
+			if key := c.metaKey("excludes"); key != "" && len(promo.Excludes) == 0 {
+				updateParams.AddMetadata(key, "")
+			}
+			updateParams.Context = ctx
+			if _, err := c.api.Coupons.Update(promo.Code, updateParams); err != nil {
+				return fmt.Errorf("failed to update coupon metadata: %w", err)
+			}
+			if err := c.migrateCouponMetadata(ctx, promo.Code, result); err != nil {
+				return fmt.Errorf("failed to migrate coupon metadata: %w", err)
+			}
+			return c.setPromotionCodesActive(ctx, promo.Code, true, result)
 		}
 		return fmt.Errorf("failed to create coupon: %w", err)
 	}
 	result.CouponsCreated++
+	c.logProgress("promotion '%s': created coupon %s", promo.Code, couponID)
 
 	// Create promotion code (the actual code customers enter)
 	promoParams := &stripe.PromotionCodeParams{
@@ -410,28 +1189,73 @@ func (c *Client) syncPromotion(promo config.Promotion, result *SyncResult) error
 		Code:   stripe.String(promo.Code),
 	}
 
+	if expires := promo.ExpiresAt(); !expires.IsZero() {
+		promoParams.ExpiresAt = stripe.Int64(expires.Unix())
+	}
+
 	if promo.NewCustomersOnly {
 		promoParams.Restrictions = &stripe.PromotionCodeRestrictionsParams{
 			FirstTimeTransaction: stripe.Bool(true),
 		}
 	}
 
-	_, err = promotioncode.New(promoParams)
+	promoParams.Context = ctx
+	promoID, err := c.newPromotionCode(promoParams, "promotion_code", promo.Code)
 	if err != nil {
 		// Promotion code might already exist
 		if stripeErr, ok := err.(*stripe.Error); ok && stripeErr.Code == stripe.ErrorCodeResourceAlreadyExists {
-			result.Warnings = append(result.Warnings,
-				fmt.Sprintf("promotion code '%s' already exists, skipping", promo.Code))
+			result.warn(Warning{
+				Code:      WarnPromoCodeExists,
+				Promotion: promo.Code,
+				Message:   fmt.Sprintf("promotion code '%s' already exists, skipping", promo.Code),
+			})
 			// Record coupon ID
-			result.PromotionIDs[promo.Code] = newCoupon.ID
+			result.PromotionIDs[promo.Code] = couponID
 			return nil
 		}
 		return fmt.Errorf("failed to create promotion code: %w", err)
 	}
 	result.PromosCreated++
+	c.logProgress("promotion '%s': created promotion code %s", promo.Code, promoID)
 
 	// Record coupon ID
-	result.PromotionIDs[promo.Code] = newCoupon.ID
+	result.PromotionIDs[promo.Code] = couponID
+
+	return nil
+}
 
+// setPromotionCodesActive deactivates a promotion's codes once its window has
+// closed, or reactivates them while it is open (e.g. after expires was moved)
+func (c *Client) setPromotionCodesActive(ctx context.Context, code string, active bool, result *SyncResult) error {
+	params := &stripe.PromotionCodeListParams{
+		Code:   stripe.String(code),
+		Active: stripe.Bool(!active),
+	}
+	params.Context = ctx
+	params.Filters.AddFilter("limit", "", listPageSize)
+	codes, err := listAll[*stripe.PromotionCode](c.api.PromotionCodes.List(params))
+	if err != nil {
+		return fmt.Errorf("failed to list promotion codes: %w", err)
+	}
+	for _, pc := range codes {
+		_, err := c.api.PromotionCodes.Update(pc.ID, &stripe.PromotionCodeParams{Params: stripe.Params{Context: ctx}, Active: stripe.Bool(active)})
+		switch {
+		case err != nil && active:
+			// Stripe refuses to reactivate a code past its own expires_at
+			result.warn(Warning{
+				Code:      WarnPromoNotReactivated,
+				Promotion: code,
+				Message:   fmt.Sprintf("promotion code '%s' (%s) could not be reactivated: %v", code, pc.ID, err),
+			})
+		case err != nil:
+			return fmt.Errorf("failed to deactivate promotion code %s: %w", pc.ID, err)
+		case active:
+			result.PromosActivated++
+			c.logProgress("promotion '%s': reactivated promotion code %s", code, pc.ID)
+		default:
+			result.PromosExpired++
+			c.logProgress("promotion '%s': deactivated promotion code %s (expired)", code, pc.ID)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,58 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// TaxActive is the Stripe Tax status required for automatic tax
+const TaxActive = string(stripe.TaxSettingsStatusActive)
+
+// TaxStatus returns the account's Stripe Tax status ("active" or "pending")
+func (c *Client) TaxStatus(ctx context.Context) (string, error) {
+	settings, err := c.api.TaxSettings.Get(&stripe.TaxSettingsParams{Params: stripe.Params{Context: ctx}})
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch Stripe Tax settings: %w", err)
+	}
+	return string(settings.Status), nil
+}
+
+// syncTaxCode updates an existing product's tax code when the config sets a different one
+func (c *Client) syncTaxCode(ctx context.Context, p Product, taxCode string) (bool, error) {
+	if taxCode == "" || p.TaxCode == taxCode {
+		return false, nil
+	}
+
+	_, err := c.api.Products.Update(p.ID, &stripe.ProductParams{
+		Params:  stripe.Params{Context: ctx},
+		TaxCode: stripe.String(taxCode),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to set tax code on product %s: %w", p.ID, err)
+	}
+	c.logProgress("product %s: set tax code %s", p.ID, taxCode)
+	return true, nil
+}
+
+// syncTaxBehavior sets the tax behavior on a reused price. Stripe only allows this
+// while the behavior is still unspecified; once set it can't be changed.
+func (c *Client) syncTaxBehavior(ctx context.Context, p ProductPrice, taxBehavior string) (bool, error) {
+	if taxBehavior == "" || p.TaxBehavior == taxBehavior {
+		return false, nil
+	}
+	if p.TaxBehavior != "" && p.TaxBehavior != string(stripe.PriceTaxBehaviorUnspecified) {
+		return false, fmt.Errorf("price %s has tax behavior '%s', which can't be changed to '%s'", p.ID, p.TaxBehavior, taxBehavior)
+	}
+
+	_, err := c.api.Prices.Update(p.ID, &stripe.PriceParams{
+		Params:      stripe.Params{Context: ctx},
+		TaxBehavior: stripe.String(taxBehavior),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to set tax behavior on price %s: %w", p.ID, err)
+	}
+	c.logProgress("price %s: set tax behavior %s", p.ID, taxBehavior)
+	return true, nil
+}
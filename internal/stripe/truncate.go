@@ -1,86 +1,187 @@
 package stripe
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/stripe/stripe-go/v82"
-	"github.com/stripe/stripe-go/v82/coupon"
-	"github.com/stripe/stripe-go/v82/price"
-	"github.com/stripe/stripe-go/v82/product"
 )
 
+// TruncateOptions extends Truncate to the test data created by SeedCustomers
+type TruncateOptions struct {
+	Subscriptions bool // cancel subscriptions with raterunner_seed metadata
+	Customers     bool // delete customers with raterunner_seed metadata and their test clocks
+}
+
 // TruncateResult contains the results of the truncate operation
 type TruncateResult struct {
-	ProductsArchived int
-	PricesArchived   int
-	CouponsDeleted   int
+	ProductsArchived      int
+	PricesArchived        int
+	CouponsDeleted        int
+	SubscriptionsCanceled int
+	CustomersDeleted      int
+	TestClocksDeleted     int
 }
 
 // Truncate archives all products, prices, and deletes coupons in the Stripe account.
-// This only works in sandbox environment.
-func (c *Client) Truncate() (*TruncateResult, error) {
+// With opts, seeded subscriptions and customers go first. This only works in
+// sandbox environment.
+func (c *Client) Truncate(ctx context.Context, opts TruncateOptions) (*TruncateResult, error) {
 	if c.env != Sandbox {
 		return nil, fmt.Errorf("truncate is only allowed in sandbox environment")
 	}
 
 	result := &TruncateResult{}
+	c.dropProductCache()
+
+	if opts.Subscriptions || opts.Customers {
+		if err := c.truncateSeeded(ctx, opts, result); err != nil {
+			return result, err
+		}
+	}
 
 	// First, archive all prices (must be done before products)
 	priceParams := &stripe.PriceListParams{}
-	priceParams.Filters.AddFilter("limit", "", "100")
+	priceParams.Context = ctx
+	priceParams.Filters.AddFilter("limit", "", listPageSize)
 	priceParams.Filters.AddFilter("active", "", "true")
 
-	priceIter := price.List(priceParams)
-	for priceIter.Next() {
-		p := priceIter.Price()
-		_, err := price.Update(p.ID, &stripe.PriceParams{
+	prices, err := listAll[*stripe.Price](c.api.Prices.List(priceParams))
+	if err != nil {
+		return result, fmt.Errorf("failed to list prices: %w", err)
+	}
+	for _, p := range prices {
+		_, err := c.api.Prices.Update(p.ID, &stripe.PriceParams{
+			Params: stripe.Params{Context: ctx},
 			Active: stripe.Bool(false),
 		})
 		if err != nil {
 			return result, fmt.Errorf("failed to archive price %s: %w", p.ID, err)
 		}
 		result.PricesArchived++
-	}
-	if err := priceIter.Err(); err != nil {
-		return result, fmt.Errorf("failed to list prices: %w", err)
+		c.logProgress("archived price %s", p.ID)
 	}
 
 	// Then archive all products
 	prodParams := &stripe.ProductListParams{}
-	prodParams.Filters.AddFilter("limit", "", "100")
+	prodParams.Context = ctx
+	prodParams.Filters.AddFilter("limit", "", listPageSize)
 	prodParams.Filters.AddFilter("active", "", "true")
 
-	prodIter := product.List(prodParams)
-	for prodIter.Next() {
-		p := prodIter.Product()
-		_, err := product.Update(p.ID, &stripe.ProductParams{
+	products, err := listAll[*stripe.Product](c.api.Products.List(prodParams))
+	if err != nil {
+		return result, fmt.Errorf("failed to list products: %w", err)
+	}
+	for _, p := range products {
+		_, err := c.api.Products.Update(p.ID, &stripe.ProductParams{
+			Params: stripe.Params{Context: ctx},
 			Active: stripe.Bool(false),
 		})
 		if err != nil {
 			return result, fmt.Errorf("failed to archive product %s: %w", p.ID, err)
 		}
 		result.ProductsArchived++
-	}
-	if err := prodIter.Err(); err != nil {
-		return result, fmt.Errorf("failed to list products: %w", err)
+		c.logProgress("archived product %s", p.ID)
 	}
 
 	// Delete all coupons
 	couponParams := &stripe.CouponListParams{}
-	couponParams.Filters.AddFilter("limit", "", "100")
+	couponParams.Context = ctx
+	couponParams.Filters.AddFilter("limit", "", listPageSize)
 
-	couponIter := coupon.List(couponParams)
-	for couponIter.Next() {
-		c := couponIter.Coupon()
-		_, err := coupon.Del(c.ID, nil)
+	coupons, err := listAll[*stripe.Coupon](c.api.Coupons.List(couponParams))
+	if err != nil {
+		return result, fmt.Errorf("failed to list coupons: %w", err)
+	}
+	for _, cp := range coupons {
+		_, err := c.api.Coupons.Del(cp.ID, &stripe.CouponParams{Params: stripe.Params{Context: ctx}})
 		if err != nil {
-			return result, fmt.Errorf("failed to delete coupon %s: %w", c.ID, err)
+			return result, fmt.Errorf("failed to delete coupon %s: %w", cp.ID, err)
 		}
 		result.CouponsDeleted++
-	}
-	if err := couponIter.Err(); err != nil {
-		return result, fmt.Errorf("failed to list coupons: %w", err)
+		c.logProgress("deleted coupon %s", cp.ID)
 	}
 
 	return result, nil
 }
+
+// truncateSeeded cancels the subscriptions and deletes the customers created by
+// SeedCustomers. Stripe leaves objects on test clocks out of list results
+// unless the clock is named, so the seed clocks are walked one by one.
+func (c *Client) truncateSeeded(ctx context.Context, opts TruncateOptions, result *TruncateResult) error {
+	var clocks []string
+	clockParams := &stripe.TestHelpersTestClockListParams{}
+	clockParams.Context = ctx
+	clockParams.Filters.AddFilter("limit", "", listPageSize)
+	clockIter := c.api.TestHelpersTestClocks.List(clockParams)
+	for clockIter.Next() {
+		if clock := clockIter.TestHelpersTestClock(); strings.HasPrefix(clock.Name, seedClockPrefix) {
+			clocks = append(clocks, clock.ID)
+		}
+	}
+	if err := clockIter.Err(); err != nil {
+		return fmt.Errorf("failed to list test clocks: %w", err)
+	}
+
+	// "" lists the objects that aren't on a test clock
+	for _, clock := range append([]string{""}, clocks...) {
+		if opts.Subscriptions {
+			params := &stripe.SubscriptionListParams{Status: stripe.String("all")}
+			params.Context = ctx
+			params.Filters.AddFilter("limit", "", listPageSize)
+			if clock != "" {
+				params.TestClock = stripe.String(clock)
+			}
+			subscriptions, err := listAll[*stripe.Subscription](c.api.Subscriptions.List(params))
+			if err != nil {
+				return fmt.Errorf("failed to list subscriptions: %w", err)
+			}
+			for _, s := range subscriptions {
+				if s.Metadata[seedMetadataKey] == "" || s.Status == stripe.SubscriptionStatusCanceled || s.Status == stripe.SubscriptionStatusIncompleteExpired {
+					continue
+				}
+				if _, err := c.api.Subscriptions.Cancel(s.ID, &stripe.SubscriptionCancelParams{Params: stripe.Params{Context: ctx}}); err != nil {
+					return fmt.Errorf("failed to cancel subscription %s: %w", s.ID, err)
+				}
+				result.SubscriptionsCanceled++
+				c.logProgress("canceled subscription %s", s.ID)
+			}
+		}
+
+		if opts.Customers {
+			params := &stripe.CustomerListParams{}
+			params.Context = ctx
+			params.Filters.AddFilter("limit", "", listPageSize)
+			if clock != "" {
+				params.TestClock = stripe.String(clock)
+			}
+			customers, err := listAll[*stripe.Customer](c.api.Customers.List(params))
+			if err != nil {
+				return fmt.Errorf("failed to list customers: %w", err)
+			}
+			for _, customer := range customers {
+				if customer.Metadata[seedMetadataKey] == "" {
+					continue
+				}
+				if _, err := c.api.Customers.Del(customer.ID, &stripe.CustomerParams{Params: stripe.Params{Context: ctx}}); err != nil {
+					return fmt.Errorf("failed to delete customer %s: %w", customer.ID, err)
+				}
+				result.CustomersDeleted++
+				c.logProgress("deleted customer %s", customer.ID)
+			}
+		}
+	}
+
+	// A clock holds on to its deleted customers' history, so it goes too
+	if opts.Customers {
+		for _, clock := range clocks {
+			if _, err := c.api.TestHelpersTestClocks.Del(clock, &stripe.TestHelpersTestClockParams{Params: stripe.Params{Context: ctx}}); err != nil {
+				return fmt.Errorf("failed to delete test clock %s: %w", clock, err)
+			}
+			result.TestClocksDeleted++
+			c.logProgress("deleted test clock %s", clock)
+		}
+	}
+	return nil
+}
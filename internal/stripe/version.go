@@ -0,0 +1,55 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/stripe/stripe-go/v82"
+)
+
+// APIVersion is the Stripe API version every request is made with. stripe-go
+// pins it, so objects read the same whatever version the account defaults to
+// or the Dashboard created them with.
+const APIVersion = stripe.APIVersion
+
+// AccountAPIVersion returns the API version of the account's newest event,
+// which Stripe renders in the account's default version. It is "" when the
+// account has no events in the last 30 days.
+func (c *Client) AccountAPIVersion(ctx context.Context) (string, error) {
+	params := &stripe.EventListParams{}
+	params.Context = ctx
+	params.Filters.AddFilter("limit", "", "1")
+	params.Single = true
+
+	iter := c.api.Events.List(params)
+	if iter.Next() {
+		return iter.Event().APIVersion, nil
+	}
+	if err := iter.Err(); err != nil {
+		return "", fmt.Errorf("failed to list events: %w", err)
+	}
+	return "", nil
+}
+
+// SameAPIRelease reports whether two API versions belong to the same release
+// train (e.g. 2025-04-30.basil and 2025-05-28.basil), whose objects have the
+// same shape. Versions from before release trains only match exactly.
+func SameAPIRelease(a, b string) bool {
+	if a == b {
+		return true
+	}
+	_, trainA, okA := strings.Cut(a, ".")
+	_, trainB, okB := strings.Cut(b, ".")
+	return okA && okB && trainA == trainB && trainA != "preview"
+}
+
+// APIVersionAge describes version relative to APIVersion: "older" or "newer"
+func APIVersionAge(version string) string {
+	date, _, _ := strings.Cut(version, ".")
+	pinned, _, _ := strings.Cut(APIVersion, ".")
+	if date < pinned {
+		return "older"
+	}
+	return "newer"
+}
@@ -0,0 +1,39 @@
+package stripe
+
+// Warning codes identify the kind of a sync warning for tooling
+const (
+	WarnNameDiffers         = "name_differs"
+	WarnPriceDiffers        = "price_differs"
+	WarnMetadataUnmigrated  = "metadata_unmigrated"
+	WarnCouponExists        = "coupon_exists"
+	WarnPromoCodeExists     = "promo_code_exists"
+	WarnPromoNotReactivated = "promo_not_reactivated"
+	WarnRequestsRetried     = "requests_retried"
+	WarnMeterDiffers        = "meter_differs"
+)
+
+// Warning is a non-fatal problem found during sync. Message is the formatted
+// text shown to humans; the other fields let tooling act on it without parsing.
+type Warning struct {
+	Code       string `json:"code"`
+	PlanID     string `json:"plan_id,omitempty"`
+	AddonID    string `json:"addon_id,omitempty"`
+	CreditPack string `json:"credit_pack,omitempty"`
+	Promotion  string `json:"promotion,omitempty"`
+	Meter      string `json:"meter,omitempty"`
+	ProductID  string `json:"product_id,omitempty"`
+	Interval   string `json:"interval,omitempty"`
+	Local      any    `json:"local,omitempty"`
+	Remote     any    `json:"remote,omitempty"`
+	Message    string `json:"message"`
+}
+
+// String returns the human-readable message
+func (w Warning) String() string {
+	return w.Message
+}
+
+// warn records a sync warning
+func (r *SyncResult) warn(w Warning) {
+	r.Warnings = append(r.Warnings, w)
+}
@@ -0,0 +1,80 @@
+// Package telemetry sends anonymous usage events for users who opted in. An
+// event names the command and how it went, never its arguments, flag values,
+// file contents or Stripe data.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultEndpoint receives events when telemetry_endpoint isn't set
+const DefaultEndpoint = "https://telemetry.raterunner.io/v1/events"
+
+// Timeout bounds how long a command waits for its event to be sent
+const Timeout = 2 * time.Second
+
+// Event is one command run
+type Event struct {
+	InstallID  string   `json:"install_id"` // random, generated when telemetry is turned on
+	Version    string   `json:"version"`
+	OS         string   `json:"os"`
+	Arch       string   `json:"arch"`
+	Command    string   `json:"command"`         // e.g. "apply" or "provider-file compact"
+	Flags      []string `json:"flags,omitempty"` // names of the flags set, without values
+	DurationMS int64    `json:"duration_ms"`
+	Status     string   `json:"status"`                // ok or error
+	ErrorClass string   `json:"error_class,omitempty"` // e.g. stripe_api, lock_held, exit_1
+	CI         bool     `json:"ci"`
+}
+
+// Disabled returns why telemetry is off regardless of the settings, or ""
+func Disabled() string {
+	if v := os.Getenv("DO_NOT_TRACK"); v != "" && v != "0" {
+		return "DO_NOT_TRACK is set"
+	}
+	return ""
+}
+
+// NewInstallID returns a random ID that tells installs apart without
+// identifying anyone
+func NewInstallID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate install ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Send posts an event to endpoint, giving up after Timeout
+func Send(ctx context.Context, endpoint string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
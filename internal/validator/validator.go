@@ -2,15 +2,20 @@ package validator
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/santhosh-tekuri/jsonschema/v6"
 	"gopkg.in/yaml.v3"
 
+	"raterunner/internal/config"
 	"raterunner/internal/schema"
 )
 
@@ -18,18 +23,24 @@ type ValidationError struct {
 	Path    string
 	Message string
 	Detail  string
+	Rule    string // set for semantic checks, which can be suppressed inline; empty for schema errors
 }
 
 func (e ValidationError) String() string {
+	msg := fmt.Sprintf("%s: %s", e.Path, e.Message)
 	if e.Detail != "" {
-		return fmt.Sprintf("%s: %s (%s)", e.Path, e.Message, e.Detail)
+		msg = fmt.Sprintf("%s: %s (%s)", e.Path, e.Message, e.Detail)
 	}
-	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+	if e.Rule != "" {
+		msg += fmt.Sprintf(" [%s]", e.Rule)
+	}
+	return msg
 }
 
 type ValidationResult struct {
-	Valid  bool
-	Errors []ValidationError
+	Valid    bool
+	Errors   []ValidationError
+	Warnings []string // problems that don't make the config invalid
 }
 
 type Validator struct {
@@ -55,13 +66,48 @@ func (v *Validator) ValidateProviderFile(filePath string) (*ValidationResult, er
 	return v.validateFile(filePath, schema.ProviderSchemaFile)
 }
 
+// ValidateBilling validates billing config content in the given format ("yaml" or "json")
+func (v *Validator) ValidateBilling(content []byte, format string) (*ValidationResult, error) {
+	return v.validateContent(content, format, schema.BillingSchemaFile)
+}
+
+// ValidateProvider validates provider config content in the given format ("yaml" or "json")
+func (v *Validator) ValidateProvider(content []byte, format string) (*ValidationResult, error) {
+	return v.validateContent(content, format, schema.ProviderSchemaFile)
+}
+
 func (v *Validator) validateFile(filePath, schemaName string) (*ValidationResult, error) {
 	data, err := loadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load file: %w", err)
 	}
 
+	return v.validateData(data, schemaName)
+}
+
+func (v *Validator) validateContent(content []byte, format, schemaName string) (*ValidationResult, error) {
+	data, err := parseContent(content, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input: %w", err)
+	}
+
+	return v.validateData(data, schemaName)
+}
+
+func (v *Validator) validateData(data any, schemaName string) (*ValidationResult, error) {
 	result := &ValidationResult{Valid: true}
+	billing := schemaName == schema.BillingSchemaFile
+
+	if billing {
+		pinned, err := v.pinnedBillingSchema(data, result)
+		if err != nil {
+			return nil, err
+		}
+		if !result.Valid {
+			return result, nil
+		}
+		schemaName = pinned
+	}
 
 	schemaErrors, err := v.validateSchema(data, schemaName)
 	if err != nil {
@@ -72,12 +118,15 @@ func (v *Validator) validateFile(filePath, schemaName string) (*ValidationResult
 		result.Errors = append(result.Errors, schemaErrors...)
 	}
 
-	if schemaName == schema.BillingSchemaFile {
+	if billing {
 		semanticErrors := validateBillingSemantics(data)
 		if len(semanticErrors) > 0 {
 			result.Valid = false
 			result.Errors = append(result.Errors, semanticErrors...)
 		}
+	} else if idErrors := validateProviderIDs(data); len(idErrors) > 0 {
+		result.Valid = false
+		result.Errors = append(result.Errors, idErrors...)
 	}
 
 	return result, nil
@@ -90,27 +139,147 @@ func (v *Validator) loadSchema(schemaName string) ([]byte, error) {
 	return fs.ReadFile(v.schemaFS, schemaName)
 }
 
+// pinnedBillingSchema returns the schema file for the billing schema version
+// a config pins with schema_version, or with a $schema URL ending in /v<N> or
+// /v<N>.json. Configs pinning a newer version than this binary ships are
+// validated against the newest one, with a warning.
+func (v *Validator) pinnedBillingSchema(data any, result *ValidationResult) (string, error) {
+	doc, ok := data.(map[string]any)
+	if !ok {
+		return schema.BillingSchemaFile, nil
+	}
+
+	version := 0
+	path := "/schema_version"
+	switch n := doc["schema_version"].(type) {
+	case int: // YAML
+		version = n
+	case float64: // JSON
+		if n == float64(int(n)) {
+			version = int(n)
+		}
+	}
+	if url, ok := doc["$schema"].(string); ok {
+		if n := schemaURLVersion(url); n > 0 {
+			if version != 0 && version != n {
+				result.Valid = false
+				result.Errors = append(result.Errors, ValidationError{
+					Path:    path,
+					Message: fmt.Sprintf("schema_version %d doesn't match the $schema URL (version %d)", version, n),
+				})
+				return "", nil
+			}
+			version = n
+			path = "/$schema"
+		}
+	}
+
+	switch {
+	case version <= 0:
+		return schema.BillingSchemaFile, nil // not pinned, or left to the schema's minimum
+	case version > schema.BillingSchemaVersion:
+		result.Warnings = append(result.Warnings, fmt.Sprintf(
+			"config targets billing schema version %d, but this binary ships up to version %d; validating against version %d (upgrade raterunner)",
+			version, schema.BillingSchemaVersion, schema.BillingSchemaVersion))
+		return schema.BillingSchemaFile, nil
+	}
+
+	schemaName := schema.BillingSchemaFileFor(version)
+	if _, err := v.loadSchema(schemaName); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to read schema: %w", err)
+		}
+		result.Valid = false
+		result.Errors = append(result.Errors, ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("billing schema version %d is not available (missing %s)", version, schemaName),
+		})
+		return "", nil
+	}
+	return schemaName, nil
+}
+
+// schemaURLVersion returns N for a $schema URL ending in /v<N> or /v<N>.json, or 0
+func schemaURLVersion(url string) int {
+	last := url[strings.LastIndex(url, "/")+1:]
+	last = strings.TrimSuffix(last, ".json")
+	if !strings.HasPrefix(last, "v") {
+		return 0
+	}
+	n, err := strconv.Atoi(last[1:])
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// addSchemaDir registers every *.schema.json in dir with the compiler, both under
+// its file path (for relative $refs) and under its $id (for absolute $refs)
+func addSchemaDir(compiler *jsonschema.Compiler, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.schema.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list schemas: %w", err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read schema %s: %w", file, err)
+		}
+
+		var doc any
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return fmt.Errorf("failed to parse schema %s: %w", file, err)
+		}
+
+		if err := compiler.AddResource(file, doc); err != nil {
+			return fmt.Errorf("failed to add schema %s: %w", file, err)
+		}
+
+		if m, ok := doc.(map[string]any); ok {
+			if id, ok := m["$id"].(string); ok && id != "" {
+				if err := compiler.AddResource(id, doc); err != nil {
+					return fmt.Errorf("failed to add schema %s as %s: %w", file, id, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func loadFile(filePath string) (any, error) {
-	content, err := os.ReadFile(filePath)
+	content, err := config.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
 	ext := strings.ToLower(filepath.Ext(filePath))
-	var data any
-
 	switch ext {
 	case ".yaml", ".yml":
+		return parseContent(content, "yaml")
+	case ".json":
+		return parseContent(content, "json")
+	default:
+		return nil, fmt.Errorf("unsupported file extension: %s (use .yaml, .yml, or .json)", ext)
+	}
+}
+
+func parseContent(content []byte, format string) (any, error) {
+	var data any
+
+	switch format {
+	case "yaml":
 		if err := yaml.Unmarshal(content, &data); err != nil {
 			return nil, fmt.Errorf("invalid YAML: %w", err)
 		}
 		data = convertYAMLToJSON(data)
-	case ".json":
+	case "json":
 		if err := json.Unmarshal(content, &data); err != nil {
 			return nil, fmt.Errorf("invalid JSON: %w", err)
 		}
 	default:
-		return nil, fmt.Errorf("unsupported file extension: %s (use .yaml, .yml, or .json)", ext)
+		return nil, fmt.Errorf("unsupported format: %s (use yaml or json)", format)
 	}
 
 	return data, nil
@@ -130,6 +299,13 @@ func convertYAMLToJSON(v any) any {
 			result[i] = convertYAMLToJSON(v)
 		}
 		return result
+	case time.Time:
+		// Unquoted dates such as expires: 2026-12-01 decode as timestamps; JSON
+		// Schema sees them as the strings they were written as
+		if val.Equal(val.Truncate(24*time.Hour)) && val.Location() == time.UTC {
+			return val.Format(time.DateOnly)
+		}
+		return val.Format(time.RFC3339)
 	default:
 		return v
 	}
@@ -147,11 +323,19 @@ func (v *Validator) validateSchema(data any, schemaName string) ([]ValidationErr
 	}
 
 	compiler := jsonschema.NewCompiler()
-	if err := compiler.AddResource(schemaName, schemaDoc); err != nil {
+	schemaURL := schemaName
+
+	if v.schemaDir != "" {
+		// Register every schema in the directory so cross-file $refs resolve
+		if err := addSchemaDir(compiler, v.schemaDir); err != nil {
+			return nil, err
+		}
+		schemaURL = filepath.Join(v.schemaDir, schemaName)
+	} else if err := compiler.AddResource(schemaName, schemaDoc); err != nil {
 		return nil, fmt.Errorf("failed to add schema: %w", err)
 	}
 
-	sch, err := compiler.Compile(schemaName)
+	sch, err := compiler.Compile(schemaURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compile schema: %w", err)
 	}
@@ -230,13 +414,44 @@ func validateBillingSemantics(data any) []ValidationError {
 		return errors
 	}
 
+	if plans, ok := root["plans"].([]any); ok {
+		errors = append(errors, validatePlanVisibility(plans)...)
+		errors = append(errors, validateTrials(root, plans)...)
+		errors = append(errors, validateCustomPricing(plans)...)
+		errors = append(errors, validateManualInvoicing(root, plans)...)
+		errors = append(errors, validatePreviousIDs(plans)...)
+		errors = append(errors, validatePlanPaths(plans)...)
+		errors = append(errors, validateProvisioning(root, plans)...)
+		errors = append(errors, validateMeteredPrices(root, plans)...)
+	}
+	errors = append(errors, validateMeterEvents(root)...)
+
+	if promotions, ok := root["promotions"].([]any); ok {
+		errors = append(errors, validatePromotionExclusions(promotions)...)
+		errors = append(errors, validatePromotionWindows(promotions)...)
+	}
+
+	errors = append(errors, validateMetadataMapping(root)...)
+	errors = append(errors, validateDunning(root)...)
+	errors = append(errors, validateInvoice(root)...)
+
 	definedEntitlements := make(map[string]bool)
+	entitlementTypes := make(map[string]string)
 	if entitlements, ok := root["entitlements"].(map[string]any); ok {
-		for key := range entitlements {
+		for key, def := range entitlements {
 			definedEntitlements[key] = true
+			if defMap, ok := def.(map[string]any); ok {
+				if t, ok := defMap["type"].(string); ok {
+					entitlementTypes[key] = t
+				}
+			}
 		}
 	}
 
+	if credits, ok := root["credits"].([]any); ok {
+		errors = append(errors, validateCreditPacks(credits, entitlementTypes)...)
+	}
+
 	if len(definedEntitlements) == 0 {
 		return errors
 	}
@@ -253,14 +468,22 @@ func validateBillingSemantics(data any) []ValidationError {
 			}
 
 			if limits, ok := planMap["limits"].(map[string]any); ok {
-				for key := range limits {
+				for key, value := range limits {
 					if !definedEntitlements[key] {
 						errors = append(errors, ValidationError{
 							Path:    fmt.Sprintf("/plans/%d/limits/%s", i, key),
+							Rule:    "undefined-entitlement",
 							Message: fmt.Sprintf("undefined entitlement '%s'", key),
 							Detail:  fmt.Sprintf("plan '%s' references entitlement '%s' which is not defined in the entitlements section", planID, key),
 						})
+						continue
+					}
+					path := fmt.Sprintf("/plans/%d/limits/%s", i, key)
+					if config.IsUnlimited(value) {
+						errors = append(errors, validateUnlimited(path, planID, key, entitlementTypes[key])...)
+						continue
 					}
+					errors = append(errors, validateRateLimit(path, planID, key, entitlementTypes[key], value)...)
 				}
 			}
 		}
@@ -278,18 +501,950 @@ func validateBillingSemantics(data any) []ValidationError {
 			}
 
 			if grants, ok := addonMap["grants"].(map[string]any); ok {
-				for key := range grants {
+				for key, value := range grants {
+					path := fmt.Sprintf("/addons/%d/grants/%s", i, key)
 					if !definedEntitlements[key] {
 						errors = append(errors, ValidationError{
-							Path:    fmt.Sprintf("/addons/%d/grants/%s", i, key),
+							Path:    path,
+							Rule:    "undefined-entitlement",
 							Message: fmt.Sprintf("undefined entitlement '%s'", key),
 							Detail:  fmt.Sprintf("addon '%s' grants entitlement '%s' which is not defined in the entitlements section", addonID, key),
 						})
+						continue
 					}
+					errors = append(errors, validateGrant(path, addonID, key, entitlementTypes[key], value)...)
+				}
+			}
+		}
+	}
+
+	return errors
+}
+
+// validateDunning checks that settings.dunning retries in increasing order and
+// cancels after a failure that can happen
+func validateDunning(root map[string]any) []ValidationError {
+	var errors []ValidationError
+
+	settings, _ := root["settings"].(map[string]any)
+	dunning, ok := settings["dunning"].(map[string]any)
+	if !ok {
+		return errors
+	}
+
+	retryDays, _ := dunning["retry_days"].([]any)
+	last := 0
+	for i, v := range retryDays {
+		day, ok := intValue(v)
+		if !ok {
+			continue
+		}
+		if day <= last {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/settings/dunning/retry_days/%d", i),
+				Rule:    "dunning",
+				Message: "retry_days must increase",
+				Detail:  fmt.Sprintf("retry on day %d comes after the retry on day %d; list retries in the order they happen", day, last),
+			})
+		}
+		last = max(last, day)
+	}
+
+	if cancelAfter, ok := intValue(dunning["cancel_after_failures"]); ok {
+		if attempts := len(retryDays) + 1; cancelAfter > attempts {
+			errors = append(errors, ValidationError{
+				Path:    "/settings/dunning/cancel_after_failures",
+				Rule:    "dunning",
+				Message: fmt.Sprintf("cancel_after_failures is %d but a payment is only attempted %d time(s)", cancelAfter, attempts),
+				Detail:  "the first payment and each of retry_days can fail; add retries or lower cancel_after_failures",
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateInvoice checks that settings.invoice names each custom field once,
+// since invoices print them as a list of labels
+func validateInvoice(root map[string]any) []ValidationError {
+	var errors []ValidationError
+
+	settings, _ := root["settings"].(map[string]any)
+	invoice, _ := settings["invoice"].(map[string]any)
+	fields, _ := invoice["custom_fields"].([]any)
+	seen := make(map[string]bool)
+	for i, field := range fields {
+		fieldMap, ok := field.(map[string]any)
+		if !ok {
+			continue
+		}
+		name, _ := fieldMap["name"].(string)
+		if name == "" {
+			continue
+		}
+		if seen[strings.ToLower(name)] {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/settings/invoice/custom_fields/%d/name", i),
+				Rule:    "invoice",
+				Message: fmt.Sprintf("duplicate invoice custom field '%s'", name),
+				Detail:  "each custom field is printed as its own label on invoices; merge the values into one field",
+			})
+		}
+		seen[strings.ToLower(name)] = true
+	}
+
+	return errors
+}
+
+// intValue returns a whole number decoded from YAML or JSON
+func intValue(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), n == float64(int(n))
+	}
+	return 0, false
+}
+
+// validateRateLimit checks that rate entitlements get a well-formed {limit, per}
+// value and that other entitlement types don't
+func validateRateLimit(path, planID, key, entType string, value any) []ValidationError {
+	_, isMap := value.(map[string]any)
+
+	if entType != "rate" {
+		if isMap {
+			return []ValidationError{{
+				Path:    path,
+				Rule:    "rate-limit",
+				Message: fmt.Sprintf("entitlement '%s' is not a rate limit", key),
+				Detail:  fmt.Sprintf("plan '%s' sets {limit, per} but entitlement '%s' has type '%s'", planID, key, entType),
+			}}
+		}
+		return nil
+	}
+
+	if !isMap {
+		return []ValidationError{{
+			Path:    path,
+			Rule:    "rate-limit",
+			Message: fmt.Sprintf("entitlement '%s' is a rate limit and requires {limit, per}", key),
+			Detail:  fmt.Sprintf("plan '%s' sets %v", planID, value),
+		}}
+	}
+
+	if _, err := config.ParseRateLimit(value); err != nil {
+		return []ValidationError{{
+			Path:    path,
+			Rule:    "rate-limit",
+			Message: fmt.Sprintf("invalid rate limit for '%s'", key),
+			Detail:  err.Error(),
+		}}
+	}
+
+	return nil
+}
+
+// validateGrant checks that an addon grant parses and fits the entitlement type:
+// bool entitlements take true/false, int entitlements take N, "+N"/"-N", or
+// "unlimited", and rate entitlements can only be lifted to "unlimited"
+func validateGrant(path, addonID, key, entType string, value any) []ValidationError {
+	grant, err := config.ParseGrant(value)
+	if err != nil {
+		return []ValidationError{{
+			Path:    path,
+			Rule:    "addon-grant",
+			Message: fmt.Sprintf("invalid grant for '%s'", key),
+			Detail:  err.Error(),
+		}}
+	}
+
+	var problem string
+	switch entType {
+	case "bool":
+		if !grant.IsBool() {
+			problem = "is a bool entitlement and must be granted as true or false"
+		}
+	case "int":
+		if grant.IsBool() {
+			problem = "is an int entitlement and can't be granted as a bool"
+		}
+	case "rate":
+		if grant.Op != config.GrantUnlimited {
+			problem = "is a rate limit and can only be granted as 'unlimited'"
+		}
+	case "credit":
+		problem = "is a credit balance; sell credits as a pack in the credits section instead"
+	}
+	if problem == "" {
+		return nil
+	}
+
+	return []ValidationError{{
+		Path:    path,
+		Rule:    "addon-grant",
+		Message: fmt.Sprintf("invalid grant for '%s'", key),
+		Detail:  fmt.Sprintf("addon '%s' grants %s to '%s', which %s", addonID, grant, key, problem),
+	}}
+}
+
+// validateCreditPacks checks that every credit pack tops up a defined credit
+// entitlement and that pack IDs are unique
+func validateCreditPacks(credits []any, entitlementTypes map[string]string) []ValidationError {
+	var errors []ValidationError
+
+	seen := make(map[string]bool)
+	for i, pack := range credits {
+		packMap, ok := pack.(map[string]any)
+		if !ok {
+			continue
+		}
+		packID, _ := packMap["id"].(string)
+		if packID != "" {
+			if seen[packID] {
+				errors = append(errors, ValidationError{
+					Path:    fmt.Sprintf("/credits/%d/id", i),
+					Rule:    "credit-pack",
+					Message: fmt.Sprintf("duplicate credit pack '%s'", packID),
+					Detail:  "credit pack IDs match packs to products and must be unique",
+				})
+			}
+			seen[packID] = true
+		}
+
+		key, ok := packMap["entitlement"].(string)
+		if !ok {
+			continue
+		}
+		path := fmt.Sprintf("/credits/%d/entitlement", i)
+		entType, defined := entitlementTypes[key]
+		switch {
+		case !defined:
+			errors = append(errors, ValidationError{
+				Path:    path,
+				Rule:    "undefined-entitlement",
+				Message: fmt.Sprintf("undefined entitlement '%s'", key),
+				Detail:  fmt.Sprintf("credit pack '%s' tops up entitlement '%s' which is not defined in the entitlements section", packID, key),
+			})
+		case entType != "credit":
+			errors = append(errors, ValidationError{
+				Path:    path,
+				Rule:    "credit-pack",
+				Message: fmt.Sprintf("entitlement '%s' is not a credit balance", key),
+				Detail:  fmt.Sprintf("credit pack '%s' tops up '%s', which has type '%s'; declare it with type: credit", packID, key, entType),
+			})
+		}
+	}
+	return errors
+}
+
+// validateMeterEvents checks that no two meters share an event name, since
+// Stripe routes usage events to meters by it
+func validateMeterEvents(root map[string]any) []ValidationError {
+	var errors []ValidationError
+
+	meters, _ := root["meters"].(map[string]any)
+	eventMeters := make(map[string]string)
+	for _, key := range sortedKeys(meters) {
+		meter, _ := meters[key].(map[string]any)
+		event, _ := meter["event_name"].(string)
+		if event == "" {
+			continue
+		}
+		if other, ok := eventMeters[event]; ok {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/meters/%s/event_name", key),
+				Rule:    "meter",
+				Message: fmt.Sprintf("event name '%s' is already used by meter '%s'", event, other),
+				Detail:  fmt.Sprintf("meters '%s' and '%s' would both count '%s' events; give each meter its own event name", other, key, event),
+			})
+			continue
+		}
+		eventMeters[event] = key
+	}
+	return errors
+}
+
+// validateMeteredPrices checks that metered prices name a defined meter and
+// recur, since usage is billed at the end of each period
+func validateMeteredPrices(root map[string]any, plans []any) []ValidationError {
+	var errors []ValidationError
+
+	meters, _ := root["meters"].(map[string]any)
+	check := func(path, planID string, prices map[string]any) {
+		for _, interval := range sortedKeys(prices) {
+			price, _ := prices[interval].(map[string]any)
+			key, ok := price["meter"].(string)
+			if !ok {
+				continue
+			}
+			switch {
+			case meters[key] == nil:
+				errors = append(errors, ValidationError{
+					Path:    fmt.Sprintf("%s/%s/meter", path, interval),
+					Rule:    "meter",
+					Message: fmt.Sprintf("undefined meter '%s'", key),
+					Detail:  fmt.Sprintf("plan '%s' bills %s usage from meter '%s' which is not defined in the meters section", planID, interval, key),
+				})
+			case interval == "one_time":
+				errors = append(errors, ValidationError{
+					Path:    fmt.Sprintf("%s/%s/meter", path, interval),
+					Rule:    "meter",
+					Message: "one-time prices can't be metered",
+					Detail:  fmt.Sprintf("plan '%s' bills usage from meter '%s' on a one-time price; metered prices must recur", planID, key),
+				})
+			}
+		}
+	}
+
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok {
+			continue
+		}
+		planID, _ := planMap["id"].(string)
+		prices, _ := planMap["prices"].(map[string]any)
+		check(fmt.Sprintf("/plans/%d/prices", i), planID, prices)
+		regions, _ := planMap["regions"].(map[string]any)
+		for _, name := range sortedKeys(regions) {
+			region, _ := regions[name].(map[string]any)
+			prices, _ := region["prices"].(map[string]any)
+			check(fmt.Sprintf("/plans/%d/regions/%s/prices", i, name), planID, prices)
+		}
+	}
+	return errors
+}
+
+// validateUnlimited checks that "unlimited" is only used on int and rate entitlements
+func validateUnlimited(path, planID, key, entType string) []ValidationError {
+	if entType == "int" || entType == "rate" {
+		return nil
+	}
+	return []ValidationError{{
+		Path:    path,
+		Rule:    "unlimited-type",
+		Message: fmt.Sprintf("'unlimited' is not allowed for entitlement '%s'", key),
+		Detail:  fmt.Sprintf("plan '%s' sets '%s' to unlimited but only int and rate entitlements can be unlimited (type is '%s')", planID, key, entType),
+	}}
+}
+
+// validatePromotionExclusions checks that excludes only names other, defined promotion codes
+func validatePromotionExclusions(promotions []any) []ValidationError {
+	var errors []ValidationError
+
+	codes := make(map[string]bool)
+	for _, promo := range promotions {
+		if promoMap, ok := promo.(map[string]any); ok {
+			if code, ok := promoMap["code"].(string); ok {
+				codes[code] = true
+			}
+		}
+	}
+
+	for i, promo := range promotions {
+		promoMap, ok := promo.(map[string]any)
+		if !ok {
+			continue
+		}
+		code, _ := promoMap["code"].(string)
+		excludes, _ := promoMap["excludes"].([]any)
+
+		for j, e := range excludes {
+			excluded, ok := e.(string)
+			if !ok {
+				continue
+			}
+			path := fmt.Sprintf("/promotions/%d/excludes/%d", i, j)
+			switch {
+			case excluded == code:
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    "promotion-excludes",
+					Message: "promotion cannot exclude itself",
+					Detail:  fmt.Sprintf("promotion '%s' lists itself in excludes", code),
+				})
+			case !codes[excluded]:
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    "promotion-excludes",
+					Message: fmt.Sprintf("undefined promotion '%s'", excluded),
+					Detail:  fmt.Sprintf("promotion '%s' excludes '%s' which is not defined in the promotions section", code, excluded),
+				})
+			}
+		}
+	}
+
+	return errors
+}
+
+// validatePromotionWindows checks that starts_at comes before expires. Both are
+// YYYY-MM-DD, so they compare as strings.
+func validatePromotionWindows(promotions []any) []ValidationError {
+	var errors []ValidationError
+
+	for i, promo := range promotions {
+		promoMap, ok := promo.(map[string]any)
+		if !ok {
+			continue
+		}
+		code, _ := promoMap["code"].(string)
+		startsAt, _ := promoMap["starts_at"].(string)
+		expires, _ := promoMap["expires"].(string)
+		if startsAt == "" || expires == "" || startsAt < expires {
+			continue
+		}
+		errors = append(errors, ValidationError{
+			Path:    fmt.Sprintf("/promotions/%d/starts_at", i),
+			Rule:    "promotion-window",
+			Message: "starts_at must be before expires",
+			Detail:  fmt.Sprintf("promotion '%s' starts %s but expires %s", code, startsAt, expires),
+		})
+	}
+
+	return errors
+}
+
+// validateCustomPricing checks that pricing: custom plans define no prices and
+// aren't explicitly synced, since there is nothing to create in a provider
+func validateCustomPricing(plans []any) []ValidationError {
+	var errors []ValidationError
+
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok || planMap["pricing"] != config.PricingCustom {
+			continue
+		}
+		planID, _ := planMap["id"].(string)
+
+		if prices, ok := planMap["prices"].(map[string]any); ok && len(prices) > 0 {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/plans/%d/prices", i),
+				Rule:    "custom-pricing",
+				Message: "plan with pricing: custom cannot define prices",
+				Detail:  fmt.Sprintf("plan '%s' is contact-sales only; remove its prices or use pricing: fixed", planID),
+			})
+		}
+		if sync, ok := planMap["sync"].(bool); ok && sync {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/plans/%d/sync", i),
+				Rule:    "custom-pricing",
+				Message: "plan with pricing: custom cannot be synced",
+				Detail:  fmt.Sprintf("plan '%s' has no fixed price to create in a billing provider", planID),
+			})
+		}
+	}
+
+	return errors
+}
+
+// validateManualInvoicing checks that invoicing: manual plans, which are sold
+// through sales and invoiced, aren't also charged automatically, the default
+// signup plan, or on a trial that asks for a card
+func validateManualInvoicing(root map[string]any, plans []any) []ValidationError {
+	var errors []ValidationError
+
+	settings, _ := root["settings"].(map[string]any)
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok || planMap["invoicing"] != config.InvoicingManual {
+			continue
+		}
+		planID, _ := planMap["id"].(string)
+
+		if planMap["collection_method"] == config.CollectionChargeAutomatically {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/plans/%d/collection_method", i),
+				Rule:    "invoicing",
+				Message: "plan with invoicing: manual cannot use collection_method: charge_automatically",
+				Detail:  fmt.Sprintf("plan '%s' is invoiced with send_invoice; remove collection_method or use invoicing: automatic", planID),
+			})
+		}
+		if isDefault, ok := planMap["default"].(bool); ok && isDefault {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/plans/%d/default", i),
+				Rule:    "invoicing",
+				Message: "plan with invoicing: manual cannot be the default plan",
+				Detail:  fmt.Sprintf("plan '%s' is sold through sales, so customers can't sign up to it on their own", planID),
+			})
+		}
+
+		trialDays, _ := intValue(planMap["trial_days"])
+		if trialDays == 0 {
+			continue
+		}
+		path, trial := fmt.Sprintf("/plans/%d/trial", i), planMap["trial"]
+		if trial == nil {
+			path, trial = "/settings/trial", settings["trial"]
+		}
+		trialMap, _ := trial.(map[string]any)
+		if require, ok := trialMap["require_payment_method"].(bool); ok && require {
+			errors = append(errors, ValidationError{
+				Path:    path + "/require_payment_method",
+				Rule:    "invoicing",
+				Message: "plan with invoicing: manual cannot require a payment method for its trial",
+				Detail:  fmt.Sprintf("plan '%s' needs no card; give it a trial block with require_payment_method: false", planID),
+			})
+		}
+	}
+
+	return errors
+}
+
+// validatePlanPaths checks upgrades_to and downgrades_to: both must name other
+// existing plans without looping back to a plan they started from, a plan
+// can't be both an upgrade and a downgrade, and a blocked downgrade_policy
+// leaves no downgrade paths to list
+func validatePlanPaths(plans []any) []ValidationError {
+	var errors []ValidationError
+
+	planIDs := make(map[string]bool)
+	for _, plan := range plans {
+		if planMap, ok := plan.(map[string]any); ok {
+			if id, ok := planMap["id"].(string); ok {
+				planIDs[id] = true
+			}
+		}
+	}
+
+	// targets returns the defined plans a plan lists in field, reporting the rest
+	targets := func(i int, planID string, planMap map[string]any, field, rule, verb string) []string {
+		var valid []string
+		list, _ := planMap[field].([]any)
+		for j, t := range list {
+			target, ok := t.(string)
+			if !ok {
+				continue
+			}
+			path := fmt.Sprintf("/plans/%d/%s/%d", i, field, j)
+			switch {
+			case target == planID:
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    rule,
+					Message: fmt.Sprintf("plan cannot %s to itself", verb),
+					Detail:  fmt.Sprintf("plan '%s' lists itself in %s", planID, field),
+				})
+			case !planIDs[target]:
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    rule,
+					Message: fmt.Sprintf("undefined plan '%s'", target),
+					Detail:  fmt.Sprintf("plan '%s' %ss to plan '%s' which is not defined", planID, verb, target),
+				})
+			default:
+				valid = append(valid, target)
+			}
+		}
+		return valid
+	}
+
+	graph := make([]config.Plan, 0, len(plans))
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok {
+			continue
+		}
+		planID, _ := planMap["id"].(string)
+		node := config.Plan{
+			ID:           planID,
+			UpgradesTo:   targets(i, planID, planMap, "upgrades_to", "upgrade-path", "upgrade"),
+			DowngradesTo: targets(i, planID, planMap, "downgrades_to", "downgrade-path", "downgrade"),
+		}
+		graph = append(graph, node)
+
+		for _, target := range node.DowngradesTo {
+			for _, upgrade := range node.UpgradesTo {
+				if target == upgrade {
+					errors = append(errors, ValidationError{
+						Path:    fmt.Sprintf("/plans/%d/downgrades_to", i),
+						Rule:    "downgrade-path",
+						Message: fmt.Sprintf("plan '%s' is both an upgrade and a downgrade", target),
+						Detail:  fmt.Sprintf("plan '%s' lists '%s' in upgrades_to and downgrades_to", planID, target),
+					})
 				}
 			}
 		}
+		if policy, _ := planMap["downgrade_policy"].(string); policy == config.DowngradeBlocked && len(node.DowngradesTo) > 0 {
+			errors = append(errors, ValidationError{
+				Path:    fmt.Sprintf("/plans/%d/downgrades_to", i),
+				Rule:    "downgrade-path",
+				Message: "downgrades_to has no effect with downgrade_policy: blocked",
+				Detail:  fmt.Sprintf("plan '%s' blocks self-serve downgrades but lists %s", planID, strings.Join(node.DowngradesTo, ", ")),
+			})
+		}
+	}
+
+	if cycle := config.UpgradeCycle(graph); cycle != nil {
+		errors = append(errors, ValidationError{
+			Path:    "/plans",
+			Rule:    "upgrade-path",
+			Message: "upgrade paths form a cycle",
+			Detail:  strings.Join(cycle, " -> "),
+		})
+	}
+	if cycle := config.DowngradeCycle(graph); cycle != nil {
+		errors = append(errors, ValidationError{
+			Path:    "/plans",
+			Rule:    "downgrade-path",
+			Message: "downgrade paths form a cycle",
+			Detail:  strings.Join(cycle, " -> "),
+		})
+	}
+
+	return errors
+}
+
+// validatePreviousIDs checks that a plan's previous_ids don't name a current plan
+// or another plan's previous ID, which would make product matching ambiguous
+func validatePreviousIDs(plans []any) []ValidationError {
+	var errors []ValidationError
+
+	currentIDs := make(map[string]bool)
+	for _, plan := range plans {
+		if planMap, ok := plan.(map[string]any); ok {
+			if id, ok := planMap["id"].(string); ok {
+				currentIDs[id] = true
+			}
+		}
+	}
+
+	claimedBy := make(map[string]string)
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok {
+			continue
+		}
+		planID, _ := planMap["id"].(string)
+		previousIDs, _ := planMap["previous_ids"].([]any)
+
+		for j, prev := range previousIDs {
+			prevID, ok := prev.(string)
+			if !ok {
+				continue
+			}
+			path := fmt.Sprintf("/plans/%d/previous_ids/%d", i, j)
+
+			if currentIDs[prevID] {
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    "plan-rename",
+					Message: fmt.Sprintf("previous ID '%s' is still a current plan ID", prevID),
+					Detail:  fmt.Sprintf("plan '%s' can't take over the Stripe product of an existing plan", planID),
+				})
+				continue
+			}
+			if owner, ok := claimedBy[prevID]; ok {
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    "plan-rename",
+					Message: fmt.Sprintf("previous ID '%s' is also listed by plan '%s'", prevID, owner),
+					Detail:  "each old ID can only be renamed to one plan",
+				})
+				continue
+			}
+			claimedBy[prevID] = planID
+		}
+	}
+
+	return errors
+}
+
+// validateMetadataMapping checks that no two metadata fields end up stored under
+// the same Stripe metadata key once metadata_prefix and metadata_mapping apply
+func validateMetadataMapping(root map[string]any) []ValidationError {
+	var errors []ValidationError
+
+	mapping, _ := root["metadata_mapping"].(map[string]any)
+	if len(mapping) == 0 {
+		return errors
+	}
+	prefix := ""
+	if settings, ok := root["settings"].(map[string]any); ok {
+		prefix, _ = settings["metadata_prefix"].(string)
+	}
+
+	storedBy := make(map[string]string)
+	for _, field := range config.MetadataFields {
+		key := prefix + field
+		if target, ok := mapping[field]; ok {
+			name, ok := target.(string)
+			if !ok {
+				continue // omitted
+			}
+			key = name
+		}
+
+		if other, ok := storedBy[key]; ok {
+			path := "/metadata_mapping/" + field
+			if _, mapped := mapping[field]; !mapped {
+				path = "/metadata_mapping/" + other
+			}
+			errors = append(errors, ValidationError{
+				Path:    path,
+				Rule:    "metadata-mapping",
+				Message: fmt.Sprintf("metadata fields '%s' and '%s' are both stored as '%s'", other, field, key),
+				Detail:  "give each field its own key or omit one with false",
+			})
+			continue
+		}
+		storedBy[key] = field
+	}
+
+	return errors
+}
+
+// Stripe metadata limits
+const (
+	maxMetadataKeyLength   = 40
+	maxMetadataValueLength = 500
+)
+
+// validateProvisioning checks that provisioning values fit Stripe metadata once
+// stored as <metadata_prefix>provisioning_<key>, with lists joined by commas
+func validateProvisioning(root map[string]any, plans []any) []ValidationError {
+	var errors []ValidationError
+
+	prefix := ""
+	if settings, ok := root["settings"].(map[string]any); ok {
+		prefix, _ = settings["metadata_prefix"].(string)
+	}
+
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok {
+			continue
+		}
+		provisioning, _ := planMap["provisioning"].(map[string]any)
+		planID, _ := planMap["id"].(string)
+
+		keys := make([]string, 0, len(provisioning))
+		for key := range provisioning {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			value := provisioning[key]
+			path := fmt.Sprintf("/plans/%d/provisioning/%s", i, key)
+			stored := prefix + config.ProvisioningKeyPrefix + key
+			if len(stored) > maxMetadataKeyLength {
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    "provisioning",
+					Message: fmt.Sprintf("plan '%s': metadata key '%s' is longer than Stripe's %d characters", planID, stored, maxMetadataKeyLength),
+					Detail:  "use a shorter provisioning key or metadata_prefix",
+				})
+			}
+			if n := len(config.ProvisioningValue(value)); n > maxMetadataValueLength {
+				errors = append(errors, ValidationError{
+					Path:    path,
+					Rule:    "provisioning",
+					Message: fmt.Sprintf("plan '%s': provisioning value for '%s' is %d characters, Stripe allows %d", planID, key, n, maxMetadataValueLength),
+				})
+			}
+		}
 	}
 
 	return errors
 }
+
+// validateTrials checks trial blocks in settings and plans: downgrade_to must name
+// another existing plan and is only meaningful with end_behavior: downgrade
+func validateTrials(root map[string]any, plans []any) []ValidationError {
+	var errors []ValidationError
+
+	planIDs := make(map[string]bool)
+	for _, plan := range plans {
+		if planMap, ok := plan.(map[string]any); ok {
+			if id, ok := planMap["id"].(string); ok {
+				planIDs[id] = true
+			}
+		}
+	}
+
+	check := func(path, owner, selfID string, trial any) {
+		trialMap, ok := trial.(map[string]any)
+		if !ok {
+			return
+		}
+		target, ok := trialMap["downgrade_to"].(string)
+		if !ok {
+			return
+		}
+		if behavior, _ := trialMap["end_behavior"].(string); behavior != config.TrialEndDowngrade {
+			errors = append(errors, ValidationError{
+				Path:    path + "/downgrade_to",
+				Rule:    "trial-downgrade",
+				Message: "downgrade_to requires end_behavior: downgrade",
+				Detail:  fmt.Sprintf("%s sets downgrade_to '%s' but end_behavior is '%s'", owner, target, behavior),
+			})
+			return
+		}
+		if !planIDs[target] {
+			errors = append(errors, ValidationError{
+				Path:    path + "/downgrade_to",
+				Rule:    "trial-downgrade",
+				Message: fmt.Sprintf("undefined plan '%s'", target),
+				Detail:  fmt.Sprintf("%s downgrades to plan '%s' which is not defined", owner, target),
+			})
+			return
+		}
+		if target == selfID {
+			errors = append(errors, ValidationError{
+				Path:    path + "/downgrade_to",
+				Rule:    "trial-downgrade",
+				Message: "plan cannot downgrade to itself",
+				Detail:  fmt.Sprintf("%s downgrades to itself", owner),
+			})
+		}
+	}
+
+	if settings, ok := root["settings"].(map[string]any); ok {
+		check("/settings/trial", "settings.trial", "", settings["trial"])
+	}
+
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok {
+			continue
+		}
+		planID, _ := planMap["id"].(string)
+		check(fmt.Sprintf("/plans/%d/trial", i), fmt.Sprintf("plan '%s'", planID), planID, planMap["trial"])
+	}
+
+	return errors
+}
+
+// validatePlanVisibility checks default and public flags across plans:
+// at most one default plan, default plans must be public, and hiding plans
+// must still leave at least one public plan for signup.
+func validatePlanVisibility(plans []any) []ValidationError {
+	var errors []ValidationError
+
+	var defaultPlans []string
+	hasHidden := false
+	hasPublic := false
+
+	for i, plan := range plans {
+		planMap, ok := plan.(map[string]any)
+		if !ok {
+			continue
+		}
+		planID := "unknown"
+		if id, ok := planMap["id"].(string); ok {
+			planID = id
+		}
+
+		// public defaults to true when omitted
+		public := true
+		if p, ok := planMap["public"].(bool); ok {
+			public = p
+		}
+		if public {
+			hasPublic = true
+		} else {
+			hasHidden = true
+		}
+
+		if isDefault, ok := planMap["default"].(bool); ok && isDefault {
+			defaultPlans = append(defaultPlans, planID)
+			if len(defaultPlans) > 1 {
+				errors = append(errors, ValidationError{
+					Path:    fmt.Sprintf("/plans/%d/default", i),
+					Rule:    "multiple-defaults",
+					Message: "multiple default plans",
+					Detail:  fmt.Sprintf("plans %s are all marked default: true, only one is allowed", strings.Join(defaultPlans, ", ")),
+				})
+			}
+			if !public {
+				errors = append(errors, ValidationError{
+					Path:    fmt.Sprintf("/plans/%d/public", i),
+					Rule:    "default-not-public",
+					Message: "default plan must be public",
+					Detail:  fmt.Sprintf("plan '%s' is marked default: true but public: false", planID),
+				})
+			}
+		}
+	}
+
+	if hasHidden && !hasPublic {
+		errors = append(errors, ValidationError{
+			Path:    "/plans",
+			Rule:    "no-public-plans",
+			Message: "no public plans",
+			Detail:  "every plan is public: false, at least one plan must be public for signup",
+		})
+	}
+
+	return errors
+}
+
+// validateProviderIDs checks that the IDs in a Stripe provider file have the
+// prefix of their object type, which catches a price ID pasted as a product
+// ID and the like. Promotions map to coupon IDs, which are chosen freely.
+func validateProviderIDs(data any) []ValidationError {
+	var errors []ValidationError
+
+	root, ok := data.(map[string]any)
+	if !ok || root["provider"] != "stripe" {
+		return errors
+	}
+
+	check := func(path string, value any, prefix string) {
+		id, ok := value.(string)
+		if !ok || strings.HasPrefix(id, prefix) {
+			return
+		}
+		errors = append(errors, ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("Stripe ID must start with %s", prefix),
+			Detail:  fmt.Sprintf("got %q", id),
+		})
+	}
+
+	plans, _ := root["plans"].(map[string]any)
+	for _, planID := range sortedKeys(plans) {
+		ids, _ := plans[planID].(map[string]any)
+		check(fmt.Sprintf("/plans/%s/product_id", planID), ids["product_id"], "prod_")
+		prices, _ := ids["prices"].(map[string]any)
+		for _, interval := range sortedKeys(prices) {
+			check(fmt.Sprintf("/plans/%s/prices/%s", planID, interval), prices[interval], "price_")
+		}
+		history, _ := ids["history"].([]any)
+		for i, entry := range history {
+			replaced, _ := entry.(map[string]any)
+			path := fmt.Sprintf("/plans/%s/history/%d", planID, i)
+			check(path+"/product_id", replaced["product_id"], "prod_")
+			prices, _ := replaced["prices"].(map[string]any)
+			for _, interval := range sortedKeys(prices) {
+				check(fmt.Sprintf("%s/prices/%s", path, interval), prices[interval], "price_")
+			}
+		}
+	}
+
+	// Addons and credit packs are both a product with a one-time price
+	for _, section := range []string{"addons", "credits"} {
+		products, _ := root[section].(map[string]any)
+		for _, id := range sortedKeys(products) {
+			ids, _ := products[id].(map[string]any)
+			check(fmt.Sprintf("/%s/%s/product_id", section, id), ids["product_id"], "prod_")
+			check(fmt.Sprintf("/%s/%s/price_id", section, id), ids["price_id"], "price_")
+		}
+	}
+
+	meters, _ := root["meters"].(map[string]any)
+	for _, key := range sortedKeys(meters) {
+		check(fmt.Sprintf("/meters/%s", key), meters[key], "mtr_")
+	}
+
+	return errors
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,103 @@
+package testkit
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"raterunner/internal/config"
+)
+
+// Builder assembles a billing config in code, for tests that need a config
+// other than the repo's billing.yaml:
+//
+//	cfg := testkit.NewConfig().
+//		Entitlement("seats", "int").
+//		Plan("free", "Free", testkit.Monthly(0), testkit.Limit("seats", 1)).
+//		Build(t)
+type Builder struct {
+	cfg config.BillingConfig
+}
+
+// PlanOption sets a field of a plan added with Builder.Plan
+type PlanOption func(*Plan)
+
+// NewConfig starts a config for the stripe provider
+func NewConfig() *Builder {
+	return &Builder{cfg: config.BillingConfig{
+		Version:      1,
+		Providers:    []string{"stripe"},
+		Entitlements: make(map[string]config.Entitlement),
+	}}
+}
+
+// Entitlement defines an entitlement of a type: bool, int or rate
+func (b *Builder) Entitlement(key, entitlementType string) *Builder {
+	b.cfg.Entitlements[key] = config.Entitlement{Type: entitlementType}
+	return b
+}
+
+// Plan adds a plan
+func (b *Builder) Plan(id, name string, opts ...PlanOption) *Builder {
+	plan := config.Plan{ID: id, Name: name}
+	for _, opt := range opts {
+		opt(&plan)
+	}
+	b.cfg.Plans = append(b.cfg.Plans, plan)
+	return b
+}
+
+// Build validates the config like Parse, failing the test when it's invalid
+func (b *Builder) Build(t testing.TB) *Config {
+	t.Helper()
+	content, err := yaml.Marshal(&b.cfg)
+	if err != nil {
+		t.Fatalf("failed to encode billing config: %v", err)
+	}
+	return parse(t, content, "built billing config")
+}
+
+// Monthly sets a plan's monthly price
+func Monthly(amount int) PlanOption {
+	return Price("monthly", amount)
+}
+
+// Yearly sets a plan's yearly price
+func Yearly(amount int) PlanOption {
+	return Price("yearly", amount)
+}
+
+// Price sets a plan's flat price for an interval
+func Price(interval string, amount int) PlanOption {
+	return func(p *Plan) {
+		if p.Prices == nil {
+			p.Prices = make(map[string]config.Price)
+		}
+		p.Prices[interval] = config.Price{Amount: amount}
+	}
+}
+
+// Limit sets a plan's value of an entitlement: a bool, an integer, or Unlimited
+func Limit(entitlement string, value any) PlanOption {
+	return func(p *Plan) {
+		if p.Limits == nil {
+			p.Limits = make(map[string]any)
+		}
+		p.Limits[entitlement] = value
+	}
+}
+
+// Features sets the feature list shown on pricing pages
+func Features(features ...string) PlanOption {
+	return func(p *Plan) {
+		p.Features = append(p.Features, features...)
+	}
+}
+
+// Hidden keeps a plan off public pricing pages
+func Hidden() PlanOption {
+	return func(p *Plan) {
+		public := false
+		p.Public = &public
+	}
+}
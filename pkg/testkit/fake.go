@@ -0,0 +1,161 @@
+package testkit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/provider"
+	"raterunner/internal/stripe"
+)
+
+// FakeProvider is an in-memory Provider. Sync stores the catalog a config
+// describes, so a Diff after a Sync of the same config shows no differences.
+type FakeProvider struct {
+	Env string // environment plans are filtered by; sandbox when empty
+
+	// Errors returned instead of doing the work, to test failure handling
+	SyncErr     error
+	ImportErr   error
+	DiffErr     error
+	TruncateErr error
+
+	Synced []*BillingConfig // configs passed to Sync, oldest first
+
+	products []stripe.Product
+	lastIDs  *config.ProviderConfig
+	nextID   int
+}
+
+var _ Provider = (*FakeProvider)(nil)
+
+// Name implements Provider
+func (f *FakeProvider) Name() string {
+	return "stripe"
+}
+
+func (f *FakeProvider) env() string {
+	if f.Env == "" {
+		return string(stripe.Sandbox)
+	}
+	return f.Env
+}
+
+func (f *FakeProvider) id(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s_fake%d", prefix, f.nextID)
+}
+
+// product returns the active product of a plan or addon, creating it when missing
+func (f *FakeProvider) product(code, name string, result *provider.SyncResult) *stripe.Product {
+	for i := range f.products {
+		if f.products[i].Active && f.products[i].PlanCode == code {
+			f.products[i].Name = name
+			return &f.products[i]
+		}
+	}
+	f.products = append(f.products, stripe.Product{ID: f.id("prod"), Name: name, PlanCode: code, Active: true})
+	result.ProductsCreated++
+	return &f.products[len(f.products)-1]
+}
+
+// setPrice archives the active price of an interval when its amount changed
+// and creates the new one, like the sync engine
+func (f *FakeProvider) setPrice(p *stripe.Product, interval string, amount int, result *provider.SyncResult) string {
+	for i := range p.Prices {
+		price := &p.Prices[i]
+		if !price.Active || price.Interval != interval {
+			continue
+		}
+		if price.Amount == int64(amount) {
+			return price.ID
+		}
+		price.Active = false
+		result.PricesArchived++
+	}
+	id := f.id("price")
+	p.Prices = append(p.Prices, stripe.ProductPrice{ID: id, Interval: interval, Amount: int64(amount), Active: true})
+	result.PricesCreated++
+	return id
+}
+
+// Sync implements Provider
+func (f *FakeProvider) Sync(_ context.Context, cfg *BillingConfig) (*SyncResult, error) {
+	if f.SyncErr != nil {
+		return nil, f.SyncErr
+	}
+	f.Synced = append(f.Synced, cfg)
+
+	result := &provider.SyncResult{}
+	ids := &config.ProviderConfig{
+		Provider:    f.Name(),
+		Environment: f.env(),
+		Plans:       make(map[string]config.PlanIDs),
+		Addons:      make(map[string]config.ProductIDs),
+	}
+	for _, plan := range cfg.Plans {
+		if !plan.HasProvider(f.Name(), cfg.Providers) || !plan.IsSynced() || !plan.InEnvironment(f.env()) {
+			continue
+		}
+		product := f.product(plan.ID, plan.Name, result)
+		planIDs := config.PlanIDs{ProductID: product.ID, Prices: make(map[string]string)}
+		for interval, price := range plan.Prices {
+			planIDs.Prices[interval] = f.setPrice(product, interval, price.Amount, result)
+		}
+		ids.Plans[plan.ID] = planIDs
+	}
+	for _, addon := range cfg.Addons {
+		product := f.product(addon.ID, addon.Name, result)
+		ids.Addons[addon.ID] = config.ProductIDs{
+			ProductID: product.ID,
+			PriceID:   f.setPrice(product, "", addon.Price.Amount, result),
+		}
+	}
+	f.lastIDs = ids
+	return result, nil
+}
+
+// Import implements Provider by returning the last synced config and the IDs
+// the fake gave it
+func (f *FakeProvider) Import(context.Context) (*ImportResult, error) {
+	if f.ImportErr != nil {
+		return nil, f.ImportErr
+	}
+	if len(f.Synced) == 0 {
+		return nil, fmt.Errorf("nothing to import: Sync was never called")
+	}
+	return &provider.ImportResult{Billing: f.Synced[len(f.Synced)-1], Provider: f.lastIDs}, nil
+}
+
+// Diff implements Provider
+func (f *FakeProvider) Diff(_ context.Context, cfg *BillingConfig) (*DiffResult, time.Time, error) {
+	if f.DiffErr != nil {
+		return nil, time.Time{}, f.DiffErr
+	}
+	return diff.Compare(cfg, f.products, f.env()), time.Time{}, nil
+}
+
+// Truncate implements Provider by archiving every product and price
+func (f *FakeProvider) Truncate(context.Context, TruncateOptions) (*TruncateResult, error) {
+	if f.TruncateErr != nil {
+		return nil, f.TruncateErr
+	}
+	result := &provider.TruncateResult{}
+	for i := range f.products {
+		p := &f.products[i]
+		if !p.Active {
+			continue
+		}
+		p.Active = false
+		result.ProductsArchived++
+		for j := range p.Prices {
+			if p.Prices[j].Active {
+				p.Prices[j].Active = false
+				result.PricesArchived++
+			}
+		}
+	}
+	return result, nil
+}
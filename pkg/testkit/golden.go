@@ -0,0 +1,53 @@
+package testkit
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"raterunner/internal/diff"
+)
+
+// UpdateGoldenEnv rewrites golden files instead of comparing with them when
+// set to 1, e.g. RATERUNNER_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "RATERUNNER_UPDATE_GOLDEN"
+
+// AssertGolden compares got with the content of a golden file
+func AssertGolden(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with %s=1 to create it): %v", UpdateGoldenEnv, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs (run with %s=1 to update it)\n--- got:\n%s\n--- want:\n%s", path, UpdateGoldenEnv, got, want)
+	}
+}
+
+// AssertDiffGolden diffs the config against a provider, usually a
+// FakeProvider, and compares the table `raterunner --deterministic apply
+// --dry-run` would print with a golden file
+func (c *Config) AssertDiffGolden(p Provider, path string) {
+	c.t.Helper()
+	result, _, err := p.Diff(context.Background(), c.cfg)
+	if err != nil {
+		c.t.Fatalf("failed to diff: %v", err)
+	}
+	result.Stabilize()
+
+	var buf bytes.Buffer
+	diff.OutputTable(&buf, result)
+	AssertGolden(c.t, path, buf.Bytes())
+}
@@ -0,0 +1,168 @@
+// Package testkit helps application teams test their billing config in Go,
+// e.g. that billing.yaml grants feature X on plan Y:
+//
+//	func TestBilling(t *testing.T) {
+//		cfg := testkit.Load(t, "billing.yaml")
+//		cfg.AssertGrants("pro", "sso")
+//		cfg.AssertDenies("free", "sso")
+//		cfg.AssertLimit("team", "seats", 25)
+//		cfg.AssertPrice("pro", "monthly", 2900)
+//	}
+package testkit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"raterunner/internal/config"
+	"raterunner/internal/validator"
+)
+
+// Unlimited is the limit value of an uncapped entitlement, for AssertLimit
+const Unlimited = config.UnlimitedKeyword
+
+// Config is a validated billing config with assertions on it
+type Config struct {
+	t   testing.TB
+	cfg *BillingConfig
+}
+
+// Load validates a billing file like `raterunner validate` and loads it.
+// Validation errors fail the test.
+func Load(t testing.TB, path string) *Config {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read billing config: %v", err)
+	}
+	return parse(t, content, path)
+}
+
+// Parse validates and loads billing config YAML
+func Parse(t testing.TB, content string) *Config {
+	t.Helper()
+	return parse(t, []byte(content), "billing config")
+}
+
+func parse(t testing.TB, content []byte, name string) *Config {
+	t.Helper()
+	result, err := validator.New().ValidateBilling(content, "yaml")
+	if err != nil {
+		t.Fatalf("failed to validate %s: %v", name, err)
+	}
+	if !result.Valid {
+		msgs := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			msgs[i] = "  " + e.String()
+		}
+		t.Fatalf("%s is invalid:\n%s", name, strings.Join(msgs, "\n"))
+	}
+
+	cfg, err := config.LoadBilling(content, "yaml")
+	if err != nil {
+		t.Fatalf("failed to load %s: %v", name, err)
+	}
+	return &Config{t: t, cfg: cfg}
+}
+
+// Billing returns the loaded config
+func (c *Config) Billing() *BillingConfig {
+	return c.cfg
+}
+
+// plan returns a plan, failing the test when it doesn't exist
+func (c *Config) plan(planID string) *config.Plan {
+	c.t.Helper()
+	plan := c.cfg.FindPlan(planID)
+	if plan == nil {
+		c.t.Fatalf("plan %q not found", planID)
+	}
+	return plan
+}
+
+// Limit returns a plan's value of an entitlement, or nil when the plan
+// doesn't set it
+func (c *Config) Limit(planID, entitlement string) any {
+	c.t.Helper()
+	return c.plan(planID).Limits[entitlement]
+}
+
+// AssertGrants checks that a plan grants an entitlement: a true feature flag,
+// a limit above zero, unlimited or a rate limit
+func (c *Config) AssertGrants(planID, entitlement string) {
+	c.t.Helper()
+	value := c.Limit(planID, entitlement)
+	if !grants(value) {
+		c.t.Errorf("plan %q does not grant %q (got %s)", planID, entitlement, format(value))
+	}
+}
+
+// AssertDenies checks that a plan doesn't grant an entitlement: a false
+// feature flag, a zero limit, or no value at all
+func (c *Config) AssertDenies(planID, entitlement string) {
+	c.t.Helper()
+	value := c.Limit(planID, entitlement)
+	if grants(value) {
+		c.t.Errorf("plan %q grants %q (got %s)", planID, entitlement, format(value))
+	}
+}
+
+// AssertLimit checks a plan's exact value of an entitlement: a bool, an
+// integer, or Unlimited
+func (c *Config) AssertLimit(planID, entitlement string, want any) {
+	c.t.Helper()
+	got := c.Limit(planID, entitlement)
+	if format(got) != format(want) {
+		c.t.Errorf("plan %q: %s = %s, want %s", planID, entitlement, format(got), format(want))
+	}
+}
+
+// AssertPrice checks the flat amount of a plan's price for an interval, in
+// the smallest currency unit
+func (c *Config) AssertPrice(planID, interval string, amount int) {
+	c.t.Helper()
+	price, ok := c.plan(planID).Prices[interval]
+	if !ok {
+		c.t.Errorf("plan %q has no %s price", planID, interval)
+		return
+	}
+	if price.Amount != amount {
+		c.t.Errorf("plan %q: %s price = %d, want %d", planID, interval, price.Amount, amount)
+	}
+}
+
+// grants reports whether a limit value gives access
+func grants(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case int:
+		return v > 0
+	case float64:
+		return v > 0
+	case config.RateLimit:
+		return v.Limit > 0
+	case nil:
+		return false
+	}
+	return config.IsUnlimited(value)
+}
+
+// format prints limit values the same whether they came from YAML, JSON or Go
+func format(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "<unset>"
+	case config.Unlimited:
+		return Unlimited
+	case config.RateLimit:
+		return fmt.Sprintf("%d/%s", v.Limit, v.Per)
+	case float64:
+		if v == float64(int64(v)) {
+			return fmt.Sprint(int64(v))
+		}
+	}
+	return fmt.Sprint(value)
+}
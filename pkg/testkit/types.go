@@ -0,0 +1,23 @@
+package testkit
+
+import (
+	"raterunner/internal/config"
+	"raterunner/internal/diff"
+	"raterunner/internal/provider"
+)
+
+// The raterunner types the helpers take and return. They live under
+// internal/, so they are re-exported here for tests in other modules to name
+// them, e.g. to write their own Provider.
+type (
+	BillingConfig   = config.BillingConfig
+	Plan            = config.Plan
+	Entitlement     = config.Entitlement
+	ProviderConfig  = config.ProviderConfig
+	Provider        = provider.Provider
+	SyncResult      = provider.SyncResult
+	ImportResult    = provider.ImportResult
+	TruncateOptions = provider.TruncateOptions
+	TruncateResult  = provider.TruncateResult
+	DiffResult      = diff.DiffResult
+)